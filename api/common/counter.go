@@ -0,0 +1,115 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var COUNTER Counter
+
+func InitCounter() {
+	if RedisEnabled {
+		COUNTER = NewRedisCounter(RDB)
+	} else {
+		COUNTER = NewLocalCounter()
+	}
+}
+
+// Counter 固定窗口计数器，用于限流，多实例部署时需使用 Redis
+type Counter interface {
+	// Incr 计数加一并返回当前窗口内的计数
+	// name: 计数器名称
+	// window: 窗口时长，从第一次计数开始计算
+	Incr(name string, window time.Duration) (int64, error)
+	// Get 返回当前窗口内的计数，计数器不存在或已过期时返回 0
+	Get(name string) (int64, error)
+}
+
+func NewLocalCounter() *LocalCounter {
+	return &LocalCounter{}
+}
+
+type LocalCounter struct {
+	mu       sync.Mutex
+	counters map[string]*counterEntry
+	// lastSweep 上次清理过期计数器的时间
+	lastSweep time.Time
+}
+
+type counterEntry struct {
+	count     int64
+	expiresAt time.Time
+}
+
+func (lc *LocalCounter) Incr(name string, window time.Duration) (int64, error) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	now := time.Now()
+	if lc.counters == nil {
+		lc.counters = make(map[string]*counterEntry)
+	}
+	if now.Sub(lc.lastSweep) > time.Minute {
+		for k, e := range lc.counters {
+			if now.After(e.expiresAt) {
+				delete(lc.counters, k)
+			}
+		}
+		lc.lastSweep = now
+	}
+
+	entry, ok := lc.counters[name]
+	if !ok || now.After(entry.expiresAt) {
+		entry = &counterEntry{expiresAt: now.Add(window)}
+		lc.counters[name] = entry
+	}
+	entry.count++
+	return entry.count, nil
+}
+
+func (lc *LocalCounter) Get(name string) (int64, error) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	entry, ok := lc.counters[name]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return 0, nil
+	}
+	return entry.count, nil
+}
+
+type RedisCounter struct {
+	client redis.Cmdable
+}
+
+func NewRedisCounter(client redis.Cmdable) *RedisCounter {
+	return &RedisCounter{client: client}
+}
+
+func (rc *RedisCounter) Incr(name string, window time.Duration) (int64, error) {
+	ctx := context.Background()
+	key := "counter:" + name
+	count, err := rc.client.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	// 第一次计数时设置过期时间，即窗口时长
+	if count == 1 {
+		if err := rc.client.Expire(ctx, key, window).Err(); err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
+
+func (rc *RedisCounter) Get(name string) (int64, error) {
+	count, err := rc.client.Get(context.Background(), "counter:"+name).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return count, err
+}
@@ -21,4 +21,17 @@ const (
 	AvailableModels   = "available_models"
 	KeyRequestBody    = "key_request_body"
 	SystemPrompt      = "system_prompt"
+	Citations         = "citations"
+	ParentMessageID   = "parent_message_id"
+	BranchRoot        = "branch_root"
+	PromptVariables   = "prompt_variables"
+	Guardrail         = "guardrail"
+	MessageID         = "message_id"
+	// 编排智能体的路由请求携带的子智能体工具
+	OrchestratorTools = "orchestrator_tools"
+	// 子智能体的回答，作为编排智能体最终回答的参考资料
+	SubAgentContext    = "sub_agent_context"
+	SubAgentMessageIDs = "sub_agent_message_ids"
+	// 不属于会话的内部调用，其用量计入的会话
+	UsageConversationID = "usage_conversation_id"
 )
@@ -39,4 +39,6 @@ func Init() {
 	// Initialize the logger
 	InitRedisClient()
 	InitLocker()
+	InitCounter()
+	InitStateStore()
 }
@@ -15,4 +15,6 @@ const (
 	SESSION_REQUEST_PROTOCOL = "SESSION_REQUEST_PROTOCOL"
 	SESSION_REQUEST_DOMAIN   = "SESSION_REQUEST_DOMAIN"
 	SESSION_ENV_VERSION      = "SESSION_ENV_VERSION"
+	SESSION_EMBED            = "SESSION_EMBED"
+	SESSION_EMBED_SESSION    = "SESSION_EMBED_SESSION"
 )
@@ -0,0 +1,154 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// STATES 默认保存在本地内存中，InitStateStore 在启用 Redis 时切换为 Redis
+var STATES StateStore = NewLocalStateStore()
+
+var ErrStateNotFound = errors.New("state not found or expired")
+
+func InitStateStore() {
+	if RedisEnabled {
+		STATES = NewRedisStateStore(RDB)
+	} else {
+		STATES = NewLocalStateStore()
+	}
+}
+
+// StateStore 保存有有效期的临时状态，如单点登录的 state、两步验证的预认证令牌。
+// 值以 JSON 保存，未启用 Redis 时保存在本地内存中，仅适用于单实例部署
+type StateStore interface {
+	// Save 保存状态，ttl 到期后自动失效
+	Save(key string, value interface{}, ttl time.Duration) error
+	// Load 读取状态但不删除，不存在或已过期时返回 ErrStateNotFound
+	Load(key string, value interface{}) error
+	// Consume 读取并删除状态，同一状态只能被取回一次
+	Consume(key string, value interface{}) error
+	Delete(key string) error
+}
+
+func NewLocalStateStore() *LocalStateStore {
+	return &LocalStateStore{}
+}
+
+type LocalStateStore struct {
+	mu     sync.Mutex
+	states map[string]*stateEntry
+	// lastSweep 上次清理过期状态的时间
+	lastSweep time.Time
+}
+
+type stateEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+func (ls *LocalStateStore) Save(key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+
+	now := time.Now()
+	if ls.states == nil {
+		ls.states = make(map[string]*stateEntry)
+	}
+	if now.Sub(ls.lastSweep) > time.Minute {
+		for k, e := range ls.states {
+			if now.After(e.expiresAt) {
+				delete(ls.states, k)
+			}
+		}
+		ls.lastSweep = now
+	}
+	ls.states[key] = &stateEntry{value: data, expiresAt: now.Add(ttl)}
+	return nil
+}
+
+func (ls *LocalStateStore) Load(key string, value interface{}) error {
+	return ls.get(key, value, false)
+}
+
+func (ls *LocalStateStore) Consume(key string, value interface{}) error {
+	return ls.get(key, value, true)
+}
+
+func (ls *LocalStateStore) Delete(key string) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	delete(ls.states, key)
+	return nil
+}
+
+func (ls *LocalStateStore) get(key string, value interface{}, remove bool) error {
+	ls.mu.Lock()
+	entry, ok := ls.states[key]
+	if ok && (remove || time.Now().After(entry.expiresAt)) {
+		delete(ls.states, key)
+	}
+	ls.mu.Unlock()
+
+	if !ok || time.Now().After(entry.expiresAt) {
+		return ErrStateNotFound
+	}
+	return json.Unmarshal(entry.value, value)
+}
+
+type RedisStateStore struct {
+	client redis.Cmdable
+}
+
+func NewRedisStateStore(client redis.Cmdable) *RedisStateStore {
+	return &RedisStateStore{client: client}
+}
+
+func (rs *RedisStateStore) Save(key string, value interface{}, ttl time.Duration) error {
+	if ttl <= 0 {
+		return ErrStateNotFound
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return rs.client.Set(context.Background(), key, data, ttl).Err()
+}
+
+func (rs *RedisStateStore) Load(key string, value interface{}) error {
+	data, err := rs.client.Get(context.Background(), key).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return ErrStateNotFound
+		}
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
+
+func (rs *RedisStateStore) Consume(key string, value interface{}) error {
+	if err := rs.Load(key, value); err != nil {
+		return err
+	}
+	// 以删除成功作为取回成功，并发取回同一状态时只有一个请求成功
+	deleted, err := rs.client.Del(context.Background(), key).Result()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrStateNotFound
+	}
+	return nil
+}
+
+func (rs *RedisStateStore) Delete(key string) error {
+	return rs.client.Del(context.Background(), key).Err()
+}
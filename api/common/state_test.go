@@ -0,0 +1,47 @@
+package common
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLocalStateStore(t *testing.T) {
+	type state struct {
+		Eid int64 `json:"eid"`
+	}
+	store := NewLocalStateStore()
+	if err := store.Save("a", &state{Eid: 1}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	var loaded state
+	if err := store.Load("a", &loaded); err != nil || loaded.Eid != 1 {
+		t.Fatalf("load: %+v %v", loaded, err)
+	}
+	var consumed state
+	if err := store.Consume("a", &consumed); err != nil || consumed.Eid != 1 {
+		t.Fatalf("consume: %+v %v", consumed, err)
+	}
+	// 状态只能取回一次
+	if err := store.Consume("a", &consumed); !errors.Is(err, ErrStateNotFound) {
+		t.Fatalf("second consume err = %v", err)
+	}
+
+	if err := store.Save("b", &state{Eid: 2}, -time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Load("b", &loaded); !errors.Is(err, ErrStateNotFound) {
+		t.Fatalf("expired state err = %v", err)
+	}
+
+	if err := store.Save("c", &state{Eid: 3}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Load("c", &loaded); !errors.Is(err, ErrStateNotFound) {
+		t.Fatalf("deleted state err = %v", err)
+	}
+}
@@ -1,6 +1,8 @@
 package jwt
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"time"
 
 	"github.com/53AI/53AIHub/common/utils/env"
@@ -9,11 +11,20 @@ import (
 
 var secretKey = []byte(env.String("JWT_SECRET", "secret"))
 
+// UserTokenTTL 用户访问令牌有效期
+const UserTokenTTL = 168 * time.Hour
+
 func UserGenerateJWT(userID int64, eid int64) (string, error) {
+	// jti 保证同一用户同一秒内多次登录签发的令牌也不相同
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
 	claims := jwt.MapClaims{
 		"user_id": userID,
 		"eid":     eid,
-		"exp":     time.Now().Add(168 * time.Hour).Unix(),
+		"exp":     time.Now().Add(UserTokenTTL).Unix(),
+		"jti":     hex.EncodeToString(jti),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 按顺序匹配，Edge、Opera 等基于 Chromium 的浏览器 UA 中也包含 Chrome，需排在前面
+var (
+	userAgentBrowsers = [][2]string{
+		{"MicroMessenger", "WeChat"},
+		{"DingTalk", "DingTalk"},
+		{"Lark", "Feishu"},
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"CriOS/", "Chrome"},
+		{"Safari/", "Safari"},
+	}
+	userAgentPlatforms = [][2]string{
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	}
+)
+
+// GetClientDevice 根据 User-Agent 生成简短的设备描述，如 "Chrome on Windows"
+func GetClientDevice(c *gin.Context) string {
+	return ParseDevice(c.Request.UserAgent())
+}
+
+// ParseDevice 无法识别时返回 "Unknown"，非浏览器客户端（如 curl、SDK）返回 UA 的产品名
+func ParseDevice(userAgent string) string {
+	browser, platform := "", ""
+	for _, item := range userAgentBrowsers {
+		if strings.Contains(userAgent, item[0]) {
+			browser = item[1]
+			break
+		}
+	}
+	for _, item := range userAgentPlatforms {
+		if strings.Contains(userAgent, item[0]) {
+			platform = item[1]
+			break
+		}
+	}
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	}
+	if product := strings.Fields(userAgent); len(product) > 0 {
+		return strings.SplitN(product[0], "/", 2)[0]
+	}
+	return "Unknown"
+}
@@ -9,3 +9,6 @@ var DebugSQLEnabled = env.Bool("DEBUG_SQL", false)
 
 var SQLitePath = "53ai-hub.db"
 var SQLiteBusyTimeout = env.Int("SQLITE_BUSY_TIMEOUT", 3000)
+
+// MessageSearchEngine 消息全文检索引擎：auto（SQLite 使用 FTS5，MySQL 使用 FULLTEXT）、like
+var MessageSearchEngine = env.String("MESSAGE_SEARCH_ENGINE", "auto")
@@ -0,0 +1,13 @@
+package config
+
+import "github.com/53AI/53AIHub/common/utils/env"
+
+// VectorStoreType 知识库向量存储：memory（进程内）、pgvector、qdrant
+var VectorStoreType = env.String("VECTOR_STORE", "memory")
+var PgVectorDSN = env.String("PGVECTOR_DSN", "")
+var QdrantURL = env.String("QDRANT_URL", "http://localhost:6333")
+var QdrantAPIKey = env.String("QDRANT_API_KEY", "")
+var QdrantCollectionPrefix = env.String("QDRANT_COLLECTION_PREFIX", "hub_kb_")
+
+var KnowledgeEmbeddingBatchSize = env.Int("KNOWLEDGE_EMBEDDING_BATCH_SIZE", 16)
+var KnowledgeMaxContextLength = env.Int("KNOWLEDGE_MAX_CONTEXT_LENGTH", 4000)
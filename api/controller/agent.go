@@ -8,11 +8,13 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/53AI/53AIHub/common"
 	"github.com/53AI/53AIHub/common/utils"
 	"github.com/53AI/53AIHub/config"
 	"github.com/53AI/53AIHub/model"
 	"github.com/53AI/53AIHub/service"
+	"github.com/53AI/53AIHub/service/agentversion"
+	"github.com/53AI/53AIHub/service/prompttpl"
+	"github.com/53AI/53AIHub/service/rbac"
 	"github.com/gin-gonic/gin"
 )
 
@@ -47,7 +49,9 @@ type AgentRequest struct {
 	Enable               bool    `json:"enable" example:"true"`
 	SubscriptionGroupIds []int64 `json:"subscription_group_ids"` // 订阅分组IDs
 	Settings             string  `json:"settings" example:"{}"`
-	AgentType            int     `json:"agent_type" example:"0"` // Agent type (0=App, 1=Workflow), default is 0
+	AgentType            int     `json:"agent_type" example:"0"` // Agent type (0=App, 1=Workflow, 2=Orchestrator), default is 0
+	// Publish 更新时直接发布，不经过草稿
+	Publish bool `json:"publish" example:"false"`
 }
 
 type UpdateAgentEnableRequest struct {
@@ -55,7 +59,7 @@ type UpdateAgentEnableRequest struct {
 }
 
 // @Summary Create a new agent
-// @Description Create agent with configurable parameters. agent_type: 0=App (default), 1=Workflow
+// @Description Create agent with configurable parameters. agent_type: 0=App (default), 1=Workflow, 2=Orchestrator (sub agents in custom_config.orchestrator)
 // @Tags Agent
 // @Accept json
 // @Produce json
@@ -69,8 +73,8 @@ func CreateAgent(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
 		return
 	}
-	// Check if user is admin
-	if !common.IsAdmin(c) {
+	// Check if user can edit agents
+	if !rbac.HasPermission(c, model.PermAgentWrite) {
 		c.JSON(http.StatusForbidden, model.AuthFailed.ToResponse(nil))
 		return
 	}
@@ -80,6 +84,14 @@ func CreateAgent(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(errors.New("model is required")))
 		return
 	}
+	if err := prompttpl.Validate(agentReq.Prompt); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if err := validateOrchestratorConfig(config.GetEID(c), 0, agentReq.AgentType, agentReq.CustomConfig); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
 
 	params := map[string]interface{}{
 		"from": "agent",
@@ -156,6 +168,11 @@ func CreateAgent(c *gin.Context) {
 		return
 	}
 
+	if err := agentversion.Init(&agent, agent.CreatedBy); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+
 	// Parse CustomConfig to get agent_type
 	var customConfig map[string]interface{}
 	if err := json.Unmarshal([]byte(agentReq.CustomConfig), &customConfig); err != nil {
@@ -208,8 +225,8 @@ func GetAgent(c *gin.Context) {
 		return
 	}
 
-	// Check if user is admin or has permission to access this agent
-	if !common.IsAdmin(c) {
+	// Check if user can view all agents or has permission to access this agent
+	if !rbac.HasPermission(c, model.PermAgentRead) {
 		hasPermission, err := model.CheckPermission(config.GetUserGroupID(c), agent_id, model.ResourceTypeAgent, model.PermissionRead)
 		if err != nil || !hasPermission {
 			c.JSON(http.StatusForbidden, model.AuthFailed.ToResponse(nil))
@@ -221,11 +238,13 @@ func GetAgent(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(nil))
 		return
 	}
+	agent.HasDraft = model.HasAgentDraft(agent.AgentID)
 	c.JSON(http.StatusOK, model.Success.ToResponse(agent))
 }
 
 // @Summary Update agent
-// @Description Update existing agent details. agent_type: 0=App (default), 1=Workflow
+// @Description Update existing agent details. agent_type: 0=App (default), 1=Workflow, 2=Orchestrator (sub agents in custom_config.orchestrator).
+// @Description 模型、提示词、配置、工具、自定义配置和设置写入草稿，发布后才对用户生效；publish 为 true 时直接发布
 // @Tags Agent
 // @Accept json
 // @Produce json
@@ -247,8 +266,8 @@ func UpdateAgent(c *gin.Context) {
 		return
 	}
 
-	// Check if user is admin
-	if !common.IsAdmin(c) {
+	// Check if user can edit agents
+	if !rbac.HasPermission(c, model.PermAgentWrite) {
 		c.JSON(http.StatusForbidden, model.AuthFailed.ToResponse(nil))
 		return
 	}
@@ -264,6 +283,14 @@ func UpdateAgent(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(errors.New("model is required")))
 		return
 	}
+	if err := prompttpl.Validate(agentReq.Prompt); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if err := validateOrchestratorConfig(eid, agent_id, agentReq.AgentType, agentReq.CustomConfig); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
 
 	// Start transaction
 	tx := model.DB.Begin()
@@ -274,21 +301,14 @@ func UpdateAgent(c *gin.Context) {
 
 	oldAgent := *agent
 
-	// Update agent fields
+	// Update agent fields，纳入版本管理的字段在提交后写入草稿
 	agent.Name = agentReq.Name
 	agent.Description = agentReq.Description
-	agent.Model = agentReq.Model
-	agent.Prompt = agentReq.Prompt
-	agent.Configs = agentReq.Configs
-	agent.Tools = agentReq.Tools
 	agent.GroupID = agentReq.GroupId
 	agent.UseCases = agentReq.UseCases
-	agent.ChannelType = agentReq.ChannelType
 	agent.Sort = agentReq.Sort
 	agent.Logo = agentReq.Logo
-	agent.CustomConfig = agentReq.CustomConfig
 	agent.Enable = agentReq.Enable
-	agent.Settings = agentReq.Settings
 	agent.AgentType = agentReq.AgentType // 添加 AgentType 字段更新
 
 	if err := tx.Save(agent).Error; err != nil {
@@ -357,6 +377,30 @@ func UpdateAgent(c *gin.Context) {
 		return
 	}
 
+	draft, err := agentversion.SaveDraft(agent, model.AgentSnapshot{
+		ChannelType:  agentReq.ChannelType,
+		Model:        agentReq.Model,
+		Prompt:       agentReq.Prompt,
+		Configs:      agentReq.Configs,
+		Tools:        agentReq.Tools,
+		CustomConfig: agentReq.CustomConfig,
+		Settings:     agentReq.Settings,
+	}, config.GetUserId(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	agent.HasDraft = draft != nil
+	if draft != nil && agentReq.Publish {
+		version, err := agentversion.Publish(agent, "", config.GetUserId(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+			return
+		}
+		agent.HasDraft = false
+		logAgentAction(c, model.SystemLogActionUpdate, fmt.Sprintf("发布智能体【%s】版本 v%d", agent.Name, version.Version))
+	}
+
 	// Prepare for logging
 	fieldMap := map[string]string{
 		"Name":        "名称",
@@ -404,8 +448,8 @@ func DeleteAgent(c *gin.Context) {
 		return
 	}
 
-	// Check if user is admin
-	if !common.IsAdmin(c) {
+	// Check if user can edit agents
+	if !rbac.HasPermission(c, model.PermAgentWrite) {
 		c.JSON(http.StatusForbidden, model.AuthFailed.ToResponse(nil))
 		return
 	}
@@ -431,6 +475,42 @@ func DeleteAgent(c *gin.Context) {
 		return
 	}
 
+	if err := model.DeleteAgentVersions(tx, agent_id); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(nil))
+		return
+	}
+
+	if err := model.DeleteAgentExperiments(tx, agent_id); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(nil))
+		return
+	}
+
+	if err := model.DeleteAgentEvalDatasets(tx, agent_id); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(nil))
+		return
+	}
+
+	if err := model.DeleteGuardrailPolicy(tx, eid, agent_id); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(nil))
+		return
+	}
+
+	if err := model.DeleteAgentSchedules(tx, agent_id); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(nil))
+		return
+	}
+
+	if err := model.DeleteAgentEmbeds(tx, agent_id); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(nil))
+		return
+	}
+
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
 		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(nil))
@@ -482,7 +562,7 @@ func GetAgents(c *gin.Context) {
 	var err error
 	channelTypes := splitChannelTypesString(agentListRequest.ChannelTypes)
 	agentTypes := splitAgentTypesString(agentListRequest.AgentTypes)
-	if common.IsAdmin(c) {
+	if rbac.HasPermission(c, model.PermAgentRead) {
 		total, agents, err = model.GetAgentListWithIDs(
 			config.GetEID(c), agentListRequest.Keyword, agentListRequest.GroupId,
 			nil, channelTypes, agentTypes, agentListRequest.Offset, agentListRequest.Limit)
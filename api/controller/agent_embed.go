@@ -0,0 +1,490 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/53AI/53AIHub/common/ctxkey"
+	"github.com/53AI/53AIHub/common/session"
+	"github.com/53AI/53AIHub/common/utils"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/embed"
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+)
+
+// embedHistoryLimit 访客提问时带上的历史消息条数
+const embedHistoryLimit = 10
+
+type AgentEmbedRequest struct {
+	Name string `json:"name" binding:"required" example:"官网客服"`
+	// Domains 允许嵌入的域名，支持 *.example.com 匹配子域名
+	Domains []string `json:"domains" example:"www.example.com"`
+	// RateLimit 每个 IP 每分钟的请求上限，DailyLimit 每个 IP 每天的提问上限，0 使用默认值
+	RateLimit  int `json:"rate_limit" example:"10"`
+	DailyLimit int `json:"daily_limit" example:"100"`
+	// SessionTokenLimit 每个访客会话的 token 上限，DailyTokenLimit 每个 IP 每天的 token 上限，0 使用默认值
+	SessionTokenLimit int   `json:"session_token_limit" example:"50000"`
+	DailyTokenLimit   int   `json:"daily_token_limit" example:"200000"`
+	Enabled           *bool `json:"enabled" example:"true"`
+}
+
+type EmbedSessionsResponse struct {
+	Count    int64                 `json:"count"`
+	Sessions []*model.EmbedSession `json:"sessions"`
+}
+
+func pathAgentEmbed(c *gin.Context) (*model.Agent, *model.AgentEmbed, bool) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return nil, nil, false
+	}
+	id, err := strconv.ParseInt(c.Param("embed_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return nil, nil, false
+	}
+	agentEmbed, err := model.GetAgentEmbed(agent.Eid, agent.AgentID, id)
+	if err != nil {
+		embedError(c, err)
+		return nil, nil, false
+	}
+	return agent, agentEmbed, true
+}
+
+func embedError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, model.ErrAgentEmbedNotFound):
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(err))
+	case errors.Is(err, embed.ErrInvalidDomain), errors.Is(err, embed.ErrInvalidLimit), errors.Is(err, embed.ErrInvalidAgent):
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+	case errors.Is(err, embed.ErrRateLimited), errors.Is(err, embed.ErrDailyLimit), errors.Is(err, embed.ErrTokenLimit):
+		c.JSON(http.StatusTooManyRequests, model.OperateTooFast.ToResponse(err))
+	default:
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+	}
+}
+
+// @Summary 获取智能体的嵌入配置列表
+// @Tags AgentEmbed
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Success 200 {object} model.CommonResponse{data=[]model.AgentEmbed} "成功"
+// @Router /api/agents/{agent_id}/embeds [get]
+func GetAgentEmbeds(c *gin.Context) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return
+	}
+	embeds, err := model.GetAgentEmbeds(agent.Eid, agent.AgentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(embeds))
+}
+
+// @Summary 创建嵌入配置
+// @Description 生成嵌入令牌，白名单域名的网页可通过 /api/embed/{token} 匿名使用该智能体，不检查访客的分组权限
+// @Tags AgentEmbed
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param request body AgentEmbedRequest true "嵌入配置"
+// @Success 200 {object} model.CommonResponse{data=model.AgentEmbed} "成功"
+// @Router /api/agents/{agent_id}/embeds [post]
+func CreateAgentEmbed(c *gin.Context) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return
+	}
+	if err := embed.ValidateAgent(agent); err != nil {
+		embedError(c, err)
+		return
+	}
+	agentEmbed := &model.AgentEmbed{
+		Eid:       agent.Eid,
+		AgentID:   agent.AgentID,
+		Token:     embed.NewToken(embed.TokenPrefix),
+		Enabled:   true,
+		CreatedBy: config.GetUserId(c),
+	}
+	if !bindAgentEmbed(c, agentEmbed) {
+		return
+	}
+	guest := &model.User{
+		Eid:      agent.Eid,
+		Username: "guest_" + agentEmbed.Token[len(embed.TokenPrefix):len(embed.TokenPrefix)+12],
+		Nickname: fmt.Sprintf("访客（%s）", agentEmbed.Name),
+		Role:     model.RoleGuestUser,
+		Type:     model.UserTypeGuest,
+		Status:   model.UserStatusJoined,
+	}
+	if err := model.CreateAgentEmbed(agentEmbed, guest); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	logAgentAction(c, model.SystemLogActionCreate, fmt.Sprintf("创建智能体【%s】的嵌入配置【%s】", agent.Name, agentEmbed.Name))
+	c.JSON(http.StatusOK, model.Success.ToResponse(agentEmbed))
+}
+
+// @Summary 修改嵌入配置
+// @Tags AgentEmbed
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param embed_id path int true "嵌入配置ID"
+// @Param request body AgentEmbedRequest true "嵌入配置"
+// @Success 200 {object} model.CommonResponse{data=model.AgentEmbed} "成功"
+// @Router /api/agents/{agent_id}/embeds/{embed_id} [put]
+func UpdateAgentEmbed(c *gin.Context) {
+	agent, agentEmbed, ok := pathAgentEmbed(c)
+	if !ok {
+		return
+	}
+	if !bindAgentEmbed(c, agentEmbed) {
+		return
+	}
+	if err := model.SaveAgentEmbed(agentEmbed); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	logAgentAction(c, model.SystemLogActionUpdate, fmt.Sprintf("修改智能体【%s】的嵌入配置【%s】", agent.Name, agentEmbed.Name))
+	c.JSON(http.StatusOK, model.Success.ToResponse(agentEmbed))
+}
+
+func bindAgentEmbed(c *gin.Context, agentEmbed *model.AgentEmbed) bool {
+	var req AgentEmbedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return false
+	}
+	domains, err := embed.NormalizeDomains(req.Domains)
+	if err != nil {
+		embedError(c, err)
+		return false
+	}
+	agentEmbed.Name = req.Name
+	agentEmbed.Domains = domains
+	agentEmbed.RateLimit = req.RateLimit
+	agentEmbed.DailyLimit = req.DailyLimit
+	agentEmbed.SessionTokenLimit = req.SessionTokenLimit
+	agentEmbed.DailyTokenLimit = req.DailyTokenLimit
+	if req.Enabled != nil {
+		agentEmbed.Enabled = *req.Enabled
+	}
+	if err := embed.ValidateLimits(agentEmbed); err != nil {
+		embedError(c, err)
+		return false
+	}
+	return true
+}
+
+// @Summary 重置嵌入令牌
+// @Description 原令牌及其访客会话立即失效，网页需更新嵌入代码
+// @Tags AgentEmbed
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param embed_id path int true "嵌入配置ID"
+// @Success 200 {object} model.CommonResponse{data=model.AgentEmbed} "成功"
+// @Router /api/agents/{agent_id}/embeds/{embed_id}/reset_token [post]
+func ResetAgentEmbedToken(c *gin.Context) {
+	agent, agentEmbed, ok := pathAgentEmbed(c)
+	if !ok {
+		return
+	}
+	agentEmbed.Token = embed.NewToken(embed.TokenPrefix)
+	if err := model.SaveAgentEmbed(agentEmbed); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	logAgentAction(c, model.SystemLogActionUpdate, fmt.Sprintf("重置智能体【%s】的嵌入配置【%s】的令牌", agent.Name, agentEmbed.Name))
+	c.JSON(http.StatusOK, model.Success.ToResponse(agentEmbed))
+}
+
+// @Summary 删除嵌入配置
+// @Description 嵌入令牌立即失效，访客的对话记录保留
+// @Tags AgentEmbed
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param embed_id path int true "嵌入配置ID"
+// @Success 200 {object} model.CommonResponse "成功"
+// @Router /api/agents/{agent_id}/embeds/{embed_id} [delete]
+func DeleteAgentEmbed(c *gin.Context) {
+	agent, agentEmbed, ok := pathAgentEmbed(c)
+	if !ok {
+		return
+	}
+	if err := model.DeleteAgentEmbed(agentEmbed); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	logAgentAction(c, model.SystemLogActionDelete, fmt.Sprintf("删除智能体【%s】的嵌入配置【%s】", agent.Name, agentEmbed.Name))
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+// @Summary 获取嵌入配置的访客对话
+// @Description 列出有对话的访客会话及其对话，消息通过 /api/conversations/{conversation_id}/messages 查看
+// @Tags AgentEmbed
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param embed_id path int true "嵌入配置ID"
+// @Param offset query int false "Pagination offset" default(0)
+// @Param limit query int false "Pagination limit" default(10)
+// @Success 200 {object} model.CommonResponse{data=EmbedSessionsResponse} "成功"
+// @Router /api/agents/{agent_id}/embeds/{embed_id}/sessions [get]
+func GetAgentEmbedSessions(c *gin.Context) {
+	_, agentEmbed, ok := pathAgentEmbed(c)
+	if !ok {
+		return
+	}
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	count, sessions, err := model.GetEmbedSessions(agentEmbed.Eid, agentEmbed.ID, offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(EmbedSessionsResponse{Count: count, Sessions: sessions}))
+}
+
+type EmbedInfoResponse struct {
+	Name        string `json:"name" example:"官网客服"`
+	Logo        string `json:"logo"`
+	Description string `json:"description"`
+}
+
+type EmbedSessionResponse struct {
+	SessionToken string `json:"session_token"`
+	ExpiredTime  int64  `json:"expired_time"`
+}
+
+type EmbedChatRequest struct {
+	Query  string `json:"query" binding:"required" example:"你们的营业时间是？"`
+	Stream bool   `json:"stream"`
+}
+
+type EmbedMessage struct {
+	ID          int64  `json:"id"`
+	Query       string `json:"query"`
+	Answer      string `json:"answer"`
+	CreatedTime int64  `json:"created_time"`
+}
+
+// embedAgent 获取嵌入的智能体，智能体停用后访客无法使用
+func embedAgent(c *gin.Context) (*model.AgentEmbed, *model.Agent, bool) {
+	agentEmbed := c.MustGet(session.SESSION_EMBED).(*model.AgentEmbed)
+	agent, err := model.GetAgentByID(agentEmbed.Eid, agentEmbed.AgentID)
+	if err != nil || !agent.Enable {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(nil))
+		return nil, nil, false
+	}
+	return agentEmbed, agent, true
+}
+
+// EmbedPreflight 跨域预检请求由 EmbedAuth 处理
+func EmbedPreflight(c *gin.Context) {
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary 获取嵌入智能体信息
+// @Description 嵌入组件的公开接口，请求来源需在嵌入配置的域名白名单中
+// @Tags Embed
+// @Produce json
+// @Param token path string true "嵌入令牌"
+// @Success 200 {object} model.CommonResponse{data=EmbedInfoResponse} "成功"
+// @Router /api/embed/{token} [get]
+func GetEmbedInfo(c *gin.Context) {
+	_, agent, ok := embedAgent(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(EmbedInfoResponse{
+		Name:        agent.Name,
+		Logo:        agent.Logo,
+		Description: agent.Description,
+	}))
+}
+
+// @Summary 创建访客会话
+// @Description 匿名访客以访客身份使用嵌入的智能体，返回的会话令牌通过 Authorization: Bearer 传递
+// @Tags Embed
+// @Produce json
+// @Param token path string true "嵌入令牌"
+// @Success 200 {object} model.CommonResponse{data=EmbedSessionResponse} "成功"
+// @Router /api/embed/{token}/sessions [post]
+func CreateEmbedSession(c *gin.Context) {
+	agentEmbed, _, ok := embedAgent(c)
+	if !ok {
+		return
+	}
+	userAgent := c.Request.UserAgent()
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	guestSession := &model.EmbedSession{
+		Eid:         agentEmbed.Eid,
+		EmbedID:     agentEmbed.ID,
+		Token:       embed.NewToken(embed.SessionTokenPrefix),
+		IP:          utils.GetClientIP(c),
+		UserAgent:   userAgent,
+		ExpiredTime: time.Now().Add(model.EmbedSessionTTL).UTC().UnixMilli(),
+	}
+	if err := model.CreateEmbedSession(guestSession); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(EmbedSessionResponse{
+		SessionToken: guestSession.Token,
+		ExpiredTime:  guestSession.ExpiredTime,
+	}))
+}
+
+// @Summary 访客提问
+// @Description 每个访客会话对应一个对话，服务端保存历史并带上最近的消息；响应与 /v1/chat/completions 相同，stream 为 true 时返回 SSE；
+// @Description 超出提问次数或访客会话、IP 的 token 预算时返回 429
+// @Tags Embed
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param token path string true "嵌入令牌"
+// @Param request body EmbedChatRequest true "提问"
+// @Success 200 {object} model.OpenAIErrorResponse
+// @Router /api/embed/{token}/chat [post]
+func EmbedChat(c *gin.Context) {
+	agentEmbed, agent, ok := embedAgent(c)
+	if !ok {
+		return
+	}
+	guestSession := c.MustGet(session.SESSION_EMBED_SESSION).(*model.EmbedSession)
+	var req EmbedChatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	ip := utils.GetClientIP(c)
+	if err := embed.CheckTokens(agentEmbed, guestSession, ip, time.Now()); err != nil {
+		embedError(c, err)
+		return
+	}
+	if err := embed.CheckDaily(agentEmbed, ip, time.Now()); err != nil {
+		embedError(c, err)
+		return
+	}
+
+	conversation, err := embedConversation(agentEmbed, guestSession, req.Query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	messages, err := embedHistory(conversation)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	messages = append(messages, Message{Role: "user", Content: req.Query})
+
+	// 按对话接口的方式转发，meta 根据请求路径判断转发模式
+	c.Request.URL.Path = "/v1/chat/completions"
+	c.Set(session.SESSION_AGENT_ID, agent.AgentID)
+	c.Set(session.SESSION_AGENT, agent)
+	c.Set(session.SESSION_CONVERSATION_ID, conversation.ConversationID)
+	c.Set(session.SESSION_CONVERSATION, conversation)
+	c.Set(ctxkey.Group, "vip")
+	processChatRequest(c, &ChatRequest{
+		Messages:       messages,
+		Stream:         req.Stream,
+		ConversationID: conversation.ConversationID,
+	}, agent, relaymode.ChatCompletions)
+}
+
+// embedConversation 访客会话首次提问时创建对话
+func embedConversation(agentEmbed *model.AgentEmbed, guestSession *model.EmbedSession, query string) (*model.Conversation, error) {
+	if guestSession.ConversationID > 0 {
+		return model.GetConversationByID(agentEmbed.Eid, agentEmbed.GuestUserID, guestSession.ConversationID)
+	}
+	title := []rune(query)
+	if len(title) > 50 {
+		title = title[:50]
+	}
+	conversation := &model.Conversation{
+		Eid:     agentEmbed.Eid,
+		UserID:  agentEmbed.GuestUserID,
+		AgentID: agentEmbed.AgentID,
+		Title:   string(title),
+		Status:  model.ConversationStatusActive,
+	}
+	if err := model.CreateConversation(conversation); err != nil {
+		return nil, err
+	}
+	if err := model.UpdateEmbedSessionConversation(guestSession.ID, conversation.ConversationID); err != nil {
+		return nil, err
+	}
+	guestSession.ConversationID = conversation.ConversationID
+	return conversation, nil
+}
+
+// embedHistory 对话当前分支上最近的问答，按时间正序
+func embedHistory(conversation *model.Conversation) ([]Message, error) {
+	_, records, err := model.GetMessagesByConversationIDWithDirection(conversation.Eid, conversation.ConversationID, "", embedHistoryLimit, 0, "desc")
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]Message, 0, len(records)*2+1)
+	for i := len(records) - 1; i >= 0; i-- {
+		query, _ := records[i].GetQueryContent()
+		if query == "" || records[i].Answer == "" {
+			continue
+		}
+		messages = append(messages, Message{Role: "user", Content: query}, Message{Role: "assistant", Content: records[i].Answer})
+	}
+	return messages, nil
+}
+
+// @Summary 获取访客会话的消息
+// @Description 用于嵌入组件刷新后恢复对话，按时间正序返回最近的问答
+// @Tags Embed
+// @Produce json
+// @Security BearerAuth
+// @Param token path string true "嵌入令牌"
+// @Param limit query int false "条数" default(20)
+// @Success 200 {object} model.CommonResponse{data=[]EmbedMessage} "成功"
+// @Router /api/embed/{token}/messages [get]
+func GetEmbedMessages(c *gin.Context) {
+	agentEmbed := c.MustGet(session.SESSION_EMBED).(*model.AgentEmbed)
+	guestSession := c.MustGet(session.SESSION_EMBED_SESSION).(*model.EmbedSession)
+	result := make([]EmbedMessage, 0)
+	if guestSession.ConversationID == 0 {
+		c.JSON(http.StatusOK, model.Success.ToResponse(result))
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	_, records, err := model.GetMessagesByConversationIDWithDirection(agentEmbed.Eid, guestSession.ConversationID, "", limit, 0, "desc")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	for i := len(records) - 1; i >= 0; i-- {
+		query, _ := records[i].GetQueryContent()
+		result = append(result, EmbedMessage{
+			ID:          records[i].ID,
+			Query:       query,
+			Answer:      records[i].Answer,
+			CreatedTime: records[i].CreatedTime,
+		})
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(result))
+}
@@ -0,0 +1,366 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/53AI/53AIHub/common/ctxkey"
+	"github.com/53AI/53AIHub/common/session"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/agentversion"
+	"github.com/53AI/53AIHub/service/evaluation"
+	"github.com/gin-gonic/gin"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+	"github.com/songquanpeng/one-api/relay/relaymode"
+)
+
+type EvalDatasetRequest struct {
+	Name        string            `json:"name" binding:"required,max=100" example:"常见问题回归"`
+	Description string            `json:"description"`
+	Cases       []*model.EvalCase `json:"cases"`
+}
+
+type EvalRunsResponse struct {
+	Count int64            `json:"count"`
+	Runs  []*model.EvalRun `json:"runs"`
+}
+
+type EvalRunResponse struct {
+	*model.EvalRun
+	Results []*model.EvalResult `json:"results"`
+}
+
+var evalRunner = evaluation.NewRunner(askAgentViaRelay, judgeViaChannel)
+
+// askAgentViaRelay 在内部构造对话请求，经与 /v1/chat/completions 相同的链路（渠道选择、知识库检索、计费、消息记录）获取回答
+func askAgentViaRelay(ctx context.Context, agent *model.Agent, userID int64, question string) (*evaluation.Answer, error) {
+	c, w := newRelayContext(ctx, agent, userID)
+	startTime := time.Now()
+	resp, err := relayInternally(c, w, &ChatRequest{
+		Messages: []Message{{Role: "user", Content: question}},
+	}, agent)
+	elapsed := time.Since(startTime).Milliseconds()
+	if err != nil {
+		return nil, err
+	}
+	return &evaluation.Answer{Content: resp.Choices[0].StringContent(), ElapsedTime: elapsed, TotalTokens: resp.Usage.TotalTokens}, nil
+}
+
+// newRelayContext 构造不属于任何会话的内部对话请求上下文
+func newRelayContext(ctx context.Context, agent *model.Agent, userID int64) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = (&http.Request{
+		Method: "POST",
+		URL:    &url.URL{Path: "/v1/chat/completions"},
+		Header: make(http.Header),
+	}).WithContext(ctx)
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set(session.SESSION_USER_ID, userID)
+	c.Set(session.ENV_EID, agent.Eid)
+	c.Set(session.SESSION_AGENT_ID, agent.AgentID)
+	c.Set(session.SESSION_AGENT, agent)
+	c.Set(session.SESSION_CONVERSATION, &model.Conversation{Eid: agent.Eid})
+	c.Set(ctxkey.Group, "vip")
+	return c, w
+}
+
+// relayInternally 执行内部的非流式对话请求并解析响应
+func relayInternally(c *gin.Context, w *httptest.ResponseRecorder, chatRequest *ChatRequest, agent *model.Agent) (*openai.TextResponse, error) {
+	processChatRequest(c, chatRequest, agent, relaymode.ChatCompletions)
+	if w.Code != http.StatusOK {
+		var errResp model.OpenAIErrorResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &errResp); err == nil && errResp.Error.Message != "" {
+			return nil, errors.New(errResp.Error.Message)
+		}
+		return nil, fmt.Errorf("http status code: %d", w.Code)
+	}
+	var resp openai.TextResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return nil, errors.New("response has no choices")
+	}
+	return &resp, nil
+}
+
+// judgeViaChannel 直接调用评审渠道，不计入智能体的消息记录
+func judgeViaChannel(ctx context.Context, channel *model.Channel, modelName string, prompt string) (string, error) {
+	request := &relaymodel.GeneralOpenAIRequest{
+		Model:    modelName,
+		Messages: []relaymodel.Message{{Role: "user", Content: prompt}},
+	}
+	reply, err, _ := testChannel(ctx, channel, request)
+	return reply, err
+}
+
+func pathEvalDataset(c *gin.Context) (*model.Agent, *model.EvalDataset, bool) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return nil, nil, false
+	}
+	id, err := strconv.ParseInt(c.Param("dataset_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return nil, nil, false
+	}
+	dataset, err := model.GetEvalDataset(agent.Eid, agent.AgentID, id)
+	if err != nil {
+		evalError(c, err)
+		return nil, nil, false
+	}
+	return agent, dataset, true
+}
+
+func evalError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, model.ErrEvalDatasetNotFound), errors.Is(err, model.ErrEvalRunNotFound),
+		errors.Is(err, agentversion.ErrNoDraft), errors.Is(err, model.ErrAgentVersionNotFound):
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(err))
+	case errors.Is(err, evaluation.ErrEmptyDataset), errors.Is(err, evaluation.ErrInvalidCase),
+		errors.Is(err, evaluation.ErrJudgeChannel), errors.Is(err, agentversion.ErrInvalidRef):
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+	default:
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+	}
+}
+
+// @Summary 获取智能体的评测集列表
+// @Tags AgentEval
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Success 200 {object} model.CommonResponse{data=[]model.EvalDataset} "成功"
+// @Router /api/agents/{agent_id}/eval_datasets [get]
+func GetEvalDatasets(c *gin.Context) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return
+	}
+	datasets, err := model.GetEvalDatasets(agent.Eid, agent.AgentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(datasets))
+}
+
+// @Summary 创建评测集
+// @Description 每条用例需要问题，以及参考答案、正则或评分标准中的至少一项
+// @Tags AgentEval
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param request body EvalDatasetRequest true "评测集"
+// @Success 200 {object} model.CommonResponse{data=model.EvalDataset} "成功"
+// @Router /api/agents/{agent_id}/eval_datasets [post]
+func CreateEvalDataset(c *gin.Context) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return
+	}
+	var req EvalDatasetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if req.Cases == nil {
+		req.Cases = make([]*model.EvalCase, 0)
+	}
+	dataset := &model.EvalDataset{
+		Eid:       agent.Eid,
+		AgentID:   agent.AgentID,
+		CreatedBy: config.GetUserId(c),
+	}
+	if !saveEvalDataset(c, dataset, req) {
+		return
+	}
+	logAgentAction(c, model.SystemLogActionCreate, fmt.Sprintf("创建智能体【%s】的评测集【%s】", agent.Name, dataset.Name))
+	c.JSON(http.StatusOK, model.Success.ToResponse(dataset))
+}
+
+// @Summary 获取评测集详情
+// @Tags AgentEval
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param dataset_id path int true "评测集ID"
+// @Success 200 {object} model.CommonResponse{data=model.EvalDataset} "成功"
+// @Router /api/agents/{agent_id}/eval_datasets/{dataset_id} [get]
+func GetEvalDataset(c *gin.Context) {
+	_, dataset, ok := pathEvalDataset(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(dataset))
+}
+
+// @Summary 修改评测集
+// @Description 传入 cases 时同步用例（带 id 的用例更新，缺少的用例删除），不传则保留原有用例
+// @Tags AgentEval
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param dataset_id path int true "评测集ID"
+// @Param request body EvalDatasetRequest true "评测集"
+// @Success 200 {object} model.CommonResponse{data=model.EvalDataset} "成功"
+// @Router /api/agents/{agent_id}/eval_datasets/{dataset_id} [put]
+func UpdateEvalDataset(c *gin.Context) {
+	agent, dataset, ok := pathEvalDataset(c)
+	if !ok {
+		return
+	}
+	var req EvalDatasetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if !saveEvalDataset(c, dataset, req) {
+		return
+	}
+	logAgentAction(c, model.SystemLogActionUpdate, fmt.Sprintf("修改智能体【%s】的评测集【%s】", agent.Name, dataset.Name))
+	c.JSON(http.StatusOK, model.Success.ToResponse(dataset))
+}
+
+func saveEvalDataset(c *gin.Context, dataset *model.EvalDataset, req EvalDatasetRequest) bool {
+	if err := evaluation.ValidateCases(req.Cases); err != nil {
+		evalError(c, err)
+		return false
+	}
+	dataset.Name = req.Name
+	dataset.Description = req.Description
+	cases := dataset.Cases
+	dataset.Cases = req.Cases
+	if err := model.SaveEvalDataset(dataset); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return false
+	}
+	if req.Cases == nil {
+		dataset.Cases = cases
+	}
+	return true
+}
+
+// @Summary 删除评测集
+// @Description 同时删除用例和运行记录
+// @Tags AgentEval
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param dataset_id path int true "评测集ID"
+// @Success 200 {object} model.CommonResponse "成功"
+// @Router /api/agents/{agent_id}/eval_datasets/{dataset_id} [delete]
+func DeleteEvalDataset(c *gin.Context) {
+	agent, dataset, ok := pathEvalDataset(c)
+	if !ok {
+		return
+	}
+	if err := model.DeleteEvalDataset(dataset); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	logAgentAction(c, model.SystemLogActionDelete, fmt.Sprintf("删除智能体【%s】的评测集【%s】", agent.Name, dataset.Name))
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+// @Summary 运行评测
+// @Description 以当前生效配置、草稿或指定版本（可覆盖模型与提示词）异步执行评测集中的全部用例，按精确匹配、正则、语义相似度和大模型评审评分，并与上一次运行对比
+// @Tags AgentEval
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param dataset_id path int true "评测集ID"
+// @Param request body model.EvalConfig false "运行配置"
+// @Success 200 {object} model.CommonResponse{data=model.EvalRun} "成功"
+// @Router /api/agents/{agent_id}/eval_datasets/{dataset_id}/runs [post]
+func CreateEvalRun(c *gin.Context) {
+	agent, dataset, ok := pathEvalDataset(c)
+	if !ok {
+		return
+	}
+	var cfg model.EvalConfig
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&cfg); err != nil {
+			c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+			return
+		}
+	}
+	run, target, err := evaluation.CreateRun(agent, dataset, cfg, config.GetUserId(c))
+	if err != nil {
+		evalError(c, err)
+		return
+	}
+	evalRunner.RunAsync(run, target, dataset.Cases)
+	c.JSON(http.StatusOK, model.Success.ToResponse(run))
+}
+
+// @Summary 获取评测运行列表
+// @Tags AgentEval
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param dataset_id query int false "评测集ID"
+// @Param offset query int false "Pagination offset" default(0)
+// @Param limit query int false "Pagination limit" default(10)
+// @Success 200 {object} model.CommonResponse{data=EvalRunsResponse} "成功"
+// @Router /api/agents/{agent_id}/eval_runs [get]
+func GetEvalRuns(c *gin.Context) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return
+	}
+	datasetID, _ := strconv.ParseInt(c.Query("dataset_id"), 10, 64)
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	count, runs, err := model.GetEvalRuns(agent.Eid, agent.AgentID, datasetID, offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(EvalRunsResponse{Count: count, Runs: runs}))
+}
+
+// @Summary 获取评测运行结果
+// @Description 包含每条用例的回答、各评分方式的得分，以及相对上一次运行的变化和回答差异
+// @Tags AgentEval
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param run_id path int true "运行ID"
+// @Success 200 {object} model.CommonResponse{data=EvalRunResponse} "成功"
+// @Router /api/agents/{agent_id}/eval_runs/{run_id} [get]
+func GetEvalRun(c *gin.Context) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("run_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return
+	}
+	run, err := model.GetEvalRun(agent.Eid, agent.AgentID, id)
+	if err != nil {
+		evalError(c, err)
+		return
+	}
+	results, err := model.GetEvalResults(run.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(EvalRunResponse{EvalRun: run, Results: results}))
+}
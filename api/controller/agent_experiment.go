@@ -0,0 +1,281 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/agentversion"
+	"github.com/53AI/53AIHub/service/experiment"
+	"github.com/53AI/53AIHub/service/prompttpl"
+	"github.com/gin-gonic/gin"
+)
+
+type PromoteVariantRequest struct {
+	VariantID int64 `json:"variant_id" binding:"required" example:"1"`
+}
+
+type PromoteVariantResponse struct {
+	Experiment *model.AgentExperiment `json:"experiment"`
+	Version    *model.AgentVersion    `json:"version"`
+}
+
+type ExperimentResultsResponse struct {
+	Experiment *model.AgentExperiment      `json:"experiment"`
+	Variants   []*experiment.VariantResult `json:"variants"`
+}
+
+func pathAgentExperiment(c *gin.Context) (*model.Agent, *model.AgentExperiment, bool) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return nil, nil, false
+	}
+	id, err := strconv.ParseInt(c.Param("experiment_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return nil, nil, false
+	}
+	exp, err := model.GetAgentExperiment(agent.Eid, agent.AgentID, id)
+	if err != nil {
+		experimentError(c, err)
+		return nil, nil, false
+	}
+	return agent, exp, true
+}
+
+func experimentError(c *gin.Context, err error) {
+	var tplErr *prompttpl.Error
+	switch {
+	case errors.Is(err, model.ErrExperimentNotFound), errors.Is(err, experiment.ErrVariantNotFound),
+		errors.Is(err, agentversion.ErrNoDraft), errors.Is(err, model.ErrAgentVersionNotFound):
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(err))
+	case errors.Is(err, experiment.ErrInvalidVariants), errors.Is(err, agentversion.ErrInvalidRef), errors.As(err, &tplErr):
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+	case errors.Is(err, experiment.ErrInvalidStatus), errors.Is(err, experiment.ErrExperimentRunning):
+		c.JSON(http.StatusConflict, model.ParamError.ToResponse(err))
+	default:
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+	}
+}
+
+// @Summary 获取智能体的 A/B 实验列表
+// @Tags AgentExperiment
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Success 200 {object} model.CommonResponse{data=[]model.AgentExperiment} "成功"
+// @Router /api/agents/{agent_id}/experiments [get]
+func GetAgentExperiments(c *gin.Context) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return
+	}
+	experiments, err := model.GetAgentExperiments(agent.Eid, agent.AgentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(experiments))
+}
+
+// @Summary 创建 A/B 实验
+// @Description 至少两个方案，每个方案以指定版本（默认当前生效配置）为基础覆盖模型、提示词和设置；创建后为草稿状态，开始后按用户分流
+// @Tags AgentExperiment
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param request body experiment.Input true "实验信息"
+// @Success 200 {object} model.CommonResponse{data=model.AgentExperiment} "成功"
+// @Router /api/agents/{agent_id}/experiments [post]
+func CreateAgentExperiment(c *gin.Context) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return
+	}
+	var req experiment.Input
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	exp, err := experiment.Create(agent, req, config.GetUserId(c))
+	if err != nil {
+		experimentError(c, err)
+		return
+	}
+	logAgentAction(c, model.SystemLogActionCreate, fmt.Sprintf("创建智能体【%s】的实验【%s】", agent.Name, exp.Name))
+	c.JSON(http.StatusOK, model.Success.ToResponse(exp))
+}
+
+// @Summary 获取 A/B 实验详情
+// @Tags AgentExperiment
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param experiment_id path int true "实验ID"
+// @Success 200 {object} model.CommonResponse{data=model.AgentExperiment} "成功"
+// @Router /api/agents/{agent_id}/experiments/{experiment_id} [get]
+func GetAgentExperiment(c *gin.Context) {
+	_, exp, ok := pathAgentExperiment(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(exp))
+}
+
+// @Summary 修改 A/B 实验
+// @Description 仅草稿状态的实验可以修改，方案整体替换
+// @Tags AgentExperiment
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param experiment_id path int true "实验ID"
+// @Param request body experiment.Input true "实验信息"
+// @Success 200 {object} model.CommonResponse{data=model.AgentExperiment} "成功"
+// @Failure 409 {object} model.CommonResponse "实验已开始"
+// @Router /api/agents/{agent_id}/experiments/{experiment_id} [put]
+func UpdateAgentExperiment(c *gin.Context) {
+	agent, exp, ok := pathAgentExperiment(c)
+	if !ok {
+		return
+	}
+	var req experiment.Input
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if err := experiment.Update(exp, agent, req); err != nil {
+		experimentError(c, err)
+		return
+	}
+	logAgentAction(c, model.SystemLogActionUpdate, fmt.Sprintf("修改智能体【%s】的实验【%s】", agent.Name, exp.Name))
+	c.JSON(http.StatusOK, model.Success.ToResponse(exp))
+}
+
+// @Summary 删除 A/B 实验
+// @Description 运行中的实验需要先停止
+// @Tags AgentExperiment
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param experiment_id path int true "实验ID"
+// @Success 200 {object} model.CommonResponse "成功"
+// @Failure 409 {object} model.CommonResponse "实验运行中"
+// @Router /api/agents/{agent_id}/experiments/{experiment_id} [delete]
+func DeleteAgentExperiment(c *gin.Context) {
+	agent, exp, ok := pathAgentExperiment(c)
+	if !ok {
+		return
+	}
+	if exp.Status == model.ExperimentStatusRunning {
+		experimentError(c, experiment.ErrInvalidStatus)
+		return
+	}
+	if err := model.DeleteAgentExperiment(exp); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	logAgentAction(c, model.SystemLogActionDelete, fmt.Sprintf("删除智能体【%s】的实验【%s】", agent.Name, exp.Name))
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+// @Summary 开始 A/B 实验
+// @Description 草稿或已停止的实验可以开始，同一智能体同时只能运行一个实验
+// @Tags AgentExperiment
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param experiment_id path int true "实验ID"
+// @Success 200 {object} model.CommonResponse{data=model.AgentExperiment} "成功"
+// @Failure 409 {object} model.CommonResponse "状态不允许或已有运行中的实验"
+// @Router /api/agents/{agent_id}/experiments/{experiment_id}/start [post]
+func StartAgentExperiment(c *gin.Context) {
+	agent, exp, ok := pathAgentExperiment(c)
+	if !ok {
+		return
+	}
+	if err := experiment.Start(exp); err != nil {
+		experimentError(c, err)
+		return
+	}
+	logAgentAction(c, model.SystemLogActionToggle, fmt.Sprintf("开始智能体【%s】的实验【%s】", agent.Name, exp.Name))
+	c.JSON(http.StatusOK, model.Success.ToResponse(exp))
+}
+
+// @Summary 停止 A/B 实验
+// @Description 停止后对话使用智能体当前生效的配置，可以再次开始
+// @Tags AgentExperiment
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param experiment_id path int true "实验ID"
+// @Success 200 {object} model.CommonResponse{data=model.AgentExperiment} "成功"
+// @Router /api/agents/{agent_id}/experiments/{experiment_id}/stop [post]
+func StopAgentExperiment(c *gin.Context) {
+	agent, exp, ok := pathAgentExperiment(c)
+	if !ok {
+		return
+	}
+	if err := experiment.Stop(exp); err != nil {
+		experimentError(c, err)
+		return
+	}
+	logAgentAction(c, model.SystemLogActionToggle, fmt.Sprintf("停止智能体【%s】的实验【%s】", agent.Name, exp.Name))
+	c.JSON(http.StatusOK, model.Success.ToResponse(exp))
+}
+
+// @Summary 推广胜出方案
+// @Description 将方案发布为智能体的新版本并结束实验
+// @Tags AgentExperiment
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param experiment_id path int true "实验ID"
+// @Param request body PromoteVariantRequest true "胜出方案"
+// @Success 200 {object} model.CommonResponse{data=PromoteVariantResponse} "成功"
+// @Router /api/agents/{agent_id}/experiments/{experiment_id}/promote [post]
+func PromoteAgentExperiment(c *gin.Context) {
+	agent, exp, ok := pathAgentExperiment(c)
+	if !ok {
+		return
+	}
+	var req PromoteVariantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	version, err := experiment.Promote(exp, agent, req.VariantID, config.GetUserId(c))
+	if err != nil {
+		experimentError(c, err)
+		return
+	}
+	logAgentAction(c, model.SystemLogActionUpdate, fmt.Sprintf("推广智能体【%s】实验【%s】的方案，发布 v%d", agent.Name, exp.Name, version.Version))
+	c.JSON(http.StatusOK, model.Success.ToResponse(PromoteVariantResponse{Experiment: exp, Version: version}))
+}
+
+// @Summary 获取 A/B 实验结果
+// @Description 按方案汇总消息数、用户数、好评/差评、平均耗时、Token 用量与额度
+// @Tags AgentExperiment
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param experiment_id path int true "实验ID"
+// @Success 200 {object} model.CommonResponse{data=ExperimentResultsResponse} "成功"
+// @Router /api/agents/{agent_id}/experiments/{experiment_id}/results [get]
+func GetAgentExperimentResults(c *gin.Context) {
+	_, exp, ok := pathAgentExperiment(c)
+	if !ok {
+		return
+	}
+	results, err := experiment.Results(exp)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(ExperimentResultsResponse{Experiment: exp, Variants: results}))
+}
@@ -0,0 +1,188 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+	"github.com/53AI/53AIHub/service/agentio"
+	"github.com/gin-gonic/gin"
+)
+
+type AgentImportResponse struct {
+	Count   int                     `json:"count"` // 新建的智能体数量
+	Results []*agentio.ImportResult `json:"results"`
+}
+
+// @Summary 导出智能体
+// @Description 导出当前生效版本的提示词、配置、工具、头像、使用场景、分组名称及渠道类型与模型，可导入到其他企业
+// @Tags Agent
+// @Produce json
+// @Produce application/zip
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param format query string false "导出格式：json/zip" default(json)
+// @Success 200 {object} agentio.ExportedAgent "导出文件"
+// @Router /api/agents/{agent_id}/export [get]
+func ExportAgent(c *gin.Context) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "zip" {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(errors.New("unsupported export format")))
+		return
+	}
+	doc, err := agentio.BuildExport(agent)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	filename := agentio.ExportFileName(doc)
+	if format == "zip" {
+		writeAgentZip(c, filename, []*agentio.ExportedAgent{doc})
+		return
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.SystemError.ToResponse(err))
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// @Summary 批量导出智能体
+// @Description 打包为 zip，每个智能体一个目录，包含 agent.json 和头像文件
+// @Tags Agent
+// @Produce application/zip
+// @Security BearerAuth
+// @Param agent_ids query string true "智能体ID，逗号分隔" example(1,2,3)
+// @Success 200 {string} string "zip 文件"
+// @Router /api/agents/export [get]
+func ExportAgents(c *gin.Context) {
+	eid := config.GetEID(c)
+	docs := make([]*agentio.ExportedAgent, 0)
+	for _, idStr := range strings.Split(c.Query("agent_ids"), ",") {
+		if strings.TrimSpace(idStr) == "" {
+			continue
+		}
+		agentID, err := strconv.ParseInt(strings.TrimSpace(idStr), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+			return
+		}
+		agent, err := model.GetAgentByID(eid, agentID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, model.NotFound.ToResponse(fmt.Errorf("agent %d not found", agentID)))
+			return
+		}
+		doc, err := agentio.BuildExport(agent)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+			return
+		}
+		docs = append(docs, doc)
+	}
+	if len(docs) == 0 {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(errors.New("agent_ids is required")))
+		return
+	}
+	writeAgentZip(c, "agents", docs)
+}
+
+func writeAgentZip(c *gin.Context, filename string, docs []*agentio.ExportedAgent) {
+	data, err := agentio.BuildZip(docs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.SystemError.ToResponse(err))
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`.zip"`)
+	c.Data(http.StatusOK, "application/zip", data)
+}
+
+// @Summary 导入智能体
+// @Description 从导出的 JSON（单个或数组）或 zip 创建智能体，渠道、平台和分组按类型与名称在本企业重新匹配，返回每个智能体的冲突报告
+// @Tags Agent
+// @Accept mpfd
+// @Produce json
+// @Security BearerAuth
+// @Param file formData file true "导出的 JSON 或 zip 文件"
+// @Param on_conflict formData string false "同名智能体的处理方式：rename/skip" default(rename)
+// @Param create_missing_groups formData bool false "自动创建不存在的分组" default(false)
+// @Param dry_run formData bool false "只检查冲突，不创建" default(false)
+// @Success 200 {object} model.CommonResponse{data=AgentImportResponse} "成功"
+// @Router /api/agents/import [post]
+func ImportAgents(c *gin.Context) {
+	var opts agentio.ImportOptions
+	if err := c.ShouldBind(&opts); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if fileHeader.Size > config.MAX_UPLOAD_FILE_SIZE {
+		c.JSON(http.StatusBadRequest, model.FileError.ToResponse(errors.New("The maximum allowed size for file uploads is "+config.MAX_UPLOAD_FILE_SIZE_STRING+".")))
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.FileError.ToResponse(err))
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.FileError.ToResponse(err))
+		return
+	}
+	docs, err := agentio.ParseImport(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	resp := AgentImportResponse{Results: make([]*agentio.ImportResult, 0, len(docs))}
+	for _, doc := range docs {
+		result, ok := importAgent(c, doc, opts)
+		if !ok {
+			return
+		}
+		if result.AgentID > 0 {
+			resp.Count++
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(resp))
+}
+
+// importAgent 导入单个智能体并记录日志，失败时已写入响应
+func importAgent(c *gin.Context, doc *agentio.ExportedAgent, opts agentio.ImportOptions) (*agentio.ImportResult, bool) {
+	if !opts.DryRun {
+		if _, err := service.IsFeatureAvailable(c, "agent", map[string]interface{}{"from": "agent"}); err != nil {
+			c.JSON(http.StatusForbidden, model.FeatureNotAvailableError.ToResponse(err))
+			return nil, false
+		}
+	}
+	eid := config.GetEID(c)
+	result, err := agentio.ImportAgent(eid, config.GetUserId(c), doc, opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return nil, false
+	}
+	if result.AgentID > 0 {
+		logAgentAction(c, model.SystemLogActionCreate, fmt.Sprintf("导入智能体【%s】", result.Name))
+	}
+	return result, true
+}
@@ -0,0 +1,158 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/agentio"
+	"github.com/gin-gonic/gin"
+)
+
+type CreateAgentTemplateRequest struct {
+	AgentID  int64  `json:"agent_id" binding:"required" example:"1"`
+	Name     string `json:"name" binding:"max=100" example:"客服助手"` // 默认为智能体名称
+	Category string `json:"category" binding:"max=50" example:"客户服务"`
+}
+
+type InstantiateAgentTemplateRequest struct {
+	Name string `json:"name" binding:"max=100" example:"售后客服"` // 默认为模板名称
+	agentio.ImportOptions
+}
+
+func pathAgentTemplate(c *gin.Context) (*model.AgentTemplate, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return nil, false
+	}
+	template, err := model.GetAgentTemplate(config.GetEID(c), id)
+	if err != nil {
+		if errors.Is(err, model.ErrAgentTemplateNotFound) {
+			c.JSON(http.StatusNotFound, model.NotFound.ToResponse(nil))
+		} else {
+			c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		}
+		return nil, false
+	}
+	return template, true
+}
+
+// @Summary 获取智能体模板列表
+// @Description 内置模板和本企业保存的模板
+// @Tags AgentTemplate
+// @Produce json
+// @Security BearerAuth
+// @Param category query string false "分类"
+// @Success 200 {object} model.CommonResponse{data=[]model.AgentTemplate} "成功"
+// @Router /api/agent_templates [get]
+func GetAgentTemplates(c *gin.Context) {
+	templates, err := model.GetAgentTemplates(config.GetEID(c), c.Query("category"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(templates))
+}
+
+// @Summary 获取智能体模板详情
+// @Tags AgentTemplate
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "模板ID"
+// @Success 200 {object} model.CommonResponse{data=model.AgentTemplate} "成功"
+// @Router /api/agent_templates/{id} [get]
+func GetAgentTemplate(c *gin.Context) {
+	template, ok := pathAgentTemplate(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(template))
+}
+
+// @Summary 保存智能体为模板
+// @Description 以智能体当前生效的版本创建本企业的模板
+// @Tags AgentTemplate
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateAgentTemplateRequest true "模板信息"
+// @Success 200 {object} model.CommonResponse{data=model.AgentTemplate} "成功"
+// @Router /api/agent_templates [post]
+func CreateAgentTemplate(c *gin.Context) {
+	var req CreateAgentTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	agent, err := model.GetAgentByID(config.GetEID(c), req.AgentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(nil))
+		return
+	}
+	template := agentio.NewTemplate(agent, req.Name, req.Category, config.GetUserId(c))
+	if err := model.CreateAgentTemplate(template); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	logAgentAction(c, model.SystemLogActionCreate, fmt.Sprintf("保存智能体【%s】为模板【%s】", agent.Name, template.Name))
+	c.JSON(http.StatusOK, model.Success.ToResponse(template))
+}
+
+// @Summary 删除智能体模板
+// @Description 只能删除本企业保存的模板，内置模板不可删除
+// @Tags AgentTemplate
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "模板ID"
+// @Success 200 {object} model.CommonResponse "成功"
+// @Failure 403 {object} model.CommonResponse "内置模板"
+// @Router /api/agent_templates/{id} [delete]
+func DeleteAgentTemplate(c *gin.Context) {
+	template, ok := pathAgentTemplate(c)
+	if !ok {
+		return
+	}
+	if template.IsBuiltIn() {
+		c.JSON(http.StatusForbidden, model.ForbiddenError.ToResponse(errors.New("built-in template cannot be deleted")))
+		return
+	}
+	if _, err := model.DeleteAgentTemplate(template.Eid, template.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	logAgentAction(c, model.SystemLogActionDelete, fmt.Sprintf("删除智能体模板【%s】", template.Name))
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+// @Summary 使用模板创建智能体
+// @Description 模型按本企业已有渠道重新匹配，无法匹配时在冲突报告中说明；新建的智能体默认不启用
+// @Tags AgentTemplate
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "模板ID"
+// @Param request body InstantiateAgentTemplateRequest false "智能体名称与导入选项"
+// @Success 200 {object} model.CommonResponse{data=agentio.ImportResult} "成功"
+// @Router /api/agent_templates/{id}/instantiate [post]
+func InstantiateAgentTemplate(c *gin.Context) {
+	template, ok := pathAgentTemplate(c)
+	if !ok {
+		return
+	}
+	var req InstantiateAgentTemplateRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+			return
+		}
+	}
+	result, ok := importAgent(c, agentio.FromTemplate(template, req.Name), req.ImportOptions)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(result))
+}
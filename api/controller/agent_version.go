@@ -0,0 +1,246 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/53AI/53AIHub/common/utils"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/agentversion"
+	"github.com/gin-gonic/gin"
+)
+
+type PublishAgentRequest struct {
+	Note string `json:"note" binding:"max=255" example:"优化开场白"`
+}
+
+type AgentVersionDiffResponse struct {
+	From    string                     `json:"from" example:"current"`
+	To      string                     `json:"to" example:"draft"`
+	Changes []agentversion.FieldChange `json:"changes"`
+}
+
+func pathAgent(c *gin.Context) (*model.Agent, bool) {
+	agentID, err := strconv.ParseInt(c.Param("agent_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return nil, false
+	}
+	agent, err := model.GetAgentByID(config.GetEID(c), agentID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(nil))
+		return nil, false
+	}
+	return agent, true
+}
+
+func logAgentAction(c *gin.Context, action uint8, content string) {
+	model.CreateSystemLog(&model.SystemLog{
+		Eid:      config.GetEID(c),
+		UserID:   config.GetUserId(c),
+		Nickname: config.GetUserNickname(c),
+		Module:   model.SystemLogModuleAgent,
+		Action:   action,
+		Content:  content,
+		IP:       utils.GetClientIP(c),
+	})
+}
+
+func agentVersionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, agentversion.ErrNoDraft), errors.Is(err, model.ErrAgentVersionNotFound):
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(err))
+	case errors.Is(err, agentversion.ErrCurrentVersion), errors.Is(err, agentversion.ErrInvalidRef):
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+	default:
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+	}
+}
+
+// @Summary 获取智能体草稿
+// @Description 草稿可通过 /v1/chat/completions 以模型名 agent-{agent_id}@draft 试用
+// @Tags Agent
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Success 200 {object} model.CommonResponse{data=model.AgentVersion} "成功"
+// @Failure 404 {object} model.CommonResponse "没有草稿"
+// @Router /api/agents/{agent_id}/draft [get]
+func GetAgentDraft(c *gin.Context) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return
+	}
+	draft, err := model.GetAgentVersion(model.DB, agent.AgentID, model.AgentVersionDraft)
+	if err != nil {
+		agentVersionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(draft))
+}
+
+// @Summary 丢弃智能体草稿
+// @Tags Agent
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Success 200 {object} model.CommonResponse "成功"
+// @Router /api/agents/{agent_id}/draft [delete]
+func DiscardAgentDraft(c *gin.Context) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return
+	}
+	if err := model.DeleteAgentDraft(model.DB, agent.AgentID); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	logAgentAction(c, model.SystemLogActionUpdate, fmt.Sprintf("丢弃智能体【%s】的草稿", agent.Name))
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+// @Summary 发布智能体草稿
+// @Description 草稿快照为新的版本号并立即对用户生效
+// @Tags Agent
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param request body PublishAgentRequest false "版本说明"
+// @Success 200 {object} model.CommonResponse{data=model.AgentVersion} "成功"
+// @Failure 404 {object} model.CommonResponse "没有草稿"
+// @Router /api/agents/{agent_id}/publish [post]
+func PublishAgent(c *gin.Context) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return
+	}
+	var req PublishAgentRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+			return
+		}
+	}
+	version, err := agentversion.Publish(agent, req.Note, config.GetUserId(c))
+	if err != nil {
+		agentVersionError(c, err)
+		return
+	}
+	logAgentAction(c, model.SystemLogActionUpdate, fmt.Sprintf("发布智能体【%s】版本 v%d", agent.Name, version.Version))
+	c.JSON(http.StatusOK, model.Success.ToResponse(version))
+}
+
+// @Summary 获取智能体版本列表
+// @Description 已发布的版本，最新的在前
+// @Tags Agent
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Success 200 {object} model.CommonResponse{data=[]model.AgentVersion} "成功"
+// @Router /api/agents/{agent_id}/versions [get]
+func GetAgentVersions(c *gin.Context) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return
+	}
+	versions, err := model.GetAgentVersions(agent.AgentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(versions))
+}
+
+// @Summary 获取智能体版本详情
+// @Tags Agent
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param version path int true "版本号"
+// @Success 200 {object} model.CommonResponse{data=model.AgentVersion} "成功"
+// @Failure 404 {object} model.CommonResponse "版本不存在"
+// @Router /api/agents/{agent_id}/versions/{version} [get]
+func GetAgentVersion(c *gin.Context) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return
+	}
+	number, err := strconv.Atoi(c.Param("version"))
+	if err != nil || number <= 0 {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return
+	}
+	version, err := model.GetAgentVersion(model.DB, agent.AgentID, number)
+	if err != nil {
+		agentVersionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(version))
+}
+
+// @Summary 回滚智能体版本
+// @Description 以指定历史版本的内容发布一个新版本，草稿不受影响
+// @Tags Agent
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param version path int true "回滚到的版本号"
+// @Success 200 {object} model.CommonResponse{data=model.AgentVersion} "成功"
+// @Failure 400 {object} model.CommonResponse "已是当前版本"
+// @Failure 404 {object} model.CommonResponse "版本不存在"
+// @Router /api/agents/{agent_id}/versions/{version}/rollback [post]
+func RollbackAgentVersion(c *gin.Context) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return
+	}
+	number, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return
+	}
+	version, err := agentversion.Rollback(agent, number, config.GetUserId(c))
+	if err != nil {
+		agentVersionError(c, err)
+		return
+	}
+	logAgentAction(c, model.SystemLogActionUpdate, fmt.Sprintf("回滚智能体【%s】到版本 v%d", agent.Name, number))
+	c.JSON(http.StatusOK, model.Success.ToResponse(version))
+}
+
+// @Summary 对比智能体版本
+// @Description from、to 取值为版本号、current（当前生效配置）或 draft（草稿），默认对比当前配置与草稿
+// @Tags Agent
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param from query string false "起始版本" default(current)
+// @Param to query string false "目标版本" default(draft)
+// @Success 200 {object} model.CommonResponse{data=AgentVersionDiffResponse} "成功"
+// @Router /api/agents/{agent_id}/versions/diff [get]
+func DiffAgentVersions(c *gin.Context) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return
+	}
+	resp := AgentVersionDiffResponse{
+		From: c.DefaultQuery("from", agentversion.RefCurrent),
+		To:   c.DefaultQuery("to", agentversion.RefDraft),
+	}
+	from, err := agentversion.ResolveSnapshot(agent, resp.From)
+	if err != nil {
+		agentVersionError(c, err)
+		return
+	}
+	to, err := agentversion.ResolveSnapshot(agent, resp.To)
+	if err != nil {
+		agentVersionError(c, err)
+		return
+	}
+	resp.Changes = agentversion.Diff(from, to)
+	c.JSON(http.StatusOK, model.Success.ToResponse(resp))
+}
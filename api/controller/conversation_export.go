@@ -0,0 +1,325 @@
+package controller
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/53AI/53AIHub/common"
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/common/storage"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	conversationService "github.com/53AI/53AIHub/service/conversation"
+	"github.com/gin-gonic/gin"
+)
+
+// ConversationExportJobRequest 批量导出任务参数
+type ConversationExportJobRequest struct {
+	UserID    int64    `json:"user_id" example:"0"`                  // 会话所属用户，仅管理员可指定，非管理员只能导出自己的会话
+	AgentID   int64    `json:"agent_id" example:"0"`                 // 智能体ID，0 为不限
+	StartTime int64    `json:"start_time" example:"0"`               // 会话创建时间起（毫秒时间戳）
+	EndTime   int64    `json:"end_time" example:"0"`                 // 会话创建时间止（毫秒时间戳）
+	Formats   []string `json:"formats" example:"markdown,json,html"` // 导出格式：markdown/json/html，默认 json
+}
+
+type ConversationExportJobsResponse struct {
+	Count int64                          `json:"count"`
+	Jobs  []*model.ConversationExportJob `json:"jobs"`
+}
+
+type ConversationImportResponse struct {
+	Count         int64                 `json:"count"`
+	Conversations []*model.Conversation `json:"conversations"`
+}
+
+// @Summary Export conversation
+// @Description Export a conversation as Markdown, JSON or self-contained HTML (printable to PDF)
+// @Tags Conversation
+// @Produce json
+// @Produce text/markdown
+// @Produce text/html
+// @Security BearerAuth
+// @Param conversation_id path int true "Conversation ID"
+// @Param format query string false "Export format: markdown/json/html" default(markdown)
+// @Param embed_attachments query bool false "Embed attachment content as base64 in JSON export" default(false)
+// @Success 200 {string} string "Exported file"
+// @Router /api/conversations/{conversation_id}/export [get]
+func ExportConversation(c *gin.Context) {
+	conversationID, err := strconv.ParseInt(c.Param("conversation_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return
+	}
+	format := c.DefaultQuery("format", model.ConversationExportFormatMarkdown)
+	if !model.IsValidConversationExportFormat(format) {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(errors.New("unsupported export format")))
+		return
+	}
+
+	eid := config.GetEID(c)
+	var conversation *model.Conversation
+	if common.IsAdmin(c) {
+		conversation, err = model.AdminGetConversationByID(eid, conversationID)
+	} else {
+		conversation, err = model.GetConversationByID(eid, config.GetUserId(c), conversationID)
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(err))
+		return
+	}
+
+	// HTML 需要内嵌图片以保证单文件可离线查看
+	embed := format == model.ConversationExportFormatHTML ||
+		(format == model.ConversationExportFormatJSON && c.Query("embed_attachments") == "true")
+	doc, err := conversationService.BuildExport(conversation, embed)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	data, err := conversationService.Render(doc, format)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.SystemError.ToResponse(err))
+		return
+	}
+
+	filename := conversationService.ExportFileName(doc) + conversationService.FileExtension(format)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, conversationService.ContentType(format), data)
+}
+
+// @Summary Import conversations
+// @Description Restore conversations from a JSON export (single conversation or array) or a bulk export zip.
+// @Description Non-admin users can only import into agents available to their user groups.
+// @Tags Conversation
+// @Accept mpfd
+// @Produce json
+// @Security BearerAuth
+// @Param file formData file true "Exported JSON or zip file"
+// @Param agent_id formData int false "Import into this agent instead of the original one"
+// @Success 200 {object} model.CommonResponse{data=ConversationImportResponse} "Success"
+// @Failure 403 {object} model.CommonResponse "No permission to use the agent"
+// @Router /api/conversations/import [post]
+func ImportConversations(c *gin.Context) {
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if fileHeader.Size > config.MAX_UPLOAD_FILE_SIZE {
+		c.JSON(http.StatusBadRequest, model.FileError.ToResponse(errors.New("The maximum allowed size for file uploads is "+config.MAX_UPLOAD_FILE_SIZE_STRING+".")))
+		return
+	}
+	var agentID int64
+	if agentIDStr := c.PostForm("agent_id"); agentIDStr != "" {
+		if agentID, err = strconv.ParseInt(agentIDStr, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+			return
+		}
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.FileError.ToResponse(err))
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.FileError.ToResponse(err))
+		return
+	}
+
+	docs, err := conversationService.ParseImport(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	eid := config.GetEID(c)
+	user, err := model.GetUserByID(config.GetUserId(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, model.UnauthorizedError.ToResponse(err))
+		return
+	}
+	conversations := make([]*model.Conversation, 0, len(docs))
+	for _, doc := range docs {
+		conversation, err := conversationService.ImportConversation(eid, user, common.IsAdmin(c), agentID, doc)
+		if err != nil {
+			if errors.Is(err, conversationService.ErrAgentForbidden) {
+				c.JSON(http.StatusForbidden, model.AgentAuthError.ToResponse(err))
+				return
+			}
+			c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+			return
+		}
+		conversation.LoadAgent()
+		conversations = append(conversations, conversation)
+	}
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(ConversationImportResponse{
+		Count:         int64(len(conversations)),
+		Conversations: conversations,
+	}))
+}
+
+// getConversationExportJob 获取当前用户可访问的导出任务，管理员可访问企业内所有任务
+func getConversationExportJob(c *gin.Context) (*model.ConversationExportJob, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return nil, false
+	}
+	job, err := model.GetConversationExportJob(config.GetEID(c), id)
+	if err != nil || (job.CreatedBy != config.GetUserId(c) && !common.IsAdmin(c)) {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(nil))
+		return nil, false
+	}
+	return job, true
+}
+
+// @Summary Create conversation export job
+// @Description Export conversations by user, agent and date range in the background, producing a zip file
+// @Tags ConversationExport
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ConversationExportJobRequest true "Export filters"
+// @Success 200 {object} model.CommonResponse{data=model.ConversationExportJob} "Success"
+// @Router /api/conversation_exports [post]
+func CreateConversationExportJob(c *gin.Context) {
+	var req ConversationExportJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	for _, format := range req.Formats {
+		if !model.IsValidConversationExportFormat(format) {
+			c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(errors.New("unsupported export format: "+format)))
+			return
+		}
+	}
+
+	userID := config.GetUserId(c)
+	if !common.IsAdmin(c) {
+		req.UserID = userID
+	}
+	job := &model.ConversationExportJob{
+		Eid:       config.GetEID(c),
+		CreatedBy: userID,
+		UserID:    req.UserID,
+		AgentID:   req.AgentID,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		Formats:   strings.Join(req.Formats, ","),
+		Status:    model.ConversationExportStatusPending,
+	}
+	if err := model.CreateConversationExportJob(job); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+
+	conversationService.RunExportJobAsync(job)
+	c.JSON(http.StatusOK, model.Success.ToResponse(job))
+}
+
+// @Summary List conversation export jobs
+// @Description List export jobs; admins see all jobs of the enterprise, others see their own
+// @Tags ConversationExport
+// @Produce json
+// @Security BearerAuth
+// @Param offset query int false "Pagination offset" default(0)
+// @Param limit query int false "Pagination limit" default(10)
+// @Success 200 {object} model.CommonResponse{data=ConversationExportJobsResponse} "Success"
+// @Router /api/conversation_exports [get]
+func GetConversationExportJobs(c *gin.Context) {
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	var createdBy int64
+	if !common.IsAdmin(c) {
+		createdBy = config.GetUserId(c)
+	}
+	count, jobs, err := model.GetConversationExportJobs(config.GetEID(c), createdBy, offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(ConversationExportJobsResponse{
+		Count: count,
+		Jobs:  jobs,
+	}))
+}
+
+// @Summary Get conversation export job
+// @Description Get export job status
+// @Tags ConversationExport
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Export job ID"
+// @Success 200 {object} model.CommonResponse{data=model.ConversationExportJob} "Success"
+// @Router /api/conversation_exports/{id} [get]
+func GetConversationExportJob(c *gin.Context) {
+	job, ok := getConversationExportJob(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(job))
+}
+
+// @Summary Download conversation export
+// @Description Download the zip file produced by a completed export job
+// @Tags ConversationExport
+// @Produce application/zip
+// @Security BearerAuth
+// @Param id path int true "Export job ID"
+// @Success 200 {string} string "Zip file"
+// @Router /api/conversation_exports/{id}/download [get]
+func DownloadConversationExport(c *gin.Context) {
+	job, ok := getConversationExportJob(c)
+	if !ok {
+		return
+	}
+	if job.Status != model.ConversationExportStatusCompleted || job.FileKey == "" {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(errors.New("export job is not completed")))
+		return
+	}
+	data, err := storage.StorageInstance.Load(job.FileKey)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.FileError.ToResponse(err))
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+job.FileName+`"`)
+	c.Data(http.StatusOK, "application/zip", data)
+}
+
+// @Summary Delete conversation export job
+// @Description Delete an export job and its zip file
+// @Tags ConversationExport
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Export job ID"
+// @Success 200 {object} model.CommonResponse "Success"
+// @Router /api/conversation_exports/{id} [delete]
+func DeleteConversationExportJob(c *gin.Context) {
+	job, ok := getConversationExportJob(c)
+	if !ok {
+		return
+	}
+	if job.Status == model.ConversationExportStatusProcessing {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(errors.New("export job is processing")))
+		return
+	}
+	if job.FileKey != "" {
+		if err := storage.StorageInstance.Delete(job.FileKey); err != nil {
+			logger.SysErrorf("delete conversation export file failed, job_id=%d: %v", job.ID, err)
+		}
+	}
+	if err := model.DeleteConversationExportJob(job.Eid, job.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
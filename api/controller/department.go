@@ -317,6 +317,19 @@ func DepartmentBindMember(c *gin.Context) {
 		return
 	}
 
+	// 绑定后成员获得该绑定所在部门上授予的角色
+	if !manageableUser(c, user) {
+		return
+	}
+	dids, err := model.GetMemberDidsByBID(eid, memberBinding.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	if !grantableDepartments(c, dids) {
+		return
+	}
+
 	memberBinding.MID = user.UserID
 	memberBinding.Status = model.MemberBindingStatusActive
 
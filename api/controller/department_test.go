@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/53AI/53AIHub/common/session"
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/rbac"
+	"github.com/gin-gonic/gin"
+)
+
+func TestDepartmentBindMemberRoleGrant(t *testing.T) {
+	testutil.SetupDB(t, &model.User{}, &model.Department{}, &model.MemberDepartmentRelation{},
+		&model.MemberBinding{}, &model.Role{}, &model.RoleAssignment{})
+	createUser := func(name string, role int64) *model.User {
+		user := &model.User{Eid: 1, Username: name, Nickname: name, Type: model.UserTypeInternal, Role: role}
+		model.DB.Create(user)
+		return user
+	}
+	admin := createUser("admin", model.RoleAdminUser)
+	operator := createUser("operator", model.RoleCommonUser)
+	member := createUser("member", model.RoleCommonUser)
+	adminPermissions, _ := rbac.UserPermissions(admin)
+	grant := func(name string, departmentIDs []int64, userIDs []int64, permissions ...string) {
+		role := &model.Role{Eid: 1, Name: name, PermissionList: permissions}
+		if err := rbac.SaveRole(role, adminPermissions); err != nil {
+			t.Fatal(err)
+		}
+		if err := rbac.Assign(role, userIDs, departmentIDs, adminPermissions); err != nil {
+			t.Fatal(err)
+		}
+	}
+	grant("部门管理", nil, []int64{operator.UserID}, model.PermDepartmentRead, model.PermDepartmentWrite)
+
+	// 未绑定的企业微信成员所在部门授予了智能体编辑角色
+	dept := &model.Department{EID: 1, Name: "研发"}
+	model.DB.Create(dept)
+	grant("智能体编辑", []int64{dept.DID}, nil, model.PermAgentRead, model.PermAgentWrite)
+	binding := &model.MemberBinding{EID: 1, Name: "member", BindValue: "wx-1", From: model.MemberBindingSourceWeChat}
+	model.DB.Create(binding)
+	model.DB.Create(&model.MemberDepartmentRelation{EID: 1, DID: dept.DID, BID: binding.ID, From: model.MemberDepartmentRelationFromWeChat})
+
+	bind := func(user *model.User) int {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		body := `{"bid":` + strconv.FormatInt(binding.ID, 10) + `,"from":` + strconv.Itoa(binding.From) +
+			`,"user_id":` + strconv.FormatInt(member.UserID, 10) + `}`
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/departments/bind-member", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Set(session.ENV_EID, int64(1))
+		c.Set(session.SESSION_USER_ID, user.UserID)
+		c.Set(session.SESSION_USER_ROLE, user.Role)
+		DepartmentBindMember(c)
+		return w.Code
+	}
+
+	// 只有 department:write 的操作者不能借部门角色给成员授予自己没有的权限
+	if code := bind(operator); code != http.StatusForbidden {
+		t.Fatalf("operator without agent:write: code = %d", code)
+	}
+	if stored, _ := model.GetMemberBindingByMidAndFrom(member.UserID, binding.From); stored != nil {
+		t.Fatal("binding should not be created")
+	}
+	if code := bind(admin); code != http.StatusOK {
+		t.Fatalf("admin: code = %d", code)
+	}
+}
@@ -2,7 +2,6 @@ package controller
 
 import (
 	"errors"
-	"fmt"
 	"net/http"
 	"net/smtp"
 	"strconv"
@@ -50,53 +49,15 @@ func SendVerificationEmail(c *gin.Context) {
 		return
 	}
 
-	now := time.Now().UTC().UnixMilli()
-	currentDayStart := now - (now % 86400000) // 计算当日0点时间戳（毫秒）
-
-	var existingVC model.VerificationCode
-	err = model.DB.Where("target = ? AND type = 'email' AND created_time >= ?", req.Email, currentDayStart).First(&existingVC).Error
-
-	if err == nil {
-		existingVC.Code = code
-		existingVC.ExpiresAt = now + int64(codeExpiration.Milliseconds())
-		existingVC.DailyCount++
-		err = model.DB.Save(&existingVC).Error
-	} else if errors.Is(err, gorm.ErrRecordNotFound) {
-		newVC := model.VerificationCode{
-			Type:       model.VerificationCodeTypeEmail,
-			Target:     req.Email,
-			Code:       code,
-			ExpiresAt:  now + int64(codeExpiration.Milliseconds()),
-			DailyCount: 1,
-		}
-		err = model.DB.Create(&newVC).Error
-	}
-
-	if err != nil {
+	expiresAt := time.Now().UTC().UnixMilli() + codeExpiration.Milliseconds()
+	if err = model.SaveVerificationCode(model.VerificationCodeTypeEmail, req.Email, code, expiresAt); err != nil {
 		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
 		return
 	}
 
 	_ = common.RedisSet("email_verification:"+req.Email, code, codeExpiration)
 
-	eid := config.GetEID(c)
-	e := email.NewEmail()
-	e.To = []string{req.Email}
-	e.Subject = "邮箱验证码"
-	e.Text = []byte(fmt.Sprintf("您的验证码是：%s，有效期%d分钟", code, duration))
-
-	auth, from, host, port, isSsl, err := service.GetSmtpConfig(eid)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.NetworkError.ToResponse(fmt.Errorf("failed to get SMTP auth: %w", err)))
-		return
-	}
-	if from == "" {
-		c.JSON(http.StatusInternalServerError, model.NetworkError.ToResponse(errors.New("SMTP from address is empty")))
-		return
-	}
-	e.From = from
-
-	if err := common.SendEmail(e, auth, isSsl, host, port); err != nil {
+	if err := service.SendVerificationCodeEmail(config.GetEID(c), req.Email, code, duration); err != nil {
 		c.JSON(http.StatusInternalServerError, model.NetworkError.ToResponse(err))
 		return
 	}
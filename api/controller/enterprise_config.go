@@ -7,6 +7,11 @@ import (
 	"github.com/53AI/53AIHub/config"
 	"github.com/53AI/53AIHub/model"
 	"github.com/53AI/53AIHub/service"
+	"github.com/53AI/53AIHub/service/dingtalk"
+	"github.com/53AI/53AIHub/service/feishu"
+	"github.com/53AI/53AIHub/service/ldap"
+	"github.com/53AI/53AIHub/service/oidc"
+	"github.com/53AI/53AIHub/service/security"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
@@ -134,6 +139,37 @@ func SaveEnterpriseConfig(c *gin.Context) {
 		return
 	}
 
+	if configType == model.EnterpriseConfigTypeOIDC {
+		if _, err := oidc.ParseConfig(req.Content); err != nil {
+			c.JSON(http.StatusBadRequest, model.ParamError.ToErrorResponse(err))
+			return
+		}
+	}
+	if configType == model.EnterpriseConfigTypeLDAP {
+		if _, err := ldap.ParseConfig(req.Content); err != nil {
+			c.JSON(http.StatusBadRequest, model.ParamError.ToErrorResponse(err))
+			return
+		}
+	}
+	if configType == model.EnterpriseConfigTypeDingTalk {
+		if _, err := dingtalk.ParseConfig(req.Content); err != nil {
+			c.JSON(http.StatusBadRequest, model.ParamError.ToErrorResponse(err))
+			return
+		}
+	}
+	if configType == model.EnterpriseConfigTypeFeishu {
+		if _, err := feishu.ParseConfig(req.Content); err != nil {
+			c.JSON(http.StatusBadRequest, model.ParamError.ToErrorResponse(err))
+			return
+		}
+	}
+	if configType == model.EnterpriseConfigTypeSecurity {
+		if _, err := security.ParseConfig(req.Content); err != nil {
+			c.JSON(http.StatusBadRequest, model.ParamError.ToErrorResponse(err))
+			return
+		}
+	}
+
 	config, err := service.SaveEnterpriseConfig(eid, configType, req.Content, req.Enabled)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
@@ -0,0 +1,307 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/53AI/53AIHub/common/ctxkey"
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/common/utils"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/guardrail"
+	"github.com/gin-gonic/gin"
+)
+
+// GuardrailPolicyRequest 护栏策略设置
+type GuardrailPolicyRequest struct {
+	Enabled bool                  `json:"enabled"`
+	Config  model.GuardrailConfig `json:"config"`
+}
+
+// GuardrailTestRequest 护栏规则试运行
+type GuardrailTestRequest struct {
+	AgentID int64  `json:"agent_id" example:"0"` // 为 0 时只使用企业级策略
+	Stage   string `json:"stage" example:"input"`
+	Text    string `json:"text" binding:"required"`
+}
+
+// GetGuardrailViolationsRequest 护栏命中记录查询参数
+type GetGuardrailViolationsRequest struct {
+	Offset    int    `form:"offset" default:"0"`
+	Limit     int    `form:"limit" default:"10"`
+	StartTime int64  `form:"start_time"`
+	EndTime   int64  `form:"end_time"`
+	AgentID   int64  `form:"agent_id"`
+	UserID    int64  `form:"user_id"`
+	Stage     string `form:"stage"`
+	Action    string `form:"action"`
+}
+
+type GuardrailViolationsResponse struct {
+	Count      int64                       `json:"count"`
+	Violations []*model.GuardrailViolation `json:"violations"`
+}
+
+// applyGuardrail 对最后一条用户消息执行输入护栏，并在配置了输出规则时接管响应写入。
+// 被拦截时写入错误响应并返回 false
+func applyGuardrail(c *gin.Context, chatRequest *ChatRequest, agent *model.Agent) bool {
+	ctx := c.Request.Context()
+	pipeline, err := guardrail.Load(agent.Eid, agent.AgentID)
+	if err != nil {
+		logger.Errorf(ctx, "load guardrail policies failed: %s", err.Error())
+		return true
+	}
+	if pipeline == nil {
+		return true
+	}
+	pipeline.Moderate = judgeViaChannel
+
+	base := model.GuardrailViolation{
+		Eid:            agent.Eid,
+		AgentID:        agent.AgentID,
+		UserID:         config.GetUserId(c),
+		ConversationID: chatRequest.ConversationID,
+	}
+	for i := len(chatRequest.Messages) - 1; i >= 0; i-- {
+		if chatRequest.Messages[i].Role != "user" {
+			continue
+		}
+		result := pipeline.CheckInput(ctx, chatRequest.Messages[i].Content)
+		guardrail.Record(ctx, base, model.GuardrailScopeInput, result.Violations)
+		if result.Blocked {
+			resp := model.OpenAIErrorResponse{}
+			resp.Error.Message = pipeline.BlockMessage()
+			resp.Error.Type = "guardrail_blocked"
+			c.JSON(http.StatusBadRequest, resp)
+			return false
+		}
+		chatRequest.Messages[i].Content = result.Text
+		break
+	}
+
+	if pipeline.HasOutputRules() {
+		gw := newGuardrailResponseWriter(c.Writer, pipeline, chatRequest.Stream, base)
+		c.Writer = gw
+		c.Set(ctxkey.Guardrail, gw)
+	}
+	return true
+}
+
+func getGuardrailWriter(c *gin.Context) *GuardrailResponseWriter {
+	value, ok := c.Get(ctxkey.Guardrail)
+	if !ok {
+		return nil
+	}
+	gw, _ := value.(*GuardrailResponseWriter)
+	return gw
+}
+
+func guardrailPolicyResponse(c *gin.Context, eid, agentID int64) {
+	policy, err := model.GetGuardrailPolicy(eid, agentID)
+	if errors.Is(err, model.ErrGuardrailPolicyNotFound) {
+		policy = &model.GuardrailPolicy{Eid: eid, AgentID: agentID,
+			GuardrailConfig: model.GuardrailConfig{Rules: make([]model.GuardrailRule, 0)}}
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(policy))
+}
+
+func saveGuardrailPolicy(c *gin.Context, eid, agentID int64) (*model.GuardrailPolicy, bool) {
+	var req GuardrailPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return nil, false
+	}
+	if err := guardrail.Normalize(&req.Config, eid); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return nil, false
+	}
+	policy := &model.GuardrailPolicy{
+		Eid:             eid,
+		AgentID:         agentID,
+		Enabled:         req.Enabled,
+		GuardrailConfig: req.Config,
+		UpdatedBy:       config.GetUserId(c),
+	}
+	if err := model.SaveGuardrailPolicy(policy); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return nil, false
+	}
+	return policy, true
+}
+
+// @Summary 获取企业级护栏策略
+// @Description 企业级策略对所有智能体生效，智能体策略在此基础上追加规则
+// @Tags Guardrail
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.CommonResponse{data=model.GuardrailPolicy} "成功"
+// @Router /api/guardrails [get]
+func GetGuardrailPolicy(c *gin.Context) {
+	guardrailPolicyResponse(c, config.GetEID(c), 0)
+}
+
+// @Summary 设置企业级护栏策略
+// @Description 规则类型为 keyword、regex、pii（id_card、mobile、email、bank_card），处理方式为 block、mask、warn，作用阶段为 input、output、both
+// @Tags Guardrail
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body GuardrailPolicyRequest true "护栏策略"
+// @Success 200 {object} model.CommonResponse{data=model.GuardrailPolicy} "成功"
+// @Failure 400 {object} model.CommonResponse "规则无效"
+// @Router /api/guardrails [put]
+func UpdateGuardrailPolicy(c *gin.Context) {
+	policy, ok := saveGuardrailPolicy(c, config.GetEID(c), 0)
+	if !ok {
+		return
+	}
+	model.CreateSystemLog(&model.SystemLog{
+		Eid:      config.GetEID(c),
+		UserID:   config.GetUserId(c),
+		Nickname: config.GetUserNickname(c),
+		Module:   model.SystemLogModuleSecurity,
+		Action:   model.SystemLogActionUpdate,
+		Content:  fmt.Sprintf("更新企业护栏策略，规则 %d 条，启用：%t", len(policy.GuardrailConfig.Rules), policy.Enabled),
+		IP:       utils.GetClientIP(c),
+	})
+	c.JSON(http.StatusOK, model.Success.ToResponse(policy))
+}
+
+// @Summary 获取智能体护栏策略
+// @Tags Guardrail
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Success 200 {object} model.CommonResponse{data=model.GuardrailPolicy} "成功"
+// @Router /api/agents/{agent_id}/guardrail [get]
+func GetAgentGuardrailPolicy(c *gin.Context) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return
+	}
+	guardrailPolicyResponse(c, agent.Eid, agent.AgentID)
+}
+
+// @Summary 设置智能体护栏策略
+// @Description 智能体规则追加在企业级规则之后；审核模型与拦截提示覆盖企业级配置
+// @Tags Guardrail
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param request body GuardrailPolicyRequest true "护栏策略"
+// @Success 200 {object} model.CommonResponse{data=model.GuardrailPolicy} "成功"
+// @Failure 400 {object} model.CommonResponse "规则无效"
+// @Router /api/agents/{agent_id}/guardrail [put]
+func UpdateAgentGuardrailPolicy(c *gin.Context) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return
+	}
+	policy, ok := saveGuardrailPolicy(c, agent.Eid, agent.AgentID)
+	if !ok {
+		return
+	}
+	logAgentAction(c, model.SystemLogActionUpdate, fmt.Sprintf("更新智能体 %s 的护栏策略", agent.Name))
+	c.JSON(http.StatusOK, model.Success.ToResponse(policy))
+}
+
+// @Summary 删除智能体护栏策略
+// @Description 删除后仅企业级策略对该智能体生效
+// @Tags Guardrail
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Success 200 {object} model.CommonResponse "成功"
+// @Router /api/agents/{agent_id}/guardrail [delete]
+func DeleteAgentGuardrailPolicy(c *gin.Context) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return
+	}
+	if err := model.DeleteGuardrailPolicy(model.DB, agent.Eid, agent.AgentID); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	logAgentAction(c, model.SystemLogActionDelete, fmt.Sprintf("删除智能体 %s 的护栏策略", agent.Name))
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+// @Summary 试运行护栏规则
+// @Description 使用已启用的企业级与智能体策略检查一段文本，不调用审核模型，也不记录命中
+// @Tags Guardrail
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body GuardrailTestRequest true "待检查文本"
+// @Success 200 {object} model.CommonResponse{data=guardrail.Result} "成功"
+// @Router /api/guardrails/test [post]
+func TestGuardrail(c *gin.Context) {
+	var req GuardrailTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	eid := config.GetEID(c)
+	if req.AgentID > 0 {
+		if _, err := model.GetAgentByID(eid, req.AgentID); err != nil {
+			c.JSON(http.StatusNotFound, model.NotFound.ToResponse(nil))
+			return
+		}
+	}
+	pipeline, err := guardrail.Load(eid, req.AgentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	if pipeline == nil {
+		c.JSON(http.StatusOK, model.Success.ToResponse(guardrail.Result{Text: req.Text, Violations: make([]guardrail.Violation, 0)}))
+		return
+	}
+	var result *guardrail.Result
+	if strings.EqualFold(req.Stage, model.GuardrailScopeOutput) {
+		result = pipeline.CheckOutput(req.Text)
+	} else {
+		result = pipeline.CheckInput(c.Request.Context(), req.Text)
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(result))
+}
+
+// @Summary 获取护栏命中记录
+// @Description 按时间范围、智能体、用户、阶段与处理方式分页查询，命中片段已脱敏
+// @Tags Guardrail
+// @Produce json
+// @Security BearerAuth
+// @Param offset query int false "offset" default(0)
+// @Param limit query int false "每页数量" default(10)
+// @Param start_time query int64 false "开始时间（毫秒时间戳）"
+// @Param end_time query int64 false "结束时间（毫秒时间戳）"
+// @Param agent_id query int64 false "智能体ID"
+// @Param user_id query int64 false "用户ID"
+// @Param stage query string false "阶段：input、output"
+// @Param action query string false "处理方式：block、mask、warn"
+// @Success 200 {object} model.CommonResponse{data=GuardrailViolationsResponse} "成功"
+// @Router /api/guardrails/violations [get]
+func GetGuardrailViolations(c *gin.Context) {
+	var req GetGuardrailViolationsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToErrorResponse(err))
+		return
+	}
+	violations, total, err := model.GetGuardrailViolationsByConditions(config.GetEID(c), req.AgentID, req.UserID,
+		req.Stage, req.Action, req.StartTime, req.EndTime, req.Offset, req.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToErrorResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(GuardrailViolationsResponse{
+		Count:      total,
+		Violations: violations,
+	}))
+}
@@ -0,0 +1,221 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/guardrail"
+	"github.com/gin-gonic/gin"
+)
+
+// GuardrailResponseWriter 在响应写给客户端前对模型输出执行护栏规则。
+// 流式响应按 SSE 行改写 choices[].delta.content，非流式响应改写 choices[].message.content
+type GuardrailResponseWriter struct {
+	gin.ResponseWriter
+	pipeline *guardrail.Pipeline
+	filter   *guardrail.OutputFilter
+	stream   bool
+	base     model.GuardrailViolation
+	// buf 尚未收到换行的 SSE 行
+	buf []byte
+	// template 最近一条内容事件，用于补发保留在过滤器中的剩余内容
+	template map[string]interface{}
+	finished bool
+}
+
+func newGuardrailResponseWriter(w gin.ResponseWriter, pipeline *guardrail.Pipeline, stream bool, base model.GuardrailViolation) *GuardrailResponseWriter {
+	return &GuardrailResponseWriter{
+		ResponseWriter: w,
+		pipeline:       pipeline,
+		filter:         pipeline.NewOutputFilter(),
+		stream:         stream,
+		base:           base,
+	}
+}
+
+// WriteHeader 内容会被改写，移除上游透传的 Content-Length
+func (w *GuardrailResponseWriter) WriteHeader(statusCode int) {
+	w.ResponseWriter.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *GuardrailResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *GuardrailResponseWriter) Write(b []byte) (int, error) {
+	if !w.stream {
+		_, err := w.ResponseWriter.Write(w.rewriteBody(b))
+		return len(b), err
+	}
+
+	w.buf = append(w.buf, b...)
+	end := bytes.LastIndexByte(w.buf, '\n')
+	var out bytes.Buffer
+	if end >= 0 {
+		for _, line := range strings.SplitAfter(string(w.buf[:end+1]), "\n") {
+			out.WriteString(w.rewriteLine(line))
+		}
+		w.buf = w.buf[end+1:]
+	}
+	// 不完整的行只在可能是 data 事件时缓存，其他内容（如错误 JSON）直接透传
+	rest := string(w.buf)
+	if rest != "" && !strings.HasPrefix(rest, "data:") && !strings.HasPrefix("data:", rest) {
+		out.WriteString(rest)
+		w.buf = w.buf[:0]
+	}
+	if out.Len() > 0 {
+		if _, err := w.ResponseWriter.Write(out.Bytes()); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush 实现 Flusher 接口
+func (w *GuardrailResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// rewriteLine 改写一行 SSE 数据，返回需要发送的内容，空字符串表示丢弃该行
+func (w *GuardrailResponseWriter) rewriteLine(line string) string {
+	payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+	if !strings.HasPrefix(line, "data:") {
+		return line
+	}
+	if payload == "[DONE]" {
+		return w.tailEvent() + line
+	}
+	event, err := decodeObject([]byte(payload))
+	if err != nil {
+		return line
+	}
+	delta, finished, ok := eventDelta(event)
+	if !ok {
+		return line
+	}
+	content, _ := delta["content"].(string)
+	if w.filter.Blocked() {
+		if !finished {
+			return ""
+		}
+		delta["content"] = ""
+		return marshalEvent(event)
+	}
+	w.template = event
+	out, blocked := w.filter.Push(content)
+	if blocked {
+		out = w.pipeline.BlockMessage()
+	} else if finished {
+		out += w.filter.Flush()
+	}
+	if out == "" && content != "" && !finished {
+		return ""
+	}
+	delta["content"] = out
+	return marshalEvent(event)
+}
+
+// tailEvent 流结束前补发过滤器中保留的内容
+func (w *GuardrailResponseWriter) tailEvent() string {
+	tail := w.filter.Flush()
+	if tail == "" || w.template == nil {
+		return ""
+	}
+	delta, _, _ := eventDelta(w.template)
+	delta["content"] = tail
+	// 作为独立事件发送，需要以空行结束
+	return marshalEvent(w.template) + "\n"
+}
+
+func (w *GuardrailResponseWriter) rewriteBody(b []byte) []byte {
+	body, err := decodeObject(b)
+	if err != nil {
+		return b
+	}
+	choices, _ := body["choices"].([]interface{})
+	changed := false
+	for _, item := range choices {
+		choice, _ := item.(map[string]interface{})
+		message, _ := choice["message"].(map[string]interface{})
+		content, ok := message["content"].(string)
+		if !ok {
+			continue
+		}
+		message["content"], _ = w.filter.Check(content)
+		changed = true
+	}
+	if !changed {
+		return b
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return b
+	}
+	return data
+}
+
+// Finish 响应结束后补发剩余内容并保存输出阶段的命中记录
+func (w *GuardrailResponseWriter) Finish(ctx context.Context) {
+	if w.finished {
+		return
+	}
+	w.finished = true
+	if w.stream {
+		rest := string(w.buf)
+		w.buf = nil
+		out := w.rewriteLine(rest) + w.tailEvent()
+		if out != "" {
+			_, _ = w.ResponseWriter.Write([]byte(out))
+		}
+	}
+	guardrail.Record(ctx, w.base, model.GuardrailScopeOutput, w.filter.Violations())
+}
+
+// eventDelta 取出流式事件第一个 choice 的 delta，finished 表示该事件带有 finish_reason
+func eventDelta(event map[string]interface{}) (delta map[string]interface{}, finished bool, ok bool) {
+	choices, _ := event["choices"].([]interface{})
+	if len(choices) == 0 {
+		return nil, false, false
+	}
+	choice, _ := choices[0].(map[string]interface{})
+	delta, ok = choice["delta"].(map[string]interface{})
+	if !ok {
+		return nil, false, false
+	}
+	finished = choice["finish_reason"] != nil
+	return delta, finished, true
+}
+
+func marshalEvent(event map[string]interface{}) string {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return ""
+	}
+	return "data: " + string(data) + "\n"
+}
+
+// decodeObject 解析 JSON 对象，数字保留原始文本，避免大整数 ID 丢失精度
+func decodeObject(data []byte) (map[string]interface{}, error) {
+	var v map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// SavedContent 写入消息记录的回复内容，与发送给用户的内容保持一致
+func (w *GuardrailResponseWriter) SavedContent(content string) string {
+	if w.filter.Blocked() {
+		return w.pipeline.BlockMessage()
+	}
+	return w.pipeline.CheckOutput(content).Text
+}
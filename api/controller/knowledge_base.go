@@ -0,0 +1,457 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/knowledge"
+	"github.com/gin-gonic/gin"
+)
+
+// KnowledgeBaseListRequest 知识库列表请求参数
+type KnowledgeBaseListRequest struct {
+	Keyword string `form:"keyword"` // 关键词搜索
+	Offset  int    `form:"offset"`  // 分页偏移量
+	Limit   int    `form:"limit"`   // 分页大小
+}
+
+// KnowledgeBasesResponse 知识库列表响应
+type KnowledgeBasesResponse struct {
+	Count          int64                  `json:"count"`
+	KnowledgeBases []*model.KnowledgeBase `json:"knowledge_bases"`
+}
+
+// KnowledgeBaseRequest 创建或更新知识库的请求参数
+type KnowledgeBaseRequest struct {
+	Name           string  `json:"name" binding:"required" example:"产品手册"`                         // 知识库名称
+	Description    string  `json:"description" example:"产品使用说明"`                                   // 描述
+	EmbeddingModel string  `json:"embedding_model" binding:"required" example:"text-embedding-v3"` // embedding 模型
+	RerankModel    string  `json:"rerank_model" example:"gte-rerank-v2"`                           // rerank 模型，为空不重排
+	ChunkSize      int     `json:"chunk_size" example:"500"`                                       // 分块大小（字符）
+	ChunkOverlap   int     `json:"chunk_overlap" example:"50"`                                     // 分块重叠（字符）
+	TopK           int     `json:"top_k" example:"5"`                                              // 检索数量
+	ScoreThreshold float64 `json:"score_threshold" example:"0.3"`                                  // 相似度阈值
+}
+
+// KnowledgeDocumentRequest 添加文档请求参数
+type KnowledgeDocumentRequest struct {
+	FileIDs []int64 `json:"file_ids" binding:"required" example:"1,2"` // 上传文件ID列表
+}
+
+// KnowledgeDocumentsResponse 文档列表响应
+type KnowledgeDocumentsResponse struct {
+	Count     int64                      `json:"count"`
+	Documents []*model.KnowledgeDocument `json:"documents"`
+}
+
+// KnowledgeRetrieveRequest 检索测试请求参数
+type KnowledgeRetrieveRequest struct {
+	Query string `json:"query" binding:"required" example:"如何重置密码"` // 查询内容
+}
+
+// AgentKnowledgeBasesRequest 智能体绑定知识库请求参数
+type AgentKnowledgeBasesRequest struct {
+	KnowledgeBaseIDs []int64 `json:"knowledge_base_ids" example:"1,2"` // 知识库ID列表
+}
+
+func (req *KnowledgeBaseRequest) applyTo(kb *model.KnowledgeBase) {
+	kb.Name = req.Name
+	kb.Description = req.Description
+	kb.EmbeddingModel = req.EmbeddingModel
+	kb.RerankModel = req.RerankModel
+	kb.ChunkSize = req.ChunkSize
+	kb.ChunkOverlap = req.ChunkOverlap
+	kb.TopK = req.TopK
+	kb.ScoreThreshold = req.ScoreThreshold
+	if kb.ChunkSize <= 0 {
+		kb.ChunkSize = 500
+	}
+	if kb.ChunkOverlap < 0 || kb.ChunkOverlap >= kb.ChunkSize {
+		kb.ChunkOverlap = 0
+	}
+	if kb.TopK <= 0 {
+		kb.TopK = 5
+	}
+}
+
+// getKnowledgeBaseFromParam 根据路由参数 id 获取当前企业的知识库
+func getKnowledgeBaseFromParam(c *gin.Context) (*model.KnowledgeBase, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return nil, false
+	}
+	kb, err := model.GetKnowledgeBaseByID(config.GetEID(c), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(nil))
+		return nil, false
+	}
+	return kb, true
+}
+
+// @Summary 获取知识库列表
+// @Description 获取当前企业的知识库列表，支持关键词搜索和分页
+// @Tags KnowledgeBase
+// @Produce json
+// @Security BearerAuth
+// @Param keyword query string false "关键词搜索"
+// @Param offset query int false "分页偏移量" default(0)
+// @Param limit query int false "分页大小" default(10)
+// @Success 200 {object} model.CommonResponse{data=KnowledgeBasesResponse} "成功"
+// @Router /api/knowledge_bases [get]
+func GetKnowledgeBases(c *gin.Context) {
+	var req KnowledgeBaseListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	count, kbs, err := model.GetKnowledgeBaseList(config.GetEID(c), req.Keyword, req.Offset, req.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	for _, kb := range kbs {
+		if err := kb.LoadDocumentCount(); err != nil {
+			c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(KnowledgeBasesResponse{
+		Count:          count,
+		KnowledgeBases: kbs,
+	}))
+}
+
+// @Summary 获取知识库详情
+// @Description 根据ID获取知识库详情
+// @Tags KnowledgeBase
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "知识库ID"
+// @Success 200 {object} model.CommonResponse{data=model.KnowledgeBase} "成功"
+// @Router /api/knowledge_bases/{id} [get]
+func GetKnowledgeBase(c *gin.Context) {
+	kb, ok := getKnowledgeBaseFromParam(c)
+	if !ok {
+		return
+	}
+	if err := kb.LoadDocumentCount(); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(kb))
+}
+
+// @Summary 创建知识库
+// @Description 创建知识库，需指定 embedding 模型
+// @Tags KnowledgeBase
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body KnowledgeBaseRequest true "知识库信息"
+// @Success 200 {object} model.CommonResponse{data=model.KnowledgeBase} "成功"
+// @Router /api/knowledge_bases [post]
+func CreateKnowledgeBase(c *gin.Context) {
+	var req KnowledgeBaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	kb := &model.KnowledgeBase{
+		Eid:       config.GetEID(c),
+		CreatedBy: config.GetUserId(c),
+	}
+	req.applyTo(kb)
+	if err := kb.Create(); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(kb))
+}
+
+// @Summary 更新知识库
+// @Description 更新知识库配置，修改 embedding 模型或分块参数后需重新索引文档
+// @Tags KnowledgeBase
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "知识库ID"
+// @Param request body KnowledgeBaseRequest true "知识库信息"
+// @Success 200 {object} model.CommonResponse{data=model.KnowledgeBase} "成功"
+// @Router /api/knowledge_bases/{id} [put]
+func UpdateKnowledgeBase(c *gin.Context) {
+	kb, ok := getKnowledgeBaseFromParam(c)
+	if !ok {
+		return
+	}
+	var req KnowledgeBaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	req.applyTo(kb)
+	if err := kb.Update(); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(kb))
+}
+
+// @Summary 删除知识库
+// @Description 删除知识库及其文档、向量和智能体绑定
+// @Tags KnowledgeBase
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "知识库ID"
+// @Success 200 {object} model.CommonResponse "成功"
+// @Router /api/knowledge_bases/{id} [delete]
+func DeleteKnowledgeBase(c *gin.Context) {
+	kb, ok := getKnowledgeBaseFromParam(c)
+	if !ok {
+		return
+	}
+	if err := knowledge.DeleteKnowledgeBase(kb); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+// @Summary 获取知识库文档列表
+// @Description 获取知识库下的文档及其索引状态（0待处理 1处理中 2已完成 3失败）
+// @Tags KnowledgeBase
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "知识库ID"
+// @Param offset query int false "分页偏移量" default(0)
+// @Param limit query int false "分页大小" default(10)
+// @Success 200 {object} model.CommonResponse{data=KnowledgeDocumentsResponse} "成功"
+// @Router /api/knowledge_bases/{id}/documents [get]
+func GetKnowledgeDocuments(c *gin.Context) {
+	kb, ok := getKnowledgeBaseFromParam(c)
+	if !ok {
+		return
+	}
+	var req KnowledgeBaseListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	count, docs, err := model.GetKnowledgeDocuments(kb.Eid, kb.ID, req.Offset, req.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(KnowledgeDocumentsResponse{
+		Count:     count,
+		Documents: docs,
+	}))
+}
+
+// @Summary 添加知识库文档
+// @Description 将已上传的文件加入知识库，文档在后台异步解析、分块和向量化
+// @Tags KnowledgeBase
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "知识库ID"
+// @Param request body KnowledgeDocumentRequest true "文件ID列表"
+// @Success 200 {object} model.CommonResponse{data=[]model.KnowledgeDocument} "成功"
+// @Router /api/knowledge_bases/{id}/documents [post]
+func AddKnowledgeDocuments(c *gin.Context) {
+	kb, ok := getKnowledgeBaseFromParam(c)
+	if !ok {
+		return
+	}
+	var req KnowledgeDocumentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	docs := make([]*model.KnowledgeDocument, 0, len(req.FileIDs))
+	for _, fileID := range req.FileIDs {
+		uploadFile, err := model.GetUploadFileByID(fileID)
+		if err != nil || uploadFile.Eid != kb.Eid {
+			c.JSON(http.StatusNotFound, model.NotFound.ToNewErrorResponse("file not found"))
+			return
+		}
+		doc := &model.KnowledgeDocument{
+			Eid:             kb.Eid,
+			KnowledgeBaseID: kb.ID,
+			FileID:          uploadFile.ID,
+			FileName:        uploadFile.FileName,
+			Status:          model.KnowledgeDocumentStatusPending,
+		}
+		if err := model.CreateKnowledgeDocument(doc); err != nil {
+			c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+			return
+		}
+		docs = append(docs, doc)
+	}
+
+	for _, doc := range docs {
+		knowledge.IngestDocumentAsync(kb, doc)
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(docs))
+}
+
+// @Summary 重新索引知识库文档
+// @Description 重新解析、分块并向量化文档
+// @Tags KnowledgeBase
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "知识库ID"
+// @Param document_id path int true "文档ID"
+// @Success 200 {object} model.CommonResponse{data=model.KnowledgeDocument} "成功"
+// @Router /api/knowledge_bases/{id}/documents/{document_id}/reindex [post]
+func ReindexKnowledgeDocument(c *gin.Context) {
+	kb, doc, ok := getKnowledgeDocumentFromParam(c)
+	if !ok {
+		return
+	}
+	doc.Status = model.KnowledgeDocumentStatusPending
+	if err := model.UpdateKnowledgeDocument(doc); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	knowledge.IngestDocumentAsync(kb, doc)
+	c.JSON(http.StatusOK, model.Success.ToResponse(doc))
+}
+
+// @Summary 删除知识库文档
+// @Description 删除文档及其分块和向量
+// @Tags KnowledgeBase
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "知识库ID"
+// @Param document_id path int true "文档ID"
+// @Success 200 {object} model.CommonResponse "成功"
+// @Router /api/knowledge_bases/{id}/documents/{document_id} [delete]
+func DeleteKnowledgeDocument(c *gin.Context) {
+	_, doc, ok := getKnowledgeDocumentFromParam(c)
+	if !ok {
+		return
+	}
+	if err := knowledge.DeleteDocument(doc); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+func getKnowledgeDocumentFromParam(c *gin.Context) (*model.KnowledgeBase, *model.KnowledgeDocument, bool) {
+	kb, ok := getKnowledgeBaseFromParam(c)
+	if !ok {
+		return nil, nil, false
+	}
+	documentID, err := strconv.ParseInt(c.Param("document_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return nil, nil, false
+	}
+	doc, err := model.GetKnowledgeDocumentByID(kb.Eid, documentID)
+	if err != nil || doc.KnowledgeBaseID != kb.ID {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(nil))
+		return nil, nil, false
+	}
+	return kb, doc, true
+}
+
+// @Summary 知识库检索测试
+// @Description 使用知识库的检索配置返回命中的分块及相似度
+// @Tags KnowledgeBase
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "知识库ID"
+// @Param request body KnowledgeRetrieveRequest true "查询内容"
+// @Success 200 {object} model.CommonResponse{data=[]model.KnowledgeCitation} "成功"
+// @Router /api/knowledge_bases/{id}/retrieve [post]
+func RetrieveKnowledgeBase(c *gin.Context) {
+	kb, ok := getKnowledgeBaseFromParam(c)
+	if !ok {
+		return
+	}
+	var req KnowledgeRetrieveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	citations, err := knowledge.Retrieve(c.Request.Context(), kb.Eid, []int64{kb.ID}, req.Query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.SystemError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(citations))
+}
+
+// @Summary 获取智能体绑定的知识库
+// @Description 获取智能体绑定的知识库列表
+// @Tags KnowledgeBase
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Success 200 {object} model.CommonResponse{data=[]int64} "知识库ID列表"
+// @Router /api/agents/{agent_id}/knowledge_bases [get]
+func GetAgentKnowledgeBases(c *gin.Context) {
+	agentID, err := strconv.ParseInt(c.Param("agent_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return
+	}
+	ids, err := model.GetAgentKnowledgeBaseIDs(config.GetEID(c), agentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(ids))
+}
+
+// @Summary 设置智能体绑定的知识库
+// @Description 覆盖式设置智能体绑定的知识库，对话时自动检索并注入上下文
+// @Tags KnowledgeBase
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param request body AgentKnowledgeBasesRequest true "知识库ID列表"
+// @Success 200 {object} model.CommonResponse "成功"
+// @Router /api/agents/{agent_id}/knowledge_bases [put]
+func SetAgentKnowledgeBases(c *gin.Context) {
+	agentID, err := strconv.ParseInt(c.Param("agent_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return
+	}
+	var req AgentKnowledgeBasesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	eid := config.GetEID(c)
+	if _, err := model.GetAgentByID(eid, agentID); err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(nil))
+		return
+	}
+	for _, id := range req.KnowledgeBaseIDs {
+		if _, err := model.GetKnowledgeBaseByID(eid, id); err != nil {
+			c.JSON(http.StatusNotFound, model.NotFound.ToNewErrorResponse("knowledge base not found"))
+			return
+		}
+	}
+	if err := model.SetAgentKnowledgeBases(eid, agentID, req.KnowledgeBaseIDs); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/common/utils"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/security"
+	"github.com/gin-gonic/gin"
+)
+
+func createLoginAudit(c *gin.Context, userID int64, account string, method string, result string, reason string) {
+	model.CreateLoginAudit(&model.LoginAudit{
+		Eid:       config.GetEID(c),
+		UserID:    userID,
+		Account:   account,
+		Method:    method,
+		Result:    result,
+		Reason:    reason,
+		IP:        utils.GetClientIP(c),
+		UserAgent: c.Request.UserAgent(),
+		Device:    utils.GetClientDevice(c),
+	})
+}
+
+// checkLoginLock 认证前检查账号锁定，已锁定时写入审计与响应并返回 false
+func checkLoginLock(c *gin.Context, account string, method string) bool {
+	status, err := security.CheckLogin(config.GetEID(c), account)
+	if errors.Is(err, security.ErrAccountLocked) {
+		createLoginAudit(c, 0, account, method, model.LoginResultLocked, model.LoginReasonAccountLocked)
+		resp := model.AccountLocked.ToErrorResponse(err)
+		resp.Data = status
+		c.JSON(http.StatusTooManyRequests, resp)
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return false
+	}
+	return true
+}
+
+// loginFailed 记录一次认证失败并返回 401，data 中带有验证码与锁定状态
+func loginFailed(c *gin.Context, userID int64, account string, method string, reason string, err error) {
+	createLoginAudit(c, userID, account, method, model.LoginResultFailure, reason)
+	status, recordErr := security.RecordLoginFailure(config.GetEID(c), account)
+	if recordErr != nil {
+		logger.SysErrorf("record login failure failed: %v", recordErr)
+	}
+	resp := model.UnauthorizedError.ToErrorResponse(err)
+	resp.Data = status
+	c.JSON(http.StatusUnauthorized, resp)
+}
+
+// GetLoginAuditsRequest 登录审计查询参数
+type GetLoginAuditsRequest struct {
+	Offset    int    `form:"offset" default:"0"`
+	Limit     int    `form:"limit" default:"10"`
+	StartTime int64  `form:"start_time"`
+	EndTime   int64  `form:"end_time"`
+	UserID    int64  `form:"user_id"`
+	Account   string `form:"account"`
+	Method    string `form:"method"`
+	Result    string `form:"result"`
+}
+
+type LoginAuditsResponse struct {
+	Count       int64               `json:"count"`
+	LoginAudits []*model.LoginAudit `json:"login_audits"`
+}
+
+// @Summary 获取登录审计日志
+// @Description 按时间范围、账号、登录方式与结果分页查询当前站点的登录记录，失败记录带有失败原因
+// @Tags SystemLog
+// @Accept json
+// @Produce json
+// @Param offset query int false "offset" default(0)
+// @Param limit query int false "每页数量" default(10)
+// @Param start_time query int64 false "开始时间（毫秒时间戳）"
+// @Param end_time query int64 false "结束时间（毫秒时间戳）"
+// @Param user_id query int64 false "用户ID筛选（可选）"
+// @Param account query string false "登录账号，模糊匹配（可选）"
+// @Param method query string false "登录方式：password、sms、two_factor、oidc、dingtalk、feishu（可选）"
+// @Param result query string false "结果：success、failure、locked（可选）"
+// @Security BearerAuth
+// @Success 200 {object} model.CommonResponse{data=LoginAuditsResponse{}} "成功返回登录记录"
+// @Failure 400 {object} model.CommonResponse "参数验证失败"
+// @Failure 500 {object} model.CommonResponse "数据库操作失败"
+// @Router /api/login_audits [get]
+func GetLoginAudits(c *gin.Context) {
+	var req GetLoginAuditsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToErrorResponse(err))
+		return
+	}
+
+	audits, total, err := model.GetLoginAuditsByConditions(
+		config.GetEID(c),
+		req.UserID,
+		req.Account,
+		req.Method,
+		req.Result,
+		req.StartTime,
+		req.EndTime,
+		req.Offset,
+		req.Limit,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToErrorResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(LoginAuditsResponse{
+		Count:       total,
+		LoginAudits: audits,
+	}))
+}
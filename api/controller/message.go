@@ -18,9 +18,11 @@ type MessagesResponse struct {
 // EnhancedMessage 增强的消息结构，包含解析后的内容
 type EnhancedMessage struct {
 	*model.Message
-	MessageType   model.MessageType `json:"message_type"`   // 消息类型
-	ParsedMessage interface{}       `json:"parsed_message"` // 解析后的 message 内容
-	ParsedAnswer  interface{}       `json:"parsed_answer"`  // 解析后的 answer 内容
+	MessageType   model.MessageType      `json:"message_type"`   // 消息类型
+	ParsedMessage interface{}            `json:"parsed_message"` // 解析后的 message 内容
+	ParsedAnswer  interface{}            `json:"parsed_answer"`  // 解析后的 answer 内容
+	Feedback      *model.MessageFeedback `json:"feedback"`       // 当前用户的评价，未评价为 null
+	SiblingIDs    []int64                `json:"sibling_ids"`    // 同一父节点下的消息ID（含自身），用于切换分支
 }
 
 type MessageListRequest struct {
@@ -72,6 +74,22 @@ func convertToEnhancedMessages(messages []*model.Message) []*EnhancedMessage {
 	return enhancedMessages
 }
 
+// attachUserFeedback 为消息附加当前用户的评价
+func attachUserFeedback(c *gin.Context, messages []*EnhancedMessage) []*EnhancedMessage {
+	ids := make([]int64, 0, len(messages))
+	for _, msg := range messages {
+		ids = append(ids, msg.ID)
+	}
+	feedbackMap, err := model.GetUserFeedbackMap(config.GetEID(c), config.GetUserId(c), ids)
+	if err != nil {
+		return messages
+	}
+	for _, msg := range messages {
+		msg.Feedback = feedbackMap[msg.ID]
+	}
+	return messages
+}
+
 // @Summary Get messages by agent
 // @Description Get messages between user and specific agent with pagination and keyword search
 // @Tags Message
@@ -109,7 +127,7 @@ func GetMessagesByUserAndAgent(c *gin.Context) {
 
 	c.JSON(http.StatusOK, model.Success.ToResponse(&MessagesResponse{
 		Count:    count,
-		Messages: convertToEnhancedMessages(messages),
+		Messages: attachUserFeedback(c, convertToEnhancedMessages(messages)),
 	}))
 }
 
@@ -242,8 +260,112 @@ func GetMessagesByConversation(c *gin.Context) {
 		}
 	}
 
+	enhancedMessages := attachUserFeedback(c, convertToEnhancedMessages(messages))
+	// 附加兄弟消息ID，前端据此展示 "< 2/3 >" 分支切换
+	parentIDs := make([]int64, 0, len(messages))
+	for _, msg := range messages {
+		parentIDs = append(parentIDs, msg.ParentID)
+	}
+	if siblingIDs, err := model.GetConversationSiblingIDs(eid, conversation_id, parentIDs); err == nil {
+		for _, msg := range enhancedMessages {
+			msg.SiblingIDs = siblingIDs[msg.ParentID]
+		}
+	}
+
 	c.JSON(http.StatusOK, model.Success.ToResponse(&MessagesResponse{
 		Count:    count,
-		Messages: convertToEnhancedMessages(messages),
+		Messages: enhancedMessages,
+	}))
+}
+
+type SwitchBranchRequest struct {
+	MessageID int64 `json:"message_id" binding:"required" example:"1"` // 要切换到的消息ID
+}
+
+type SwitchBranchResponse struct {
+	ActiveMessageID int64 `json:"active_message_id"` // 切换后当前分支末尾的消息ID
+}
+
+// getBranchConversation 获取当前用户可访问的会话ID，管理员可访问企业内所有会话
+func getBranchConversation(c *gin.Context) (int64, bool) {
+	conversationID, err := strconv.ParseInt(c.Param("conversation_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return 0, false
+	}
+	eid := config.GetEID(c)
+	if common.IsAdmin(c) {
+		_, err = model.AdminGetConversationByID(eid, conversationID)
+	} else {
+		_, err = model.GetConversationByID(eid, config.GetUserId(c), conversationID)
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(err))
+		return 0, false
+	}
+	return conversationID, true
+}
+
+// @Summary Get message siblings
+// @Description Get all versions of a message produced by regenerate or edit (messages sharing the same parent)
+// @Tags Message
+// @Produce json
+// @Security BearerAuth
+// @Param conversation_id path int true "Conversation ID"
+// @Param message_id path int true "Message ID"
+// @Success 200 {object} model.CommonResponse{data=MessagesResponse} "Success"
+// @Router /api/conversations/{conversation_id}/messages/{message_id}/siblings [get]
+func GetMessageSiblings(c *gin.Context) {
+	conversationID, ok := getBranchConversation(c)
+	if !ok {
+		return
+	}
+	messageID, err := strconv.ParseInt(c.Param("message_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return
+	}
+
+	messages, err := model.GetMessageSiblings(config.GetEID(c), conversationID, messageID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(&MessagesResponse{
+		Count:    int64(len(messages)),
+		Messages: attachUserFeedback(c, convertToEnhancedMessages(messages)),
+	}))
+}
+
+// @Summary Switch conversation branch
+// @Description Switch the active branch of a conversation to the one containing the given message
+// @Tags Message
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param conversation_id path int true "Conversation ID"
+// @Param request body SwitchBranchRequest true "Target message"
+// @Success 200 {object} model.CommonResponse{data=SwitchBranchResponse} "Success"
+// @Router /api/conversations/{conversation_id}/active_message [put]
+func SwitchConversationBranch(c *gin.Context) {
+	conversationID, ok := getBranchConversation(c)
+	if !ok {
+		return
+	}
+	var req SwitchBranchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	activeMessageID, err := model.SwitchConversationBranch(config.GetEID(c), conversationID, req.MessageID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(&SwitchBranchResponse{
+		ActiveMessageID: activeMessageID,
 	}))
 }
@@ -0,0 +1,277 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/53AI/53AIHub/common"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/gin-gonic/gin"
+)
+
+// MessageFeedbackRequest 消息评价请求参数
+type MessageFeedbackRequest struct {
+	Rating  int    `json:"rating" binding:"required" example:"-1"` // 评价：1 点赞，-1 点踩
+	Reason  string `json:"reason" example:"inaccurate"`            // 原因分类：inaccurate/irrelevant/incomplete/harmful/format/helpful/other
+	Comment string `json:"comment" example:"引用的数据已经过时"`            // 评价内容
+}
+
+// FeedbackFilterRequest 评价统计与查询的筛选条件
+type FeedbackFilterRequest struct {
+	AgentID   int64  `form:"agent_id"`   // 智能体ID
+	ModelName string `form:"model_name"` // 模型名
+	ChannelID int64  `form:"channel_id"` // 渠道ID
+	Rating    int    `form:"rating"`     // 评价：1 点赞，-1 点踩，不传为全部
+	Reason    string `form:"reason"`     // 原因分类
+	StartTime int64  `form:"start_time"` // 开始时间（毫秒时间戳）
+	EndTime   int64  `form:"end_time"`   // 结束时间（毫秒时间戳）
+	Dimension string `form:"dimension"`  // 统计维度：agent/model/channel
+	Offset    int    `form:"offset"`     // 分页偏移量
+	Limit     int    `form:"limit"`      // 分页大小
+}
+
+// LowRatedConversationsResponse 差评会话列表响应
+type LowRatedConversationsResponse struct {
+	Count         int64                         `json:"count"`
+	Conversations []*model.LowRatedConversation `json:"conversations"`
+}
+
+// FeedbackExportLine 导出的 JSONL 单行，messages 可直接用于微调数据集
+type FeedbackExportLine struct {
+	Messages       []map[string]interface{} `json:"messages"`
+	Rating         int                      `json:"rating"`
+	Reason         string                   `json:"reason,omitempty"`
+	Comment        string                   `json:"comment,omitempty"`
+	MessageID      int64                    `json:"message_id"`
+	ConversationID int64                    `json:"conversation_id"`
+	AgentID        int64                    `json:"agent_id"`
+	ModelName      string                   `json:"model_name"`
+}
+
+func (req *FeedbackFilterRequest) toFilter() model.FeedbackFilter {
+	return model.FeedbackFilter{
+		AgentID:   req.AgentID,
+		ModelName: req.ModelName,
+		ChannelID: req.ChannelID,
+		Rating:    req.Rating,
+		Reason:    req.Reason,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+	}
+}
+
+// getFeedbackMessage 获取当前用户可评价的消息，管理员可评价企业内所有消息
+func getFeedbackMessage(c *gin.Context) (*model.Message, bool) {
+	messageID, err := strconv.ParseInt(c.Param("message_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return nil, false
+	}
+	message, err := model.GetMessageByID(config.GetEID(c), messageID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(nil))
+		return nil, false
+	}
+	if message.UserID != config.GetUserId(c) && !common.IsAdmin(c) {
+		c.JSON(http.StatusForbidden, model.ForbiddenError.ToResponse(nil))
+		return nil, false
+	}
+	return message, true
+}
+
+// @Summary 评价消息
+// @Description 对智能体回答点赞或点踩，可附带原因分类和评价内容，重复提交覆盖之前的评价
+// @Tags MessageFeedback
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param message_id path int true "消息ID"
+// @Param request body MessageFeedbackRequest true "评价内容"
+// @Success 200 {object} model.CommonResponse{data=model.MessageFeedback} "成功"
+// @Router /api/messages/{message_id}/feedback [put]
+func SaveMessageFeedback(c *gin.Context) {
+	message, ok := getFeedbackMessage(c)
+	if !ok {
+		return
+	}
+	var req MessageFeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	feedback := &model.MessageFeedback{
+		Eid:            message.Eid,
+		MessageID:      message.ID,
+		UserID:         config.GetUserId(c),
+		ConversationID: message.ConversationID,
+		AgentID:        message.AgentID,
+		ModelName:      message.ModelName,
+		ChannelID:      int64(message.ChannelId),
+		Rating:         req.Rating,
+		Reason:         req.Reason,
+		Comment:        req.Comment,
+	}
+	if err := feedback.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if err := model.SaveMessageFeedback(feedback); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+
+	saved, err := model.GetMessageFeedback(feedback.Eid, feedback.UserID, feedback.MessageID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(saved))
+}
+
+// @Summary 取消消息评价
+// @Description 删除当前用户对消息的评价
+// @Tags MessageFeedback
+// @Produce json
+// @Security BearerAuth
+// @Param message_id path int true "消息ID"
+// @Success 200 {object} model.CommonResponse "成功"
+// @Router /api/messages/{message_id}/feedback [delete]
+func DeleteMessageFeedback(c *gin.Context) {
+	message, ok := getFeedbackMessage(c)
+	if !ok {
+		return
+	}
+	if err := model.DeleteMessageFeedback(message.Eid, config.GetUserId(c), message.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+// @Summary 评价统计
+// @Description 按智能体、模型或渠道维度和天聚合点赞、点踩数量
+// @Tags MessageFeedback
+// @Produce json
+// @Security BearerAuth
+// @Param dimension query string false "统计维度：agent/model/channel" default(agent)
+// @Param agent_id query int false "智能体ID"
+// @Param model_name query string false "模型名"
+// @Param channel_id query int false "渠道ID"
+// @Param start_time query int false "开始时间（毫秒时间戳）"
+// @Param end_time query int false "结束时间（毫秒时间戳）"
+// @Success 200 {object} model.CommonResponse{data=[]model.FeedbackStat} "成功"
+// @Router /api/feedbacks/stats [get]
+func GetFeedbackStats(c *gin.Context) {
+	var req FeedbackFilterRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	stats, err := model.GetFeedbackStats(config.GetEID(c), req.Dimension, req.toFilter())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(stats))
+}
+
+// @Summary 差评会话列表
+// @Description 获取收到点踩的会话，按最近差评时间倒序，用于优化智能体提示词
+// @Tags MessageFeedback
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id query int false "智能体ID"
+// @Param model_name query string false "模型名"
+// @Param channel_id query int false "渠道ID"
+// @Param reason query string false "原因分类"
+// @Param start_time query int false "开始时间（毫秒时间戳）"
+// @Param end_time query int false "结束时间（毫秒时间戳）"
+// @Param offset query int false "分页偏移量" default(0)
+// @Param limit query int false "分页大小" default(10)
+// @Success 200 {object} model.CommonResponse{data=LowRatedConversationsResponse} "成功"
+// @Router /api/feedbacks/low_rated_conversations [get]
+func GetLowRatedConversations(c *gin.Context) {
+	var req FeedbackFilterRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	count, list, err := model.GetLowRatedConversations(config.GetEID(c), req.toFilter(), req.Offset, req.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(LowRatedConversationsResponse{
+		Count:         count,
+		Conversations: list,
+	}))
+}
+
+// @Summary 导出评价数据
+// @Description 以 JSONL 格式导出评价及对应的对话内容，可用于构建微调数据集
+// @Tags MessageFeedback
+// @Produce application/x-ndjson
+// @Security BearerAuth
+// @Param agent_id query int false "智能体ID"
+// @Param model_name query string false "模型名"
+// @Param rating query int false "评价：1 点赞，-1 点踩"
+// @Param reason query string false "原因分类"
+// @Param start_time query int false "开始时间（毫秒时间戳）"
+// @Param end_time query int false "结束时间（毫秒时间戳）"
+// @Success 200 {string} string "JSONL 文件"
+// @Router /api/feedbacks/export [get]
+func ExportFeedbacks(c *gin.Context) {
+	var req FeedbackFilterRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	filename := fmt.Sprintf("feedback_%s.jsonl", time.Now().Format("20060102150405"))
+	c.Header("Content-Type", "application/x-ndjson; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	encoder.SetEscapeHTML(false)
+	messageTypes := make(map[int64]model.MessageType)
+	err := model.IterateFeedbackWithMessages(config.GetEID(c), req.toFilter(), 200, func(feedback *model.MessageFeedback, message *model.Message) error {
+		messageType, ok := messageTypes[message.AgentID]
+		if !ok {
+			messageType = message.GetMessageType()
+			messageTypes[message.AgentID] = messageType
+		}
+		// 工作流消息不是对话格式，不导出
+		if messageType != model.MessageTypeChat {
+			return nil
+		}
+		messages, err := message.ParseChatMessage()
+		if err != nil {
+			return nil
+		}
+		messages = append(messages, map[string]interface{}{
+			"role":    "assistant",
+			"content": message.Answer,
+		})
+		return encoder.Encode(FeedbackExportLine{
+			Messages:       messages,
+			Rating:         feedback.Rating,
+			Reason:         feedback.Reason,
+			Comment:        feedback.Comment,
+			MessageID:      message.ID,
+			ConversationID: message.ConversationID,
+			AgentID:        message.AgentID,
+			ModelName:      message.ModelName,
+		})
+	})
+	if err != nil {
+		c.Error(err)
+	}
+}
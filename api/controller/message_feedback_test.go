@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/53AI/53AIHub/common/session"
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/middleware"
+	"github.com/53AI/53AIHub/model"
+	"github.com/gin-gonic/gin"
+)
+
+func setupFeedback(t *testing.T) *model.Message {
+	testutil.SetupDB(t, &model.Message{}, &model.MessageFeedback{}, &model.Conversation{}, &model.Role{},
+		&model.RoleAssignment{}, &model.MemberBinding{}, &model.MemberDepartmentRelation{})
+	conversation := &model.Conversation{Eid: 1, UserID: 2, AgentID: 5, Title: "报销"}
+	if err := model.CreateConversation(conversation); err != nil {
+		t.Fatal(err)
+	}
+	message := &model.Message{Eid: 1, UserID: 2, AgentID: 5, ConversationID: conversation.ConversationID,
+		Message: `[{"role":"user","content":"报销流程"}]`, Answer: "请联系财务", ModelName: "gpt-4o", ChannelId: 3}
+	if err := model.DB.Create(message).Error; err != nil {
+		t.Fatal(err)
+	}
+	return message
+}
+
+func feedbackRequest(method string, messageID int64, userID int64, role int64, body string) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, fmt.Sprintf("/api/messages/%d/feedback", messageID), strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{{Key: "message_id", Value: fmt.Sprint(messageID)}}
+	c.Set(session.ENV_EID, int64(1))
+	c.Set(session.SESSION_USER_ID, userID)
+	c.Set(session.SESSION_USER_ROLE, role)
+	return c, w
+}
+
+func TestSaveMessageFeedback(t *testing.T) {
+	message := setupFeedback(t)
+
+	for _, tc := range []struct {
+		name   string
+		userID int64
+		role   int64
+		body   string
+		code   int
+	}{
+		{"invalid rating", 2, model.RoleCommonUser, `{"rating":2}`, http.StatusBadRequest},
+		{"invalid reason", 2, model.RoleCommonUser, `{"rating":-1,"reason":"boring"}`, http.StatusBadRequest},
+		{"other member", 3, model.RoleCommonUser, `{"rating":1}`, http.StatusForbidden},
+		{"owner", 2, model.RoleCommonUser, `{"rating":1,"reason":"helpful"}`, http.StatusOK},
+		{"owner overrides", 2, model.RoleCommonUser, `{"rating":-1,"reason":"inaccurate","comment":"数据过时"}`, http.StatusOK},
+		{"admin", 1, model.RoleAdminUser, `{"rating":-1,"reason":"incomplete"}`, http.StatusOK},
+	} {
+		c, w := feedbackRequest(http.MethodPut, message.ID, tc.userID, tc.role, tc.body)
+		SaveMessageFeedback(c)
+		if w.Code != tc.code {
+			t.Fatalf("%s: code = %d, want %d: %s", tc.name, w.Code, tc.code, w.Body.String())
+		}
+	}
+	c, w := feedbackRequest(http.MethodPut, message.ID+1, 2, model.RoleCommonUser, `{"rating":1}`)
+	SaveMessageFeedback(c)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("missing message: code = %d", w.Code)
+	}
+
+	feedback, err := model.GetMessageFeedback(1, 2, message.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if feedback.Rating != model.FeedbackRatingDown || feedback.Comment != "数据过时" || feedback.ModelName != "gpt-4o" ||
+		feedback.ChannelID != 3 || feedback.ConversationID != message.ConversationID {
+		t.Fatalf("unexpected feedback: %+v", feedback)
+	}
+	var count int64
+	model.DB.Model(&model.MessageFeedback{}).Count(&count)
+	if count != 2 {
+		t.Fatalf("feedback count = %d, want one per user", count)
+	}
+
+	c, w = feedbackRequest(http.MethodDelete, message.ID, 2, model.RoleCommonUser, "")
+	DeleteMessageFeedback(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete: code = %d", w.Code)
+	}
+	if _, err := model.GetMessageFeedback(1, 2, message.ID); err == nil {
+		t.Fatal("feedback should be deleted")
+	}
+}
+
+func TestListLowRatedConversations(t *testing.T) {
+	message := setupFeedback(t)
+	c, _ := feedbackRequest(http.MethodPut, message.ID, 2, model.RoleCommonUser, `{"rating":-1,"reason":"inaccurate"}`)
+	SaveMessageFeedback(c)
+
+	// 查看评价统计需要 feedback:read 权限
+	router := gin.New()
+	var userID, role int64
+	router.GET("/api/feedbacks/low_rated_conversations", func(c *gin.Context) {
+		c.Set(session.ENV_EID, int64(1))
+		c.Set(session.SESSION_USER_ID, userID)
+		c.Set(session.SESSION_USER_ROLE, role)
+	}, middleware.PermissionAuth(model.PermFeedbackRead), GetLowRatedConversations)
+	list := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/feedbacks/low_rated_conversations?reason=inaccurate", nil))
+		return w
+	}
+
+	userID, role = 2, model.RoleCommonUser
+	if w := list(); w.Code != http.StatusForbidden {
+		t.Fatalf("member without permission: code = %d", w.Code)
+	}
+	viewer := &model.Role{Eid: 1, Name: "质检", PermissionList: []string{model.PermFeedbackRead}}
+	if err := model.CreateRole(viewer); err != nil {
+		t.Fatal(err)
+	}
+	if err := model.AssignRole(1, viewer.ID, model.RoleSubjectUser, []int64{2}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range []int64{model.RoleCommonUser, model.RoleAdminUser} {
+		role = r
+		w := list()
+		var resp struct {
+			Data LowRatedConversationsResponse `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("role %d: %d %s", r, w.Code, w.Body.String())
+		}
+		if resp.Data.Count != 1 || resp.Data.Conversations[0].ConversationID != message.ConversationID ||
+			resp.Data.Conversations[0].DownCount != 1 || resp.Data.Conversations[0].Conversation == nil {
+			t.Fatalf("role %d: unexpected list %s", r, w.Body.String())
+		}
+	}
+}
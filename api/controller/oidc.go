@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/oidc"
+	"github.com/gin-gonic/gin"
+)
+
+type OIDCAuthorizeResponse struct {
+	AuthorizeURL string `json:"authorize_url"`
+}
+
+type OIDCLoginRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+// @Summary 获取单点登录身份提供方
+// @Description 返回企业已启用的 OpenID Connect 身份提供方，用于登录页展示
+// @Tags SSO
+// @Produce json
+// @Success 200 {object} model.CommonResponse{data=[]oidc.ProviderInfo} "Success"
+// @Router /api/sso/oidc/providers [get]
+func GetOIDCProviders(c *gin.Context) {
+	providers, err := oidc.ListProviders(config.GetEID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.SystemError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(providers))
+}
+
+// @Summary 发起单点登录
+// @Description 生成身份提供方的授权地址（授权码模式 + PKCE），前端跳转到该地址
+// @Tags SSO
+// @Produce json
+// @Param provider path string true "身份提供方 ID"
+// @Success 200 {object} model.CommonResponse{data=OIDCAuthorizeResponse} "Success"
+// @Router /api/sso/oidc/{provider}/authorize [get]
+func OIDCAuthorize(c *gin.Context) {
+	eid := config.GetEID(c)
+	provider, err := oidc.GetProvider(eid, c.Param("provider"))
+	if err != nil {
+		oidcError(c, err)
+		return
+	}
+	authorizeURL, err := oidc.AuthorizeURL(eid, provider)
+	if err != nil {
+		logger.SysErrorf("oidc authorize failed: provider=%s err=%v", provider.ID, err)
+		c.JSON(http.StatusBadGateway, model.NetworkError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(OIDCAuthorizeResponse{AuthorizeURL: authorizeURL}))
+}
+
+// @Summary 单点登录回调
+// @Description 身份提供方回调前端后，前端提交 code 与 state 完成登录；首次登录按配置自动创建成员并同步部门与用户组；需要两步验证时 data 为 TwoFactorChallengeResponse
+// @Tags SSO
+// @Accept json
+// @Produce json
+// @Param provider path string true "身份提供方 ID"
+// @Param request body OIDCLoginRequest true "授权码与 state"
+// @Success 200 {object} model.CommonResponse{data=SmsLoginResponse} "Success"
+// @Router /api/sso/oidc/{provider}/login [post]
+func OIDCLogin(c *gin.Context) {
+	var req OIDCLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	eid := config.GetEID(c)
+	result, err := oidc.Exchange(eid, c.Param("provider"), req.Code, req.State)
+	if err != nil {
+		oidcError(c, err)
+		return
+	}
+	user, err := oidc.ResolveUser(result)
+	if err != nil {
+		createLoginAudit(c, 0, c.Param("provider"), model.LoginMethodOIDC, model.LoginResultFailure, err.Error())
+		oidcError(c, err)
+		return
+	}
+
+	if !startLogin(c, user) {
+		return
+	}
+	createLoginAudit(c, user.UserID, user.Username, model.LoginMethodOIDC, model.LoginResultSuccess, "")
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(&SmsLoginResponse{
+		LoginResponse: LoginResponse{
+			AccessToken: user.AccessToken,
+			UserID:      user.UserID,
+		},
+		Username: user.Username,
+		Nickname: user.Nickname,
+	}))
+}
+
+func oidcError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, oidc.ErrNotEnabled), errors.Is(err, oidc.ErrProviderNotFound):
+		c.JSON(http.StatusNotFound, model.NotFound.ToErrorResponse(err))
+	case errors.Is(err, oidc.ErrUserNotProvisioned), errors.Is(err, oidc.ErrUserDisabled),
+		errors.Is(err, oidc.ErrEmailNotAllowed), errors.Is(err, oidc.ErrAccountConflict):
+		c.JSON(http.StatusForbidden, model.ForbiddenError.ToErrorResponse(err))
+	default:
+		// state 失效、令牌兑换或 ID Token 校验失败
+		logger.SysErrorf("oidc login failed: %v", err)
+		c.JSON(http.StatusUnauthorized, model.AuthFailed.ToErrorResponse(err))
+	}
+}
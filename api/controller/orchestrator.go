@@ -0,0 +1,143 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/53AI/53AIHub/common"
+	"github.com/53AI/53AIHub/common/ctxkey"
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/orchestrator"
+	"github.com/gin-gonic/gin"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+// orchestrate 编排智能体先通过工具调用请求有权限的子智能体，子智能体的回答作为参考资料交给最终回答。
+// 编排失败时不影响对话，由编排智能体直接回答
+func orchestrate(c *gin.Context, chatRequest *ChatRequest, agent *model.Agent) {
+	ctx := c.Request.Context()
+	cfg, err := model.ParseOrchestratorConfig(agent.CustomConfig)
+	if err != nil {
+		logger.Errorf(ctx, "parse orchestrator config failed: %s", err.Error())
+		return
+	}
+	user, err := model.GetUserByID(config.GetUserId(c))
+	if err != nil {
+		logger.Errorf(ctx, "orchestrator get user failed: %s", err.Error())
+		return
+	}
+	candidates, err := orchestrator.Candidates(cfg, agent.Eid, user, common.IsAdmin(c))
+	if err != nil {
+		logger.Errorf(ctx, "orchestrator load sub agents failed: %s", err.Error())
+		return
+	}
+
+	var conversationID int64
+	if conversation, err := GetSessionConversation(c); err == nil {
+		conversationID = conversation.ConversationID
+	}
+	messageIDs := make([]int64, 0)
+	// 子智能体与路由请求产生的消息不属于会话，用量计入当前会话，回答消息创建后再挂到其下
+	subRelay := func(ctx context.Context, sub *model.Agent, chatRequest *ChatRequest, tools []relaymodel.Tool) (*relaymodel.Message, error) {
+		rc, w := newRelayContext(ctx, sub, user.UserID)
+		rc.Set(ctxkey.UsageConversationID, conversationID)
+		if tools != nil {
+			rc.Set(ctxkey.OrchestratorTools, tools)
+			if vars, ok := c.Get(ctxkey.PromptVariables); ok {
+				rc.Set(ctxkey.PromptVariables, vars)
+			}
+		}
+		resp, err := relayInternally(rc, w, chatRequest, sub)
+		if id := rc.GetInt64(ctxkey.MessageID); id > 0 {
+			messageIDs = append(messageIDs, id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &resp.Choices[0].Message, nil
+	}
+	runner := &orchestrator.Runner{
+		Route: func(ctx context.Context, messages []relaymodel.Message, tools []relaymodel.Tool) (*relaymodel.Message, error) {
+			return subRelay(ctx, agent, &ChatRequest{Messages: fromRelayMessages(messages)}, tools)
+		},
+		Call: func(ctx context.Context, sub *model.Agent, query string) (string, error) {
+			reply, err := subRelay(ctx, sub, &ChatRequest{Messages: []Message{{Role: "user", Content: query}}}, nil)
+			if err != nil {
+				return "", err
+			}
+			return reply.StringContent(), nil
+		},
+	}
+
+	calls, err := runner.Run(ctx, cfg, toRelayMessages(chatRequest.Messages), candidates)
+	if err != nil {
+		logger.Errorf(ctx, "orchestrator route failed: %s", err.Error())
+	}
+	c.Set(ctxkey.SubAgentMessageIDs, messageIDs)
+	if prompt := orchestrator.ContextPrompt(calls); prompt != "" {
+		c.Set(ctxkey.SubAgentContext, prompt)
+	}
+}
+
+func toRelayMessages(messages []Message) []relaymodel.Message {
+	result := make([]relaymodel.Message, 0, len(messages))
+	for _, m := range messages {
+		result = append(result, relaymodel.Message{Role: m.Role, Content: m.Content, ToolCalls: m.ToolCalls, ToolCallId: m.ToolCallID})
+	}
+	return result
+}
+
+func fromRelayMessages(messages []relaymodel.Message) []Message {
+	result := make([]Message, 0, len(messages))
+	for _, m := range messages {
+		result = append(result, Message{Role: m.Role, Content: m.StringContent(), ToolCalls: m.ToolCalls, ToolCallID: m.ToolCallId})
+	}
+	return result
+}
+
+// validateOrchestratorConfig 编排智能体保存时校验 custom_config 中的子智能体配置
+func validateOrchestratorConfig(eid int64, agentID int64, agentType int, customConfig string) error {
+	if agentType != model.AgentTypeOrchestrator {
+		return nil
+	}
+	cfg, err := model.ParseOrchestratorConfig(customConfig)
+	if err != nil {
+		return err
+	}
+	return orchestrator.Validate(cfg, eid, agentID)
+}
+
+// @Summary Get sub agent messages
+// @Description Get the messages produced when an orchestrator agent called sub agents (and routed between them) for the given answer
+// @Tags Message
+// @Produce json
+// @Security BearerAuth
+// @Param conversation_id path int true "Conversation ID"
+// @Param message_id path int true "Message ID"
+// @Success 200 {object} model.CommonResponse{data=MessagesResponse} "Success"
+// @Router /api/conversations/{conversation_id}/messages/{message_id}/sub_agent_messages [get]
+func GetSubAgentMessages(c *gin.Context) {
+	conversationID, ok := getBranchConversation(c)
+	if !ok {
+		return
+	}
+	messageID, err := strconv.ParseInt(c.Param("message_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return
+	}
+
+	messages, err := model.GetSubAgentMessages(config.GetEID(c), conversationID, messageID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(&MessagesResponse{
+		Count:    int64(len(messages)),
+		Messages: convertToEnhancedMessages(messages),
+	}))
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+	"github.com/53AI/53AIHub/service/dingtalk"
+	"github.com/53AI/53AIHub/service/feishu"
+	"github.com/53AI/53AIHub/service/orgsync"
+	"github.com/gin-gonic/gin"
+)
+
+type OrgLoginRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+// @Summary 发起钉钉 / 飞书登录
+// @Description 生成平台授权地址与一次性 state；PC 端跳转后展示二维码，也可使用 client_id 与 state 嵌入平台扫码组件
+// @Tags SSO
+// @Produce json
+// @Param platform path string true "平台：dingtalk、feishu"
+// @Param redirect_uri query string true "授权回调地址，需与开发者后台配置一致"
+// @Success 200 {object} model.CommonResponse{data=orgsync.LoginInfo} "Success"
+// @Router /api/sso/{platform}/authorize [get]
+func OrgLoginAuthorize(c *gin.Context) {
+	redirectURI := c.Query("redirect_uri")
+	if redirectURI == "" {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return
+	}
+	eid := config.GetEID(c)
+	auth, err := service.GetOrgAuthenticator(eid, c.Param("platform"))
+	if err != nil {
+		orgLoginError(c, err)
+		return
+	}
+	info, err := orgsync.Authorize(eid, auth, redirectURI)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.SystemError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(info))
+}
+
+// @Summary 钉钉 / 飞书登录回调
+// @Description 平台回调前端后，前端提交 code 与 state 完成登录；成员首次登录时按手机号、邮箱关联企业成员，按配置自动创建；需要两步验证时 data 为 TwoFactorChallengeResponse
+// @Tags SSO
+// @Accept json
+// @Produce json
+// @Param platform path string true "平台：dingtalk、feishu"
+// @Param request body OrgLoginRequest true "授权码与 state"
+// @Success 200 {object} model.CommonResponse{data=SmsLoginResponse} "Success"
+// @Router /api/sso/{platform}/login [post]
+func OrgLogin(c *gin.Context) {
+	var req OrgLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	eid := config.GetEID(c)
+	auth, err := service.GetOrgAuthenticator(eid, c.Param("platform"))
+	if err != nil {
+		orgLoginError(c, err)
+		return
+	}
+	user, err := orgsync.Login(eid, auth, req.Code, req.State)
+	if err != nil {
+		createLoginAudit(c, 0, "", c.Param("platform"), model.LoginResultFailure, err.Error())
+		orgLoginError(c, err)
+		return
+	}
+
+	if !startLogin(c, user) {
+		return
+	}
+	if err := user.UpdateStatusToJoin(); err != nil {
+		c.JSON(http.StatusInternalServerError, model.SystemError.ToResponse(err))
+		return
+	}
+	createLoginAudit(c, user.UserID, user.Username, c.Param("platform"), model.LoginResultSuccess, "")
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(&SmsLoginResponse{
+		LoginResponse: LoginResponse{
+			AccessToken: user.AccessToken,
+			UserID:      user.UserID,
+		},
+		Username: user.Username,
+		Nickname: user.Nickname,
+	}))
+}
+
+func orgLoginError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrOrgSyncNotSupported), errors.Is(err, dingtalk.ErrNotEnabled), errors.Is(err, feishu.ErrNotEnabled):
+		c.JSON(http.StatusNotFound, model.NotFound.ToErrorResponse(err))
+	case errors.Is(err, orgsync.ErrUserNotProvisioned), errors.Is(err, orgsync.ErrUserDisabled), errors.Is(err, orgsync.ErrAccountConflict):
+		c.JSON(http.StatusForbidden, model.ForbiddenError.ToErrorResponse(err))
+	default:
+		// state 失效或平台接口调用失败
+		logger.SysErrorf("org login failed: %v", err)
+		c.JSON(http.StatusUnauthorized, model.AuthFailed.ToErrorResponse(err))
+	}
+}
@@ -7,10 +7,12 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/53AI/53AIHub/common"
 	"github.com/53AI/53AIHub/config"
 	"github.com/53AI/53AIHub/model"
 	"github.com/53AI/53AIHub/service"
+	"github.com/53AI/53AIHub/service/promptlib"
+	"github.com/53AI/53AIHub/service/prompttpl"
+	"github.com/53AI/53AIHub/service/rbac"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
@@ -146,6 +148,10 @@ func CreatePrompt(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
 		return
 	}
+	if err := prompttpl.Validate(promptReq.Content); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
 
 	eid := config.GetEID(c)
 	userID := config.GetUserId(c)
@@ -157,7 +163,7 @@ func CreatePrompt(c *gin.Context) {
 		promptType = model.PromptTypePersonal
 	}
 
-	if promptType == model.PromptTypeSystem && !common.IsAdmin(c) {
+	if promptType == model.PromptTypeSystem && !rbac.HasPermission(c, model.PermPromptWrite) {
 		c.JSON(http.StatusForbidden, model.AuthFailed.ToResponse(nil))
 		return
 	}
@@ -209,6 +215,11 @@ func CreatePrompt(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(nil))
 		return
 	}
+	if _, err := promptlib.RecordRevision(tx, prompt, userID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(nil))
+		return
+	}
 
 	// 添加分组关联
 	allGroupIds := make([]int64, 0)
@@ -339,6 +350,10 @@ func UpdatePrompt(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
 		return
 	}
+	if err = prompttpl.Validate(promptReq.Content); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
 
 	eid := config.GetEID(c)
 	prompt, err := model.GetPromptByID(promptID)
@@ -347,7 +362,7 @@ func UpdatePrompt(c *gin.Context) {
 		return
 	}
 
-	if prompt.Type == model.PromptTypeSystem && !common.IsAdmin(c) {
+	if prompt.Type == model.PromptTypeSystem && !rbac.HasPermission(c, model.PermPromptWrite) {
 		c.JSON(http.StatusForbidden, model.AuthFailed.ToResponse(nil))
 		return
 	}
@@ -365,6 +380,13 @@ func UpdatePrompt(c *gin.Context) {
 		return
 	}
 
+	// 历史提示词没有修订记录，修改前先以原内容记录一版
+	if _, err := promptlib.RecordRevision(tx, prompt, prompt.UserID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(nil))
+		return
+	}
+
 	// 更新提示词字段
 	prompt.Name = promptReq.Name
 	prompt.Content = promptReq.Content
@@ -396,6 +418,11 @@ func UpdatePrompt(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(nil))
 		return
 	}
+	if _, err := promptlib.RecordRevision(tx, prompt, config.GetUserId(c)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(nil))
+		return
+	}
 
 	// 添加分组关联
 	allGroupIds := make([]int64, 0)
@@ -460,7 +487,7 @@ func DeletePrompt(c *gin.Context) {
 		return
 	}
 
-	if prompt.Type == model.PromptTypeSystem && !common.IsAdmin(c) {
+	if prompt.Type == model.PromptTypeSystem && !rbac.HasPermission(c, model.PermPromptWrite) {
 		c.JSON(http.StatusForbidden, model.AuthFailed.ToResponse(nil))
 		return
 	}
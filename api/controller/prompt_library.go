@@ -0,0 +1,229 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/promptlib"
+	"github.com/gin-gonic/gin"
+)
+
+// PromptUsageRequest 记录提示词插入对话
+type PromptUsageRequest struct {
+	AgentID        int64 `json:"agent_id" example:"1"`        // 插入时所在的智能体，可为空
+	ConversationID int64 `json:"conversation_id" example:"0"` // 插入时所在的会话，可为空
+}
+
+// pathPrompt 取路径中的提示词并校验归属企业；个人提示词仅创建者可见
+func pathPrompt(c *gin.Context) (*model.Prompt, bool) {
+	promptID, err := strconv.Atoi(c.Param("pid"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return nil, false
+	}
+	prompt, err := model.GetPromptByID(promptID)
+	if err != nil || prompt.Eid != config.GetEID(c) ||
+		(prompt.Type == model.PromptTypePersonal && prompt.UserID != config.GetUserId(c)) {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(nil))
+		return nil, false
+	}
+	return prompt, true
+}
+
+// @Summary 获取提示词修订记录
+// @Tags Prompt
+// @Produce json
+// @Security BearerAuth
+// @Param pid path int true "提示词ID"
+// @Success 200 {object} model.CommonResponse{data=[]model.PromptRevision} "成功"
+// @Router /api/prompts/{pid}/revisions [get]
+func GetPromptRevisions(c *gin.Context) {
+	prompt, ok := pathPrompt(c)
+	if !ok {
+		return
+	}
+	revisions, err := model.GetPromptRevisions(prompt.PromptID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(revisions))
+}
+
+// @Summary 获取提示词的指定修订版本
+// @Tags Prompt
+// @Produce json
+// @Security BearerAuth
+// @Param pid path int true "提示词ID"
+// @Param revision path int true "修订版本号"
+// @Success 200 {object} model.CommonResponse{data=model.PromptRevision} "成功"
+// @Router /api/prompts/{pid}/revisions/{revision} [get]
+func GetPromptRevision(c *gin.Context) {
+	prompt, ok := pathPrompt(c)
+	if !ok {
+		return
+	}
+	revision, err := strconv.Atoi(c.Param("revision"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return
+	}
+	r, err := model.GetPromptRevision(prompt.PromptID, revision)
+	if err != nil {
+		promptLibraryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(r))
+}
+
+// @Summary 对比提示词的两个修订版本
+// @Description to 默认为当前修订版本，from 默认为 to 的上一版本
+// @Tags Prompt
+// @Produce json
+// @Security BearerAuth
+// @Param pid path int true "提示词ID"
+// @Param from query int false "起始修订版本号"
+// @Param to query int false "目标修订版本号"
+// @Success 200 {object} model.CommonResponse{data=promptlib.RevisionDiff} "成功"
+// @Router /api/prompts/{pid}/revisions/diff [get]
+func DiffPromptRevisions(c *gin.Context) {
+	prompt, ok := pathPrompt(c)
+	if !ok {
+		return
+	}
+	to, err := strconv.Atoi(c.DefaultQuery("to", strconv.Itoa(prompt.Revision)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return
+	}
+	from, err := strconv.Atoi(c.DefaultQuery("from", strconv.Itoa(to-1)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return
+	}
+	fromRevision, err := model.GetPromptRevision(prompt.PromptID, from)
+	if err != nil {
+		promptLibraryError(c, err)
+		return
+	}
+	toRevision, err := model.GetPromptRevision(prompt.PromptID, to)
+	if err != nil {
+		promptLibraryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(promptlib.Diff(fromRevision, toRevision)))
+}
+
+// @Summary 复制系统提示词为个人提示词
+// @Description 复制后的个人提示词通过 source_prompt_id、source_revision 记录来源
+// @Tags Prompt
+// @Produce json
+// @Security BearerAuth
+// @Param pid path int true "系统提示词ID"
+// @Success 200 {object} model.CommonResponse{data=model.Prompt} "成功"
+// @Failure 400 {object} model.CommonResponse "不是系统提示词"
+// @Router /api/prompts/{pid}/fork [post]
+func ForkPrompt(c *gin.Context) {
+	prompt, ok := pathPrompt(c)
+	if !ok {
+		return
+	}
+	if prompt.Status != model.PromptStatusNormal {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(nil))
+		return
+	}
+	fork, err := promptlib.Fork(prompt, config.GetUserId(c))
+	if err != nil {
+		promptLibraryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(fork))
+}
+
+// @Summary 记录提示词插入对话
+// @Tags Prompt
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param pid path int true "提示词ID"
+// @Param request body PromptUsageRequest true "插入时所在的智能体与会话"
+// @Success 200 {object} model.CommonResponse "成功"
+// @Router /api/prompts/{pid}/usages [post]
+func RecordPromptUsage(c *gin.Context) {
+	prompt, ok := pathPrompt(c)
+	if !ok {
+		return
+	}
+	var req PromptUsageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if req.AgentID > 0 {
+		if _, err := model.GetAgentByID(prompt.Eid, req.AgentID); err != nil {
+			c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(errors.New("agent not found")))
+			return
+		}
+	}
+	if err := promptlib.RecordUsage(prompt, config.GetUserId(c), req.AgentID, req.ConversationID); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+// @Summary 获取提示词使用统计
+// @Description 累计插入次数、按智能体的分布以及被复制为个人提示词的次数
+// @Tags Prompt
+// @Produce json
+// @Security BearerAuth
+// @Param pid path int true "提示词ID"
+// @Success 200 {object} model.CommonResponse{data=promptlib.Stats} "成功"
+// @Router /api/prompts/{pid}/stats [get]
+func GetPromptStats(c *gin.Context) {
+	prompt, ok := pathPrompt(c)
+	if !ok {
+		return
+	}
+	stats, err := promptlib.GetStats(prompt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(stats))
+}
+
+// @Summary 获取热门提示词排行
+// @Description 按统计周期内插入对话的次数排序，周期为自然日、自然周、自然月或全部
+// @Tags Prompt
+// @Produce json
+// @Security BearerAuth
+// @Param period query string false "统计周期：day、week、month、all，默认 week"
+// @Param type query int false "提示词类型：1系统；2个人，默认全部"
+// @Param limit query int false "数量，默认 20，最大 100"
+// @Success 200 {object} model.CommonResponse{data=[]model.PromptRanking} "成功"
+// @Router /api/prompts/rankings [get]
+func GetPromptRankings(c *gin.Context) {
+	promptType, _ := strconv.Atoi(c.Query("type"))
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	rankings, err := promptlib.Rankings(config.GetEID(c), c.DefaultQuery("period", promptlib.PeriodWeek), promptType, limit)
+	if err != nil {
+		promptLibraryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(rankings))
+}
+
+func promptLibraryError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, model.ErrPromptRevisionNotFound):
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(err))
+	case errors.Is(err, promptlib.ErrNotForkable), errors.Is(err, promptlib.ErrInvalidPeriod):
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+	default:
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+	}
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/prompttpl"
+	"github.com/gin-gonic/gin"
+)
+
+// PromptTemplateVariable 可在提示词模板中使用的内置变量
+type PromptTemplateVariable struct {
+	Name        string `json:"name" example:"user.nickname"`
+	Description string `json:"description" example:"用户昵称"`
+}
+
+// PromptPreviewRequest 提示词模板预览请求
+type PromptPreviewRequest struct {
+	Content string `json:"content" binding:"required" example:"你好 {{user.nickname}}，请围绕{{form.topic}}回答"`
+	// 表单字段的取值，键不含 form. 前缀
+	Variables map[string]string `json:"variables"`
+}
+
+// PromptPreviewResponse 提示词模板预览结果
+type PromptPreviewResponse struct {
+	Content       string   `json:"content"`        // 渲染后的内容
+	Variables     []string `json:"variables"`      // 模板引用的变量
+	FormFields    []string `json:"form_fields"`    // 需要用户填写的表单字段
+	MissingFields []string `json:"missing_fields"` // 未提供取值的表单字段
+}
+
+// @Summary 获取提示词模板内置变量
+// @Description 返回可在智能体提示词和提示词库中使用的内置变量，表单字段以 {{form.xxx}} 引用
+// @Tags Prompt
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.CommonResponse{data=[]PromptTemplateVariable} "成功"
+// @Router /api/prompts/template_variables [get]
+func GetPromptTemplateVariables(c *gin.Context) {
+	variables := make([]PromptTemplateVariable, 0, len(prompttpl.BuiltinVariables))
+	for name, description := range prompttpl.BuiltinVariables {
+		variables = append(variables, PromptTemplateVariable{Name: name, Description: description})
+	}
+	sort.Slice(variables, func(i, j int) bool { return variables[i].Name < variables[j].Name })
+	c.JSON(http.StatusOK, model.Success.ToResponse(variables))
+}
+
+// @Summary 预览提示词模板
+// @Description 校验模板语法，并以当前用户、企业和传入的表单字段渲染模板
+// @Tags Prompt
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body PromptPreviewRequest true "模板内容与表单字段"
+// @Success 200 {object} model.CommonResponse{data=PromptPreviewResponse} "成功"
+// @Failure 400 {object} model.CommonResponse "模板语法错误"
+// @Router /api/prompts/preview [post]
+func PreviewPromptTemplate(c *gin.Context) {
+	var req PromptPreviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if err := prompttpl.Validate(req.Content); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	vars := prompttpl.BuildVars(config.GetEID(c), config.GetUserId(c), req.Variables)
+	formFields := prompttpl.FormFields(req.Content)
+	missing := make([]string, 0)
+	for _, field := range formFields {
+		if req.Variables[field] == "" {
+			missing = append(missing, field)
+		}
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(PromptPreviewResponse{
+		Content:       prompttpl.Render(req.Content, vars),
+		Variables:     prompttpl.Variables(req.Content),
+		FormFields:    formFields,
+		MissingFields: missing,
+	}))
+}
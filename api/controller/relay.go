@@ -26,6 +26,8 @@ import (
 	"github.com/53AI/53AIHub/service/hub_adaptor/dify"
 	"github.com/53AI/53AIHub/service/hub_adaptor/fastgpt"
 	"github.com/53AI/53AIHub/service/hub_adaptor/n8n"
+	"github.com/53AI/53AIHub/service/knowledge"
+	"github.com/53AI/53AIHub/service/prompttpl"
 	"github.com/gin-gonic/gin"
 	"github.com/songquanpeng/one-api/common"
 	oneapi_model "github.com/songquanpeng/one-api/model"
@@ -47,6 +49,9 @@ import (
 type Message struct {
 	Role    string `json:"role" example:"user"`
 	Content string `json:"content" example:"who are you"`
+	// 编排智能体请求子智能体时的工具调用与调用结果
+	ToolCalls  []relay_model.Tool `json:"tool_calls,omitempty"`
+	ToolCallID string             `json:"tool_call_id,omitempty"`
 }
 
 type ChatRequest struct {
@@ -58,6 +63,12 @@ type ChatRequest struct {
 	FrequencyPenalty float64   `json:"frequency_penalty,omitempty"`
 	TopP             float64   `json:"top_p,omitempty"`
 	ConversationID   int64     `json:"conversation_id"`
+	// 重新生成指定消息的回答，新回答与原消息互为兄弟节点
+	RegenerateMessageID int64 `json:"regenerate_message_id,omitempty"`
+	// 编辑指定消息的提问后重新发送，从该消息处分叉出新分支
+	EditMessageID int64 `json:"edit_message_id,omitempty"`
+	// 提示词模板中 {{form.xxx}} 表单字段的取值，不会转发给模型
+	Variables map[string]string `json:"variables,omitempty"`
 }
 
 // WorkflowRunRequest 工作流运行请求结构体
@@ -284,6 +295,25 @@ func processChatRequest(c *gin.Context, chatRequest *ChatRequest, agent *model.A
 	}
 
 	chatRequest.Model = requestModel
+	if len(chatRequest.Variables) > 0 {
+		c.Set(ctxkey.PromptVariables, chatRequest.Variables)
+		chatRequest.Variables = nil
+	}
+
+	if err := resolveMessageBranch(c, chatRequest); err != nil {
+		c.JSON(400, model.ParamError.ToOpenAIErrorRespone(err))
+		return
+	}
+
+	// 编排智能体自身的路由请求不再重复执行护栏与编排
+	if _, routing := c.Get(ctxkey.OrchestratorTools); !routing {
+		if !applyGuardrail(c, chatRequest, agent) {
+			return
+		}
+		if agent.AgentType == model.AgentTypeOrchestrator {
+			orchestrate(c, chatRequest, agent)
+		}
+	}
 
 	// if 1o model, unset temperature, presence_penalty, frequency_penalty, top_p
 	if agent.ChannelType == channeltype.OpenAI && strings.Contains(strings.ToLower(chatRequest.Model), "o1") {
@@ -408,7 +438,8 @@ func getMappedModelName(modelName string, mapping map[string]string) (string, bo
 	return modelName, false
 }
 
-func setSystemPrompt(ctx context.Context, request *relay_model.GeneralOpenAIRequest, prompt string) (reset bool) {
+func setSystemPrompt(ctx context.Context, request *relay_model.GeneralOpenAIRequest, prompt string, vars prompttpl.Vars) (reset bool) {
+	prompt = prompttpl.Render(prompt, vars)
 	if prompt == "" {
 		return false
 	}
@@ -428,6 +459,37 @@ func setSystemPrompt(ctx context.Context, request *relay_model.GeneralOpenAIRequ
 	return true
 }
 
+// resolveMessageBranch 在重试渠道前确定新消息在会话树中的父节点，重试产生的消息互为兄弟节点而不是串成一条链
+func resolveMessageBranch(c *gin.Context, chatRequest *ChatRequest) error {
+	targetMessageID := chatRequest.RegenerateMessageID
+	if targetMessageID == 0 {
+		targetMessageID = chatRequest.EditMessageID
+	}
+	// 分支参数不转发给上游
+	chatRequest.RegenerateMessageID = 0
+	chatRequest.EditMessageID = 0
+
+	conversation, err := GetSessionConversation(c)
+	if err != nil || conversation.ConversationID == 0 {
+		return nil
+	}
+
+	var parentID int64
+	isRoot := false
+	if targetMessageID > 0 {
+		parentID, isRoot, err = model.ResolveBranchParent(conversation.Eid, conversation.ConversationID, targetMessageID)
+	} else {
+		parentID, err = model.EnsureConversationMessageTree(conversation.Eid, conversation.ConversationID)
+		isRoot = parentID == 0
+	}
+	if err != nil {
+		return err
+	}
+	c.Set(ctxkey.ParentMessageID, parentID)
+	c.Set(ctxkey.BranchRoot, isRoot)
+	return nil
+}
+
 // createInitialMessage 在请求发起前创建占位消息，返回 messageID
 func createInitialMessage(c *gin.Context, agent *model.Agent, user_id int64, conversationId int64, textRequest *relay_model.GeneralOpenAIRequest, meta *meta.Meta, requestId string) (int64, error) {
 	ctx := c.Request.Context()
@@ -459,6 +521,12 @@ func createInitialMessage(c *gin.Context, agent *model.Agent, user_id int64, con
 			// 保存历史配置便于追溯
 			return agent.CustomConfig
 		}(),
+		Citations:    c.GetString(ctxkey.Citations),
+		ParentID:     c.GetInt64(ctxkey.ParentMessageID),
+		IsBranchRoot: c.GetBool(ctxkey.BranchRoot),
+		AgentVersion: agent.Version,
+		ExperimentID: agent.ExperimentID,
+		VariantID:    agent.VariantID,
 	}
 	if err := model.CreateMessage(msg); err != nil {
 		return 0, err
@@ -574,6 +642,9 @@ func RelayTextHelper(c *gin.Context) *relay_model.ErrorWithStatusCode {
 		return openai.ErrorWrapper(err, "invalid_text_request", http.StatusBadRequest)
 	}
 	meta.IsStream = textRequest.Stream
+	if tools, ok := c.Get(ctxkey.OrchestratorTools); ok {
+		textRequest.Tools, _ = tools.([]relay_model.Tool)
+	}
 
 	if meta.IsStream {
 		SetupStreamInterceptor(c)
@@ -599,8 +670,13 @@ func RelayTextHelper(c *gin.Context) *relay_model.ErrorWithStatusCode {
 		return openai.ErrorWrapper(err, "invalid_text_request", http.StatusBadRequest)
 	}
 	systemPromptReset := false
-	if agent.Prompt != "" {
-		systemPromptReset = addAgentPrompt(ctx, textRequest, agent.Prompt, agent.ChannelType)
+	// 智能体绑定了知识库时，检索参考资料并拼接到系统提示词
+	knowledgePrompt := retrieveKnowledgeContext(c, agent, textRequest)
+	if subAgentPrompt := c.GetString(ctxkey.SubAgentContext); subAgentPrompt != "" {
+		knowledgePrompt = strings.TrimSpace(knowledgePrompt + "\n\n" + subAgentPrompt)
+	}
+	if agent.Prompt != "" || knowledgePrompt != "" {
+		systemPromptReset = addAgentPrompt(ctx, textRequest, agent.Prompt, knowledgePrompt, promptVars(c, agent), agent.ChannelType)
 		modifiedBody, err := json.Marshal(textRequest)
 		if err != nil {
 			return openai.ErrorWrapper(err, "marshal_request_failed", http.StatusInternalServerError)
@@ -645,6 +721,13 @@ func RelayTextHelper(c *gin.Context) *relay_model.ErrorWithStatusCode {
 		logger.Errorf(ctx, "createInitialMessage failed: %s", errCreate.Error())
 		return openai.ErrorWrapper(errCreate, "create_message_failed", http.StatusInternalServerError)
 	}
+	c.Set(ctxkey.MessageID, messageID)
+	if value, ok := c.Get(ctxkey.SubAgentMessageIDs); ok {
+		ids, _ := value.([]int64)
+		if err := model.LinkSubAgentMessages(agent.Eid, messageID, ids); err != nil {
+			logger.Errorf(ctx, "link sub agent messages failed: %s", err.Error())
+		}
+	}
 
 	// get request body
 	requestBody, err := getRequestBody(c, meta, textRequest, adaptor)
@@ -693,6 +776,10 @@ func RelayTextHelper(c *gin.Context) *relay_model.ErrorWithStatusCode {
 	}
 
 	responseContent, reasoningContent := GetResponseContent(c, meta.IsStream, resp)
+	if gw := getGuardrailWriter(c); gw != nil {
+		gw.Finish(ctx)
+		responseContent = gw.SavedContent(responseContent)
+	}
 
 	customConfig = service.GetCustomConfig(&adaptor)
 	// post-consume quota
@@ -734,7 +821,59 @@ func isErrorHappened(meta *meta.Meta, resp *http.Response) bool {
 	return false
 }
 
-func addAgentPrompt(ctx context.Context, textRequest *relay_model.GeneralOpenAIRequest, agentPrompt string, channelType int) bool {
+// retrieveKnowledgeContext 使用最后一条用户消息检索智能体绑定的知识库，返回需注入的上下文
+// 命中的引用写入 ctxkey.Citations，随消息一起保存
+func retrieveKnowledgeContext(c *gin.Context, agent *model.Agent, textRequest *relay_model.GeneralOpenAIRequest) string {
+	ctx := c.Request.Context()
+	kbIDs, err := model.GetAgentKnowledgeBaseIDs(agent.Eid, agent.AgentID)
+	if err != nil || len(kbIDs) == 0 {
+		return ""
+	}
+
+	query := ""
+	for i := len(textRequest.Messages) - 1; i >= 0; i-- {
+		if textRequest.Messages[i].Role == "user" {
+			query = textRequest.Messages[i].StringContent()
+			break
+		}
+	}
+	if query == "" {
+		return ""
+	}
+
+	citations, err := knowledge.Retrieve(ctx, agent.Eid, kbIDs, query)
+	if err != nil {
+		logger.Errorf(ctx, "knowledge retrieve failed: %s", err.Error())
+		return ""
+	}
+	contextPrompt, citations := knowledge.BuildContextPrompt(citations)
+	if contextPrompt == "" {
+		return ""
+	}
+	citationsJSON, err := json.Marshal(citations)
+	if err == nil {
+		c.Set(ctxkey.Citations, string(citationsJSON))
+	}
+	logger.Infof(ctx, "knowledge retrieve hit %d chunks", len(citations))
+	return contextPrompt
+}
+
+// promptVars 智能体提示词为模板时，组装当前用户、企业和请求中表单字段的渲染变量
+func promptVars(c *gin.Context, agent *model.Agent) prompttpl.Vars {
+	if !prompttpl.IsTemplate(agent.Prompt) {
+		return nil
+	}
+	value, _ := c.Get(ctxkey.PromptVariables)
+	form, _ := value.(map[string]string)
+	return prompttpl.BuildVars(agent.Eid, config.GetUserId(c), form)
+}
+
+// addAgentPrompt 渲染智能体提示词模板后加入请求；知识库参考资料在渲染后拼接，其中的内容不作为模板解析
+func addAgentPrompt(ctx context.Context, textRequest *relay_model.GeneralOpenAIRequest, agentPrompt string, contextPrompt string, vars prompttpl.Vars, channelType int) bool {
+	agentPrompt = prompttpl.Render(agentPrompt, vars)
+	if contextPrompt != "" {
+		agentPrompt = strings.TrimSpace(agentPrompt + "\n\n" + contextPrompt)
+	}
 	if agentPrompt == "" {
 		return false
 	}
@@ -849,6 +988,11 @@ func postConsumeQuota(c *gin.Context, agent *model.Agent, user_id int64, startTi
 				logger.Errorf(ctx, "UpdateConversation failed: %s", err.Error())
 			}
 		}
+	} else if usageConversationID := c.GetInt64(ctxkey.UsageConversationID); usageConversationID != 0 {
+		// 子智能体调用的用量计入编排智能体所在的会话
+		if err := model.AddConversationUsage(agent.Eid, usageConversationID, int(quotaDelta), totalTokens); err != nil {
+			logger.Errorf(ctx, "AddConversationUsage failed: %s", err.Error())
+		}
 	}
 }
 
@@ -1457,6 +1601,9 @@ func saveWorkflowMessage(c *gin.Context, workflowRequest *WorkflowRunRequest, ag
 		IsStream:          false, // 工作流不支持流式
 		QuotaContent:      quotaContent,
 		AgentCustomConfig: agent.CustomConfig, // 历史记录
+		AgentVersion:      agent.Version,
+		ExperimentID:      agent.ExperimentID,
+		VariantID:         agent.VariantID,
 	}
 
 	// 保存消息到数据库
@@ -1465,6 +1612,7 @@ func saveWorkflowMessage(c *gin.Context, workflowRequest *WorkflowRunRequest, ag
 	}
 
 	logger.SysLogf("工作流消息保存成功 - MessageID: %d, ExecuteID: %s", message.ID, response.ExecuteID)
+	c.Set(ctxkey.MessageID, message.ID)
 
 	// 更新会话的最后消息（如果有会话ID）
 	if conversationId != 0 {
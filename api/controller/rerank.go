@@ -253,17 +253,7 @@ func validateRerankRequest(req *RerankRequest) error {
 
 // getChannelTypeByModel 根据模型名称确定渠道类型
 func getChannelTypeByModel(modelName string) int {
-	// 百炼模型
-	if strings.HasPrefix(modelName, "gte-rerank") {
-		return model.ChannelApiBailian
-	}
-
-	// 可以扩展支持其他厂商的 rerank 模型
-	// if strings.HasPrefix(modelName, "cohere-rerank") {
-	//     return channeltype.Cohere
-	// }
-
-	return -1 // 不支持的模型
+	return service.GetRerankChannelType(modelName)
 }
 
 // executeRerankRequest 执行 rerank 请求
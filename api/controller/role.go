@@ -0,0 +1,308 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/53AI/53AIHub/common/utils"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/rbac"
+	"github.com/gin-gonic/gin"
+)
+
+type RoleRequest struct {
+	Name        string   `json:"name" binding:"required,max=100" example:"智能体编辑"`
+	Description string   `json:"description" binding:"max=255" example:"可以编辑智能体，不能修改支付配置"`
+	Permissions []string `json:"permissions" binding:"required" example:"agent:read,agent:write"`
+}
+
+type RoleAssignmentRequest struct {
+	UserIDs       []int64 `json:"user_ids"`
+	DepartmentIDs []int64 `json:"department_ids"`
+}
+
+func roleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, model.ErrRoleNotFound):
+		c.JSON(http.StatusNotFound, model.NotFound.ToErrorResponse(err))
+	case errors.Is(err, rbac.ErrPermissionEscalation):
+		c.JSON(http.StatusForbidden, model.ForbiddenError.ToErrorResponse(err))
+	case errors.Is(err, rbac.ErrInvalidPermission), errors.Is(err, rbac.ErrInvalidSubject):
+		c.JSON(http.StatusBadRequest, model.ParamError.ToErrorResponse(err))
+	default:
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+	}
+}
+
+// pathRole 路径中的自定义角色，不存在时写入响应并返回 false
+func pathRole(c *gin.Context) (*model.Role, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return nil, false
+	}
+	role, err := model.GetRole(config.GetEID(c), id)
+	if err != nil {
+		roleError(c, err)
+		return nil, false
+	}
+	return role, true
+}
+
+func operatorPermissions(c *gin.Context) ([]string, bool) {
+	permissions, err := rbac.ContextPermissions(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return nil, false
+	}
+	return permissions, true
+}
+
+func createRoleLog(c *gin.Context, action uint8, content string) {
+	model.CreateSystemLog(&model.SystemLog{
+		Eid:      config.GetEID(c),
+		UserID:   config.GetUserId(c),
+		Nickname: config.GetUserNickname(c),
+		Module:   model.SystemLogModuleAdmin,
+		Action:   action,
+		Content:  content,
+		IP:       utils.GetClientIP(c),
+	})
+}
+
+// @Summary 获取权限点列表
+// @Description 自定义角色可选的全部权限点；授予权限时也可以使用 资源:* 或 *
+// @Tags Role
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.CommonResponse{data=[]model.PermissionDefinition} "成功"
+// @Router /api/roles/permissions [get]
+func GetPermissionDefinitions(c *gin.Context) {
+	c.JSON(http.StatusOK, model.Success.ToResponse(model.Permissions))
+}
+
+// @Summary 获取当前用户的权限点
+// @Description 当前登录成员的有效权限点，包含内置角色与授予本人或所在部门的自定义角色，前端据此控制菜单
+// @Tags Role
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.CommonResponse{data=[]string} "成功"
+// @Router /api/users/me/permissions [get]
+func GetMyPermissions(c *gin.Context) {
+	permissions, ok := operatorPermissions(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(permissions))
+}
+
+// @Summary 获取角色列表
+// @Description 内置角色（由成员的 role 数值映射，不可修改）在前，之后是企业的自定义角色
+// @Tags Role
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.CommonResponse{data=[]model.Role} "成功"
+// @Router /api/roles [get]
+func GetRoles(c *gin.Context) {
+	custom, err := model.GetRoles(config.GetEID(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	roles := make([]*model.Role, 0, len(model.BuiltinRoles)+len(custom))
+	for i := range model.BuiltinRoles {
+		roles = append(roles, &model.BuiltinRoles[i])
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(append(roles, custom...)))
+}
+
+// @Summary 创建自定义角色
+// @Description 只能授予操作者自己拥有的权限点
+// @Tags Role
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body RoleRequest true "角色名称与权限点"
+// @Success 200 {object} model.CommonResponse{data=model.Role} "成功"
+// @Failure 400 {object} model.CommonResponse "权限点无效"
+// @Failure 403 {object} model.CommonResponse "授予了操作者没有的权限点"
+// @Router /api/roles [post]
+func CreateRole(c *gin.Context) {
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	operator, ok := operatorPermissions(c)
+	if !ok {
+		return
+	}
+
+	role := &model.Role{
+		Eid:            config.GetEID(c),
+		Name:           req.Name,
+		Description:    req.Description,
+		PermissionList: req.Permissions,
+	}
+	if err := rbac.SaveRole(role, operator); err != nil {
+		roleError(c, err)
+		return
+	}
+
+	createRoleLog(c, model.SystemLogActionCreate, fmt.Sprintf("新增角色【%s】", role.Name))
+	c.JSON(http.StatusOK, model.Success.ToResponse(role))
+}
+
+// @Summary 更新自定义角色
+// @Description 修改后已授予该角色的成员立即按新的权限点鉴权
+// @Tags Role
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "角色ID"
+// @Param request body RoleRequest true "角色名称与权限点"
+// @Success 200 {object} model.CommonResponse{data=model.Role} "成功"
+// @Failure 403 {object} model.CommonResponse "授予了操作者没有的权限点"
+// @Failure 404 {object} model.CommonResponse "角色不存在"
+// @Router /api/roles/{id} [put]
+func UpdateRole(c *gin.Context) {
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	role, ok := pathRole(c)
+	if !ok {
+		return
+	}
+	operator, ok := operatorPermissions(c)
+	if !ok {
+		return
+	}
+
+	role.Name = req.Name
+	role.Description = req.Description
+	role.PermissionList = req.Permissions
+	if err := rbac.SaveRole(role, operator); err != nil {
+		roleError(c, err)
+		return
+	}
+
+	createRoleLog(c, model.SystemLogActionUpdate, fmt.Sprintf("编辑角色【%s】", role.Name))
+	c.JSON(http.StatusOK, model.Success.ToResponse(role))
+}
+
+// @Summary 删除自定义角色
+// @Description 同时收回该角色的全部授权
+// @Tags Role
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "角色ID"
+// @Success 200 {object} model.CommonResponse "成功"
+// @Failure 404 {object} model.CommonResponse "角色不存在"
+// @Router /api/roles/{id} [delete]
+func DeleteRole(c *gin.Context) {
+	role, ok := pathRole(c)
+	if !ok {
+		return
+	}
+	if err := model.DeleteRole(role.Eid, role.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+
+	createRoleLog(c, model.SystemLogActionDelete, fmt.Sprintf("删除角色【%s】", role.Name))
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+// @Summary 获取角色的授权对象
+// @Tags Role
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "角色ID"
+// @Success 200 {object} model.CommonResponse{data=[]model.RoleAssignment} "成功"
+// @Failure 404 {object} model.CommonResponse "角色不存在"
+// @Router /api/roles/{id}/assignments [get]
+func GetRoleAssignments(c *gin.Context) {
+	role, ok := pathRole(c)
+	if !ok {
+		return
+	}
+	assignments, err := model.GetRoleAssignments(role.Eid, role.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(assignments))
+}
+
+// @Summary 授予角色
+// @Description 把自定义角色授予成员或部门，部门的直属成员获得该角色的权限；操作者需拥有该角色的全部权限点
+// @Tags Role
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "角色ID"
+// @Param request body RoleAssignmentRequest true "成员ID与部门ID"
+// @Success 200 {object} model.CommonResponse "成功"
+// @Failure 400 {object} model.CommonResponse "成员或部门不存在"
+// @Failure 403 {object} model.CommonResponse "操作者缺少该角色的权限点"
+// @Router /api/roles/{id}/assignments [post]
+func AssignRole(c *gin.Context) {
+	var req RoleAssignmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	role, ok := pathRole(c)
+	if !ok {
+		return
+	}
+	operator, ok := operatorPermissions(c)
+	if !ok {
+		return
+	}
+	if err := rbac.Assign(role, req.UserIDs, req.DepartmentIDs, operator); err != nil {
+		roleError(c, err)
+		return
+	}
+
+	createRoleLog(c, model.SystemLogActionUpdate, fmt.Sprintf("授予角色【%s】：成员 %v，部门 %v", role.Name, req.UserIDs, req.DepartmentIDs))
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+// @Summary 收回角色
+// @Tags Role
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "角色ID"
+// @Param request body RoleAssignmentRequest true "成员ID与部门ID"
+// @Success 200 {object} model.CommonResponse "成功"
+// @Failure 403 {object} model.CommonResponse "操作者缺少该角色的权限点"
+// @Router /api/roles/{id}/assignments [delete]
+func UnassignRole(c *gin.Context) {
+	var req RoleAssignmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	role, ok := pathRole(c)
+	if !ok {
+		return
+	}
+	operator, ok := operatorPermissions(c)
+	if !ok {
+		return
+	}
+	if err := rbac.Unassign(role, req.UserIDs, req.DepartmentIDs, operator); err != nil {
+		roleError(c, err)
+		return
+	}
+
+	createRoleLog(c, model.SystemLogActionUpdate, fmt.Sprintf("收回角色【%s】：成员 %v，部门 %v", role.Name, req.UserIDs, req.DepartmentIDs))
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
@@ -0,0 +1,338 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/53AI/53AIHub/common/ctxkey"
+	"github.com/53AI/53AIHub/common/session"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/rbac"
+	"github.com/53AI/53AIHub/service/schedule"
+	"github.com/53AI/53AIHub/tasks"
+	"github.com/gin-gonic/gin"
+)
+
+var scheduleRunner = schedule.NewRunner(runScheduleTarget)
+
+func init() {
+	tasks.SetScheduleRunner(scheduleRunner)
+}
+
+// runScheduleTarget 以执行用户的身份运行智能体或工作流，投递到会话时结果写入该会话
+func runScheduleTarget(ctx context.Context, target *schedule.Target) (*schedule.Output, error) {
+	agent := target.Agent
+	c, w := newRelayContext(ctx, agent, target.User.UserID)
+	c.Set(session.SESSION_USER_ROLE, target.User.Role)
+	var conversationID int64
+	if target.Conversation != nil {
+		conversationID = target.Conversation.ConversationID
+		c.Set(session.SESSION_CONVERSATION_ID, conversationID)
+		c.Set(session.SESSION_CONVERSATION, target.Conversation)
+	}
+
+	if agent.AgentType == model.AgentTypeWorkflow {
+		req := &WorkflowRunRequest{
+			Parameters:     target.Parameters,
+			Model:          agent.Model,
+			ConversationID: conversationID,
+		}
+		c.Set("workflow_start_time", time.Now())
+		resp, err := executeWorkflow(c, req, agent)
+		if err != nil {
+			return nil, err
+		}
+		if err := saveWorkflowMessage(c, req, agent, resp); err != nil {
+			return nil, err
+		}
+		content, err := json.Marshal(resp.WorkflowOutputData)
+		if err != nil {
+			return nil, err
+		}
+		return &schedule.Output{Content: string(content), MessageID: c.GetInt64(ctxkey.MessageID)}, nil
+	}
+
+	resp, err := relayInternally(c, w, &ChatRequest{
+		Messages:       []Message{{Role: "user", Content: target.Schedule.Query}},
+		ConversationID: conversationID,
+	}, agent)
+	if err != nil {
+		return nil, err
+	}
+	return &schedule.Output{Content: resp.Choices[0].Message.StringContent(), MessageID: c.GetInt64(ctxkey.MessageID)}, nil
+}
+
+type ScheduleRequest struct {
+	Name     string `json:"name" binding:"required" example:"每日销售日报"`
+	Cron     string `json:"cron" binding:"required" example:"0 9 * * 1-5"`
+	Timezone string `json:"timezone" example:"Asia/Shanghai"`
+	// Query 对话类智能体的提问
+	Query string `json:"query" example:"汇总昨天的销售数据"`
+	// Parameters 工作流的固定输入，智能体配置中的静态参数优先
+	Parameters map[string]interface{} `json:"parameters"`
+	// RunAs 执行用户，默认为当前用户；拥有 user:write 权限的成员可指定其他用户
+	RunAs    int64                  `json:"run_as" example:"1"`
+	Delivery model.ScheduleDelivery `json:"delivery"`
+	Enabled  *bool                  `json:"enabled" example:"true"`
+}
+
+type ScheduleRunsResponse struct {
+	Count int64                `json:"count"`
+	Runs  []*model.ScheduleRun `json:"runs"`
+}
+
+type SchedulesResponse struct {
+	Count     int64             `json:"count"`
+	Schedules []*model.Schedule `json:"schedules"`
+}
+
+func pathSchedule(c *gin.Context) (*model.Agent, *model.Schedule, bool) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return nil, nil, false
+	}
+	id, err := strconv.ParseInt(c.Param("schedule_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(nil))
+		return nil, nil, false
+	}
+	s, err := model.GetSchedule(agent.Eid, id)
+	if err == nil && s.AgentID != agent.AgentID {
+		err = model.ErrScheduleNotFound
+	}
+	if err != nil {
+		scheduleError(c, err)
+		return nil, nil, false
+	}
+	return agent, s, true
+}
+
+func scheduleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, model.ErrScheduleNotFound):
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(err))
+	case errors.Is(err, schedule.ErrInvalidCron), errors.Is(err, schedule.ErrInvalidTarget), errors.Is(err, schedule.ErrInvalidDelivery),
+		errors.Is(err, schedule.ErrInvalidTimezone), errors.Is(err, schedule.ErrInvalidRunAs):
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+	case errors.Is(err, schedule.ErrRunning):
+		c.JSON(http.StatusConflict, model.ParamError.ToResponse(err))
+	default:
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+	}
+}
+
+// @Summary 获取智能体的定时任务列表
+// @Tags Schedule
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param offset query int false "Pagination offset" default(0)
+// @Param limit query int false "Pagination limit" default(10)
+// @Success 200 {object} model.CommonResponse{data=SchedulesResponse} "成功"
+// @Router /api/agents/{agent_id}/schedules [get]
+func GetSchedules(c *gin.Context) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return
+	}
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	count, schedules, err := model.GetSchedules(agent.Eid, agent.AgentID, offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(SchedulesResponse{Count: count, Schedules: schedules}))
+}
+
+// @Summary 创建定时任务
+// @Description 按 cron 表达式（分 时 日 月 周）定时以执行用户的身份运行智能体或工作流，结果投递到会话、邮件（企业 SMTP 配置）或 webhook
+// @Tags Schedule
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param request body ScheduleRequest true "定时任务"
+// @Success 200 {object} model.CommonResponse{data=model.Schedule} "成功"
+// @Router /api/agents/{agent_id}/schedules [post]
+func CreateSchedule(c *gin.Context) {
+	agent, ok := pathAgent(c)
+	if !ok {
+		return
+	}
+	s := &model.Schedule{
+		Eid:       agent.Eid,
+		AgentID:   agent.AgentID,
+		Enabled:   true,
+		CreatedBy: config.GetUserId(c),
+	}
+	if !saveSchedule(c, s) {
+		return
+	}
+	logAgentAction(c, model.SystemLogActionCreate, fmt.Sprintf("创建智能体【%s】的定时任务【%s】", agent.Name, s.Name))
+	c.JSON(http.StatusOK, model.Success.ToResponse(s))
+}
+
+// @Summary 获取定时任务详情
+// @Tags Schedule
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param schedule_id path int true "定时任务ID"
+// @Success 200 {object} model.CommonResponse{data=model.Schedule} "成功"
+// @Router /api/agents/{agent_id}/schedules/{schedule_id} [get]
+func GetSchedule(c *gin.Context) {
+	_, s, ok := pathSchedule(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(s))
+}
+
+// @Summary 修改定时任务
+// @Description 修改后按新的 cron 表达式重新计算下次执行时间
+// @Tags Schedule
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param schedule_id path int true "定时任务ID"
+// @Param request body ScheduleRequest true "定时任务"
+// @Success 200 {object} model.CommonResponse{data=model.Schedule} "成功"
+// @Router /api/agents/{agent_id}/schedules/{schedule_id} [put]
+func UpdateSchedule(c *gin.Context) {
+	agent, s, ok := pathSchedule(c)
+	if !ok {
+		return
+	}
+	if !saveSchedule(c, s) {
+		return
+	}
+	logAgentAction(c, model.SystemLogActionUpdate, fmt.Sprintf("修改智能体【%s】的定时任务【%s】", agent.Name, s.Name))
+	c.JSON(http.StatusOK, model.Success.ToResponse(s))
+}
+
+func saveSchedule(c *gin.Context, s *model.Schedule) bool {
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return false
+	}
+	userID := config.GetUserId(c)
+	if req.RunAs == 0 {
+		req.RunAs = userID
+	}
+	// 指定其他执行用户需有成员管理权限，且不能借用自己无权管理的账号运行智能体
+	if req.RunAs != userID {
+		target, err := model.GetUserByID(req.RunAs)
+		if !rbac.HasPermission(c, model.PermUserWrite) || (err == nil && !rbac.CanManageUser(c, target)) {
+			c.JSON(http.StatusForbidden, model.ForbiddenError.ToResponse(nil))
+			return false
+		}
+	}
+	if req.RunAs != s.RunAs {
+		// 执行用户变更后不再写入原用户的会话
+		s.ConversationID = 0
+	}
+	s.Name = req.Name
+	s.Cron = req.Cron
+	s.Timezone = req.Timezone
+	s.Query = req.Query
+	s.Parameters = req.Parameters
+	s.RunAs = req.RunAs
+	s.DeliveryConfig = req.Delivery
+	if req.Enabled != nil {
+		s.Enabled = *req.Enabled
+	}
+	if err := schedule.Validate(s); err != nil {
+		scheduleError(c, err)
+		return false
+	}
+	next, err := schedule.NextRun(s, time.Now())
+	if err != nil {
+		scheduleError(c, err)
+		return false
+	}
+	s.NextRunTime = next
+	if err := model.SaveSchedule(s); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return false
+	}
+	return true
+}
+
+// @Summary 删除定时任务
+// @Description 同时删除执行记录，已投递到会话的消息保留
+// @Tags Schedule
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param schedule_id path int true "定时任务ID"
+// @Success 200 {object} model.CommonResponse "成功"
+// @Router /api/agents/{agent_id}/schedules/{schedule_id} [delete]
+func DeleteSchedule(c *gin.Context) {
+	agent, s, ok := pathSchedule(c)
+	if !ok {
+		return
+	}
+	if err := model.DeleteSchedule(s.Eid, s.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	logAgentAction(c, model.SystemLogActionDelete, fmt.Sprintf("删除智能体【%s】的定时任务【%s】", agent.Name, s.Name))
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+// @Summary 立即执行定时任务
+// @Description 在后台执行一次并按配置投递结果，不影响下次定时执行；上一次执行未结束时返回 409
+// @Tags Schedule
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param schedule_id path int true "定时任务ID"
+// @Success 200 {object} model.CommonResponse{data=model.ScheduleRun} "成功"
+// @Router /api/agents/{agent_id}/schedules/{schedule_id}/run [post]
+func RunSchedule(c *gin.Context) {
+	agent, s, ok := pathSchedule(c)
+	if !ok {
+		return
+	}
+	run, err := scheduleRunner.Start(s)
+	if err != nil {
+		scheduleError(c, err)
+		return
+	}
+	logAgentAction(c, model.SystemLogActionUpdate, fmt.Sprintf("手动执行智能体【%s】的定时任务【%s】", agent.Name, s.Name))
+	c.JSON(http.StatusOK, model.Success.ToResponse(run))
+}
+
+// @Summary 获取定时任务的执行记录
+// @Tags Schedule
+// @Produce json
+// @Security BearerAuth
+// @Param agent_id path int true "智能体ID"
+// @Param schedule_id path int true "定时任务ID"
+// @Param offset query int false "Pagination offset" default(0)
+// @Param limit query int false "Pagination limit" default(10)
+// @Success 200 {object} model.CommonResponse{data=ScheduleRunsResponse} "成功"
+// @Router /api/agents/{agent_id}/schedules/{schedule_id}/runs [get]
+func GetScheduleRuns(c *gin.Context) {
+	_, s, ok := pathSchedule(c)
+	if !ok {
+		return
+	}
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	count, runs, err := model.GetScheduleRuns(s.Eid, s.ID, offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(ScheduleRunsResponse{Count: count, Runs: runs}))
+}
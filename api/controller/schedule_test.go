@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/53AI/53AIHub/common/session"
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/rbac"
+	"github.com/gin-gonic/gin"
+)
+
+func TestCreateScheduleRunAs(t *testing.T) {
+	testutil.SetupDB(t, &model.Agent{}, &model.User{}, &model.ResourcePermission{}, &model.Schedule{}, &model.SystemLog{},
+		&model.Role{}, &model.RoleAssignment{}, &model.Department{}, &model.MemberDepartmentRelation{}, &model.MemberBinding{})
+	agent := &model.Agent{Eid: 1, Name: "日报", Enable: true}
+	model.DB.Create(agent)
+	model.DB.Create(&model.ResourcePermission{GroupID: 10, ResourceID: agent.AgentID, ResourceType: model.ResourceTypeAgent,
+		Permission: model.PermissionRead})
+	createUser := func(name string, role int64) *model.User {
+		user := &model.User{Eid: 1, Username: name, Nickname: name, Role: role, Status: model.UserStatusJoined, GroupId: 10}
+		model.DB.Create(user)
+		return user
+	}
+	admin := createUser("admin", model.RoleAdminUser)
+	editor := createUser("editor", model.RoleCommonUser)
+	operator := createUser("operator", model.RoleCommonUser)
+	member := createUser("member", model.RoleCommonUser)
+	adminPermissions, _ := rbac.UserPermissions(admin)
+	role := &model.Role{Eid: 1, Name: "成员管理", PermissionList: []string{model.PermUserRead, model.PermUserWrite}}
+	if err := rbac.SaveRole(role, adminPermissions); err != nil {
+		t.Fatal(err)
+	}
+	if err := rbac.Assign(role, []int64{operator.UserID}, nil, adminPermissions); err != nil {
+		t.Fatal(err)
+	}
+
+	create := func(user *model.User, runAs int64) int {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		body := `{"name":"日报","cron":"0 9 * * *","query":"总结昨天","delivery":{"conversation":true},"run_as":` +
+			strconv.FormatInt(runAs, 10) + `}`
+		c.Request = httptest.NewRequest(http.MethodPost, "/api/agents/schedules", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Params = gin.Params{{Key: "agent_id", Value: strconv.FormatInt(agent.AgentID, 10)}}
+		c.Set(session.ENV_EID, int64(1))
+		c.Set(session.SESSION_USER_ID, user.UserID)
+		c.Set(session.SESSION_USER_ROLE, user.Role)
+		CreateSchedule(c)
+		return w.Code
+	}
+
+	cases := []struct {
+		name     string
+		operator *model.User
+		runAs    int64
+		want     int
+	}{
+		{"self", editor, 0, http.StatusOK},
+		{"without user:write", editor, member.UserID, http.StatusForbidden},
+		{"custom role with user:write", operator, member.UserID, http.StatusOK},
+		// 自定义角色不能借管理员身份执行
+		{"custom role runs as admin", operator, admin.UserID, http.StatusForbidden},
+		{"admin", admin, member.UserID, http.StatusOK},
+	}
+	for _, tc := range cases {
+		if code := create(tc.operator, tc.runAs); code != tc.want {
+			t.Errorf("%s: code = %d, want %d", tc.name, code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,381 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/scim"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ScimTokenResponse SCIM 令牌信息，Token 仅在生成时返回
+type ScimTokenResponse struct {
+	*model.ScimToken
+	Token    string `json:"token,omitempty"`
+	Endpoint string `json:"endpoint"` // 在身份提供商中填写的 SCIM 接口地址
+}
+
+func scimBaseURL(c *gin.Context) string {
+	return config.GetProtocol(c) + "://" + config.GetDomain(c) + "/scim/v2"
+}
+
+func scimService(c *gin.Context) *scim.Service {
+	return scim.NewService(config.GetEID(c), scimBaseURL(c))
+}
+
+func scimJSON(c *gin.Context, status int, data interface{}) {
+	c.Header("Content-Type", scim.ContentType)
+	c.JSON(status, data)
+}
+
+func scimError(c *gin.Context, err error) {
+	scimErr := scim.ToError(err)
+	scimJSON(c, scimErr.HTTPStatus(), scimErr)
+}
+
+// @Summary Get SCIM token
+// @Description Get the enterprise SCIM token status and the endpoint to configure in the identity provider (admin only)
+// @Tags SCIM
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.CommonResponse{data=ScimTokenResponse} "Success"
+// @Router /api/scim/token [get]
+func GetScimToken(c *gin.Context) {
+	resp := ScimTokenResponse{Endpoint: scimBaseURL(c)}
+	token, err := model.GetScimTokenByEid(config.GetEID(c))
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	resp.ScimToken = token
+	c.JSON(http.StatusOK, model.Success.ToResponse(resp))
+}
+
+// @Summary Generate SCIM token
+// @Description Generate or rotate the enterprise SCIM bearer token. The plain token is only returned once; the previous token stops working immediately (admin only)
+// @Tags SCIM
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.CommonResponse{data=ScimTokenResponse} "Success"
+// @Router /api/scim/token [post]
+func GenerateScimToken(c *gin.Context) {
+	token, record, err := model.GenerateScimToken(config.GetEID(c), config.GetUserId(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(ScimTokenResponse{
+		ScimToken: record,
+		Token:     token,
+		Endpoint:  scimBaseURL(c),
+	}))
+}
+
+// @Summary Revoke SCIM token
+// @Description Revoke the enterprise SCIM token, disabling SCIM provisioning (admin only)
+// @Tags SCIM
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.CommonResponse "Success"
+// @Router /api/scim/token [delete]
+func DeleteScimToken(c *gin.Context) {
+	if err := model.DeleteScimToken(config.GetEID(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+// @Summary SCIM service provider config
+// @Tags SCIM
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Router /scim/v2/ServiceProviderConfig [get]
+func ScimServiceProviderConfig(c *gin.Context) {
+	scimJSON(c, http.StatusOK, scim.ServiceProviderConfig(scimBaseURL(c)))
+}
+
+// @Summary SCIM resource types
+// @Tags SCIM
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} scim.ListResponse
+// @Router /scim/v2/ResourceTypes [get]
+func ScimResourceTypes(c *gin.Context) {
+	resourceTypes := scim.ResourceTypes(scimBaseURL(c))
+	list := scim.ListResponse{
+		Schemas:      []string{scim.SchemaListResponse},
+		TotalResults: int64(len(resourceTypes)),
+		StartIndex:   1,
+		ItemsPerPage: len(resourceTypes),
+		Resources:    make([]interface{}, 0, len(resourceTypes)),
+	}
+	for _, resourceType := range resourceTypes {
+		list.Resources = append(list.Resources, resourceType)
+	}
+	scimJSON(c, http.StatusOK, list)
+}
+
+// @Summary List SCIM users
+// @Description List internal members. Supports filter (eq, ne, co, sw, ew, pr, gt, ge, lt, le joined by and), startIndex and count
+// @Tags SCIM
+// @Produce json
+// @Security BearerAuth
+// @Param filter query string false "SCIM filter, e.g. userName eq \"john@example.com\""
+// @Param startIndex query int false "1-based start index" default(1)
+// @Param count query int false "Page size" default(100)
+// @Success 200 {object} scim.ListResponse
+// @Router /scim/v2/Users [get]
+func ScimListUsers(c *gin.Context) {
+	var params scim.ListParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		scimError(c, scim.NewError(http.StatusBadRequest, "invalidValue", err.Error()))
+		return
+	}
+	list, err := scimService(c).ListUsers(params)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, list)
+}
+
+// @Summary Get SCIM user
+// @Tags SCIM
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 200 {object} scim.User
+// @Failure 404 {object} scim.Error
+// @Router /scim/v2/Users/{id} [get]
+func ScimGetUser(c *gin.Context) {
+	user, err := scimService(c).GetUser(c.Param("id"))
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, user)
+}
+
+// @Summary Create SCIM user
+// @Description Provision an internal member. active=false creates the member as disabled
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param user body scim.User true "SCIM user"
+// @Success 201 {object} scim.User
+// @Failure 409 {object} scim.Error "userName, email or mobile already exists"
+// @Router /scim/v2/Users [post]
+func ScimCreateUser(c *gin.Context) {
+	var input scim.User
+	if err := c.ShouldBindJSON(&input); err != nil {
+		scimError(c, scim.NewError(http.StatusBadRequest, "invalidSyntax", err.Error()))
+		return
+	}
+	user, err := scimService(c).CreateUser(&input)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusCreated, user)
+}
+
+// @Summary Replace SCIM user
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param user body scim.User true "SCIM user"
+// @Success 200 {object} scim.User
+// @Failure 403 {object} scim.Error "Member not provisioned through SCIM, or administrator identity, contact or status change"
+// @Router /scim/v2/Users/{id} [put]
+func ScimReplaceUser(c *gin.Context) {
+	var input scim.User
+	if err := c.ShouldBindJSON(&input); err != nil {
+		scimError(c, scim.NewError(http.StatusBadRequest, "invalidSyntax", err.Error()))
+		return
+	}
+	user, err := scimService(c).ReplaceUser(c.Param("id"), &input)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, user)
+}
+
+// @Summary Patch SCIM user
+// @Description Apply PatchOp operations. Setting active=false disables the member (UserStatusDisabled)
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Param patch body scim.PatchRequest true "SCIM PatchOp"
+// @Success 200 {object} scim.User
+// @Failure 403 {object} scim.Error "Member not provisioned through SCIM, or administrator identity, contact or status change"
+// @Router /scim/v2/Users/{id} [patch]
+func ScimPatchUser(c *gin.Context) {
+	var patch scim.PatchRequest
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		scimError(c, scim.NewError(http.StatusBadRequest, "invalidSyntax", err.Error()))
+		return
+	}
+	user, err := scimService(c).PatchUser(c.Param("id"), &patch)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, user)
+}
+
+// @Summary Delete SCIM user
+// @Tags SCIM
+// @Security BearerAuth
+// @Param id path string true "User ID"
+// @Success 204
+// @Failure 403 {object} scim.Error "Member not provisioned through SCIM, or administrator identity, contact or status change"
+// @Router /scim/v2/Users/{id} [delete]
+func ScimDeleteUser(c *gin.Context) {
+	if err := scimService(c).DeleteUser(c.Param("id")); err != nil {
+		scimError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary List SCIM groups
+// @Description List departments provisioned through SCIM. Supports filter on id, displayName and externalId, and excludedAttributes=members
+// @Tags SCIM
+// @Produce json
+// @Security BearerAuth
+// @Param filter query string false "SCIM filter, e.g. displayName eq \"Sales\""
+// @Param startIndex query int false "1-based start index" default(1)
+// @Param count query int false "Page size" default(100)
+// @Param excludedAttributes query string false "Set to members to omit members"
+// @Success 200 {object} scim.ListResponse
+// @Router /scim/v2/Groups [get]
+func ScimListGroups(c *gin.Context) {
+	var params scim.ListParams
+	if err := c.ShouldBindQuery(&params); err != nil {
+		scimError(c, scim.NewError(http.StatusBadRequest, "invalidValue", err.Error()))
+		return
+	}
+	list, err := scimService(c).ListGroups(params)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, list)
+}
+
+// @Summary Get SCIM group
+// @Tags SCIM
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Group (department) ID"
+// @Param excludedAttributes query string false "Set to members to omit members"
+// @Success 200 {object} scim.Group
+// @Failure 404 {object} scim.Error
+// @Router /scim/v2/Groups/{id} [get]
+func ScimGetGroup(c *gin.Context) {
+	withMembers := !strings.EqualFold(c.Query("excludedAttributes"), "members")
+	group, err := scimService(c).GetGroup(c.Param("id"), withMembers)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, group)
+}
+
+// @Summary Create SCIM group
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param group body scim.Group true "SCIM group"
+// @Success 201 {object} scim.Group
+// @Failure 409 {object} scim.Error "Group already exists"
+// @Router /scim/v2/Groups [post]
+func ScimCreateGroup(c *gin.Context) {
+	var input scim.Group
+	if err := c.ShouldBindJSON(&input); err != nil {
+		scimError(c, scim.NewError(http.StatusBadRequest, "invalidSyntax", err.Error()))
+		return
+	}
+	group, err := scimService(c).CreateGroup(&input)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusCreated, group)
+}
+
+// @Summary Replace SCIM group
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Group (department) ID"
+// @Param group body scim.Group true "SCIM group"
+// @Success 200 {object} scim.Group
+// @Failure 403 {object} scim.Error "Member not provisioned through SCIM"
+// @Router /scim/v2/Groups/{id} [put]
+func ScimReplaceGroup(c *gin.Context) {
+	var input scim.Group
+	if err := c.ShouldBindJSON(&input); err != nil {
+		scimError(c, scim.NewError(http.StatusBadRequest, "invalidSyntax", err.Error()))
+		return
+	}
+	group, err := scimService(c).ReplaceGroup(c.Param("id"), &input)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, group)
+}
+
+// @Summary Patch SCIM group
+// @Description Apply PatchOp operations on displayName, externalId and members (add/remove/replace, including members[value eq "id"] paths)
+// @Tags SCIM
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Group (department) ID"
+// @Param patch body scim.PatchRequest true "SCIM PatchOp"
+// @Success 200 {object} scim.Group
+// @Failure 403 {object} scim.Error "Member not provisioned through SCIM"
+// @Router /scim/v2/Groups/{id} [patch]
+func ScimPatchGroup(c *gin.Context) {
+	var patch scim.PatchRequest
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		scimError(c, scim.NewError(http.StatusBadRequest, "invalidSyntax", err.Error()))
+		return
+	}
+	group, err := scimService(c).PatchGroup(c.Param("id"), &patch)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+	scimJSON(c, http.StatusOK, group)
+}
+
+// @Summary Delete SCIM group
+// @Tags SCIM
+// @Security BearerAuth
+// @Param id path string true "Group (department) ID"
+// @Success 204
+// @Router /scim/v2/Groups/{id} [delete]
+func ScimDeleteGroup(c *gin.Context) {
+	if err := scimService(c).DeleteGroup(c.Param("id")); err != nil {
+		scimError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/middleware"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/scim"
+	"github.com/gin-gonic/gin"
+)
+
+// scimEngine 按 router/scim.go 注册 SCIM 路由
+func scimEngine() *gin.Engine {
+	engine := gin.New()
+	scimRouter := engine.Group("/scim/v2")
+	scimRouter.Use(middleware.ScimTokenAuth())
+	{
+		scimRouter.GET("/Users", ScimListUsers)
+		scimRouter.POST("/Users", ScimCreateUser)
+		scimRouter.GET("/Users/:id", ScimGetUser)
+		scimRouter.PATCH("/Users/:id", ScimPatchUser)
+		scimRouter.DELETE("/Users/:id", ScimDeleteUser)
+		scimRouter.POST("/Groups", ScimCreateGroup)
+	}
+	return engine
+}
+
+func scimRequest(engine *gin.Engine, token, method, path, body string, out interface{}) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", scim.ContentType)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	engine.ServeHTTP(w, req)
+	if out != nil {
+		json.Unmarshal(w.Body.Bytes(), out)
+	}
+	return w
+}
+
+func TestScimProvisioning(t *testing.T) {
+	testutil.SetupDB(t, &model.ScimToken{}, &model.User{}, &model.MemberBinding{}, &model.Department{},
+		&model.MemberDepartmentRelation{}, &model.UserSession{}, &model.PasswordHistory{}, &model.RoleAssignment{},
+		&model.UserTwoFactor{})
+	engine := scimEngine()
+
+	rotated, _, _ := model.GenerateScimToken(1, 1)
+	token, _, err := model.GenerateScimToken(1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherToken, _, _ := model.GenerateScimToken(2, 1)
+	for _, bad := range []string{"", "invalid", rotated} {
+		w := scimRequest(engine, bad, http.MethodGet, "/scim/v2/Users", "", nil)
+		if w.Code != http.StatusUnauthorized || w.Header().Get("Content-Type") != scim.ContentType {
+			t.Fatalf("token %q: %d %s", bad, w.Code, w.Header().Get("Content-Type"))
+		}
+	}
+
+	// 以停用状态创建内部成员
+	var user scim.User
+	w := scimRequest(engine, token, http.MethodPost, "/scim/v2/Users",
+		`{"userName":"alice@example.com","displayName":"Alice","externalId":"okta-1","active":false}`, &user)
+	if w.Code != http.StatusCreated || user.ID == "" || user.Active == nil || *user.Active {
+		t.Fatalf("create user: %d %s", w.Code, w.Body.String())
+	}
+	userID, _ := strconv.ParseInt(user.ID, 10, 64)
+	stored, err := model.GetUserByID(userID)
+	if err != nil || stored.Type != model.UserTypeInternal || stored.Status != model.UserStatusDisabled || stored.Email != "alice@example.com" {
+		t.Fatalf("unexpected stored user: %+v %v", stored, err)
+	}
+	if w := scimRequest(engine, token, http.MethodPost, "/scim/v2/Users", `{"userName":"Alice@example.com"}`, nil); w.Code != http.StatusConflict {
+		t.Fatalf("duplicate userName: code = %d", w.Code)
+	}
+
+	// 启用与停用映射到成员状态和 SCIM 绑定状态
+	patch := func(active bool) {
+		t.Helper()
+		body := `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"replace","path":"active","value":` +
+			strconv.FormatBool(active) + `}]}`
+		if w := scimRequest(engine, token, http.MethodPatch, "/scim/v2/Users/"+user.ID, body, nil); w.Code != http.StatusOK {
+			t.Fatalf("patch active=%v: %d %s", active, w.Code, w.Body.String())
+		}
+	}
+	patch(true)
+	if stored, _ = model.GetUserByID(userID); stored.Status != model.UserStatusNotJoined {
+		t.Fatalf("reactivated status = %d", stored.Status)
+	}
+	patch(false)
+	binding, _ := model.GetMemberBindingByMidAndFrom(userID, model.MemberBindingSourceSCIM)
+	if stored, _ = model.GetUserByID(userID); stored.Status != model.UserStatusDisabled ||
+		binding == nil || binding.Status != model.MemberBindingStatusDisabled || binding.BindValue != "okta-1" {
+		t.Fatalf("deactivated: status = %d binding = %+v", stored.Status, binding)
+	}
+
+	var list scim.ListResponse
+	filter := url.QueryEscape(`externalId eq "okta-1"`)
+	if w := scimRequest(engine, token, http.MethodGet, "/scim/v2/Users?filter="+filter, "", &list); w.Code != http.StatusOK || list.TotalResults != 1 {
+		t.Fatalf("filter by externalId: %d %s", w.Code, w.Body.String())
+	}
+
+	// 组映射为部门，成员映射为部门关系
+	var group scim.Group
+	w = scimRequest(engine, token, http.MethodPost, "/scim/v2/Groups",
+		`{"displayName":"Engineering","members":[{"value":"`+user.ID+`"}]}`, &group)
+	if w.Code != http.StatusCreated || len(group.Members) != 1 {
+		t.Fatalf("create group: %d %s", w.Code, w.Body.String())
+	}
+	var relations int64
+	model.DB.Model(&model.MemberDepartmentRelation{}).Where("bid = ? AND `from` = ?", binding.ID, model.MemberDepartmentRelationFromSCIM).Count(&relations)
+	if relations != 1 {
+		t.Fatalf("department relations = %d", relations)
+	}
+
+	// 其它企业的令牌看不到该成员
+	if w := scimRequest(engine, otherToken, http.MethodGet, "/scim/v2/Users/"+user.ID, "", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("other enterprise: code = %d", w.Code)
+	}
+
+	if w := scimRequest(engine, token, http.MethodDelete, "/scim/v2/Users/"+user.ID, "", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: %d %s", w.Code, w.Body.String())
+	}
+	if w := scimRequest(engine, token, http.MethodGet, "/scim/v2/Users/"+user.ID, "", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("deleted user: code = %d", w.Code)
+	}
+	model.DB.Model(&model.MemberDepartmentRelation{}).Where("bid = ?", binding.ID).Count(&relations)
+	if relations != 0 {
+		t.Fatalf("relations left after delete: %d", relations)
+	}
+}
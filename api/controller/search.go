@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/53AI/53AIHub/common"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/gin-gonic/gin"
+)
+
+// MessageSearchRequest 消息检索参数
+type MessageSearchRequest struct {
+	Keyword   string `form:"keyword" binding:"required" example:"报销流程"` // 关键词，多个关键词以空格分隔
+	Scope     string `form:"scope" example:"mine"`                      // 检索范围：mine=自己的会话，enterprise=企业内全部会话（仅管理员）
+	UserID    int64  `form:"user_id" example:"0"`                       // 按用户筛选，仅 scope=enterprise 时有效
+	AgentID   int64  `form:"agent_id" example:"0"`                      // 按智能体筛选
+	StartTime int64  `form:"start_time" example:"0"`                    // 开始时间（毫秒时间戳）
+	EndTime   int64  `form:"end_time" example:"0"`                      // 结束时间（毫秒时间戳）
+	Offset    int    `form:"offset" example:"0"`
+	Limit     int    `form:"limit" example:"10"`
+}
+
+type MessageSearchResponse struct {
+	Count   int64                        `json:"count"`
+	Results []*model.MessageSearchResult `json:"results"`
+}
+
+const (
+	SearchScopeMine       = "mine"
+	SearchScopeEnterprise = "enterprise"
+)
+
+// @Summary Search messages
+// @Description Full-text search over conversation history, ranked by relevance with highlighted snippets. Admins can search across the enterprise with scope=enterprise
+// @Tags Search
+// @Produce json
+// @Security BearerAuth
+// @Param keyword query string true "Keywords separated by spaces, all must match"
+// @Param scope query string false "Search scope: mine/enterprise" default(mine)
+// @Param user_id query int false "Filter by user (scope=enterprise only)"
+// @Param agent_id query int false "Filter by agent"
+// @Param start_time query int false "Start time (ms timestamp)"
+// @Param end_time query int false "End time (ms timestamp)"
+// @Param offset query int false "Pagination offset" default(0)
+// @Param limit query int false "Pagination limit" default(10)
+// @Success 200 {object} model.CommonResponse{data=MessageSearchResponse} "Success"
+// @Router /api/search/messages [get]
+func SearchMessages(c *gin.Context) {
+	var req MessageSearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if req.Limit > 100 {
+		req.Limit = 100
+	}
+
+	params := model.MessageSearchParams{
+		Eid:       config.GetEID(c),
+		UserID:    config.GetUserId(c),
+		AgentID:   req.AgentID,
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		Keyword:   req.Keyword,
+		Offset:    req.Offset,
+		Limit:     req.Limit,
+	}
+	if req.Scope == SearchScopeEnterprise {
+		if !common.IsAdmin(c) {
+			c.JSON(http.StatusForbidden, model.ForbiddenError.ToResponse(nil))
+			return
+		}
+		params.UserID = req.UserID
+	}
+
+	count, results, err := model.SearchMessages(params)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(MessageSearchResponse{
+		Count:   count,
+		Results: results,
+	}))
+}
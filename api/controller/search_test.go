@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/53AI/53AIHub/common/session"
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+	"github.com/gin-gonic/gin"
+)
+
+func TestSearchMessagesScope(t *testing.T) {
+	testutil.SetupDB(t, &model.Conversation{}, &model.MessageSearchDocument{})
+	docs := []*model.MessageSearchDocument{
+		{MessageID: 1, Eid: 1, UserID: 2, AgentID: 1, Query: "报销流程", Answer: "提交申请", CreatedTime: 1},
+		{MessageID: 2, Eid: 1, UserID: 3, AgentID: 1, Query: "报销标准", Answer: "见制度", CreatedTime: 2},
+		{MessageID: 3, Eid: 2, UserID: 4, AgentID: 5, Query: "报销", Answer: "", CreatedTime: 3},
+	}
+	model.DB.Create(&docs)
+
+	search := func(query string, role int64) (int, MessageSearchResponse) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/search/messages?"+query, nil)
+		c.Set(session.ENV_EID, int64(1))
+		c.Set(session.SESSION_USER_ID, int64(2))
+		c.Set(session.SESSION_USER_ROLE, role)
+		SearchMessages(c)
+		var resp struct {
+			Data MessageSearchResponse `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		return w.Code, resp.Data
+	}
+
+	code, resp := search("keyword=报销", model.RoleCommonUser)
+	if code != http.StatusOK || resp.Count != 1 || resp.Results[0].MessageID != 1 {
+		t.Fatalf("mine: %d %+v", code, resp)
+	}
+	if code, _ = search("keyword=报销&scope=enterprise", model.RoleCommonUser); code != http.StatusForbidden {
+		t.Fatalf("member enterprise scope: code = %d", code)
+	}
+	code, resp = search("keyword=报销&scope=enterprise", model.RoleAdminUser)
+	if code != http.StatusOK || resp.Count != 2 || resp.Results[0].QueryHighlight != "<em>报销</em>标准" {
+		t.Fatalf("admin enterprise scope: %d %+v", code, resp)
+	}
+	code, resp = search("keyword=报销&scope=enterprise&user_id=3", model.RoleAdminUser)
+	if code != http.StatusOK || resp.Count != 1 || resp.Results[0].MessageID != 2 {
+		t.Fatalf("admin filtered by user: %d %+v", code, resp)
+	}
+	if code, _ = search("scope=mine", model.RoleCommonUser); code != http.StatusBadRequest {
+		t.Fatalf("missing keyword: code = %d", code)
+	}
+}
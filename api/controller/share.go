@@ -1,26 +1,50 @@
 package controller
 
 import (
+	"errors"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/53AI/53AIHub/common"
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/common/utils/helper"
 	"github.com/53AI/53AIHub/config"
 	"github.com/53AI/53AIHub/model"
+	conversationService "github.com/53AI/53AIHub/service/conversation"
+	"github.com/53AI/53AIHub/service/rbac"
 	"github.com/gin-gonic/gin"
 )
 
 type CreateShareRequest struct {
-	ConversationID int64   `json:"conversation_id" binding:"required"`
-	MessageIDs     []int64 `json:"message_ids"`
-	SelectAll      bool    `json:"select_all"`
+	ConversationID  int64   `json:"conversation_id" binding:"required"`
+	MessageIDs      []int64 `json:"message_ids"`
+	SelectAll       bool    `json:"select_all"`
+	ExpireTime      int64   `json:"expire_time" example:"0"`          // 过期时间（毫秒时间戳），0 为永久有效
+	Password        string  `json:"password" example:""`              // 访问密码，为空表示无需密码
+	Visibility      string  `json:"visibility" example:"public"`      // 可见范围：public=公开，enterprise=仅企业成员
+	HideAttachments bool    `json:"hide_attachments" example:"false"` // 是否隐藏消息中的附件
 }
 
 type CreateShareResponse struct {
-	ShareID string `json:"share_id"`
+	ShareID string             `json:"share_id"`
+	Share   *model.ShareRecord `json:"share"`
 }
 
+// SharePasswordHeader 访问受密码保护的分享时携带密码的请求头
+const SharePasswordHeader = "X-Share-Password"
+
+// 窗口期内同一分享、同一 IP 输错访问密码的次数上限，达到后暂停校验密码，防止暴力猜解
+const (
+	sharePasswordShareLimit = 50
+	sharePasswordIPLimit    = 10
+	sharePasswordWindow     = 15 * time.Minute
+)
+
 // @Summary Create a share for selected messages
 // @Description Create a share record under a conversation with a set of message IDs (dedup+sorted, idempotent). Returns a UUID share_id.
+// @Description Sharing the same message set again returns the existing share when the options match; when they differ it fails with 409
+// @Description and the existing share in data, which has to be changed through PUT /api/shares/{share_id}.
 // @Tags Share
 // @Accept json
 // @Produce json
@@ -28,6 +52,9 @@ type CreateShareResponse struct {
 // @Param share body CreateShareRequest true "Share create payload"
 // @Success 200 {object} model.CommonResponse{data=CreateShareResponse} "Success"
 // @Failure 400 {object} model.CommonResponse "Param error"
+// @Failure 403 {object} model.CommonResponse "Share disabled by administrator"
+// @Failure 404 {object} model.CommonResponse "Conversation not found"
+// @Failure 409 {object} model.CommonResponse{data=CreateShareResponse} "Share exists with different options"
 // @Failure 500 {object} model.CommonResponse "DB error"
 // @Router /api/shares [post]
 // POST /api/shares
@@ -42,7 +69,26 @@ func CreateShare(c *gin.Context) {
 		return
 	}
 
+	if req.Visibility == "" {
+		req.Visibility = model.ShareVisibilityPublic
+	}
+	if !model.IsValidShareVisibility(req.Visibility) {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToNewErrorResponse("invalid visibility"))
+		return
+	}
+	if req.ExpireTime < 0 || (req.ExpireTime > 0 && req.ExpireTime <= time.Now().UnixMilli()) {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToNewErrorResponse("expire_time must be in the future"))
+		return
+	}
+
 	eid := config.GetEID(c)
+	userID := config.GetUserId(c)
+
+	// 仅允许分享自己的会话
+	if _, err := model.GetConversationByID(eid, userID, req.ConversationID); err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(err))
+		return
+	}
 
 	// 构造用于分享的消息ID集合（支持全选）
 	var idsForShare []int64
@@ -77,13 +123,31 @@ func CreateShare(c *gin.Context) {
 		return
 	}
 
-	shareID, _, err := model.CreateShareRecord(eid, req.ConversationID, nkey)
+	shareID, reused, err := model.CreateShareRecord(eid, req.ConversationID, nkey)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
 		return
 	}
 
-	c.JSON(http.StatusOK, model.Success.ToResponse(&CreateShareResponse{ShareID: shareID}))
+	options := &model.ShareOptions{
+		ExpireTime:      req.ExpireTime,
+		Visibility:      req.Visibility,
+		HideAttachments: req.HideAttachments,
+	}
+	rec, err := model.ApplyShareOptions(eid, shareID, userID, options, req.Password, reused)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrShareDisabled):
+			c.JSON(http.StatusForbidden, model.ForbiddenError.ToResponse(err))
+		case errors.Is(err, model.ErrShareExists):
+			c.JSON(http.StatusConflict, model.RecordAlreadyExists.ToResponse(&CreateShareResponse{ShareID: shareID, Share: rec}))
+		default:
+			c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(&CreateShareResponse{ShareID: shareID, Share: rec}))
 }
 
 // GET /api/shares/:share_id
@@ -109,74 +173,396 @@ type GetShareResponse struct {
 
 // @Summary Get share content (public)
 // @Description Get shared conversation details and messages by share_id. Anonymous access allowed.
+// @Description Password protected shares require the password in the X-Share-Password header (code 18 when missing or wrong).
+// @Description Enterprise-only shares require a logged-in member of the same enterprise. Expired shares return code 19.
 // @Tags Share
 // @Produce json
 // @Param share_id path string true "Share ID (UUID)"
+// @Param X-Share-Password header string false "Share access password"
 // @Success 200 {object} model.CommonResponse{data=GetShareResponse} "Success"
+// @Failure 401 {object} model.CommonResponse "Login required for enterprise-only share"
+// @Failure 403 {object} model.CommonResponse "Password required or incorrect"
 // @Failure 404 {object} model.CommonResponse "Not found"
+// @Failure 410 {object} model.CommonResponse "Share expired"
+// @Failure 429 {object} model.CommonResponse "Too many wrong passwords"
 // @Failure 400 {object} model.CommonResponse "Param error"
 // @Failure 500 {object} model.CommonResponse "DB error"
 // @Router /api/shares/{share_id} [get]
 func GetShare(c *gin.Context) {
+	rec, conv, msgs, ok := loadSharedConversation(c)
+	if !ok {
+		return
+	}
+
+	if err := model.IncreaseShareViewCount(rec.ID); err != nil {
+		logger.SysErrorf("increase share view count failed: %v", err)
+	}
+
+	resp := &GetShareResponse{}
+	resp.Conversation.ID = conv.ConversationID
+	resp.Conversation.Title = conv.Title
+	resp.Conversation.CreatedTime = conv.CreatedTime
+	resp.User.Nickname = conv.User.Nickname
+	resp.User.Avatar = conv.User.Avatar
+	resp.Agent.AgentId = conv.Agent.AgentID
+	resp.Agent.Name = conv.Agent.Name
+	resp.Agent.Logo = conv.Agent.Logo
+	resp.Agent.Model = conv.Agent.Model
+	resp.Agent.Description = conv.Agent.Description
+	resp.Messages = convertToEnhancedMessages(msgs)
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(resp))
+}
+
+// loadSharedConversation 校验分享的状态、有效期、可见范围与访问密码，返回分享记录、会话与分享的消息
+// 校验失败时已写入响应，ok 为 false
+func loadSharedConversation(c *gin.Context) (rec *model.ShareRecord, conv *model.Conversation, msgs []*model.Message, ok bool) {
 	shareID := c.Param("share_id")
 	shareID = strings.TrimSpace(shareID)
 	if shareID == "" {
 		c.JSON(http.StatusBadRequest, model.ParamError.ToNewErrorResponse("empty share_id"))
-		return
+		return nil, nil, nil, false
 	}
 
 	rec, err := model.GetShareRecordByShareID(shareID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, model.NotFound.ToNewErrorResponse("分享不存在"))
-		return
+		return nil, nil, nil, false
+	}
+	if rec.IsDisabled() {
+		c.JSON(http.StatusNotFound, model.NotFound.ToNewErrorResponse("分享不存在"))
+		return nil, nil, nil, false
+	}
+	if rec.IsExpired() {
+		c.JSON(http.StatusGone, model.ShareExpired.ToResponse(nil))
+		return nil, nil, nil, false
+	}
+	if rec.Visibility == model.ShareVisibilityEnterprise {
+		user, err := model.GetLoginUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, model.UnauthorizedError.ToResponse(nil))
+			return nil, nil, nil, false
+		}
+		if user.Eid != rec.Eid {
+			c.JSON(http.StatusForbidden, model.ForbiddenError.ToResponse(nil))
+			return nil, nil, nil, false
+		}
+	}
+	password := c.GetHeader(SharePasswordHeader)
+	if rec.Password != "" && password != "" && sharePasswordThrottled(rec, c.ClientIP()) {
+		c.JSON(http.StatusTooManyRequests, model.OperateTooFast.ToResponse(nil))
+		return nil, nil, nil, false
+	}
+	if !rec.CheckPassword(password) {
+		// 未携带密码只是提示输入，不计入失败次数
+		if password != "" {
+			recordSharePasswordFailure(rec, c.ClientIP())
+		}
+		c.JSON(http.StatusForbidden, model.SharePasswordRequired.ToResponse(nil))
+		return nil, nil, nil, false
 	}
 
 	// 加载会话
-	conv, err := model.AdminGetConversationByID(rec.Eid, rec.ConversationID)
+	conv, err = model.AdminGetConversationByID(rec.Eid, rec.ConversationID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, model.NotFound.ToNewErrorResponse("分享不存在"))
-		return
+		return nil, nil, nil, false
 	}
 	// 加载用户与智能体
 	if err := conv.LoadUser(); err != nil {
 		c.JSON(http.StatusNotFound, model.NotFound.ToNewErrorResponse("分享不存在"))
-		return
+		return nil, nil, nil, false
 	}
 	if err := conv.LoadAgent(); err != nil {
 		c.JSON(http.StatusNotFound, model.NotFound.ToNewErrorResponse("分享不存在"))
-		return
+		return nil, nil, nil, false
 	}
 
 	if !conv.Agent.Enable {
 		c.JSON(http.StatusNotFound, model.NotFound.ToNewErrorResponse("分享不存在"))
+		return nil, nil, nil, false
 	}
 
 	// 解析 normalized_key 为 ids
 	ids, err := model.ParseMessageIDsToIDs(rec.MessageIDs)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
-		return
+		return nil, nil, nil, false
 	}
 
 	// 读取消息并升序排序
-	msgs, err := model.GetMessagesByIDsOrderedAsc(rec.Eid, ids)
+	msgs, err = model.GetMessagesByIDsOrderedAsc(rec.Eid, ids)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return nil, nil, nil, false
+	}
+
+	if rec.HideAttachments {
+		for _, msg := range msgs {
+			model.StripMessageAttachments(msg)
+		}
+	}
+
+	return rec, conv, msgs, true
+}
+
+// sharePasswordCounters 分享访问密码失败次数的计数器名称及上限
+func sharePasswordCounters(rec *model.ShareRecord, ip string) map[string]int64 {
+	return map[string]int64{
+		"share:password:share:" + rec.ShareID: sharePasswordShareLimit,
+		"share:password:ip:" + ip:             sharePasswordIPLimit,
+	}
+}
+
+// sharePasswordThrottled 同一分享或同一 IP 输错密码的次数是否已达上限，计数失败时不拦截
+func sharePasswordThrottled(rec *model.ShareRecord, ip string) bool {
+	for name, limit := range sharePasswordCounters(rec, ip) {
+		count, err := common.COUNTER.Get(name)
+		if err != nil {
+			logger.SysErrorf("share password counter %s failed: %v", name, err)
+			continue
+		}
+		if count >= limit {
+			return true
+		}
+	}
+	return false
+}
+
+func recordSharePasswordFailure(rec *model.ShareRecord, ip string) {
+	for name := range sharePasswordCounters(rec, ip) {
+		if _, err := common.COUNTER.Incr(name, sharePasswordWindow); err != nil {
+			logger.SysErrorf("share password counter %s failed: %v", name, err)
+		}
+	}
+}
+
+// @Summary Continue a shared conversation
+// @Description Copy the shared messages into a new conversation owned by the current user, bound to the same agent.
+// @Description The viewer must be a member of the sharing enterprise with permission to use the agent. The new conversation records source_share_id.
+// @Tags Share
+// @Produce json
+// @Security BearerAuth
+// @Param share_id path string true "Share ID (UUID)"
+// @Param X-Share-Password header string false "Share access password"
+// @Success 200 {object} model.CommonResponse{data=model.Conversation} "Success"
+// @Failure 403 {object} model.CommonResponse "No permission to use the agent"
+// @Failure 404 {object} model.CommonResponse "Not found"
+// @Failure 410 {object} model.CommonResponse "Share expired"
+// @Failure 429 {object} model.CommonResponse "Too many wrong passwords"
+// @Router /api/shares/{share_id}/fork [post]
+func ForkShare(c *gin.Context) {
+	rec, conv, msgs, ok := loadSharedConversation(c)
+	if !ok {
 		return
 	}
 
-	resp := &GetShareResponse{}
-	resp.Conversation.ID = conv.ConversationID
-	resp.Conversation.Title = conv.Title
-	resp.Conversation.CreatedTime = conv.CreatedTime
-	resp.User.Nickname = conv.User.Nickname
-	resp.User.Avatar = conv.User.Avatar
-	resp.Agent.AgentId = conv.Agent.AgentID
-	resp.Agent.Name = conv.Agent.Name
-	resp.Agent.Logo = conv.Agent.Logo
-	resp.Agent.Model = conv.Agent.Model
-	resp.Agent.Description = conv.Agent.Description
-	resp.Messages = convertToEnhancedMessages(msgs)
+	eid := config.GetEID(c)
+	if eid != rec.Eid {
+		c.JSON(http.StatusForbidden, model.ForbiddenError.ToResponse(nil))
+		return
+	}
+	user, err := model.GetUserByID(config.GetUserId(c))
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(err))
+		return
+	}
 
-	c.JSON(http.StatusOK, model.Success.ToResponse(resp))
+	// 校验查看者是否有权限使用该智能体
+	if !rbac.HasPermission(c, model.PermAgentRead) {
+		agentUserGroupIds, err := conv.Agent.GetUserGroupIds()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+			return
+		}
+		userGroupIds, err := user.GetUserGroupIds()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+			return
+		}
+		if !helper.HasIntersection(agentUserGroupIds, userGroupIds) {
+			c.JSON(http.StatusForbidden, model.AgentAuthError.ToResponse(nil))
+			return
+		}
+	}
+
+	forked, err := conversationService.ForkSharedConversation(rec, conv, user.UserID, msgs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	forked.Agent = conv.Agent
+	c.JSON(http.StatusOK, model.Success.ToResponse(forked))
+}
+
+type ShareListRequest struct {
+	Status int `form:"status" example:"0"` // 按状态筛选：1=正常，2=已停用，0 为不限
+	Offset int `form:"offset" example:"0"`
+	Limit  int `form:"limit" example:"10"`
+}
+
+type ShareListResponse struct {
+	Count  int64                        `json:"count"`
+	Shares []*model.ShareRecordListItem `json:"shares"`
+}
+
+// @Summary List my shares
+// @Description List the shares created by the current user with access options and view statistics
+// @Tags Share
+// @Produce json
+// @Security BearerAuth
+// @Param status query int false "Filter by status: 1=active, 2=disabled"
+// @Param offset query int false "Pagination offset" default(0)
+// @Param limit query int false "Pagination limit" default(10)
+// @Success 200 {object} model.CommonResponse{data=ShareListResponse} "Success"
+// @Router /api/shares/mine [get]
+func GetMyShares(c *gin.Context) {
+	listShares(c, config.GetUserId(c))
+}
+
+// @Summary List enterprise shares
+// @Description List all shares in the enterprise (admin only)
+// @Tags Share
+// @Produce json
+// @Security BearerAuth
+// @Param status query int false "Filter by status: 1=active, 2=disabled"
+// @Param offset query int false "Pagination offset" default(0)
+// @Param limit query int false "Pagination limit" default(10)
+// @Success 200 {object} model.CommonResponse{data=ShareListResponse} "Success"
+// @Router /api/shares [get]
+func GetShares(c *gin.Context) {
+	listShares(c, 0)
+}
+
+func listShares(c *gin.Context, userID int64) {
+	var req ShareListRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	count, shares, err := model.GetShareRecords(config.GetEID(c), userID, req.Status, req.Offset, req.Limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(ShareListResponse{
+		Count:  count,
+		Shares: shares,
+	}))
+}
+
+// @Summary Delete share
+// @Description Revoke a share created by the current user, the link stops working immediately
+// @Tags Share
+// @Produce json
+// @Security BearerAuth
+// @Param share_id path string true "Share ID (UUID)"
+// @Success 200 {object} model.CommonResponse "Success"
+// @Failure 404 {object} model.CommonResponse "Not found"
+// @Router /api/shares/{share_id} [delete]
+func DeleteShare(c *gin.Context) {
+	eid := config.GetEID(c)
+	rec, err := model.GetShareRecordByEidAndShareID(eid, c.Param("share_id"))
+	if err != nil || rec.UserID != config.GetUserId(c) {
+		c.JSON(http.StatusNotFound, model.NotFound.ToNewErrorResponse("分享不存在"))
+		return
+	}
+	if err := model.DeleteShareRecord(eid, rec.ShareID); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+type UpdateShareRequest struct {
+	ExpireTime int64 `json:"expire_time" example:"0"` // 过期时间（毫秒时间戳），0 为永久有效
+	// Password 访问密码：不传保留原密码，传空字符串清除密码
+	Password        *string `json:"password" example:""`
+	Visibility      string  `json:"visibility" example:"public"`      // 可见范围：public=公开，enterprise=仅企业成员
+	HideAttachments bool    `json:"hide_attachments" example:"false"` // 是否隐藏消息中的附件
+}
+
+// @Summary Update share options
+// @Description Change the expiry, password, visibility and attachment options of a share created by the current user
+// @Tags Share
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param share_id path string true "Share ID (UUID)"
+// @Param request body UpdateShareRequest true "Share options"
+// @Success 200 {object} model.CommonResponse{data=model.ShareRecord} "Success"
+// @Failure 403 {object} model.CommonResponse "Share disabled by administrator"
+// @Failure 404 {object} model.CommonResponse "Not found"
+// @Router /api/shares/{share_id} [put]
+func UpdateShare(c *gin.Context) {
+	var req UpdateShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if req.Visibility == "" {
+		req.Visibility = model.ShareVisibilityPublic
+	}
+	if !model.IsValidShareVisibility(req.Visibility) {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToNewErrorResponse("invalid visibility"))
+		return
+	}
+	if req.ExpireTime < 0 || (req.ExpireTime > 0 && req.ExpireTime <= time.Now().UnixMilli()) {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToNewErrorResponse("expire_time must be in the future"))
+		return
+	}
+
+	rec, err := model.GetShareRecordByEidAndShareID(config.GetEID(c), c.Param("share_id"))
+	if err != nil || rec.UserID != config.GetUserId(c) {
+		c.JSON(http.StatusNotFound, model.NotFound.ToNewErrorResponse("分享不存在"))
+		return
+	}
+	options := &model.ShareOptions{
+		ExpireTime:      req.ExpireTime,
+		Visibility:      req.Visibility,
+		HideAttachments: req.HideAttachments,
+	}
+	if err := model.UpdateShareOptions(rec, options, req.Password); err != nil {
+		if errors.Is(err, model.ErrShareDisabled) {
+			c.JSON(http.StatusForbidden, model.ForbiddenError.ToResponse(err))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(rec))
+}
+
+type UpdateShareStatusRequest struct {
+	Status int `json:"status" binding:"required,oneof=1 2" example:"2"` // 1=启用，2=停用
+}
+
+// @Summary Update share status
+// @Description Disable or re-enable any share in the enterprise (admin only)
+// @Tags Share
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param share_id path string true "Share ID (UUID)"
+// @Param request body UpdateShareStatusRequest true "Share status"
+// @Success 200 {object} model.CommonResponse "Success"
+// @Failure 404 {object} model.CommonResponse "Not found"
+// @Router /api/shares/{share_id}/status [put]
+func UpdateShareStatus(c *gin.Context) {
+	var req UpdateShareStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	eid := config.GetEID(c)
+	rec, err := model.GetShareRecordByEidAndShareID(eid, c.Param("share_id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, model.NotFound.ToNewErrorResponse("分享不存在"))
+		return
+	}
+	if err := model.UpdateShareRecordStatus(eid, rec.ShareID, req.Status); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
 }
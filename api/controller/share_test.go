@@ -0,0 +1,294 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/53AI/53AIHub/common"
+	"github.com/53AI/53AIHub/common/session"
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+	"github.com/gin-gonic/gin"
+)
+
+type shareFixture struct {
+	owner      *model.User
+	agent      *model.Agent
+	conv       *model.Conversation
+	messageIDs []int64
+}
+
+// setupShare 创建一个已启用智能体下包含两条消息的会话，第一条提问带有图片附件
+func setupShare(t *testing.T) *shareFixture {
+	testutil.SetupDB(t, &model.User{}, &model.UserSession{}, &model.Agent{}, &model.Conversation{},
+		&model.Message{}, &model.MessageSearchDocument{}, &model.ShareRecord{}, &model.ResourcePermission{},
+		&model.Role{}, &model.RoleAssignment{}, &model.MemberDepartmentRelation{}, &model.MemberBinding{})
+	counter := common.COUNTER
+	common.COUNTER = common.NewLocalCounter()
+	t.Cleanup(func() { common.COUNTER = counter })
+
+	f := &shareFixture{
+		owner: &model.User{Eid: 1, Username: "alice", Nickname: "Alice"},
+		agent: &model.Agent{Eid: 1, Name: "Helper", Enable: true},
+	}
+	model.DB.Create(f.owner)
+	model.DB.Create(f.agent)
+	f.conv = &model.Conversation{Eid: 1, UserID: f.owner.UserID, AgentID: f.agent.AgentID, Title: "报销"}
+	model.DB.Create(f.conv)
+	for _, content := range []string{
+		`[{"role":"user","content":"[{\"type\":\"text\",\"content\":\"看图\"},{\"type\":\"image\",\"content\":\"file_id:7\"}]"}]`,
+		`[{"role":"user","content":"继续"}]`,
+	} {
+		msg := &model.Message{Eid: 1, UserID: f.owner.UserID, AgentID: f.agent.AgentID, ConversationID: f.conv.ConversationID,
+			Message: content, Answer: "好的"}
+		model.DB.Create(msg)
+		f.messageIDs = append(f.messageIDs, msg.ID)
+	}
+	return f
+}
+
+// shareRequest 以 userID 登录身份调用分享接口，userID 为 0 时视为匿名访问
+func shareRequest(handler gin.HandlerFunc, method, shareID, body string, userID int64, header http.Header) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, "/api/shares/"+shareID, strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	for key, values := range header {
+		c.Request.Header[key] = values
+	}
+	c.Params = gin.Params{{Key: "share_id", Value: shareID}}
+	c.Set(session.ENV_EID, int64(1))
+	if userID > 0 {
+		c.Set(session.SESSION_USER_ID, userID)
+		c.Set(session.SESSION_USER_ROLE, int64(model.RoleCommonUser))
+	}
+	handler(c)
+	return w
+}
+
+func (f *shareFixture) createShare(t *testing.T, body string) string {
+	t.Helper()
+	w := shareRequest(CreateShare, http.MethodPost, "", body, f.owner.UserID, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("create share: %d %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Data CreateShareResponse `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return resp.Data.ShareID
+}
+
+func viewShare(shareID, password, ip string) int {
+	header := http.Header{}
+	if password != "" {
+		header.Set(SharePasswordHeader, password)
+	}
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/shares/"+shareID, nil)
+	c.Request.Header = header
+	c.Request.RemoteAddr = ip + ":1234"
+	c.Params = gin.Params{{Key: "share_id", Value: shareID}}
+	GetShare(c)
+	return w.Code
+}
+
+func TestSharePasswordThrottle(t *testing.T) {
+	f := setupShare(t)
+	shareID := f.createShare(t, `{"conversation_id":`+strconv.FormatInt(f.conv.ConversationID, 10)+`,"select_all":true,"password":"1234"}`)
+
+	// 未携带密码只是提示输入，不计入失败次数
+	for i := 0; i < sharePasswordIPLimit+1; i++ {
+		if code := viewShare(shareID, "", "10.0.0.1"); code != http.StatusForbidden {
+			t.Fatalf("without password: code = %d", code)
+		}
+	}
+	for i := 0; i < sharePasswordIPLimit; i++ {
+		if code := viewShare(shareID, "0000", "10.0.0.1"); code != http.StatusForbidden {
+			t.Fatalf("wrong password #%d: code = %d", i, code)
+		}
+	}
+	// 达到上限后正确的密码也不再校验
+	if code := viewShare(shareID, "1234", "10.0.0.1"); code != http.StatusTooManyRequests {
+		t.Fatalf("throttled ip: code = %d", code)
+	}
+	if code := viewShare(shareID, "1234", "10.0.0.2"); code != http.StatusOK {
+		t.Fatalf("other ip: code = %d", code)
+	}
+
+	// 同一分享的失败次数跨 IP 累计
+	for i := sharePasswordIPLimit; i < sharePasswordShareLimit; i++ {
+		viewShare(shareID, "0000", "10.1.0."+strconv.Itoa(i))
+	}
+	if code := viewShare(shareID, "1234", "10.0.0.3"); code != http.StatusTooManyRequests {
+		t.Fatalf("throttled share: code = %d", code)
+	}
+	other := f.createShare(t, `{"conversation_id":`+strconv.FormatInt(f.conv.ConversationID, 10)+`,"message_ids":[`+
+		strconv.FormatInt(f.messageIDs[0], 10)+`],"password":"1234"}`)
+	if code := viewShare(other, "1234", "10.0.0.3"); code != http.StatusOK {
+		t.Fatalf("other share: code = %d", code)
+	}
+}
+
+func TestShareOptions(t *testing.T) {
+	f := setupShare(t)
+	convID := strconv.FormatInt(f.conv.ConversationID, 10)
+	member := &model.User{Eid: 1, Username: "bob", Nickname: "Bob"}
+	outsider := &model.User{Eid: 2, Username: "carol", Nickname: "Carol"}
+	model.DB.Create(member)
+	model.DB.Create(outsider)
+	for _, user := range []*model.User{member, outsider} {
+		model.CreateUserSession(&model.UserSession{Eid: user.Eid, UserID: user.UserID, TokenHash: model.HashAccessToken("token-" + user.Username),
+			ExpiresAt: time.Now().Add(time.Hour).UnixMilli()})
+	}
+	login := func(user *model.User) http.Header {
+		return http.Header{"Authorization": {"Bearer token-" + user.Username}}
+	}
+	withPassword := func(header http.Header, password string) http.Header {
+		header = header.Clone()
+		header.Set(SharePasswordHeader, password)
+		return header
+	}
+
+	if w := shareRequest(CreateShare, http.MethodPost, "", `{"conversation_id":`+convID+`,"select_all":true,"expire_time":1}`,
+		f.owner.UserID, nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("expired on creation: code = %d", w.Code)
+	}
+	body := `{"conversation_id":` + convID + `,"select_all":true,"password":"1234","visibility":"enterprise","hide_attachments":true}`
+	shareID := f.createShare(t, body)
+
+	// 相同消息集合：选项一致时复用，不一致时需显式修改
+	if again := f.createShare(t, body); again != shareID {
+		t.Fatalf("same options should reuse the share: %s != %s", again, shareID)
+	}
+	if w := shareRequest(CreateShare, http.MethodPost, "", `{"conversation_id":`+convID+`,"select_all":true}`,
+		f.owner.UserID, nil); w.Code != http.StatusConflict {
+		t.Fatalf("different options: code = %d", w.Code)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		header http.Header
+		code   int
+	}{
+		{"anonymous", withPassword(http.Header{}, "1234"), http.StatusUnauthorized},
+		{"other enterprise", withPassword(login(outsider), "1234"), http.StatusForbidden},
+		{"missing password", login(member), http.StatusForbidden},
+		{"member", withPassword(login(member), "1234"), http.StatusOK},
+	} {
+		if w := shareRequest(GetShare, http.MethodGet, shareID, "", 0, tc.header); w.Code != tc.code {
+			t.Fatalf("%s: code = %d, want %d", tc.name, w.Code, tc.code)
+		}
+	}
+	w := shareRequest(GetShare, http.MethodGet, shareID, "", 0, withPassword(login(member), "1234"))
+	var resp struct {
+		Data struct {
+			Messages []struct {
+				Message string `json:"message"`
+			} `json:"messages"`
+		} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Data.Messages) != 2 || strings.Contains(resp.Data.Messages[0].Message, "file_id") ||
+		!strings.Contains(resp.Data.Messages[0].Message, "看图") {
+		t.Fatalf("attachments should be hidden: %+v", resp.Data.Messages)
+	}
+	rec, _ := model.GetShareRecordByShareID(shareID)
+	if rec.ViewCount != 2 || rec.LastViewedTime == 0 || rec.UserID != f.owner.UserID {
+		t.Fatalf("unexpected share record: %+v", rec.ShareOptions)
+	}
+
+	// 修改选项：不传密码保留原密码，公开后匿名可访问
+	if w := shareRequest(UpdateShare, http.MethodPut, shareID, `{"visibility":"public"}`, member.UserID, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("update by other user: code = %d", w.Code)
+	}
+	if w := shareRequest(UpdateShare, http.MethodPut, shareID, `{"visibility":"public"}`, f.owner.UserID, nil); w.Code != http.StatusOK {
+		t.Fatalf("update: code = %d", w.Code)
+	}
+	if w := shareRequest(GetShare, http.MethodGet, shareID, "", 0, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("password kept: code = %d", w.Code)
+	}
+	if w := shareRequest(UpdateShare, http.MethodPut, shareID, `{"visibility":"public","password":""}`, f.owner.UserID, nil); w.Code != http.StatusOK {
+		t.Fatalf("clear password: code = %d", w.Code)
+	}
+	if w := shareRequest(GetShare, http.MethodGet, shareID, "", 0, nil); w.Code != http.StatusOK {
+		t.Fatalf("public share: code = %d", w.Code)
+	}
+
+	model.DB.Model(&model.ShareRecord{}).Where("share_id = ?", shareID).Update("expire_time", time.Now().Add(-time.Minute).UnixMilli())
+	if w := shareRequest(GetShare, http.MethodGet, shareID, "", 0, nil); w.Code != http.StatusGone {
+		t.Fatalf("expired share: code = %d", w.Code)
+	}
+	model.DB.Model(&model.ShareRecord{}).Where("share_id = ?", shareID).Update("expire_time", 0)
+
+	// 管理员停用后链接失效，创建者也不能修改
+	if w := shareRequest(UpdateShareStatus, http.MethodPut, shareID, `{"status":2}`, 0, nil); w.Code != http.StatusOK {
+		t.Fatalf("disable: code = %d", w.Code)
+	}
+	if w := shareRequest(GetShare, http.MethodGet, shareID, "", 0, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("disabled share: code = %d", w.Code)
+	}
+	if w := shareRequest(UpdateShare, http.MethodPut, shareID, `{"visibility":"public"}`, f.owner.UserID, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("update disabled share: code = %d", w.Code)
+	}
+	if w := shareRequest(UpdateShareStatus, http.MethodPut, shareID, `{"status":1}`, 0, nil); w.Code != http.StatusOK {
+		t.Fatalf("enable: code = %d", w.Code)
+	}
+
+	// 仅创建者可撤销，撤销后链接立即失效
+	if w := shareRequest(DeleteShare, http.MethodDelete, shareID, "", member.UserID, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("delete by other user: code = %d", w.Code)
+	}
+	if w := shareRequest(DeleteShare, http.MethodDelete, shareID, "", f.owner.UserID, nil); w.Code != http.StatusOK {
+		t.Fatalf("delete: code = %d", w.Code)
+	}
+	if w := shareRequest(GetShare, http.MethodGet, shareID, "", 0, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("deleted share: code = %d", w.Code)
+	}
+}
+
+func TestForkShare(t *testing.T) {
+	f := setupShare(t)
+	model.DB.Create(&model.ResourcePermission{GroupID: 5, ResourceID: f.agent.AgentID, ResourceType: model.ResourceTypeAgent,
+		Permission: model.PermissionRead})
+	allowed := &model.User{Eid: 1, Username: "bob", Nickname: "Bob", Type: model.UserTypeRegistered, GroupId: 5}
+	denied := &model.User{Eid: 1, Username: "carol", Nickname: "Carol", Type: model.UserTypeRegistered, GroupId: 6}
+	model.DB.Create(allowed)
+	model.DB.Create(denied)
+	shareID := f.createShare(t, `{"conversation_id":`+strconv.FormatInt(f.conv.ConversationID, 10)+`,"select_all":true,"password":"1234"}`)
+	password := http.Header{SharePasswordHeader: {"1234"}}
+
+	if w := shareRequest(ForkShare, http.MethodPost, shareID, "", allowed.UserID, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("fork without password: code = %d", w.Code)
+	}
+	if w := shareRequest(ForkShare, http.MethodPost, shareID, "", denied.UserID, password); w.Code != http.StatusForbidden {
+		t.Fatalf("fork without agent permission: code = %d", w.Code)
+	}
+	// 拥有 agent:read 的自定义角色可使用全部智能体
+	reader := &model.Role{Eid: 1, Name: "智能体查看", PermissionList: []string{model.PermAgentRead}}
+	model.DB.Create(reader)
+	model.AssignRole(1, reader.ID, model.RoleSubjectUser, []int64{denied.UserID})
+	if w := shareRequest(ForkShare, http.MethodPost, shareID, "", denied.UserID, password); w.Code != http.StatusOK {
+		t.Fatalf("fork with agent:read role: %d %s", w.Code, w.Body.String())
+	}
+	w := shareRequest(ForkShare, http.MethodPost, shareID, "", allowed.UserID, password)
+	var resp struct {
+		Data model.Conversation `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp.Data.UserID != allowed.UserID || resp.Data.SourceShareID != shareID ||
+		resp.Data.AgentID != f.agent.AgentID || resp.Data.Agent == nil {
+		t.Fatalf("fork: %d %s", w.Code, w.Body.String())
+	}
+	var count int64
+	model.DB.Model(&model.Message{}).Where("conversation_id = ? AND user_id = ?", resp.Data.ConversationID, allowed.UserID).Count(&count)
+	if count != int64(len(f.messageIDs)) {
+		t.Fatalf("forked %d messages, want %d", count, len(f.messageIDs))
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"github.com/53AI/53AIHub/config"
 	"github.com/53AI/53AIHub/model"
 	"github.com/53AI/53AIHub/service"
+	"github.com/53AI/53AIHub/service/ldap"
+	"github.com/53AI/53AIHub/service/orgsync"
 	"github.com/gin-gonic/gin"
 )
 
@@ -20,7 +22,7 @@ import (
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param from path int true "Source identifier (1=WeCom)"
+// @Param from path int true "Source identifier (1=WeCom, 4=LDAP, 5=DingTalk, 6=Feishu)"
 // @Param body body service.SyncOrganizationParams true "Sync parameters"
 // @Success 200 {object} model.CommonResponse "Operation succeeded"
 // @Failure 400 {object} model.CommonResponse "Parameter error"
@@ -54,12 +56,31 @@ func SyncOrganization(c *gin.Context) {
 	}
 
 	switch from {
-	case model.DepartmentFromWecom:
+	case model.DepartmentFromLDAP:
 		go func() {
-			err := service.WeComRunSyncOrganization(enterprise, params)
+			defer common.LOCKER.Unlock(lockKey)
+			result, err := ldap.Sync(enterprise.Eid)
 			if err != nil {
-				logger.SysErrorf("sync organization from wecom failed: %v", err)
+				logger.SysErrorf("sync organization from ldap failed: %v", err)
+				return
 			}
+			logger.SysLogf("sync organization from ldap: eid=%d result=%+v", enterprise.Eid, *result)
+		}()
+	default:
+		provider, err := service.GetOrgSyncProvider(enterprise, from, params)
+		if err != nil {
+			common.LOCKER.Unlock(lockKey)
+			c.JSON(http.StatusBadRequest, model.ParamError.ToErrorResponse(err))
+			return
+		}
+		go func() {
+			defer common.LOCKER.Unlock(lockKey)
+			result, err := orgsync.Sync(enterprise.Eid, provider)
+			if err != nil {
+				logger.SysErrorf("sync organization from %d failed: %v", from, err)
+				return
+			}
+			logger.SysLogf("sync organization from %d: eid=%d result=%+v", from, enterprise.Eid, *result)
 		}()
 	}
 
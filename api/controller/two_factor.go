@@ -0,0 +1,409 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/53AI/53AIHub/common/utils"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/security"
+	"github.com/gin-gonic/gin"
+)
+
+// TwoFactorChallengeResponse 账号需要两步验证时各登录接口返回预认证令牌而不是 access_token
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool `json:"two_factor_required" example:"true"`
+	*security.Challenge
+}
+
+type TwoFactorPreAuthRequest struct {
+	PreAuthToken string `json:"pre_auth_token" binding:"required"`
+}
+
+type TwoFactorLoginRequest struct {
+	PreAuthToken string `json:"pre_auth_token" binding:"required"`
+	Method       string `json:"method" binding:"required,oneof=totp email recovery" example:"totp"`
+	Code         string `json:"code" binding:"required" example:"123456"`
+}
+
+type TwoFactorLoginResponse struct {
+	LoginResponse
+	// RecoveryCodes 登录过程中完成 TOTP 设置时返回，只展示一次
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required" example:"123456"`
+}
+
+type TwoFactorVerifyRequest struct {
+	Method string `json:"method" binding:"required,oneof=totp email recovery" example:"totp"`
+	Code   string `json:"code" binding:"required" example:"123456"`
+}
+
+type TwoFactorRecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func twoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, security.ErrInvalidPreAuthToken), errors.Is(err, security.ErrInvalidCode):
+		c.JSON(http.StatusUnauthorized, model.AuthFailed.ToErrorResponse(err))
+	case errors.Is(err, security.ErrTwoFactorMandatory):
+		c.JSON(http.StatusForbidden, model.ForbiddenError.ToErrorResponse(err))
+	case errors.Is(err, security.ErrMethodNotAllowed), errors.Is(err, security.ErrEmailRequired),
+		errors.Is(err, security.ErrSetupRequired), errors.Is(err, security.ErrAlreadyEnabled),
+		errors.Is(err, security.ErrNotEnabled):
+		c.JSON(http.StatusBadRequest, model.ParamError.ToErrorResponse(err))
+	default:
+		c.JSON(http.StatusInternalServerError, model.SystemError.ToResponse(err))
+	}
+}
+
+// startLogin 第一步认证（密码、短信验证码、单点登录）通过后调用：开启了两步验证或企业要求管理员两步验证时
+// 返回预认证令牌，审计在两步验证完成后记录；否则签发访问令牌。返回 false 表示已写入响应
+func startLogin(c *gin.Context, user *model.User) bool {
+	challenge, err := security.BeginLogin(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.SystemError.ToResponse(err))
+		return false
+	}
+	if challenge != nil {
+		c.JSON(http.StatusOK, model.Success.ToResponse(TwoFactorChallengeResponse{
+			TwoFactorRequired: true,
+			Challenge:         challenge,
+		}))
+		return false
+	}
+	if err := security.StartSession(user, utils.GetClientIP(c), c.Request.UserAgent()); err != nil {
+		c.JSON(http.StatusInternalServerError, model.SystemError.ToResponse(err))
+		return false
+	}
+	return true
+}
+
+func currentUser(c *gin.Context) (*model.User, bool) {
+	user, err := model.GetUserByID(config.GetUserId(c))
+	if err != nil || user.Eid != config.GetEID(c) {
+		c.JSON(http.StatusUnauthorized, model.UnauthorizedError.ToResponse(err))
+		return nil, false
+	}
+	return user, true
+}
+
+// @Summary 登录第二步：校验两步验证码
+// @Description 使用 /api/login 返回的 pre_auth_token 完成登录。method 为 totp、email 或 recovery（恢复码）；
+// @Description 企业强制开启但尚未设置时，可先调用 /api/login/2fa/totp/setup 再以 totp 校验，成功后同时返回恢复码
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorLoginRequest true "预认证令牌与验证码"
+// @Success 200 {object} model.CommonResponse{data=TwoFactorLoginResponse} "登录成功"
+// @Failure 400 {object} model.CommonResponse "验证方式不可用"
+// @Failure 401 {object} model.CommonResponse "令牌失效或验证码错误"
+// @Router /api/login/2fa/verify [post]
+func TwoFactorLogin(c *gin.Context) {
+	var req TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+
+	user, recoveryCodes, err := security.VerifyLogin(req.PreAuthToken, req.Method, req.Code)
+	if err != nil {
+		if errors.Is(err, security.ErrInvalidCode) {
+			createLoginAudit(c, 0, "", model.LoginMethodTwoFactor, model.LoginResultFailure, model.LoginReasonInvalidCode)
+		}
+		twoFactorError(c, err)
+		return
+	}
+
+	if err := security.StartSession(user, utils.GetClientIP(c), c.Request.UserAgent()); err != nil {
+		c.JSON(http.StatusInternalServerError, model.SystemError.ToResponse(err))
+		return
+	}
+	if err := user.UpdateStatusToJoin(); err != nil {
+		c.JSON(http.StatusInternalServerError, model.SystemError.ToResponse(err))
+		return
+	}
+	createLoginAudit(c, user.UserID, user.Username, model.LoginMethodTwoFactor, model.LoginResultSuccess, "")
+
+	c.JSON(http.StatusOK, model.Success.ToResponse(TwoFactorLoginResponse{
+		LoginResponse: LoginResponse{AccessToken: user.AccessToken, UserID: user.UserID},
+		RecoveryCodes: recoveryCodes,
+	}))
+}
+
+// @Summary 登录第二步：发送邮箱验证码
+// @Description 向账号绑定的邮箱发送登录验证码
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorPreAuthRequest true "预认证令牌"
+// @Success 200 {object} model.CommonResponse "已发送"
+// @Failure 400 {object} model.CommonResponse "邮箱验证不可用"
+// @Failure 401 {object} model.CommonResponse "令牌失效"
+// @Router /api/login/2fa/email [post]
+func SendTwoFactorLoginEmail(c *gin.Context) {
+	var req TwoFactorPreAuthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	if err := security.SendLoginEmailCode(req.PreAuthToken); err != nil {
+		twoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+// @Summary 登录第二步：设置 TOTP
+// @Description 企业强制管理员开启两步验证但尚未设置时，在登录过程中生成 TOTP 密钥
+// @Tags Auth
+// @Accept json
+// @Produce json
+// @Param request body TwoFactorPreAuthRequest true "预认证令牌"
+// @Success 200 {object} model.CommonResponse{data=security.TOTPSetup} "密钥与 otpauth 地址"
+// @Failure 400 {object} model.CommonResponse "已开启两步验证"
+// @Failure 401 {object} model.CommonResponse "令牌失效"
+// @Router /api/login/2fa/totp/setup [post]
+func SetupTwoFactorLoginTOTP(c *gin.Context) {
+	var req TwoFactorPreAuthRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	setup, err := security.SetupLoginTOTP(req.PreAuthToken)
+	if err != nil {
+		twoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(setup))
+}
+
+// @Summary 获取当前用户两步验证状态
+// @Tags User
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.CommonResponse{data=security.Status} "成功"
+// @Router /api/users/me/2fa [get]
+func GetTwoFactorStatus(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	status, err := security.GetStatus(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(status))
+}
+
+// @Summary 开始设置 TOTP
+// @Description 生成待确认的 TOTP 密钥，前端使用 otpauth_uri 生成二维码，用户扫码后调用启用接口确认
+// @Tags User
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.CommonResponse{data=security.TOTPSetup} "成功"
+// @Failure 400 {object} model.CommonResponse "已开启 TOTP"
+// @Router /api/users/me/2fa/totp/setup [post]
+func SetupTwoFactorTOTP(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	setup, err := security.SetupTOTP(user)
+	if err != nil {
+		twoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(setup))
+}
+
+// @Summary 启用 TOTP
+// @Description 输入动态码确认密钥后启用，返回的恢复码只展示一次
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body TwoFactorCodeRequest true "动态码"
+// @Success 200 {object} model.CommonResponse{data=TwoFactorRecoveryCodesResponse} "成功"
+// @Failure 401 {object} model.CommonResponse "动态码错误"
+// @Router /api/users/me/2fa/totp/enable [post]
+func EnableTwoFactorTOTP(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	codes, err := security.EnableTOTP(user, req.Code)
+	if err != nil {
+		twoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(TwoFactorRecoveryCodesResponse{RecoveryCodes: codes}))
+}
+
+// @Summary 发送两步验证邮箱验证码
+// @Description 向当前用户绑定的邮箱发送验证码，用于启用邮箱验证、重新生成恢复码或关闭两步验证
+// @Tags User
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.CommonResponse "已发送"
+// @Failure 400 {object} model.CommonResponse "未绑定邮箱"
+// @Router /api/users/me/2fa/email/send [post]
+func SendTwoFactorEmail(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if err := security.SendEmailCode(user); err != nil {
+		twoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+// @Summary 启用邮箱两步验证
+// @Description 输入邮箱验证码后启用，返回的恢复码只展示一次
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body TwoFactorCodeRequest true "邮箱验证码"
+// @Success 200 {object} model.CommonResponse{data=TwoFactorRecoveryCodesResponse} "成功"
+// @Failure 401 {object} model.CommonResponse "验证码错误"
+// @Router /api/users/me/2fa/email/enable [post]
+func EnableTwoFactorEmail(c *gin.Context) {
+	var req TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	codes, err := security.EnableEmail(user, req.Code)
+	if err != nil {
+		twoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(TwoFactorRecoveryCodesResponse{RecoveryCodes: codes}))
+}
+
+// @Summary 重新生成恢复码
+// @Description 校验当前验证方式后重新生成恢复码，旧恢复码全部失效
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body TwoFactorVerifyRequest true "验证方式与验证码"
+// @Success 200 {object} model.CommonResponse{data=TwoFactorRecoveryCodesResponse} "成功"
+// @Failure 401 {object} model.CommonResponse "验证码错误"
+// @Router /api/users/me/2fa/recovery_codes [post]
+func RegenerateTwoFactorRecoveryCodes(c *gin.Context) {
+	var req TwoFactorVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	codes, err := security.RegenerateRecoveryCodes(user, req.Method, req.Code)
+	if err != nil {
+		twoFactorError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(TwoFactorRecoveryCodesResponse{RecoveryCodes: codes}))
+}
+
+// @Summary 关闭两步验证
+// @Description 校验当前验证方式后关闭；企业强制管理员开启时不能关闭
+// @Tags User
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body TwoFactorVerifyRequest true "验证方式与验证码"
+// @Success 200 {object} model.CommonResponse "成功"
+// @Failure 401 {object} model.CommonResponse "验证码错误"
+// @Failure 403 {object} model.CommonResponse "企业要求管理员开启两步验证"
+// @Router /api/users/me/2fa [delete]
+func DisableTwoFactor(c *gin.Context) {
+	var req TwoFactorVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	if err := security.Disable(user, req.Method, req.Code); err != nil {
+		twoFactorError(c, err)
+		return
+	}
+
+	model.CreateSystemLog(&model.SystemLog{
+		Eid:      user.Eid,
+		UserID:   user.UserID,
+		Nickname: user.Nickname,
+		Module:   model.SystemLogModuleSecurity,
+		Action:   model.SystemLogActionToggle,
+		Content:  "关闭两步验证",
+		IP:       utils.GetClientIP(c),
+	})
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+// @Summary 重置成员两步验证
+// @Description 成员丢失验证设备时由管理员重置，成员下次登录时重新设置
+// @Tags User
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "用户ID"
+// @Success 200 {object} model.CommonResponse "成功"
+// @Failure 400 {object} model.CommonResponse "成员未开启两步验证"
+// @Failure 404 {object} model.CommonResponse "成员不存在"
+// @Router /api/users/{id}/2fa [delete]
+func ResetUserTwoFactor(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	eid := config.GetEID(c)
+	user, err := model.GetUserByID(id)
+	if err != nil || user.Eid != eid {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(nil))
+		return
+	}
+	if !manageableUser(c, user) {
+		return
+	}
+	if err := security.Reset(eid, user.UserID); err != nil {
+		twoFactorError(c, err)
+		return
+	}
+
+	model.CreateSystemLog(&model.SystemLog{
+		Eid:      eid,
+		UserID:   config.GetUserId(c),
+		Nickname: config.GetUserNickname(c),
+		Module:   model.SystemLogModuleSecurity,
+		Action:   model.SystemLogActionUpdate,
+		Content:  fmt.Sprintf("重置账号【%s】的两步验证", user.Nickname),
+		IP:       utils.GetClientIP(c),
+	})
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
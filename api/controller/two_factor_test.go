@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+	"github.com/gin-gonic/gin"
+)
+
+func setupDB(t *testing.T) {
+	testutil.SetupDB(t, &model.EnterpriseConfig{}, &model.User{}, &model.UserTwoFactor{}, &model.UserSession{})
+}
+
+func loginContext() (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/sms_login", nil)
+	return c, w
+}
+
+// 企业要求管理员两步验证时，短信、单点登录等登录方式同样只返回预认证令牌
+func TestStartLoginRequiresTwoFactorForAdmins(t *testing.T) {
+	setupDB(t)
+	model.DB.Create(&model.EnterpriseConfig{EID: 1, Type: model.EnterpriseConfigTypeSecurity, Enabled: true,
+		Content: `{"two_factor_required_for_admins":true}`})
+	admin := &model.User{Username: "admin@example.com", Email: "admin@example.com", Mobile: "13800000000",
+		Password: "x", Eid: 1, Type: model.UserTypeInternal, Role: model.RoleAdminUser}
+	member := &model.User{Username: "member@example.com", Email: "member@example.com", Mobile: "13800000001",
+		Password: "x", Eid: 1, Type: model.UserTypeInternal, Role: model.RoleCommonUser}
+	for _, u := range []*model.User{admin, member} {
+		if err := u.Create(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, w := loginContext()
+	if startLogin(c, admin) {
+		t.Fatal("admin login should stop at the two-factor challenge")
+	}
+	var resp struct {
+		Data struct {
+			TwoFactorRequired bool     `json:"two_factor_required"`
+			PreAuthToken      string   `json:"pre_auth_token"`
+			Methods           []string `json:"methods"`
+			AccessToken       string   `json:"access_token"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Data.TwoFactorRequired || resp.Data.PreAuthToken == "" || len(resp.Data.Methods) == 0 || resp.Data.AccessToken != "" {
+		t.Fatalf("unexpected challenge response: %s", w.Body.String())
+	}
+	if admin.AccessToken != "" {
+		t.Fatal("no access token should be issued before two-factor verification")
+	}
+	var count int64
+	model.DB.Model(&model.UserSession{}).Where("user_id = ?", admin.UserID).Count(&count)
+	if count != 0 {
+		t.Fatalf("no session should be started, got %d", count)
+	}
+
+	c, w = loginContext()
+	if !startLogin(c, member) || member.AccessToken == "" || w.Body.Len() != 0 {
+		t.Fatalf("member login should start a session: %s", w.Body.String())
+	}
+}
@@ -10,12 +10,15 @@ import (
 	"time"
 
 	"github.com/53AI/53AIHub/common"
+	"github.com/53AI/53AIHub/common/logger"
 	"github.com/53AI/53AIHub/common/session"
 	"github.com/53AI/53AIHub/common/utils"
 	"github.com/53AI/53AIHub/common/utils/helper"
 	"github.com/53AI/53AIHub/config"
 	"github.com/53AI/53AIHub/model"
 	"github.com/53AI/53AIHub/service"
+	"github.com/53AI/53AIHub/service/ldap"
+	"github.com/53AI/53AIHub/service/security"
 	"gorm.io/gorm"
 
 	"github.com/gin-gonic/gin"
@@ -67,12 +70,16 @@ type EnterpriseUsersResponse struct {
 
 // Register User Login
 // @Summary User Login
-// @Description User Login
+// @Description User Login. When two-factor authentication applies, data is a TwoFactorChallengeResponse
+// @Description and the login continues with /api/login/2fa/verify
 // @Tags User
 // @Accept json
 // @Produce json
 // @Param user body LoginRequest true "User Login Request Data"
 // @Success 200 {object} model.CommonResponse{data=LoginResponse} "Success"
+// @Failure 401 {object} model.CommonResponse{data=security.LoginThrottleStatus} "Invalid credentials; data tells whether a captcha is required"
+// @Failure 403 {object} model.CommonResponse "Password expired, reset it before logging in"
+// @Failure 429 {object} model.CommonResponse{data=security.LoginThrottleStatus} "Account temporarily locked"
 // @Router /api/login [post]
 func Login(c *gin.Context) {
 	var loginRequest LoginRequest
@@ -86,34 +93,68 @@ func Login(c *gin.Context) {
 	password := loginRequest.Password
 	eid := config.GetEID(c)
 
-	isEmail := helper.IsValidEmail(username)
-	isMobile := helper.IsValidPhone(username)
-
-	var user model.User
-	if isEmail {
-		user, err = model.GetUserByEmail(eid, username)
-	} else if isMobile {
-		user, err = model.GetUserByMobile(eid, username)
-	} else {
+	if !checkLoginLock(c, username, model.LoginMethodPassword) {
+		return
 	}
 
+	user, err := ldapLogin(eid, username, password)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, model.UnauthorizedError.ToResponse(err))
+		reason := model.LoginReasonInvalidPassword
+		if errors.Is(err, ldap.ErrUserDisabled) {
+			reason = model.LoginReasonUserDisabled
+		}
+		loginFailed(c, 0, username, model.LoginMethodPassword, reason, err)
 		return
 	}
+	isLdapUser := user.UserID != 0
+	if !isLdapUser {
+		isEmail := helper.IsValidEmail(username)
+		isMobile := helper.IsValidPhone(username)
 
-	err = user.VerifyPassword(password)
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, model.UnauthorizedError.ToResponse(err))
-		return
+		if isEmail {
+			user, err = model.GetUserByEmail(eid, username)
+		} else if isMobile {
+			user, err = model.GetUserByMobile(eid, username)
+		} else {
+			err = gorm.ErrRecordNotFound
+		}
+
+		if err != nil {
+			loginFailed(c, 0, username, model.LoginMethodPassword, model.LoginReasonUserNotFound, err)
+			return
+		}
+
+		err = user.VerifyPassword(password)
+		if err != nil {
+			loginFailed(c, user.UserID, username, model.LoginMethodPassword, model.LoginReasonInvalidPassword, err)
+			return
+		}
 	}
 
-	err = user.RefreshAccessToken()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.SystemError.ToResponse(err))
+	if err := security.RecordLoginSuccess(eid, username); err != nil {
+		logger.SysErrorf("reset login throttle failed: %v", err)
+	}
+
+	// 目录账号的密码由 LDAP 管理，不检查本地密码有效期
+	if !isLdapUser {
+		expired, err := security.PasswordExpired(&user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+			return
+		}
+		if expired {
+			createLoginAudit(c, user.UserID, username, model.LoginMethodPassword, model.LoginResultFailure, model.LoginReasonPasswordExpired)
+			c.JSON(http.StatusForbidden, model.PasswordExpired.ToResponse(nil))
+			return
+		}
+	}
+
+	if !startLogin(c, &user) {
 		return
 	}
 
+	createLoginAudit(c, user.UserID, username, model.LoginMethodPassword, model.LoginResultSuccess, "")
+
 	err = user.UpdateStatusToJoin()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.SystemError.ToResponse(err))
@@ -137,6 +178,23 @@ func Login(c *gin.Context) {
 	c.JSON(http.StatusOK, model.Success.ToResponse(loginResponse))
 }
 
+// ldapLogin 企业启用 LDAP 时先通过目录认证；目录未启用、找不到该登录名或目录不可用时返回空成员，
+// 回退到本地密码登录。目录认证失败时返回错误
+func ldapLogin(eid int64, username string, password string) (model.User, error) {
+	user, err := ldap.Authenticate(eid, username, password)
+	switch {
+	case err == nil:
+		return *user, nil
+	case errors.Is(err, ldap.ErrNotEnabled), errors.Is(err, ldap.ErrUserNotFound):
+		return model.User{}, nil
+	case errors.Is(err, ldap.ErrInvalidCredentials), errors.Is(err, ldap.ErrUserDisabled), errors.Is(err, ldap.ErrUserNotProvisioned):
+		return model.User{}, err
+	default:
+		logger.SysErrorf("ldap login failed, fallback to local password: eid=%d err=%v", eid, err)
+		return model.User{}, nil
+	}
+}
+
 // SmsLoginRequest 手机号登录请求结构体
 type SmsLoginRequest struct {
 	Mobile     string `json:"mobile" binding:"required"`      // 手机号
@@ -150,7 +208,7 @@ type SmsLoginResponse struct {
 }
 
 // @Summary 手机号验证码登录
-// @Description 使用手机号和验证码登录
+// @Description 使用手机号和验证码登录。需要两步验证时 data 为 TwoFactorChallengeResponse，继续调用 /api/login/2fa/verify
 // @Tags User
 // @Accept json
 // @Produce json
@@ -177,6 +235,7 @@ func SmsLogin(c *gin.Context) {
 	redisKey := fmt.Sprintf("Api::CheckVerificationCode:%s", req.Mobile)
 	code, err := common.RedisGet(redisKey)
 	if err != nil || code != req.VerifyCode {
+		createLoginAudit(c, 0, req.Mobile, model.LoginMethodSms, model.LoginResultFailure, model.LoginReasonInvalidCode)
 		c.JSON(http.StatusUnauthorized, model.UnauthorizedError.ToNewErrorResponse(model.InvalidVerificationCode))
 		return
 	}
@@ -184,14 +243,16 @@ func SmsLogin(c *gin.Context) {
 	eid := config.GetEID(c)
 	existingUser, err := model.GetUserByMobile(eid, req.Mobile)
 	if err != nil {
+		createLoginAudit(c, 0, req.Mobile, model.LoginMethodSms, model.LoginResultFailure, model.LoginReasonUserNotFound)
 		c.JSON(http.StatusUnauthorized, model.UnauthorizedError.ToResponse(err))
 		return
 	}
 
-	if err := existingUser.RefreshAccessToken(); err != nil {
-		c.JSON(http.StatusInternalServerError, model.SystemError.ToResponse(err))
+	// 短信验证码只证明持有手机号，需要两步验证时同样先返回预认证令牌
+	if !startLogin(c, &existingUser) {
 		return
 	}
+	createLoginAudit(c, existingUser.UserID, req.Mobile, model.LoginMethodSms, model.LoginResultSuccess, "")
 
 	c.JSON(http.StatusOK, model.Success.ToResponse(&SmsLoginResponse{
 		LoginResponse: LoginResponse{
@@ -290,6 +351,10 @@ func PasswordRegister(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, model.ParamError.ToErrorResponse(err))
 		return
 	}
+	if err := security.ValidatePassword(eid, userRequest.Password); err != nil {
+		passwordPolicyError(c, err)
+		return
+	}
 
 	var theUser model.User
 	if err = model.DB.Where("eid = ?", eid).First(&theUser).Error; err != nil && err.Error() == "record not found" {
@@ -303,6 +368,11 @@ func PasswordRegister(c *gin.Context) {
 		return
 	}
 
+	if err := security.StartSession(&user, utils.GetClientIP(c), c.Request.UserAgent()); err != nil {
+		c.JSON(http.StatusInternalServerError, model.SystemError.ToResponse(err))
+		return
+	}
+
 	c.JSON(http.StatusOK, model.Success.ToResponse(LoginResponse{
 		AccessToken: user.AccessToken,
 		UserID:      user.UserID,
@@ -343,6 +413,10 @@ func EnterpriseAddUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, model.ParamError.ToErrorResponse(err))
 		return
 	}
+	if err := security.ValidatePassword(user.Eid, userRequest.Password); err != nil {
+		passwordPolicyError(c, err)
+		return
+	}
 
 	err = user.Create()
 	if err != nil {
@@ -474,6 +548,15 @@ func DeleteEnterpriseUser(c *gin.Context) {
 			c.JSON(http.StatusNotFound, model.NotFound.ToResponse(err))
 			return
 		}
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	if user.Eid != eid {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(nil))
+		return
+	}
+	if !manageableUser(c, user) {
+		return
 	}
 	err = model.DeleteUser(eid, int64(user_id))
 	if err != nil {
@@ -528,8 +611,11 @@ func UpdateEnterpriseUser(c *gin.Context) {
 	}
 
 	user, err := model.GetUserByID(int64(user_id))
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.NotFound.ToResponse(err))
+	if err != nil || user.Eid != config.GetEID(c) {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(err))
+		return
+	}
+	if !manageableUser(c, user) {
 		return
 	}
 
@@ -647,15 +733,28 @@ func UpdateUserPassword(c *gin.Context) {
 
 	eid := config.GetEID(c)
 
-	err := model.UpdateUserPassword(eid, userID, req.NewPassword)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+	user, err := model.GetUserByID(userID)
+	if err != nil || user.Eid != eid {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(err))
+		return
+	}
+	if err := security.ChangePassword(user, req.NewPassword); err != nil {
+		passwordPolicyError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
 }
 
+// passwordPolicyError 新密码不满足企业密码策略时返回 400
+func passwordPolicyError(c *gin.Context, err error) {
+	if errors.Is(err, security.ErrWeakPassword) || errors.Is(err, security.ErrPasswordReused) {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToErrorResponse(err))
+		return
+	}
+	c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+}
+
 type UpdateCurrentUserRequest struct {
 	Nickname string `json:"nickname" example:"new nickname"`
 	Avatar   string `json:"avatar" example:"http://example.com/avatar.jpg"`
@@ -1013,16 +1112,39 @@ func BatchAddInternalUsers(c *gin.Context) {
 		return
 	}
 
-	nicknames := make([]string, len(batchRequest.Users))
-	users := make([]service.InternalUserInfo, len(batchRequest.Users))
-	for i, user := range batchRequest.Users {
-		users[i] = service.InternalUserInfo{
+	securityConfig, err := security.LoadConfig(eid)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+
+	// 不满足企业密码策略的成员直接记为失败，其余成员照常添加
+	weakPasswords := make([]service.BatchAddUserResult, 0)
+	nicknames := make([]string, 0, len(batchRequest.Users))
+	users := make([]service.InternalUserInfo, 0, len(batchRequest.Users))
+	for _, user := range batchRequest.Users {
+		if err := securityConfig.PasswordPolicy.Validate(user.Password); err != nil {
+			weakPasswords = append(weakPasswords, service.BatchAddUserResult{
+				Username: user.Username,
+				Message:  err.Error(),
+			})
+			continue
+		}
+		users = append(users, service.InternalUserInfo{
 			Username: user.Username,
 			Nickname: user.Nickname,
 			Dids:     user.Dids,
 			Password: user.Password,
-		}
-		nicknames[i] = user.Nickname
+		})
+		nicknames = append(nicknames, user.Nickname)
+	}
+
+	dids := make([]int64, 0)
+	for _, user := range users {
+		dids = append(dids, user.Dids...)
+	}
+	if !grantableDepartments(c, dids) {
+		return
 	}
 
 	userService := service.UserService{}
@@ -1031,6 +1153,7 @@ func BatchAddInternalUsers(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
 		return
 	}
+	result.Failed = append(weakPasswords, result.Failed...)
 
 	if len(result.Failed) > 0 && len(result.Success) == 0 {
 		c.JSON(http.StatusOK, model.ParamError.ToResponse(BatchAddInternalUserResponse{
@@ -1096,11 +1219,16 @@ func RegisterUserToInternal(c *gin.Context) {
 	}
 
 	mappings := make([]service.UserDepartmentMapping, len(req.UserDepartments))
+	dids := make([]int64, 0)
 	for i, mapping := range req.UserDepartments {
 		mappings[i] = service.UserDepartmentMapping{
 			UserID: mapping.UserID,
 			DIDs:   mapping.DIDs,
 		}
+		dids = append(dids, mapping.DIDs...)
+	}
+	if !grantableDepartments(c, dids) {
+		return
 	}
 
 	userService := service.UserService{}
@@ -1247,6 +1375,9 @@ func UpdateUserStatus(c *gin.Context) {
 		c.JSON(http.StatusForbidden, model.NotFound.ToResponse(nil))
 		return
 	}
+	if !manageableUser(c, user) {
+		return
+	}
 
 	// Update user status
 	user.Status = req.Status
@@ -1322,6 +1453,9 @@ func UpdateInternalUser(c *gin.Context) {
 		c.JSON(http.StatusForbidden, model.ForbiddenError.ToResponse(nil))
 		return
 	}
+	if !manageableUser(c, user) {
+		return
+	}
 
 	// Parse request body
 	var req UpdateInternalUserRequest
@@ -1413,6 +1547,16 @@ func UpdateInternalUser(c *gin.Context) {
 		for _, relation := range existingRelations {
 			existingDeptMap[relation.DID] = true
 		}
+		var deptToAdd []int64
+		for _, deptID := range req.Department {
+			if !existingDeptMap[deptID] && deptID > 0 {
+				deptToAdd = append(deptToAdd, deptID)
+			}
+		}
+		if !grantableDepartments(c, deptToAdd) {
+			tx.Rollback()
+			return
+		}
 
 		// Identify departments to remove
 		var deptToDelete []int64
@@ -1536,8 +1680,8 @@ func Logout(c *gin.Context) {
 		return
 	}
 
-	// 使令牌失效
-	err = user.InvalidateAccessToken()
+	// 只结束当前会话，其他设备上的登录不受影响
+	err = model.DeleteUserSessionByToken(token)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, model.SystemError.ToResponse(err))
 		return
@@ -1644,20 +1788,9 @@ func ResetPassword(c *gin.Context) {
 		}
 	}
 
-	// 加密新密码，并更新用户
-	salt := helper.RandomString(6)
-	hashedPassword, err := helper.PasswordHash(req.NewPassword, salt)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.InvalidVerificationCodeError.ToResponse(err))
-		return
-	}
-
-	// 更新用户密码
-	user.Password = hashedPassword
-	user.Salt = salt
-	err = model.DB.Save(&user).Error
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+	// 按企业密码策略检查并更新用户密码
+	if err := security.ChangePassword(user, req.NewPassword); err != nil {
+		passwordPolicyError(c, err)
 		return
 	}
 
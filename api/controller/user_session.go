@@ -0,0 +1,199 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/53AI/53AIHub/common/session"
+	"github.com/53AI/53AIHub/common/utils"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/rbac"
+	"github.com/gin-gonic/gin"
+)
+
+type UserSessionResponse struct {
+	*model.UserSession
+	Current bool `json:"current"` // 是否为发起本次请求的会话
+}
+
+func requestAccessToken(c *gin.Context) string {
+	return strings.Replace(c.Request.Header.Get("Authorization"), "Bearer ", "", 1)
+}
+
+func sessionResponses(c *gin.Context, sessions []*model.UserSession) []UserSessionResponse {
+	currentHash := model.HashAccessToken(requestAccessToken(c))
+	list := make([]UserSessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		list = append(list, UserSessionResponse{UserSession: s, Current: s.TokenHash == currentHash})
+	}
+	return list
+}
+
+// @Summary 获取当前用户的登录会话
+// @Description 列出当前用户在各设备上未过期的登录，最近活跃的在前
+// @Tags User
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.CommonResponse{data=[]UserSessionResponse} "成功"
+// @Router /api/users/me/sessions [get]
+func GetMySessions(c *gin.Context) {
+	sessions, err := model.GetUserSessions(config.GetEID(c), config.GetUserId(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(sessionResponses(c, sessions)))
+}
+
+// @Summary 撤销当前用户的登录会话
+// @Description 撤销后该设备上的访问令牌立即失效
+// @Tags User
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "会话ID"
+// @Success 200 {object} model.CommonResponse "成功"
+// @Failure 404 {object} model.CommonResponse "会话不存在"
+// @Router /api/users/me/sessions/{id} [delete]
+func RevokeMySession(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return
+	}
+	found, err := model.DeleteUserSession(config.GetEID(c), config.GetUserId(c), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(nil))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+// @Summary 退出其他设备
+// @Description 撤销除当前会话以外的全部登录会话
+// @Tags User
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} model.CommonResponse "成功"
+// @Router /api/users/me/sessions [delete]
+func RevokeMyOtherSessions(c *gin.Context) {
+	eid := config.GetEID(c)
+	userID := config.GetUserId(c)
+	var currentID int64
+	if current := model.GetUserSessionByToken(requestAccessToken(c)); current != nil {
+		currentID = current.ID
+	}
+	if err := model.DeleteUserSessions(eid, userID, currentID); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
+
+// enterpriseUser 管理员操作的目标成员，不能操作角色高于自己的成员
+func enterpriseUser(c *gin.Context) (*model.User, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, model.ParamError.ToResponse(err))
+		return nil, false
+	}
+	user, err := model.GetUserByID(id)
+	if err != nil || user.Eid != config.GetEID(c) {
+		c.JSON(http.StatusNotFound, model.NotFound.ToResponse(nil))
+		return nil, false
+	}
+	if role, _ := c.Get(session.SESSION_USER_ROLE); role == nil || user.Role > role.(int64) {
+		c.JSON(http.StatusForbidden, model.ForbiddenError.ToResponse(nil))
+		return nil, false
+	}
+	if !manageableUser(c, user) {
+		return nil, false
+	}
+	return user, true
+}
+
+// manageableUser 管理员及以上的账号只有拥有全部权限的成员才能操作，否则返回 403
+func manageableUser(c *gin.Context, user *model.User) bool {
+	if !rbac.CanManageUser(c, user) {
+		c.JSON(http.StatusForbidden, model.ForbiddenError.ToResponse(nil))
+		return false
+	}
+	return true
+}
+
+// grantableDepartments 部门上授予的自定义角色随成员关系生效，操作者需拥有这些角色的全部权限点，否则返回 403
+func grantableDepartments(c *gin.Context, departmentIDs []int64) bool {
+	operator, err := rbac.ContextPermissions(c)
+	if err == nil {
+		err = rbac.CheckDepartmentGrant(config.GetEID(c), departmentIDs, operator)
+	}
+	if err != nil {
+		if errors.Is(err, rbac.ErrPermissionEscalation) {
+			c.JSON(http.StatusForbidden, model.ForbiddenError.ToErrorResponse(err))
+		} else {
+			c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		}
+		return false
+	}
+	return true
+}
+
+// @Summary 获取成员的登录会话
+// @Tags User
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "用户ID"
+// @Success 200 {object} model.CommonResponse{data=[]UserSessionResponse} "成功"
+// @Failure 404 {object} model.CommonResponse "成员不存在"
+// @Router /api/users/{id}/sessions [get]
+func GetUserSessions(c *gin.Context) {
+	user, ok := enterpriseUser(c)
+	if !ok {
+		return
+	}
+	sessions, err := model.GetUserSessions(user.Eid, user.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+	c.JSON(http.StatusOK, model.Success.ToResponse(sessionResponses(c, sessions)))
+}
+
+// @Summary 强制成员下线
+// @Description 撤销成员在所有设备上的登录会话
+// @Tags User
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "用户ID"
+// @Success 200 {object} model.CommonResponse "成功"
+// @Failure 403 {object} model.CommonResponse "不能操作角色更高的成员"
+// @Failure 404 {object} model.CommonResponse "成员不存在"
+// @Router /api/users/{id}/sessions [delete]
+func ForceLogoutUser(c *gin.Context) {
+	user, ok := enterpriseUser(c)
+	if !ok {
+		return
+	}
+	if err := user.InvalidateAccessToken(); err != nil {
+		c.JSON(http.StatusInternalServerError, model.DBError.ToResponse(err))
+		return
+	}
+
+	model.CreateSystemLog(&model.SystemLog{
+		Eid:      user.Eid,
+		UserID:   config.GetUserId(c),
+		Nickname: config.GetUserNickname(c),
+		Module:   model.SystemLogModuleSecurity,
+		Action:   model.SystemLogActionLoginOut,
+		Content:  fmt.Sprintf("强制账号【%s】下线", user.Nickname),
+		IP:       utils.GetClientIP(c),
+	})
+	c.JSON(http.StatusOK, model.Success.ToResponse(nil))
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/53AI/53AIHub/common/session"
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+	"github.com/gin-gonic/gin"
+)
+
+func setupPasswordPolicy(t *testing.T) {
+	db := testutil.SetupDB(t, &model.EnterpriseConfig{}, &model.User{}, &model.MemberBinding{},
+		&model.Department{}, &model.MemberDepartmentRelation{}, &model.SystemLog{}, &model.Role{}, &model.RoleAssignment{})
+	db.Create(&model.EnterpriseConfig{EID: 1, Type: model.EnterpriseConfigTypeSecurity, Enabled: true,
+		Content: `{"password_policy":{"min_length":10,"require_digit":true}}`})
+}
+
+func adminRequest(method string, path string, body string) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(method, path, strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Set(session.ENV_EID, int64(1))
+	c.Set(session.SESSION_USER_ID, int64(1))
+	return c, w
+}
+
+// 管理员设置的密码同样需要满足企业密码策略
+func TestEnterpriseAddUserValidatesPassword(t *testing.T) {
+	setupPasswordPolicy(t)
+
+	c, w := adminRequest(http.MethodPost, "/api/users", `{"username":"alice","nickname":"Alice","password":"weakpassword"}`)
+	EnterpriseAddUser(c)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("weak password should be rejected: %d %s", w.Code, w.Body.String())
+	}
+
+	c, w = adminRequest(http.MethodPost, "/api/users", `{"username":"alice","nickname":"Alice","password":"strongpass1"}`)
+	EnterpriseAddUser(c)
+	if w.Code != http.StatusOK {
+		t.Fatalf("strong password should be accepted: %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestBatchAddInternalUsersValidatesPasswords(t *testing.T) {
+	setupPasswordPolicy(t)
+
+	c, w := adminRequest(http.MethodPost, "/api/users/internal/batch", `{"users":[
+		{"username":"alice@example.com","nickname":"Alice","password":"weakpassword"},
+		{"username":"bob@example.com","nickname":"Bob","password":"strongpass1"}]}`)
+	BatchAddInternalUsers(c)
+	var resp struct {
+		Data BatchAddInternalUserResponse `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data.Failed) != 1 || resp.Data.Failed[0].Username != "alice@example.com" ||
+		len(resp.Data.Success) != 1 || resp.Data.Success[0].Username != "bob@example.com" {
+		t.Fatalf("unexpected result: %s", w.Body.String())
+	}
+	var count int64
+	model.DB.Model(&model.User{}).Where("username = ?", "alice@example.com").Count(&count)
+	if count != 0 {
+		t.Fatal("member with a weak password should not be created")
+	}
+}
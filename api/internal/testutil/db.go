@@ -0,0 +1,27 @@
+// Package testutil 提供各包测试共用的测试夹具，仅供 _test.go 文件引用
+package testutil
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/53AI/53AIHub/common"
+	"github.com/53AI/53AIHub/model"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// SetupDB 在测试临时目录中创建 SQLite 数据库并迁移 models，替换全局 model.DB，Redis 视为未启用
+func SetupDB(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+	common.RedisEnabled = false
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "hub.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	model.DB = db
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
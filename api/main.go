@@ -30,6 +30,9 @@ func main() {
 	if err := model.InitializeSystem(); err != nil {
 		logger.FatalLog("Failed to initialize system: " + err.Error())
 	}
+	if err := model.InitializeAgentTemplates(); err != nil {
+		logger.SysErrorf("Failed to initialize agent templates: %s", err.Error())
+	}
 
 	tasks.Start()
 
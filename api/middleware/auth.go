@@ -8,6 +8,7 @@ import (
 	"github.com/53AI/53AIHub/common/session"
 	"github.com/53AI/53AIHub/common/utils/jwt"
 	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/rbac"
 	"github.com/gin-gonic/gin"
 )
 
@@ -44,6 +45,25 @@ func UserTokenAuth(role int64) func(c *gin.Context) {
 	}
 }
 
+// PermissionAuth 登录且拥有 permission 权限点才能访问。权限来自 User.Role 对应的内置角色，
+// 以及授予成员本人或其部门的自定义角色，见 rbac.UserPermissions
+func PermissionAuth(permission string) func(c *gin.Context) {
+	auth := UserTokenAuth(model.RoleGuestUser)
+	return func(c *gin.Context) {
+		// 分组已经通过 UserTokenAuth 登录时不再重复校验令牌
+		if _, ok := c.Get(session.SESSION_USER_ID); !ok {
+			auth(c)
+			if c.IsAborted() {
+				return
+			}
+		}
+		if !rbac.HasPermission(c, permission) {
+			c.JSON(http.StatusForbidden, model.ForbiddenError.ToResponse(nil))
+			c.Abort()
+		}
+	}
+}
+
 func HandleTokenAuth(token string, role int64) (user *model.User, err error) {
 	user_id, _, err := jwt.UserParseJWT(token)
 	if err != nil {
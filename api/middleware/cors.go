@@ -1,15 +1,36 @@
 package middleware
 
 import (
+	"strings"
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
+// EmbedPathPrefix 嵌入组件的公开接口，跨域按嵌入配置的域名白名单处理，见 EmbedAuth
+const EmbedPathPrefix = "/api/embed/"
+
 func CORS() gin.HandlerFunc {
 	config := cors.DefaultConfig()
 	config.AllowAllOrigins = true
 	config.AllowCredentials = true
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"}
 	config.AllowHeaders = []string{"*"}
-	return cors.New(config)
+	handler := cors.New(config)
+	return func(c *gin.Context) {
+		if strings.HasPrefix(c.Request.URL.Path, EmbedPathPrefix) {
+			return
+		}
+		handler(c)
+	}
+}
+
+// AllowOrigin 允许指定来源跨域访问，用于按白名单放行的接口
+func AllowOrigin(c *gin.Context, origin string) {
+	header := c.Writer.Header()
+	header.Set("Access-Control-Allow-Origin", origin)
+	header.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+	header.Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+	header.Set("Access-Control-Max-Age", "600")
+	header.Add("Vary", "Origin")
 }
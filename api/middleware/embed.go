@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/53AI/53AIHub/common/session"
+	"github.com/53AI/53AIHub/common/utils"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/embed"
+	"github.com/gin-gonic/gin"
+)
+
+// EmbedAuth 校验嵌入令牌与请求来源，来源需在嵌入配置的域名白名单中；预检请求在此直接返回
+func EmbedAuth() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		agentEmbed, err := model.GetAgentEmbedByToken(c.Param("token"))
+		if err != nil || !agentEmbed.Enabled {
+			c.JSON(http.StatusNotFound, model.NotFound.ToResponse(nil))
+			c.Abort()
+			return
+		}
+		origin := embed.RequestOrigin(c.GetHeader("Origin"), c.GetHeader("Referer"))
+		if !embed.OriginAllowed(agentEmbed.Domains, origin) {
+			c.JSON(http.StatusForbidden, model.ForbiddenError.ToResponse(errors.New("origin not allowed")))
+			c.Abort()
+			return
+		}
+		AllowOrigin(c, origin)
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		if err := embed.CheckRate(agentEmbed, utils.GetClientIP(c)); err != nil {
+			c.JSON(http.StatusTooManyRequests, model.OperateTooFast.ToResponse(err))
+			c.Abort()
+			return
+		}
+		c.Set(session.SESSION_EMBED, agentEmbed)
+		c.Set(session.ENV_EID, agentEmbed.Eid)
+	}
+}
+
+// EmbedSessionAuth 校验访客会话令牌，需在 EmbedAuth 之后使用
+func EmbedSessionAuth() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		agentEmbed := c.MustGet(session.SESSION_EMBED).(*model.AgentEmbed)
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		guestSession, err := model.GetEmbedSession(agentEmbed.ID, token)
+		if err != nil {
+			if errors.Is(err, model.ErrEmbedSessionExpired) {
+				c.JSON(http.StatusUnauthorized, model.TokenExpiredError.ToResponse(nil))
+			} else {
+				c.JSON(http.StatusUnauthorized, model.UnauthorizedError.ToResponse(nil))
+			}
+			c.Abort()
+			return
+		}
+		c.Set(session.SESSION_USER_ID, agentEmbed.GuestUserID)
+		c.Set(session.SESSION_USER_ROLE, int64(model.RoleGuestUser))
+		c.Set(session.SESSION_USER_NICKNAME, "访客")
+		c.Set(session.SESSION_EMBED_SESSION, guestSession)
+	}
+}
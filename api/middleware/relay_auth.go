@@ -15,6 +15,9 @@ import (
 	"github.com/53AI/53AIHub/common/utils/helper"
 	"github.com/53AI/53AIHub/common/utils/jwt"
 	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/agentversion"
+	"github.com/53AI/53AIHub/service/experiment"
+	"github.com/53AI/53AIHub/service/rbac"
 	"github.com/gin-gonic/gin"
 )
 
@@ -39,8 +42,9 @@ func RelayTokenAuth() func(c *gin.Context) {
 			return
 		}
 
+		// 会话被撤销或已过期时 ValidateAccessToken 返回 nil
 		user := model.ValidateAccessToken(token)
-		if user.UserID != user_id {
+		if user == nil || user.UserID != user_id {
 			c.JSON(http.StatusUnauthorized, model.UnauthorizedError.ToOpenAIErrorRespone(nil))
 			c.Abort()
 			return
@@ -98,7 +102,13 @@ func RelayTokenAuth() func(c *gin.Context) {
 			}
 
 			if strings.HasPrefix(modelStr, "agent-") {
-				agentIDStr := strings.TrimPrefix(modelStr, "agent-")
+				// agent-<id>@draft 试用未发布的草稿，仅限有智能体编辑权限的成员
+				agentIDStr, ref, _ := strings.Cut(strings.TrimPrefix(modelStr, "agent-"), "@")
+				if ref != "" && ref != agentversion.RefDraft {
+					c.JSON(http.StatusBadRequest, model.ParamError.ToOpenAIErrorRespone(errors.New("AgentId Error")))
+					c.Abort()
+					return
+				}
 				agentID, err := strconv.ParseInt(agentIDStr, 10, 64)
 				if err != nil {
 					c.JSON(http.StatusBadRequest, model.ParamError.ToOpenAIErrorRespone(errors.New("AgentId Error")))
@@ -131,6 +141,28 @@ func RelayTokenAuth() func(c *gin.Context) {
 					logger.SysLogf("Admin user access agent: %d", agent.AgentID)
 				}
 
+				if ref == agentversion.RefDraft {
+					if !rbac.HasPermission(c, model.PermAgentWrite) {
+						c.JSON(http.StatusForbidden, model.AgentAuthError.ToOpenAIErrorRespone(nil))
+						c.Abort()
+						return
+					}
+					agent, err = agentversion.DraftAgent(agent)
+					if err != nil {
+						c.JSON(http.StatusNotFound, model.NotFound.ToOpenAIErrorRespone(err))
+						c.Abort()
+						return
+					}
+				} else {
+					// 运行中的 A/B 实验按用户分流到不同方案
+					agent, err = experiment.ApplyExperiment(agent, user_id)
+					if err != nil {
+						c.JSON(http.StatusInternalServerError, model.DBError.ToOpenAIErrorRespone(err))
+						c.Abort()
+						return
+					}
+				}
+
 				c.Set(session.SESSION_AGENT_ID, agentID)
 				c.Set(session.SESSION_AGENT, agent)
 				logger.SysLogf("Agent ID: %d", agent.AgentID)
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/53AI/53AIHub/common/utils/jwt"
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRelayTokenAuthRevokedSession(t *testing.T) {
+	testutil.SetupDB(t, &model.User{}, &model.UserSession{})
+	user := &model.User{Eid: 1, Username: "alice", Nickname: "Alice"}
+	model.DB.Create(user)
+	token, err := jwt.UserGenerateJWT(user.UserID, user.Eid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	model.CreateUserSession(&model.UserSession{Eid: user.Eid, UserID: user.UserID, TokenHash: model.HashAccessToken(token),
+		ExpiresAt: time.Now().Add(time.Hour).UnixMilli()})
+
+	engine := gin.New()
+	engine.POST("/v1/chat/completions", RelayTokenAuth(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	relay := func() int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{"messages":[]}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		engine.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := relay(); code != http.StatusOK {
+		t.Fatalf("active session: code = %d", code)
+	}
+	// 令牌本身仍未过期，但会话已被撤销
+	if err := model.DeleteUserSessionByToken(token); err != nil {
+		t.Fatal(err)
+	}
+	if code := relay(); code != http.StatusUnauthorized {
+		t.Fatalf("revoked session: code = %d", code)
+	}
+}
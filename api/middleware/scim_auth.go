@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/common/session"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/scim"
+	"github.com/gin-gonic/gin"
+)
+
+// ScimTokenAuth 校验企业 SCIM 令牌，通过后在上下文中设置企业ID
+func ScimTokenAuth() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+		token = strings.TrimSpace(strings.Replace(token, "Bearer ", "", 1))
+		record, err := model.GetScimTokenByToken(token)
+		if err != nil {
+			c.Header("Content-Type", scim.ContentType)
+			c.JSON(http.StatusUnauthorized, scim.NewError(http.StatusUnauthorized, "", "invalid or missing SCIM bearer token"))
+			c.Abort()
+			return
+		}
+		if err := model.TouchScimToken(record.ID); err != nil {
+			logger.SysErrorf("touch scim token failed: %v", err)
+		}
+
+		c.Set(session.ENV_EID, record.Eid)
+		c.Next()
+	}
+}
@@ -30,12 +30,20 @@ type Agent struct {
 	Enable               bool    `json:"enable" gorm:"default:false;comment:enable status"`
 	ConversationCount    int64   `json:"conversation_count" gorm:"-"`
 	AgentType            int     `json:"agent_type" gorm:"default:0"`
+	// Version 当前对用户生效的版本号，0 表示尚未纳入版本管理
+	Version int `json:"version" gorm:"not null;default:0"`
+	// HasDraft 是否有未发布的草稿
+	HasDraft bool `json:"has_draft" gorm:"-"`
+	// ExperimentID、VariantID 运行中的实验为本次对话分配的方案，仅在对话请求中设置
+	ExperimentID int64 `json:"-" gorm:"-"`
+	VariantID    int64 `json:"-" gorm:"-"`
 	BaseModel
 }
 
 const (
-	AgentTypeApp      = 0
-	AgentTypeWorkflow = 1
+	AgentTypeApp          = 0
+	AgentTypeWorkflow     = 1
+	AgentTypeOrchestrator = 2 // 编排智能体，根据问题调用子智能体
 )
 
 func (agent *Agent) Create() error {
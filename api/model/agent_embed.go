@@ -0,0 +1,255 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAgentEmbedNotFound  = errors.New("agent embed not found")
+	ErrEmbedSessionExpired = errors.New("embed session expired")
+)
+
+const (
+	// EmbedDefaultRateLimit 每个 IP 每分钟默认最多请求次数
+	EmbedDefaultRateLimit = 10
+	// EmbedDefaultDailyLimit 每个 IP 每天默认最多提问次数
+	EmbedDefaultDailyLimit = 100
+	// EmbedDefaultSessionTokenLimit 每个访客会话默认最多消耗的 token 数
+	EmbedDefaultSessionTokenLimit = 50000
+	// EmbedDefaultDailyTokenLimit 每个 IP 每天默认最多消耗的 token 数
+	EmbedDefaultDailyTokenLimit = 200000
+	// EmbedSessionTTL 访客会话有效期
+	EmbedSessionTTL = 24 * time.Hour
+)
+
+// AgentEmbed 智能体的网页嵌入配置，持有嵌入令牌的页面可以匿名使用该智能体
+type AgentEmbed struct {
+	ID      int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid     int64  `json:"eid" gorm:"not null;index"`
+	AgentID int64  `json:"agent_id" gorm:"not null;index"`
+	Name    string `json:"name" gorm:"type:varchar(100);not null"`
+	Token   string `json:"token" gorm:"type:varchar(64);not null;uniqueIndex"`
+	// Domains 允许嵌入的域名，支持 *.example.com 匹配子域名
+	AllowedDomains string   `json:"-" gorm:"type:text"`
+	Domains        []string `json:"domains" gorm:"-"`
+	// RateLimit 每个 IP 每分钟的请求上限，DailyLimit 每个 IP 每天的提问上限
+	RateLimit  int `json:"rate_limit" gorm:"not null;default:0"`
+	DailyLimit int `json:"daily_limit" gorm:"not null;default:0"`
+	// SessionTokenLimit 每个访客会话累计消耗的 token 上限，DailyTokenLimit 每个 IP 每天消耗的 token 上限
+	SessionTokenLimit int  `json:"session_token_limit" gorm:"not null;default:0"`
+	DailyTokenLimit   int  `json:"daily_token_limit" gorm:"not null;default:0"`
+	Enabled           bool `json:"enabled" gorm:"not null;default:true"`
+	// GuestUserID 访客会话使用的匿名用户，会话与消息都记在该用户下
+	GuestUserID int64 `json:"guest_user_id" gorm:"not null;default:0"`
+	CreatedBy   int64 `json:"created_by" gorm:"not null;default:0"`
+	BaseModel
+}
+
+func (AgentEmbed) TableName() string {
+	return "agent_embeds"
+}
+
+func (e *AgentEmbed) BeforeSave(tx *gorm.DB) error {
+	if e.Domains == nil {
+		e.Domains = make([]string, 0)
+	}
+	data, err := json.Marshal(e.Domains)
+	if err != nil {
+		return err
+	}
+	e.AllowedDomains = string(data)
+	return nil
+}
+
+func (e *AgentEmbed) AfterFind(tx *gorm.DB) error {
+	if e.AllowedDomains != "" {
+		if err := json.Unmarshal([]byte(e.AllowedDomains), &e.Domains); err != nil {
+			return err
+		}
+	}
+	if e.Domains == nil {
+		e.Domains = make([]string, 0)
+	}
+	return nil
+}
+
+// EffectiveRateLimit 未配置时使用默认值
+func (e *AgentEmbed) EffectiveRateLimit() int {
+	if e.RateLimit <= 0 {
+		return EmbedDefaultRateLimit
+	}
+	return e.RateLimit
+}
+
+func (e *AgentEmbed) EffectiveDailyLimit() int {
+	if e.DailyLimit <= 0 {
+		return EmbedDefaultDailyLimit
+	}
+	return e.DailyLimit
+}
+
+func (e *AgentEmbed) EffectiveSessionTokenLimit() int {
+	if e.SessionTokenLimit <= 0 {
+		return EmbedDefaultSessionTokenLimit
+	}
+	return e.SessionTokenLimit
+}
+
+func (e *AgentEmbed) EffectiveDailyTokenLimit() int {
+	if e.DailyTokenLimit <= 0 {
+		return EmbedDefaultDailyTokenLimit
+	}
+	return e.DailyTokenLimit
+}
+
+// EmbedSession 访客会话，每个会话对应一个对话
+type EmbedSession struct {
+	ID             int64         `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid            int64         `json:"eid" gorm:"not null;index"`
+	EmbedID        int64         `json:"embed_id" gorm:"not null;index"`
+	Token          string        `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	ConversationID int64         `json:"conversation_id" gorm:"not null;default:0"`
+	IP             string        `json:"ip" gorm:"type:varchar(64);default:''"`
+	UserAgent      string        `json:"user_agent" gorm:"type:varchar(512);default:''"`
+	ExpiredTime    int64         `json:"expired_time" gorm:"not null"`
+	Conversation   *Conversation `json:"conversation,omitempty" gorm:"-"`
+	BaseModel
+}
+
+func (EmbedSession) TableName() string {
+	return "embed_sessions"
+}
+
+// CreateAgentEmbed 创建嵌入配置及其匿名访客用户
+func CreateAgentEmbed(embed *AgentEmbed, guest *User) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(guest).Error; err != nil {
+			return err
+		}
+		embed.GuestUserID = guest.UserID
+		return tx.Create(embed).Error
+	})
+}
+
+func SaveAgentEmbed(embed *AgentEmbed) error {
+	return DB.Save(embed).Error
+}
+
+func GetAgentEmbed(eid int64, agentID int64, id int64) (*AgentEmbed, error) {
+	var embed AgentEmbed
+	err := DB.Where("eid = ? AND agent_id = ? AND id = ?", eid, agentID, id).First(&embed).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAgentEmbedNotFound
+	}
+	return &embed, err
+}
+
+func GetAgentEmbedByToken(token string) (*AgentEmbed, error) {
+	var embed AgentEmbed
+	err := DB.Where("token = ?", token).First(&embed).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAgentEmbedNotFound
+	}
+	return &embed, err
+}
+
+func GetAgentEmbeds(eid int64, agentID int64) ([]*AgentEmbed, error) {
+	var embeds []*AgentEmbed
+	err := DB.Where("eid = ? AND agent_id = ?", eid, agentID).Order("id DESC").Find(&embeds).Error
+	return embeds, err
+}
+
+// deleteEmbeds 删除嵌入配置与访客会话；访客的对话记录保留，匿名访客用户停用而不删除
+func deleteEmbeds(tx *gorm.DB, embeds []*AgentEmbed) error {
+	for _, embed := range embeds {
+		if err := tx.Where("embed_id = ?", embed.ID).Delete(&EmbedSession{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&User{}).Where("user_id = ? AND type = ?", embed.GuestUserID, UserTypeGuest).
+			Update("status", UserStatusDisabled).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(embed).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func DeleteAgentEmbed(embed *AgentEmbed) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		return deleteEmbeds(tx, []*AgentEmbed{embed})
+	})
+}
+
+// DeleteAgentEmbeds 删除智能体时删除其嵌入配置
+func DeleteAgentEmbeds(tx *gorm.DB, agentID int64) error {
+	var embeds []*AgentEmbed
+	if err := tx.Where("agent_id = ?", agentID).Find(&embeds).Error; err != nil {
+		return err
+	}
+	return deleteEmbeds(tx, embeds)
+}
+
+func CreateEmbedSession(session *EmbedSession) error {
+	return DB.Create(session).Error
+}
+
+// GetEmbedSession 根据令牌获取嵌入配置下未过期的访客会话
+func GetEmbedSession(embedID int64, token string) (*EmbedSession, error) {
+	var session EmbedSession
+	err := DB.Where("embed_id = ? AND token = ?", embedID, token).First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	if session.ExpiredTime < time.Now().UTC().UnixMilli() {
+		return nil, ErrEmbedSessionExpired
+	}
+	return &session, nil
+}
+
+// GetEmbedSessionTokens 访客会话的对话累计消耗的 token 数
+func GetEmbedSessionTokens(session *EmbedSession) (int64, error) {
+	if session.ConversationID == 0 {
+		return 0, nil
+	}
+	var total int64
+	err := DB.Model(&Message{}).Where("eid = ? AND conversation_id = ?", session.Eid, session.ConversationID).
+		Select("COALESCE(SUM(total_tokens), 0)").Scan(&total).Error
+	return total, err
+}
+
+// GetEmbedIPTokens 嵌入配置下来自同一 IP 的访客会话自 since（毫秒）起消耗的 token 数
+func GetEmbedIPTokens(embed *AgentEmbed, ip string, since int64) (int64, error) {
+	var total int64
+	conversations := DB.Model(&EmbedSession{}).Select("conversation_id").
+		Where("embed_id = ? AND ip = ? AND conversation_id > 0", embed.ID, ip)
+	err := DB.Model(&Message{}).Where("eid = ? AND conversation_id IN (?) AND created_time >= ?", embed.Eid, conversations, since).
+		Select("COALESCE(SUM(total_tokens), 0)").Scan(&total).Error
+	return total, err
+}
+
+func UpdateEmbedSessionConversation(id int64, conversationID int64) error {
+	return DB.Model(&EmbedSession{}).Where("id = ?", id).Update("conversation_id", conversationID).Error
+}
+
+// GetEmbedSessions 获取嵌入配置下有对话的访客会话，附带对话信息，供管理员查看
+func GetEmbedSessions(eid int64, embedID int64, offset int, limit int) (count int64, sessions []*EmbedSession, err error) {
+	db := DB.Model(&EmbedSession{}).Where("eid = ? AND embed_id = ? AND conversation_id > 0", eid, embedID)
+	if err = db.Count(&count).Error; err != nil {
+		return 0, nil, err
+	}
+	if err = db.Order("id DESC").Offset(offset).Limit(limit).Find(&sessions).Error; err != nil {
+		return 0, nil, err
+	}
+	for _, s := range sessions {
+		if conversation, err := AdminGetConversationByID(eid, s.ConversationID); err == nil {
+			s.Conversation = conversation
+		}
+	}
+	return count, sessions, nil
+}
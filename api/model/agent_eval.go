@@ -0,0 +1,321 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrEvalDatasetNotFound = errors.New("eval dataset not found")
+	ErrEvalRunNotFound     = errors.New("eval run not found")
+)
+
+// EvalDataset 智能体的评测集，发布改动前用于回归测试
+type EvalDataset struct {
+	ID          int64       `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid         int64       `json:"eid" gorm:"not null;index"`
+	AgentID     int64       `json:"agent_id" gorm:"not null;index"`
+	Name        string      `json:"name" gorm:"type:varchar(100);not null"`
+	Description string      `json:"description" gorm:"type:text"`
+	CreatedBy   int64       `json:"created_by" gorm:"not null;default:0"`
+	Cases       []*EvalCase `json:"cases,omitempty" gorm:"foreignKey:DatasetID"`
+	BaseModel
+}
+
+func (EvalDataset) TableName() string {
+	return "eval_datasets"
+}
+
+// EvalCase 评测用例：ExpectedAnswer 用于精确匹配和语义相似度，Pattern 为正则，Rubric 为大模型评审的评分标准
+type EvalCase struct {
+	ID             int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	DatasetID      int64  `json:"dataset_id" gorm:"not null;index"`
+	Question       string `json:"question" gorm:"type:text;not null"`
+	ExpectedAnswer string `json:"expected_answer" gorm:"type:text"`
+	Pattern        string `json:"pattern" gorm:"type:varchar(500);default:''"`
+	Rubric         string `json:"rubric" gorm:"type:text"`
+	Sort           int    `json:"sort" gorm:"not null;default:0"`
+	BaseModel
+}
+
+func (EvalCase) TableName() string {
+	return "eval_cases"
+}
+
+const (
+	EvalRunStatusPending   = 0
+	EvalRunStatusRunning   = 1
+	EvalRunStatusCompleted = 2
+	EvalRunStatusFailed    = 3
+)
+
+// EvalConfig 评测运行的配置：被测配置的覆盖项与评分方式
+type EvalConfig struct {
+	// Target 被测版本：current/draft/版本号
+	Target      string `json:"target" example:"current"`
+	ChannelType int    `json:"channel_type" example:"1"`
+	Model       string `json:"model" example:"gpt-4o"`
+	Prompt      string `json:"prompt"`
+	// EmbeddingModel 语义相似度使用的向量模型，为空时不计算
+	EmbeddingModel      string  `json:"embedding_model" example:"text-embedding-3-small"`
+	SimilarityThreshold float64 `json:"similarity_threshold" example:"0.85"`
+	// JudgeChannelID 大模型评审使用的渠道，为 0 时不评审
+	JudgeChannelID int64  `json:"judge_channel_id" example:"1"`
+	JudgeModel     string `json:"judge_model" example:"gpt-4o"`
+	// PassScore 大模型评审得分（0-1）不低于该值视为通过，默认 0.6
+	PassScore float64 `json:"pass_score" example:"0.6"`
+}
+
+// EvalRun 一次评测运行，逐条用例通过正常的对话链路执行并评分
+type EvalRun struct {
+	ID        int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid       int64  `json:"eid" gorm:"not null;index"`
+	AgentID   int64  `json:"agent_id" gorm:"not null;index"`
+	DatasetID int64  `json:"dataset_id" gorm:"not null;index"`
+	Config    string `json:"-" gorm:"type:text"`
+	// AgentVersion 被测的智能体版本，草稿为 -1
+	AgentVersion  int        `json:"agent_version" gorm:"not null;default:0"`
+	PreviousRunID int64      `json:"previous_run_id" gorm:"not null;default:0"` // 对比的上一次运行
+	Status        int        `json:"status" gorm:"not null;default:0"`
+	CaseCount     int        `json:"case_count" gorm:"not null;default:0"`
+	PassedCount   int        `json:"passed_count" gorm:"not null;default:0"`
+	AvgScore      float64    `json:"avg_score" gorm:"not null;default:0"`
+	Improved      int        `json:"improved" gorm:"not null;default:0"`  // 较上一次运行由不通过变为通过的用例数
+	Regressed     int        `json:"regressed" gorm:"not null;default:0"` // 较上一次运行由通过变为不通过的用例数
+	ErrorMessage  string     `json:"error_message" gorm:"type:text"`
+	CreatedBy     int64      `json:"created_by" gorm:"not null;default:0"`
+	FinishedTime  int64      `json:"finished_time" gorm:"not null;default:0"`
+	EvalConfig    EvalConfig `json:"config" gorm:"-"`
+	BaseModel
+}
+
+func (EvalRun) TableName() string {
+	return "eval_runs"
+}
+
+func (run *EvalRun) BeforeSave(tx *gorm.DB) error {
+	data, err := json.Marshal(run.EvalConfig)
+	if err != nil {
+		return err
+	}
+	run.Config = string(data)
+	return nil
+}
+
+func (run *EvalRun) AfterFind(tx *gorm.DB) error {
+	if run.Config == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(run.Config), &run.EvalConfig)
+}
+
+// 用例结果相对上一次运行的变化
+const (
+	EvalChangeNew       = "new"
+	EvalChangeImproved  = "improved"
+	EvalChangeRegressed = "regressed"
+	EvalChangeUnchanged = "unchanged"
+)
+
+// EvalScore 单个评分方式的结果
+type EvalScore struct {
+	Scorer string  `json:"scorer" example:"exact"` // exact/regex/embedding/judge
+	Score  float64 `json:"score"`
+	Passed bool    `json:"passed"`
+	Reason string  `json:"reason,omitempty"`
+}
+
+// EvalResult 用例在一次运行中的结果，附带与上一次运行的对比
+type EvalResult struct {
+	ID             int64   `json:"id" gorm:"primaryKey;autoIncrement"`
+	RunID          int64   `json:"run_id" gorm:"not null;index"`
+	CaseID         int64   `json:"case_id" gorm:"not null;index"`
+	Question       string  `json:"question" gorm:"type:text"`
+	ExpectedAnswer string  `json:"expected_answer" gorm:"type:text"`
+	Answer         string  `json:"answer" gorm:"type:text"`
+	Scores         string  `json:"-" gorm:"type:text"`
+	Score          float64 `json:"score" gorm:"not null;default:0"`
+	Passed         bool    `json:"passed" gorm:"not null;default:false"`
+	ElapsedTime    int64   `json:"elapsed_time" gorm:"not null;default:0"` // 毫秒
+	TotalTokens    int     `json:"total_tokens" gorm:"not null;default:0"`
+	ErrorMessage   string  `json:"error_message" gorm:"type:text"`
+	Change         string  `json:"change" gorm:"type:varchar(20);default:''"`
+	PreviousScore  float64 `json:"previous_score" gorm:"not null;default:0"`
+	// AnswerDiff 与上一次运行回答的 unified diff
+	AnswerDiff   string      `json:"answer_diff" gorm:"type:text"`
+	ScoreDetails []EvalScore `json:"scores" gorm:"-"`
+	BaseModel
+}
+
+func (EvalResult) TableName() string {
+	return "eval_results"
+}
+
+func (r *EvalResult) BeforeSave(tx *gorm.DB) error {
+	data, err := json.Marshal(r.ScoreDetails)
+	if err != nil {
+		return err
+	}
+	r.Scores = string(data)
+	return nil
+}
+
+func (r *EvalResult) AfterFind(tx *gorm.DB) error {
+	if r.Scores == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(r.Scores), &r.ScoreDetails)
+}
+
+func GetEvalDatasets(eid int64, agentID int64) ([]*EvalDataset, error) {
+	datasets := make([]*EvalDataset, 0)
+	err := DB.Where("eid = ? AND agent_id = ?", eid, agentID).Order("id DESC").Find(&datasets).Error
+	return datasets, err
+}
+
+// GetEvalDataset 评测集及其用例
+func GetEvalDataset(eid int64, agentID int64, id int64) (*EvalDataset, error) {
+	var dataset EvalDataset
+	err := DB.Preload("Cases", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sort, id")
+	}).Where("id = ? AND eid = ? AND agent_id = ?", id, eid, agentID).First(&dataset).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrEvalDatasetNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &dataset, nil
+}
+
+// SaveEvalDataset 保存评测集，Cases 不为 nil 时同步用例：保留已有用例的 ID 以便与历史运行对比，未出现的用例被删除
+func SaveEvalDataset(dataset *EvalDataset) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Cases").Save(dataset).Error; err != nil {
+			return err
+		}
+		if dataset.Cases == nil {
+			return nil
+		}
+		var existing []int64
+		if err := tx.Model(&EvalCase{}).Where("dataset_id = ?", dataset.ID).Pluck("id", &existing).Error; err != nil {
+			return err
+		}
+		keep := make(map[int64]bool, len(existing))
+		for _, id := range existing {
+			keep[id] = false
+		}
+		for i, c := range dataset.Cases {
+			if _, ok := keep[c.ID]; !ok {
+				c.ID = 0
+			}
+			c.DatasetID = dataset.ID
+			c.Sort = i
+			if err := tx.Save(c).Error; err != nil {
+				return err
+			}
+			keep[c.ID] = true
+		}
+		removed := make([]int64, 0)
+		for id, kept := range keep {
+			if !kept {
+				removed = append(removed, id)
+			}
+		}
+		if len(removed) == 0 {
+			return nil
+		}
+		return tx.Where("id IN ?", removed).Delete(&EvalCase{}).Error
+	})
+}
+
+// DeleteEvalDataset 删除评测集及其用例和运行记录
+func DeleteEvalDataset(dataset *EvalDataset) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		return deleteEvalDatasets(tx, []int64{dataset.ID})
+	})
+}
+
+// DeleteAgentEvalDatasets 删除智能体的全部评测数据，随智能体删除时调用
+func DeleteAgentEvalDatasets(db *gorm.DB, agentID int64) error {
+	var ids []int64
+	if err := db.Model(&EvalDataset{}).Where("agent_id = ?", agentID).Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return deleteEvalDatasets(db, ids)
+}
+
+func deleteEvalDatasets(tx *gorm.DB, ids []int64) error {
+	runs := tx.Model(&EvalRun{}).Select("id").Where("dataset_id IN ?", ids)
+	if err := tx.Where("run_id IN (?)", runs).Delete(&EvalResult{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("dataset_id IN ?", ids).Delete(&EvalRun{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("dataset_id IN ?", ids).Delete(&EvalCase{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("id IN ?", ids).Delete(&EvalDataset{}).Error
+}
+
+func CreateEvalRun(run *EvalRun) error {
+	return DB.Create(run).Error
+}
+
+func UpdateEvalRun(run *EvalRun) error {
+	return DB.Save(run).Error
+}
+
+func GetEvalRun(eid int64, agentID int64, id int64) (*EvalRun, error) {
+	var run EvalRun
+	err := DB.Where("id = ? AND eid = ? AND agent_id = ?", id, eid, agentID).First(&run).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrEvalRunNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// GetEvalRuns 智能体的评测运行记录，datasetID 为 0 时不限评测集
+func GetEvalRuns(eid int64, agentID int64, datasetID int64, offset int, limit int) (int64, []*EvalRun, error) {
+	var count int64
+	runs := make([]*EvalRun, 0)
+	db := DB.Model(&EvalRun{}).Where("eid = ? AND agent_id = ?", eid, agentID)
+	if datasetID > 0 {
+		db = db.Where("dataset_id = ?", datasetID)
+	}
+	if err := db.Count(&count).Error; err != nil {
+		return 0, nil, err
+	}
+	err := db.Order("id DESC").Offset(offset).Limit(limit).Find(&runs).Error
+	return count, runs, err
+}
+
+// GetLatestCompletedEvalRun 评测集最近一次完成的运行，没有时返回 nil
+func GetLatestCompletedEvalRun(datasetID int64, beforeID int64) (*EvalRun, error) {
+	var run EvalRun
+	result := DB.Where("dataset_id = ? AND status = ? AND id < ?", datasetID, EvalRunStatusCompleted, beforeID).
+		Order("id DESC").Limit(1).Find(&run)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+	return &run, nil
+}
+
+func CreateEvalResult(result *EvalResult) error {
+	return DB.Create(result).Error
+}
+
+func GetEvalResults(runID int64) ([]*EvalResult, error) {
+	results := make([]*EvalResult, 0)
+	err := DB.Where("run_id = ?", runID).Order("id").Find(&results).Error
+	return results, err
+}
@@ -0,0 +1,182 @@
+package model
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// 实验状态：草稿可编辑，运行中分流，停止后可再次开始，推广胜出方案后结束
+const (
+	ExperimentStatusDraft     = "draft"
+	ExperimentStatusRunning   = "running"
+	ExperimentStatusStopped   = "stopped"
+	ExperimentStatusCompleted = "completed"
+)
+
+var ErrExperimentNotFound = errors.New("experiment not found")
+
+// AgentExperiment 智能体 A/B 实验，运行期间按用户将对话分流到不同方案，同一智能体同时只能运行一个实验
+type AgentExperiment struct {
+	ID          int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid         int64  `json:"eid" gorm:"not null;index"`
+	AgentID     int64  `json:"agent_id" gorm:"not null;index"`
+	Name        string `json:"name" gorm:"type:varchar(100);not null"`
+	Description string `json:"description" gorm:"type:text"`
+	Status      string `json:"status" gorm:"type:varchar(20);not null;default:'draft';index"`
+	StartedTime int64  `json:"started_time" gorm:"not null;default:0"`
+	StoppedTime int64  `json:"stopped_time" gorm:"not null;default:0"`
+	// WinnerVariantID 推广到智能体的方案
+	WinnerVariantID int64                     `json:"winner_variant_id" gorm:"not null;default:0"`
+	CreatedBy       int64                     `json:"created_by" gorm:"not null;default:0"`
+	Variants        []*AgentExperimentVariant `json:"variants" gorm:"foreignKey:ExperimentID"`
+	BaseModel
+}
+
+func (AgentExperiment) TableName() string {
+	return "agent_experiments"
+}
+
+// AgentExperimentVariant 实验方案，Weight 为分流权重
+type AgentExperimentVariant struct {
+	ID           int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	ExperimentID int64  `json:"experiment_id" gorm:"not null;index"`
+	Name         string `json:"name" gorm:"type:varchar(100);not null"`
+	Weight       int    `json:"weight" gorm:"not null;default:1"`
+	AgentSnapshot
+	BaseModel
+}
+
+func (AgentExperimentVariant) TableName() string {
+	return "agent_experiment_variants"
+}
+
+// VariantStat 方案的实验结果
+type VariantStat struct {
+	VariantID        int64   `json:"variant_id"`
+	MessageCount     int64   `json:"message_count"`
+	UserCount        int64   `json:"user_count"`
+	UpCount          int64   `json:"up_count"`
+	DownCount        int64   `json:"down_count"`
+	AvgElapsedTime   float64 `json:"avg_elapsed_time"` // 毫秒
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	Quota            int64   `json:"quota"`
+}
+
+func GetAgentExperiment(eid int64, agentID int64, id int64) (*AgentExperiment, error) {
+	var experiment AgentExperiment
+	err := DB.Preload("Variants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Where("id = ? AND eid = ? AND agent_id = ?", id, eid, agentID).First(&experiment).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrExperimentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &experiment, nil
+}
+
+func GetAgentExperiments(eid int64, agentID int64) ([]*AgentExperiment, error) {
+	experiments := make([]*AgentExperiment, 0)
+	err := DB.Preload("Variants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Where("eid = ? AND agent_id = ?", eid, agentID).Order("id DESC").Find(&experiments).Error
+	return experiments, err
+}
+
+// GetRunningAgentExperiment 智能体正在运行的实验，没有时返回 nil
+func GetRunningAgentExperiment(agentID int64) (*AgentExperiment, error) {
+	var experiment AgentExperiment
+	result := DB.Preload("Variants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Where("agent_id = ? AND status = ?", agentID, ExperimentStatusRunning).Limit(1).Find(&experiment)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+	return &experiment, nil
+}
+
+// SaveAgentExperiment 保存实验及其方案，方案整体替换
+func SaveAgentExperiment(experiment *AgentExperiment) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Variants").Save(experiment).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("experiment_id = ?", experiment.ID).Delete(&AgentExperimentVariant{}).Error; err != nil {
+			return err
+		}
+		for _, variant := range experiment.Variants {
+			variant.ID = 0
+			variant.ExperimentID = experiment.ID
+			if err := tx.Create(variant).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func UpdateAgentExperimentStatus(experiment *AgentExperiment) error {
+	return DB.Model(experiment).Select("status", "started_time", "stopped_time", "winner_variant_id", "updated_time").
+		Updates(experiment).Error
+}
+
+func DeleteAgentExperiment(experiment *AgentExperiment) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("experiment_id = ?", experiment.ID).Delete(&AgentExperimentVariant{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(experiment).Error
+	})
+}
+
+// DeleteAgentExperiments 删除智能体的全部实验，随智能体删除时调用
+func DeleteAgentExperiments(db *gorm.DB, agentID int64) error {
+	if err := db.Where("experiment_id IN (?)", db.Model(&AgentExperiment{}).Select("id").Where("agent_id = ?", agentID)).
+		Delete(&AgentExperimentVariant{}).Error; err != nil {
+		return err
+	}
+	return db.Where("agent_id = ?", agentID).Delete(&AgentExperiment{}).Error
+}
+
+// GetExperimentVariantStats 按方案汇总实验期间的消息、评价、耗时、Token 与额度
+func GetExperimentVariantStats(experimentID int64) ([]*VariantStat, error) {
+	stats := make([]*VariantStat, 0)
+	err := DB.Model(&Message{}).
+		Select("variant_id, COUNT(*) AS message_count, COUNT(DISTINCT user_id) AS user_count, "+
+			"AVG(elapsed_time) AS avg_elapsed_time, SUM(prompt_tokens) AS prompt_tokens, "+
+			"SUM(completion_tokens) AS completion_tokens, SUM(total_tokens) AS total_tokens, SUM(quota) AS quota").
+		Where("experiment_id = ?", experimentID).Group("variant_id").Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var ratings []struct {
+		VariantID int64
+		UpCount   int64
+		DownCount int64
+	}
+	err = DB.Table("message_feedbacks").
+		Select("messages.variant_id AS variant_id, "+
+			"SUM(CASE WHEN message_feedbacks.rating = ? THEN 1 ELSE 0 END) AS up_count, "+
+			"SUM(CASE WHEN message_feedbacks.rating = ? THEN 1 ELSE 0 END) AS down_count", FeedbackRatingUp, FeedbackRatingDown).
+		Joins("JOIN messages ON messages.id = message_feedbacks.message_id").
+		Where("messages.experiment_id = ?", experimentID).Group("messages.variant_id").Scan(&ratings).Error
+	if err != nil {
+		return nil, err
+	}
+	byVariant := make(map[int64]*VariantStat, len(stats))
+	for _, stat := range stats {
+		byVariant[stat.VariantID] = stat
+	}
+	for _, r := range ratings {
+		if stat, ok := byVariant[r.VariantID]; ok {
+			stat.UpCount = r.UpCount
+			stat.DownCount = r.DownCount
+		}
+	}
+	return stats, nil
+}
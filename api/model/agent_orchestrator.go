@@ -0,0 +1,70 @@
+package model
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+// SubAgent 编排智能体可调用的子智能体，Description 供模型判断何时调用
+type SubAgent struct {
+	AgentID     int64  `json:"agent_id" example:"2"`
+	Description string `json:"description" example:"回答考勤、假期、薪酬等人事问题"`
+}
+
+// OrchestratorConfig 编排智能体配置，保存在智能体 custom_config 的 orchestrator 字段中
+type OrchestratorConfig struct {
+	SubAgents []SubAgent `json:"sub_agents"`
+	// MaxRounds 单次提问最多的调用轮数，0 表示使用默认值
+	MaxRounds int `json:"max_rounds" example:"3"`
+}
+
+// ParseOrchestratorConfig 从 custom_config 中读取编排配置，未配置时返回空配置
+func ParseOrchestratorConfig(customConfig string) (*OrchestratorConfig, error) {
+	cfg := &OrchestratorConfig{SubAgents: make([]SubAgent, 0)}
+	if customConfig == "" {
+		return cfg, nil
+	}
+	var wrapper struct {
+		Orchestrator *OrchestratorConfig `json:"orchestrator"`
+	}
+	if err := json.Unmarshal([]byte(customConfig), &wrapper); err != nil {
+		return nil, err
+	}
+	if wrapper.Orchestrator != nil {
+		cfg = wrapper.Orchestrator
+	}
+	return cfg, nil
+}
+
+// GetSubAgentMessages 获取会话中某条回答调用子智能体产生的消息
+func GetSubAgentMessages(eid int64, conversationID int64, messageID int64) ([]*Message, error) {
+	message, err := GetMessageByID(eid, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.ConversationID != conversationID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var messages []*Message
+	err = DB.Where("eid = ? AND caller_message_id = ?", eid, messageID).Order("id ASC").Find(&messages).Error
+	return messages, err
+}
+
+// LinkSubAgentMessages 将子智能体消息挂到编排智能体的回答消息下
+func LinkSubAgentMessages(eid int64, callerID int64, messageIDs []int64) error {
+	if len(messageIDs) == 0 {
+		return nil
+	}
+	return DB.Model(&Message{}).Where("eid = ? AND id IN ?", eid, messageIDs).
+		Update("caller_message_id", callerID).Error
+}
+
+// AddConversationUsage 累加会话的用量，用于子智能体调用的用量计入所属会话
+func AddConversationUsage(eid int64, conversationID int64, quota int, totalTokens int) error {
+	return DB.Model(&Conversation{}).Where("eid = ? AND conversation_id = ?", eid, conversationID).
+		Updates(map[string]interface{}{
+			"quota":        gorm.Expr("quota + ?", quota),
+			"total_tokens": gorm.Expr("total_tokens + ?", totalTokens),
+		}).Error
+}
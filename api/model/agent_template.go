@@ -0,0 +1,145 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/songquanpeng/one-api/relay/channeltype"
+	"gorm.io/gorm"
+)
+
+var ErrAgentTemplateNotFound = errors.New("agent template not found")
+
+// AgentTemplate 智能体模板，Eid 为 0 的是所有企业可见的内置模板，其余为企业保存的模板
+type AgentTemplate struct {
+	ID  int64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid int64 `json:"eid" gorm:"not null;default:0;index"`
+	// Code 内置模板的唯一标识，启动时按该标识同步内置模板
+	Code        string `json:"code" gorm:"type:varchar(64);not null;default:'';index"`
+	Name        string `json:"name" gorm:"type:varchar(100);not null"`
+	Description string `json:"description" gorm:"type:text"`
+	Logo        string `json:"logo" gorm:"type:varchar(512);not null;default:''"`
+	Category    string `json:"category" gorm:"type:varchar(50);not null;default:'';index"`
+	AgentType   int    `json:"agent_type" gorm:"default:0"`
+	UseCases    string `json:"use_cases" gorm:"type:text"`
+	AgentSnapshot
+	Sort      int   `json:"sort" gorm:"default:0"`
+	CreatedBy int64 `json:"created_by" gorm:"not null;default:0"`
+	BaseModel
+}
+
+func (AgentTemplate) TableName() string {
+	return "agent_templates"
+}
+
+// IsBuiltIn 内置模板只读，不能删除
+func (t *AgentTemplate) IsBuiltIn() bool {
+	return t.Eid == 0
+}
+
+const agentTemplateLogo = "https://img.ibos.cn/common/agenthub/agent/53ai.png"
+
+// AgentTemplateData 内置模板，Code 一经发布不要修改
+var AgentTemplateData = []struct {
+	Code        string
+	Category    string
+	Name        string
+	Description string
+	Prompt      string
+	UseCases    string
+	Sort        int
+}{
+	{"general_assistant", "通用", "通用助手", "回答日常问题，帮助整理思路", "你是一位耐心、专业的 AI 助手。请用简洁清晰的中文回答用户的问题，不确定时如实说明。",
+		`["帮我规划一次三天的杭州旅行","解释一下什么是大语言模型"]`, 50},
+	{"translator", "办公提效", "翻译助手", "中英互译，保留原文格式与专业术语", "你是一位专业翻译。用户输入中文时翻译成英文，输入其他语言时翻译成中文。保留原文的段落、列表和代码格式，专业术语首次出现时在括号中附上原文。只输出译文。",
+		`["把这段产品介绍翻译成英文","翻译这封英文邮件"]`, 40},
+	{"writing_assistant", "办公提效", "写作助手", "润色、扩写、改写各类文稿", "你是一位资深编辑。根据用户提供的文稿和要求进行润色、扩写、缩写或改写，保持原意，语言通顺得体。修改较大时先给出修改后的全文，再简要列出主要改动。",
+		`["帮我润色这份周报","把这段话改写得更正式"]`, 30},
+	{"code_assistant", "研发", "编程助手", "解释代码、排查问题、编写示例", "你是一位经验丰富的软件工程师。回答编程问题时先给出结论，再给出可运行的代码示例，并说明关键步骤。代码使用 Markdown 代码块并标注语言。",
+		`["这段 Go 代码为什么会死锁","写一个读取 CSV 的 Python 脚本"]`, 20},
+	{"customer_service", "客户服务", "客服助手", "礼貌专业地解答客户咨询", "你是企业的在线客服。语气礼貌、耐心，先确认客户的问题，再给出明确的解决步骤。无法解决的问题引导客户留下联系方式，不要编造产品信息。",
+		`["我的订单什么时候发货","如何申请退款"]`, 10},
+}
+
+// BuiltinAgentTemplates 内置模板默认使用 OpenAI 渠道的通用对话模型，导入时按企业已有渠道重新匹配
+func BuiltinAgentTemplates() []AgentTemplate {
+	templates := make([]AgentTemplate, 0, len(AgentTemplateData))
+	for _, data := range AgentTemplateData {
+		templates = append(templates, AgentTemplate{
+			Code:        data.Code,
+			Name:        data.Name,
+			Description: data.Description,
+			Logo:        agentTemplateLogo,
+			Category:    data.Category,
+			AgentType:   AgentTypeApp,
+			UseCases:    data.UseCases,
+			AgentSnapshot: AgentSnapshot{
+				ChannelType:  channeltype.OpenAI,
+				Model:        "gpt-4o-mini",
+				Prompt:       data.Prompt,
+				Configs:      `{"temperature":0.7}`,
+				Tools:        "[]",
+				CustomConfig: `{"agent_type":"prompt"}`,
+				Settings:     "{}",
+			},
+			Sort: data.Sort,
+		})
+	}
+	return templates
+}
+
+// InitializeAgentTemplates 同步内置模板：新增缺少的模板，已有的按代码中的内容更新
+func InitializeAgentTemplates() error {
+	for _, template := range BuiltinAgentTemplates() {
+		var existing AgentTemplate
+		err := DB.Where("eid = 0 AND code = ?", template.Code).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if err := DB.Create(&template).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		template.ID = existing.ID
+		template.CreatedTime = existing.CreatedTime
+		if err := DB.Save(&template).Error; err != nil {
+			return err
+		}
+	}
+	logger.SysLogf("Agent templates initialized: %d built-in", len(AgentTemplateData))
+	return nil
+}
+
+// GetAgentTemplates 企业可见的模板：内置模板和本企业保存的模板
+func GetAgentTemplates(eid int64, category string) ([]*AgentTemplate, error) {
+	templates := make([]*AgentTemplate, 0)
+	db := DB.Where("eid IN ?", []int64{0, eid})
+	if category != "" {
+		db = db.Where("category = ?", category)
+	}
+	err := db.Order("eid DESC").Order("sort DESC").Order("id ASC").Find(&templates).Error
+	return templates, err
+}
+
+func GetAgentTemplate(eid int64, id int64) (*AgentTemplate, error) {
+	var template AgentTemplate
+	err := DB.Where("id = ? AND eid IN ?", id, []int64{0, eid}).First(&template).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAgentTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+func CreateAgentTemplate(template *AgentTemplate) error {
+	return DB.Create(template).Error
+}
+
+func DeleteAgentTemplate(eid int64, id int64) (bool, error) {
+	result := DB.Where("id = ? AND eid = ?", id, eid).Delete(&AgentTemplate{})
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,105 @@
+package model
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// AgentVersionDraft 草稿的版本号；Message.AgentVersion 为该值表示由草稿产生，为 0 表示启用版本管理之前的消息
+const AgentVersionDraft = -1
+
+var ErrAgentVersionNotFound = errors.New("agent version not found")
+
+// AgentSnapshot 智能体中纳入版本管理的字段，编辑时先写入草稿，发布后才对用户生效
+type AgentSnapshot struct {
+	ChannelType  int    `json:"channel_type" gorm:"default:0"`
+	Model        string `json:"model" gorm:"not null"`
+	Prompt       string `json:"prompt" gorm:"not null"`
+	Configs      string `json:"configs" gorm:"not null;type:text"`
+	Tools        string `json:"tools" gorm:"not null;type:text"`
+	CustomConfig string `json:"custom_config" gorm:"not null;type:text"`
+	Settings     string `json:"settings" gorm:"not null;type:text"`
+}
+
+// AgentVersion 智能体版本，Version 从 1 递增，草稿固定为 AgentVersionDraft 且每个智能体最多一份
+type AgentVersion struct {
+	ID      int64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid     int64 `json:"eid" gorm:"not null;index"`
+	AgentID int64 `json:"agent_id" gorm:"not null;uniqueIndex:idx_agent_version"`
+	Version int   `json:"version" gorm:"not null;uniqueIndex:idx_agent_version"`
+	AgentSnapshot
+	Note string `json:"note" gorm:"type:varchar(255)"`
+	// SourceVersion 回滚产生的版本记录来源版本号，其他情况为 0
+	SourceVersion int   `json:"source_version" gorm:"not null;default:0"`
+	CreatedBy     int64 `json:"created_by" gorm:"not null;default:0"`
+	BaseModel
+}
+
+func (AgentVersion) TableName() string {
+	return "agent_versions"
+}
+
+func (agent *Agent) Snapshot() AgentSnapshot {
+	return AgentSnapshot{
+		ChannelType:  agent.ChannelType,
+		Model:        agent.Model,
+		Prompt:       agent.Prompt,
+		Configs:      agent.Configs,
+		Tools:        agent.Tools,
+		CustomConfig: agent.CustomConfig,
+		Settings:     agent.Settings,
+	}
+}
+
+func (agent *Agent) ApplySnapshot(s AgentSnapshot) {
+	agent.ChannelType = s.ChannelType
+	agent.Model = s.Model
+	agent.Prompt = s.Prompt
+	agent.Configs = s.Configs
+	agent.Tools = s.Tools
+	agent.CustomConfig = s.CustomConfig
+	agent.Settings = s.Settings
+}
+
+// GetAgentVersion 获取指定版本，version 为 AgentVersionDraft 时获取草稿
+func GetAgentVersion(db *gorm.DB, agentID int64, version int) (*AgentVersion, error) {
+	var v AgentVersion
+	err := db.Where("agent_id = ? AND version = ?", agentID, version).First(&v).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAgentVersionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// GetAgentVersions 已发布的版本，最新的在前
+func GetAgentVersions(agentID int64) ([]*AgentVersion, error) {
+	versions := make([]*AgentVersion, 0)
+	err := DB.Where("agent_id = ? AND version > 0", agentID).Order("version DESC").Find(&versions).Error
+	return versions, err
+}
+
+// GetLatestAgentVersion 最大的已发布版本号，没有时返回 0
+func GetLatestAgentVersion(db *gorm.DB, agentID int64) (int, error) {
+	var latest int
+	err := db.Model(&AgentVersion{}).Where("agent_id = ?", agentID).
+		Select("COALESCE(MAX(version), 0)").Scan(&latest).Error
+	return latest, err
+}
+
+func HasAgentDraft(agentID int64) bool {
+	var count int64
+	DB.Model(&AgentVersion{}).Where("agent_id = ? AND version = ?", agentID, AgentVersionDraft).Count(&count)
+	return count > 0
+}
+
+func DeleteAgentDraft(db *gorm.DB, agentID int64) error {
+	return db.Where("agent_id = ? AND version = ?", agentID, AgentVersionDraft).Delete(&AgentVersion{}).Error
+}
+
+func DeleteAgentVersions(db *gorm.DB, agentID int64) error {
+	return db.Where("agent_id = ?", agentID).Delete(&AgentVersion{}).Error
+}
@@ -232,6 +232,35 @@ func GetRandomChannel(eid int64, channelType int, modelName string) (*Channel, e
 		return nil, fmt.Errorf("no available channel found")
 	}
 
+	return pickChannelByWeight(channels), nil
+}
+
+// GetRandomChannelByModelType 按模型类型（如 embedding）选择可用渠道，不限定渠道类型
+// models 字段为逗号分隔的模型名，LIKE 仅用于初筛，需再按模型名精确匹配，避免 bge-m3 命中 bge-m3-large
+func GetRandomChannelByModelType(eid int64, modelType int, modelName string) (*Channel, error) {
+	var candidates []Channel
+
+	err := DB.Where("eid = ? AND model_type = ? AND status = ? AND models LIKE ?",
+		eid, modelType, ChannelStatusEnabled, "%"+modelName+"%").
+		Find(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	channels := make([]Channel, 0, len(candidates))
+	for _, channel := range candidates {
+		if channel.HasModel(modelName) {
+			channels = append(channels, channel)
+		}
+	}
+	if len(channels) == 0 {
+		return nil, fmt.Errorf("no available channel found")
+	}
+
+	return pickChannelByWeight(channels), nil
+}
+
+func pickChannelByWeight(channels []Channel) *Channel {
 	var totalWeight uint = 0
 	for _, channel := range channels {
 		if channel.Weight != nil {
@@ -240,7 +269,7 @@ func GetRandomChannel(eid int64, channelType int, modelName string) (*Channel, e
 	}
 
 	if totalWeight == 0 {
-		return &channels[utils.GetRandomInt64(int64(len(channels)))], nil
+		return &channels[utils.GetRandomInt64(int64(len(channels)))]
 	}
 
 	randomWeight := utils.GetRandomInt64(int64(totalWeight))
@@ -249,12 +278,12 @@ func GetRandomChannel(eid int64, channelType int, modelName string) (*Channel, e
 		if channel.Weight != nil {
 			currentWeight += *channel.Weight
 			if uint(randomWeight) < currentWeight {
-				return &channel, nil
+				return &channel
 			}
 		}
 	}
 
-	return &channels[0], nil
+	return &channels[0]
 }
 
 func GetApiType(channelType int) int {
@@ -329,6 +358,16 @@ func ProcessModelNames(models string, channelType int) string {
 	return strings.Join(newModels, ",")
 }
 
+// HasModel 判断渠道的模型列表中是否包含指定模型名
+func (channel *Channel) HasModel(modelName string) bool {
+	for _, m := range strings.Split(channel.Models, ",") {
+		if strings.TrimSpace(m) == modelName {
+			return true
+		}
+	}
+	return false
+}
+
 func (channel *Channel) GetAddModelString(model string) string {
 	existingModels := make(map[string]bool)
 	for _, m := range strings.Split(channel.Models, ",") {
@@ -0,0 +1,29 @@
+package model
+
+import "testing"
+
+func TestGetRandomChannelByModelTypeMatchesExactModel(t *testing.T) {
+	setupTestDB(t, &Channel{})
+	for _, channel := range []*Channel{
+		{Eid: 1, ModelType: ModelTypeEmbedding, Name: "large", Models: "bge-m3-large,text-embedding-3-small", Status: ChannelStatusEnabled},
+		{Eid: 1, ModelType: ModelTypeEmbedding, Name: "m3", Models: "text-embedding-3-large, bge-m3", Status: ChannelStatusEnabled},
+		{Eid: 1, ModelType: ModelTypeLLM, Name: "llm", Models: "bge-m3", Status: ChannelStatusEnabled},
+	} {
+		if err := CreateChannel(channel); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		channel, err := GetRandomChannelByModelType(1, ModelTypeEmbedding, "bge-m3")
+		if err != nil || channel.Name != "m3" {
+			t.Fatalf("bge-m3 = %+v, %v; want channel m3", channel, err)
+		}
+	}
+	if channel, err := GetRandomChannelByModelType(1, ModelTypeEmbedding, "text-embedding-3"); err == nil {
+		t.Fatalf("partial model name matched channel %s", channel.Name)
+	}
+	if _, err := GetRandomChannelByModelType(2, ModelTypeEmbedding, "bge-m3"); err == nil {
+		t.Fatal("channels of another enterprise should not match")
+	}
+}
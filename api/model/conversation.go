@@ -14,6 +14,8 @@ type Conversation struct {
 	ChannelConversationID             string `json:"channel_conversation_id" gorm:"column:channel_conversation_id;type:varchar(255)"`
 	ChannelConversationExpirationTime int64  `json:"channel_conversation_expiration_time" gorm:"column:channel_conversation_expiration_time;default:0"`
 	Model                             string `json:"model" gorm:"column:model;type:varchar(255)"`
+	ActiveMessageID                   int64  `json:"active_message_id" gorm:"column:active_message_id;default:0"`
+	SourceShareID                     string `json:"source_share_id" gorm:"column:source_share_id;type:varchar(64);default:'';index"` // 从分享继续对话时记录来源分享ID
 	Agent                             *Agent `json:"agent" gorm:"-"`
 	User                              *User  `json:"user" gorm:"-"`
 	BaseModel
@@ -166,8 +168,9 @@ func GetAgentConversationsWithFilter(eid, agentID int64, keyword string, created
 }
 
 // UpdateConversation updates a conversation record
+// active_message_id 只通过 SetConversationActiveMessage 修改，避免并发请求用旧值覆盖当前分支
 func UpdateConversation(conversation *Conversation) error {
-	return DB.Save(conversation).Error
+	return DB.Omit("active_message_id").Save(conversation).Error
 }
 
 // DeleteConversation deletes a conversation record
@@ -0,0 +1,120 @@
+package model
+
+import (
+	"strings"
+)
+
+// ConversationExportJob 会话批量导出任务，导出结果为存储中的 zip 文件
+type ConversationExportJob struct {
+	ID                int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid               int64  `json:"eid" gorm:"not null;index"`
+	CreatedBy         int64  `json:"created_by" gorm:"not null;index"`
+	UserID            int64  `json:"user_id" gorm:"default:0"`                    // 筛选：会话所属用户，0 为不限
+	AgentID           int64  `json:"agent_id" gorm:"default:0"`                   // 筛选：智能体，0 为不限
+	StartTime         int64  `json:"start_time" gorm:"default:0"`                 // 筛选：会话创建时间起（毫秒）
+	EndTime           int64  `json:"end_time" gorm:"default:0"`                   // 筛选：会话创建时间止（毫秒）
+	Formats           string `json:"formats" gorm:"type:varchar(100);default:''"` // 导出格式，逗号分隔：markdown,json,html
+	Status            int    `json:"status" gorm:"default:0"`
+	ConversationCount int64  `json:"conversation_count" gorm:"default:0"`
+	FileKey           string `json:"-" gorm:"type:varchar(512);default:''"`
+	FileName          string `json:"file_name" gorm:"type:varchar(255);default:''"`
+	FileSize          int64  `json:"file_size" gorm:"default:0"`
+	ErrorMessage      string `json:"error_message" gorm:"type:text"`
+	FinishedTime      int64  `json:"finished_time" gorm:"default:0"`
+	BaseModel
+}
+
+const (
+	ConversationExportStatusPending    = 0
+	ConversationExportStatusProcessing = 1
+	ConversationExportStatusCompleted  = 2
+	ConversationExportStatusFailed     = 3
+)
+
+// 导出格式
+const (
+	ConversationExportFormatMarkdown = "markdown"
+	ConversationExportFormatJSON     = "json"
+	ConversationExportFormatHTML     = "html"
+)
+
+// IsValidConversationExportFormat 判断导出格式是否支持
+func IsValidConversationExportFormat(format string) bool {
+	switch format {
+	case ConversationExportFormatMarkdown, ConversationExportFormatJSON, ConversationExportFormatHTML:
+		return true
+	}
+	return false
+}
+
+// GetFormats 返回任务的导出格式列表，未指定时默认为 json
+func (job *ConversationExportJob) GetFormats() []string {
+	formats := make([]string, 0)
+	for _, format := range strings.Split(job.Formats, ",") {
+		format = strings.TrimSpace(format)
+		if format != "" {
+			formats = append(formats, format)
+		}
+	}
+	if len(formats) == 0 {
+		formats = append(formats, ConversationExportFormatJSON)
+	}
+	return formats
+}
+
+func CreateConversationExportJob(job *ConversationExportJob) error {
+	return DB.Create(job).Error
+}
+
+func UpdateConversationExportJob(job *ConversationExportJob) error {
+	return DB.Save(job).Error
+}
+
+func GetConversationExportJob(eid int64, id int64) (*ConversationExportJob, error) {
+	var job ConversationExportJob
+	err := DB.Where("eid = ? AND id = ?", eid, id).First(&job).Error
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetConversationExportJobs 分页获取导出任务，createdBy 为 0 时返回企业内所有任务
+func GetConversationExportJobs(eid int64, createdBy int64, offset, limit int) (count int64, jobs []*ConversationExportJob, err error) {
+	query := DB.Model(&ConversationExportJob{}).Where("eid = ?", eid)
+	if createdBy > 0 {
+		query = query.Where("created_by = ?", createdBy)
+	}
+	if err = query.Count(&count).Error; err != nil {
+		return 0, nil, err
+	}
+	if limit == 0 {
+		limit = 10
+	}
+	err = query.Order("id DESC").Offset(offset).Limit(limit).Find(&jobs).Error
+	return count, jobs, err
+}
+
+func DeleteConversationExportJob(eid int64, id int64) error {
+	return DB.Where("eid = ? AND id = ?", eid, id).Delete(&ConversationExportJob{}).Error
+}
+
+// FindConversationsForExport 按导出任务的筛选条件分批获取会话，lastID 为上一批最后一条会话ID
+func FindConversationsForExport(job *ConversationExportJob, lastID int64, limit int) ([]*Conversation, error) {
+	query := DB.Where("eid = ? AND conversation_id > ?", job.Eid, lastID)
+	if job.UserID > 0 {
+		query = query.Where("user_id = ?", job.UserID)
+	}
+	if job.AgentID > 0 {
+		query = query.Where("agent_id = ?", job.AgentID)
+	}
+	if job.StartTime > 0 {
+		query = query.Where("created_time >= ?", job.StartTime)
+	}
+	if job.EndTime > 0 {
+		query = query.Where("created_time <= ?", job.EndTime)
+	}
+	var conversations []*Conversation
+	err := query.Order("conversation_id ASC").Limit(limit).Find(&conversations).Error
+	return conversations, err
+}
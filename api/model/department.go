@@ -10,8 +10,12 @@ import (
 
 // Department source constants
 const (
-	DepartmentFromBackend = 0 // Created from Backend
-	DepartmentFromWecom   = 1 // Imported from WecomChat
+	DepartmentFromBackend  = 0 // Created from Backend
+	DepartmentFromWecom    = 1 // Imported from WecomChat
+	DepartmentFromSCIM     = 2 // Provisioned as a SCIM 2.0 group, bindvalue is the externalId
+	DepartmentFromLDAP     = 4 // Synced from LDAP organizational units, bindvalue is the OU DN
+	DepartmentFromDingTalk = 5 // Synced from DingTalk, bindvalue is the dept_id
+	DepartmentFromFeishu   = 6 // Synced from Feishu (Lark), bindvalue is the open_department_id
 )
 
 // Department status constants
@@ -361,6 +365,11 @@ func DeleteDepartment(eid int64, did int64, deleteChildren bool) error {
 		return fmt.Errorf("failed to delete member-department relations: %w", err)
 	}
 
+	if err := tx.Where("eid = ? AND subject_type = ? AND subject_id = ?", eid, RoleSubjectDepartment, did).Delete(&RoleAssignment{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	if deleteChildren && len(childDepts) > 0 {
 		var childDIDs []int64
 		for _, child := range childDepts {
@@ -372,6 +381,11 @@ func DeleteDepartment(eid int64, did int64, deleteChildren bool) error {
 			return fmt.Errorf("failed to delete child member-department relations: %w", err)
 		}
 
+		if err := tx.Where("eid = ? AND subject_type = ? AND subject_id IN ?", eid, RoleSubjectDepartment, childDIDs).Delete(&RoleAssignment{}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+
 		if err := tx.Where("eid = ? AND did IN ?", eid, childDIDs).Delete(&Department{}).Error; err != nil {
 			tx.Rollback()
 			return err
@@ -15,13 +15,23 @@ type EnterpriseConfig struct {
 }
 
 const (
-	EnterpriseConfigTypeSMTP   = "smtp"
-	EnterpriseConfigTypeMobile = "mobile"
+	EnterpriseConfigTypeSMTP     = "smtp"
+	EnterpriseConfigTypeMobile   = "mobile"
+	EnterpriseConfigTypeOIDC     = "oidc"
+	EnterpriseConfigTypeLDAP     = "ldap"
+	EnterpriseConfigTypeDingTalk = "dingtalk"
+	EnterpriseConfigTypeFeishu   = "feishu"
+	EnterpriseConfigTypeSecurity = "security"
 )
 
 var EnterpriseConfigTypes = []string{
 	EnterpriseConfigTypeSMTP,
 	EnterpriseConfigTypeMobile,
+	EnterpriseConfigTypeOIDC,
+	EnterpriseConfigTypeLDAP,
+	EnterpriseConfigTypeDingTalk,
+	EnterpriseConfigTypeFeishu,
+	EnterpriseConfigTypeSecurity,
 }
 
 // 根据 type 获取 content 默认值
@@ -31,6 +41,18 @@ func GetEnterpriseConfigDefaultContent(configType string) (string, error) {
 		return `{"smtp_host":"","smtp_username":"","smtp_port":"","smtp_password":"","smtp_from":"","smtp_is_ssl":true,"smtp_to":""}`, nil
 	case EnterpriseConfigTypeMobile:
 		return `{}`, nil
+	case EnterpriseConfigTypeOIDC:
+		return `{"providers":[]}`, nil
+	case EnterpriseConfigTypeLDAP:
+		return `{"url":"","bind_dn":"","bind_password":"","base_dn":"","sync_interval":0}`, nil
+	case EnterpriseConfigTypeDingTalk:
+		return `{"app_key":"","app_secret":"","auto_create_user":false}`, nil
+	case EnterpriseConfigTypeFeishu:
+		return `{"app_id":"","app_secret":"","auto_create_user":false}`, nil
+	case EnterpriseConfigTypeSecurity:
+		return `{"two_factor_required_for_admins":false,"max_sessions_per_user":0,` +
+			`"password_policy":{"min_length":8,"require_uppercase":false,"require_lowercase":false,"require_digit":false,"require_symbol":false,"expire_days":0,"history_count":0},` +
+			`"lockout":{"max_failures":5,"lock_minutes":5,"max_lock_minutes":1440,"captcha_after":3}}`, nil
 	default:
 		return "", fmt.Errorf("config type %s not found", configType)
 	}
@@ -114,6 +114,16 @@ func UpdateGroup(group *Group) error {
 		Updates(group).Error
 }
 
+// GetGroupByName 按名称查找企业内指定类型的分组
+func GetGroupByName(eid int64, groupType int64, name string) (*Group, error) {
+	var group Group
+	err := DB.Where("eid = ? AND group_type = ? AND group_name = ?", eid, groupType, name).First(&group).Error
+	if err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
 func GetGroupByID(groupID int64) (*Group, error) {
 	var group Group
 	// 执行查询操作
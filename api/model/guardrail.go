@@ -0,0 +1,202 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var ErrGuardrailPolicyNotFound = errors.New("guardrail policy not found")
+
+// 规则类型
+const (
+	GuardrailRuleKeyword = "keyword"
+	GuardrailRuleRegex   = "regex"
+	GuardrailRulePII     = "pii"
+	// GuardrailRuleModeration 审核模型判定，仅出现在违规记录中
+	GuardrailRuleModeration = "moderation"
+)
+
+// 命中规则后的处理方式
+const (
+	GuardrailActionBlock = "block"
+	GuardrailActionMask  = "mask"
+	GuardrailActionWarn  = "warn"
+)
+
+// 规则作用的阶段
+const (
+	GuardrailScopeInput  = "input"
+	GuardrailScopeOutput = "output"
+	GuardrailScopeBoth   = "both"
+)
+
+// 内置的个人信息检测项
+const (
+	GuardrailPIIIDCard   = "id_card"
+	GuardrailPIIMobile   = "mobile"
+	GuardrailPIIEmail    = "email"
+	GuardrailPIIBankCard = "bank_card"
+)
+
+// GuardrailRule 一条护栏规则：Type 为 keyword 时使用 Words，regex 时使用 Pattern，pii 时使用 PII
+type GuardrailRule struct {
+	Name    string   `json:"name" example:"竞品名称"`
+	Type    string   `json:"type" example:"keyword"`
+	Words   []string `json:"words,omitempty"`
+	Pattern string   `json:"pattern,omitempty"`
+	PII     string   `json:"pii,omitempty" example:"mobile"`
+	Action  string   `json:"action" example:"mask"`
+	Scope   string   `json:"scope" example:"both"`
+}
+
+// GuardrailModeration 通过渠道调用审核模型检查用户输入，Action 仅支持 block 与 warn
+type GuardrailModeration struct {
+	ChannelID int64  `json:"channel_id"`
+	Model     string `json:"model"`
+	Action    string `json:"action" example:"block"`
+}
+
+// GuardrailConfig 护栏策略内容
+type GuardrailConfig struct {
+	Rules      []GuardrailRule      `json:"rules"`
+	Moderation *GuardrailModeration `json:"moderation,omitempty"`
+	// BlockMessage 拦截时返回给用户的提示，为空时使用默认提示
+	BlockMessage string `json:"block_message"`
+}
+
+// GuardrailPolicy 护栏策略，AgentID 为 0 表示企业级策略，对企业内所有智能体生效
+type GuardrailPolicy struct {
+	ID      int64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid     int64 `json:"eid" gorm:"not null;uniqueIndex:idx_guardrail_policy"`
+	AgentID int64 `json:"agent_id" gorm:"not null;default:0;uniqueIndex:idx_guardrail_policy"`
+	Enabled bool  `json:"enabled" gorm:"not null;default:false"`
+	// Config 策略内容 JSON
+	Config          string          `json:"-" gorm:"type:text"`
+	GuardrailConfig GuardrailConfig `json:"config" gorm:"-"`
+	UpdatedBy       int64           `json:"updated_by" gorm:"not null;default:0"`
+	BaseModel
+}
+
+func (GuardrailPolicy) TableName() string {
+	return "guardrail_policies"
+}
+
+func (p *GuardrailPolicy) AfterFind(tx *gorm.DB) error {
+	if p.Config == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(p.Config), &p.GuardrailConfig)
+}
+
+func (p *GuardrailPolicy) BeforeSave(tx *gorm.DB) error {
+	if p.GuardrailConfig.Rules == nil {
+		p.GuardrailConfig.Rules = make([]GuardrailRule, 0)
+	}
+	data, err := json.Marshal(p.GuardrailConfig)
+	if err != nil {
+		return err
+	}
+	p.Config = string(data)
+	return nil
+}
+
+// GuardrailViolation 护栏命中记录。Excerpt 为脱敏后的命中片段，不保存原始个人信息
+type GuardrailViolation struct {
+	ID             int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid            int64  `json:"eid" gorm:"not null;index"`
+	AgentID        int64  `json:"agent_id" gorm:"not null;default:0;index"`
+	UserID         int64  `json:"user_id" gorm:"not null;default:0"`
+	ConversationID int64  `json:"conversation_id" gorm:"not null;default:0"`
+	Stage          string `json:"stage" gorm:"type:varchar(20);not null" example:"input"`
+	RuleName       string `json:"rule_name" gorm:"type:varchar(100);not null;default:''"`
+	RuleType       string `json:"rule_type" gorm:"type:varchar(20);not null" example:"pii"`
+	Action         string `json:"action" gorm:"type:varchar(20);not null" example:"mask"`
+	Excerpt        string `json:"excerpt" gorm:"type:varchar(255);not null;default:''"`
+	BaseModel
+}
+
+func (GuardrailViolation) TableName() string {
+	return "guardrail_violations"
+}
+
+// GetGuardrailPolicy 获取企业级（agentID 为 0）或智能体的护栏策略
+func GetGuardrailPolicy(eid, agentID int64) (*GuardrailPolicy, error) {
+	var policy GuardrailPolicy
+	err := DB.Where("eid = ? AND agent_id = ?", eid, agentID).First(&policy).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrGuardrailPolicyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// GetEnabledGuardrailPolicies 对智能体生效的策略：企业级策略在前，智能体策略在后
+func GetEnabledGuardrailPolicies(eid, agentID int64) ([]*GuardrailPolicy, error) {
+	policies := make([]*GuardrailPolicy, 0)
+	err := DB.Where("eid = ? AND agent_id IN ? AND enabled = ?", eid, []int64{0, agentID}, true).
+		Order("agent_id").Find(&policies).Error
+	return policies, err
+}
+
+// SaveGuardrailPolicy 按企业与智能体新增或更新策略
+func SaveGuardrailPolicy(policy *GuardrailPolicy) error {
+	existing, err := GetGuardrailPolicy(policy.Eid, policy.AgentID)
+	if err != nil && !errors.Is(err, ErrGuardrailPolicyNotFound) {
+		return err
+	}
+	if existing != nil {
+		policy.ID = existing.ID
+		policy.CreatedTime = existing.CreatedTime
+	}
+	return DB.Save(policy).Error
+}
+
+func DeleteGuardrailPolicy(db *gorm.DB, eid, agentID int64) error {
+	return db.Where("eid = ? AND agent_id = ?", eid, agentID).Delete(&GuardrailPolicy{}).Error
+}
+
+func CreateGuardrailViolations(violations []*GuardrailViolation) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	return DB.Create(&violations).Error
+}
+
+func GetGuardrailViolationsByConditions(eid, agentID, userID int64, stage, action string, startTime, endTime int64, offset, limit int) ([]*GuardrailViolation, int64, error) {
+	query := DB.Model(&GuardrailViolation{}).Where("eid = ?", eid)
+
+	if agentID > 0 {
+		query = query.Where("agent_id = ?", agentID)
+	}
+	if userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if stage != "" {
+		query = query.Where("stage = ?", stage)
+	}
+	if action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if startTime > 0 {
+		query = query.Where("created_time >= ?", startTime)
+	}
+	if endTime > 0 {
+		query = query.Where("created_time <= ?", endTime)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	violations := make([]*GuardrailViolation, 0)
+	if err := query.Offset(offset).Limit(limit).Order("id DESC").Find(&violations).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return violations, total, nil
+}
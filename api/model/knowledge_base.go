@@ -0,0 +1,257 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// KnowledgeBase 知识库
+type KnowledgeBase struct {
+	ID             int64   `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid            int64   `json:"eid" gorm:"not null;index"`
+	Name           string  `json:"name" gorm:"type:varchar(255);not null"`
+	Description    string  `json:"description" gorm:"type:text"`
+	EmbeddingModel string  `json:"embedding_model" gorm:"type:varchar(255);not null"`
+	RerankModel    string  `json:"rerank_model" gorm:"type:varchar(255);default:''"`
+	ChunkSize      int     `json:"chunk_size" gorm:"default:500"`
+	ChunkOverlap   int     `json:"chunk_overlap" gorm:"default:50"`
+	TopK           int     `json:"top_k" gorm:"default:5"`
+	ScoreThreshold float64 `json:"score_threshold" gorm:"default:0"`
+	DocumentCount  int64   `json:"document_count" gorm:"-"`
+	CreatedBy      int64   `json:"created_by" gorm:"not null;default:0"`
+	BaseModel
+}
+
+// KnowledgeDocument 知识库文档，对应一个 UploadFile
+type KnowledgeDocument struct {
+	ID              int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid             int64  `json:"eid" gorm:"not null;index"`
+	KnowledgeBaseID int64  `json:"knowledge_base_id" gorm:"not null;index"`
+	FileID          int64  `json:"file_id" gorm:"not null;index"`
+	FileName        string `json:"file_name" gorm:"type:varchar(512);default:''"`
+	Status          int    `json:"status" gorm:"default:0"`
+	ChunkCount      int    `json:"chunk_count" gorm:"default:0"`
+	TotalTokens     int    `json:"total_tokens" gorm:"default:0"`
+	ErrorMessage    string `json:"error_message" gorm:"type:text"`
+	BaseModel
+}
+
+// KnowledgeChunk 文档分块，embedding 以 JSON 数组存储，供进程内向量索引加载
+type KnowledgeChunk struct {
+	ID              int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid             int64  `json:"eid" gorm:"not null;index"`
+	KnowledgeBaseID int64  `json:"knowledge_base_id" gorm:"not null;index"`
+	DocumentID      int64  `json:"document_id" gorm:"not null;index"`
+	Seq             int    `json:"seq" gorm:"default:0"`
+	Content         string `json:"content" gorm:"type:text"`
+	Embedding       string `json:"-" gorm:"type:longtext"`
+	TokenCount      int    `json:"token_count" gorm:"default:0"`
+	BaseModel
+}
+
+// AgentKnowledgeBase 智能体与知识库的绑定关系
+type AgentKnowledgeBase struct {
+	ID              int64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid             int64 `json:"eid" gorm:"not null;index"`
+	AgentID         int64 `json:"agent_id" gorm:"not null;index:idx_agent_kb,unique"`
+	KnowledgeBaseID int64 `json:"knowledge_base_id" gorm:"not null;index:idx_agent_kb,unique"`
+	BaseModel
+}
+
+const (
+	KnowledgeDocumentStatusPending    = 0
+	KnowledgeDocumentStatusProcessing = 1
+	KnowledgeDocumentStatusCompleted  = 2
+	KnowledgeDocumentStatusFailed     = 3
+)
+
+// KnowledgeCitation 写入 Message.Citations 的引用信息
+type KnowledgeCitation struct {
+	KnowledgeBaseID int64   `json:"knowledge_base_id"`
+	DocumentID      int64   `json:"document_id"`
+	ChunkID         int64   `json:"chunk_id"`
+	FileName        string  `json:"file_name"`
+	Score           float64 `json:"score"`
+	Content         string  `json:"content"`
+}
+
+func (kb *KnowledgeBase) Create() error {
+	if kb.Eid == 0 {
+		return errors.New("eid is empty")
+	}
+	var count int64
+	DB.Model(&KnowledgeBase{}).Where("eid = ? AND name = ?", kb.Eid, kb.Name).Count(&count)
+	if count > 0 {
+		return errors.New("name already exists")
+	}
+	return DB.Create(kb).Error
+}
+
+func (kb *KnowledgeBase) Update() error {
+	return DB.Save(kb).Error
+}
+
+// Delete 删除知识库及其文档、分块和智能体绑定
+func (kb *KnowledgeBase) Delete() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("knowledge_base_id = ?", kb.ID).Delete(&KnowledgeChunk{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("knowledge_base_id = ?", kb.ID).Delete(&KnowledgeDocument{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("knowledge_base_id = ?", kb.ID).Delete(&AgentKnowledgeBase{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(kb).Error
+	})
+}
+
+func (kb *KnowledgeBase) LoadDocumentCount() error {
+	return DB.Model(&KnowledgeDocument{}).Where("knowledge_base_id = ?", kb.ID).Count(&kb.DocumentCount).Error
+}
+
+func GetKnowledgeBaseByID(eid int64, id int64) (*KnowledgeBase, error) {
+	var kb KnowledgeBase
+	err := DB.Where("eid = ? AND id = ?", eid, id).First(&kb).Error
+	if err != nil {
+		return nil, err
+	}
+	return &kb, nil
+}
+
+func GetKnowledgeBaseList(eid int64, keyword string, offset, limit int) (count int64, kbs []*KnowledgeBase, err error) {
+	db := DB.Model(&KnowledgeBase{}).Where("eid = ?", eid)
+	if keyword != "" {
+		db = db.Where("name LIKE ?", "%"+keyword+"%")
+	}
+	if err = db.Count(&count).Error; err != nil {
+		return 0, nil, err
+	}
+	if limit == 0 {
+		limit = 10
+	}
+	err = db.Offset(offset).Limit(limit).Order("id DESC").Find(&kbs).Error
+	return count, kbs, err
+}
+
+func CreateKnowledgeDocument(doc *KnowledgeDocument) error {
+	return DB.Create(doc).Error
+}
+
+func UpdateKnowledgeDocument(doc *KnowledgeDocument) error {
+	return DB.Save(doc).Error
+}
+
+func GetKnowledgeDocumentByID(eid int64, id int64) (*KnowledgeDocument, error) {
+	var doc KnowledgeDocument
+	err := DB.Where("eid = ? AND id = ?", eid, id).First(&doc).Error
+	if err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+func GetKnowledgeDocuments(eid int64, knowledgeBaseID int64, offset, limit int) (count int64, docs []*KnowledgeDocument, err error) {
+	db := DB.Model(&KnowledgeDocument{}).Where("eid = ? AND knowledge_base_id = ?", eid, knowledgeBaseID)
+	if err = db.Count(&count).Error; err != nil {
+		return 0, nil, err
+	}
+	if limit == 0 {
+		limit = 10
+	}
+	err = db.Offset(offset).Limit(limit).Order("id DESC").Find(&docs).Error
+	return count, docs, err
+}
+
+func GetKnowledgeDocumentsByIDs(ids []int64) ([]*KnowledgeDocument, error) {
+	var docs []*KnowledgeDocument
+	if len(ids) == 0 {
+		return docs, nil
+	}
+	err := DB.Where("id IN ?", ids).Find(&docs).Error
+	return docs, err
+}
+
+// DeleteKnowledgeDocument 删除文档及其分块
+func DeleteKnowledgeDocument(eid int64, id int64) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("eid = ? AND document_id = ?", eid, id).Delete(&KnowledgeChunk{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("eid = ? AND id = ?", eid, id).Delete(&KnowledgeDocument{}).Error
+	})
+}
+
+// ReplaceKnowledgeChunks 用新分块替换文档的全部分块
+func ReplaceKnowledgeChunks(documentID int64, chunks []*KnowledgeChunk) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("document_id = ?", documentID).Delete(&KnowledgeChunk{}).Error; err != nil {
+			return err
+		}
+		if len(chunks) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(chunks, 100).Error
+	})
+}
+
+func GetKnowledgeChunksByKnowledgeBaseID(knowledgeBaseID int64) ([]*KnowledgeChunk, error) {
+	var chunks []*KnowledgeChunk
+	err := DB.Where("knowledge_base_id = ?", knowledgeBaseID).Order("document_id ASC, seq ASC").Find(&chunks).Error
+	return chunks, err
+}
+
+func GetKnowledgeChunksByIDs(ids []int64) ([]*KnowledgeChunk, error) {
+	var chunks []*KnowledgeChunk
+	if len(ids) == 0 {
+		return chunks, nil
+	}
+	err := DB.Where("id IN ?", ids).Find(&chunks).Error
+	return chunks, err
+}
+
+// GetEmbeddingVector 解析分块的 embedding
+func (chunk *KnowledgeChunk) GetEmbeddingVector() ([]float32, error) {
+	var vector []float32
+	if chunk.Embedding == "" {
+		return vector, nil
+	}
+	err := json.Unmarshal([]byte(chunk.Embedding), &vector)
+	return vector, err
+}
+
+// GetAgentKnowledgeBaseIDs 获取智能体绑定的知识库ID
+func GetAgentKnowledgeBaseIDs(eid int64, agentID int64) ([]int64, error) {
+	ids := make([]int64, 0)
+	err := DB.Model(&AgentKnowledgeBase{}).
+		Where("eid = ? AND agent_id = ?", eid, agentID).
+		Pluck("knowledge_base_id", &ids).Error
+	return ids, err
+}
+
+// SetAgentKnowledgeBases 覆盖式设置智能体绑定的知识库
+func SetAgentKnowledgeBases(eid int64, agentID int64, knowledgeBaseIDs []int64) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("eid = ? AND agent_id = ?", eid, agentID).Delete(&AgentKnowledgeBase{}).Error; err != nil {
+			return err
+		}
+		seen := make(map[int64]bool)
+		for _, id := range knowledgeBaseIDs {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if err := tx.Create(&AgentKnowledgeBase{
+				Eid:             eid,
+				AgentID:         agentID,
+				KnowledgeBaseID: id,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,95 @@
+package model
+
+import (
+	"time"
+
+	"github.com/53AI/53AIHub/common/logger"
+)
+
+// LoginAudit 登录审计日志，记录每次登录的结果与失败原因
+type LoginAudit struct {
+	ID          int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid         int64  `json:"eid" gorm:"not null;index"`
+	UserID      int64  `json:"user_id" gorm:"not null;default:0;index"`                    // 找不到账号时为 0
+	Account     string `json:"account" gorm:"type:varchar(255);not null;default:''"`       // 登录时提交的账号
+	Method      string `json:"method" gorm:"type:varchar(20);not null" example:"password"` // 登录方式
+	Result      string `json:"result" gorm:"type:varchar(20);not null;index" example:"failure"`
+	Reason      string `json:"reason" gorm:"type:varchar(255);not null;default:''" example:"invalid_password"`
+	IP          string `json:"ip" gorm:"type:varchar(64)" example:"127.0.0.1"`
+	UserAgent   string `json:"user_agent" gorm:"type:varchar(512)"`
+	Device      string `json:"device" gorm:"type:varchar(100)" example:"Chrome on Windows"`
+	CreatedTime int64  `json:"created_time" gorm:"not null;index"`
+}
+
+const (
+	LoginMethodPassword  = "password"
+	LoginMethodSms       = "sms"
+	LoginMethodTwoFactor = "two_factor"
+	LoginMethodOIDC      = "oidc"
+	LoginMethodDingTalk  = "dingtalk"
+	LoginMethodFeishu    = "feishu"
+
+	LoginResultSuccess = "success"
+	LoginResultFailure = "failure"
+	LoginResultLocked  = "locked" // 账号锁定期间的登录尝试
+
+	LoginReasonUserNotFound    = "user_not_found"
+	LoginReasonInvalidPassword = "invalid_password"
+	LoginReasonInvalidCode     = "invalid_code"
+	LoginReasonUserDisabled    = "user_disabled"
+	LoginReasonAccountLocked   = "account_locked"
+	LoginReasonPasswordExpired = "password_expired"
+)
+
+func (LoginAudit) TableName() string {
+	return "login_audits"
+}
+
+// CreateLoginAudit 异步写入登录审计日志，不阻塞登录流程
+func CreateLoginAudit(audit *LoginAudit) {
+	if reason := []rune(audit.Reason); len(reason) > 255 {
+		audit.Reason = string(reason[:255])
+	}
+	go func() {
+		audit.CreatedTime = time.Now().UnixMilli()
+		if err := DB.Create(audit).Error; err != nil {
+			logger.SysErrorf("create login audit failed: %v", err)
+		}
+	}()
+}
+
+// GetLoginAuditsByConditions 按条件分页查询登录审计日志
+func GetLoginAuditsByConditions(eid, userID int64, account, method, result string, startTime, endTime int64, offset, limit int) ([]*LoginAudit, int64, error) {
+	query := DB.Model(&LoginAudit{}).Where("eid = ?", eid)
+
+	if userID > 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if account != "" {
+		query = query.Where("account LIKE ?", "%"+account+"%")
+	}
+	if method != "" {
+		query = query.Where("method = ?", method)
+	}
+	if result != "" {
+		query = query.Where("result = ?", result)
+	}
+	if startTime > 0 {
+		query = query.Where("created_time >= ?", startTime)
+	}
+	if endTime > 0 {
+		query = query.Where("created_time <= ?", endTime)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	audits := make([]*LoginAudit, 0)
+	if err := query.Offset(offset).Limit(limit).Order("id DESC").Find(&audits).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return audits, total, nil
+}
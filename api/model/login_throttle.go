@@ -0,0 +1,45 @@
+package model
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// LoginThrottle 账号的连续登录失败计数与锁定状态，按企业和登录账号记录，
+// 账号不存在时同样计数，避免通过锁定行为探测账号是否存在
+type LoginThrottle struct {
+	ID             int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid            int64  `json:"eid" gorm:"not null;uniqueIndex:idx_login_throttle_account"`
+	Account        string `json:"account" gorm:"type:varchar(255);not null;uniqueIndex:idx_login_throttle_account"`
+	Failures       int    `json:"failures" gorm:"not null;default:0"`   // 本轮连续失败次数，锁定后清零
+	LockCount      int    `json:"lock_count" gorm:"not null;default:0"` // 已锁定次数，决定下一次锁定时长
+	LockedUntil    int64  `json:"locked_until" gorm:"not null;default:0"`
+	LastFailedTime int64  `json:"last_failed_time" gorm:"not null;default:0"`
+	BaseModel
+}
+
+func (LoginThrottle) TableName() string {
+	return "login_throttles"
+}
+
+// GetLoginThrottle 不存在时返回 nil
+func GetLoginThrottle(eid int64, account string) (*LoginThrottle, error) {
+	var throttle LoginThrottle
+	err := DB.Where("eid = ? AND account = ?", eid, account).First(&throttle).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &throttle, nil
+}
+
+func SaveLoginThrottle(throttle *LoginThrottle) error {
+	return DB.Save(throttle).Error
+}
+
+func DeleteLoginThrottle(eid int64, account string) error {
+	return DB.Where("eid = ? AND account = ?", eid, account).Delete(&LoginThrottle{}).Error
+}
@@ -39,6 +39,8 @@ func InitDB() {
 	} else {
 		logger.SysLog("database migration skipped (MIGRATE_DB_ENABLED=false)")
 	}
+
+	InitMessageSearch()
 }
 
 func GetDbConn() (*gorm.DB, error) {
@@ -160,5 +162,58 @@ func migrateDB() error {
 	if err := DB.AutoMigrate(&ShareRecord{}); err != nil {
 		return err
 	}
+	if err := backfillShareRecordUserID(); err != nil {
+		return err
+	}
+	if err := DB.AutoMigrate(
+		&KnowledgeBase{},
+		&KnowledgeDocument{},
+		&KnowledgeChunk{},
+		&AgentKnowledgeBase{},
+	); err != nil {
+		return err
+	}
+	if err := DB.AutoMigrate(&MessageFeedback{}); err != nil {
+		return err
+	}
+	if err := DB.AutoMigrate(&ConversationExportJob{}); err != nil {
+		return err
+	}
+	if err := DB.AutoMigrate(&MessageSearchDocument{}); err != nil {
+		return err
+	}
+	if err := DB.AutoMigrate(&ScimToken{}); err != nil {
+		return err
+	}
+	if err := DB.AutoMigrate(&UserTwoFactor{}, &UserSession{}); err != nil {
+		return err
+	}
+	if err := DB.AutoMigrate(&LoginThrottle{}, &LoginAudit{}, &PasswordHistory{}); err != nil {
+		return err
+	}
+	if err := DB.AutoMigrate(&Role{}, &RoleAssignment{}); err != nil {
+		return err
+	}
+	if err := DB.AutoMigrate(&AgentVersion{}, &AgentTemplate{}); err != nil {
+		return err
+	}
+	if err := DB.AutoMigrate(&AgentExperiment{}, &AgentExperimentVariant{}); err != nil {
+		return err
+	}
+	if err := DB.AutoMigrate(&EvalDataset{}, &EvalCase{}, &EvalRun{}, &EvalResult{}); err != nil {
+		return err
+	}
+	if err := DB.AutoMigrate(&PromptRevision{}, &PromptUsage{}); err != nil {
+		return err
+	}
+	if err := DB.AutoMigrate(&GuardrailPolicy{}, &GuardrailViolation{}); err != nil {
+		return err
+	}
+	if err := DB.AutoMigrate(&Schedule{}, &ScheduleRun{}); err != nil {
+		return err
+	}
+	if err := DB.AutoMigrate(&AgentEmbed{}, &EmbedSession{}); err != nil {
+		return err
+	}
 	return nil
 }
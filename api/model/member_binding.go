@@ -10,8 +10,13 @@ import (
 
 // Member binding source constants
 const (
-	MemberBindingSourceNone   = 0 // No binding
-	MemberBindingSourceWeChat = 1 // WeChat Enterprise
+	MemberBindingSourceNone     = 0 // No binding
+	MemberBindingSourceWeChat   = 1 // WeChat Enterprise
+	MemberBindingSourceSCIM     = 2 // SCIM 2.0 provisioning, bindvalue is the externalId or the user ID
+	MemberBindingSourceOIDC     = 3 // OpenID Connect SSO, bindvalue is issuer|sub
+	MemberBindingSourceLDAP     = 4 // LDAP / Active Directory, bindvalue is the entry ID
+	MemberBindingSourceDingTalk = 5 // DingTalk, bindvalue is the userid
+	MemberBindingSourceFeishu   = 6 // Feishu (Lark), bindvalue is the open_id
 )
 
 // Member binding status constants
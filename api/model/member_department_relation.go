@@ -9,8 +9,12 @@ import (
 
 // MemberDepartmentRelation source constants
 const (
-	MemberDepartmentRelationFromBackend = 0 // Created from Backend
-	MemberDepartmentRelationFromWeChat  = 1 // Imported from Enterprise WeChat
+	MemberDepartmentRelationFromBackend  = 0 // Created from Backend
+	MemberDepartmentRelationFromWeChat   = 1 // Imported from Enterprise WeChat
+	MemberDepartmentRelationFromSCIM     = 2 // Provisioned through SCIM 2.0 group membership, bid is the SCIM member binding ID
+	MemberDepartmentRelationFromLDAP     = 4 // Synced from LDAP, bid is the LDAP member binding ID
+	MemberDepartmentRelationFromDingTalk = 5 // Synced from DingTalk, bid is the DingTalk member binding ID
+	MemberDepartmentRelationFromFeishu   = 6 // Synced from Feishu (Lark), bid is the Feishu member binding ID
 )
 
 // MemberDepartmentRelation represents the relationship between members and departments
@@ -1,6 +1,12 @@
 package model
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
 
 type Message struct {
 	ID                int64  `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
@@ -22,6 +28,16 @@ type Message struct {
 	IsStream          bool   `json:"is_stream" gorm:"default:false"`
 	QuotaContent      string `json:"quota_content" gorm:"default:''"`
 	AgentCustomConfig string `json:"agent_custom_config" gorm:"default:''"`
+	Citations         string `json:"citations" gorm:"column:citations;type:text"`
+	ParentID          int64  `json:"parent_id" gorm:"column:parent_id;default:0;index"`
+	IsBranchRoot      bool   `json:"-" gorm:"-"` // 为 true 时作为新的根节点创建（编辑第一条提问），不自动挂到当前分支末尾
+	// AgentVersion 产生该消息的智能体版本，AgentVersionDraft 表示草稿
+	AgentVersion int `json:"agent_version" gorm:"default:0"`
+	// ExperimentID、VariantID 产生该消息的 A/B 实验方案，未参与实验时为 0
+	ExperimentID int64 `json:"experiment_id" gorm:"default:0;index"`
+	VariantID    int64 `json:"variant_id" gorm:"default:0"`
+	// CallerID 编排智能体调用子智能体产生的消息指向编排智能体的回答消息，这类消息不属于任何会话
+	CallerID int64 `json:"caller_id" gorm:"column:caller_message_id;default:0;index"`
 	BaseModel
 }
 
@@ -76,9 +92,95 @@ func (m *Message) ParseWorkflowOutput() (map[string]interface{}, error) {
 	return outputData, nil
 }
 
+// GetQueryContent 提取用户最后一次提问的文本和附件ID，请求消息无法解析时返回原始内容
+func (m *Message) GetQueryContent() (string, []int64) {
+	messages, err := m.ParseChatMessage()
+	if err != nil {
+		return m.Message, nil
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if role, _ := messages[i]["role"].(string); role != "user" {
+			continue
+		}
+		return ParseMessageContent(messages[i]["content"])
+	}
+	return "", nil
+}
+
+// ParseMessageContent 解析消息内容中的文本和附件ID
+// 支持纯文本、object_string（[{"type":"image","content":"file_id:1"}]）和 OpenAI 多模态格式
+func ParseMessageContent(content interface{}) (string, []int64) {
+	texts := make([]string, 0)
+	fileIDs := make([]int64, 0)
+	switch v := content.(type) {
+	case string:
+		var parts []ObjectStringContent
+		if err := json.Unmarshal([]byte(v), &parts); err != nil || len(parts) == 0 {
+			return v, nil
+		}
+		for _, part := range parts {
+			if part.Type == "text" {
+				texts = append(texts, part.Content)
+				continue
+			}
+			if fileID, ok := ParseFileIDContent(part.Content); ok {
+				fileIDs = append(fileIDs, fileID)
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			part, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if partType, _ := part["type"].(string); partType == "text" {
+				if text, ok := part["text"].(string); ok {
+					texts = append(texts, text)
+				}
+			}
+		}
+	}
+	return strings.Join(texts, "\n"), fileIDs
+}
+
+// ParseFileIDContent 解析 file_id: 格式的文件引用
+func ParseFileIDContent(content string) (int64, bool) {
+	if !strings.HasPrefix(content, "file_id:") {
+		return 0, false
+	}
+	fileID, err := strconv.ParseInt(strings.TrimPrefix(content, "file_id:"), 10, 64)
+	return fileID, err == nil
+}
+
 // CreateMessage creates a new message record
+// 属于会话的消息未指定 ParentID 时挂到会话当前分支末尾，创建后成为会话的当前分支
 func CreateMessage(message *Message) error {
-	return DB.Create(message).Error
+	if message.ConversationID == 0 {
+		if err := DB.Create(message).Error; err != nil {
+			return err
+		}
+		indexMessageQuietly(message)
+		return nil
+	}
+	// 挂到当前分支末尾与更新当前分支放在同一事务中，避免写入一半时会话指向不完整的分支
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		activeMessageID, err := ensureConversationMessageTree(tx, message.Eid, message.ConversationID)
+		if err != nil {
+			return err
+		}
+		if message.ParentID == 0 && !message.IsBranchRoot {
+			message.ParentID = activeMessageID
+		}
+		if err := tx.Create(message).Error; err != nil {
+			return err
+		}
+		return setConversationActiveMessage(tx, message.Eid, message.ConversationID, message.ID)
+	})
+	if err != nil {
+		return err
+	}
+	indexMessageQuietly(message)
+	return nil
 }
 
 // GetMessageByID retrieves a message by ID
@@ -143,27 +245,51 @@ func GetMessagesByUserAndAgent(eid int64, userID int64, agentID int64, keyword s
 
 // UpdateMessage updates a message record
 func UpdateMessage(message *Message) error {
-	return DB.Save(message).Error
+	if err := DB.Save(message).Error; err != nil {
+		return err
+	}
+	indexMessageQuietly(message)
+	return nil
 }
 
 // DeleteMessage deletes a message by ID
 func DeleteMessage(eid int64, id int64) error {
-	return DB.Where("eid = ? AND id = ?", eid, id).Delete(&Message{}).Error
+	if err := DB.Where("eid = ? AND id = ?", eid, id).Delete(&Message{}).Error; err != nil {
+		return err
+	}
+	deleteMessageSearchDocuments("eid = ? AND message_id = ?", eid, id)
+	return nil
 }
 
 // DeleteMessagesByUserID deletes all messages for a user
 func DeleteMessagesByUserID(eid int64, userID int64) error {
-	return DB.Where("eid = ? AND user_id = ?", eid, userID).Delete(&Message{}).Error
+	if err := DB.Where("eid = ? AND user_id = ?", eid, userID).Delete(&Message{}).Error; err != nil {
+		return err
+	}
+	deleteMessageSearchDocuments("eid = ? AND user_id = ?", eid, userID)
+	return nil
 }
 
 // DeleteMessagesByAgentID deletes all messages for a specific agent
 func DeleteMessagesByAgentID(eid int64, agentID int64) error {
-	return DB.Where("eid = ? AND agent_id = ?", eid, agentID).Delete(&Message{}).Error
+	if err := DB.Where("eid = ? AND agent_id = ?", eid, agentID).Delete(&Message{}).Error; err != nil {
+		return err
+	}
+	deleteMessageSearchDocuments("eid = ? AND agent_id = ?", eid, agentID)
+	return nil
 }
 
 // GetMessagesByConversationID retrieves conversation messages by conversation ID
 func GetMessagesByConversationID(eid int64, conversationID int64, keyword string, limit int, offset int) (count int64, messages []*Message, err error) {
 	query := DB.Model(&Message{}).Where("eid =? AND conversation_id =?", eid, conversationID)
+	// 只返回当前分支上的消息
+	pathIDs, err := GetActiveMessagePath(eid, conversationID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if pathIDs != nil {
+		query = query.Where("id IN ?", pathIDs)
+	}
 	if keyword != "" {
 		query = query.Where("message LIKE? OR answer LIKE?", "%"+keyword+"%", "%"+keyword+"%")
 	}
@@ -181,7 +307,7 @@ func GetMessagesByConversationID(eid int64, conversationID int64, keyword string
 		query = query.Offset(offset)
 	}
 
-	err = query.Find(&messages).Order("created_time DESC").Error
+	err = query.Order("created_time DESC").Find(&messages).Error
 	if err != nil {
 		return 0, nil, err
 	}
@@ -191,6 +317,14 @@ func GetMessagesByConversationID(eid int64, conversationID int64, keyword string
 // GetMessagesByConversationIDWithDirection retrieves conversation messages by conversation ID with direction control
 func GetMessagesByConversationIDWithDirection(eid int64, conversationID int64, keyword string, limit, offset int, direction string) (count int64, messages []*Message, err error) {
 	query := DB.Model(&Message{}).Where("eid =? AND conversation_id =?", eid, conversationID)
+	// 只返回当前分支上的消息
+	pathIDs, err := GetActiveMessagePath(eid, conversationID)
+	if err != nil {
+		return 0, nil, err
+	}
+	if pathIDs != nil {
+		query = query.Where("id IN ?", pathIDs)
+	}
 	if keyword != "" {
 		query = query.Where("message LIKE? OR answer LIKE?", "%"+keyword+"%", "%"+keyword+"%")
 	}
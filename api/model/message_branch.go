@@ -0,0 +1,206 @@
+package model
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// messageNode 消息树节点，仅包含构建分支所需的字段
+type messageNode struct {
+	ID       int64
+	ParentID int64
+}
+
+func loadMessageNodes(db *gorm.DB, eid int64, conversationID int64) ([]messageNode, error) {
+	var nodes []messageNode
+	err := db.Model(&Message{}).
+		Select("id, parent_id").
+		Where("eid = ? AND conversation_id = ?", eid, conversationID).
+		Order("id ASC").
+		Scan(&nodes).Error
+	return nodes, err
+}
+
+func getConversationActiveMessageID(db *gorm.DB, eid int64, conversationID int64) (int64, error) {
+	var activeMessageID int64
+	err := db.Model(&Conversation{}).
+		Where("eid = ? AND conversation_id = ?", eid, conversationID).
+		Pluck("active_message_id", &activeMessageID).Error
+	return activeMessageID, err
+}
+
+// SetConversationActiveMessage 设置会话当前分支的末尾消息
+func SetConversationActiveMessage(eid int64, conversationID int64, messageID int64) error {
+	return setConversationActiveMessage(DB, eid, conversationID, messageID)
+}
+
+func setConversationActiveMessage(db *gorm.DB, eid int64, conversationID int64, messageID int64) error {
+	return db.Model(&Conversation{}).
+		Where("eid = ? AND conversation_id = ?", eid, conversationID).
+		UpdateColumn("active_message_id", messageID).Error
+}
+
+// EnsureConversationMessageTree 确保会话消息已组织为树并返回当前分支末尾消息ID
+// 分支功能上线前的会话消息没有 parent_id，按创建顺序串成一条链
+func EnsureConversationMessageTree(eid int64, conversationID int64) (int64, error) {
+	return ensureConversationMessageTree(DB, eid, conversationID)
+}
+
+func ensureConversationMessageTree(db *gorm.DB, eid int64, conversationID int64) (int64, error) {
+	activeMessageID, err := getConversationActiveMessageID(db, eid, conversationID)
+	if err != nil || activeMessageID != 0 {
+		return activeMessageID, err
+	}
+	nodes, err := loadMessageNodes(db, eid, conversationID)
+	if err != nil || len(nodes) == 0 {
+		return 0, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for i := 1; i < len(nodes); i++ {
+			if err := tx.Model(&Message{}).Where("id = ?", nodes[i].ID).
+				UpdateColumn("parent_id", nodes[i-1].ID).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&Conversation{}).
+			Where("eid = ? AND conversation_id = ?", eid, conversationID).
+			UpdateColumn("active_message_id", nodes[len(nodes)-1].ID).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return nodes[len(nodes)-1].ID, nil
+}
+
+// GetActiveMessagePath 获取会话当前分支上的消息ID（从根到末尾）
+// 返回 nil 表示会话尚未启用分支，所有消息都在同一条链上
+func GetActiveMessagePath(eid int64, conversationID int64) ([]int64, error) {
+	activeMessageID, err := getConversationActiveMessageID(DB, eid, conversationID)
+	if err != nil || activeMessageID == 0 {
+		return nil, err
+	}
+	nodes, err := loadMessageNodes(DB, eid, conversationID)
+	if err != nil || len(nodes) == 0 {
+		return nil, err
+	}
+	parents := make(map[int64]int64, len(nodes))
+	for _, node := range nodes {
+		parents[node.ID] = node.ParentID
+	}
+	// 当前分支末尾的消息已不存在时退回最新的分支：最后创建的消息没有子节点，一定是叶子
+	if _, ok := parents[activeMessageID]; !ok {
+		activeMessageID = nodes[len(nodes)-1].ID
+	}
+
+	path := make([]int64, 0)
+	visited := make(map[int64]bool)
+	for id := activeMessageID; id != 0 && !visited[id]; id = parents[id] {
+		if _, ok := parents[id]; !ok {
+			break
+		}
+		visited[id] = true
+		path = append(path, id)
+	}
+	// 反转为从根到末尾
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, nil
+}
+
+// GetConversationSiblingIDs 获取会话中指定父节点下的子消息ID，key 为 parent_id
+// 只查询当前页消息的父节点，不加载整棵消息树
+func GetConversationSiblingIDs(eid int64, conversationID int64, parentIDs []int64) (map[int64][]int64, error) {
+	siblings := make(map[int64][]int64)
+	if len(parentIDs) == 0 {
+		return siblings, nil
+	}
+	var nodes []messageNode
+	err := DB.Model(&Message{}).
+		Select("id, parent_id").
+		Where("eid = ? AND conversation_id = ? AND parent_id IN ?", eid, conversationID, parentIDs).
+		Order("id ASC").
+		Scan(&nodes).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		siblings[node.ParentID] = append(siblings[node.ParentID], node.ID)
+	}
+	return siblings, nil
+}
+
+// GetMessageSiblings 获取与指定消息同一父节点的所有消息（包含自身），按创建顺序排列
+func GetMessageSiblings(eid int64, conversationID int64, messageID int64) ([]*Message, error) {
+	if _, err := EnsureConversationMessageTree(eid, conversationID); err != nil {
+		return nil, err
+	}
+	message, err := GetMessageByID(eid, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if message.ConversationID != conversationID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var messages []*Message
+	err = DB.Where("eid = ? AND conversation_id = ? AND parent_id = ?", eid, conversationID, message.ParentID).
+		Order("id ASC").Find(&messages).Error
+	return messages, err
+}
+
+// SwitchConversationBranch 切换到包含指定消息的分支
+// 从该消息向下沿最新的子消息走到末尾，作为会话的当前分支
+func SwitchConversationBranch(eid int64, conversationID int64, messageID int64) (int64, error) {
+	if _, err := EnsureConversationMessageTree(eid, conversationID); err != nil {
+		return 0, err
+	}
+	nodes, err := loadMessageNodes(DB, eid, conversationID)
+	if err != nil {
+		return 0, err
+	}
+	latestChild := make(map[int64]int64)
+	found := false
+	for _, node := range nodes {
+		if node.ID == messageID {
+			found = true
+		}
+		// 节点按 ID 升序，后出现的子节点更新
+		latestChild[node.ParentID] = node.ID
+	}
+	if !found {
+		return 0, errors.New("message not found in conversation")
+	}
+
+	leaf := messageID
+	visited := map[int64]bool{leaf: true}
+	for {
+		child, ok := latestChild[leaf]
+		if !ok || visited[child] {
+			break
+		}
+		visited[child] = true
+		leaf = child
+	}
+	if err := SetConversationActiveMessage(eid, conversationID, leaf); err != nil {
+		return 0, err
+	}
+	return leaf, nil
+}
+
+// ResolveBranchParent 根据重新生成或编辑的目标消息确定新消息的父节点
+// 新消息与目标消息互为兄弟节点；目标为第一条消息时返回 isRoot=true
+func ResolveBranchParent(eid int64, conversationID int64, targetMessageID int64) (parentID int64, isRoot bool, err error) {
+	if _, err = EnsureConversationMessageTree(eid, conversationID); err != nil {
+		return 0, false, err
+	}
+	target, err := GetMessageByID(eid, targetMessageID)
+	if err != nil {
+		return 0, false, err
+	}
+	if target.ConversationID != conversationID {
+		return 0, false, errors.New("message not found in conversation")
+	}
+	return target.ParentID, target.ParentID == 0, nil
+}
@@ -0,0 +1,171 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func setupBranchDB(t *testing.T) *Conversation {
+	setupTestDB(t, &Conversation{}, &Message{}, &MessageSearchDocument{})
+	conversation := &Conversation{Eid: 1, UserID: 7, AgentID: 1, Title: "分支"}
+	if err := CreateConversation(conversation); err != nil {
+		t.Fatal(err)
+	}
+	return conversation
+}
+
+// newBranchMessage 按调用顺序递增 created_time，分页排序不依赖写入时的时钟精度
+func newBranchMessage(conversation *Conversation, text string) *Message {
+	var count int64
+	DB.Model(&Message{}).Count(&count)
+	message := &Message{Eid: 1, UserID: 7, AgentID: 1, ConversationID: conversation.ConversationID, Message: text}
+	message.CreatedTime = count + 1
+	return message
+}
+
+func createBranchMessage(t *testing.T, conversation *Conversation, text string, parentID int64, isRoot bool) int64 {
+	t.Helper()
+	message := newBranchMessage(conversation, text)
+	message.ParentID = parentID
+	message.IsBranchRoot = isRoot
+	if err := CreateMessage(message); err != nil {
+		t.Fatal(err)
+	}
+	return message.ID
+}
+
+func activePath(t *testing.T, conversation *Conversation) []int64 {
+	t.Helper()
+	path, err := GetActiveMessagePath(1, conversation.ConversationID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func pageIDs(t *testing.T, conversation *Conversation, limit, offset int, direction string) (int64, []int64) {
+	t.Helper()
+	count, messages, err := GetMessagesByConversationIDWithDirection(1, conversation.ConversationID, "", limit, offset, direction)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int64, 0, len(messages))
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+	}
+	return count, ids
+}
+
+func TestMessageBranches(t *testing.T) {
+	conversation := setupBranchDB(t)
+	// 分支功能上线前的消息没有 parent_id，写入新消息时按创建顺序串成链
+	legacy := []*Message{newBranchMessage(conversation, "q1")}
+	DB.Create(legacy[0])
+	legacy = append(legacy, newBranchMessage(conversation, "q2"))
+	DB.Create(legacy[1])
+	m1, m2 := legacy[0].ID, legacy[1].ID
+	m3 := createBranchMessage(t, conversation, "q3", 0, false)
+	if path := activePath(t, conversation); !reflect.DeepEqual(path, []int64{m1, m2, m3}) {
+		t.Fatalf("legacy path = %v", path)
+	}
+
+	// 编辑 q2 产生与其同级的新分支
+	parentID, isRoot, err := ResolveBranchParent(1, conversation.ConversationID, m2)
+	if err != nil || parentID != m1 || isRoot {
+		t.Fatalf("ResolveBranchParent(q2) = %d, %v, %v", parentID, isRoot, err)
+	}
+	m4 := createBranchMessage(t, conversation, "q2 edited", parentID, isRoot)
+	m5 := createBranchMessage(t, conversation, "q4", 0, false)
+	if path := activePath(t, conversation); !reflect.DeepEqual(path, []int64{m1, m4, m5}) {
+		t.Fatalf("edited path = %v", path)
+	}
+
+	// 编辑第一条提问产生新的根节点
+	parentID, isRoot, err = ResolveBranchParent(1, conversation.ConversationID, m1)
+	if err != nil || parentID != 0 || !isRoot {
+		t.Fatalf("ResolveBranchParent(q1) = %d, %v, %v", parentID, isRoot, err)
+	}
+	m6 := createBranchMessage(t, conversation, "q1 edited", parentID, isRoot)
+	if path := activePath(t, conversation); !reflect.DeepEqual(path, []int64{m6}) {
+		t.Fatalf("root branch path = %v", path)
+	}
+
+	siblings, err := GetConversationSiblingIDs(1, conversation.ConversationID, []int64{0, m1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int64][]int64{0: {m1, m6}, m1: {m2, m4}}
+	if !reflect.DeepEqual(siblings, want) {
+		t.Fatalf("siblings = %v, want %v", siblings, want)
+	}
+
+	// 切换分支时沿最新的子消息走到末尾
+	for _, tc := range []struct {
+		target, leaf int64
+		path         []int64
+	}{
+		{m2, m3, []int64{m1, m2, m3}},
+		{m1, m5, []int64{m1, m4, m5}},
+		{m6, m6, []int64{m6}},
+		{m4, m5, []int64{m1, m4, m5}},
+	} {
+		leaf, err := SwitchConversationBranch(1, conversation.ConversationID, tc.target)
+		if err != nil || leaf != tc.leaf {
+			t.Fatalf("switch to %d = %d, %v; want %d", tc.target, leaf, err, tc.leaf)
+		}
+		if path := activePath(t, conversation); !reflect.DeepEqual(path, tc.path) {
+			t.Fatalf("switch to %d: path = %v, want %v", tc.target, path, tc.path)
+		}
+	}
+	if _, err := SwitchConversationBranch(1, conversation.ConversationID, m6+100); err == nil {
+		t.Fatal("switching to a message outside the conversation should fail")
+	}
+
+	// 分页只返回当前分支上的消息
+	for _, tc := range []struct {
+		limit, offset int
+		direction     string
+		want          []int64
+	}{
+		{2, 0, "desc", []int64{m5, m4}},
+		{2, 2, "desc", []int64{m1}},
+		{2, 0, "asc", []int64{m1, m4}},
+	} {
+		count, ids := pageIDs(t, conversation, tc.limit, tc.offset, tc.direction)
+		if count != 3 || !reflect.DeepEqual(ids, tc.want) {
+			t.Errorf("page %d/%d %s = %d %v, want 3 %v", tc.offset, tc.limit, tc.direction, count, ids, tc.want)
+		}
+	}
+
+	// 当前分支末尾的消息被删除后退回最新的分支，会话不会显示为空
+	DB.Delete(&Message{}, m5)
+	if path := activePath(t, conversation); !reflect.DeepEqual(path, []int64{m6}) {
+		t.Fatalf("path after deleting active message = %v", path)
+	}
+	if count, ids := pageIDs(t, conversation, 10, 0, "asc"); count != 1 || !reflect.DeepEqual(ids, []int64{m6}) {
+		t.Fatalf("page after deleting active message = %d %v", count, ids)
+	}
+}
+
+func TestCreateMessageRollsBackBranchUpdate(t *testing.T) {
+	conversation := setupBranchDB(t)
+	first := newBranchMessage(conversation, "q1")
+	DB.Create(first)
+	second := newBranchMessage(conversation, "q2")
+	DB.Create(second)
+
+	// 主键冲突导致写入失败时，旧会话的链化和当前分支都不应生效
+	duplicate := newBranchMessage(conversation, "q3")
+	duplicate.ID = first.ID
+	if err := CreateMessage(duplicate); err == nil {
+		t.Fatal("duplicate message should fail")
+	}
+	activeMessageID, err := getConversationActiveMessageID(DB, 1, conversation.ConversationID)
+	if err != nil || activeMessageID != 0 {
+		t.Fatalf("active message = %d, %v; want 0", activeMessageID, err)
+	}
+	reloaded, err := GetMessageByID(1, second.ID)
+	if err != nil || reloaded.ParentID != 0 {
+		t.Fatalf("legacy message parent = %+v, %v; want 0", reloaded, err)
+	}
+}
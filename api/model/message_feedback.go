@@ -0,0 +1,267 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MessageFeedback 用户对对话回答的评价，每个用户对每条消息仅保留一条
+type MessageFeedback struct {
+	ID             int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid            int64  `json:"eid" gorm:"not null;index"`
+	MessageID      int64  `json:"message_id" gorm:"not null;uniqueIndex:idx_feedback_message_user"`
+	UserID         int64  `json:"user_id" gorm:"not null;uniqueIndex:idx_feedback_message_user"`
+	ConversationID int64  `json:"conversation_id" gorm:"not null;index"`
+	AgentID        int64  `json:"agent_id" gorm:"not null;index"`
+	ModelName      string `json:"model_name" gorm:"type:varchar(255);default:''"`
+	ChannelID      int64  `json:"channel_id" gorm:"default:0"`
+	Rating         int    `json:"rating" gorm:"not null;index"`
+	Reason         string `json:"reason" gorm:"type:varchar(50);default:''"`
+	Comment        string `json:"comment" gorm:"type:text"`
+	BaseModel
+}
+
+// 评价值
+const (
+	FeedbackRatingDown = -1 // 点踩
+	FeedbackRatingUp   = 1  // 点赞
+)
+
+// 评价原因分类
+const (
+	FeedbackReasonInaccurate = "inaccurate" // 内容不准确
+	FeedbackReasonIrrelevant = "irrelevant" // 答非所问
+	FeedbackReasonIncomplete = "incomplete" // 回答不完整
+	FeedbackReasonHarmful    = "harmful"    // 有害或不安全
+	FeedbackReasonFormat     = "format"     // 格式问题
+	FeedbackReasonHelpful    = "helpful"    // 有帮助
+	FeedbackReasonOther      = "other"      // 其他
+)
+
+var feedbackReasons = map[string]bool{
+	FeedbackReasonInaccurate: true,
+	FeedbackReasonIrrelevant: true,
+	FeedbackReasonIncomplete: true,
+	FeedbackReasonHarmful:    true,
+	FeedbackReasonFormat:     true,
+	FeedbackReasonHelpful:    true,
+	FeedbackReasonOther:      true,
+}
+
+// 统计维度
+const (
+	FeedbackDimensionAgent   = "agent"
+	FeedbackDimensionModel   = "model"
+	FeedbackDimensionChannel = "channel"
+)
+
+const dayMilliseconds = 24 * 60 * 60 * 1000
+
+// FeedbackStat 按维度和日期聚合的评价统计
+type FeedbackStat struct {
+	Dimension string `json:"dimension"` // 维度值：智能体ID、模型名或渠道ID
+	Day       int64  `json:"day"`       // 当天 0 点（UTC）毫秒时间戳
+	UpCount   int64  `json:"up_count"`
+	DownCount int64  `json:"down_count"`
+}
+
+// LowRatedConversation 差评会话
+type LowRatedConversation struct {
+	ConversationID   int64         `json:"conversation_id"`
+	AgentID          int64         `json:"agent_id"`
+	DownCount        int64         `json:"down_count"`
+	LastFeedbackTime int64         `json:"last_feedback_time"`
+	Conversation     *Conversation `json:"conversation" gorm:"-"`
+}
+
+// FeedbackFilter 评价查询条件
+type FeedbackFilter struct {
+	AgentID   int64
+	ModelName string
+	ChannelID int64
+	Rating    int
+	Reason    string
+	StartTime int64
+	EndTime   int64
+}
+
+func IsValidFeedbackReason(reason string) bool {
+	return reason == "" || feedbackReasons[reason]
+}
+
+func (f *MessageFeedback) Validate() error {
+	if f.Rating != FeedbackRatingUp && f.Rating != FeedbackRatingDown {
+		return errors.New("invalid rating")
+	}
+	if !IsValidFeedbackReason(f.Reason) {
+		return errors.New("invalid reason")
+	}
+	return nil
+}
+
+// SaveMessageFeedback 新增或覆盖用户对消息的评价
+func SaveMessageFeedback(feedback *MessageFeedback) error {
+	if err := feedback.Validate(); err != nil {
+		return err
+	}
+	return DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "message_id"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rating", "reason", "comment", "updated_time"}),
+	}).Create(feedback).Error
+}
+
+func GetMessageFeedback(eid int64, userID int64, messageID int64) (*MessageFeedback, error) {
+	var feedback MessageFeedback
+	err := DB.Where("eid = ? AND user_id = ? AND message_id = ?", eid, userID, messageID).First(&feedback).Error
+	if err != nil {
+		return nil, err
+	}
+	return &feedback, nil
+}
+
+// GetUserFeedbackMap 获取用户对一批消息的评价，key 为消息ID
+func GetUserFeedbackMap(eid int64, userID int64, messageIDs []int64) (map[int64]*MessageFeedback, error) {
+	result := make(map[int64]*MessageFeedback)
+	if len(messageIDs) == 0 {
+		return result, nil
+	}
+	var feedbacks []*MessageFeedback
+	err := DB.Where("eid = ? AND user_id = ? AND message_id IN ?", eid, userID, messageIDs).Find(&feedbacks).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range feedbacks {
+		result[f.MessageID] = f
+	}
+	return result, nil
+}
+
+func DeleteMessageFeedback(eid int64, userID int64, messageID int64) error {
+	return DB.Where("eid = ? AND user_id = ? AND message_id = ?", eid, userID, messageID).Delete(&MessageFeedback{}).Error
+}
+
+func (filter *FeedbackFilter) apply(db *gorm.DB) *gorm.DB {
+	if filter.AgentID > 0 {
+		db = db.Where("agent_id = ?", filter.AgentID)
+	}
+	if filter.ModelName != "" {
+		db = db.Where("model_name = ?", filter.ModelName)
+	}
+	if filter.ChannelID > 0 {
+		db = db.Where("channel_id = ?", filter.ChannelID)
+	}
+	if filter.Rating != 0 {
+		db = db.Where("rating = ?", filter.Rating)
+	}
+	if filter.Reason != "" {
+		db = db.Where("reason = ?", filter.Reason)
+	}
+	if filter.StartTime > 0 {
+		db = db.Where("created_time >= ?", filter.StartTime)
+	}
+	if filter.EndTime > 0 {
+		db = db.Where("created_time <= ?", filter.EndTime)
+	}
+	return db
+}
+
+// GetFeedbackStats 按维度（agent/model/channel）和天聚合评价数量
+func GetFeedbackStats(eid int64, dimension string, filter FeedbackFilter) ([]*FeedbackStat, error) {
+	var column string
+	switch dimension {
+	case FeedbackDimensionAgent, "":
+		column = "agent_id"
+	case FeedbackDimensionModel:
+		column = "model_name"
+	case FeedbackDimensionChannel:
+		column = "channel_id"
+	default:
+		return nil, errors.New("invalid dimension")
+	}
+
+	stats := make([]*FeedbackStat, 0)
+	dayExpr := fmt.Sprintf("(created_time - created_time %% %d)", dayMilliseconds)
+	db := filter.apply(DB.Model(&MessageFeedback{}).Where("eid = ?", eid))
+	err := db.Select(
+		column+" AS dimension, "+dayExpr+" AS day, "+
+			"SUM(CASE WHEN rating = ? THEN 1 ELSE 0 END) AS up_count, "+
+			"SUM(CASE WHEN rating = ? THEN 1 ELSE 0 END) AS down_count",
+		FeedbackRatingUp, FeedbackRatingDown,
+	).Group(column + ", " + dayExpr).Order("day ASC").Scan(&stats).Error
+	return stats, err
+}
+
+// GetLowRatedConversations 获取收到差评的会话，按最近差评时间倒序
+func GetLowRatedConversations(eid int64, filter FeedbackFilter, offset, limit int) (count int64, list []*LowRatedConversation, err error) {
+	filter.Rating = FeedbackRatingDown
+	db := filter.apply(DB.Model(&MessageFeedback{}).Where("eid = ?", eid))
+
+	err = db.Session(&gorm.Session{}).Distinct("conversation_id").Count(&count).Error
+	if err != nil {
+		return 0, nil, err
+	}
+	if limit == 0 {
+		limit = 10
+	}
+	list = make([]*LowRatedConversation, 0)
+	err = db.Select("conversation_id, agent_id, COUNT(*) AS down_count, MAX(created_time) AS last_feedback_time").
+		Group("conversation_id, agent_id").
+		Order("last_feedback_time DESC").
+		Offset(offset).Limit(limit).
+		Scan(&list).Error
+	if err != nil {
+		return 0, nil, err
+	}
+
+	for _, item := range list {
+		if conversation, err := AdminGetConversationByID(eid, item.ConversationID); err == nil {
+			item.Conversation = conversation
+		}
+	}
+	return count, list, nil
+}
+
+// IterateFeedbackWithMessages 按ID顺序分批遍历评价及其对应消息，用于导出
+func IterateFeedbackWithMessages(eid int64, filter FeedbackFilter, batchSize int, fn func(feedback *MessageFeedback, message *Message) error) error {
+	if batchSize <= 0 {
+		batchSize = 200
+	}
+	var lastID int64
+	for {
+		var feedbacks []*MessageFeedback
+		db := filter.apply(DB.Model(&MessageFeedback{}).Where("eid = ? AND id > ?", eid, lastID))
+		if err := db.Order("id ASC").Limit(batchSize).Find(&feedbacks).Error; err != nil {
+			return err
+		}
+		if len(feedbacks) == 0 {
+			return nil
+		}
+
+		messageIDs := make([]int64, 0, len(feedbacks))
+		for _, f := range feedbacks {
+			messageIDs = append(messageIDs, f.MessageID)
+		}
+		var messages []*Message
+		if err := DB.Where("eid = ? AND id IN ?", eid, messageIDs).Find(&messages).Error; err != nil {
+			return err
+		}
+		messageMap := make(map[int64]*Message, len(messages))
+		for _, m := range messages {
+			messageMap[m.ID] = m
+		}
+
+		for _, f := range feedbacks {
+			lastID = f.ID
+			message, ok := messageMap[f.MessageID]
+			if !ok {
+				continue
+			}
+			if err := fn(f, message); err != nil {
+				return err
+			}
+		}
+	}
+}
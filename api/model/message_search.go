@@ -0,0 +1,440 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/config"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MessageSearchDocument 消息全文检索文档，保存从请求和回答中提取的纯文本
+// 由 CreateMessage、UpdateMessage 写入，FTS5 / FULLTEXT 索引建立在该表上
+type MessageSearchDocument struct {
+	MessageID      int64  `json:"message_id" gorm:"primaryKey;autoIncrement:false"`
+	Eid            int64  `json:"eid" gorm:"not null;index:idx_message_search_user"`
+	UserID         int64  `json:"user_id" gorm:"not null;index:idx_message_search_user"`
+	AgentID        int64  `json:"agent_id" gorm:"not null;index"`
+	ConversationID int64  `json:"conversation_id" gorm:"not null;index"`
+	Query          string `json:"query" gorm:"type:text"`
+	Answer         string `json:"answer" gorm:"type:text"`
+	CreatedTime    int64  `json:"created_time" gorm:"not null;index"`
+}
+
+// MessageSearchParams 消息检索条件
+type MessageSearchParams struct {
+	Eid       int64
+	UserID    int64 // 0 为企业内全部用户
+	AgentID   int64
+	StartTime int64
+	EndTime   int64
+	Keyword   string
+	Offset    int
+	Limit     int
+}
+
+// MessageSearchResult 消息检索结果
+type MessageSearchResult struct {
+	MessageID         int64   `json:"message_id"`
+	ConversationID    int64   `json:"conversation_id"`
+	ConversationTitle string  `json:"conversation_title"`
+	AgentID           int64   `json:"agent_id"`
+	UserID            int64   `json:"user_id"`
+	Query             string  `json:"query"`
+	Answer            string  `json:"answer"`
+	QueryHighlight    string  `json:"query_highlight"`  // 命中片段，关键词以 <em> 标记，内容已做 HTML 转义
+	AnswerHighlight   string  `json:"answer_highlight"` // 命中片段，关键词以 <em> 标记，内容已做 HTML 转义
+	Score             float64 `json:"score"`
+	CreatedTime       int64   `json:"created_time"`
+}
+
+// messageSearchEngine 全文检索引擎，索引数据统一写入 MessageSearchDocument，引擎负责建立索引和查询
+type messageSearchEngine interface {
+	Name() string
+	Setup(db *gorm.DB) error
+	// Match 为查询增加关键词条件并返回相关度表达式，返回 false 表示关键词不适用于该引擎
+	Match(query *gorm.DB, keywords []string) (matched *gorm.DB, score clause.Expr, ok bool)
+}
+
+var searchEngine messageSearchEngine = likeSearchEngine{}
+
+// InitMessageSearch 按数据库驱动选择检索引擎并建立索引，失败时退化为 LIKE 检索
+func InitMessageSearch() {
+	var engine messageSearchEngine = likeSearchEngine{}
+	if config.MessageSearchEngine == "auto" {
+		switch {
+		case config.UsingSQLite:
+			engine = sqliteFTSSearchEngine{}
+		case config.UsingMySQL:
+			engine = mysqlFullTextSearchEngine{}
+		}
+	}
+	if err := engine.Setup(DB); err != nil {
+		logger.SysErrorf("message search engine %s setup failed, fallback to like: %v", engine.Name(), err)
+		engine = likeSearchEngine{}
+	}
+	searchEngine = engine
+	logger.SysLogf("message search engine: %s", engine.Name())
+
+	go func() {
+		if err := BackfillMessageSearchIndex(500); err != nil {
+			logger.SysErrorf("backfill message search index failed: %v", err)
+		}
+	}()
+}
+
+// newMessageSearchDocument 从消息中提取可检索文本
+func newMessageSearchDocument(message *Message) *MessageSearchDocument {
+	var query string
+	if _, err := message.ParseChatMessage(); err == nil {
+		query, _ = message.GetQueryContent()
+	} else if parameters, err := message.ParseWorkflowParameters(); err == nil {
+		query = strings.Join(collectSearchText(parameters, nil), "\n")
+	} else {
+		query = message.Message
+	}
+
+	answer := message.Answer
+	if output, err := message.ParseWorkflowOutput(); err == nil {
+		answer = strings.Join(collectSearchText(output, nil), "\n")
+	}
+
+	return &MessageSearchDocument{
+		MessageID:      message.ID,
+		Eid:            message.Eid,
+		UserID:         message.UserID,
+		AgentID:        message.AgentID,
+		ConversationID: message.ConversationID,
+		Query:          query,
+		Answer:         answer,
+		CreatedTime:    message.CreatedTime,
+	}
+}
+
+// collectSearchText 收集工作流参数或输出中的文本值，文件引用不参与检索
+func collectSearchText(value interface{}, texts []string) []string {
+	switch v := value.(type) {
+	case string:
+		if _, ok := ParseFileIDContent(v); !ok && v != "" {
+			texts = append(texts, v)
+		}
+	case []interface{}:
+		for _, item := range v {
+			texts = collectSearchText(item, texts)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			texts = collectSearchText(v[key], texts)
+		}
+	}
+	return texts
+}
+
+// IndexMessage 写入或更新消息的检索文档
+func IndexMessage(message *Message) error {
+	return indexMessages(DB, []*Message{message})
+}
+
+func indexMessages(db *gorm.DB, messages []*Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	docs := make([]*MessageSearchDocument, 0, len(messages))
+	for _, message := range messages {
+		docs = append(docs, newMessageSearchDocument(message))
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "message_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"query", "answer", "conversation_id"}),
+	}).Create(&docs).Error
+}
+
+// indexMessageQuietly 更新检索文档，失败只记录日志，不影响消息写入
+func indexMessageQuietly(message *Message) {
+	if err := IndexMessage(message); err != nil {
+		logger.SysErrorf("index message %d failed: %v", message.ID, err)
+	}
+}
+
+// BackfillMessageSearchIndex 为检索功能上线前的历史消息建立检索文档
+func BackfillMessageSearchIndex(batchSize int) error {
+	var lastID int64
+	for {
+		var messages []*Message
+		err := DB.Model(&Message{}).
+			Joins("LEFT JOIN message_search_documents d ON d.message_id = messages.id").
+			Where("messages.id > ? AND d.message_id IS NULL", lastID).
+			Order("messages.id ASC").Limit(batchSize).
+			Find(&messages).Error
+		if err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+		if err := indexMessages(DB, messages); err != nil {
+			return err
+		}
+		lastID = messages[len(messages)-1].ID
+	}
+}
+
+// SearchMessages 全文检索消息，多个关键词以空格分隔且需全部命中，按相关度排序
+func SearchMessages(params MessageSearchParams) (count int64, results []*MessageSearchResult, err error) {
+	keywords := splitSearchKeywords(params.Keyword)
+	if len(keywords) == 0 {
+		return 0, nil, errors.New("keyword is required")
+	}
+
+	query := DB.Table("message_search_documents d").
+		Joins("LEFT JOIN conversations c ON c.conversation_id = d.conversation_id").
+		Where("d.eid = ?", params.Eid).
+		// 会话已删除的消息不参与检索
+		Where("(d.conversation_id = 0 OR c.conversation_id IS NOT NULL)")
+	if params.UserID > 0 {
+		query = query.Where("d.user_id = ?", params.UserID)
+	}
+	if params.AgentID > 0 {
+		query = query.Where("d.agent_id = ?", params.AgentID)
+	}
+	if params.StartTime > 0 {
+		query = query.Where("d.created_time >= ?", params.StartTime)
+	}
+	if params.EndTime > 0 {
+		query = query.Where("d.created_time <= ?", params.EndTime)
+	}
+
+	matched, score, ok := searchEngine.Match(query, keywords)
+	if !ok {
+		// 关键词过短等情况下全文索引无法命中，退化为 LIKE
+		matched, score, _ = likeSearchEngine{}.Match(query, keywords)
+	}
+
+	if err = matched.Session(&gorm.Session{}).Count(&count).Error; err != nil {
+		return 0, nil, err
+	}
+	if params.Limit == 0 {
+		params.Limit = 10
+	}
+	results = make([]*MessageSearchResult, 0)
+	err = matched.Select("d.message_id, d.conversation_id, c.title AS conversation_title, d.agent_id, d.user_id, d.query, d.answer, d.created_time, ? AS score", score).
+		Order("score DESC, d.created_time DESC").
+		Offset(params.Offset).Limit(params.Limit).
+		Scan(&results).Error
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, result := range results {
+		result.QueryHighlight = HighlightKeywords(result.Query, keywords, 60)
+		result.AnswerHighlight = HighlightKeywords(result.Answer, keywords, 60)
+	}
+	return count, results, nil
+}
+
+// splitSearchKeywords 按空白拆分关键词并去重
+func splitSearchKeywords(keyword string) []string {
+	seen := make(map[string]bool)
+	keywords := make([]string, 0)
+	for _, word := range strings.Fields(keyword) {
+		lower := strings.ToLower(word)
+		if seen[lower] {
+			continue
+		}
+		seen[lower] = true
+		keywords = append(keywords, word)
+	}
+	return keywords
+}
+
+func minKeywordLength(keywords []string) int {
+	min := -1
+	for _, keyword := range keywords {
+		if n := utf8.RuneCountInString(keyword); min < 0 || n < min {
+			min = n
+		}
+	}
+	return min
+}
+
+// likeSearchEngine 不依赖全文索引的 LIKE 检索，所有关键词都需命中
+type likeSearchEngine struct{}
+
+func (likeSearchEngine) Name() string { return "like" }
+
+func (likeSearchEngine) Setup(db *gorm.DB) error { return nil }
+
+func (likeSearchEngine) Match(query *gorm.DB, keywords []string) (*gorm.DB, clause.Expr, bool) {
+	for _, keyword := range keywords {
+		pattern := "%" + keyword + "%"
+		query = query.Where("(d.query LIKE ? OR d.answer LIKE ?)", pattern, pattern)
+	}
+	return query, gorm.Expr("0"), true
+}
+
+// sqliteFTSSearchEngine SQLite FTS5 检索，使用 trigram 分词以支持中文子串匹配
+// 需要以 sqlite_fts5 构建标签编译 go-sqlite3
+type sqliteFTSSearchEngine struct{}
+
+func (sqliteFTSSearchEngine) Name() string { return "sqlite_fts5" }
+
+func (sqliteFTSSearchEngine) Setup(db *gorm.DB) error {
+	var exists int64
+	if err := db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'message_search_fts'").Scan(&exists).Error; err != nil {
+		return err
+	}
+	if exists > 0 {
+		return nil
+	}
+	statements := []string{
+		`CREATE VIRTUAL TABLE message_search_fts USING fts5(query, answer, content='message_search_documents', content_rowid='message_id', tokenize='trigram')`,
+		`CREATE TRIGGER message_search_documents_ai AFTER INSERT ON message_search_documents BEGIN
+			INSERT INTO message_search_fts(rowid, query, answer) VALUES (new.message_id, new.query, new.answer);
+		END`,
+		`CREATE TRIGGER message_search_documents_ad AFTER DELETE ON message_search_documents BEGIN
+			INSERT INTO message_search_fts(message_search_fts, rowid, query, answer) VALUES ('delete', old.message_id, old.query, old.answer);
+		END`,
+		`CREATE TRIGGER message_search_documents_au AFTER UPDATE ON message_search_documents BEGIN
+			INSERT INTO message_search_fts(message_search_fts, rowid, query, answer) VALUES ('delete', old.message_id, old.query, old.answer);
+			INSERT INTO message_search_fts(rowid, query, answer) VALUES (new.message_id, new.query, new.answer);
+		END`,
+		// 已有检索文档写入索引
+		`INSERT INTO message_search_fts(message_search_fts) VALUES ('rebuild')`,
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (sqliteFTSSearchEngine) Match(query *gorm.DB, keywords []string) (*gorm.DB, clause.Expr, bool) {
+	// trigram 分词要求每个关键词至少 3 个字符
+	if minKeywordLength(keywords) < 3 {
+		return nil, clause.Expr{}, false
+	}
+	terms := make([]string, 0, len(keywords))
+	for _, keyword := range keywords {
+		terms = append(terms, `"`+strings.ReplaceAll(keyword, `"`, `""`)+`"`)
+	}
+	// bm25 越小越相关，取负数使 score 越大越相关
+	return query.Joins("JOIN message_search_fts f ON f.rowid = d.message_id").
+		Where("message_search_fts MATCH ?", strings.Join(terms, " AND ")), gorm.Expr("-bm25(message_search_fts)"), true
+}
+
+// mysqlFullTextSearchEngine MySQL FULLTEXT 检索，使用 ngram 分词以支持中文
+type mysqlFullTextSearchEngine struct{}
+
+const mysqlFullTextIndexName = "idx_message_search_fulltext"
+
+func (mysqlFullTextSearchEngine) Name() string { return "mysql_fulltext" }
+
+func (mysqlFullTextSearchEngine) Setup(db *gorm.DB) error {
+	if db.Migrator().HasIndex(&MessageSearchDocument{}, mysqlFullTextIndexName) {
+		return nil
+	}
+	return db.Exec(fmt.Sprintf("ALTER TABLE message_search_documents ADD FULLTEXT INDEX %s (query, answer) WITH PARSER ngram", mysqlFullTextIndexName)).Error
+}
+
+func (mysqlFullTextSearchEngine) Match(query *gorm.DB, keywords []string) (*gorm.DB, clause.Expr, bool) {
+	// ngram 默认 token 长度为 2，单个字符无法命中
+	if minKeywordLength(keywords) < 2 {
+		return nil, clause.Expr{}, false
+	}
+	terms := make([]string, 0, len(keywords))
+	for _, keyword := range keywords {
+		terms = append(terms, `+"`+strings.ReplaceAll(keyword, `"`, ``)+`"`)
+	}
+	against := strings.Join(terms, " ")
+	return query.Where("MATCH(d.query, d.answer) AGAINST (? IN BOOLEAN MODE)", against),
+		gorm.Expr("MATCH(d.query, d.answer) AGAINST (? IN BOOLEAN MODE)", against), true
+}
+
+// deleteMessageSearchDocuments 删除符合条件的检索文档，失败只记录日志
+func deleteMessageSearchDocuments(query interface{}, args ...interface{}) {
+	if err := DB.Where(query, args...).Delete(&MessageSearchDocument{}).Error; err != nil {
+		logger.SysErrorf("delete message search documents failed: %v", err)
+	}
+}
+
+// HighlightKeywords 截取首个关键词附近 radius 个字符的片段，HTML 转义后以 <em> 标记所有关键词
+func HighlightKeywords(text string, keywords []string, radius int) string {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	patterns := make([][]rune, 0, len(keywords))
+	for _, keyword := range keywords {
+		pattern := []rune(strings.ToLower(keyword))
+		if len(pattern) > 0 {
+			patterns = append(patterns, pattern)
+		}
+	}
+	// 按长度倒序，优先标记较长的关键词
+	sort.Slice(patterns, func(i, j int) bool {
+		return len(patterns[i]) > len(patterns[j])
+	})
+
+	matchAt := func(i int) int {
+		for _, pattern := range patterns {
+			if i+len(pattern) <= len(lower) && string(lower[i:i+len(pattern)]) == string(pattern) {
+				return len(pattern)
+			}
+		}
+		return 0
+	}
+
+	first := -1
+	for i := range lower {
+		if matchAt(i) > 0 {
+			first = i
+			break
+		}
+	}
+	start, end := 0, len(runes)
+	if first >= 0 {
+		start = first - radius
+		if start < 0 {
+			start = 0
+		}
+	}
+	if end > start+radius*2 {
+		end = start + radius*2
+	}
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("...")
+	}
+	for i := start; i < end; {
+		if n := matchAt(i); n > 0 && first >= 0 {
+			if i+n > end {
+				end = i + n
+			}
+			sb.WriteString("<em>" + html.EscapeString(string(runes[i:i+n])) + "</em>")
+			i += n
+			continue
+		}
+		sb.WriteString(html.EscapeString(string(runes[i])))
+		i++
+	}
+	if end < len(runes) {
+		sb.WriteString("...")
+	}
+	return sb.String()
+}
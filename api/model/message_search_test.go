@@ -0,0 +1,218 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+func setupSearchDB(t *testing.T) {
+	setupTestDB(t, &Conversation{}, &MessageSearchDocument{})
+	conversation := &Conversation{ConversationID: 1, Eid: 1, UserID: 7, AgentID: 1, Title: "部署"}
+	if err := DB.Create(conversation).Error; err != nil {
+		t.Fatal(err)
+	}
+	docs := []*MessageSearchDocument{
+		{MessageID: 1, Eid: 1, UserID: 7, AgentID: 1, ConversationID: 1, Query: "如何配置单点登录", Answer: "在后台打开 OIDC 设置", CreatedTime: 1},
+		{MessageID: 2, Eid: 1, UserID: 7, AgentID: 1, ConversationID: 1, Query: "deploy with Docker", Answer: "use docker compose up", CreatedTime: 2},
+		{MessageID: 3, Eid: 1, UserID: 8, AgentID: 2, ConversationID: 0, Query: "docker logs", Answer: "run docker logs to see docker output", CreatedTime: 3},
+		// 会话已删除
+		{MessageID: 4, Eid: 1, UserID: 7, AgentID: 1, ConversationID: 99, Query: "docker orphan", Answer: "", CreatedTime: 4},
+		// 其它企业
+		{MessageID: 5, Eid: 2, UserID: 9, AgentID: 3, ConversationID: 0, Query: "docker", Answer: "", CreatedTime: 5},
+	}
+	if err := DB.Create(&docs).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func searchMessageIDs(t *testing.T, params MessageSearchParams) []int64 {
+	count, results, err := SearchMessages(params)
+	if err != nil {
+		t.Fatalf("search %q: %v", params.Keyword, err)
+	}
+	ids := make([]int64, 0, len(results))
+	for _, result := range results {
+		ids = append(ids, result.MessageID)
+	}
+	if int(count) != len(ids) {
+		t.Fatalf("search %q: count %d, results %v", params.Keyword, count, ids)
+	}
+	return ids
+}
+
+func sameIDs(got []int64, want ...int64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// testCommonSearch 各检索引擎共同的检索行为
+func testCommonSearch(t *testing.T) {
+	if ids := searchMessageIDs(t, MessageSearchParams{Eid: 1, Keyword: "docker"}); len(ids) != 2 {
+		t.Errorf("docker: got %v", ids)
+	}
+	if ids := searchMessageIDs(t, MessageSearchParams{Eid: 1, UserID: 7, Keyword: "docker"}); !sameIDs(ids, 2) {
+		t.Errorf("docker for user 7: got %v", ids)
+	}
+	if ids := searchMessageIDs(t, MessageSearchParams{Eid: 1, AgentID: 2, Keyword: "docker"}); !sameIDs(ids, 3) {
+		t.Errorf("docker for agent 2: got %v", ids)
+	}
+	if ids := searchMessageIDs(t, MessageSearchParams{Eid: 1, Keyword: "docker compose"}); !sameIDs(ids, 2) {
+		t.Errorf("docker compose: got %v", ids)
+	}
+	if ids := searchMessageIDs(t, MessageSearchParams{Eid: 1, StartTime: 3, Keyword: "docker"}); !sameIDs(ids, 3) {
+		t.Errorf("docker since 3: got %v", ids)
+	}
+	// 中文子串
+	_, results, err := SearchMessages(MessageSearchParams{Eid: 1, Keyword: "单点登录"})
+	if err != nil || len(results) != 1 || results[0].ConversationTitle != "部署" ||
+		!strings.Contains(results[0].QueryHighlight, "<em>单点登录</em>") {
+		t.Errorf("单点登录: %+v %v", results, err)
+	}
+	// 过短的关键词退化为 LIKE 检索
+	if ids := searchMessageIDs(t, MessageSearchParams{Eid: 1, Keyword: "后台"}); !sameIDs(ids, 1) {
+		t.Errorf("后台: got %v", ids)
+	}
+	if _, _, err := SearchMessages(MessageSearchParams{Eid: 1, Keyword: "  "}); err == nil {
+		t.Error("empty keyword should be rejected")
+	}
+}
+
+func TestSearchMessagesLike(t *testing.T) {
+	setupSearchDB(t)
+	searchEngine = likeSearchEngine{}
+	testCommonSearch(t)
+}
+
+func TestSearchMessagesSQLiteFTS(t *testing.T) {
+	setupSearchDB(t)
+	engine := sqliteFTSSearchEngine{}
+	if err := engine.Setup(DB); err != nil {
+		t.Skipf("go-sqlite3 built without sqlite_fts5: %v", err)
+	}
+	searchEngine = engine
+	defer func() { searchEngine = likeSearchEngine{} }()
+	testCommonSearch(t)
+
+	// 命中次数多的消息排在前面
+	if ids := searchMessageIDs(t, MessageSearchParams{Eid: 1, Keyword: "docker"}); !sameIDs(ids, 3, 2) {
+		t.Errorf("docker should rank message 3 first: got %v", ids)
+	}
+	// 触发器同步检索文档的写入与更新
+	doc := &MessageSearchDocument{MessageID: 6, Eid: 1, UserID: 7, AgentID: 1, ConversationID: 1, Query: "kubernetes helm", CreatedTime: 6}
+	if err := DB.Create(doc).Error; err != nil {
+		t.Fatal(err)
+	}
+	if ids := searchMessageIDs(t, MessageSearchParams{Eid: 1, Keyword: "helm"}); !sameIDs(ids, 6) {
+		t.Errorf("helm after insert: got %v", ids)
+	}
+	if err := DB.Model(doc).Update("query", "kubernetes operator").Error; err != nil {
+		t.Fatal(err)
+	}
+	if ids := searchMessageIDs(t, MessageSearchParams{Eid: 1, Keyword: "helm"}); len(ids) != 0 {
+		t.Errorf("helm after update: got %v", ids)
+	}
+}
+
+func TestMySQLFullTextMatch(t *testing.T) {
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "root@tcp(127.0.0.1:3306)/hub", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine := mysqlFullTextSearchEngine{}
+	if _, _, ok := engine.Match(db, []string{"单点登录", "库"}); ok {
+		t.Error("single character keywords cannot match ngram tokens")
+	}
+
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		matched, score, ok := engine.Match(tx.Table("message_search_documents d"), []string{"单点", `say "hi"`})
+		if !ok {
+			t.Fatal("keywords should match")
+		}
+		return matched.Select("d.message_id, ? AS score", score).Find(&[]*MessageSearchResult{})
+	})
+	against := `AGAINST ('+"单点" +"say hi"' IN BOOLEAN MODE)`
+	if strings.Count(sql, "MATCH(d.query, d.answer) "+against) != 2 {
+		t.Fatalf("unexpected sql: %s", sql)
+	}
+}
+
+func TestSplitSearchKeywords(t *testing.T) {
+	keywords := splitSearchKeywords("  Docker docker\tcompose  单点 ")
+	if strings.Join(keywords, ",") != "Docker,compose,单点" {
+		t.Fatalf("unexpected keywords: %v", keywords)
+	}
+}
+
+func TestHighlightKeywords(t *testing.T) {
+	for _, tc := range []struct {
+		text     string
+		keywords []string
+		radius   int
+		want     string
+	}{
+		{strings.Repeat("a", 20) + "单点登录" + strings.Repeat("b", 20), []string{"单点登录"}, 5, "...aaaaa<em>单点登录</em>b..."},
+		// 关键词不区分大小写，片段末尾的关键词完整保留，其余内容转义
+		{"Use <Docker> compose", []string{"docker"}, 5, "Use &lt;<em>Docker</em>..."},
+		{"单点登录 单点", []string{"单点", "单点登录"}, 10, "<em>单点登录</em> <em>单点</em>"},
+		{"hello <world>", []string{"xyz"}, 3, "hello ..."},
+	} {
+		if got := HighlightKeywords(tc.text, tc.keywords, tc.radius); got != tc.want {
+			t.Errorf("HighlightKeywords(%q, %v) = %q, want %q", tc.text, tc.keywords, got, tc.want)
+		}
+	}
+}
+
+func TestIndexMessage(t *testing.T) {
+	setupTestDB(t, &Conversation{}, &Message{}, &MessageSearchDocument{})
+	chat := &Message{Eid: 1, UserID: 7, AgentID: 1,
+		Message: `[{"role":"user","content":"[{\"type\":\"text\",\"content\":\"这张发票\"},{\"type\":\"image\",\"content\":\"file_id:3\"}]"}]`,
+		Answer:  "可以报销"}
+	workflow := &Message{Eid: 1, UserID: 7, AgentID: 2,
+		Message: `{"region":"华东","file":"file_id:4","tags":["季度","汇总"]}`,
+		Answer:  `{"summary":"销售额增长","chart":"file_id:5"}`}
+	for _, message := range []*Message{chat, workflow} {
+		if err := CreateMessage(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var docs []*MessageSearchDocument
+	DB.Order("message_id").Find(&docs)
+	if len(docs) != 2 {
+		t.Fatalf("expected 2 search documents, got %d", len(docs))
+	}
+	// 文件引用不参与检索，工作流参数与输出按键名顺序拼接
+	if docs[0].Query != "这张发票" || docs[0].Answer != "可以报销" {
+		t.Errorf("unexpected chat document: %+v", docs[0])
+	}
+	if docs[1].Query != "华东\n季度\n汇总" || docs[1].Answer != "销售额增长" {
+		t.Errorf("unexpected workflow document: %+v", docs[1])
+	}
+
+	// 重新生成回答后更新检索文档
+	chat.Answer = "不能报销"
+	if err := IndexMessage(chat); err != nil {
+		t.Fatal(err)
+	}
+	if ids := searchMessageIDs(t, MessageSearchParams{Eid: 1, Keyword: "不能报销"}); !sameIDs(ids, chat.ID) {
+		t.Errorf("updated answer: got %v", ids)
+	}
+
+	if err := DeleteMessagesByAgentID(1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if ids := searchMessageIDs(t, MessageSearchParams{Eid: 1, Keyword: "销售额"}); len(ids) != 0 {
+		t.Errorf("deleted messages should not be found: %v", ids)
+	}
+}
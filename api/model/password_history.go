@@ -0,0 +1,83 @@
+package model
+
+import (
+	"time"
+
+	"github.com/53AI/53AIHub/common/utils/helper"
+	"gorm.io/gorm"
+)
+
+// PasswordHistory 用户用过的密码摘要，用于禁止重复使用最近的密码
+type PasswordHistory struct {
+	ID       int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid      int64  `json:"eid" gorm:"not null;index"`
+	UserID   int64  `json:"user_id" gorm:"not null;index"`
+	Password string `json:"-" gorm:"not null"`
+	Salt     string `json:"-" gorm:"size:10;not null"`
+	BaseModel
+}
+
+func (PasswordHistory) TableName() string {
+	return "password_histories"
+}
+
+// GetPasswordHistories 用户最近使用过的 limit 个密码，最新的在前
+func GetPasswordHistories(eid int64, userID int64, limit int) ([]*PasswordHistory, error) {
+	histories := make([]*PasswordHistory, 0)
+	err := DB.Where("eid = ? AND user_id = ?", eid, userID).
+		Order("id DESC").Limit(limit).Find(&histories).Error
+	return histories, err
+}
+
+// Matches 判断明文密码是否与该历史密码相同
+func (history *PasswordHistory) Matches(password string) bool {
+	hashed, err := helper.PasswordHash(password, history.Salt)
+	return err == nil && hashed == history.Password
+}
+
+// ChangePassword 使用新盐值设置密码并更新密码修改时间；keepHistory 大于 0 时把旧密码写入历史，
+// 并只保留最近 keepHistory 条
+func (user *User) ChangePassword(password string, keepHistory int) error {
+	salt := helper.RandomString(6)
+	hashed, err := helper.PasswordHash(password, salt)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC().UnixMilli()
+
+	err = DB.Transaction(func(tx *gorm.DB) error {
+		if keepHistory > 0 && user.Password != "" {
+			if err := tx.Create(&PasswordHistory{
+				Eid:      user.Eid,
+				UserID:   user.UserID,
+				Password: user.Password,
+				Salt:     user.Salt,
+			}).Error; err != nil {
+				return err
+			}
+			var ids []int64
+			if err := tx.Model(&PasswordHistory{}).Where("eid = ? AND user_id = ?", user.Eid, user.UserID).
+				Order("id DESC").Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+			if len(ids) > keepHistory {
+				if err := tx.Where("id IN ?", ids[keepHistory:]).Delete(&PasswordHistory{}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Model(&User{}).Where("user_id = ?", user.UserID).Updates(map[string]interface{}{
+			"password":              hashed,
+			"salt":                  salt,
+			"password_updated_time": now,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	user.Password = hashed
+	user.Salt = salt
+	user.PasswordUpdatedTime = now
+	return nil
+}
@@ -21,6 +21,10 @@ type Prompt struct {
 	Sort         int          `json:"sort" gorm:"not null;default:0;comment:排序"`
 	CustomConfig string       `json:"custom_config" gorm:"not null;type:text"`
 	AILinks      string       `json:"ai_links" gorm:"type:text;comment:关联的AI链接"`
+	Revision     int          `json:"revision" gorm:"not null;default:0;comment:当前修订版本"`
+	UseCount     int64        `json:"use_count" gorm:"not null;default:0;comment:插入对话次数"`
+	SourceID     int64        `json:"source_prompt_id" gorm:"column:source_prompt_id;not null;default:0;index;comment:复制来源的系统提示词id"`
+	SourceRev    int          `json:"source_revision" gorm:"column:source_revision;not null;default:0;comment:复制时来源提示词的修订版本"`
 	AILinksData  []AILinkInfo `gorm:"-" json:"ai_links_data"`
 	GroupIDs     []int64      `json:"group_ids" gorm:"-"`
 	IsLiked      bool         `json:"is_liked" gorm:"-"`
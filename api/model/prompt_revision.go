@@ -0,0 +1,135 @@
+package model
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var ErrPromptRevisionNotFound = errors.New("prompt revision not found")
+
+// PromptRevision 提示词修订记录，Revision 从 1 递增；创建和每次修改名称、内容或描述时记录一次
+type PromptRevision struct {
+	ID          int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid         int64  `json:"eid" gorm:"not null;index"`
+	PromptID    int64  `json:"prompt_id" gorm:"not null;uniqueIndex:idx_prompt_revision"`
+	Revision    int    `json:"revision" gorm:"not null;uniqueIndex:idx_prompt_revision"`
+	Name        string `json:"name" gorm:"size:255;not null;default:''"`
+	Content     string `json:"content" gorm:"size:5000;not null;default:''"`
+	Description string `json:"description" gorm:"type:text"`
+	CreatedBy   int64  `json:"created_by" gorm:"not null;default:0"`
+	BaseModel
+}
+
+func (PromptRevision) TableName() string {
+	return "prompt_revisions"
+}
+
+// PromptUsage 提示词被插入对话的记录
+type PromptUsage struct {
+	ID             int64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid            int64 `json:"eid" gorm:"not null;index"`
+	PromptID       int64 `json:"prompt_id" gorm:"not null;index"`
+	UserID         int64 `json:"user_id" gorm:"not null;default:0"`
+	AgentID        int64 `json:"agent_id" gorm:"not null;default:0;index"`
+	ConversationID int64 `json:"conversation_id" gorm:"not null;default:0"`
+	BaseModel
+}
+
+func (PromptUsage) TableName() string {
+	return "prompt_usages"
+}
+
+// PromptAgentUsage 提示词在某个智能体中的使用次数
+type PromptAgentUsage struct {
+	AgentID   int64  `json:"agent_id"`
+	AgentName string `json:"agent_name"`
+	Count     int64  `json:"count"`
+}
+
+// PromptRanking 统计周期内的提示词使用排行
+type PromptRanking struct {
+	PromptID  int64  `json:"prompt_id"`
+	Name      string `json:"name"`
+	Type      int    `json:"type"`
+	Count     int64  `json:"count"`
+	UserCount int64  `json:"user_count"`
+}
+
+// GetPromptRevision 获取指定修订版本
+func GetPromptRevision(promptID int64, revision int) (*PromptRevision, error) {
+	var r PromptRevision
+	err := DB.Where("prompt_id = ? AND revision = ?", promptID, revision).First(&r).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPromptRevisionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// GetPromptRevisions 提示词的修订记录，最新的在前
+func GetPromptRevisions(promptID int64) ([]*PromptRevision, error) {
+	revisions := make([]*PromptRevision, 0)
+	err := DB.Where("prompt_id = ?", promptID).Order("revision DESC").Find(&revisions).Error
+	return revisions, err
+}
+
+// GetLatestPromptRevision 最新的修订记录，没有时返回 nil
+func GetLatestPromptRevision(db *gorm.DB, promptID int64) (*PromptRevision, error) {
+	var r PromptRevision
+	err := db.Where("prompt_id = ?", promptID).Order("revision DESC").First(&r).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// CountPromptUsages 提示词的累计使用次数
+func CountPromptUsages(promptID int64) (int64, error) {
+	var count int64
+	err := DB.Model(&PromptUsage{}).Where("prompt_id = ?", promptID).Count(&count).Error
+	return count, err
+}
+
+// GetPromptAgentUsages 提示词按智能体统计的使用次数，未关联智能体的使用记录 AgentID 为 0
+func GetPromptAgentUsages(promptID int64) ([]*PromptAgentUsage, error) {
+	usages := make([]*PromptAgentUsage, 0)
+	err := DB.Table("prompt_usages").
+		Select("prompt_usages.agent_id, COALESCE(agents.name, '') AS agent_name, COUNT(*) AS count").
+		Joins("LEFT JOIN agents ON agents.agent_id = prompt_usages.agent_id").
+		Where("prompt_usages.prompt_id = ?", promptID).
+		Group("prompt_usages.agent_id, agents.name").
+		Order("count DESC").
+		Scan(&usages).Error
+	return usages, err
+}
+
+// GetPromptRankings 统计 startTime（毫秒时间戳）之后使用次数最多的提示词，promptType 为 0 时不限类型
+func GetPromptRankings(eid int64, promptType int, startTime int64, limit int) ([]*PromptRanking, error) {
+	rankings := make([]*PromptRanking, 0)
+	db := DB.Table("prompt_usages").
+		Select("prompt_usages.prompt_id, prompts.name, prompts.type, COUNT(*) AS count, COUNT(DISTINCT prompt_usages.user_id) AS user_count").
+		Joins("JOIN prompts ON prompts.prompt_id = prompt_usages.prompt_id").
+		Where("prompt_usages.eid = ? AND prompts.status <> ? AND prompt_usages.created_time >= ?", eid, PromptStatusDelete, startTime)
+	if promptType > 0 {
+		db = db.Where("prompts.type = ?", promptType)
+	}
+	err := db.Group("prompt_usages.prompt_id, prompts.name, prompts.type").
+		Order("count DESC, prompt_usages.prompt_id").
+		Limit(limit).
+		Scan(&rankings).Error
+	return rankings, err
+}
+
+// GetPromptForks 由指定提示词复制出的个人提示词
+func GetPromptForks(promptID int64) ([]*Prompt, error) {
+	prompts := make([]*Prompt, 0)
+	err := DB.Where("source_prompt_id = ? AND status <> ?", promptID, PromptStatusDelete).
+		Order("prompt_id DESC").Find(&prompts).Error
+	return prompts, err
+}
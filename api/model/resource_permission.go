@@ -1,5 +1,7 @@
 package model
 
+import "gorm.io/gorm"
+
 // ResourceType defines constants for resource types
 const (
 	ResourceTypeAgent      = "agent"      // Agent resource type
@@ -143,6 +145,55 @@ func GetGroupsByDepartmentID(departmentID int64) ([]int64, error) {
 	return groupIDs, nil
 }
 
+// SyncUserGroups 将成员在 managed 范围内的用户组调整为 wanted，managed 之外的用户组保持不变；
+// 用于按外部目录（OIDC claim、LDAP 组）维护成员所属用户组
+func SyncUserGroups(eid int64, userID int64, managed []int64, wanted map[int64]bool) error {
+	if len(managed) == 0 {
+		return nil
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var existing []int64
+		err := tx.Model(&ResourcePermission{}).
+			Where("resource_id = ? AND resource_type = ? AND group_id IN ?", userID, ResourceTypeUser, managed).
+			Pluck("group_id", &existing).Error
+		if err != nil {
+			return err
+		}
+		has := make(map[int64]bool, len(existing))
+		for _, groupID := range existing {
+			has[groupID] = true
+			if !wanted[groupID] {
+				err := tx.Where("resource_id = ? AND resource_type = ? AND group_id = ?", userID, ResourceTypeUser, groupID).
+					Delete(&ResourcePermission{}).Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		// 只关联企业内存在的用户组
+		var groupIDs []int64
+		err = tx.Model(&Group{}).Where("eid = ? AND group_id IN ?", eid, managed).Pluck("group_id", &groupIDs).Error
+		if err != nil {
+			return err
+		}
+		for _, groupID := range groupIDs {
+			if wanted[groupID] && !has[groupID] {
+				permission := &ResourcePermission{
+					GroupID:      groupID,
+					ResourceID:   userID,
+					ResourceType: ResourceTypeUser,
+					Permission:   PermissionRead,
+				}
+				if err := tx.Create(permission).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
 func GetGroupIDsByDepartmentIDs(dids []int64) ([]int64, error) {
 	var groupIDs []int64
 
@@ -60,6 +60,10 @@ const (
 	FeatureNotAvailableError                         // 15 - Feature not available
 	RecordAlreadyExists                              // 16 - Record already exists
 	InvalidVerificationCodeError                     // 17 - 验证码错误
+	SharePasswordRequired                            // 18 - 分享需要访问密码或密码错误
+	ShareExpired                                     // 19 - 分享已过期
+	AccountLocked                                    // 20 - 登录失败次数过多，账号暂时锁定
+	PasswordExpired                                  // 21 - 密码已过期，需要重置密码
 )
 
 // Response code descriptions
@@ -85,6 +89,10 @@ var CodeMessage = map[ResponseCode]string{
 	FeatureNotAvailableError:     "feature not available",
 	RecordAlreadyExists:          "record already exists",
 	InvalidVerificationCodeError: "invalid or expired verification code",
+	SharePasswordRequired:        "share password required",
+	ShareExpired:                 "share expired",
+	AccountLocked:                "account temporarily locked",
+	PasswordExpired:              "password expired",
 }
 
 const (
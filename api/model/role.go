@@ -0,0 +1,287 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// 权限点，格式为 资源:操作；* 表示全部权限，资源:* 表示该资源的全部操作
+const (
+	PermAll = "*"
+
+	PermEnterpriseRead    = "enterprise:read"
+	PermEnterpriseWrite   = "enterprise:write"
+	PermUserRead          = "user:read"
+	PermUserWrite         = "user:write"
+	PermGroupRead         = "group:read"
+	PermGroupWrite        = "group:write"
+	PermDepartmentRead    = "department:read"
+	PermDepartmentWrite   = "department:write"
+	PermRoleRead          = "role:read"
+	PermRoleWrite         = "role:write"
+	PermAgentRead         = "agent:read"
+	PermAgentWrite        = "agent:write"
+	PermChannelRead       = "channel:read"
+	PermChannelWrite      = "channel:write"
+	PermKnowledgeRead     = "knowledge_base:read"
+	PermKnowledgeWrite    = "knowledge_base:write"
+	PermPromptRead        = "prompt:read"
+	PermPromptWrite       = "prompt:write"
+	PermAILinkWrite       = "ai_link:write"
+	PermNavigationWrite   = "navigation:write"
+	PermConversationRead  = "conversation:read"
+	PermFeedbackRead      = "feedback:read"
+	PermShareRead         = "share:read"
+	PermShareWrite        = "share:write"
+	PermOrderRead         = "order:read"
+	PermOrderWrite        = "order:write"
+	PermOrderRefund       = "order:refund"
+	PermPaymentWrite      = "payment:write"
+	PermSubscriptionWrite = "subscription:write"
+	PermSystemLogRead     = "system_log:read"
+)
+
+// PermissionDefinition 权限点说明，供前端配置自定义角色
+type PermissionDefinition struct {
+	Code   string `json:"code" example:"agent:write"`
+	Name   string `json:"name" example:"编辑智能体"`
+	Module string `json:"module" example:"智能体"`
+}
+
+var Permissions = []PermissionDefinition{
+	{PermEnterpriseRead, "查看站点设置", "站点"},
+	{PermEnterpriseWrite, "修改站点设置与企业配置", "站点"},
+	{PermUserRead, "查看成员", "成员"},
+	{PermUserWrite, "管理成员", "成员"},
+	{PermGroupRead, "查看分组", "成员"},
+	{PermGroupWrite, "管理分组", "成员"},
+	{PermDepartmentRead, "查看部门", "成员"},
+	{PermDepartmentWrite, "管理部门与组织同步", "成员"},
+	{PermRoleRead, "查看角色", "成员"},
+	{PermRoleWrite, "管理角色与授权", "成员"},
+	{PermAgentRead, "查看全部智能体", "智能体"},
+	{PermAgentWrite, "编辑智能体", "智能体"},
+	{PermChannelRead, "查看渠道与第三方应用", "智能体"},
+	{PermChannelWrite, "管理渠道与模型供应商", "智能体"},
+	{PermKnowledgeRead, "查看知识库", "知识库"},
+	{PermKnowledgeWrite, "管理知识库", "知识库"},
+	{PermPromptRead, "查看全部提示词", "提示词"},
+	{PermPromptWrite, "管理系统提示词", "提示词"},
+	{PermAILinkWrite, "管理AI工具", "AI工具"},
+	{PermNavigationWrite, "管理导航", "站点"},
+	{PermConversationRead, "查看成员对话", "对话"},
+	{PermFeedbackRead, "查看消息反馈", "对话"},
+	{PermShareRead, "查看全部分享", "对话"},
+	{PermShareWrite, "停用分享", "对话"},
+	{PermOrderRead, "查看订单", "订单"},
+	{PermOrderWrite, "处理订单", "订单"},
+	{PermOrderRefund, "订单退款", "订单"},
+	{PermPaymentWrite, "支付配置", "订单"},
+	{PermSubscriptionWrite, "订阅设置", "订单"},
+	{PermSystemLogRead, "查看系统日志与登录审计", "系统"},
+}
+
+// IsValidPermission 判断是否为已定义的权限点或通配符
+func IsValidPermission(permission string) bool {
+	if permission == PermAll {
+		return true
+	}
+	for _, p := range Permissions {
+		if p.Code == permission || permissionResource(p.Code)+":*" == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func permissionResource(permission string) string {
+	for i := 0; i < len(permission); i++ {
+		if permission[i] == ':' {
+			return permission[:i]
+		}
+	}
+	return permission
+}
+
+// MatchPermission 判断已授予的权限点是否包含 required
+func MatchPermission(granted []string, required string) bool {
+	resourceAll := permissionResource(required) + ":*"
+	for _, p := range granted {
+		if p == PermAll || p == required || p == resourceAll {
+			return true
+		}
+	}
+	return false
+}
+
+// Role 自定义角色，由若干权限点组成，可分配给成员或部门。
+// 内置角色由 User.Role 数值映射，见 BuiltinRoles，不落库
+type Role struct {
+	ID          int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid         int64  `json:"eid" gorm:"not null;index"`
+	Name        string `json:"name" gorm:"type:varchar(100);not null" example:"智能体编辑"`
+	Description string `json:"description" gorm:"type:varchar(255);not null;default:''"`
+	// Permissions 权限点 JSON 数组
+	Permissions    string   `json:"-" gorm:"type:text"`
+	PermissionList []string `json:"permissions" gorm:"-" example:"agent:read,agent:write"`
+	// BuiltIn 内置角色，Level 为对应的 User.Role 数值
+	BuiltIn bool  `json:"built_in" gorm:"-"`
+	Level   int64 `json:"level,omitempty" gorm:"-"`
+	BaseModel
+}
+
+func (Role) TableName() string {
+	return "roles"
+}
+
+func (role *Role) AfterFind(tx *gorm.DB) error {
+	role.PermissionList = make([]string, 0)
+	if role.Permissions == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(role.Permissions), &role.PermissionList)
+}
+
+func (role *Role) BeforeSave(tx *gorm.DB) error {
+	if role.PermissionList == nil {
+		role.PermissionList = make([]string, 0)
+	}
+	data, err := json.Marshal(role.PermissionList)
+	if err != nil {
+		return err
+	}
+	role.Permissions = string(data)
+	return nil
+}
+
+// BuiltinRoles 现有数值角色对应的内置角色，按 Level 升序。管理员及以上拥有全部权限，与原先的阈值判断一致
+var BuiltinRoles = []Role{
+	{Name: "访客", BuiltIn: true, Level: RoleGuestUser, PermissionList: []string{}},
+	{Name: "成员", BuiltIn: true, Level: RoleCommonUser, PermissionList: []string{}},
+	{Name: "管理员", BuiltIn: true, Level: RoleAdminUser, PermissionList: []string{PermAll}},
+	{Name: "创建者", BuiltIn: true, Level: RoleCreatorUser, PermissionList: []string{PermAll}},
+	{Name: "超级管理员", BuiltIn: true, Level: RoleRootUser, PermissionList: []string{PermAll}},
+}
+
+// BuiltinRoleByLevel 数值角色对应的内置角色：Level 不超过 level 的最高内置角色
+func BuiltinRoleByLevel(level int64) Role {
+	role := BuiltinRoles[0]
+	for _, r := range BuiltinRoles {
+		if r.Level <= level {
+			role = r
+		}
+	}
+	return role
+}
+
+var ErrRoleNotFound = errors.New("role not found")
+
+func CreateRole(role *Role) error {
+	return DB.Create(role).Error
+}
+
+func GetRole(eid int64, id int64) (*Role, error) {
+	var role Role
+	err := DB.Where("eid = ? AND id = ?", eid, id).First(&role).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRoleNotFound
+	}
+	return &role, err
+}
+
+func GetRoles(eid int64) ([]*Role, error) {
+	roles := make([]*Role, 0)
+	err := DB.Where("eid = ?", eid).Order("id ASC").Find(&roles).Error
+	return roles, err
+}
+
+func UpdateRole(role *Role) error {
+	return DB.Model(role).Select("name", "description", "permissions").Updates(role).Error
+}
+
+// DeleteRole 删除角色及其全部授权
+func DeleteRole(eid int64, id int64) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("eid = ? AND role_id = ?", eid, id).Delete(&RoleAssignment{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("eid = ? AND id = ?", eid, id).Delete(&Role{}).Error
+	})
+}
+
+// 角色授权对象
+const (
+	RoleSubjectUser       = "user"
+	RoleSubjectDepartment = "department"
+)
+
+// RoleAssignment 把自定义角色授予成员或部门，部门的直属成员获得该角色的权限
+type RoleAssignment struct {
+	ID          int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid         int64  `json:"eid" gorm:"not null;index"`
+	RoleID      int64  `json:"role_id" gorm:"not null;uniqueIndex:idx_role_assignment"`
+	SubjectType string `json:"subject_type" gorm:"type:varchar(20);not null;uniqueIndex:idx_role_assignment" example:"user"`
+	SubjectID   int64  `json:"subject_id" gorm:"not null;uniqueIndex:idx_role_assignment;index:idx_role_assignment_subject"`
+	BaseModel
+}
+
+func (RoleAssignment) TableName() string {
+	return "role_assignments"
+}
+
+func GetRoleAssignments(eid int64, roleID int64) ([]*RoleAssignment, error) {
+	assignments := make([]*RoleAssignment, 0)
+	err := DB.Where("eid = ? AND role_id = ?", eid, roleID).Order("id ASC").Find(&assignments).Error
+	return assignments, err
+}
+
+// AssignRole 授予角色，已授予的对象跳过
+func AssignRole(eid int64, roleID int64, subjectType string, subjectIDs []int64) error {
+	if len(subjectIDs) == 0 {
+		return nil
+	}
+	var existing []int64
+	err := DB.Model(&RoleAssignment{}).Where("eid = ? AND role_id = ? AND subject_type = ? AND subject_id IN ?",
+		eid, roleID, subjectType, subjectIDs).Pluck("subject_id", &existing).Error
+	if err != nil {
+		return err
+	}
+	assigned := make(map[int64]bool, len(existing))
+	for _, id := range existing {
+		assigned[id] = true
+	}
+	assignments := make([]*RoleAssignment, 0, len(subjectIDs))
+	for _, id := range subjectIDs {
+		if assigned[id] {
+			continue
+		}
+		assigned[id] = true
+		assignments = append(assignments, &RoleAssignment{Eid: eid, RoleID: roleID, SubjectType: subjectType, SubjectID: id})
+	}
+	if len(assignments) == 0 {
+		return nil
+	}
+	return DB.Create(&assignments).Error
+}
+
+func UnassignRole(eid int64, roleID int64, subjectType string, subjectIDs []int64) error {
+	if len(subjectIDs) == 0 {
+		return nil
+	}
+	return DB.Where("eid = ? AND role_id = ? AND subject_type = ? AND subject_id IN ?",
+		eid, roleID, subjectType, subjectIDs).Delete(&RoleAssignment{}).Error
+}
+
+// GetAssignedRoles 授予成员本人或其所在部门的自定义角色
+func GetAssignedRoles(eid int64, userID int64, departmentIDs []int64) ([]*Role, error) {
+	query := DB.Model(&RoleAssignment{}).Select("role_id").
+		Where("eid = ? AND subject_type = ? AND subject_id = ?", eid, RoleSubjectUser, userID)
+	if len(departmentIDs) > 0 {
+		query = query.Or("eid = ? AND subject_type = ? AND subject_id IN ?", eid, RoleSubjectDepartment, departmentIDs)
+	}
+	roles := make([]*Role, 0)
+	err := DB.Where("eid = ? AND id IN (?)", eid, query).Find(&roles).Error
+	return roles, err
+}
@@ -0,0 +1,195 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrScheduleNotFound    = errors.New("schedule not found")
+	ErrScheduleRunNotFound = errors.New("schedule run not found")
+)
+
+const (
+	ScheduleRunStatusRunning = 1
+	ScheduleRunStatusSuccess = 2
+	ScheduleRunStatusFailed  = 3
+)
+
+const (
+	ScheduleTriggerCron   = "cron"
+	ScheduleTriggerManual = "manual"
+)
+
+// ScheduleDelivery 执行结果的投递方式，可同时选择多种
+type ScheduleDelivery struct {
+	// Conversation 写入执行用户的会话，首次执行时创建，之后的结果追加到同一会话
+	Conversation bool     `json:"conversation" example:"true"`
+	Emails       []string `json:"emails" example:"ops@example.com"`
+	WebhookURL   string   `json:"webhook_url" example:"https://example.com/hooks/digest"`
+}
+
+// Schedule 定时执行的智能体或工作流
+type Schedule struct {
+	ID       int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid      int64  `json:"eid" gorm:"not null;index"`
+	Name     string `json:"name" gorm:"type:varchar(100);not null"`
+	Cron     string `json:"cron" gorm:"type:varchar(100);not null"`
+	Timezone string `json:"timezone" gorm:"type:varchar(64);default:''"`
+	AgentID  int64  `json:"agent_id" gorm:"not null;index"`
+	// Query 对话类智能体的提问
+	Query string `json:"query" gorm:"type:text"`
+	// Inputs 工作流的固定输入
+	Inputs     string                 `json:"-" gorm:"type:text"`
+	Parameters map[string]interface{} `json:"parameters" gorm:"-"`
+	// RunAs 以该用户的身份与权限执行
+	RunAs          int64            `json:"run_as" gorm:"column:run_as_user_id;not null"`
+	Delivery       string           `json:"-" gorm:"type:text"`
+	DeliveryConfig ScheduleDelivery `json:"delivery" gorm:"-"`
+	ConversationID int64            `json:"conversation_id" gorm:"not null;default:0"`
+	Enabled        bool             `json:"enabled" gorm:"not null;default:true"`
+	NextRunTime    int64            `json:"next_run_time" gorm:"not null;default:0;index"`
+	LastRunTime    int64            `json:"last_run_time" gorm:"not null;default:0"`
+	LastStatus     int              `json:"last_status" gorm:"not null;default:0"`
+	CreatedBy      int64            `json:"created_by" gorm:"not null;default:0"`
+	BaseModel
+}
+
+func (Schedule) TableName() string {
+	return "schedules"
+}
+
+func (s *Schedule) BeforeSave(tx *gorm.DB) error {
+	if s.Parameters == nil {
+		s.Parameters = make(map[string]interface{})
+	}
+	inputs, err := json.Marshal(s.Parameters)
+	if err != nil {
+		return err
+	}
+	delivery, err := json.Marshal(s.DeliveryConfig)
+	if err != nil {
+		return err
+	}
+	s.Inputs, s.Delivery = string(inputs), string(delivery)
+	return nil
+}
+
+func (s *Schedule) AfterFind(tx *gorm.DB) error {
+	if s.Inputs != "" {
+		if err := json.Unmarshal([]byte(s.Inputs), &s.Parameters); err != nil {
+			return err
+		}
+	}
+	if s.Parameters == nil {
+		s.Parameters = make(map[string]interface{})
+	}
+	if s.Delivery != "" {
+		return json.Unmarshal([]byte(s.Delivery), &s.DeliveryConfig)
+	}
+	return nil
+}
+
+// ScheduleRun 定时任务的一次执行
+type ScheduleRun struct {
+	ID         int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid        int64  `json:"eid" gorm:"not null;index"`
+	ScheduleID int64  `json:"schedule_id" gorm:"not null;index"`
+	Trigger    string `json:"trigger" gorm:"type:varchar(20);not null"`
+	Status     int    `json:"status" gorm:"not null;default:0"`
+	Output     string `json:"output" gorm:"type:text"`
+	MessageID  int64  `json:"message_id" gorm:"not null;default:0"`
+	// ErrorMessage 执行失败原因，DeliveryError 投递失败原因（执行成功但部分投递失败时仍为成功）
+	ErrorMessage  string `json:"error_message" gorm:"type:text"`
+	DeliveryError string `json:"delivery_error" gorm:"type:text"`
+	FinishedTime  int64  `json:"finished_time" gorm:"not null;default:0"`
+	BaseModel
+}
+
+func (ScheduleRun) TableName() string {
+	return "schedule_runs"
+}
+
+func GetSchedule(eid int64, id int64) (*Schedule, error) {
+	var schedule Schedule
+	err := DB.Where("eid = ? AND id = ?", eid, id).First(&schedule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrScheduleNotFound
+	}
+	return &schedule, err
+}
+
+func GetSchedules(eid int64, agentID int64, offset int, limit int) (count int64, schedules []*Schedule, err error) {
+	db := DB.Model(&Schedule{}).Where("eid = ?", eid)
+	if agentID > 0 {
+		db = db.Where("agent_id = ?", agentID)
+	}
+	if err = db.Count(&count).Error; err != nil {
+		return 0, nil, err
+	}
+	err = db.Order("id DESC").Offset(offset).Limit(limit).Find(&schedules).Error
+	return count, schedules, err
+}
+
+// GetDueSchedules 获取已到执行时间的定时任务
+func GetDueSchedules(now int64) ([]*Schedule, error) {
+	var schedules []*Schedule
+	err := DB.Where("enabled = ? AND next_run_time > 0 AND next_run_time <= ?", true, now).
+		Order("next_run_time ASC").Find(&schedules).Error
+	return schedules, err
+}
+
+func SaveSchedule(schedule *Schedule) error {
+	return DB.Save(schedule).Error
+}
+
+// UpdateScheduleColumns 只更新指定列，调度器推进执行时间时不覆盖用户同时做的修改
+func UpdateScheduleColumns(id int64, columns map[string]interface{}) error {
+	return DB.Model(&Schedule{}).Where("id = ?", id).UpdateColumns(columns).Error
+}
+
+func DeleteSchedule(eid int64, id int64) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("eid = ? AND schedule_id = ?", eid, id).Delete(&ScheduleRun{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("eid = ? AND id = ?", eid, id).Delete(&Schedule{}).Error
+	})
+}
+
+// DeleteAgentSchedules 删除智能体时删除其定时任务与执行记录
+func DeleteAgentSchedules(tx *gorm.DB, agentID int64) error {
+	ids := tx.Model(&Schedule{}).Select("id").Where("agent_id = ?", agentID)
+	if err := tx.Where("schedule_id IN (?)", ids).Delete(&ScheduleRun{}).Error; err != nil {
+		return err
+	}
+	return tx.Where("agent_id = ?", agentID).Delete(&Schedule{}).Error
+}
+
+func CreateScheduleRun(run *ScheduleRun) error {
+	return DB.Create(run).Error
+}
+
+func UpdateScheduleRun(run *ScheduleRun) error {
+	return DB.Save(run).Error
+}
+
+func GetScheduleRun(eid int64, scheduleID int64, id int64) (*ScheduleRun, error) {
+	var run ScheduleRun
+	err := DB.Where("eid = ? AND schedule_id = ? AND id = ?", eid, scheduleID, id).First(&run).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrScheduleRunNotFound
+	}
+	return &run, err
+}
+
+func GetScheduleRuns(eid int64, scheduleID int64, offset int, limit int) (count int64, runs []*ScheduleRun, err error) {
+	db := DB.Model(&ScheduleRun{}).Where("eid = ? AND schedule_id = ?", eid, scheduleID)
+	if err = db.Count(&count).Error; err != nil {
+		return 0, nil, err
+	}
+	err = db.Order("id DESC").Offset(offset).Limit(limit).Find(&runs).Error
+	return count, runs, err
+}
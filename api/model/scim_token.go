@@ -0,0 +1,86 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	scimTokenPrefix        = "scim_"
+	scimTokenDisplayLength = 12
+)
+
+// ScimToken 企业 SCIM 2.0 接口的访问令牌，每个企业一个，仅保存哈希
+type ScimToken struct {
+	ID           int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid          int64  `json:"eid" gorm:"not null;uniqueIndex"`
+	TokenHash    string `json:"-" gorm:"type:char(64);not null;uniqueIndex"`
+	TokenPrefix  string `json:"token_prefix" gorm:"type:varchar(32);not null;default:''"` // 令牌前缀，便于管理员识别
+	CreatedBy    int64  `json:"created_by" gorm:"not null;default:0"`
+	LastUsedTime int64  `json:"last_used_time" gorm:"not null;default:0"`
+	BaseModel
+}
+
+func hashScimToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// GenerateScimToken 生成（或轮换）企业的 SCIM 令牌，返回明文令牌，明文只在生成时返回一次
+func GenerateScimToken(eid int64, createdBy int64) (string, *ScimToken, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	token := scimTokenPrefix + hex.EncodeToString(buf)
+
+	var record ScimToken
+	err := DB.Where("eid = ?", eid).First(&record).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil, err
+	}
+	record.Eid = eid
+	record.TokenHash = hashScimToken(token)
+	record.TokenPrefix = token[:scimTokenDisplayLength]
+	record.CreatedBy = createdBy
+	record.LastUsedTime = 0
+	if err := DB.Save(&record).Error; err != nil {
+		return "", nil, err
+	}
+	return token, &record, nil
+}
+
+func GetScimTokenByEid(eid int64) (*ScimToken, error) {
+	var record ScimToken
+	if err := DB.Where("eid = ?", eid).First(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// GetScimTokenByToken 根据明文令牌查找 SCIM 令牌
+func GetScimTokenByToken(token string) (*ScimToken, error) {
+	if token == "" {
+		return nil, errors.New("empty scim token")
+	}
+	var record ScimToken
+	err := DB.Where("token_hash = ?", hashScimToken(token)).First(&record).Error
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func DeleteScimToken(eid int64) error {
+	return DB.Where("eid = ?", eid).Delete(&ScimToken{}).Error
+}
+
+// TouchScimToken 记录令牌最后使用时间
+func TouchScimToken(id int64) error {
+	return DB.Model(&ScimToken{}).Where("id = ?", id).UpdateColumn("last_used_time", time.Now().UnixMilli()).Error
+}
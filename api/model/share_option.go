@@ -0,0 +1,293 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/53AI/53AIHub/common/utils/helper"
+	"gorm.io/gorm"
+)
+
+// 分享可见范围
+const (
+	ShareVisibilityPublic     = "public"     // 任何人可访问
+	ShareVisibilityEnterprise = "enterprise" // 仅同企业登录成员可访问
+)
+
+// 分享状态
+const (
+	ShareStatusActive   = 1 // 正常
+	ShareStatusDisabled = 2 // 已被管理员停用
+)
+
+// ShareOptions 分享的访问控制与访问统计，嵌入 ShareRecord
+type ShareOptions struct {
+	UserID          int64  `json:"user_id" gorm:"column:user_id;not null;default:0;index"`                         // 分享创建者
+	ExpireTime      int64  `json:"expire_time" gorm:"column:expire_time;not null;default:0"`                       // 过期时间（毫秒），0 为永久有效
+	Password        string `json:"-" gorm:"column:password;type:varchar(64);not null;default:''"`                  // 访问密码哈希，为空表示无需密码
+	PasswordSalt    string `json:"-" gorm:"column:password_salt;type:varchar(32);not null;default:''"`             // 访问密码盐
+	HasPassword     bool   `json:"has_password" gorm:"-"`                                                          // 是否设置了访问密码
+	Visibility      string `json:"visibility" gorm:"column:visibility;type:varchar(20);not null;default:'public'"` // public / enterprise
+	HideAttachments bool   `json:"hide_attachments" gorm:"column:hide_attachments;not null;default:false"`         // 是否隐藏消息中的附件
+	Status          int    `json:"status" gorm:"column:status;not null;default:1;index"`                           // 1 正常，2 管理员停用
+	ViewCount       int64  `json:"view_count" gorm:"column:view_count;not null;default:0"`                         // 访问次数
+	LastViewedTime  int64  `json:"last_viewed_time" gorm:"column:last_viewed_time;not null;default:0"`             // 最后访问时间（毫秒）
+}
+
+// ShareRecordListItem 分享列表项，附带会话标题
+type ShareRecordListItem struct {
+	ShareRecord
+	ConversationTitle string `json:"conversation_title" gorm:"column:conversation_title"`
+}
+
+var (
+	ErrShareDisabled = errors.New("share has been disabled by administrator")
+	// ErrShareExists 相同消息集合已有分享且选项不同，需显式修改已有分享
+	ErrShareExists = errors.New("share already exists with different options")
+)
+
+func (rec *ShareRecord) AfterFind(tx *gorm.DB) error {
+	rec.HasPassword = rec.Password != ""
+	return nil
+}
+
+// IsValidShareVisibility 判断可见范围是否合法
+func IsValidShareVisibility(visibility string) bool {
+	return visibility == ShareVisibilityPublic || visibility == ShareVisibilityEnterprise
+}
+
+// IsExpired 判断分享是否已过期
+func (rec *ShareRecord) IsExpired() bool {
+	return rec.ExpireTime > 0 && rec.ExpireTime <= time.Now().UnixMilli()
+}
+
+// IsDisabled 判断分享是否已被管理员停用
+func (rec *ShareRecord) IsDisabled() bool {
+	return rec.Status == ShareStatusDisabled
+}
+
+// CheckPassword 校验访问密码，未设置密码时始终通过
+func (rec *ShareRecord) CheckPassword(password string) bool {
+	if rec.Password == "" {
+		return true
+	}
+	if password == "" {
+		return false
+	}
+	hashed, err := helper.PasswordHash(password, rec.PasswordSalt)
+	if err != nil {
+		return false
+	}
+	return hashed == rec.Password
+}
+
+// SetPassword 设置访问密码，传空字符串清除密码
+func (rec *ShareRecord) SetPassword(password string) error {
+	if password == "" {
+		rec.Password = ""
+		rec.PasswordSalt = ""
+		rec.HasPassword = false
+		return nil
+	}
+	salt := helper.RandomString(16)
+	hashed, err := helper.PasswordHash(password, salt)
+	if err != nil {
+		return err
+	}
+	rec.Password = hashed
+	rec.PasswordSalt = salt
+	rec.HasPassword = true
+	return nil
+}
+
+// backfillShareRecordUserID 创建者字段上线前的分享没有 user_id，按会话所有者补齐，否则创建者无法查看和撤销
+func backfillShareRecordUserID() error {
+	return DB.Exec(`UPDATE share_records SET user_id = (
+		SELECT conversations.user_id FROM conversations WHERE conversations.conversation_id = share_records.conversation_id
+	) WHERE user_id = 0 AND EXISTS (
+		SELECT 1 FROM conversations WHERE conversations.conversation_id = share_records.conversation_id
+	)`).Error
+}
+
+// ApplyShareOptions 创建分享后写入创建者与访问选项。相同消息集合复用同一分享记录：
+// 选项一致时直接返回已有分享；不一致时返回 ErrShareExists，需通过 UpdateShareOptions 显式修改，
+// 避免覆盖已发出链接的密码、有效期与创建者。已被管理员停用的分享不允许重新设置
+func ApplyShareOptions(eid int64, shareID string, userID int64, options *ShareOptions, password string, reused bool) (*ShareRecord, error) {
+	rec, err := GetShareRecordByEidAndShareID(eid, shareID)
+	if err != nil {
+		return nil, err
+	}
+	if rec.IsDisabled() {
+		return nil, ErrShareDisabled
+	}
+	if reused {
+		if !rec.sameOptions(options, password) {
+			return rec, ErrShareExists
+		}
+		return rec, nil
+	}
+	rec.UserID = userID
+	if err := rec.setOptions(options, password); err != nil {
+		return nil, err
+	}
+	if err := DB.Model(rec).Select(append([]string{"user_id"}, shareOptionColumns...)).Updates(rec).Error; err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// UpdateShareOptions 修改已有分享的访问选项，password 为 nil 时保留原密码，为空字符串时清除密码
+func UpdateShareOptions(rec *ShareRecord, options *ShareOptions, password *string) error {
+	if rec.IsDisabled() {
+		return ErrShareDisabled
+	}
+	if password == nil {
+		rec.ExpireTime = options.ExpireTime
+		rec.Visibility = options.Visibility
+		rec.HideAttachments = options.HideAttachments
+	} else if err := rec.setOptions(options, *password); err != nil {
+		return err
+	}
+	return DB.Model(rec).Select(shareOptionColumns).Updates(rec).Error
+}
+
+var shareOptionColumns = []string{"expire_time", "password", "password_salt", "visibility", "hide_attachments"}
+
+func (rec *ShareRecord) setOptions(options *ShareOptions, password string) error {
+	if err := rec.SetPassword(password); err != nil {
+		return err
+	}
+	rec.ExpireTime = options.ExpireTime
+	rec.Visibility = options.Visibility
+	rec.HideAttachments = options.HideAttachments
+	return nil
+}
+
+// sameOptions 判断重复分享请求的选项与已有分享是否一致
+func (rec *ShareRecord) sameOptions(options *ShareOptions, password string) bool {
+	if rec.ExpireTime != options.ExpireTime || rec.Visibility != options.Visibility || rec.HideAttachments != options.HideAttachments {
+		return false
+	}
+	if password == "" {
+		return rec.Password == ""
+	}
+	return rec.CheckPassword(password)
+}
+
+// IncreaseShareViewCount 原子递增访问次数并记录访问时间
+func IncreaseShareViewCount(id int64) error {
+	return DB.Model(&ShareRecord{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"view_count":       gorm.Expr("view_count + ?", 1),
+		"last_viewed_time": time.Now().UnixMilli(),
+	}).Error
+}
+
+// GetShareRecords 分页获取分享列表，userID 为 0 时返回企业内所有分享
+func GetShareRecords(eid int64, userID int64, status int, offset, limit int) (count int64, items []*ShareRecordListItem, err error) {
+	query := DB.Table("share_records").Where("share_records.eid = ?", eid)
+	if userID > 0 {
+		query = query.Where("share_records.user_id = ?", userID)
+	}
+	if status > 0 {
+		query = query.Where("share_records.status = ?", status)
+	}
+	if err = query.Count(&count).Error; err != nil {
+		return 0, nil, err
+	}
+	if limit == 0 {
+		limit = 10
+	}
+	err = query.Select("share_records.*, conversations.title AS conversation_title").
+		Joins("LEFT JOIN conversations ON conversations.conversation_id = share_records.conversation_id").
+		Order("share_records.id DESC").Offset(offset).Limit(limit).Find(&items).Error
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, item := range items {
+		item.HasPassword = item.Password != ""
+	}
+	return count, items, nil
+}
+
+func GetShareRecordByEidAndShareID(eid int64, shareID string) (*ShareRecord, error) {
+	var rec ShareRecord
+	if err := DB.Where("eid = ? AND share_id = ?", eid, shareID).First(&rec).Error; err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func UpdateShareRecordStatus(eid int64, shareID string, status int) error {
+	return DB.Model(&ShareRecord{}).Where("eid = ? AND share_id = ?", eid, shareID).
+		Update("status", status).Error
+}
+
+func DeleteShareRecord(eid int64, shareID string) error {
+	return DB.Where("eid = ? AND share_id = ?", eid, shareID).Delete(&ShareRecord{}).Error
+}
+
+// StripMessageAttachments 移除消息请求内容中的附件，仅保留文本，用于隐藏附件的分享
+func StripMessageAttachments(message *Message) {
+	if message.GetMessageType() == MessageTypeWorkflow {
+		parameters, err := message.ParseWorkflowParameters()
+		if err != nil {
+			return
+		}
+		for key, value := range parameters {
+			if s, ok := value.(string); ok {
+				if _, isFile := ParseFileIDContent(s); isFile {
+					delete(parameters, key)
+				}
+			}
+		}
+		if data, err := json.Marshal(parameters); err == nil {
+			message.Message = string(data)
+		}
+		return
+	}
+
+	messages, err := message.ParseChatMessage()
+	if err != nil {
+		return
+	}
+	for _, item := range messages {
+		item["content"] = stripContentAttachments(item["content"])
+	}
+	if data, err := json.Marshal(messages); err == nil {
+		message.Message = string(data)
+	}
+}
+
+func stripContentAttachments(content interface{}) interface{} {
+	switch v := content.(type) {
+	case string:
+		var parts []ObjectStringContent
+		if err := json.Unmarshal([]byte(v), &parts); err != nil || len(parts) == 0 {
+			return v
+		}
+		kept := make([]ObjectStringContent, 0, len(parts))
+		for _, part := range parts {
+			if part.Type == "text" {
+				kept = append(kept, part)
+			}
+		}
+		data, err := json.Marshal(kept)
+		if err != nil {
+			return v
+		}
+		return string(data)
+	case []interface{}:
+		kept := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			if part, ok := item.(map[string]interface{}); ok {
+				if partType, _ := part["type"].(string); partType != "text" {
+					continue
+				}
+			}
+			kept = append(kept, item)
+		}
+		return kept
+	}
+	return content
+}
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBackfillShareRecordUserID(t *testing.T) {
+	setupTestDB(t, &Conversation{}, &ShareRecord{})
+	conversation := &Conversation{Eid: 1, UserID: 7, AgentID: 1, Title: "t"}
+	if err := CreateConversation(conversation); err != nil {
+		t.Fatal(err)
+	}
+	legacy := &ShareRecord{Eid: 1, ShareID: "legacy", ConversationID: conversation.ConversationID, MessageIDs: "1", NormalizedHash: "a"}
+	owned := &ShareRecord{Eid: 1, ShareID: "owned", ConversationID: conversation.ConversationID, MessageIDs: "2", NormalizedHash: "b",
+		ShareOptions: ShareOptions{UserID: 9}}
+	orphan := &ShareRecord{Eid: 1, ShareID: "orphan", ConversationID: conversation.ConversationID + 1, MessageIDs: "3", NormalizedHash: "c"}
+	for _, rec := range []*ShareRecord{legacy, owned, orphan} {
+		if err := DB.Create(rec).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := backfillShareRecordUserID(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int64{"legacy": 7, "owned": 9, "orphan": 0}
+	for shareID, userID := range want {
+		rec, err := GetShareRecordByEidAndShareID(1, shareID)
+		if err != nil || rec.UserID != userID {
+			t.Errorf("share %s user_id = %+v, %v; want %d", shareID, rec, err, userID)
+		}
+	}
+}
+
+func TestApplyShareOptionsKeepsExistingShare(t *testing.T) {
+	setupTestDB(t, &Conversation{}, &ShareRecord{})
+	shareID, reused, err := CreateShareRecord(1, 10, "1,2")
+	if err != nil || reused {
+		t.Fatalf("CreateShareRecord = %s, %v, %v", shareID, reused, err)
+	}
+	options := &ShareOptions{Visibility: ShareVisibilityPublic}
+	if _, err := ApplyShareOptions(1, shareID, 7, options, "secret", reused); err != nil {
+		t.Fatal(err)
+	}
+
+	// 相同消息集合、相同选项重复分享，直接返回已有分享
+	again, reused, _ := CreateShareRecord(1, 10, "1,2")
+	if again != shareID || !reused {
+		t.Fatalf("share should be reused: %s %v", again, reused)
+	}
+	if rec, err := ApplyShareOptions(1, shareID, 7, options, "secret", reused); err != nil || rec.ShareID != shareID {
+		t.Fatalf("same options = %+v, %v", rec, err)
+	}
+
+	// 选项不同时不能覆盖已发出链接的密码、可见范围与创建者
+	changes := []struct {
+		options  *ShareOptions
+		password string
+	}{
+		{options, ""},
+		{options, "other"},
+		{&ShareOptions{Visibility: ShareVisibilityEnterprise}, "secret"},
+		{&ShareOptions{Visibility: ShareVisibilityPublic, HideAttachments: true}, "secret"},
+	}
+	for _, change := range changes {
+		if _, err := ApplyShareOptions(1, shareID, 8, change.options, change.password, true); !errors.Is(err, ErrShareExists) {
+			t.Errorf("ApplyShareOptions(%+v, %q) err = %v", change.options, change.password, err)
+		}
+	}
+	rec, _ := GetShareRecordByEidAndShareID(1, shareID)
+	if rec.UserID != 7 || !rec.CheckPassword("secret") || rec.Visibility != ShareVisibilityPublic {
+		t.Fatalf("existing share was modified: %+v", rec)
+	}
+
+	// 显式修改：不传密码时保留原密码，传空字符串清除密码
+	if err := UpdateShareOptions(rec, &ShareOptions{Visibility: ShareVisibilityEnterprise}, nil); err != nil {
+		t.Fatal(err)
+	}
+	rec, _ = GetShareRecordByEidAndShareID(1, shareID)
+	if rec.Visibility != ShareVisibilityEnterprise || !rec.CheckPassword("secret") {
+		t.Fatalf("update should keep the password: %+v", rec)
+	}
+	empty := ""
+	if err := UpdateShareOptions(rec, &ShareOptions{Visibility: ShareVisibilityEnterprise}, &empty); err != nil {
+		t.Fatal(err)
+	}
+	if rec, _ = GetShareRecordByEidAndShareID(1, shareID); rec.HasPassword || rec.UserID != 7 {
+		t.Fatalf("update should clear the password only: %+v", rec)
+	}
+}
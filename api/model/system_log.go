@@ -14,7 +14,7 @@ type SystemLog struct {
 	Eid        int64  `json:"eid" gorm:"not null;comment:站点ID"`
 	UserID     int64  `json:"user_id" gorm:"not null;comment:操作成员ID"`
 	Nickname   string `json:"nickname" gorm:"size:255;not null;comment:成员名称"`
-	Module     uint8  `json:"module" gorm:"unsigned;not null;comment:模块。1系统；2智能体；3提示词；4AI工具；5订单数据；6注册用户；7内部用户；8订阅设置；9管理员；10模板风格；11Banner图；12导航管理；13站点信息；14平台接入；15支付配置；16站点域名；17三方统计"`
+	Module     uint8  `json:"module" gorm:"unsigned;not null;comment:模块。1系统；2智能体；3提示词；4AI工具；5订单数据；6注册用户；7内部用户；8订阅设置；9管理员；10模板风格；11Banner图；12导航管理；13站点信息；14平台接入；15支付配置；16站点域名；17三方统计；18账号安全"`
 	Action     uint8  `json:"action" gorm:"unsigned;not null;comment:动作。1新建；2编辑；3删除；4启用/停用；5登录/退出"`
 	Content    string `json:"content" gorm:"type:text;not null;comment:日志内容"`
 	IP         string `json:"ip" gorm:"size:20;not null;comment:ip"`
@@ -40,6 +40,7 @@ const (
 	SystemLogModulePayment      uint8 = 15 // 支付配置
 	SystemLogModuleDomain       uint8 = 16 // 站点域名
 	SystemLogModuleStatistics   uint8 = 17 // 三方统计
+	SystemLogModuleSecurity     uint8 = 18 // 账号安全
 )
 
 // GetModuleByGroupType 根据分组类型获取对应的系统日志模块
@@ -253,6 +254,7 @@ var moduleTextMap = map[uint8]string{
 	SystemLogModulePayment:      "支付配置",
 	SystemLogModuleDomain:       "站点域名",
 	SystemLogModuleStatistics:   "三方统计",
+	SystemLogModuleSecurity:     "账号安全",
 }
 
 // GetAllModules 获取所有模块定义
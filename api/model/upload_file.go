@@ -80,6 +80,22 @@ func GetUploadFileByID(id int64) (uploadFile *UploadFile, err error) {
 	return uploadFile, err
 }
 
+// GetUploadFileByEidAndHash 按内容哈希查找企业内已上传的文件
+func GetUploadFileByEidAndHash(Eid int64, hash string) (uploadFile *UploadFile, err error) {
+	err = DB.Model(&UploadFile{}).Where("eid =? AND hash =?", Eid, hash).First(&uploadFile).Error
+	return uploadFile, err
+}
+
+// GetUserUploadFileByHash 按哈希查找成员本人上传的文件
+func GetUserUploadFileByHash(eid int64, userID int64, hash string) (*UploadFile, error) {
+	var uploadFile UploadFile
+	err := DB.Where("eid = ? AND user_id = ? AND hash = ?", eid, userID, hash).First(&uploadFile).Error
+	if err != nil {
+		return nil, err
+	}
+	return &uploadFile, nil
+}
+
 func (uploadFile *UploadFile) GetChannelFileMapping(channelId int, model string) *ChannelFileMapping {
 	var channelFileMapping ChannelFileMapping
 	err := DB.Model(&ChannelFileMapping{}).Where("channel_id =? AND file_id =? AND model =?", channelId, uploadFile.ID, model).First(&channelFileMapping).Error
@@ -10,6 +10,7 @@ import (
 	"github.com/53AI/53AIHub/common/utils/helper"
 	"github.com/53AI/53AIHub/common/utils/jwt"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type User struct {
@@ -36,6 +37,9 @@ type User struct {
 	Departments    []Department    `json:"departments" gorm:"-"`
 	MemberBindings []MemberBinding `json:"memberbindings" gorm:"-"`
 	GroupIds       []int64         `json:"group_ids" gorm:"-"`
+
+	// PasswordUpdatedTime 最近一次设置密码的时间（毫秒），用于密码过期策略；0 表示未记录
+	PasswordUpdatedTime int64 `json:"password_updated_time" gorm:"not null;default:0"`
 	BaseModel
 }
 
@@ -52,6 +56,7 @@ const (
 
 	UserTypeRegistered = 1 // Registered user
 	UserTypeInternal   = 2 // Internal user
+	UserTypeGuest      = 3 // 嵌入组件的匿名访客，每个嵌入配置一个，不能登录
 )
 
 func (user *User) Create() error {
@@ -90,23 +95,13 @@ func (user *User) Create() error {
 		if err != nil {
 			return err
 		}
+		user.PasswordUpdatedTime = time.Now().UTC().UnixMilli()
 	} else {
 		return errors.New("password is empty")
 	}
 
-	result := DB.Create(user)
-	if result.Error != nil {
-		return result.Error
-	}
-
-	user.AccessToken, err = jwt.UserGenerateJWT(user.UserID, user.Eid)
-	if err != nil {
-		return err
-	}
-
-	err = DB.Model(user).Updates(user).Error
-
-	return err
+	// 访问令牌在登录时签发，见 UserSession
+	return DB.Create(user).Error
 }
 
 func (user *User) Update(updatePassword bool) error {
@@ -194,7 +189,8 @@ func (user *User) RefreshAccessToken() error {
 	}
 
 	user.LastLoginTime = time.Now().UTC().UnixMilli()
-	err = DB.Model(user).Updates(user).Error
+	// 访问令牌保存在登录会话中，见 UserSession
+	err = DB.Model(user).Omit("access_token").Updates(user).Error
 	return err
 }
 
@@ -211,15 +207,46 @@ func ValidateAccessToken(token string) (user *User) {
 	if token == "" {
 		return nil
 	}
+	if session := GetUserSessionByToken(token); session != nil {
+		user = &User{}
+		if DB.Where("user_id = ? AND eid = ?", session.UserID, session.Eid).First(user).RowsAffected != 1 {
+			return nil
+		}
+		session.Touch()
+		user.AccessToken = token
+		return user
+	}
+
+	// 兼容升级前签发、保存在用户表中的令牌，首次使用时转为登录会话
 	user = &User{}
 	if DB.Where("access_token = ?", token).First(user).RowsAffected == 1 {
+		if err := user.convertLegacyToken(token); err != nil {
+			return nil
+		}
 		return user
 	}
 	return nil
 }
 
+func (user *User) convertLegacyToken(token string) error {
+	now := time.Now().UTC()
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("user_id = ?", user.UserID).Update("access_token", "").Error; err != nil {
+			return err
+		}
+		return tx.Create(&UserSession{
+			Eid:          user.Eid,
+			UserID:       user.UserID,
+			TokenHash:    HashAccessToken(token),
+			Device:       "Unknown",
+			LastSeenTime: now.UnixMilli(),
+			ExpiresAt:    now.Add(jwt.UserTokenTTL).UnixMilli(),
+		}).Error
+	})
+}
+
 func GetUserList(eid int64, keyword string, group_id int64, offset int, limit int) (count int64, users []*User, err error) {
-	db := DB.Model(&User{}).Omit("password", "access_token").Where("eid = ?", eid)
+	db := DB.Model(&User{}).Omit("password", "access_token").Where("eid = ? AND type <> ?", eid, UserTypeGuest)
 	if keyword != "" {
 		db = db.Where("username LIKE ? OR nickname LIKE ? OR mobile LIKE ? OR email LIKE ?",
 			keyword+"%", keyword+"%", keyword+"%", keyword+"%")
@@ -252,14 +279,16 @@ func DeleteUser(eid int64, user_id int64) error {
 		tx.Where("eid = ? AND mid = ?", eid, user_id).Find(&binds)
 		if len(binds) > 0 {
 			for _, bind := range binds {
-				if bind.From == DepartmentFromBackend {
-					err := tx.Where("eid = ? AND bid = ? AND `from` = ? ", eid, bind.ID, DepartmentFromBackend).Delete(&MemberDepartmentRelation{}).Error
+				if bind.From == DepartmentFromBackend || bind.From == MemberBindingSourceSCIM || bind.From == MemberBindingSourceLDAP {
+					err := tx.Where("eid = ? AND bid = ? AND `from` = ? ", eid, bind.ID, bind.From).Delete(&MemberDepartmentRelation{}).Error
 					if err != nil {
 						tx.Rollback()
 						return err
 					}
 					tx.Where("eid = ? AND id = ?", eid, bind.ID).Delete(&MemberBinding{})
-				} else if bind.From == DepartmentFromWecom {
+				} else if bind.From == MemberBindingSourceOIDC {
+					tx.Where("eid = ? AND id = ?", eid, bind.ID).Delete(&MemberBinding{})
+				} else if bind.From == DepartmentFromWecom || bind.From == MemberBindingSourceDingTalk || bind.From == MemberBindingSourceFeishu {
 					err := tx.Model(&MemberBinding{}).Where("eid = ? AND id = ?", eid, bind.ID).Updates(
 						map[string]interface{}{
 							"mid":    0,
@@ -274,6 +303,26 @@ func DeleteUser(eid int64, user_id int64) error {
 		}
 	}
 
+	if err := tx.Where("eid = ? AND user_id = ?", eid, user_id).Delete(&UserSession{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Where("eid = ? AND user_id = ?", eid, user_id).Delete(&PasswordHistory{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Where("eid = ? AND subject_type = ? AND subject_id = ?", eid, RoleSubjectUser, user_id).Delete(&RoleAssignment{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Where("eid = ? AND user_id = ?", eid, user_id).Delete(&UserTwoFactor{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	if err := tx.Where("eid = ? AND user_id = ?", eid, user_id).Delete(&User{}).Error; err != nil {
 		tx.Rollback()
 		return err
@@ -359,6 +408,8 @@ func GetUserListWithRoles(eid int64, keyword string, groupId int64, roleStr stri
 
 	if userType != 0 {
 		query = query.Where("type =?", userType)
+	} else {
+		query = query.Where("type <> ?", UserTypeGuest)
 	}
 
 	// Process keyword search
@@ -471,6 +522,29 @@ func (u *User) LoadUserInfo(from int) {
 	_ = u.LoadMemberBindings(from)
 }
 
+// GetDepartmentIDs 成员直属的部门ID，包含后台添加与各平台同步的部门关系
+func (u *User) GetDepartmentIDs() ([]int64, error) {
+	var dids []int64
+	boundFroms := []int{MemberDepartmentRelationFromWeChat, MemberDepartmentRelationFromSCIM, MemberDepartmentRelationFromLDAP,
+		MemberDepartmentRelationFromDingTalk, MemberDepartmentRelationFromFeishu}
+	err := DB.Model(&MemberDepartmentRelation{}).Where("eid = ? AND bid = ? AND `from` NOT IN ?", u.Eid, u.UserID, boundFroms).Pluck("did", &dids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// 企业微信、SCIM、LDAP、钉钉、飞书同步的部门关系以对应来源的成员绑定ID关联
+	var boundDids []int64
+	err = DB.Model(&MemberDepartmentRelation{}).
+		Joins("JOIN member_bindings ON member_bindings.id = member_department_relations.bid AND member_bindings.eid = member_department_relations.eid AND member_bindings.`from` = member_department_relations.`from`").
+		Where("member_department_relations.eid = ? AND member_department_relations.`from` IN ? AND member_bindings.mid = ?",
+			u.Eid, boundFroms, u.UserID).
+		Pluck("member_department_relations.did", &boundDids).Error
+	if err != nil {
+		return nil, err
+	}
+	return append(dids, boundDids...), nil
+}
+
 func (u *User) GetUserGroupIds() ([]int64, error) {
 	if u.Type == UserTypeRegistered {
 		return []int64{u.GroupId}, nil
@@ -481,8 +555,7 @@ func (u *User) GetUserGroupIds() ([]int64, error) {
 			return nil, err
 		}
 
-		var dids []int64
-		err = DB.Model(&MemberDepartmentRelation{}).Where("eid = ? AND bid = ?", u.Eid, u.UserID).Pluck("did", &dids).Error
+		dids, err := u.GetDepartmentIDs()
 		if err != nil {
 			return nil, err
 		}
@@ -565,10 +638,13 @@ func GetUserCountByEIDAndType(eid int64, theType int) (int64, error) {
 	return count, nil
 }
 
-// InvalidateAccessToken 使用户的访问令牌失效
+// InvalidateAccessToken 使用户在所有设备上的访问令牌失效
 func (user *User) InvalidateAccessToken() error {
 	// 清空用户的访问令牌
 	user.AccessToken = ""
 	// 更新数据库中的用户记录
-	return DB.Model(user).Update("access_token", "").Error
+	if err := DB.Model(user).Update("access_token", "").Error; err != nil {
+		return err
+	}
+	return DeleteUserSessions(user.Eid, user.UserID, 0)
 }
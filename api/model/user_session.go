@@ -0,0 +1,106 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// 最近活跃时间的刷新间隔，避免每个请求都写库
+const sessionTouchInterval = time.Minute
+
+// UserSession 登录会话，每次登录签发一个访问令牌，可在多个设备同时登录
+type UserSession struct {
+	ID     int64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid    int64 `json:"eid" gorm:"not null;index"`
+	UserID int64 `json:"user_id" gorm:"not null;index"`
+	// TokenHash 访问令牌的 SHA-256 摘要，不保存令牌明文
+	TokenHash    string `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	Device       string `json:"device" gorm:"type:varchar(100)" example:"Chrome on Windows"`
+	IP           string `json:"ip" gorm:"type:varchar(64)" example:"127.0.0.1"`
+	UserAgent    string `json:"user_agent" gorm:"type:varchar(512)"`
+	LastSeenTime int64  `json:"last_seen_time" gorm:"not null;default:0"`
+	ExpiresAt    int64  `json:"expires_at" gorm:"not null;index"` // 与访问令牌有效期一致（毫秒）
+	BaseModel
+}
+
+func (UserSession) TableName() string {
+	return "user_sessions"
+}
+
+func HashAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func CreateUserSession(session *UserSession) error {
+	if session.LastSeenTime == 0 {
+		session.LastSeenTime = time.Now().UTC().UnixMilli()
+	}
+	return DB.Create(session).Error
+}
+
+// GetUserSessionByToken 查找令牌对应的未过期会话，不存在时返回 nil
+func GetUserSessionByToken(token string) *UserSession {
+	var session UserSession
+	result := DB.Where("token_hash = ? AND expires_at > ?", HashAccessToken(token), time.Now().UTC().UnixMilli()).
+		Limit(1).Find(&session)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil
+	}
+	return &session
+}
+
+// Touch 刷新最近活跃时间
+func (session *UserSession) Touch() {
+	now := time.Now().UTC().UnixMilli()
+	if now-session.LastSeenTime < sessionTouchInterval.Milliseconds() {
+		return
+	}
+	session.LastSeenTime = now
+	DB.Model(&UserSession{}).Where("id = ?", session.ID).Update("last_seen_time", now)
+}
+
+// GetUserSessions 用户未过期的会话，最近活跃的在前
+func GetUserSessions(eid int64, userID int64) ([]*UserSession, error) {
+	sessions := make([]*UserSession, 0)
+	err := DB.Where("eid = ? AND user_id = ? AND expires_at > ?", eid, userID, time.Now().UTC().UnixMilli()).
+		Order("last_seen_time DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+// DeleteUserSession 撤销单个会话，返回是否存在
+func DeleteUserSession(eid int64, userID int64, id int64) (bool, error) {
+	result := DB.Where("eid = ? AND user_id = ? AND id = ?", eid, userID, id).Delete(&UserSession{})
+	return result.RowsAffected > 0, result.Error
+}
+
+func DeleteUserSessionByToken(token string) error {
+	return DB.Where("token_hash = ?", HashAccessToken(token)).Delete(&UserSession{}).Error
+}
+
+// DeleteUserSessions 撤销用户的全部会话，exceptID 大于 0 时保留该会话
+func DeleteUserSessions(eid int64, userID int64, exceptID int64) error {
+	query := DB.Where("eid = ? AND user_id = ?", eid, userID)
+	if exceptID > 0 {
+		query = query.Where("id <> ?", exceptID)
+	}
+	return query.Delete(&UserSession{}).Error
+}
+
+// TrimUserSessions 只保留最近活跃的 keep 个会话，超出并发数限制时踢掉最早的登录
+func TrimUserSessions(eid int64, userID int64, keep int) error {
+	var ids []int64
+	err := DB.Model(&UserSession{}).Where("eid = ? AND user_id = ?", eid, userID).
+		Order("last_seen_time DESC").Order("id DESC").Pluck("id", &ids).Error
+	if err != nil || len(ids) <= keep {
+		return err
+	}
+	return DB.Where("id IN ?", ids[keep:]).Delete(&UserSession{}).Error
+}
+
+// DeleteExpiredUserSessions 清理已过期的会话
+func DeleteExpiredUserSessions() (int64, error) {
+	result := DB.Where("expires_at <= ?", time.Now().UTC().UnixMilli()).Delete(&UserSession{})
+	return result.RowsAffected, result.Error
+}
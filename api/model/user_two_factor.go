@@ -0,0 +1,56 @@
+package model
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+const (
+	TwoFactorMethodTOTP  = "totp"
+	TwoFactorMethodEmail = "email"
+	// TwoFactorMethodRecovery 恢复码只能用于登录或关闭两步验证，不能作为开启方式
+	TwoFactorMethodRecovery = "recovery"
+)
+
+// UserTwoFactor 用户两步验证设置
+// 开启 TOTP 前先保存待确认的密钥（Enabled 为 false），用户输入动态码确认后才启用
+type UserTwoFactor struct {
+	ID      int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	Eid     int64  `json:"eid" gorm:"not null;index"`
+	UserID  int64  `json:"user_id" gorm:"not null;uniqueIndex"`
+	Method  string `json:"method" gorm:"type:varchar(20);not null"` // totp / email
+	Enabled bool   `json:"enabled" gorm:"not null;default:false"`
+	// Secret TOTP 密钥（Base32），Method 为 email 时为空
+	Secret string `json:"-" gorm:"type:varchar(64)"`
+	// RecoveryCodes 恢复码的 SHA-256 摘要（JSON 数组），使用后移除
+	RecoveryCodes string `json:"-" gorm:"type:text"`
+	// LastUsedStep 最近一次通过校验的 TOTP 时间步，防止同一动态码被重复使用
+	LastUsedStep int64 `json:"-" gorm:"not null;default:0"`
+	BaseModel
+}
+
+func (UserTwoFactor) TableName() string {
+	return "user_two_factors"
+}
+
+// GetUserTwoFactor 获取用户的两步验证设置，未设置时返回 nil
+func GetUserTwoFactor(eid int64, userID int64) (*UserTwoFactor, error) {
+	var tf UserTwoFactor
+	err := DB.Where("eid = ? AND user_id = ?", eid, userID).First(&tf).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &tf, nil
+}
+
+func SaveUserTwoFactor(tf *UserTwoFactor) error {
+	return DB.Save(tf).Error
+}
+
+func DeleteUserTwoFactor(eid int64, userID int64) error {
+	return DB.Where("eid = ? AND user_id = ?", eid, userID).Delete(&UserTwoFactor{}).Error
+}
@@ -1,5 +1,12 @@
 package model
 
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
 // VerificationCode 验证码数据表
 // @Description 存储手机号/邮箱验证码及发送次数等信息
 type VerificationCode struct {
@@ -21,3 +28,35 @@ const (
 func (VerificationCode) TableName() string {
 	return "verification_codes"
 }
+
+// SaveVerificationCode 保存新发送的验证码，同一目标当日只保留一条记录并累计发送次数
+func SaveVerificationCode(codeType string, target string, code string, expiresAt int64) error {
+	now := time.Now().UTC().UnixMilli()
+	currentDayStart := now - (now % 86400000) // 计算当日0点时间戳（毫秒）
+
+	var existingVC VerificationCode
+	err := DB.Where("target = ? AND type = ? AND created_time >= ?", target, codeType, currentDayStart).First(&existingVC).Error
+	if err == nil {
+		existingVC.Code = code
+		existingVC.ExpiresAt = expiresAt
+		existingVC.DailyCount++
+		return DB.Save(&existingVC).Error
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	return DB.Create(&VerificationCode{
+		Type:       codeType,
+		Target:     target,
+		Code:       code,
+		ExpiresAt:  expiresAt,
+		DailyCount: 1,
+	}).Error
+}
+
+// ExpireVerificationCode 验证通过后立即作废，防止验证码在有效期内被重复使用
+func ExpireVerificationCode(codeType string, target string) error {
+	return DB.Model(&VerificationCode{}).
+		Where("target = ? AND type = ? AND expires_at > ?", target, codeType, time.Now().UTC().UnixMilli()).
+		Update("expires_at", 0).Error
+}
@@ -19,24 +19,24 @@ func SetApiRouter(router *gin.Engine) {
 
 		enterpriseRoute.GET("/current", controller.GetCurrentEnterprise)
 
-		enterpriseRoute.GET("/:id", middleware.UserTokenAuth(model.RoleAdminUser), controller.GetEnterprise)
+		enterpriseRoute.GET("/:id", middleware.PermissionAuth(model.PermEnterpriseRead), controller.GetEnterprise)
 		enterpriseRoute.PUT("/:id", controller.UpdateEnterprise)
-		enterpriseRoute.PATCH("/:id", middleware.UserTokenAuth(model.RoleAdminUser), controller.UpdateEnterpriseAttribute)
-		enterpriseRoute.DELETE("/:id", middleware.UserTokenAuth(model.RoleAdminUser), controller.DeleteEnterprise)
-		enterpriseRoute.POST("", middleware.UserTokenAuth(model.RoleAdminUser), controller.CreateEnterprise)
-		enterpriseRoute.GET("/banner", middleware.UserTokenAuth(model.RoleAdminUser), controller.GetEnterpriseBanner)
-		enterpriseRoute.PUT("/banner", middleware.UserTokenAuth(model.RoleAdminUser), controller.UpdateEnterpriseBanner)
-		enterpriseRoute.GET("/template_type", middleware.UserTokenAuth(model.RoleAdminUser), controller.GetEnterpriseTemplateType)
-		enterpriseRoute.PUT("/template_type", middleware.UserTokenAuth(model.RoleAdminUser), controller.UpdateEnterpriseTemplateType)
+		enterpriseRoute.PATCH("/:id", middleware.PermissionAuth(model.PermEnterpriseWrite), controller.UpdateEnterpriseAttribute)
+		enterpriseRoute.DELETE("/:id", middleware.PermissionAuth(model.PermEnterpriseWrite), controller.DeleteEnterprise)
+		enterpriseRoute.POST("", middleware.PermissionAuth(model.PermEnterpriseWrite), controller.CreateEnterprise)
+		enterpriseRoute.GET("/banner", middleware.PermissionAuth(model.PermEnterpriseRead), controller.GetEnterpriseBanner)
+		enterpriseRoute.PUT("/banner", middleware.PermissionAuth(model.PermEnterpriseWrite), controller.UpdateEnterpriseBanner)
+		enterpriseRoute.GET("/template_type", middleware.PermissionAuth(model.PermEnterpriseRead), controller.GetEnterpriseTemplateType)
+		enterpriseRoute.PUT("/template_type", middleware.PermissionAuth(model.PermEnterpriseWrite), controller.UpdateEnterpriseTemplateType)
 	}
 
 	enterpriseConfigRoute := apiRouter.Group("/enterprise-configs")
 	{
-		enterpriseConfigRoute.GET("", middleware.UserTokenAuth(model.RoleAdminUser), controller.GetEnterpriseConfigTypes)
-		enterpriseConfigRoute.GET("/:type", middleware.UserTokenAuth(model.RoleAdminUser), controller.GetEnterpriseConfig)
+		enterpriseConfigRoute.GET("", middleware.PermissionAuth(model.PermEnterpriseRead), controller.GetEnterpriseConfigTypes)
+		enterpriseConfigRoute.GET("/:type", middleware.PermissionAuth(model.PermEnterpriseRead), controller.GetEnterpriseConfig)
 		enterpriseConfigRoute.GET("/:type/enabled", controller.IsEnterpriseConfigEnabled)
-		enterpriseConfigRoute.POST("/:type", middleware.UserTokenAuth(model.RoleAdminUser), controller.SaveEnterpriseConfig)
-		enterpriseConfigRoute.PUT("/:type/toggle", middleware.UserTokenAuth(model.RoleAdminUser), controller.ToggleEnterpriseConfig)
+		enterpriseConfigRoute.POST("/:type", middleware.PermissionAuth(model.PermEnterpriseWrite), controller.SaveEnterpriseConfig)
+		enterpriseConfigRoute.PUT("/:type/toggle", middleware.PermissionAuth(model.PermEnterpriseWrite), controller.ToggleEnterpriseConfig)
 	}
 
 	commonRoute := apiRouter.Group("")
@@ -56,7 +56,7 @@ func SetApiRouter(router *gin.Engine) {
 	emailRoute := apiRouter.Group("/email")
 	{
 		emailRoute.POST("/send_verification", controller.SendVerificationEmail)
-		emailRoute.POST("/send_test", middleware.UserTokenAuth(model.RoleAdminUser), controller.SendTestEmail)
+		emailRoute.POST("/send_test", middleware.PermissionAuth(model.PermEnterpriseWrite), controller.SendTestEmail)
 	}
 
 	userRoute := apiRouter.Group("/users")
@@ -67,49 +67,68 @@ func SetApiRouter(router *gin.Engine) {
 	userRoute.PUT("/me", middleware.UserTokenAuth(model.RoleCommonUser), controller.UpdateCurrentUser)
 	userRoute.POST("/system_log", middleware.UserTokenAuth(model.RoleCommonUser), controller.CreateSystemLogs)
 	userRoute.PUT("/:id/default_subscription", middleware.UserTokenAuth(model.RoleCommonUser), controller.SetUserToDefaultSubscription)
-	userRoute.Use(middleware.UserTokenAuth(model.RoleAdminUser))
-	{
-		userRoute.POST("", controller.EnterpriseAddUser)
-		userRoute.GET("", controller.EnterpriseUsers)
-		userRoute.DELETE("/:id", controller.DeleteEnterpriseUser)
-		userRoute.PUT("/:id", controller.UpdateEnterpriseUser)
-		userRoute.GET("/:user_id/agents/:agent_id/messages", controller.GetUserMessages)
-		userRoute.GET("/:user_id/conversations", controller.GetUserConversations)
-		userRoute.PUT("/batch/admin", controller.SetUserAsAdmin)
-		userRoute.DELETE("/batch/admin", controller.UnsetUserAsAdmin)
-		userRoute.POST("/internal/batch", controller.BatchAddInternalUsers)
-		userRoute.PUT("/register/to/internal", controller.RegisterUserToInternal)
-		userRoute.GET("/internal", controller.GetInternalUsers)
-		userRoute.PATCH("/:id/status", controller.UpdateUserStatus)
-		userRoute.PUT("/internal/:id", controller.UpdateInternalUser)
-		userRoute.GET("/admin", controller.EnterpriseUsers)
-		userRoute.GET("/organization", controller.GetOrganizationUserList)
+	twoFactorRoute := userRoute.Group("/me/2fa", middleware.UserTokenAuth(model.RoleCommonUser))
+	{
+		twoFactorRoute.GET("", controller.GetTwoFactorStatus)
+		twoFactorRoute.DELETE("", controller.DisableTwoFactor)
+		twoFactorRoute.POST("/totp/setup", controller.SetupTwoFactorTOTP)
+		twoFactorRoute.POST("/totp/enable", controller.EnableTwoFactorTOTP)
+		twoFactorRoute.POST("/email/send", controller.SendTwoFactorEmail)
+		twoFactorRoute.POST("/email/enable", controller.EnableTwoFactorEmail)
+		twoFactorRoute.POST("/recovery_codes", controller.RegenerateTwoFactorRecoveryCodes)
+	}
+	userRoute.GET("/me/permissions", middleware.UserTokenAuth(model.RoleGuestUser), controller.GetMyPermissions)
+	sessionRoute := userRoute.Group("/me/sessions", middleware.UserTokenAuth(model.RoleCommonUser))
+	{
+		sessionRoute.GET("", controller.GetMySessions)
+		sessionRoute.DELETE("", controller.RevokeMyOtherSessions)
+		sessionRoute.DELETE("/:id", controller.RevokeMySession)
+	}
+	{
+		userRoute.POST("", middleware.PermissionAuth(model.PermUserWrite), controller.EnterpriseAddUser)
+		userRoute.GET("", middleware.PermissionAuth(model.PermUserRead), controller.EnterpriseUsers)
+		userRoute.DELETE("/:id", middleware.PermissionAuth(model.PermUserWrite), controller.DeleteEnterpriseUser)
+		userRoute.PUT("/:id", middleware.PermissionAuth(model.PermUserWrite), controller.UpdateEnterpriseUser)
+		userRoute.GET("/:user_id/agents/:agent_id/messages", middleware.PermissionAuth(model.PermConversationRead), controller.GetUserMessages)
+		userRoute.GET("/:user_id/conversations", middleware.PermissionAuth(model.PermConversationRead), controller.GetUserConversations)
+		// 管理员拥有全部权限，只有拥有全部权限的成员才能设置管理员
+		userRoute.PUT("/batch/admin", middleware.PermissionAuth(model.PermAll), controller.SetUserAsAdmin)
+		userRoute.DELETE("/batch/admin", middleware.PermissionAuth(model.PermAll), controller.UnsetUserAsAdmin)
+		userRoute.POST("/internal/batch", middleware.PermissionAuth(model.PermUserWrite), controller.BatchAddInternalUsers)
+		userRoute.PUT("/register/to/internal", middleware.PermissionAuth(model.PermUserWrite), controller.RegisterUserToInternal)
+		userRoute.GET("/internal", middleware.PermissionAuth(model.PermUserRead), controller.GetInternalUsers)
+		userRoute.PATCH("/:id/status", middleware.PermissionAuth(model.PermUserWrite), controller.UpdateUserStatus)
+		userRoute.DELETE("/:id/2fa", middleware.PermissionAuth(model.PermUserWrite), controller.ResetUserTwoFactor)
+		userRoute.GET("/:id/sessions", middleware.PermissionAuth(model.PermUserRead), controller.GetUserSessions)
+		userRoute.DELETE("/:id/sessions", middleware.PermissionAuth(model.PermUserWrite), controller.ForceLogoutUser)
+		userRoute.PUT("/internal/:id", middleware.PermissionAuth(model.PermUserWrite), controller.UpdateInternalUser)
+		userRoute.GET("/admin", middleware.PermissionAuth(model.PermUserRead), controller.EnterpriseUsers)
+		userRoute.GET("/organization", middleware.PermissionAuth(model.PermUserRead), controller.GetOrganizationUserList)
 	}
 
 	groupRoute := apiRouter.Group("/groups")
 	groupRoute.GET("type/current/:group_type", controller.GetGroups)
 	groupRoute.POST("/prompt", middleware.UserTokenAuth(model.RoleCommonUser), controller.CreateGroup)
-	groupRoute.Use(middleware.UserTokenAuth(model.RoleAdminUser))
-	{
-		groupRoute.POST("", controller.CreateGroup)
-		groupRoute.GET("/:id", controller.GetGroup)
-		groupRoute.PUT("/:id", controller.UpdateGroup)
-		groupRoute.DELETE("/:id", controller.DeleteGroup)
-		groupRoute.POST("type/:group_type", controller.BatchSubmitGroups)
-		groupRoute.GET("type/:group_type", controller.GetGroups)
-		groupRoute.POST("/:id/agents", controller.AddAgentsToGroup)
-		groupRoute.DELETE("/:id/agents", controller.RemoveAgentsFromGroup)
-		groupRoute.GET("/:id/agents", controller.GetGroupAgents)
-		groupRoute.DELETE("/:id/users", controller.RemoveUsersFromGroup)
-		groupRoute.GET("/:id/users", controller.GetGroupUsers)
-		groupRoute.POST("/:id/users/batch", controller.BatchAddUsersToGroup)
+	{
+		groupRoute.POST("", middleware.PermissionAuth(model.PermGroupWrite), controller.CreateGroup)
+		groupRoute.GET("/:id", middleware.PermissionAuth(model.PermGroupRead), controller.GetGroup)
+		groupRoute.PUT("/:id", middleware.PermissionAuth(model.PermGroupWrite), controller.UpdateGroup)
+		groupRoute.DELETE("/:id", middleware.PermissionAuth(model.PermGroupWrite), controller.DeleteGroup)
+		groupRoute.POST("type/:group_type", middleware.PermissionAuth(model.PermGroupWrite), controller.BatchSubmitGroups)
+		groupRoute.GET("type/:group_type", middleware.PermissionAuth(model.PermGroupRead), controller.GetGroups)
+		groupRoute.POST("/:id/agents", middleware.PermissionAuth(model.PermGroupWrite), controller.AddAgentsToGroup)
+		groupRoute.DELETE("/:id/agents", middleware.PermissionAuth(model.PermGroupWrite), controller.RemoveAgentsFromGroup)
+		groupRoute.GET("/:id/agents", middleware.PermissionAuth(model.PermGroupRead), controller.GetGroupAgents)
+		groupRoute.DELETE("/:id/users", middleware.PermissionAuth(model.PermGroupWrite), controller.RemoveUsersFromGroup)
+		groupRoute.GET("/:id/users", middleware.PermissionAuth(model.PermGroupRead), controller.GetGroupUsers)
+		groupRoute.POST("/:id/users/batch", middleware.PermissionAuth(model.PermGroupWrite), controller.BatchAddUsersToGroup)
 	}
 
 	aiLinkRoute := apiRouter.Group("/ai_links")
 	aiLinkRoute.GET("/current", controller.GetCurrentSiteAILinks)
 	aiLinkRoute.GET("/default", controller.GetDefaultAILinks)
 	aiLinkRoute.GET("/:id", middleware.UserTokenAuth(model.RoleCommonUser), controller.GetAILink)
-	aiLinkRoute.Use(middleware.UserTokenAuth(model.RoleAdminUser))
+	aiLinkRoute.Use(middleware.PermissionAuth(model.PermAILinkWrite))
 	{
 		aiLinkRoute.POST("", controller.CreateAILink)
 		aiLinkRoute.GET("", controller.GetAILinks)
@@ -124,7 +143,7 @@ func SetApiRouter(router *gin.Engine) {
 		settingRoute.GET("/:id", controller.GetSetting)
 		settingRoute.PUT("/:id", middleware.UserTokenAuth(model.RoleGuestUser), controller.UpdateSetting)
 		settingRoute.DELETE("/:id", middleware.UserTokenAuth(model.RoleGuestUser), controller.DeleteSetting)
-		settingRoute.GET("", middleware.UserTokenAuth(model.RoleAdminUser), controller.GetSettings)
+		settingRoute.GET("", middleware.PermissionAuth(model.PermEnterpriseRead), controller.GetSettings)
 		settingRoute.GET("/group/:group_name", controller.GetSettingsByGroup)
 		settingRoute.GET("/key/:key", controller.GetSettingByKey)
 		settingRoute.POST("/default_links", middleware.UserTokenAuth(model.RoleGuestUser), controller.BatchUpdateDefaultPromptLinks) // 批量更新默认提示词链接
@@ -132,15 +151,14 @@ func SetApiRouter(router *gin.Engine) {
 	}
 
 	channelGroup := apiRouter.Group("/channels")
-	channelGroup.Use(middleware.UserTokenAuth(model.RoleAdminUser))
 	{
-		channelGroup.POST("", controller.CreateChannel)
-		channelGroup.GET("", controller.GetChannels)
-		channelGroup.GET("/:channel_id", controller.GetChannel)
-		channelGroup.PUT("/:channel_id", controller.UpdateChannel)
-		channelGroup.DELETE("/:channel_id", controller.DeleteChannel)
-		channelGroup.GET("/test/:channel_id", controller.TestChannel)
-		channelGroup.GET("/models", controller.ListAllModels)
+		channelGroup.POST("", middleware.PermissionAuth(model.PermChannelWrite), controller.CreateChannel)
+		channelGroup.GET("", middleware.PermissionAuth(model.PermChannelRead), controller.GetChannels)
+		channelGroup.GET("/:channel_id", middleware.PermissionAuth(model.PermChannelRead), controller.GetChannel)
+		channelGroup.PUT("/:channel_id", middleware.PermissionAuth(model.PermChannelWrite), controller.UpdateChannel)
+		channelGroup.DELETE("/:channel_id", middleware.PermissionAuth(model.PermChannelWrite), controller.DeleteChannel)
+		channelGroup.GET("/test/:channel_id", middleware.PermissionAuth(model.PermChannelWrite), controller.TestChannel)
+		channelGroup.GET("/models", middleware.PermissionAuth(model.PermChannelRead), controller.ListAllModels)
 	}
 
 	agentGroup := apiRouter.Group("/agents")
@@ -148,44 +166,117 @@ func SetApiRouter(router *gin.Engine) {
 	agentGroup.GET("/available", controller.GetAvailableAgents)
 	agentGroup.Use(middleware.UserTokenAuth(model.RoleGuestUser))
 	{
-		agentGroup.POST("", controller.CreateAgent)
+		agentGroup.POST("", middleware.PermissionAuth(model.PermAgentWrite), controller.CreateAgent)
 		agentGroup.GET("", controller.GetAgents)
 		agentGroup.GET("/group", controller.GetAgentsByGroup)
+		agentGroup.GET("/export", middleware.PermissionAuth(model.PermAgentRead), controller.ExportAgents)
+		agentGroup.POST("/import", middleware.PermissionAuth(model.PermAgentWrite), controller.ImportAgents)
 		agentGroup.GET("/:agent_id", controller.GetAgent)
-		agentGroup.PUT("/:agent_id", controller.UpdateAgent)
-		agentGroup.DELETE("/:agent_id", controller.DeleteAgent)
+		agentGroup.PUT("/:agent_id", middleware.PermissionAuth(model.PermAgentWrite), controller.UpdateAgent)
+		agentGroup.DELETE("/:agent_id", middleware.PermissionAuth(model.PermAgentWrite), controller.DeleteAgent)
 		agentGroup.GET("/:agent_id/messages", controller.GetMessagesByUserAndAgent)
-		agentGroup.PATCH("/:agent_id/status", controller.UpdateAgentStatus)
+		agentGroup.PATCH("/:agent_id/status", middleware.PermissionAuth(model.PermAgentWrite), controller.UpdateAgentStatus)
 		agentGroup.GET("/internal_users", controller.GetInternalUserAgents)
 		agentGroup.GET("/:agent_id/conversations", controller.GetAgentConversations)
+		agentGroup.GET("/:agent_id/knowledge_bases", middleware.PermissionAuth(model.PermAgentRead), controller.GetAgentKnowledgeBases)
+		agentGroup.PUT("/:agent_id/knowledge_bases", middleware.PermissionAuth(model.PermAgentWrite), controller.SetAgentKnowledgeBases)
+		agentGroup.GET("/:agent_id/draft", middleware.PermissionAuth(model.PermAgentRead), controller.GetAgentDraft)
+		agentGroup.DELETE("/:agent_id/draft", middleware.PermissionAuth(model.PermAgentWrite), controller.DiscardAgentDraft)
+		agentGroup.POST("/:agent_id/publish", middleware.PermissionAuth(model.PermAgentWrite), controller.PublishAgent)
+		agentGroup.GET("/:agent_id/versions", middleware.PermissionAuth(model.PermAgentRead), controller.GetAgentVersions)
+		agentGroup.GET("/:agent_id/versions/diff", middleware.PermissionAuth(model.PermAgentRead), controller.DiffAgentVersions)
+		agentGroup.GET("/:agent_id/versions/:version", middleware.PermissionAuth(model.PermAgentRead), controller.GetAgentVersion)
+		agentGroup.POST("/:agent_id/versions/:version/rollback", middleware.PermissionAuth(model.PermAgentWrite), controller.RollbackAgentVersion)
+		agentGroup.GET("/:agent_id/export", middleware.PermissionAuth(model.PermAgentRead), controller.ExportAgent)
+		agentGroup.GET("/:agent_id/experiments", middleware.PermissionAuth(model.PermAgentRead), controller.GetAgentExperiments)
+		agentGroup.POST("/:agent_id/experiments", middleware.PermissionAuth(model.PermAgentWrite), controller.CreateAgentExperiment)
+		agentGroup.GET("/:agent_id/experiments/:experiment_id", middleware.PermissionAuth(model.PermAgentRead), controller.GetAgentExperiment)
+		agentGroup.PUT("/:agent_id/experiments/:experiment_id", middleware.PermissionAuth(model.PermAgentWrite), controller.UpdateAgentExperiment)
+		agentGroup.DELETE("/:agent_id/experiments/:experiment_id", middleware.PermissionAuth(model.PermAgentWrite), controller.DeleteAgentExperiment)
+		agentGroup.POST("/:agent_id/experiments/:experiment_id/start", middleware.PermissionAuth(model.PermAgentWrite), controller.StartAgentExperiment)
+		agentGroup.POST("/:agent_id/experiments/:experiment_id/stop", middleware.PermissionAuth(model.PermAgentWrite), controller.StopAgentExperiment)
+		agentGroup.POST("/:agent_id/experiments/:experiment_id/promote", middleware.PermissionAuth(model.PermAgentWrite), controller.PromoteAgentExperiment)
+		agentGroup.GET("/:agent_id/experiments/:experiment_id/results", middleware.PermissionAuth(model.PermAgentRead), controller.GetAgentExperimentResults)
+		agentGroup.GET("/:agent_id/eval_datasets", middleware.PermissionAuth(model.PermAgentRead), controller.GetEvalDatasets)
+		agentGroup.POST("/:agent_id/eval_datasets", middleware.PermissionAuth(model.PermAgentWrite), controller.CreateEvalDataset)
+		agentGroup.GET("/:agent_id/eval_datasets/:dataset_id", middleware.PermissionAuth(model.PermAgentRead), controller.GetEvalDataset)
+		agentGroup.PUT("/:agent_id/eval_datasets/:dataset_id", middleware.PermissionAuth(model.PermAgentWrite), controller.UpdateEvalDataset)
+		agentGroup.DELETE("/:agent_id/eval_datasets/:dataset_id", middleware.PermissionAuth(model.PermAgentWrite), controller.DeleteEvalDataset)
+		agentGroup.POST("/:agent_id/eval_datasets/:dataset_id/runs", middleware.PermissionAuth(model.PermAgentWrite), controller.CreateEvalRun)
+		agentGroup.GET("/:agent_id/eval_runs", middleware.PermissionAuth(model.PermAgentRead), controller.GetEvalRuns)
+		agentGroup.GET("/:agent_id/eval_runs/:run_id", middleware.PermissionAuth(model.PermAgentRead), controller.GetEvalRun)
+		agentGroup.GET("/:agent_id/guardrail", middleware.PermissionAuth(model.PermAgentRead), controller.GetAgentGuardrailPolicy)
+		agentGroup.PUT("/:agent_id/guardrail", middleware.PermissionAuth(model.PermAgentWrite), controller.UpdateAgentGuardrailPolicy)
+		agentGroup.DELETE("/:agent_id/guardrail", middleware.PermissionAuth(model.PermAgentWrite), controller.DeleteAgentGuardrailPolicy)
+		agentGroup.GET("/:agent_id/schedules", middleware.PermissionAuth(model.PermAgentRead), controller.GetSchedules)
+		agentGroup.POST("/:agent_id/schedules", middleware.PermissionAuth(model.PermAgentWrite), controller.CreateSchedule)
+		agentGroup.GET("/:agent_id/schedules/:schedule_id", middleware.PermissionAuth(model.PermAgentRead), controller.GetSchedule)
+		agentGroup.PUT("/:agent_id/schedules/:schedule_id", middleware.PermissionAuth(model.PermAgentWrite), controller.UpdateSchedule)
+		agentGroup.DELETE("/:agent_id/schedules/:schedule_id", middleware.PermissionAuth(model.PermAgentWrite), controller.DeleteSchedule)
+		agentGroup.POST("/:agent_id/schedules/:schedule_id/run", middleware.PermissionAuth(model.PermAgentWrite), controller.RunSchedule)
+		agentGroup.GET("/:agent_id/schedules/:schedule_id/runs", middleware.PermissionAuth(model.PermAgentRead), controller.GetScheduleRuns)
+		agentGroup.GET("/:agent_id/embeds", middleware.PermissionAuth(model.PermAgentRead), controller.GetAgentEmbeds)
+		agentGroup.POST("/:agent_id/embeds", middleware.PermissionAuth(model.PermAgentWrite), controller.CreateAgentEmbed)
+		agentGroup.PUT("/:agent_id/embeds/:embed_id", middleware.PermissionAuth(model.PermAgentWrite), controller.UpdateAgentEmbed)
+		agentGroup.DELETE("/:agent_id/embeds/:embed_id", middleware.PermissionAuth(model.PermAgentWrite), controller.DeleteAgentEmbed)
+		agentGroup.POST("/:agent_id/embeds/:embed_id/reset_token", middleware.PermissionAuth(model.PermAgentWrite), controller.ResetAgentEmbedToken)
+		agentGroup.GET("/:agent_id/embeds/:embed_id/sessions", middleware.PermissionAuth(model.PermAgentRead), controller.GetAgentEmbedSessions)
+	}
+
+	// 嵌入组件的公开接口，跨域由 EmbedAuth 按嵌入配置的域名白名单处理
+	embedRouter := apiRouter.Group("/embed/:token")
+	embedRouter.Use(middleware.EmbedAuth())
+	{
+		embedRouter.GET("", controller.GetEmbedInfo)
+		embedRouter.POST("/sessions", controller.CreateEmbedSession)
+		embedRouter.POST("/chat", middleware.EmbedSessionAuth(), controller.EmbedChat)
+		embedRouter.GET("/messages", middleware.EmbedSessionAuth(), controller.GetEmbedMessages)
+		embedRouter.OPTIONS("", controller.EmbedPreflight)
+		embedRouter.OPTIONS("/sessions", controller.EmbedPreflight)
+		embedRouter.OPTIONS("/chat", controller.EmbedPreflight)
+		embedRouter.OPTIONS("/messages", controller.EmbedPreflight)
+	}
+
+	agentTemplateGroup := apiRouter.Group("/agent_templates")
+	agentTemplateGroup.Use(middleware.UserTokenAuth(model.RoleGuestUser))
+	{
+		agentTemplateGroup.GET("", middleware.PermissionAuth(model.PermAgentRead), controller.GetAgentTemplates)
+		agentTemplateGroup.GET("/:id", middleware.PermissionAuth(model.PermAgentRead), controller.GetAgentTemplate)
+		agentTemplateGroup.POST("", middleware.PermissionAuth(model.PermAgentWrite), controller.CreateAgentTemplate)
+		agentTemplateGroup.DELETE("/:id", middleware.PermissionAuth(model.PermAgentWrite), controller.DeleteAgentTemplate)
+		agentTemplateGroup.POST("/:id/instantiate", middleware.PermissionAuth(model.PermAgentWrite), controller.InstantiateAgentTemplate)
 	}
 
 	conversationGroup := apiRouter.Group("/conversations")
 	conversationGroup.Use(middleware.UserTokenAuth(model.RoleGuestUser))
 	{
 		conversationGroup.POST("", controller.CreateConversation)
+		conversationGroup.POST("/import", controller.ImportConversations)
 		conversationGroup.GET("", controller.GetConversations)
 		conversationGroup.GET("/:conversation_id", controller.GetConversation)
 		conversationGroup.PUT("/:conversation_id", controller.UpdateConversation)
 		conversationGroup.DELETE("/:conversation_id", controller.DeleteConversation)
 		//conversationGroup.POST("/:conversation_id/messages", controller.CreateMessage)
 		conversationGroup.GET("/:conversation_id/messages", controller.GetMessagesByConversation)
+		conversationGroup.GET("/:conversation_id/messages/:message_id/siblings", controller.GetMessageSiblings)
+		conversationGroup.GET("/:conversation_id/messages/:message_id/sub_agent_messages", controller.GetSubAgentMessages)
+		conversationGroup.PUT("/:conversation_id/active_message", controller.SwitchConversationBranch)
+		conversationGroup.GET("/:conversation_id/export", controller.ExportConversation)
 	}
 
 	subscription := apiRouter.Group("/subscriptions")
 	{
 		subscription.GET("/settings", controller.GetSubscriptionList)
 		subscription.
-			POST("/batch", middleware.UserTokenAuth(model.RoleAdminUser), controller.BatchSubscriptionOperation)
+			POST("/batch", middleware.PermissionAuth(model.PermSubscriptionWrite), controller.BatchSubscriptionOperation)
 	}
 
 	providerRouter := apiRouter.Group("/providers")
-	providerRouter.Use(middleware.UserTokenAuth(model.RoleAdminUser))
 	{
-		providerRouter.POST("", controller.CreateProvider)
-		providerRouter.GET("", controller.GetProviders)
-		providerRouter.PUT("/:id", controller.UpdateProvider)
-		providerRouter.DELETE("/:id", controller.DeleteProvider)
+		providerRouter.POST("", middleware.PermissionAuth(model.PermChannelWrite), controller.CreateProvider)
+		providerRouter.GET("", middleware.PermissionAuth(model.PermChannelRead), controller.GetProviders)
+		providerRouter.PUT("/:id", middleware.PermissionAuth(model.PermChannelWrite), controller.UpdateProvider)
+		providerRouter.DELETE("/:id", middleware.PermissionAuth(model.PermChannelWrite), controller.DeleteProvider)
 	}
 
 	callbackRouter := apiRouter.Group("/callback")
@@ -195,20 +286,20 @@ func SetApiRouter(router *gin.Engine) {
 	}
 
 	cozeRouter := apiRouter.Group("/coze")
-	cozeRouter.Use(middleware.UserTokenAuth(model.RoleAdminUser))
+	cozeRouter.Use(middleware.PermissionAuth(model.PermChannelRead))
 	{
 		cozeRouter.GET("/workspaces", controller.GetCozeAllWorkspaces)
 		cozeRouter.GET("/workspaces/:workspace_id/bots", controller.GetCozeAllBots)
 	}
 
 	AppBuilderRouter := apiRouter.Group("/appbuilder")
-	AppBuilderRouter.Use(middleware.UserTokenAuth(model.RoleAdminUser))
+	AppBuilderRouter.Use(middleware.PermissionAuth(model.PermChannelRead))
 	{
 		AppBuilderRouter.GET("/bots", controller.GetAppBuilderAllBots)
 	}
 
 	ai53Router := apiRouter.Group("/53ai")
-	ai53Router.Use(middleware.UserTokenAuth(model.RoleAdminUser))
+	ai53Router.Use(middleware.PermissionAuth(model.PermChannelRead))
 	{
 		ai53Router.GET("/bots", controller.Get53AIAllBots)
 		ai53Router.GET("/workflows", controller.Get53AIAllWorkflows)
@@ -230,28 +321,28 @@ func SetApiRouter(router *gin.Engine) {
 	{
 		paySettingRouter.GET("", middleware.UserTokenAuth(model.RoleGuestUser), controller.GetPaySettings)
 		paySettingRouter.GET("/:id", middleware.UserTokenAuth(model.RoleGuestUser), controller.GetPaySetting)
-		paySettingRouter.POST("", middleware.UserTokenAuth(model.RoleAdminUser), controller.CreatePaySetting)
+		paySettingRouter.POST("", middleware.PermissionAuth(model.PermPaymentWrite), controller.CreatePaySetting)
 		// paySettingRouter.PUT("/:id", controller.UpdatePaySetting)
-		paySettingRouter.DELETE("/:id", middleware.UserTokenAuth(model.RoleAdminUser), controller.DeletePaySetting)
-		paySettingRouter.PATCH("/:id/config", middleware.UserTokenAuth(model.RoleAdminUser), controller.UpdatePayConfig)
-		paySettingRouter.PATCH("/:id/status", middleware.UserTokenAuth(model.RoleAdminUser), controller.UpdatePayStatus)
+		paySettingRouter.DELETE("/:id", middleware.PermissionAuth(model.PermPaymentWrite), controller.DeletePaySetting)
+		paySettingRouter.PATCH("/:id/config", middleware.PermissionAuth(model.PermPaymentWrite), controller.UpdatePayConfig)
+		paySettingRouter.PATCH("/:id/status", middleware.PermissionAuth(model.PermPaymentWrite), controller.UpdatePayStatus)
 	}
 
 	orderRouter := apiRouter.Group("/orders")
 	{
 		orderRouter.POST("", middleware.UserTokenAuth(model.RoleCommonUser), controller.CreateOrder)
-		orderRouter.PUT("/:id/manual", middleware.UserTokenAuth(model.RoleAdminUser), controller.UpdateManualTransferOrder)
+		orderRouter.PUT("/:id/manual", middleware.PermissionAuth(model.PermOrderWrite), controller.UpdateManualTransferOrder)
 		orderRouter.GET("", middleware.UserTokenAuth(model.RoleCommonUser), controller.GetOrders)
 		orderRouter.GET("/me", middleware.UserTokenAuth(model.RoleCommonUser), controller.GetOrders)
-		orderRouter.GET("/:id", middleware.UserTokenAuth(model.RoleAdminUser), controller.GetOrder)
-		orderRouter.PATCH("/:id/status", middleware.UserTokenAuth(model.RoleAdminUser), controller.UpdateOrderStatus) // Only manual transfers can be marked as paid
-		orderRouter.DELETE("/:id", middleware.UserTokenAuth(model.RoleAdminUser), controller.DeleteOrder)             // Only manual transfers can be deleted, but paid ones cannot be deleted
+		orderRouter.GET("/:id", middleware.PermissionAuth(model.PermOrderRead), controller.GetOrder)
+		orderRouter.PATCH("/:id/status", middleware.PermissionAuth(model.PermOrderWrite), controller.UpdateOrderStatus) // Only manual transfers can be marked as paid
+		orderRouter.DELETE("/:id", middleware.PermissionAuth(model.PermOrderWrite), controller.DeleteOrder)             // Only manual transfers can be deleted, but paid ones cannot be deleted
 		orderRouter.GET("/status/:order_id", middleware.UserTokenAuth(model.RoleCommonUser), controller.QueryOrderStatus)
 		orderRouter.POST("/:id/confirm", middleware.UserTokenAuth(model.RoleCommonUser), controller.ConfirmManualPayment)
-		orderRouter.GET("/user", middleware.UserTokenAuth(model.RoleAdminUser), controller.GetUserOrders)
+		orderRouter.GET("/user", middleware.PermissionAuth(model.PermOrderRead), controller.GetUserOrders)
 		orderRouter.POST("/:id/close", middleware.UserTokenAuth(model.RoleCommonUser), controller.CloseOrder)
-		orderRouter.GET("/trade/:order_id", middleware.UserTokenAuth(model.RoleAdminUser), controller.QueryTradeOrder)
-		orderRouter.POST("/trade/:order_id/refund", middleware.UserTokenAuth(model.RoleAdminUser), controller.RefunTradeOrder)
+		orderRouter.GET("/trade/:order_id", middleware.PermissionAuth(model.PermOrderRead), controller.QueryTradeOrder)
+		orderRouter.POST("/trade/:order_id/refund", middleware.PermissionAuth(model.PermOrderRefund), controller.RefunTradeOrder)
 	}
 
 	paymentRouter := apiRouter.Group("/payment")
@@ -262,41 +353,61 @@ func SetApiRouter(router *gin.Engine) {
 		paymentRouter.POST("/alipay/notify/:id", controller.AlipayNotify)
 	}
 
+	roleGroup := apiRouter.Group("/roles")
+	{
+		roleGroup.GET("/permissions", middleware.PermissionAuth(model.PermRoleRead), controller.GetPermissionDefinitions)
+		roleGroup.GET("", middleware.PermissionAuth(model.PermRoleRead), controller.GetRoles)
+		roleGroup.POST("", middleware.PermissionAuth(model.PermRoleWrite), controller.CreateRole)
+		roleGroup.PUT("/:id", middleware.PermissionAuth(model.PermRoleWrite), controller.UpdateRole)
+		roleGroup.DELETE("/:id", middleware.PermissionAuth(model.PermRoleWrite), controller.DeleteRole)
+		roleGroup.GET("/:id/assignments", middleware.PermissionAuth(model.PermRoleRead), controller.GetRoleAssignments)
+		roleGroup.POST("/:id/assignments", middleware.PermissionAuth(model.PermRoleWrite), controller.AssignRole)
+		roleGroup.DELETE("/:id/assignments", middleware.PermissionAuth(model.PermRoleWrite), controller.UnassignRole)
+	}
+
 	// Department routes
 	departmentGroup := apiRouter.Group("/departments")
-	departmentGroup.Use(middleware.UserTokenAuth(model.RoleAdminUser))
 	{
-		departmentGroup.POST("", controller.CreateDepartment)
-		departmentGroup.GET("", controller.GetDepartments)
-		departmentGroup.GET("/:did", controller.GetDepartment)
-		departmentGroup.PUT("/:did", controller.UpdateDepartment)
-		departmentGroup.DELETE("/:did", controller.DeleteDepartment)
-		departmentGroup.GET("/children/:pdid", controller.GetChildDepartments)
-		departmentGroup.GET("/tree", controller.GetDepartmentTree)
-		departmentGroup.POST("/sync/:from", controller.SyncOrganization)
-		departmentGroup.POST("/bind-member", controller.DepartmentBindMember)
-		departmentGroup.DELETE("/bind-member", controller.DepartmentUnbindMember)
+		departmentGroup.POST("", middleware.PermissionAuth(model.PermDepartmentWrite), controller.CreateDepartment)
+		departmentGroup.GET("", middleware.PermissionAuth(model.PermDepartmentRead), controller.GetDepartments)
+		departmentGroup.GET("/:did", middleware.PermissionAuth(model.PermDepartmentRead), controller.GetDepartment)
+		departmentGroup.PUT("/:did", middleware.PermissionAuth(model.PermDepartmentWrite), controller.UpdateDepartment)
+		departmentGroup.DELETE("/:did", middleware.PermissionAuth(model.PermDepartmentWrite), controller.DeleteDepartment)
+		departmentGroup.GET("/children/:pdid", middleware.PermissionAuth(model.PermDepartmentRead), controller.GetChildDepartments)
+		departmentGroup.GET("/tree", middleware.PermissionAuth(model.PermDepartmentRead), controller.GetDepartmentTree)
+		departmentGroup.POST("/sync/:from", middleware.PermissionAuth(model.PermDepartmentWrite), controller.SyncOrganization)
+		departmentGroup.POST("/bind-member", middleware.PermissionAuth(model.PermDepartmentWrite), controller.DepartmentBindMember)
+		departmentGroup.DELETE("/bind-member", middleware.PermissionAuth(model.PermDepartmentWrite), controller.DepartmentUnbindMember)
 	}
 
 	promptGroup := apiRouter.Group("/prompts")
 	{
 		promptGroup.GET("", controller.GetPrompts)
-		promptGroup.GET("/admin", middleware.UserTokenAuth(model.RoleAdminUser), controller.GetPrompts)
-		promptGroup.POST("/system", middleware.UserTokenAuth(model.RoleAdminUser), controller.CreatePrompt)
+		promptGroup.GET("/admin", middleware.PermissionAuth(model.PermPromptRead), controller.GetPrompts)
+		promptGroup.POST("/system", middleware.PermissionAuth(model.PermPromptWrite), controller.CreatePrompt)
 		promptGroup.POST("/personal", middleware.UserTokenAuth(model.RoleCommonUser), controller.CreatePrompt)
+		promptGroup.GET("/template_variables", middleware.UserTokenAuth(model.RoleCommonUser), controller.GetPromptTemplateVariables)
+		promptGroup.POST("/preview", middleware.UserTokenAuth(model.RoleCommonUser), controller.PreviewPromptTemplate)
 		promptGroup.GET("/:pid", controller.GetPrompt)
 		promptGroup.PUT("/:pid", middleware.UserTokenAuth(model.RoleCommonUser), controller.UpdatePrompt)
 		promptGroup.DELETE("/:pid", middleware.UserTokenAuth(model.RoleCommonUser), controller.DeletePrompt)
 		promptGroup.PATCH("/:pid/like", middleware.UserTokenAuth(model.RoleCommonUser), controller.UpdatePromptLike)
 		promptGroup.GET("/:pid/groups", middleware.UserTokenAuth(model.RoleCommonUser), controller.GetPromptGroups)
 		promptGroup.PATCH("/:pid/status", middleware.UserTokenAuth(model.RoleCommonUser), controller.UpdatePromptStatus)
+		promptGroup.GET("/rankings", middleware.PermissionAuth(model.PermPromptRead), controller.GetPromptRankings)
+		promptGroup.GET("/:pid/revisions", middleware.UserTokenAuth(model.RoleCommonUser), controller.GetPromptRevisions)
+		promptGroup.GET("/:pid/revisions/diff", middleware.UserTokenAuth(model.RoleCommonUser), controller.DiffPromptRevisions)
+		promptGroup.GET("/:pid/revisions/:revision", middleware.UserTokenAuth(model.RoleCommonUser), controller.GetPromptRevision)
+		promptGroup.POST("/:pid/fork", middleware.UserTokenAuth(model.RoleCommonUser), controller.ForkPrompt)
+		promptGroup.POST("/:pid/usages", middleware.UserTokenAuth(model.RoleCommonUser), controller.RecordPromptUsage)
+		promptGroup.GET("/:pid/stats", middleware.PermissionAuth(model.PermPromptRead), controller.GetPromptStats)
 	}
 
 	navigationRoute := apiRouter.Group("/navigations")
 	navigationRoute.GET("", controller.GetNavigations)
 	navigationRoute.GET("/icons", controller.GetNavigationIcons)
 	navigationRoute.POST("/init", controller.InitSystemNavigation)
-	navigationRoute.Use(middleware.UserTokenAuth(model.RoleAdminUser))
+	navigationRoute.Use(middleware.PermissionAuth(model.PermNavigationWrite))
 	{
 		navigationRoute.GET("/:nav_id", controller.GetNavigation)
 		navigationRoute.POST("", controller.CreateNavigation)
@@ -309,20 +420,30 @@ func SetApiRouter(router *gin.Engine) {
 	}
 
 	systemLogRouter := apiRouter.Group("/system_logs")
-	systemLogRouter.Use(middleware.UserTokenAuth(model.RoleAdminUser))
+	systemLogRouter.Use(middleware.PermissionAuth(model.PermSystemLogRead))
 	{
 		systemLogRouter.GET("/modules", controller.GetModules)
 		systemLogRouter.GET("/actions", controller.GetActions)
 		systemLogRouter.GET("", controller.GetSystemLogs)
 	}
 
+	apiRouter.GET("/login_audits", middleware.PermissionAuth(model.PermSystemLogRead), controller.GetLoginAudits)
+
+	guardrailRouter := apiRouter.Group("/guardrails")
+	{
+		guardrailRouter.GET("", middleware.PermissionAuth(model.PermEnterpriseRead), controller.GetGuardrailPolicy)
+		guardrailRouter.PUT("", middleware.PermissionAuth(model.PermEnterpriseWrite), controller.UpdateGuardrailPolicy)
+		guardrailRouter.POST("/test", middleware.PermissionAuth(model.PermEnterpriseRead), controller.TestGuardrail)
+		guardrailRouter.GET("/violations", middleware.PermissionAuth(model.PermSystemLogRead), controller.GetGuardrailViolations)
+	}
+
 	maxKB := apiRouter.Group("/maxkb")
 	{
-		maxKB.GET("/application/profile", middleware.UserTokenAuth(model.RoleAdminUser), controller.GetMaxKBApplicationProfile)
+		maxKB.GET("/application/profile", middleware.PermissionAuth(model.PermChannelRead), controller.GetMaxKBApplicationProfile)
 	}
 
 	difyRouter := apiRouter.Group("/dify")
-	difyRouter.Use(middleware.UserTokenAuth(model.RoleAdminUser))
+	difyRouter.Use(middleware.PermissionAuth(model.PermChannelRead))
 	{
 		difyRouter.GET("/info/:channelId", controller.GetDifyAppInfo)
 		difyRouter.GET("/parameters/:channelId", controller.GetDifyAppParameters)
@@ -332,10 +453,91 @@ func SetApiRouter(router *gin.Engine) {
 	sharesAuth.Use(middleware.UserTokenAuth(model.RoleGuestUser))
 	{
 		sharesAuth.POST("", controller.CreateShare)
+		sharesAuth.GET("/mine", controller.GetMyShares)
+		sharesAuth.PUT("/:share_id", controller.UpdateShare)
+		sharesAuth.DELETE("/:share_id", controller.DeleteShare)
+		sharesAuth.POST("/:share_id/fork", controller.ForkShare)
+		sharesAuth.GET("", middleware.PermissionAuth(model.PermShareRead), controller.GetShares)
+		sharesAuth.PUT("/:share_id/status", middleware.PermissionAuth(model.PermShareWrite), controller.UpdateShareStatus)
 	}
 
 	sharesPublic := apiRouter.Group("/shares")
 	{
 		sharesPublic.GET("/:share_id", controller.GetShare)
 	}
+
+	knowledgeBaseGroup := apiRouter.Group("/knowledge_bases")
+	{
+		knowledgeBaseGroup.GET("", middleware.PermissionAuth(model.PermKnowledgeRead), controller.GetKnowledgeBases)
+		knowledgeBaseGroup.POST("", middleware.PermissionAuth(model.PermKnowledgeWrite), controller.CreateKnowledgeBase)
+		knowledgeBaseGroup.GET("/:id", middleware.PermissionAuth(model.PermKnowledgeRead), controller.GetKnowledgeBase)
+		knowledgeBaseGroup.PUT("/:id", middleware.PermissionAuth(model.PermKnowledgeWrite), controller.UpdateKnowledgeBase)
+		knowledgeBaseGroup.DELETE("/:id", middleware.PermissionAuth(model.PermKnowledgeWrite), controller.DeleteKnowledgeBase)
+		knowledgeBaseGroup.POST("/:id/retrieve", middleware.PermissionAuth(model.PermKnowledgeRead), controller.RetrieveKnowledgeBase)
+		knowledgeBaseGroup.GET("/:id/documents", middleware.PermissionAuth(model.PermKnowledgeRead), controller.GetKnowledgeDocuments)
+		knowledgeBaseGroup.POST("/:id/documents", middleware.PermissionAuth(model.PermKnowledgeWrite), controller.AddKnowledgeDocuments)
+		knowledgeBaseGroup.DELETE("/:id/documents/:document_id", middleware.PermissionAuth(model.PermKnowledgeWrite), controller.DeleteKnowledgeDocument)
+		knowledgeBaseGroup.POST("/:id/documents/:document_id/reindex", middleware.PermissionAuth(model.PermKnowledgeWrite), controller.ReindexKnowledgeDocument)
+	}
+
+	messageGroup := apiRouter.Group("/messages")
+	messageGroup.Use(middleware.UserTokenAuth(model.RoleGuestUser))
+	{
+		messageGroup.PUT("/:message_id/feedback", controller.SaveMessageFeedback)
+		messageGroup.DELETE("/:message_id/feedback", controller.DeleteMessageFeedback)
+	}
+
+	feedbackGroup := apiRouter.Group("/feedbacks")
+	feedbackGroup.Use(middleware.PermissionAuth(model.PermFeedbackRead))
+	{
+		feedbackGroup.GET("/stats", controller.GetFeedbackStats)
+		feedbackGroup.GET("/low_rated_conversations", controller.GetLowRatedConversations)
+		feedbackGroup.GET("/export", controller.ExportFeedbacks)
+	}
+
+	conversationExportGroup := apiRouter.Group("/conversation_exports")
+	conversationExportGroup.Use(middleware.UserTokenAuth(model.RoleGuestUser))
+	{
+		conversationExportGroup.GET("", controller.GetConversationExportJobs)
+		conversationExportGroup.POST("", controller.CreateConversationExportJob)
+		conversationExportGroup.GET("/:id", controller.GetConversationExportJob)
+		conversationExportGroup.GET("/:id/download", controller.DownloadConversationExport)
+		conversationExportGroup.DELETE("/:id", controller.DeleteConversationExportJob)
+	}
+
+	searchGroup := apiRouter.Group("/search")
+	searchGroup.Use(middleware.UserTokenAuth(model.RoleGuestUser))
+	{
+		searchGroup.GET("/messages", controller.SearchMessages)
+	}
+
+	scimTokenGroup := apiRouter.Group("/scim/token")
+	scimTokenGroup.Use(middleware.PermissionAuth(model.PermEnterpriseWrite))
+	{
+		scimTokenGroup.GET("", controller.GetScimToken)
+		scimTokenGroup.POST("", controller.GenerateScimToken)
+		scimTokenGroup.DELETE("", controller.DeleteScimToken)
+	}
+
+	ssoGroup := apiRouter.Group("/sso/oidc")
+	{
+		ssoGroup.GET("/providers", controller.GetOIDCProviders)
+		ssoGroup.GET("/:provider/authorize", controller.OIDCAuthorize)
+		ssoGroup.POST("/:provider/login", controller.OIDCLogin)
+	}
+
+	// 钉钉、飞书扫码登录
+	orgLoginGroup := apiRouter.Group("/sso/:platform")
+	{
+		orgLoginGroup.GET("/authorize", controller.OrgLoginAuthorize)
+		orgLoginGroup.POST("/login", controller.OrgLogin)
+	}
+
+	// 两步验证登录，pre_auth_token 由 /api/login 返回
+	twoFactorLoginGroup := apiRouter.Group("/login/2fa")
+	{
+		twoFactorLoginGroup.POST("/verify", controller.TwoFactorLogin)
+		twoFactorLoginGroup.POST("/email", controller.SendTwoFactorLoginEmail)
+		twoFactorLoginGroup.POST("/totp/setup", controller.SetupTwoFactorLoginTOTP)
+	}
 }
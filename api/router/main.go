@@ -31,6 +31,7 @@ func SetRouter(router *gin.Engine, buildFS embed.FS) {
 	setStaticImagesRouter(router, buildFS)
 	setStaticLibsRouter(router, buildFS)
 	SetApiRouter(router)
+	SetScimRouter(router)
 	// SetWebRouter(router, buildFS)
 	SetStaticRouter(router, buildFS)
 }
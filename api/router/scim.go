@@ -0,0 +1,32 @@
+package router
+
+import (
+	"github.com/53AI/53AIHub/controller"
+	"github.com/53AI/53AIHub/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// SetScimRouter SCIM 2.0 用户与组同步接口，使用企业 SCIM 令牌认证
+func SetScimRouter(router *gin.Engine) {
+	scimRouter := router.Group("/scim/v2")
+	scimRouter.Use(middleware.Logger())
+	scimRouter.Use(middleware.ScimTokenAuth())
+	{
+		scimRouter.GET("/ServiceProviderConfig", controller.ScimServiceProviderConfig)
+		scimRouter.GET("/ResourceTypes", controller.ScimResourceTypes)
+
+		scimRouter.GET("/Users", controller.ScimListUsers)
+		scimRouter.POST("/Users", controller.ScimCreateUser)
+		scimRouter.GET("/Users/:id", controller.ScimGetUser)
+		scimRouter.PUT("/Users/:id", controller.ScimReplaceUser)
+		scimRouter.PATCH("/Users/:id", controller.ScimPatchUser)
+		scimRouter.DELETE("/Users/:id", controller.ScimDeleteUser)
+
+		scimRouter.GET("/Groups", controller.ScimListGroups)
+		scimRouter.POST("/Groups", controller.ScimCreateGroup)
+		scimRouter.GET("/Groups/:id", controller.ScimGetGroup)
+		scimRouter.PUT("/Groups/:id", controller.ScimReplaceGroup)
+		scimRouter.PATCH("/Groups/:id", controller.ScimPatchGroup)
+		scimRouter.DELETE("/Groups/:id", controller.ScimDeleteGroup)
+	}
+}
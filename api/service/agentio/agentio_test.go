@@ -0,0 +1,146 @@
+package agentio
+
+import (
+	"testing"
+
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+)
+
+func setupDB(t *testing.T) {
+	testutil.SetupDB(t, &model.Enterprise{}, &model.Agent{}, &model.AgentVersion{}, &model.Group{},
+		&model.ResourcePermission{}, &model.Channel{}, &model.Provider{}, &model.UploadFile{})
+}
+
+func mustCreate(t *testing.T, value interface{}) {
+	if err := model.DB.Create(value).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func createGroup(t *testing.T, eid int64, groupType int64, name string) *model.Group {
+	group := &model.Group{Eid: eid, GroupName: name, GroupType: groupType}
+	mustCreate(t, group)
+	return group
+}
+
+func conflictTypes(result *ImportResult) map[string]int {
+	types := make(map[string]int)
+	for _, c := range result.Conflicts {
+		types[c.Type]++
+	}
+	return types
+}
+
+func TestExportImportRemap(t *testing.T) {
+	setupDB(t)
+	mustCreate(t, &model.Enterprise{Eid: 1, DisplayName: "staging", Type: model.EnterpriseTypeIndustry})
+	mustCreate(t, &model.Enterprise{Eid: 2, DisplayName: "production", Type: model.EnterpriseTypeEnterprise})
+
+	category := createGroup(t, 1, model.AGENT_TYPE, "研发")
+	internal := createGroup(t, 1, model.INTERNAL_USER_GROUP_TYPE, "工程部")
+	subscription := createGroup(t, 1, model.USER_GROUP_TYPE, "免费版")
+	agent := &model.Agent{Eid: 1, Name: "代码助手", Model: "gpt-4o", ChannelType: 1, Prompt: "你是编程助手",
+		Configs: "{}", Tools: "[]", CustomConfig: `{"agent_type":"prompt"}`, Settings: "{}", UseCases: "[]",
+		GroupID: category.GroupId, Enable: true}
+	mustCreate(t, agent)
+	for _, groupID := range []int64{internal.GroupId, subscription.GroupId} {
+		mustCreate(t, &model.ResourcePermission{GroupID: groupID, ResourceID: agent.AgentID,
+			ResourceType: model.ResourceTypeAgent, Permission: model.PermissionRead})
+	}
+
+	doc, err := BuildExport(agent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Group != "研发" || len(doc.UserGroups) != 1 || len(doc.SubscriptionGroups) != 1 {
+		t.Fatalf("unexpected export groups: %q %v %v", doc.Group, doc.UserGroups, doc.SubscriptionGroups)
+	}
+	data, err := BuildZip([]*ExportedAgent{doc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs, err := ParseImport(data)
+	if err != nil || len(docs) != 1 || docs[0].Prompt != "你是编程助手" {
+		t.Fatalf("ParseImport = %v, %v", docs, err)
+	}
+
+	// 目标企业：只有其他类型的渠道提供该模型，已有同名智能体，缺少智能体分组
+	targetGroup := createGroup(t, 2, model.INTERNAL_USER_GROUP_TYPE, "工程部")
+	mustCreate(t, &model.Channel{Eid: 2, Name: "azure", Type: 3, ModelType: model.ModelTypeLLM,
+		Models: "gpt-4o,gpt-4o-mini", Status: model.ChannelStatusEnabled})
+	mustCreate(t, &model.Agent{Eid: 2, Name: "代码助手", Model: "x"})
+
+	dry, err := ImportAgent(2, 9, docs[0], ImportOptions{DryRun: true})
+	if err != nil || dry.AgentID != 0 {
+		t.Fatalf("dry run = %+v, %v", dry, err)
+	}
+	skipped, err := ImportAgent(2, 9, docs[0], ImportOptions{OnConflict: OnConflictSkip})
+	if err != nil || !skipped.Skipped {
+		t.Fatalf("skip = %+v, %v", skipped, err)
+	}
+
+	result, err := ImportAgent(2, 9, docs[0], ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := conflictTypes(result)
+	if types[ConflictTypeName] != 1 || types[ConflictTypeChannel] != 1 || types[ConflictTypeGroup] != 1 {
+		t.Fatalf("unexpected conflicts: %+v", types)
+	}
+	imported, err := model.GetAgentByID(2, result.AgentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.Name != "代码助手 (2)" || imported.ChannelType != 3 || imported.GroupID != 0 || imported.Version != 1 {
+		t.Fatalf("unexpected imported agent: %+v", imported)
+	}
+	// 企业内部站点只保留内部用户组的权限
+	if err := imported.LoadGroupIdsByType(); err != nil {
+		t.Fatal(err)
+	}
+	if len(imported.UserGroupIds) != 1 || imported.UserGroupIds[0] != targetGroup.GroupId || len(imported.SubscriptionGroupIds) != 0 {
+		t.Fatalf("unexpected permissions: %v %v", imported.UserGroupIds, imported.SubscriptionGroupIds)
+	}
+
+	// 自动创建缺少的分组
+	result, err = ImportAgent(2, 9, docs[0], ImportOptions{CreateMissingGroups: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported, _ = model.GetAgentByID(2, result.AgentID)
+	if group, err := model.GetGroupByName(2, model.AGENT_TYPE, "研发"); err != nil || imported.GroupID != group.GroupId {
+		t.Fatalf("agent group not created: %v", err)
+	}
+}
+
+func TestInstantiateBuiltinTemplate(t *testing.T) {
+	setupDB(t)
+	if err := model.DB.AutoMigrate(&model.AgentTemplate{}); err != nil {
+		t.Fatal(err)
+	}
+	mustCreate(t, &model.Enterprise{Eid: 1, DisplayName: "hub", Type: model.EnterpriseTypeIndependent})
+	if err := model.InitializeAgentTemplates(); err != nil {
+		t.Fatal(err)
+	}
+	// 重复同步不产生重复的内置模板
+	if err := model.InitializeAgentTemplates(); err != nil {
+		t.Fatal(err)
+	}
+	templates, err := model.GetAgentTemplates(1, "")
+	if err != nil || len(templates) != len(model.AgentTemplateData) {
+		t.Fatalf("GetAgentTemplates = %d, %v", len(templates), err)
+	}
+
+	result, err := ImportAgent(1, 9, FromTemplate(templates[0], "我的助手"), ImportOptions{})
+	if err != nil || result.AgentID == 0 {
+		t.Fatalf("instantiate = %+v, %v", result, err)
+	}
+	if conflictTypes(result)[ConflictTypeChannel] != 1 {
+		t.Fatalf("expected missing channel to be reported: %+v", result.Conflicts)
+	}
+	agent, _ := model.GetAgentByID(1, result.AgentID)
+	if agent.Name != "我的助手" || agent.Prompt != templates[0].Prompt || agent.Enable {
+		t.Fatalf("unexpected agent: %+v", agent)
+	}
+}
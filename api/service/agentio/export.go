@@ -0,0 +1,193 @@
+package agentio
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/53AI/53AIHub/common/storage"
+	"github.com/53AI/53AIHub/model"
+)
+
+// ExportVersion 导出 JSON 的结构版本，导入时校验
+const ExportVersion = 1
+
+// maxEmbedLogoSize 头像内嵌到导出文件的大小上限
+const maxEmbedLogoSize = 5 * 1024 * 1024
+
+// 上传文件的预览地址前缀，头像为该地址时随导出文件一起迁移
+const previewPathPrefix = "api/preview/"
+
+// ExportedAgent 导出的智能体，JSON 格式即为该结构，也是导入和模板实例化的输入格式。
+// 渠道、平台与分组按类型和名称记录，导入时在目标企业重新匹配
+type ExportedAgent struct {
+	Version     int           `json:"version"`
+	AgentID     int64         `json:"agent_id"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Logo        string        `json:"logo"`
+	LogoFile    *ExportedFile `json:"logo_file,omitempty"`
+	Sort        int           `json:"sort"`
+	Enable      bool          `json:"enable"`
+	AgentType   int           `json:"agent_type"`
+	UseCases    string        `json:"use_cases"`
+	model.AgentSnapshot
+	// ProviderType、ProviderName custom_config 中 provider_id 对应的平台
+	ProviderType       int64    `json:"provider_type,omitempty"`
+	ProviderName       string   `json:"provider_name,omitempty"`
+	Group              string   `json:"group,omitempty"` // 智能体分组名称
+	UserGroups         []string `json:"user_groups"`
+	SubscriptionGroups []string `json:"subscription_groups"`
+	ExportedTime       int64    `json:"exported_time"`
+}
+
+// ExportedFile 随智能体导出的上传文件
+type ExportedFile struct {
+	FileName string `json:"file_name"`
+	MimeType string `json:"mime_type"`
+	Hash     string `json:"hash"`
+	Path     string `json:"path,omitempty"` // zip 中相对智能体目录的路径
+	Data     []byte `json:"data,omitempty"` // 文件内容，JSON 中为 base64
+}
+
+// BuildExport 构建智能体的导出内容，导出的是当前生效的版本
+func BuildExport(agent *model.Agent) (*ExportedAgent, error) {
+	if err := agent.LoadGroupIdsByType(); err != nil {
+		return nil, err
+	}
+	doc := &ExportedAgent{
+		Version:       ExportVersion,
+		AgentID:       agent.AgentID,
+		Name:          agent.Name,
+		Description:   agent.Description,
+		Logo:          agent.Logo,
+		Sort:          agent.Sort,
+		Enable:        agent.Enable,
+		AgentType:     agent.AgentType,
+		UseCases:      agent.UseCases,
+		AgentSnapshot: agent.Snapshot(),
+		ExportedTime:  time.Now().UnixMilli(),
+	}
+	if providerID := agent.GetProviderID(); providerID > 0 {
+		if provider, err := model.GetProviderByID(providerID, agent.Eid); err == nil {
+			doc.ProviderType = provider.ProviderType
+			doc.ProviderName = provider.Name
+		}
+	}
+	if agent.GroupID > 0 {
+		if group, err := model.GetGroupByID(agent.GroupID); err == nil && group.Eid == agent.Eid {
+			doc.Group = group.GroupName
+		}
+	}
+	var err error
+	if doc.UserGroups, err = groupNames(agent.Eid, agent.UserGroupIds); err != nil {
+		return nil, err
+	}
+	if doc.SubscriptionGroups, err = groupNames(agent.Eid, agent.SubscriptionGroupIds); err != nil {
+		return nil, err
+	}
+	doc.LogoFile = loadLogo(agent.Eid, agent.Logo)
+	return doc, nil
+}
+
+func groupNames(eid int64, ids []int64) ([]string, error) {
+	names := make([]string, 0, len(ids))
+	if len(ids) == 0 {
+		return names, nil
+	}
+	err := model.DB.Model(&model.Group{}).Where("eid = ? AND group_id IN ?", eid, ids).
+		Order("group_id").Pluck("group_name", &names).Error
+	return names, err
+}
+
+// previewKey 头像为本系统的上传文件时返回其预览 key
+func previewKey(logo string) string {
+	index := strings.LastIndex(logo, previewPathPrefix)
+	if index < 0 {
+		return ""
+	}
+	return logo[index+len(previewPathPrefix):]
+}
+
+func loadLogo(eid int64, logo string) *ExportedFile {
+	key := previewKey(logo)
+	if key == "" {
+		return nil
+	}
+	uploadFile, err := model.GetUploadFileByEidAndPreviewKey(eid, key)
+	if err != nil {
+		return nil
+	}
+	file := &ExportedFile{
+		FileName: uploadFile.FileName,
+		MimeType: uploadFile.MimeType,
+		Hash:     uploadFile.Hash,
+	}
+	if uploadFile.Size <= maxEmbedLogoSize {
+		if data, err := storage.StorageInstance.Load(uploadFile.Key); err == nil {
+			file.Data = data
+		}
+	}
+	return file
+}
+
+// ExportFileName 导出文件名（不含扩展名）
+func ExportFileName(doc *ExportedAgent) string {
+	return fmt.Sprintf("agent_%d_%s", doc.AgentID, sanitizeFileName(doc.Name))
+}
+
+func sanitizeFileName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < 0x20 {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if name == "" {
+		return "agent"
+	}
+	return name
+}
+
+// BuildZip 打包多个智能体，每个智能体一个目录，包含 agent.json 和头像文件
+func BuildZip(docs []*ExportedAgent) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	writer := zip.NewWriter(buf)
+	for _, doc := range docs {
+		dir := ExportFileName(doc)
+		exported := *doc
+		if doc.LogoFile != nil && doc.LogoFile.Data != nil {
+			logo := *doc.LogoFile
+			logo.Path = "logo" + path.Ext(logo.FileName)
+			if err := writeZipFile(writer, path.Join(dir, logo.Path), logo.Data); err != nil {
+				return nil, err
+			}
+			logo.Data = nil
+			exported.LogoFile = &logo
+		}
+		content, err := json.MarshalIndent(&exported, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := writeZipFile(writer, path.Join(dir, "agent.json"), content); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeZipFile(writer *zip.Writer, name string, data []byte) error {
+	w, err := writer.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,450 @@
+package agentio
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/53AI/53AIHub/common/storage"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/agentversion"
+	"gorm.io/gorm"
+)
+
+// maxImportZipEntrySize zip 中单个文件的大小上限
+const maxImportZipEntrySize = 20 * 1024 * 1024
+
+// 名称冲突的处理方式
+const (
+	OnConflictRename = "rename"
+	OnConflictSkip   = "skip"
+)
+
+// 冲突类型
+const (
+	ConflictTypeName     = "name"
+	ConflictTypeChannel  = "channel"
+	ConflictTypeProvider = "provider"
+	ConflictTypeGroup    = "group"
+	ConflictTypeLogo     = "logo"
+)
+
+// ImportOptions 导入选项
+type ImportOptions struct {
+	// OnConflict 目标企业已有同名智能体时的处理方式：rename（默认，追加序号）或 skip
+	OnConflict string `json:"on_conflict" form:"on_conflict" example:"rename"`
+	// CreateMissingGroups 目标企业没有同名分组时自动创建，否则忽略该分组
+	CreateMissingGroups bool `json:"create_missing_groups" form:"create_missing_groups" example:"false"`
+	// DryRun 只返回冲突报告，不创建智能体
+	DryRun bool `json:"dry_run" form:"dry_run" example:"false"`
+}
+
+// Conflict 导入时无法原样对应到目标企业的内容及其处理结果
+type Conflict struct {
+	Type       string `json:"type" example:"channel"`
+	Name       string `json:"name" example:"gpt-4o"`
+	Message    string `json:"message"`
+	Resolution string `json:"resolution"`
+}
+
+// ImportResult 单个智能体的导入结果
+type ImportResult struct {
+	Name      string      `json:"name"`
+	AgentID   int64       `json:"agent_id"` // 新建的智能体ID，跳过或试运行时为 0
+	Skipped   bool        `json:"skipped"`
+	Conflicts []*Conflict `json:"conflicts"`
+}
+
+// ParseImport 解析导入文件，支持单个智能体 JSON、JSON 数组以及 BuildZip 导出的 zip
+func ParseImport(data []byte) ([]*ExportedAgent, error) {
+	if bytes.HasPrefix(data, []byte("PK")) {
+		return parseImportZip(data)
+	}
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		var docs []*ExportedAgent
+		if err := json.Unmarshal(data, &docs); err != nil {
+			return nil, err
+		}
+		return docs, nil
+	}
+	var doc ExportedAgent
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return []*ExportedAgent{&doc}, nil
+}
+
+func parseImportZip(data []byte) ([]*ExportedAgent, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]*zip.File, len(reader.File))
+	for _, f := range reader.File {
+		files[f.Name] = f
+	}
+
+	docs := make([]*ExportedAgent, 0)
+	for _, f := range reader.File {
+		if path.Base(f.Name) != "agent.json" {
+			continue
+		}
+		content, err := readZipFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var doc ExportedAgent
+		if err := json.Unmarshal(content, &doc); err != nil {
+			return nil, fmt.Errorf("parse %s failed: %w", f.Name, err)
+		}
+		if logo := doc.LogoFile; logo != nil && logo.Path != "" && logo.Data == nil {
+			if lf, ok := files[path.Join(path.Dir(f.Name), logo.Path)]; ok {
+				if logo.Data, err = readZipFile(lf); err != nil {
+					return nil, err
+				}
+			}
+		}
+		docs = append(docs, &doc)
+	}
+	if len(docs) == 0 {
+		return nil, errors.New("no agent found in zip")
+	}
+	return docs, nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	if f.UncompressedSize64 > maxImportZipEntrySize {
+		return nil, fmt.Errorf("file %s is too large", f.Name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, maxImportZipEntrySize))
+}
+
+// importPlan 导入内容在目标企业上的对应结果
+type importPlan struct {
+	agent          *model.Agent
+	groupNames     map[int64][]string // 分组类型 -> 需要新建的分组名称
+	groupIDs       []int64            // 已存在的权限分组
+	agentGroup     string             // 需要新建的智能体分组名称
+	restoreLogo    bool
+	result         *ImportResult
+	enterpriseType string
+}
+
+func (p *importPlan) conflict(typ, name, message, resolution string) {
+	p.result.Conflicts = append(p.result.Conflicts, &Conflict{Type: typ, Name: name, Message: message, Resolution: resolution})
+}
+
+// ImportAgent 在企业 eid 中按导入内容创建智能体，返回冲突报告
+func ImportAgent(eid int64, userID int64, doc *ExportedAgent, opts ImportOptions) (*ImportResult, error) {
+	if doc.Version <= 0 || doc.Version > ExportVersion {
+		return nil, fmt.Errorf("unsupported export version: %d", doc.Version)
+	}
+	if strings.TrimSpace(doc.Name) == "" {
+		return nil, errors.New("agent name is required")
+	}
+	enterprise, err := model.GetEnterpriseByID(eid)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &importPlan{
+		agent: &model.Agent{
+			Eid:         eid,
+			Name:        doc.Name,
+			Description: doc.Description,
+			Logo:        doc.Logo,
+			Sort:        doc.Sort,
+			Enable:      doc.Enable,
+			AgentType:   doc.AgentType,
+			UseCases:    doc.UseCases,
+			CreatedBy:   userID,
+		},
+		groupNames:     make(map[int64][]string),
+		result:         &ImportResult{Name: doc.Name, Conflicts: make([]*Conflict, 0)},
+		enterpriseType: enterprise.Type,
+	}
+	plan.agent.ApplySnapshot(doc.AgentSnapshot)
+
+	if skip, err := plan.resolveName(opts.OnConflict); err != nil || skip {
+		return plan.result, err
+	}
+	if err := plan.resolveChannel(); err != nil {
+		return nil, err
+	}
+	if err := plan.resolveProvider(doc); err != nil {
+		return nil, err
+	}
+	if err := plan.resolveGroups(doc, opts.CreateMissingGroups); err != nil {
+		return nil, err
+	}
+	plan.resolveLogo(doc)
+	if opts.DryRun {
+		return plan.result, nil
+	}
+
+	if plan.restoreLogo {
+		if uploadFile, err := restoreFile(eid, userID, doc.LogoFile); err == nil {
+			plan.agent.Logo = uploadFile.GetPreviewFullUrl()
+		} else {
+			plan.conflict(ConflictTypeLogo, doc.LogoFile.FileName, err.Error(), "保留原头像地址")
+		}
+	}
+	if err := plan.create(); err != nil {
+		return nil, err
+	}
+	if err := agentversion.Init(plan.agent, userID); err != nil {
+		return nil, err
+	}
+	plan.result.AgentID = plan.agent.AgentID
+	return plan.result, nil
+}
+
+// resolveName 目标企业已有同名智能体时按 onConflict 跳过或追加序号
+func (p *importPlan) resolveName(onConflict string) (bool, error) {
+	name := p.agent.Name
+	for i := 2; ; i++ {
+		var count int64
+		if err := model.DB.Model(&model.Agent{}).Where("eid = ? AND name = ?", p.agent.Eid, name).Count(&count).Error; err != nil {
+			return false, err
+		}
+		if count == 0 {
+			break
+		}
+		if onConflict == OnConflictSkip {
+			p.result.Skipped = true
+			p.conflict(ConflictTypeName, p.agent.Name, "已存在同名智能体", "跳过")
+			return true, nil
+		}
+		name = fmt.Sprintf("%s (%d)", p.agent.Name, i)
+	}
+	if name != p.agent.Name {
+		p.conflict(ConflictTypeName, p.agent.Name, "已存在同名智能体", "重命名为 "+name)
+		p.agent.Name = name
+		p.result.Name = name
+	}
+	return false, nil
+}
+
+// resolveChannel 优先匹配同类型且提供该模型的渠道，其次匹配任意提供该模型的对话渠道
+func (p *importPlan) resolveChannel() error {
+	modelName := p.agent.Model
+	if modelName == "" {
+		return nil
+	}
+	var channels []model.Channel
+	err := model.DB.Where("eid = ? AND status = ? AND model_type = ?", p.agent.Eid, model.ChannelStatusEnabled, model.ModelTypeLLM).
+		Order("channel_id").Find(&channels).Error
+	if err != nil {
+		return err
+	}
+	var fallback *model.Channel
+	for i := range channels {
+		if !channelServes(&channels[i], modelName) {
+			continue
+		}
+		if channels[i].Type == p.agent.ChannelType {
+			return nil
+		}
+		if fallback == nil {
+			fallback = &channels[i]
+		}
+	}
+	if fallback != nil {
+		p.conflict(ConflictTypeChannel, modelName, fmt.Sprintf("没有类型为 %d 的渠道提供该模型", p.agent.ChannelType),
+			fmt.Sprintf("改用渠道【%s】（类型 %d）", fallback.Name, fallback.Type))
+		p.agent.ChannelType = fallback.Type
+		return nil
+	}
+	p.conflict(ConflictTypeChannel, modelName, "没有可用渠道提供该模型", "保留原模型，请在智能体中重新选择模型")
+	return nil
+}
+
+func channelServes(channel *model.Channel, modelName string) bool {
+	for _, m := range strings.Split(channel.Models, ",") {
+		if strings.TrimSpace(m) == modelName {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveProvider custom_config 中的 provider_id 改为目标企业中同类型平台的ID，同名的优先
+func (p *importPlan) resolveProvider(doc *ExportedAgent) error {
+	if doc.ProviderType == 0 {
+		return nil
+	}
+	providers, err := model.GetProvidersByEidAndProviderType(p.agent.Eid, doc.ProviderType)
+	if err != nil {
+		return err
+	}
+	var target *model.Provider
+	for i := range providers {
+		if providers[i].Name == doc.ProviderName {
+			target = &providers[i]
+			break
+		}
+	}
+	if target == nil && len(providers) > 0 {
+		target = &providers[0]
+		p.conflict(ConflictTypeProvider, doc.ProviderName, "没有同名的平台", "改用平台【"+target.Name+"】")
+	}
+
+	var customConfig map[string]interface{}
+	if err := json.Unmarshal([]byte(p.agent.CustomConfig), &customConfig); err != nil || customConfig == nil {
+		return nil
+	}
+	if target == nil {
+		delete(customConfig, "provider_id")
+		p.conflict(ConflictTypeProvider, doc.ProviderName, "目标企业没有该类型的平台", "已清除平台配置，请先添加平台")
+	} else {
+		customConfig["provider_id"] = target.ProviderID
+	}
+	content, err := json.Marshal(customConfig)
+	if err != nil {
+		return err
+	}
+	p.agent.CustomConfig = string(content)
+	return nil
+}
+
+// resolveGroups 按名称匹配智能体分组与权限分组，按企业类型忽略不适用的权限分组
+func (p *importPlan) resolveGroups(doc *ExportedAgent, createMissing bool) error {
+	if doc.Group != "" {
+		group, err := model.GetGroupByName(p.agent.Eid, model.AGENT_TYPE, doc.Group)
+		switch {
+		case err == nil:
+			p.agent.GroupID = group.GroupId
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		case createMissing:
+			p.agentGroup = doc.Group
+			p.conflict(ConflictTypeGroup, doc.Group, "智能体分组不存在", "新建分组")
+		default:
+			p.conflict(ConflictTypeGroup, doc.Group, "智能体分组不存在", "不设置分组")
+		}
+	}
+
+	permissionGroups := map[int64][]string{}
+	if p.enterpriseType != model.EnterpriseTypeEnterprise {
+		permissionGroups[model.USER_GROUP_TYPE] = doc.SubscriptionGroups
+	}
+	if p.enterpriseType != model.EnterpriseTypeIndependent {
+		permissionGroups[model.INTERNAL_USER_GROUP_TYPE] = doc.UserGroups
+	}
+	for _, groupType := range []int64{model.USER_GROUP_TYPE, model.INTERNAL_USER_GROUP_TYPE} {
+		for _, name := range permissionGroups[groupType] {
+			group, err := model.GetGroupByName(p.agent.Eid, groupType, name)
+			switch {
+			case err == nil:
+				p.groupIDs = append(p.groupIDs, group.GroupId)
+			case !errors.Is(err, gorm.ErrRecordNotFound):
+				return err
+			case createMissing:
+				p.groupNames[groupType] = append(p.groupNames[groupType], name)
+				p.conflict(ConflictTypeGroup, name, "权限分组不存在", "新建分组")
+			default:
+				p.conflict(ConflictTypeGroup, name, "权限分组不存在", "忽略该分组")
+			}
+		}
+	}
+	return nil
+}
+
+// resolveLogo 头像为上传文件时在目标企业恢复该文件
+func (p *importPlan) resolveLogo(doc *ExportedAgent) {
+	logo := doc.LogoFile
+	if logo == nil {
+		return
+	}
+	if logo.Data != nil {
+		p.restoreLogo = true
+		return
+	}
+	if logo.Hash != "" {
+		if uploadFile, err := model.GetUploadFileByEidAndHash(p.agent.Eid, logo.Hash); err == nil {
+			p.agent.Logo = uploadFile.GetPreviewFullUrl()
+			return
+		}
+	}
+	p.conflict(ConflictTypeLogo, logo.FileName, "导出文件未包含头像内容", "保留原头像地址")
+}
+
+func (p *importPlan) create() error {
+	return model.DB.Transaction(func(tx *gorm.DB) error {
+		if p.agentGroup != "" {
+			group := &model.Group{Eid: p.agent.Eid, CreatedBy: p.agent.CreatedBy, GroupName: p.agentGroup, GroupType: model.AGENT_TYPE}
+			if err := tx.Create(group).Error; err != nil {
+				return err
+			}
+			p.agent.GroupID = group.GroupId
+		}
+		groupIDs := append([]int64{}, p.groupIDs...)
+		for groupType, names := range p.groupNames {
+			for _, name := range names {
+				group := &model.Group{Eid: p.agent.Eid, CreatedBy: p.agent.CreatedBy, GroupName: name, GroupType: groupType}
+				if err := tx.Create(group).Error; err != nil {
+					return err
+				}
+				groupIDs = append(groupIDs, group.GroupId)
+			}
+		}
+		if err := tx.Create(p.agent).Error; err != nil {
+			return err
+		}
+		for _, groupID := range groupIDs {
+			permission := model.ResourcePermission{
+				GroupID:      groupID,
+				ResourceID:   p.agent.AgentID,
+				ResourceType: model.ResourceTypeAgent,
+				Permission:   model.PermissionRead,
+			}
+			if err := tx.Create(&permission).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// restoreFile 保存导入的文件内容为企业的上传文件
+func restoreFile(eid int64, userID int64, file *ExportedFile) (*model.UploadFile, error) {
+	sum := sha256.Sum256(file.Data)
+	hashStr := hex.EncodeToString(sum[:])
+	extension := path.Ext(file.FileName)
+	previewKey, err := model.GetPreviewKey(hashStr, extension)
+	if err != nil {
+		return nil, err
+	}
+	key := model.GetFileKey(previewKey, eid, userID)
+	if err := storage.StorageInstance.Save(file.Data, key); err != nil {
+		return nil, err
+	}
+	uploadFile := &model.UploadFile{
+		FileName:   file.FileName,
+		Key:        key,
+		Eid:        eid,
+		UserID:     userID,
+		Size:       int64(len(file.Data)),
+		Extension:  extension,
+		MimeType:   file.MimeType,
+		Hash:       hashStr,
+		PreviewKey: previewKey,
+	}
+	if err := uploadFile.Save(); err != nil {
+		return nil, err
+	}
+	return uploadFile, nil
+}
@@ -0,0 +1,42 @@
+package agentio
+
+import (
+	"github.com/53AI/53AIHub/model"
+)
+
+// FromTemplate 模板转换为导入内容，实例化模板即按该内容导入；新建的智能体默认不启用
+func FromTemplate(template *model.AgentTemplate, name string) *ExportedAgent {
+	if name == "" {
+		name = template.Name
+	}
+	return &ExportedAgent{
+		Version:            ExportVersion,
+		Name:               name,
+		Description:        template.Description,
+		Logo:               template.Logo,
+		Sort:               template.Sort,
+		AgentType:          template.AgentType,
+		UseCases:           template.UseCases,
+		AgentSnapshot:      template.AgentSnapshot,
+		UserGroups:         []string{},
+		SubscriptionGroups: []string{},
+	}
+}
+
+// NewTemplate 以智能体当前生效的版本创建企业模板
+func NewTemplate(agent *model.Agent, name string, category string, userID int64) *model.AgentTemplate {
+	if name == "" {
+		name = agent.Name
+	}
+	return &model.AgentTemplate{
+		Eid:           agent.Eid,
+		Name:          name,
+		Description:   agent.Description,
+		Logo:          agent.Logo,
+		Category:      category,
+		AgentType:     agent.AgentType,
+		UseCases:      agent.UseCases,
+		AgentSnapshot: agent.Snapshot(),
+		CreatedBy:     userID,
+	}
+}
@@ -0,0 +1,242 @@
+package agentversion
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/53AI/53AIHub/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNoDraft = errors.New("agent has no draft")
+	// ErrCurrentVersion 回滚目标就是当前生效的版本
+	ErrCurrentVersion = errors.New("version is already current")
+	ErrInvalidRef     = errors.New("invalid version reference")
+)
+
+// 版本引用：草稿、当前生效的配置，或数字版本号
+const (
+	RefDraft   = "draft"
+	RefCurrent = "current"
+)
+
+// 发布或回滚时写回智能体的字段
+var snapshotColumns = []string{"channel_type", "model", "prompt", "configs", "tools", "custom_config", "settings", "version", "updated_time"}
+
+// FieldChange 两个版本之间有差异的字段
+type FieldChange struct {
+	Field string `json:"field" example:"prompt"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// Init 为新建或尚未纳入版本管理的智能体以当前配置创建 v1
+func Init(agent *model.Agent, userID int64) error {
+	return model.DB.Transaction(func(tx *gorm.DB) error {
+		return ensureBaseline(tx, agent, userID)
+	})
+}
+
+func ensureBaseline(tx *gorm.DB, agent *model.Agent, userID int64) error {
+	if agent.Version > 0 {
+		return nil
+	}
+	baseline := &model.AgentVersion{
+		Eid:           agent.Eid,
+		AgentID:       agent.AgentID,
+		Version:       1,
+		AgentSnapshot: agent.Snapshot(),
+		Note:          "初始版本",
+		CreatedBy:     userID,
+	}
+	if err := tx.Create(baseline).Error; err != nil {
+		return err
+	}
+	agent.Version = baseline.Version
+	return tx.Model(&model.Agent{}).Where("agent_id = ?", agent.AgentID).Update("version", agent.Version).Error
+}
+
+// SaveDraft 保存编辑内容为草稿，不影响当前生效的配置；与当前配置一致时删除草稿并返回 nil
+func SaveDraft(agent *model.Agent, snapshot model.AgentSnapshot, userID int64) (*model.AgentVersion, error) {
+	var draft *model.AgentVersion
+	err := model.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ensureBaseline(tx, agent, userID); err != nil {
+			return err
+		}
+		if snapshot == agent.Snapshot() {
+			return model.DeleteAgentDraft(tx, agent.AgentID)
+		}
+		existing, err := model.GetAgentVersion(tx, agent.AgentID, model.AgentVersionDraft)
+		if err != nil && !errors.Is(err, model.ErrAgentVersionNotFound) {
+			return err
+		}
+		if existing == nil {
+			existing = &model.AgentVersion{Eid: agent.Eid, AgentID: agent.AgentID, Version: model.AgentVersionDraft}
+		}
+		existing.AgentSnapshot = snapshot
+		existing.CreatedBy = userID
+		draft = existing
+		return tx.Save(draft).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return draft, nil
+}
+
+// DraftAgent 叠加了草稿内容的智能体副本，用于管理员在正式发布前试用草稿
+func DraftAgent(agent *model.Agent) (*model.Agent, error) {
+	draft, err := model.GetAgentVersion(model.DB, agent.AgentID, model.AgentVersionDraft)
+	if err != nil {
+		if errors.Is(err, model.ErrAgentVersionNotFound) {
+			return nil, ErrNoDraft
+		}
+		return nil, err
+	}
+	preview := *agent
+	preview.ApplySnapshot(draft.AgentSnapshot)
+	preview.Version = model.AgentVersionDraft
+	return &preview, nil
+}
+
+// Publish 将草稿发布为新版本并立即生效
+func Publish(agent *model.Agent, note string, userID int64) (*model.AgentVersion, error) {
+	var published *model.AgentVersion
+	err := model.DB.Transaction(func(tx *gorm.DB) error {
+		draft, err := model.GetAgentVersion(tx, agent.AgentID, model.AgentVersionDraft)
+		if err != nil {
+			if errors.Is(err, model.ErrAgentVersionNotFound) {
+				return ErrNoDraft
+			}
+			return err
+		}
+		if err := ensureBaseline(tx, agent, userID); err != nil {
+			return err
+		}
+		published, err = release(tx, agent, draft.AgentSnapshot, note, 0, userID)
+		if err != nil {
+			return err
+		}
+		return model.DeleteAgentDraft(tx, agent.AgentID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return published, nil
+}
+
+// Rollback 以历史版本的内容发布一个新版本，历史记录只追加不修改；草稿保留
+func Rollback(agent *model.Agent, version int, userID int64) (*model.AgentVersion, error) {
+	if version <= 0 {
+		return nil, model.ErrAgentVersionNotFound
+	}
+	if version == agent.Version {
+		return nil, ErrCurrentVersion
+	}
+	var published *model.AgentVersion
+	err := model.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ensureBaseline(tx, agent, userID); err != nil {
+			return err
+		}
+		target, err := model.GetAgentVersion(tx, agent.AgentID, version)
+		if err != nil {
+			return err
+		}
+		published, err = release(tx, agent, target.AgentSnapshot, fmt.Sprintf("回滚到 v%d", version), version, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return published, nil
+}
+
+// PublishSnapshot 以给定内容发布一个新版本并立即生效，用于推广实验方案等草稿之外的来源；草稿保留
+func PublishSnapshot(agent *model.Agent, snapshot model.AgentSnapshot, note string, userID int64) (*model.AgentVersion, error) {
+	var published *model.AgentVersion
+	err := model.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ensureBaseline(tx, agent, userID); err != nil {
+			return err
+		}
+		var err error
+		published, err = release(tx, agent, snapshot, note, 0, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return published, nil
+}
+
+func release(tx *gorm.DB, agent *model.Agent, snapshot model.AgentSnapshot, note string, source int, userID int64) (*model.AgentVersion, error) {
+	latest, err := model.GetLatestAgentVersion(tx, agent.AgentID)
+	if err != nil {
+		return nil, err
+	}
+	v := &model.AgentVersion{
+		Eid:           agent.Eid,
+		AgentID:       agent.AgentID,
+		Version:       latest + 1,
+		AgentSnapshot: snapshot,
+		Note:          note,
+		SourceVersion: source,
+		CreatedBy:     userID,
+	}
+	if err := tx.Create(v).Error; err != nil {
+		return nil, err
+	}
+	agent.ApplySnapshot(snapshot)
+	agent.Version = v.Version
+	if err := tx.Model(agent).Select(snapshotColumns).Updates(agent).Error; err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// ResolveSnapshot 按引用取出版本内容：RefDraft、RefCurrent（或空）或数字版本号
+func ResolveSnapshot(agent *model.Agent, ref string) (model.AgentSnapshot, error) {
+	switch ref {
+	case "", RefCurrent:
+		return agent.Snapshot(), nil
+	case RefDraft:
+		draft, err := model.GetAgentVersion(model.DB, agent.AgentID, model.AgentVersionDraft)
+		if errors.Is(err, model.ErrAgentVersionNotFound) {
+			return model.AgentSnapshot{}, ErrNoDraft
+		}
+		if err != nil {
+			return model.AgentSnapshot{}, err
+		}
+		return draft.AgentSnapshot, nil
+	}
+	version, err := strconv.Atoi(ref)
+	if err != nil || version <= 0 {
+		return model.AgentSnapshot{}, ErrInvalidRef
+	}
+	v, err := model.GetAgentVersion(model.DB, agent.AgentID, version)
+	if err != nil {
+		return model.AgentSnapshot{}, err
+	}
+	return v.AgentSnapshot, nil
+}
+
+// Diff 列出 from 到 to 之间发生变化的字段
+func Diff(from, to model.AgentSnapshot) []FieldChange {
+	fields := []FieldChange{
+		{"channel_type", strconv.Itoa(from.ChannelType), strconv.Itoa(to.ChannelType)},
+		{"model", from.Model, to.Model},
+		{"prompt", from.Prompt, to.Prompt},
+		{"configs", from.Configs, to.Configs},
+		{"tools", from.Tools, to.Tools},
+		{"custom_config", from.CustomConfig, to.CustomConfig},
+		{"settings", from.Settings, to.Settings},
+	}
+	changes := make([]FieldChange, 0)
+	for _, f := range fields {
+		if f.From != f.To {
+			changes = append(changes, f)
+		}
+	}
+	return changes
+}
@@ -0,0 +1,85 @@
+package agentversion
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+)
+
+func setupAgent(t *testing.T) *model.Agent {
+	testutil.SetupDB(t, &model.Agent{}, &model.AgentVersion{})
+	agent := &model.Agent{Eid: 1, Name: "assistant", Model: "gpt-4o", Prompt: "v1 prompt",
+		Configs: "{}", Tools: "[]", CustomConfig: "{}", Settings: "{}", UseCases: "[]"}
+	if err := agent.Create(); err != nil {
+		t.Fatal(err)
+	}
+	return agent
+}
+
+func reload(t *testing.T, agentID int64) *model.Agent {
+	agent, err := model.GetAgentByID(1, agentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return agent
+}
+
+func TestDraftPublishRollback(t *testing.T) {
+	agent := setupAgent(t)
+
+	edited := agent.Snapshot()
+	edited.Prompt = "v2 prompt"
+	draft, err := SaveDraft(agent, edited, 7)
+	if err != nil || draft == nil {
+		t.Fatalf("SaveDraft = %v, %v", draft, err)
+	}
+	live := reload(t, agent.AgentID)
+	if live.Prompt != "v1 prompt" || live.Version != 1 {
+		t.Fatalf("draft leaked into live agent: prompt=%q version=%d", live.Prompt, live.Version)
+	}
+
+	preview, err := DraftAgent(live)
+	if err != nil || preview.Prompt != "v2 prompt" || preview.Version != model.AgentVersionDraft {
+		t.Fatalf("DraftAgent = %+v, %v", preview, err)
+	}
+
+	changes := Diff(live.Snapshot(), edited)
+	if len(changes) != 1 || changes[0].Field != "prompt" {
+		t.Fatalf("Diff = %+v", changes)
+	}
+
+	v2, err := Publish(live, "new prompt", 7)
+	if err != nil || v2.Version != 2 {
+		t.Fatalf("Publish = %+v, %v", v2, err)
+	}
+	live = reload(t, agent.AgentID)
+	if live.Prompt != "v2 prompt" || live.Version != 2 || model.HasAgentDraft(agent.AgentID) {
+		t.Fatalf("after publish: prompt=%q version=%d", live.Prompt, live.Version)
+	}
+	if _, err := Publish(live, "", 7); !errors.Is(err, ErrNoDraft) {
+		t.Fatalf("publishing without draft: %v", err)
+	}
+
+	v3, err := Rollback(live, 1, 7)
+	if err != nil || v3.Version != 3 || v3.SourceVersion != 1 {
+		t.Fatalf("Rollback = %+v, %v", v3, err)
+	}
+	live = reload(t, agent.AgentID)
+	if live.Prompt != "v1 prompt" || live.Version != 3 {
+		t.Fatalf("after rollback: prompt=%q version=%d", live.Prompt, live.Version)
+	}
+	if _, err := Rollback(live, 3, 7); !errors.Is(err, ErrCurrentVersion) {
+		t.Fatalf("rollback to current: %v", err)
+	}
+
+	// 编辑回与当前配置一致时不保留草稿
+	if draft, err := SaveDraft(live, live.Snapshot(), 7); err != nil || draft != nil {
+		t.Fatalf("SaveDraft unchanged = %v, %v", draft, err)
+	}
+	versions, err := model.GetAgentVersions(agent.AgentID)
+	if err != nil || len(versions) != 3 || versions[0].Version != 3 {
+		t.Fatalf("GetAgentVersions = %d, %v", len(versions), err)
+	}
+}
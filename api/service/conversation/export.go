@@ -0,0 +1,222 @@
+package conversation
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/53AI/53AIHub/common/storage"
+	"github.com/53AI/53AIHub/model"
+)
+
+// ExportVersion 导出 JSON 的结构版本，导入时校验
+const ExportVersion = 1
+
+// maxEmbedFileSize 单个附件内嵌到导出文件的大小上限
+const maxEmbedFileSize = 10 * 1024 * 1024
+
+// ExportedConversation 导出的会话，JSON 格式即为该结构，也是导入的输入格式
+type ExportedConversation struct {
+	Version        int                   `json:"version"`
+	ConversationID int64                 `json:"conversation_id"`
+	Title          string                `json:"title"`
+	AgentID        int64                 `json:"agent_id"`
+	AgentName      string                `json:"agent_name"`
+	UserID         int64                 `json:"user_id"`
+	UserName       string                `json:"user_name"`
+	Model          string                `json:"model"`
+	CreatedTime    int64                 `json:"created_time"`
+	UpdatedTime    int64                 `json:"updated_time"`
+	ExportedTime   int64                 `json:"exported_time"`
+	Messages       []*ExportedMessage    `json:"messages"`
+	Attachments    []*ExportedAttachment `json:"attachments"`
+}
+
+// ExportedMessage 导出的一轮问答
+type ExportedMessage struct {
+	ID               int64                     `json:"id"`
+	ParentID         int64                     `json:"parent_id"`
+	Type             model.MessageType         `json:"type"`
+	Query            string                    `json:"query"`                // 用户提问文本
+	Parameters       map[string]interface{}    `json:"parameters,omitempty"` // 工作流输入参数
+	Answer           string                    `json:"answer"`               // 原始回答，工作流为输出 JSON
+	Output           map[string]interface{}    `json:"output,omitempty"`     // 工作流输出
+	ReasoningContent string                    `json:"reasoning_content,omitempty"`
+	Citations        []model.KnowledgeCitation `json:"citations,omitempty"`
+	AttachmentIDs    []int64                   `json:"attachment_ids,omitempty"`
+	ModelName        string                    `json:"model_name"`
+	PromptTokens     int                       `json:"prompt_tokens"`
+	CompletionTokens int                       `json:"completion_tokens"`
+	TotalTokens      int                       `json:"total_tokens"`
+	CreatedTime      int64                     `json:"created_time"`
+	RawMessage       string                    `json:"raw_message"` // 原始请求消息，导入时原样恢复
+}
+
+// ExportedAttachment 消息引用的上传文件
+type ExportedAttachment struct {
+	FileID   int64  `json:"file_id"`
+	FileName string `json:"file_name"`
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+	Hash     string `json:"hash"`
+	URL      string `json:"url"`            // 预览地址
+	Path     string `json:"path,omitempty"` // 批量导出时在 zip 中相对会话目录的路径
+	Data     []byte `json:"data,omitempty"` // 文件内容，JSON 中为 base64，仅在内嵌附件时导出
+}
+
+// IsImage 判断附件是否为图片
+func (a *ExportedAttachment) IsImage() bool {
+	return strings.HasPrefix(a.MimeType, "image/")
+}
+
+// BuildExport 构建会话的导出内容，embedAttachments 为 true 时读取附件内容一并导出
+func BuildExport(conversation *model.Conversation, embedAttachments bool) (*ExportedConversation, error) {
+	messages, err := loadConversationMessages(conversation.Eid, conversation.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &ExportedConversation{
+		Version:        ExportVersion,
+		ConversationID: conversation.ConversationID,
+		Title:          conversation.Title,
+		AgentID:        conversation.AgentID,
+		UserID:         conversation.UserID,
+		Model:          conversation.Model,
+		CreatedTime:    conversation.CreatedTime,
+		UpdatedTime:    conversation.UpdatedTime,
+		ExportedTime:   time.Now().UnixMilli(),
+		Messages:       make([]*ExportedMessage, 0, len(messages)),
+		Attachments:    make([]*ExportedAttachment, 0),
+	}
+	agent := conversation.Agent
+	if agent == nil {
+		agent, _ = model.GetAgentByID(conversation.Eid, conversation.AgentID)
+	}
+	if agent != nil {
+		doc.AgentName = agent.Name
+	}
+	if user, err := model.GetUserByID(conversation.UserID); err == nil {
+		doc.UserName = user.Nickname
+	}
+
+	messageType := model.MessageTypeChat
+	if agent != nil && agent.AgentType == model.AgentTypeWorkflow {
+		messageType = model.MessageTypeWorkflow
+	}
+
+	attachmentIndex := make(map[int64]bool)
+	for _, message := range messages {
+		exported := &ExportedMessage{
+			ID:               message.ID,
+			ParentID:         message.ParentID,
+			Type:             messageType,
+			Answer:           message.Answer,
+			ReasoningContent: message.ReasoningContent,
+			ModelName:        message.ModelName,
+			PromptTokens:     message.PromptTokens,
+			CompletionTokens: message.CompletionTokens,
+			TotalTokens:      message.TotalTokens,
+			CreatedTime:      message.CreatedTime,
+			RawMessage:       message.Message,
+		}
+		if messageType == model.MessageTypeWorkflow {
+			if parameters, err := message.ParseWorkflowParameters(); err == nil {
+				exported.Parameters = parameters
+				exported.AttachmentIDs = collectFileIDs(parameters, nil)
+			}
+			if output, err := message.ParseWorkflowOutput(); err == nil {
+				exported.Output = output
+			}
+		} else {
+			exported.Query, exported.AttachmentIDs = message.GetQueryContent()
+		}
+		if message.Citations != "" {
+			_ = json.Unmarshal([]byte(message.Citations), &exported.Citations)
+		}
+		doc.Messages = append(doc.Messages, exported)
+
+		for _, fileID := range exported.AttachmentIDs {
+			if attachmentIndex[fileID] {
+				continue
+			}
+			attachmentIndex[fileID] = true
+			if attachment := loadAttachment(conversation.Eid, fileID, embedAttachments); attachment != nil {
+				doc.Attachments = append(doc.Attachments, attachment)
+			}
+		}
+	}
+	return doc, nil
+}
+
+// loadConversationMessages 分页读取会话当前分支上的全部消息，按创建顺序排列
+func loadConversationMessages(eid int64, conversationID int64) ([]*model.Message, error) {
+	const pageSize = 100
+	all := make([]*model.Message, 0)
+	for offset := 0; ; offset += pageSize {
+		count, messages, err := model.GetMessagesByConversationID(eid, conversationID, "", pageSize, offset)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, messages...)
+		if len(messages) < pageSize || int64(len(all)) >= count {
+			break
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].ID < all[j].ID
+	})
+	return all, nil
+}
+
+// collectFileIDs 递归收集工作流参数中 file_id: 格式的文件引用
+func collectFileIDs(value interface{}, fileIDs []int64) []int64 {
+	switch v := value.(type) {
+	case string:
+		if fileID, ok := model.ParseFileIDContent(v); ok {
+			fileIDs = append(fileIDs, fileID)
+		}
+	case []interface{}:
+		for _, item := range v {
+			fileIDs = collectFileIDs(item, fileIDs)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fileIDs = collectFileIDs(v[key], fileIDs)
+		}
+	}
+	return fileIDs
+}
+
+func loadAttachment(eid int64, fileID int64, embed bool) *ExportedAttachment {
+	uploadFile, err := model.GetUploadFileByID(fileID)
+	if err != nil || uploadFile.Eid != eid {
+		return nil
+	}
+	attachment := &ExportedAttachment{
+		FileID:   uploadFile.ID,
+		FileName: uploadFile.FileName,
+		MimeType: uploadFile.MimeType,
+		Size:     uploadFile.Size,
+		Hash:     uploadFile.Hash,
+		URL:      uploadFile.GetPreviewFullUrl(),
+	}
+	if embed && uploadFile.Size <= maxEmbedFileSize {
+		if data, err := storage.StorageInstance.Load(uploadFile.Key); err == nil {
+			attachment.Data = data
+		}
+	}
+	return attachment
+}
+
+// attachmentFileName 附件在 zip 中的文件名，加上文件ID避免重名
+func attachmentFileName(attachment *ExportedAttachment) string {
+	return fmt.Sprintf("%d_%s", attachment.FileID, sanitizeFileName(attachment.FileName))
+}
@@ -0,0 +1,217 @@
+package conversation
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/53AI/53AIHub/common/storage"
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+)
+
+var pngData = []byte("\x89PNG\r\n\x1a\nfake")
+
+// setupExport 准备一个带图片附件和未选中分支的会话，存储替换为临时目录
+func setupExport(t *testing.T) (*model.Conversation, *model.UploadFile) {
+	testutil.SetupDB(t, &model.Agent{}, &model.ResourcePermission{}, &model.User{}, &model.Conversation{},
+		&model.Message{}, &model.MessageSearchDocument{}, &model.UploadFile{}, &model.ConversationExportJob{})
+	previous := storage.StorageInstance
+	storage.StorageInstance = &storage.LocalStorage{BasePath: t.TempDir()}
+	t.Cleanup(func() { storage.StorageInstance = previous })
+
+	agent := &model.Agent{Eid: 1, Name: "客服助手", Enable: true}
+	model.DB.Create(agent)
+	user := &model.User{Eid: 1, Username: "alice", Nickname: "Alice"}
+	model.DB.Create(user)
+	file := &model.UploadFile{Eid: 1, UserID: user.UserID, FileName: "截图.png", MimeType: "image/png",
+		Key: model.GetFileKey("shot.png", 1, user.UserID), Size: int64(len(pngData)), Hash: "h1"}
+	if err := storage.StorageInstance.Save(pngData, file.Key); err != nil {
+		t.Fatal(err)
+	}
+	model.DB.Create(file)
+
+	conversation := &model.Conversation{Eid: 1, UserID: user.UserID, AgentID: agent.AgentID, Title: "发票/报销"}
+	if err := model.CreateConversation(conversation); err != nil {
+		t.Fatal(err)
+	}
+	query := func(content string) string {
+		data, _ := json.Marshal([]map[string]interface{}{{"role": "user", "content": content}})
+		return string(data)
+	}
+	withImage, _ := json.Marshal([]model.ObjectStringContent{{Type: "text", Content: "这张发票能报销吗"},
+		{Type: "image", Content: fmt.Sprintf("file_id:%d", file.ID)}})
+	first := &model.Message{Eid: 1, UserID: user.UserID, AgentID: agent.AgentID, ConversationID: conversation.ConversationID,
+		Message: query("报销流程"), Answer: "先提交 <b>申请</b>", TotalTokens: 5}
+	discarded := &model.Message{Eid: 1, UserID: user.UserID, AgentID: agent.AgentID, ConversationID: conversation.ConversationID,
+		Message: query("被编辑掉的提问"), Answer: "旧回答"}
+	second := &model.Message{Eid: 1, UserID: user.UserID, AgentID: agent.AgentID, ConversationID: conversation.ConversationID,
+		Message: query(string(withImage)), Answer: "可以报销", ReasoningContent: "检查发票抬头", TotalTokens: 7,
+		Citations: `[{"file_name":"报销制度.pdf"}]`}
+	for _, message := range []*model.Message{first, discarded} {
+		if err := model.CreateMessage(message); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 编辑第二条提问，原提问所在的分支不再导出
+	second.ParentID = first.ID
+	if err := model.CreateMessage(second); err != nil {
+		t.Fatal(err)
+	}
+	return conversation, file
+}
+
+func TestBuildExportAndRender(t *testing.T) {
+	conversation, file := setupExport(t)
+
+	doc, err := BuildExport(conversation, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.AgentName != "客服助手" || doc.UserName != "Alice" || len(doc.Messages) != 2 {
+		t.Fatalf("unexpected export: %+v", doc)
+	}
+	if doc.Messages[0].Query != "报销流程" || doc.Messages[1].Query != "这张发票能报销吗" ||
+		len(doc.Messages[1].AttachmentIDs) != 1 || doc.Messages[1].Citations[0].FileName != "报销制度.pdf" {
+		t.Fatalf("unexpected messages: %+v %+v", doc.Messages[0], doc.Messages[1])
+	}
+	if len(doc.Attachments) != 1 || doc.Attachments[0].FileID != file.ID || !bytes.Equal(doc.Attachments[0].Data, pngData) {
+		t.Fatalf("unexpected attachments: %+v", doc.Attachments)
+	}
+	if ExportFileName(doc) != fmt.Sprintf("%d_发票_报销", conversation.ConversationID) {
+		t.Fatalf("unexpected file name: %s", ExportFileName(doc))
+	}
+
+	markdown, err := Render(doc, model.ConversationExportFormatMarkdown)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"# 发票/报销", "- 智能体：客服助手", "报销流程", "![截图.png](", "<summary>思考过程</summary>", "1. 报销制度.pdf"} {
+		if !strings.Contains(string(markdown), want) {
+			t.Errorf("markdown missing %q:\n%s", want, markdown)
+		}
+	}
+	if strings.Contains(string(markdown), "被编辑掉的提问") {
+		t.Error("markdown should only contain the active branch")
+	}
+
+	html, err := Render(doc, model.ConversationExportFormatHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 回答内容需要转义，图片以 data URI 内嵌
+	for _, want := range []string{"先提交 &lt;b&gt;申请&lt;/b&gt;", `src="data:image/png;base64,`, "<li>报销制度.pdf</li>"} {
+		if !strings.Contains(string(html), want) {
+			t.Errorf("html missing %q", want)
+		}
+	}
+
+	if _, err := Render(doc, "pdf"); err == nil {
+		t.Error("unsupported format should fail")
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	conversation, file := setupExport(t)
+	doc, err := BuildExport(conversation, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := Render(doc, model.ConversationExportFormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs, err := ParseImport(data)
+	if err != nil || len(docs) != 1 {
+		t.Fatalf("ParseImport = %d docs, %v", len(docs), err)
+	}
+
+	importer := &model.User{UserID: 9, Eid: 1}
+	imported, err := ImportConversation(1, importer, true, 0, docs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.Title != "发票/报销" || imported.AgentID != conversation.AgentID || imported.TotalTokens != 12 {
+		t.Fatalf("unexpected conversation: %+v", imported)
+	}
+	_, messages, err := model.GetMessagesByConversationIDWithDirection(1, imported.ConversationID, "", 10, 0, "asc")
+	if err != nil || len(messages) != 2 {
+		t.Fatalf("imported messages = %d, %v", len(messages), err)
+	}
+	if messages[1].ParentID != messages[0].ID || messages[1].ReasoningContent != "检查发票抬头" || messages[1].Citations == "" {
+		t.Fatalf("unexpected imported message: %+v", messages[1])
+	}
+	// 附件以导入者身份重新保存，消息中的文件ID随之替换
+	query, fileIDs := messages[1].GetQueryContent()
+	if query != "这张发票能报销吗" || len(fileIDs) != 1 || fileIDs[0] == file.ID {
+		t.Fatalf("unexpected query %q, files %v", query, fileIDs)
+	}
+	restored, err := model.GetUploadFileByID(fileIDs[0])
+	if err != nil || restored.UserID != importer.UserID {
+		t.Fatalf("restored file = %+v, %v", restored, err)
+	}
+	if data, err := storage.StorageInstance.Load(restored.Key); err != nil || !bytes.Equal(data, pngData) {
+		t.Fatalf("restored file content = %q, %v", data, err)
+	}
+
+	if _, err := ImportConversation(1, importer, true, 0, &ExportedConversation{Version: ExportVersion + 1}); err == nil {
+		t.Fatal("unsupported version should fail")
+	}
+}
+
+func TestRunExportJob(t *testing.T) {
+	conversation, _ := setupExport(t)
+	// 其它企业的会话不在导出范围内
+	model.CreateConversation(&model.Conversation{Eid: 2, UserID: 1, AgentID: 1, Title: "other"})
+
+	job := &model.ConversationExportJob{Eid: 1, CreatedBy: 1, Formats: "markdown,json"}
+	if err := model.CreateConversationExportJob(job); err != nil {
+		t.Fatal(err)
+	}
+	if err := RunExportJob(job); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := model.GetConversationExportJob(1, job.ID)
+	if err != nil || stored.Status != model.ConversationExportStatusCompleted || stored.ConversationCount != 1 || stored.FileSize == 0 {
+		t.Fatalf("unexpected job: %+v, %v", stored, err)
+	}
+
+	data, err := storage.StorageInstance.Load(stored.FileKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(reader.File))
+	for _, f := range reader.File {
+		names = append(names, f.Name)
+	}
+	dir := fmt.Sprintf("%d_发票_报销/", conversation.ConversationID)
+	if len(names) != 3 || !strings.HasPrefix(names[0], dir+"attachments/") ||
+		names[1] != dir+"conversation.md" || names[2] != dir+"conversation.json" {
+		t.Fatalf("unexpected zip entries: %v", names)
+	}
+
+	// 批量导出的 zip 可直接导入，附件从 zip 中读取
+	docs, err := ParseImport(data)
+	if err != nil || len(docs) != 1 {
+		t.Fatalf("ParseImport = %d docs, %v", len(docs), err)
+	}
+	if len(docs[0].Attachments) != 1 || !bytes.Equal(docs[0].Attachments[0].Data, pngData) {
+		t.Fatalf("attachments should be loaded from the zip: %+v", docs[0].Attachments)
+	}
+
+	failed := &model.ConversationExportJob{Eid: 1, CreatedBy: 1, Formats: "pdf"}
+	model.CreateConversationExportJob(failed)
+	if err := RunExportJob(failed); err == nil {
+		t.Fatal("unsupported format should fail the job")
+	}
+	if stored, _ = model.GetConversationExportJob(1, failed.ID); stored.Status != model.ConversationExportStatusFailed || stored.ErrorMessage == "" {
+		t.Fatalf("unexpected failed job: %+v", stored)
+	}
+}
@@ -0,0 +1,67 @@
+package conversation
+
+import (
+	"encoding/json"
+
+	"github.com/53AI/53AIHub/model"
+)
+
+// ForkSharedConversation 将分享中的消息复制到查看者名下的新会话，绑定同一智能体并记录来源分享
+// messages 需按创建顺序排列，复制后串成一条分支，新会话的当前分支指向最后一条消息
+func ForkSharedConversation(share *model.ShareRecord, source *model.Conversation, userID int64, messages []*model.Message) (*model.Conversation, error) {
+	conversation := &model.Conversation{
+		Eid:           share.Eid,
+		UserID:        userID,
+		AgentID:       source.AgentID,
+		Title:         source.Title,
+		Status:        model.ConversationStatusActive,
+		Model:         source.Model,
+		SourceShareID: share.ShareID,
+	}
+	if err := model.CreateConversation(conversation); err != nil {
+		return nil, err
+	}
+
+	var lastMessage *model.Message
+	for _, shared := range messages {
+		message := &model.Message{
+			Eid:               share.Eid,
+			UserID:            userID,
+			AgentID:           source.AgentID,
+			ConversationID:    conversation.ConversationID,
+			Message:           shared.Message,
+			Answer:            shared.Answer,
+			ReasoningContent:  shared.ReasoningContent,
+			ModelName:         shared.ModelName,
+			PromptTokens:      shared.PromptTokens,
+			CompletionTokens:  shared.CompletionTokens,
+			TotalTokens:       shared.TotalTokens,
+			AgentCustomConfig: shared.AgentCustomConfig,
+			Citations:         shared.Citations,
+		}
+		message.CreatedTime = shared.CreatedTime
+		if lastMessage != nil {
+			message.ParentID = lastMessage.ID
+		} else {
+			message.IsBranchRoot = true
+		}
+		if err := model.CreateMessage(message); err != nil {
+			return nil, err
+		}
+		lastMessage = message
+		conversation.TotalTokens += message.TotalTokens
+	}
+
+	if lastMessage != nil {
+		lastMessageJSON, _ := json.Marshal(map[string]string{
+			"question": lastMessage.Message,
+			"answer":   lastMessage.Answer,
+		})
+		conversation.LastMessage = string(lastMessageJSON)
+		if err := model.UpdateConversation(conversation); err != nil {
+			return nil, err
+		}
+		conversation.ActiveMessageID = lastMessage.ID
+	}
+	return conversation, nil
+}
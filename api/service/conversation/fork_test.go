@@ -0,0 +1,63 @@
+package conversation
+
+import (
+	"testing"
+
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+)
+
+func TestForkSharedConversation(t *testing.T) {
+	testutil.SetupDB(t, &model.Conversation{}, &model.Message{}, &model.MessageSearchDocument{})
+	source := &model.Conversation{Eid: 1, UserID: 2, AgentID: 3, Title: "报销", Model: "gpt-4o"}
+	if err := model.CreateConversation(source); err != nil {
+		t.Fatal(err)
+	}
+	var shared []*model.Message
+	for i, query := range []string{"报销流程", "需要哪些材料"} {
+		message := &model.Message{Eid: 1, UserID: 2, AgentID: 3, ConversationID: source.ConversationID,
+			Message: query, Answer: "回答", TotalTokens: 5, Citations: `[{"file_name":"制度.pdf"}]`}
+		message.CreatedTime = int64(1000 + i)
+		if err := model.CreateMessage(message); err != nil {
+			t.Fatal(err)
+		}
+		shared = append(shared, message)
+	}
+	share := &model.ShareRecord{Eid: 1, ShareID: "share-1", ConversationID: source.ConversationID}
+
+	forked, err := ForkSharedConversation(share, source, 9, shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if forked.ConversationID == source.ConversationID || forked.UserID != 9 || forked.AgentID != 3 ||
+		forked.SourceShareID != "share-1" || forked.Title != "报销" || forked.TotalTokens != 10 {
+		t.Fatalf("unexpected forked conversation: %+v", forked)
+	}
+	stored, err := model.GetConversationByID(1, 9, forked.ConversationID)
+	if err != nil || stored.SourceShareID != "share-1" || stored.LastMessage == "" {
+		t.Fatalf("forked conversation not stored: %+v %v", stored, err)
+	}
+
+	// 复制的消息串成一条分支，当前分支指向最后一条
+	path, err := model.GetActiveMessagePath(1, forked.ConversationID)
+	if err != nil || len(path) != 2 || path[1] != forked.ActiveMessageID {
+		t.Fatalf("unexpected active path: %v %v", path, err)
+	}
+	var copies []*model.Message
+	model.DB.Where("id IN ?", path).Order("id").Find(&copies)
+	for i, message := range copies {
+		if message.ID == shared[i].ID || message.UserID != 9 || message.Message != shared[i].Message ||
+			message.Citations != shared[i].Citations || message.CreatedTime != shared[i].CreatedTime {
+			t.Fatalf("unexpected copy %d: %+v", i, message)
+		}
+	}
+	if copies[0].ParentID != 0 || copies[1].ParentID != copies[0].ID {
+		t.Fatalf("copies should form one branch: %d %d", copies[0].ParentID, copies[1].ParentID)
+	}
+
+	var count int64
+	model.DB.Model(&model.Message{}).Where("conversation_id = ?", source.ConversationID).Count(&count)
+	if count != 2 {
+		t.Fatalf("source conversation changed: %d messages", count)
+	}
+}
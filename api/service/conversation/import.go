@@ -0,0 +1,310 @@
+package conversation
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/common/storage"
+	"github.com/53AI/53AIHub/common/utils/helper"
+	"github.com/53AI/53AIHub/model"
+	"gorm.io/gorm"
+)
+
+// maxImportZipEntrySize zip 中单个文件的大小上限
+const maxImportZipEntrySize = 50 * 1024 * 1024
+
+var fileIDRegexp = regexp.MustCompile(`file_id:(\d+)`)
+
+// ParseImport 解析导入文件，支持单个会话 JSON、会话 JSON 数组以及批量导出的 zip
+func ParseImport(data []byte) ([]*ExportedConversation, error) {
+	if bytes.HasPrefix(data, []byte("PK")) {
+		return parseImportZip(data)
+	}
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		var docs []*ExportedConversation
+		if err := json.Unmarshal(data, &docs); err != nil {
+			return nil, err
+		}
+		return docs, nil
+	}
+	var doc ExportedConversation
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return []*ExportedConversation{&doc}, nil
+}
+
+// parseImportZip 读取批量导出 zip 中每个会话目录下的 conversation.json，并按 path 加载附件内容
+func parseImportZip(data []byte) ([]*ExportedConversation, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]*zip.File, len(reader.File))
+	for _, f := range reader.File {
+		files[f.Name] = f
+	}
+
+	docs := make([]*ExportedConversation, 0)
+	for _, f := range reader.File {
+		if path.Base(f.Name) != "conversation.json" {
+			continue
+		}
+		content, err := readZipFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var doc ExportedConversation
+		if err := json.Unmarshal(content, &doc); err != nil {
+			return nil, fmt.Errorf("parse %s failed: %w", f.Name, err)
+		}
+		dir := path.Dir(f.Name)
+		for _, attachment := range doc.Attachments {
+			if attachment.Path == "" || attachment.Data != nil {
+				continue
+			}
+			if af, ok := files[path.Join(dir, attachment.Path)]; ok {
+				if attachment.Data, err = readZipFile(af); err != nil {
+					return nil, err
+				}
+			}
+		}
+		docs = append(docs, &doc)
+	}
+	if len(docs) == 0 {
+		return nil, errors.New("no conversation found in zip")
+	}
+	return docs, nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	if f.UncompressedSize64 > maxImportZipEntrySize {
+		return nil, fmt.Errorf("file %s is too large", f.Name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, maxImportZipEntrySize))
+}
+
+// ErrAgentForbidden 导入者没有使用目标智能体的权限
+var ErrAgentForbidden = errors.New("no permission to use the agent")
+
+// ImportConversation 从导出内容恢复会话，会话归属 user；agentID 不为 0 时导入到指定智能体。
+// 非管理员只能导入到自己所在用户组可用的智能体。附件先行保存，会话与消息在同一事务中创建
+func ImportConversation(eid int64, user *model.User, isAdmin bool, agentID int64, doc *ExportedConversation) (*model.Conversation, error) {
+	if doc.Version <= 0 || doc.Version > ExportVersion {
+		return nil, fmt.Errorf("unsupported export version: %d", doc.Version)
+	}
+	if agentID == 0 {
+		agentID = doc.AgentID
+	}
+	agent, err := model.GetAgentByID(eid, agentID)
+	if err != nil {
+		return nil, fmt.Errorf("agent %d not found", agentID)
+	}
+	if !isAdmin {
+		if err := checkAgentPermission(agent, user); err != nil {
+			return nil, err
+		}
+	}
+
+	fileIDMap := make(map[int64]int64)
+	for _, attachment := range doc.Attachments {
+		if newID, err := restoreAttachment(eid, user.UserID, attachment); err == nil {
+			fileIDMap[attachment.FileID] = newID
+		}
+	}
+
+	conversation := &model.Conversation{
+		Eid:     eid,
+		UserID:  user.UserID,
+		AgentID: agent.AgentID,
+		Title:   doc.Title,
+		Status:  model.ConversationStatusActive,
+		Model:   doc.Model,
+	}
+	conversation.CreatedTime = doc.CreatedTime
+
+	messages := append([]*ExportedMessage{}, doc.Messages...)
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].ID < messages[j].ID
+	})
+	created := make([]*model.Message, 0, len(messages))
+	err = model.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(conversation).Error; err != nil {
+			return err
+		}
+		messageIDMap := make(map[int64]int64)
+		var lastMessage *model.Message
+		for _, exported := range messages {
+			message := importedMessage(conversation, exported, fileIDMap)
+			// 父节点不在导出内容中时接到上一条消息之后，第一条消息为根节点
+			if parentID, ok := messageIDMap[exported.ParentID]; ok {
+				message.ParentID = parentID
+			} else if lastMessage != nil {
+				message.ParentID = lastMessage.ID
+			}
+			if err := tx.Create(message).Error; err != nil {
+				return err
+			}
+			messageIDMap[exported.ID] = message.ID
+			lastMessage = message
+			created = append(created, message)
+			conversation.TotalTokens += message.TotalTokens
+		}
+		if lastMessage == nil {
+			return nil
+		}
+		lastMessageJSON, _ := json.Marshal(map[string]string{
+			"question": lastMessage.Message,
+			"answer":   lastMessage.Answer,
+		})
+		conversation.LastMessage = string(lastMessageJSON)
+		conversation.ActiveMessageID = lastMessage.ID
+		return tx.Model(conversation).Select("last_message", "total_tokens", "active_message_id").Updates(conversation).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, message := range created {
+		if err := model.IndexMessage(message); err != nil {
+			logger.SysErrorf("index imported message %d failed: %v", message.ID, err)
+		}
+	}
+	return conversation, nil
+}
+
+// checkAgentPermission 与继续分享会话相同：智能体的可用用户组需包含导入者所在的用户组
+func checkAgentPermission(agent *model.Agent, user *model.User) error {
+	agentUserGroupIds, err := agent.GetUserGroupIds()
+	if err != nil {
+		return err
+	}
+	userGroupIds, err := user.GetUserGroupIds()
+	if err != nil {
+		return err
+	}
+	if !helper.HasIntersection(agentUserGroupIds, userGroupIds) {
+		return ErrAgentForbidden
+	}
+	return nil
+}
+
+func importedMessage(conversation *model.Conversation, exported *ExportedMessage, fileIDMap map[int64]int64) *model.Message {
+	message := &model.Message{
+		Eid:              conversation.Eid,
+		UserID:           conversation.UserID,
+		AgentID:          conversation.AgentID,
+		ConversationID:   conversation.ConversationID,
+		Message:          remapFileIDs(importedRawMessage(exported), fileIDMap),
+		Answer:           exported.Answer,
+		ReasoningContent: exported.ReasoningContent,
+		ModelName:        exported.ModelName,
+		PromptTokens:     exported.PromptTokens,
+		CompletionTokens: exported.CompletionTokens,
+		TotalTokens:      exported.TotalTokens,
+	}
+	message.CreatedTime = exported.CreatedTime
+	if message.Answer == "" && exported.Output != nil {
+		if output, err := json.Marshal(exported.Output); err == nil {
+			message.Answer = string(output)
+		}
+	}
+	if len(exported.Citations) > 0 {
+		if citations, err := json.Marshal(exported.Citations); err == nil {
+			message.Citations = string(citations)
+		}
+	}
+	return message
+}
+
+// importedRawMessage 优先使用原始请求消息，手工编写的导入文件可只提供 query 或 parameters
+func importedRawMessage(exported *ExportedMessage) string {
+	if exported.RawMessage != "" {
+		return exported.RawMessage
+	}
+	var data []byte
+	if exported.Type == model.MessageTypeWorkflow {
+		data, _ = json.Marshal(exported.Parameters)
+	} else {
+		data, _ = json.Marshal([]map[string]interface{}{
+			{"role": "user", "content": exported.Query},
+		})
+	}
+	return string(data)
+}
+
+// remapFileIDs 将消息中的 file_id:旧ID 替换为导入后的文件ID
+func remapFileIDs(raw string, fileIDMap map[int64]int64) string {
+	if len(fileIDMap) == 0 {
+		return raw
+	}
+	return fileIDRegexp.ReplaceAllStringFunc(raw, func(match string) string {
+		oldID, err := strconv.ParseInt(strings.TrimPrefix(match, "file_id:"), 10, 64)
+		if err != nil {
+			return match
+		}
+		if newID, ok := fileIDMap[oldID]; ok {
+			return "file_id:" + strconv.FormatInt(newID, 10)
+		}
+		return match
+	})
+}
+
+// restoreAttachment 恢复附件：有文件内容时重新保存，否则按哈希复用导入者本人已上传的文件，
+// 不能借导入文件中的哈希取得他人上传的文件
+func restoreAttachment(eid int64, userID int64, attachment *ExportedAttachment) (int64, error) {
+	if attachment.Data == nil {
+		if attachment.Hash == "" {
+			return 0, errors.New("attachment data is missing")
+		}
+		uploadFile, err := model.GetUserUploadFileByHash(eid, userID, attachment.Hash)
+		if err != nil {
+			return 0, err
+		}
+		return uploadFile.ID, nil
+	}
+
+	sum := sha256.Sum256(attachment.Data)
+	hashStr := hex.EncodeToString(sum[:])
+	extension := path.Ext(attachment.FileName)
+	previewKey, err := model.GetPreviewKey(hashStr, extension)
+	if err != nil {
+		return 0, err
+	}
+	key := model.GetFileKey(previewKey, eid, userID)
+	if err := storage.StorageInstance.Save(attachment.Data, key); err != nil {
+		return 0, err
+	}
+	uploadFile := &model.UploadFile{
+		FileName:   attachment.FileName,
+		Key:        key,
+		Eid:        eid,
+		UserID:     userID,
+		Size:       int64(len(attachment.Data)),
+		Extension:  extension,
+		MimeType:   attachment.MimeType,
+		Hash:       hashStr,
+		PreviewKey: previewKey,
+	}
+	if err := uploadFile.Save(); err != nil {
+		return 0, err
+	}
+	return uploadFile.ID, nil
+}
@@ -0,0 +1,131 @@
+package conversation
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+)
+
+func setupDB(t *testing.T) {
+	testutil.SetupDB(t, &model.Agent{}, &model.ResourcePermission{}, &model.User{}, &model.Conversation{},
+		&model.Message{}, &model.MessageSearchDocument{}, &model.UploadFile{})
+}
+
+func createAgent(t *testing.T, groupID int64) *model.Agent {
+	agent := &model.Agent{Eid: 1, Name: "agent", Enable: true}
+	if err := model.DB.Create(agent).Error; err != nil {
+		t.Fatal(err)
+	}
+	permission := &model.ResourcePermission{GroupID: groupID, ResourceID: agent.AgentID, ResourceType: model.ResourceTypeAgent, Permission: "read"}
+	if err := model.DB.Create(permission).Error; err != nil {
+		t.Fatal(err)
+	}
+	return agent
+}
+
+func exportDoc(agentID int64) *ExportedConversation {
+	return &ExportedConversation{
+		Version: ExportVersion,
+		AgentID: agentID,
+		Title:   "imported",
+		Messages: []*ExportedMessage{
+			{ID: 1, Query: "q1", Answer: "a1", TotalTokens: 3},
+			{ID: 2, ParentID: 1, Query: "see file_id:100", Answer: "a2", TotalTokens: 4},
+		},
+		Attachments: []*ExportedAttachment{{FileID: 100, FileName: "a.txt", Hash: "h"}},
+	}
+}
+
+func TestImportConversationChecksAgentPermission(t *testing.T) {
+	setupDB(t)
+	allowed := createAgent(t, 5)
+	denied := createAgent(t, 6)
+	user := &model.User{UserID: 7, Eid: 1, Type: model.UserTypeRegistered, GroupId: 5}
+
+	// 导入文件中的智能体与显式指定的智能体都需要有使用权限
+	if _, err := ImportConversation(1, user, false, 0, exportDoc(denied.AgentID)); !errors.Is(err, ErrAgentForbidden) {
+		t.Fatalf("import into agent from file err = %v", err)
+	}
+	if _, err := ImportConversation(1, user, false, denied.AgentID, exportDoc(allowed.AgentID)); !errors.Is(err, ErrAgentForbidden) {
+		t.Fatalf("import into given agent err = %v", err)
+	}
+	if _, err := ImportConversation(1, user, true, denied.AgentID, exportDoc(allowed.AgentID)); err != nil {
+		t.Fatalf("admin import err = %v", err)
+	}
+
+	conversation, err := ImportConversation(1, user, false, 0, exportDoc(allowed.AgentID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var messages []*model.Message
+	model.DB.Where("conversation_id = ?", conversation.ConversationID).Order("id").Find(&messages)
+	if len(messages) != 2 || messages[0].ParentID != 0 || messages[1].ParentID != messages[0].ID {
+		t.Fatalf("unexpected messages: %+v", messages)
+	}
+	stored, _ := model.GetConversationByID(1, user.UserID, conversation.ConversationID)
+	if stored.ActiveMessageID != messages[1].ID || stored.TotalTokens != 7 || stored.LastMessage == "" {
+		t.Fatalf("unexpected conversation: %+v", stored)
+	}
+	var indexed int64
+	model.DB.Model(&model.MessageSearchDocument{}).Where("conversation_id = ?", conversation.ConversationID).Count(&indexed)
+	if indexed != 2 {
+		t.Fatalf("imported messages should be indexed, got %d", indexed)
+	}
+}
+
+func TestImportConversationRollsBack(t *testing.T) {
+	setupDB(t)
+	agent := createAgent(t, 5)
+	user := &model.User{UserID: 7, Eid: 1, Type: model.UserTypeRegistered, GroupId: 5}
+	if err := model.DB.Migrator().DropTable(&model.Message{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ImportConversation(1, user, false, 0, exportDoc(agent.AgentID)); err == nil {
+		t.Fatal("import should fail when messages cannot be created")
+	}
+	var count int64
+	model.DB.Model(&model.Conversation{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("failed import should not leave a conversation, got %d", count)
+	}
+}
+
+func TestImportReusesOnlyOwnFiles(t *testing.T) {
+	setupDB(t)
+	agent := createAgent(t, 5)
+	user := &model.User{UserID: 7, Eid: 1, Type: model.UserTypeRegistered, GroupId: 5}
+	others := &model.UploadFile{Eid: 1, UserID: 8, FileName: "secret.txt", Key: "k1", Hash: "h"}
+	if err := model.DB.Create(others).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	conversation, err := ImportConversation(1, user, false, 0, exportDoc(agent.AgentID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var message model.Message
+	model.DB.Where("conversation_id = ? AND answer = ?", conversation.ConversationID, "a2").First(&message)
+	// 他人的文件不会被复用，消息中保留原文件 ID
+	if !strings.Contains(message.Message, "file_id:100") {
+		t.Fatalf("another user's file should not be reused: %s", message.Message)
+	}
+
+	own := &model.UploadFile{Eid: 1, UserID: 7, FileName: "mine.txt", Key: "k2", Hash: "h"}
+	if err := model.DB.Create(own).Error; err != nil {
+		t.Fatal(err)
+	}
+	conversation, err = ImportConversation(1, user, false, 0, exportDoc(agent.AgentID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	message = model.Message{}
+	model.DB.Where("conversation_id = ? AND answer = ?", conversation.ConversationID, "a2").First(&message)
+	if !strings.Contains(message.Message, "file_id:"+strconv.FormatInt(own.ID, 10)) {
+		t.Fatalf("own file should be reused: %s", message.Message)
+	}
+}
@@ -0,0 +1,148 @@
+package conversation
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/common/storage"
+	"github.com/53AI/53AIHub/model"
+)
+
+// RunExportJobAsync 异步执行批量导出任务
+func RunExportJobAsync(job *model.ConversationExportJob) {
+	go func() {
+		if err := RunExportJob(job); err != nil {
+			logger.SysErrorf("conversation export job failed, job_id=%d: %v", job.ID, err)
+		}
+	}()
+}
+
+// RunExportJob 按筛选条件导出会话并打包为 zip 保存到存储，执行结果写回任务状态
+func RunExportJob(job *model.ConversationExportJob) error {
+	job.Status = model.ConversationExportStatusProcessing
+	job.ErrorMessage = ""
+	if err := model.UpdateConversationExportJob(job); err != nil {
+		return err
+	}
+
+	err := runExportJob(job)
+	if err != nil {
+		job.Status = model.ConversationExportStatusFailed
+		job.ErrorMessage = err.Error()
+	} else {
+		job.Status = model.ConversationExportStatusCompleted
+	}
+	job.FinishedTime = time.Now().UTC().UnixMilli()
+	if updateErr := model.UpdateConversationExportJob(job); updateErr != nil {
+		return updateErr
+	}
+	return err
+}
+
+func runExportJob(job *model.ConversationExportJob) error {
+	const batchSize = 100
+	formats := job.GetFormats()
+	for _, format := range formats {
+		if !model.IsValidConversationExportFormat(format) {
+			return fmt.Errorf("unsupported export format: %s", format)
+		}
+	}
+
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	var count int64
+	var lastID int64
+	for {
+		conversations, err := model.FindConversationsForExport(job, lastID, batchSize)
+		if err != nil {
+			return err
+		}
+		for _, conversation := range conversations {
+			lastID = conversation.ConversationID
+			if err := writeConversation(writer, conversation, formats); err != nil {
+				return fmt.Errorf("export conversation %d failed: %w", conversation.ConversationID, err)
+			}
+			count++
+		}
+		if len(conversations) < batchSize {
+			break
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	fileName := fmt.Sprintf("conversations_%d_%s.zip", job.ID, time.Now().Format("20060102150405"))
+	key := storage.StorageInstance.GetBasePath() + "/" + path.Join(strconv.FormatInt(job.Eid, 10), "exports", fileName)
+	if err := storage.StorageInstance.Save(buf.Bytes(), key); err != nil {
+		return err
+	}
+	job.ConversationCount = count
+	job.FileKey = key
+	job.FileName = fileName
+	job.FileSize = int64(buf.Len())
+	return nil
+}
+
+// writeConversation 将会话写入 zip 中独立的目录，附件保存在目录下的 attachments 中
+func writeConversation(writer *zip.Writer, conversation *model.Conversation, formats []string) error {
+	doc, err := BuildExport(conversation, true)
+	if err != nil {
+		return err
+	}
+	dir := ExportFileName(doc)
+
+	for _, attachment := range doc.Attachments {
+		if attachment.Data == nil {
+			continue
+		}
+		attachment.Path = path.Join("attachments", attachmentFileName(attachment))
+		if err := writeZipFile(writer, path.Join(dir, attachment.Path), attachment.Data); err != nil {
+			return err
+		}
+	}
+
+	for _, format := range formats {
+		var data []byte
+		if format == model.ConversationExportFormatJSON {
+			// 附件已单独打包，JSON 中只保留路径
+			data, err = json.MarshalIndent(withoutAttachmentData(doc), "", "  ")
+		} else {
+			data, err = Render(doc, format)
+		}
+		if err != nil {
+			return err
+		}
+		if err := writeZipFile(writer, path.Join(dir, "conversation"+FileExtension(format)), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeZipFile(writer *zip.Writer, name string, data []byte) error {
+	w, err := writer.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// withoutAttachmentData 返回不含附件内容的副本
+func withoutAttachmentData(doc *ExportedConversation) *ExportedConversation {
+	copied := *doc
+	copied.Attachments = make([]*ExportedAttachment, 0, len(doc.Attachments))
+	for _, attachment := range doc.Attachments {
+		a := *attachment
+		a.Data = nil
+		copied.Attachments = append(copied.Attachments, &a)
+	}
+	return &copied
+}
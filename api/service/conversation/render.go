@@ -0,0 +1,283 @@
+package conversation
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/53AI/53AIHub/model"
+)
+
+var invalidFileNameRegexp = regexp.MustCompile(`[\\/:*?"<>|\s]+`)
+
+// Render 按格式渲染导出内容
+func Render(doc *ExportedConversation, format string) ([]byte, error) {
+	switch format {
+	case model.ConversationExportFormatMarkdown:
+		return renderMarkdown(doc), nil
+	case model.ConversationExportFormatJSON:
+		return json.MarshalIndent(doc, "", "  ")
+	case model.ConversationExportFormatHTML:
+		return renderHTML(doc)
+	default:
+		return nil, fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+// FileExtension 导出格式对应的文件扩展名
+func FileExtension(format string) string {
+	switch format {
+	case model.ConversationExportFormatMarkdown:
+		return ".md"
+	case model.ConversationExportFormatHTML:
+		return ".html"
+	default:
+		return ".json"
+	}
+}
+
+// ContentType 导出格式对应的 Content-Type
+func ContentType(format string) string {
+	switch format {
+	case model.ConversationExportFormatMarkdown:
+		return "text/markdown; charset=utf-8"
+	case model.ConversationExportFormatHTML:
+		return "text/html; charset=utf-8"
+	default:
+		return "application/json; charset=utf-8"
+	}
+}
+
+// ExportFileName 单个会话导出的文件名（不含扩展名）
+func ExportFileName(doc *ExportedConversation) string {
+	title := sanitizeFileName(doc.Title)
+	if title == "" {
+		return fmt.Sprintf("conversation_%d", doc.ConversationID)
+	}
+	return fmt.Sprintf("%d_%s", doc.ConversationID, title)
+}
+
+func sanitizeFileName(name string) string {
+	name = strings.Trim(invalidFileNameRegexp.ReplaceAllString(name, "_"), "_.")
+	runes := []rune(name)
+	if len(runes) > 50 {
+		name = string(runes[:50])
+	}
+	return name
+}
+
+func formatTime(milli int64) string {
+	if milli == 0 {
+		return ""
+	}
+	return time.UnixMilli(milli).Format("2006-01-02 15:04:05")
+}
+
+// formatValue 将工作流参数或输出的值格式化为文本
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func attachmentLink(attachment *ExportedAttachment) string {
+	if attachment.Path != "" {
+		return attachment.Path
+	}
+	return attachment.URL
+}
+
+// messageAttachments 返回消息引用的附件
+func messageAttachments(doc *ExportedConversation, message *ExportedMessage) []*ExportedAttachment {
+	attachments := make([]*ExportedAttachment, 0, len(message.AttachmentIDs))
+	for _, fileID := range message.AttachmentIDs {
+		for _, attachment := range doc.Attachments {
+			if attachment.FileID == fileID {
+				attachments = append(attachments, attachment)
+				break
+			}
+		}
+	}
+	return attachments
+}
+
+func assistantName(doc *ExportedConversation) string {
+	if doc.AgentName != "" {
+		return doc.AgentName
+	}
+	return "助手"
+}
+
+func renderMarkdown(doc *ExportedConversation) []byte {
+	var sb strings.Builder
+	title := doc.Title
+	if title == "" {
+		title = fmt.Sprintf("会话 %d", doc.ConversationID)
+	}
+	fmt.Fprintf(&sb, "# %s\n\n", title)
+	fmt.Fprintf(&sb, "- 智能体：%s\n", doc.AgentName)
+	if doc.UserName != "" {
+		fmt.Fprintf(&sb, "- 用户：%s\n", doc.UserName)
+	}
+	fmt.Fprintf(&sb, "- 创建时间：%s\n", formatTime(doc.CreatedTime))
+	fmt.Fprintf(&sb, "- 导出时间：%s\n\n---\n\n", formatTime(doc.ExportedTime))
+
+	for _, message := range doc.Messages {
+		fmt.Fprintf(&sb, "### 用户 · %s\n\n", formatTime(message.CreatedTime))
+		if message.Type == model.MessageTypeWorkflow {
+			for _, key := range sortedKeys(message.Parameters) {
+				fmt.Fprintf(&sb, "- **%s**：%s\n", key, formatValue(message.Parameters[key]))
+			}
+			sb.WriteString("\n")
+		} else if message.Query != "" {
+			sb.WriteString(message.Query + "\n\n")
+		}
+		for _, attachment := range messageAttachments(doc, message) {
+			if attachment.IsImage() {
+				fmt.Fprintf(&sb, "![%s](%s)\n\n", attachment.FileName, attachmentLink(attachment))
+			} else {
+				fmt.Fprintf(&sb, "[%s](%s)\n\n", attachment.FileName, attachmentLink(attachment))
+			}
+		}
+
+		fmt.Fprintf(&sb, "### %s\n\n", assistantName(doc))
+		if message.ReasoningContent != "" {
+			fmt.Fprintf(&sb, "<details>\n<summary>思考过程</summary>\n\n%s\n\n</details>\n\n", message.ReasoningContent)
+		}
+		if message.Output != nil {
+			for _, key := range sortedKeys(message.Output) {
+				value := formatValue(message.Output[key])
+				if strings.Contains(value, "\n") {
+					fmt.Fprintf(&sb, "**%s**：\n\n%s\n\n", key, value)
+				} else {
+					fmt.Fprintf(&sb, "- **%s**：%s\n", key, value)
+				}
+			}
+			sb.WriteString("\n")
+		} else {
+			sb.WriteString(message.Answer + "\n\n")
+		}
+		if len(message.Citations) > 0 {
+			sb.WriteString("引用：\n\n")
+			for i, citation := range message.Citations {
+				fmt.Fprintf(&sb, "%d. %s\n", i+1, citation.FileName)
+			}
+			sb.WriteString("\n")
+		}
+		sb.WriteString("---\n\n")
+	}
+	return []byte(sb.String())
+}
+
+const htmlTemplate = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "PingFang SC", "Microsoft YaHei", sans-serif; color: #1f2329; max-width: 860px; margin: 0 auto; padding: 32px 24px; line-height: 1.7; }
+h1 { font-size: 24px; margin: 0 0 8px; }
+.meta { color: #8f959e; font-size: 13px; margin-bottom: 24px; }
+.turn { border-top: 1px solid #e5e6eb; padding: 16px 0; page-break-inside: avoid; break-inside: avoid; }
+.role { font-weight: 600; font-size: 14px; margin: 8px 0 4px; }
+.role .time { color: #8f959e; font-weight: normal; margin-left: 8px; }
+.content { white-space: pre-wrap; word-break: break-word; }
+.user .content { background: #f2f3f5; border-radius: 8px; padding: 8px 12px; }
+.reasoning { color: #646a73; border-left: 3px solid #dee0e3; padding-left: 12px; white-space: pre-wrap; margin-bottom: 8px; }
+.attachments img { max-width: 100%; max-height: 480px; border-radius: 4px; margin: 8px 0; display: block; }
+.attachments a { display: inline-block; margin: 4px 8px 4px 0; }
+dl { margin: 0; }
+dt { font-weight: 600; }
+dd { margin: 0 0 8px 0; white-space: pre-wrap; }
+.citations { color: #646a73; font-size: 13px; }
+@media print {
+  body { max-width: none; padding: 0; }
+  a { color: inherit; }
+}
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="meta">智能体：{{.AgentName}}{{if .UserName}} · 用户：{{.UserName}}{{end}} · 创建时间：{{formatTime .CreatedTime}} · 导出时间：{{formatTime .ExportedTime}}</div>
+{{range .Messages}}
+<div class="turn">
+  <div class="user">
+    <div class="role">用户<span class="time">{{formatTime .CreatedTime}}</span></div>
+    {{if .Parameters}}<dl class="content">{{range $key, $value := .Parameters}}<dt>{{$key}}</dt><dd>{{formatValue $value}}</dd>{{end}}</dl>{{else}}<div class="content">{{.Query}}</div>{{end}}
+    <div class="attachments">
+    {{range attachments .}}{{if and .IsImage .Data}}<img src="{{dataURI .}}" alt="{{.FileName}}">{{else}}<a href="{{link .}}">{{.FileName}}</a>{{end}}
+    {{end}}
+    </div>
+  </div>
+  <div class="assistant">
+    <div class="role">{{$.AssistantName}}</div>
+    {{if .ReasoningContent}}<div class="reasoning">{{.ReasoningContent}}</div>{{end}}
+    {{if .Output}}<dl class="content">{{range $key, $value := .Output}}<dt>{{$key}}</dt><dd>{{formatValue $value}}</dd>{{end}}</dl>{{else}}<div class="content">{{.Answer}}</div>{{end}}
+    {{if .Citations}}<ol class="citations">{{range .Citations}}<li>{{.FileName}}</li>{{end}}</ol>{{end}}
+  </div>
+</div>
+{{end}}
+</body>
+</html>
+`
+
+// renderHTML 渲染为单文件 HTML，图片附件以 data URI 内嵌，适合浏览器打印为 PDF
+func renderHTML(doc *ExportedConversation) ([]byte, error) {
+	tmpl, err := template.New("conversation").Funcs(template.FuncMap{
+		"formatTime":  formatTime,
+		"formatValue": formatValue,
+		"link": func(attachment *ExportedAttachment) template.URL {
+			return template.URL(attachmentLink(attachment))
+		},
+		"dataURI": func(attachment *ExportedAttachment) template.URL {
+			return template.URL("data:" + attachment.MimeType + ";base64," + base64.StdEncoding.EncodeToString(attachment.Data))
+		},
+		"attachments": func(message *ExportedMessage) []*ExportedAttachment {
+			return messageAttachments(doc, message)
+		},
+	}).Parse(htmlTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	title := doc.Title
+	if title == "" {
+		title = fmt.Sprintf("会话 %d", doc.ConversationID)
+	}
+	data := struct {
+		*ExportedConversation
+		Title         string
+		AssistantName string
+	}{doc, title, assistantName(doc)}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
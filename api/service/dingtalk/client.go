@@ -0,0 +1,235 @@
+package dingtalk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/orgsync"
+)
+
+// 钉钉开放平台地址，测试时替换为本地模拟服务
+var (
+	oapiBase  = "https://oapi.dingtalk.com"
+	apiBase   = "https://api.dingtalk.com"
+	loginBase = "https://login.dingtalk.com"
+)
+
+const (
+	rootDepartmentID = 1
+	pageSize         = 100
+
+	// 通过 unionid 查询 userid 时成员不在企业通讯录中
+	errcodeUserNotFound = 60121
+)
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// Client 钉钉企业内部应用，实现 orgsync.Provider 与 orgsync.Authenticator
+type Client struct {
+	cfg         *Config
+	accessToken string
+}
+
+var _ orgsync.Authenticator = (*Client)(nil)
+
+func NewClient(cfg *Config) *Client {
+	return &Client{cfg: cfg}
+}
+
+// Load 按企业配置创建客户端
+func Load(eid int64) (*Client, error) {
+	cfg, err := LoadConfig(eid)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(cfg), nil
+}
+
+func (c *Client) Source() int {
+	return model.DepartmentFromDingTalk
+}
+
+// oapiError 旧版服务端接口的公共返回
+type oapiError struct {
+	Errcode int    `json:"errcode"`
+	Errmsg  string `json:"errmsg"`
+}
+
+func (e *oapiError) err() error {
+	if e.Errcode != 0 {
+		return fmt.Errorf("dingtalk api error %d: %s", e.Errcode, e.Errmsg)
+	}
+	return nil
+}
+
+func (c *Client) token() (string, error) {
+	if c.accessToken != "" {
+		return c.accessToken, nil
+	}
+	query := url.Values{"appkey": {c.cfg.AppKey}, "appsecret": {c.cfg.AppSecret}}
+	var resp struct {
+		oapiError
+		AccessToken string `json:"access_token"`
+	}
+	if err := doJSON(http.MethodGet, oapiBase+"/gettoken?"+query.Encode(), nil, nil, &resp); err != nil {
+		return "", err
+	}
+	if err := resp.err(); err != nil {
+		return "", err
+	}
+	c.accessToken = resp.AccessToken
+	return c.accessToken, nil
+}
+
+// oapi 调用旧版服务端接口，result 需内嵌 oapiError
+func (c *Client) oapi(path string, body interface{}, result interface{ err() error }) error {
+	token, err := c.token()
+	if err != nil {
+		return err
+	}
+	if err := doJSON(http.MethodPost, oapiBase+path+"?access_token="+url.QueryEscape(token), body, nil, result); err != nil {
+		return err
+	}
+	return result.err()
+}
+
+func doJSON(method string, rawURL string, body interface{}, header map[string]string, result interface{}) error {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequest(method, rawURL, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		// 新版接口出错时返回 {"code":"...","message":"..."}
+		var apiErr struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+		return fmt.Errorf("dingtalk api status %d: %s %s", resp.StatusCode, apiErr.Code, apiErr.Message)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+type department struct {
+	DeptID   int64  `json:"dept_id"`
+	Name     string `json:"name"`
+	ParentID int64  `json:"parent_id"`
+	Order    int    `json:"order"`
+}
+
+type departmentResponse struct {
+	oapiError
+	Result department `json:"result"`
+}
+
+type departmentListResponse struct {
+	oapiError
+	Result []department `json:"result"`
+}
+
+// Departments 从根部门逐级获取子部门，根部门即企业本身
+func (c *Client) Departments() ([]*orgsync.Department, error) {
+	var root departmentResponse
+	if err := c.oapi("/topapi/v2/department/get", map[string]int64{"dept_id": rootDepartmentID}, &root); err != nil {
+		return nil, err
+	}
+	departments := []*orgsync.Department{{ID: strconv.FormatInt(rootDepartmentID, 10), Name: root.Result.Name}}
+
+	queue := []int64{rootDepartmentID}
+	for len(queue) > 0 {
+		parentID := queue[0]
+		queue = queue[1:]
+		var resp departmentListResponse
+		if err := c.oapi("/topapi/v2/department/listsub", map[string]int64{"dept_id": parentID}, &resp); err != nil {
+			return nil, err
+		}
+		for _, item := range resp.Result {
+			departments = append(departments, &orgsync.Department{
+				ID:       strconv.FormatInt(item.DeptID, 10),
+				ParentID: strconv.FormatInt(item.ParentID, 10),
+				Name:     item.Name,
+				Order:    item.Order,
+			})
+			queue = append(queue, item.DeptID)
+		}
+	}
+	return departments, nil
+}
+
+type user struct {
+	UserID     string  `json:"userid"`
+	UnionID    string  `json:"unionid"`
+	Name       string  `json:"name"`
+	Avatar     string  `json:"avatar"`
+	Mobile     string  `json:"mobile"`
+	Email      string  `json:"email"`
+	OrgEmail   string  `json:"org_email"`
+	DeptIDList []int64 `json:"dept_id_list"`
+}
+
+func (u *user) member() *orgsync.Member {
+	member := &orgsync.Member{ID: u.UserID, Name: u.Name, Avatar: u.Avatar, Mobile: u.Mobile, Email: u.Email}
+	if member.Email == "" {
+		member.Email = u.OrgEmail
+	}
+	for _, id := range u.DeptIDList {
+		member.DepartmentIDs = append(member.DepartmentIDs, strconv.FormatInt(id, 10))
+	}
+	return member
+}
+
+// Members 分页获取部门直属成员，离职成员不会出现在通讯录中
+func (c *Client) Members(departmentID string) ([]*orgsync.Member, error) {
+	deptID, err := strconv.ParseInt(departmentID, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]*orgsync.Member, 0)
+	cursor := int64(0)
+	for {
+		var resp struct {
+			oapiError
+			Result struct {
+				HasMore    bool   `json:"has_more"`
+				NextCursor int64  `json:"next_cursor"`
+				List       []user `json:"list"`
+			} `json:"result"`
+		}
+		body := map[string]int64{"dept_id": deptID, "cursor": cursor, "size": pageSize}
+		if err := c.oapi("/topapi/v2/user/list", body, &resp); err != nil {
+			return nil, err
+		}
+		for i := range resp.Result.List {
+			members = append(members, resp.Result.List[i].member())
+		}
+		if !resp.Result.HasMore {
+			return members, nil
+		}
+		cursor = resp.Result.NextCursor
+	}
+}
@@ -0,0 +1,52 @@
+package dingtalk
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/53AI/53AIHub/model"
+	"gorm.io/gorm"
+)
+
+var ErrNotEnabled = errors.New("dingtalk is not enabled")
+
+// Config 企业配置 dingtalk 的内容，使用钉钉企业内部应用的 AppKey / AppSecret
+//
+// 应用需开通通讯录只读权限与个人信息权限，登录回调地址需在开发者后台配置
+type Config struct {
+	AppKey    string `json:"app_key"`
+	AppSecret string `json:"app_secret"`
+	// AutoCreateUser 成员首次扫码登录时自动创建企业成员，否则需已有相同手机号或邮箱的成员
+	AutoCreateUser bool `json:"auto_create_user"`
+}
+
+// ParseConfig 解析并校验 dingtalk 配置内容
+func ParseConfig(content string) (*Config, error) {
+	var cfg Config
+	if err := json.Unmarshal([]byte(content), &cfg); err != nil {
+		return nil, err
+	}
+	cfg.AppKey = strings.TrimSpace(cfg.AppKey)
+	cfg.AppSecret = strings.TrimSpace(cfg.AppSecret)
+	if cfg.AppKey == "" || cfg.AppSecret == "" {
+		return nil, errors.New("dingtalk app_key and app_secret are required")
+	}
+	return &cfg, nil
+}
+
+// LoadConfig 读取企业已启用的 dingtalk 配置
+func LoadConfig(eid int64) (*Config, error) {
+	var record model.EnterpriseConfig
+	err := model.DB.Where("eid = ? AND type = ?", eid, model.EnterpriseConfigTypeDingTalk).First(&record).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotEnabled
+		}
+		return nil, err
+	}
+	if !record.Enabled {
+		return nil, ErrNotEnabled
+	}
+	return ParseConfig(record.Content)
+}
@@ -0,0 +1,207 @@
+package dingtalk
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/orgsync"
+)
+
+// fakeDingTalk 模拟钉钉开放平台的通讯录与登录接口
+type fakeDingTalk struct {
+	mu    sync.Mutex
+	users map[string]map[string]interface{} // userid -> 成员详情
+}
+
+func newFakeDingTalk(t *testing.T) *fakeDingTalk {
+	f := &fakeDingTalk{users: map[string]map[string]interface{}{
+		"alice": {"userid": "alice", "unionid": "union-alice", "name": "Alice", "mobile": "13800138000", "dept_id_list": []int64{2}},
+		"bob":   {"userid": "bob", "unionid": "union-bob", "name": "Bob", "email": "bob@example.com", "dept_id_list": []int64{1, 3}},
+	}}
+	reply := func(w http.ResponseWriter, v map[string]interface{}) {
+		if _, ok := v["errcode"]; !ok {
+			v["errcode"] = 0
+		}
+		_ = json.NewEncoder(w).Encode(v)
+	}
+	body := func(r *http.Request) map[string]interface{} {
+		var v map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&v)
+		return v
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/gettoken", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("appkey") != "key" || r.URL.Query().Get("appsecret") != "secret" {
+			reply(w, map[string]interface{}{"errcode": 40089, "errmsg": "invalid appkey"})
+			return
+		}
+		reply(w, map[string]interface{}{"access_token": "corp-token"})
+	})
+	mux.HandleFunc("/topapi/v2/department/get", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]interface{}{"result": map[string]interface{}{"dept_id": 1, "name": "Acme"}})
+	})
+	mux.HandleFunc("/topapi/v2/department/listsub", func(w http.ResponseWriter, r *http.Request) {
+		children := map[float64][]map[string]interface{}{
+			1: {{"dept_id": 2, "name": "Engineering", "parent_id": 1}, {"dept_id": 3, "name": "Sales", "parent_id": 1}},
+		}
+		reply(w, map[string]interface{}{"result": children[body(r)["dept_id"].(float64)]})
+	})
+	mux.HandleFunc("/topapi/v2/user/list", func(w http.ResponseWriter, r *http.Request) {
+		deptID := int64(body(r)["dept_id"].(float64))
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		list := make([]map[string]interface{}, 0)
+		for _, u := range f.users {
+			for _, id := range u["dept_id_list"].([]int64) {
+				if id == deptID {
+					list = append(list, u)
+				}
+			}
+		}
+		reply(w, map[string]interface{}{"result": map[string]interface{}{"has_more": false, "list": list}})
+	})
+	mux.HandleFunc("/v1.0/oauth2/userAccessToken", func(w http.ResponseWriter, r *http.Request) {
+		v := body(r)
+		if v["clientId"] != "key" || v["code"] == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"code": "invalidCode", "message": "invalid code"})
+			return
+		}
+		// 测试中授权码即成员的 unionid
+		_ = json.NewEncoder(w).Encode(map[string]string{"accessToken": v["code"].(string)})
+	})
+	mux.HandleFunc("/v1.0/contact/users/me", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"unionId": r.Header.Get("x-acs-dingtalk-access-token")})
+	})
+	mux.HandleFunc("/topapi/user/getbyunionid", func(w http.ResponseWriter, r *http.Request) {
+		unionID := body(r)["unionid"]
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for id, u := range f.users {
+			if u["unionid"] == unionID {
+				reply(w, map[string]interface{}{"result": map[string]string{"userid": id}})
+				return
+			}
+		}
+		reply(w, map[string]interface{}{"errcode": errcodeUserNotFound, "errmsg": "user not found"})
+	})
+	mux.HandleFunc("/topapi/v2/user/get", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		reply(w, map[string]interface{}{"result": f.users[body(r)["userid"].(string)]})
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	oapiBase, apiBase, loginBase = server.URL, server.URL, server.URL
+	return f
+}
+
+func setupDB(t *testing.T) {
+	db := testutil.SetupDB(t, &model.EnterpriseConfig{}, &model.User{}, &model.MemberBinding{},
+		&model.Department{}, &model.MemberDepartmentRelation{})
+	content := `{"app_key":"key","app_secret":"secret","auto_create_user":true}`
+	db.Create(&model.EnterpriseConfig{EID: 1, Type: model.EnterpriseConfigTypeDingTalk, Enabled: true, Content: content})
+}
+
+func TestSyncOrganization(t *testing.T) {
+	f := newFakeDingTalk(t)
+	setupDB(t)
+	client, err := Load(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := orgsync.Sync(1, client)
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Departments != 3 || result.Created != 2 {
+		t.Fatalf("unexpected result: %+v", *result)
+	}
+
+	departments, _ := model.GetDepartmentsByEID(1, model.DepartmentFromDingTalk)
+	byID := map[string]*model.Department{}
+	for _, department := range departments {
+		byID[department.BindValue] = department
+	}
+	if byID["1"] == nil || byID["2"] == nil || byID["2"].PDID != byID["1"].DID || byID["3"].Name != "Sales" {
+		t.Fatalf("department tree not synced: %+v", departments)
+	}
+
+	bob, _ := model.GetMemberBindingByBindValue(1, "bob", model.MemberBindingSourceDingTalk)
+	if bob == nil || bob.MID != 0 || bob.Status != model.MemberBindingStatusInactive {
+		t.Fatalf("unexpected binding: %+v", bob)
+	}
+	dids, _ := model.GetMemberDidsByBID(1, bob.ID)
+	if len(dids) != 2 {
+		t.Fatalf("bob should belong to 2 departments: %v", dids)
+	}
+
+	// 离职成员不再出现在通讯录中
+	f.mu.Lock()
+	delete(f.users, "bob")
+	f.mu.Unlock()
+	if result, err = orgsync.Sync(1, client); err != nil {
+		t.Fatalf("second sync: %v", err)
+	}
+	if result.Created != 0 || result.Updated != 1 || result.Disabled != 1 {
+		t.Fatalf("unexpected second result: %+v", *result)
+	}
+	bob, _ = model.GetMemberBindingByBindValue(1, "bob", model.MemberBindingSourceDingTalk)
+	if bob.Status != model.MemberBindingStatusDisabled {
+		t.Fatalf("resigned member should be disabled: %+v", bob)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	newFakeDingTalk(t)
+	setupDB(t)
+	client, err := Load(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	login := func(code string) (*model.User, error) {
+		info, err := orgsync.Authorize(1, client, "https://hub.example.com/callback")
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, _ := url.Parse(info.AuthorizeURL)
+		if u.Query().Get("client_id") != "key" || u.Query().Get("state") != info.State {
+			t.Fatalf("unexpected authorize url: %s", info.AuthorizeURL)
+		}
+		return orgsync.Login(1, client, code, info.State)
+	}
+
+	user, err := login("union-alice")
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if user.Username != "13800138000" || user.Nickname != "Alice" || user.Type != model.UserTypeInternal {
+		t.Fatalf("unexpected user: %+v", user)
+	}
+	binding, _ := model.GetMemberBindingByBindValue(1, "alice", model.MemberBindingSourceDingTalk)
+	if binding == nil || binding.MID != user.UserID || binding.Status != model.MemberBindingStatusActive {
+		t.Fatalf("binding not linked: %+v", binding)
+	}
+
+	again, err := login("union-alice")
+	if err != nil || again.UserID != user.UserID {
+		t.Fatalf("second login should resolve the same user: %v %v", again, err)
+	}
+
+	if _, err := login("union-carol"); err != orgsync.ErrUserNotProvisioned {
+		t.Fatalf("expected ErrUserNotProvisioned, got %v", err)
+	}
+	if _, err := orgsync.Login(1, client, "union-alice", "forged"); err != orgsync.ErrInvalidState {
+		t.Fatalf("expected ErrInvalidState, got %v", err)
+	}
+}
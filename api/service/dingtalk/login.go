@@ -0,0 +1,75 @@
+package dingtalk
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/53AI/53AIHub/service/orgsync"
+)
+
+func (c *Client) ClientID() string {
+	return c.cfg.AppKey
+}
+
+func (c *Client) AutoCreateUser() bool {
+	return c.cfg.AutoCreateUser
+}
+
+// AuthorizeURL 钉钉统一登录页，PC 浏览器展示二维码，钉钉客户端内直接授权
+func (c *Client) AuthorizeURL(redirectURI string, state string) string {
+	query := url.Values{
+		"redirect_uri":  {redirectURI},
+		"response_type": {"code"},
+		"client_id":     {c.cfg.AppKey},
+		"scope":         {"openid"},
+		"state":         {state},
+		"prompt":        {"consent"},
+	}
+	return loginBase + "/oauth2/auth?" + query.Encode()
+}
+
+// UserByCode 授权码换取用户 token 获取 unionid，再通过通讯录接口查询企业内的成员
+func (c *Client) UserByCode(code string, redirectURI string) (*orgsync.Member, error) {
+	var token struct {
+		AccessToken string `json:"accessToken"`
+	}
+	body := map[string]string{
+		"clientId":     c.cfg.AppKey,
+		"clientSecret": c.cfg.AppSecret,
+		"code":         code,
+		"grantType":    "authorization_code",
+	}
+	if err := doJSON(http.MethodPost, apiBase+"/v1.0/oauth2/userAccessToken", body, nil, &token); err != nil {
+		return nil, err
+	}
+
+	var me struct {
+		UnionID string `json:"unionId"`
+	}
+	header := map[string]string{"x-acs-dingtalk-access-token": token.AccessToken}
+	if err := doJSON(http.MethodGet, apiBase+"/v1.0/contact/users/me", nil, header, &me); err != nil {
+		return nil, err
+	}
+
+	var byUnion struct {
+		oapiError
+		Result struct {
+			UserID string `json:"userid"`
+		} `json:"result"`
+	}
+	if err := c.oapi("/topapi/user/getbyunionid", map[string]string{"unionid": me.UnionID}, &byUnion); err != nil {
+		if byUnion.Errcode == errcodeUserNotFound {
+			return nil, orgsync.ErrUserNotProvisioned
+		}
+		return nil, err
+	}
+
+	var detail struct {
+		oapiError
+		Result user `json:"result"`
+	}
+	if err := c.oapi("/topapi/v2/user/get", map[string]string{"userid": byUnion.Result.UserID}, &detail); err != nil {
+		return nil, err
+	}
+	return detail.Result.member(), nil
+}
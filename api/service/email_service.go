@@ -0,0 +1,47 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/53AI/53AIHub/common"
+	"github.com/jordan-wright/email"
+)
+
+// SendVerificationCodeEmail 使用企业 SMTP 配置发送验证码邮件
+func SendVerificationCodeEmail(eid int64, to string, code string, minutes int) error {
+	e := email.NewEmail()
+	e.To = []string{to}
+	e.Subject = "邮箱验证码"
+	e.Text = []byte(fmt.Sprintf("您的验证码是：%s，有效期%d分钟", code, minutes))
+
+	auth, from, host, port, isSsl, err := GetSmtpConfig(eid)
+	if err != nil {
+		return fmt.Errorf("failed to get SMTP auth: %w", err)
+	}
+	if from == "" {
+		return errors.New("SMTP from address is empty")
+	}
+	e.From = from
+
+	return common.SendEmail(e, auth, isSsl, host, port)
+}
+
+// SendTextEmail 使用企业 SMTP 配置发送纯文本邮件
+func SendTextEmail(eid int64, to []string, subject string, text string) error {
+	e := email.NewEmail()
+	e.To = to
+	e.Subject = subject
+	e.Text = []byte(text)
+
+	auth, from, host, port, isSsl, err := GetSmtpConfig(eid)
+	if err != nil {
+		return fmt.Errorf("failed to get SMTP auth: %w", err)
+	}
+	if from == "" {
+		return errors.New("SMTP from address is empty")
+	}
+	e.From = from
+
+	return common.SendEmail(e, auth, isSsl, host, port)
+}
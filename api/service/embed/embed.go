@@ -0,0 +1,190 @@
+package embed
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/53AI/53AIHub/common"
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/model"
+)
+
+var (
+	ErrInvalidDomain = errors.New("invalid embed domain")
+	ErrInvalidLimit  = errors.New("invalid embed limit")
+	ErrInvalidAgent  = errors.New("agent cannot be embedded")
+	ErrRateLimited   = errors.New("too many requests, please try again later")
+	ErrDailyLimit    = errors.New("daily question limit reached")
+	ErrTokenLimit    = errors.New("token budget exhausted")
+)
+
+const (
+	// MaxDomains 单个嵌入配置最多允许的域名数
+	MaxDomains = 50
+	// MaxRateLimit、MaxDailyLimit 可配置的上限
+	MaxRateLimit         = 600
+	MaxDailyLimit        = 100000
+	MaxSessionTokenLimit = 10000000
+	MaxDailyTokenLimit   = 100000000
+
+	TokenPrefix        = "emb-"
+	SessionTokenPrefix = "ems-"
+)
+
+// hostPattern 域名或 IP，可带 *. 前缀匹配子域名，可带端口
+var hostPattern = regexp.MustCompile(`^(\*\.)?[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*(:[0-9]{1,5})?$`)
+
+// NewToken 生成随机令牌
+func NewToken(prefix string) string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return prefix + hex.EncodeToString(b)
+}
+
+// NormalizeDomains 整理允许嵌入的域名：去掉协议与路径、转小写并去重，
+// 支持 example.com、*.example.com（仅子域名）与 localhost:3000（限定端口）
+func NormalizeDomains(domains []string) ([]string, error) {
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("%w: at least one domain is required", ErrInvalidDomain)
+	}
+	if len(domains) > MaxDomains {
+		return nil, fmt.Errorf("%w: at most %d domains", ErrInvalidDomain, MaxDomains)
+	}
+	seen := make(map[string]bool, len(domains))
+	result := make([]string, 0, len(domains))
+	for _, d := range domains {
+		host := strings.ToLower(strings.TrimSpace(d))
+		if strings.Contains(host, "://") {
+			u, err := url.Parse(host)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidDomain, d)
+			}
+			host = u.Host
+		}
+		host = strings.TrimSuffix(host, "/")
+		if !hostPattern.MatchString(host) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidDomain, d)
+		}
+		if !seen[host] {
+			seen[host] = true
+			result = append(result, host)
+		}
+	}
+	return result, nil
+}
+
+// RequestOrigin 取请求来源，浏览器未带 Origin 时使用 Referer
+func RequestOrigin(origin string, referer string) string {
+	if origin != "" && origin != "null" {
+		return origin
+	}
+	if u, err := url.Parse(referer); err == nil && u.Scheme != "" && u.Host != "" {
+		return u.Scheme + "://" + u.Host
+	}
+	return ""
+}
+
+// OriginAllowed 判断来源是否在允许的域名中
+func OriginAllowed(domains []string, origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	hostWithPort := strings.ToLower(u.Host)
+	for _, pattern := range domains {
+		target := host
+		if _, _, err := net.SplitHostPort(strings.TrimPrefix(pattern, "*.")); err == nil {
+			target = hostWithPort
+		}
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if strings.HasSuffix(target, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if target == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateAgent 只有对话类智能体可以嵌入
+func ValidateAgent(agent *model.Agent) error {
+	if agent.AgentType == model.AgentTypeWorkflow {
+		return fmt.Errorf("%w: workflows are not supported", ErrInvalidAgent)
+	}
+	return nil
+}
+
+// ValidateLimits 校验限流配置，0 表示使用默认值
+func ValidateLimits(embed *model.AgentEmbed) error {
+	if embed.RateLimit < 0 || embed.RateLimit > MaxRateLimit {
+		return fmt.Errorf("%w: rate_limit must be between 0 and %d", ErrInvalidLimit, MaxRateLimit)
+	}
+	if embed.DailyLimit < 0 || embed.DailyLimit > MaxDailyLimit {
+		return fmt.Errorf("%w: daily_limit must be between 0 and %d", ErrInvalidLimit, MaxDailyLimit)
+	}
+	if embed.SessionTokenLimit < 0 || embed.SessionTokenLimit > MaxSessionTokenLimit {
+		return fmt.Errorf("%w: session_token_limit must be between 0 and %d", ErrInvalidLimit, MaxSessionTokenLimit)
+	}
+	if embed.DailyTokenLimit < 0 || embed.DailyTokenLimit > MaxDailyTokenLimit {
+		return fmt.Errorf("%w: daily_token_limit must be between 0 and %d", ErrInvalidLimit, MaxDailyTokenLimit)
+	}
+	return nil
+}
+
+// CheckRate 按 IP 限制每分钟的请求次数
+func CheckRate(embed *model.AgentEmbed, ip string) error {
+	return check(fmt.Sprintf("embed:rate:%d:%s", embed.ID, ip), time.Minute, embed.EffectiveRateLimit(), ErrRateLimited)
+}
+
+// CheckDaily 按 IP 限制每天的提问次数，以服务器时区的自然日计算
+func CheckDaily(embed *model.AgentEmbed, ip string, now time.Time) error {
+	key := fmt.Sprintf("embed:daily:%d:%s:%s", embed.ID, ip, now.Format("20060102"))
+	return check(key, 24*time.Hour, embed.EffectiveDailyLimit(), ErrDailyLimit)
+}
+
+// CheckTokens 限制访客会话与每个 IP 每天消耗的 token 数，用量取自已保存的消息，多实例部署时同样生效；
+// 单次回答的用量在回答结束后才计入，因此最后一次提问可能略超出预算
+func CheckTokens(embed *model.AgentEmbed, guestSession *model.EmbedSession, ip string, now time.Time) error {
+	used, err := model.GetEmbedSessionTokens(guestSession)
+	if err != nil {
+		return err
+	}
+	if used >= int64(embed.EffectiveSessionTokenLimit()) {
+		return fmt.Errorf("%w: session used %d tokens", ErrTokenLimit, used)
+	}
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	used, err = model.GetEmbedIPTokens(embed, ip, day.UnixMilli())
+	if err != nil {
+		return err
+	}
+	if used >= int64(embed.EffectiveDailyTokenLimit()) {
+		return fmt.Errorf("%w: %s used %d tokens today", ErrTokenLimit, ip, used)
+	}
+	return nil
+}
+
+func check(key string, window time.Duration, limit int, limitErr error) error {
+	count, err := common.COUNTER.Incr(key, window)
+	if err != nil {
+		// 计数失败时不拦截访客
+		logger.SysErrorf("embed counter %s failed: %v", key, err)
+		return nil
+	}
+	if count > int64(limit) {
+		return limitErr
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package embed
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/53AI/53AIHub/common"
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+)
+
+func TestNormalizeDomains(t *testing.T) {
+	got, err := NormalizeDomains([]string{" WWW.Example.com ", "https://shop.example.com/", "*.example.org", "localhost:3000", "www.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"www.example.com", "shop.example.com", "*.example.org", "localhost:3000"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NormalizeDomains = %v, want %v", got, want)
+	}
+
+	tooMany := make([]string, MaxDomains+1)
+	for i := range tooMany {
+		tooMany[i] = "example.com"
+	}
+	for _, domains := range [][]string{nil, {""}, {"*"}, {"exa mple.com"}, {"example.com/path"}, {"*.*.example.com"}, {"-example.com"}, tooMany} {
+		if _, err := NormalizeDomains(domains); !errors.Is(err, ErrInvalidDomain) {
+			t.Errorf("NormalizeDomains(%v) err = %v", domains, err)
+		}
+	}
+}
+
+func TestRequestOrigin(t *testing.T) {
+	cases := []struct {
+		origin, referer, want string
+	}{
+		{"https://www.example.com", "https://other.com/page", "https://www.example.com"},
+		{"", "https://www.example.com:8443/path?q=1", "https://www.example.com:8443"},
+		{"null", "https://www.example.com/", "https://www.example.com"},
+		{"", "not a url", ""},
+		{"", "", ""},
+	}
+	for _, c := range cases {
+		if got := RequestOrigin(c.origin, c.referer); got != c.want {
+			t.Errorf("RequestOrigin(%q, %q) = %q, want %q", c.origin, c.referer, got, c.want)
+		}
+	}
+}
+
+func TestOriginAllowed(t *testing.T) {
+	domains := []string{"www.example.com", "*.example.org", "localhost:3000"}
+	cases := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://www.example.com", true},
+		{"http://www.example.com:8080", true},
+		{"https://WWW.EXAMPLE.COM", true},
+		{"https://example.com", false},
+		{"https://evil-www.example.com", false},
+		{"https://www.example.com.evil.com", false},
+		{"https://a.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"https://badexample.org", false},
+		{"http://localhost:3000", true},
+		{"http://localhost:4000", false},
+		{"http://localhost", false},
+		{"file://www.example.com", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		if got := OriginAllowed(domains, c.origin); got != c.want {
+			t.Errorf("OriginAllowed(%q) = %v, want %v", c.origin, got, c.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := ValidateAgent(&model.Agent{AgentType: model.AgentTypeWorkflow}); !errors.Is(err, ErrInvalidAgent) {
+		t.Errorf("ValidateAgent(workflow) = %v", err)
+	}
+	if err := ValidateLimits(&model.AgentEmbed{RateLimit: 0, DailyLimit: MaxDailyLimit}); err != nil {
+		t.Errorf("ValidateLimits = %v", err)
+	}
+	for _, e := range []*model.AgentEmbed{{RateLimit: -1}, {RateLimit: MaxRateLimit + 1}, {DailyLimit: -1}, {DailyLimit: MaxDailyLimit + 1},
+		{SessionTokenLimit: -1}, {SessionTokenLimit: MaxSessionTokenLimit + 1}, {DailyTokenLimit: -1}, {DailyTokenLimit: MaxDailyTokenLimit + 1}} {
+		if err := ValidateLimits(e); !errors.Is(err, ErrInvalidLimit) {
+			t.Errorf("ValidateLimits(%+v) = %v", e, err)
+		}
+	}
+}
+
+func TestNewToken(t *testing.T) {
+	a, b := NewToken(TokenPrefix), NewToken(TokenPrefix)
+	if a == b || !strings.HasPrefix(a, TokenPrefix) || len(a) != len(TokenPrefix)+48 {
+		t.Errorf("NewToken = %q, %q", a, b)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	common.COUNTER = common.NewLocalCounter()
+	embed := &model.AgentEmbed{ID: 1, RateLimit: 2, DailyLimit: 3}
+
+	for i := 0; i < 2; i++ {
+		if err := CheckRate(embed, "1.1.1.1"); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if err := CheckRate(embed, "1.1.1.1"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("third request err = %v", err)
+	}
+	if err := CheckRate(embed, "2.2.2.2"); err != nil {
+		t.Errorf("other ip err = %v", err)
+	}
+	if err := CheckRate(&model.AgentEmbed{ID: 2, RateLimit: 2}, "1.1.1.1"); err != nil {
+		t.Errorf("other embed err = %v", err)
+	}
+
+	day := time.Date(2026, 10, 19, 10, 0, 0, 0, time.Local)
+	for i := 0; i < 3; i++ {
+		if err := CheckDaily(embed, "1.1.1.1", day); err != nil {
+			t.Fatalf("question %d: %v", i, err)
+		}
+	}
+	if err := CheckDaily(embed, "1.1.1.1", day); !errors.Is(err, ErrDailyLimit) {
+		t.Errorf("fourth question err = %v", err)
+	}
+	if err := CheckDaily(embed, "1.1.1.1", day.AddDate(0, 0, 1)); err != nil {
+		t.Errorf("next day err = %v", err)
+	}
+}
+
+func TestTokenBudget(t *testing.T) {
+	db := testutil.SetupDB(t, &model.EmbedSession{}, &model.Message{})
+	embed := &model.AgentEmbed{ID: 1, Eid: 1, SessionTokenLimit: 1000, DailyTokenLimit: 1500}
+	now := time.Now()
+	newSession := func(ip string, conversationID int64) *model.EmbedSession {
+		s := &model.EmbedSession{Eid: 1, EmbedID: embed.ID, Token: NewToken(SessionTokenPrefix), IP: ip,
+			ConversationID: conversationID, ExpiredTime: now.Add(time.Hour).UnixMilli()}
+		if err := model.CreateEmbedSession(s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	answer := func(s *model.EmbedSession, tokens int, at time.Time) {
+		message := &model.Message{Eid: 1, ConversationID: s.ConversationID, TotalTokens: tokens}
+		message.CreatedTime = at.UnixMilli()
+		if err := db.Create(message).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first := newSession("1.1.1.1", 11)
+	if err := CheckTokens(embed, newSession("1.1.1.1", 0), "1.1.1.1", now); err != nil {
+		t.Fatalf("new session: %v", err)
+	}
+	answer(first, 600, now)
+	if err := CheckTokens(embed, first, "1.1.1.1", now); err != nil {
+		t.Fatalf("within budget: %v", err)
+	}
+	// 会话用完预算后不能继续提问
+	answer(first, 400, now)
+	if err := CheckTokens(embed, first, "1.1.1.1", now); !errors.Is(err, ErrTokenLimit) {
+		t.Errorf("session budget err = %v", err)
+	}
+
+	// 同一 IP 换新会话仍受当天预算限制，昨天的用量和其它 IP 不计入
+	second := newSession("1.1.1.1", 12)
+	answer(second, 500, now)
+	answer(newSession("1.1.1.1", 13), 5000, now.AddDate(0, 0, -1))
+	if err := CheckTokens(embed, second, "1.1.1.1", now); !errors.Is(err, ErrTokenLimit) {
+		t.Errorf("ip budget err = %v", err)
+	}
+	other := newSession("2.2.2.2", 14)
+	answer(other, 100, now)
+	if err := CheckTokens(embed, other, "2.2.2.2", now); err != nil {
+		t.Errorf("other ip err = %v", err)
+	}
+	if err := CheckTokens(embed, second, "1.1.1.1", now.AddDate(0, 0, 1)); err != nil {
+		t.Errorf("next day err = %v", err)
+	}
+
+	// 未配置时使用默认预算
+	if err := CheckTokens(&model.AgentEmbed{ID: 1, Eid: 1}, first, "1.1.1.1", now); err != nil {
+		t.Errorf("default budget err = %v", err)
+	}
+}
+
+func TestEmbedStorage(t *testing.T) {
+	db := testutil.SetupDB(t, &model.User{}, &model.AgentEmbed{}, &model.EmbedSession{})
+
+	domains, _ := NormalizeDomains([]string{"https://www.example.com"})
+	embed := &model.AgentEmbed{Eid: 1, AgentID: 7, Name: "官网", Token: NewToken(TokenPrefix), Domains: domains, Enabled: true}
+	guest := &model.User{Eid: 1, Username: "guest_test", Nickname: "访客", Role: model.RoleGuestUser, Type: model.UserTypeGuest, Status: model.UserStatusJoined}
+	if err := model.CreateAgentEmbed(embed, guest); err != nil {
+		t.Fatal(err)
+	}
+	if embed.GuestUserID == 0 || embed.GuestUserID != guest.UserID {
+		t.Fatalf("GuestUserID = %d, guest = %d", embed.GuestUserID, guest.UserID)
+	}
+	found, err := model.GetAgentEmbedByToken(embed.Token)
+	if err != nil || !reflect.DeepEqual(found.Domains, domains) {
+		t.Fatalf("GetAgentEmbedByToken = %+v, %v", found, err)
+	}
+	if _, err := model.GetAgentEmbedByToken("emb-missing"); !errors.Is(err, model.ErrAgentEmbedNotFound) {
+		t.Errorf("missing token err = %v", err)
+	}
+
+	active := &model.EmbedSession{Eid: 1, EmbedID: embed.ID, Token: NewToken(SessionTokenPrefix), ExpiredTime: time.Now().Add(time.Hour).UnixMilli()}
+	expired := &model.EmbedSession{Eid: 1, EmbedID: embed.ID, Token: NewToken(SessionTokenPrefix), ExpiredTime: time.Now().Add(-time.Hour).UnixMilli()}
+	for _, s := range []*model.EmbedSession{active, expired} {
+		if err := model.CreateEmbedSession(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := model.GetEmbedSession(embed.ID, active.Token); err != nil {
+		t.Errorf("active session err = %v", err)
+	}
+	if _, err := model.GetEmbedSession(embed.ID, expired.Token); !errors.Is(err, model.ErrEmbedSessionExpired) {
+		t.Errorf("expired session err = %v", err)
+	}
+	if _, err := model.GetEmbedSession(embed.ID+1, active.Token); err == nil {
+		t.Error("session of another embed should not be found")
+	}
+
+	if err := model.DeleteAgentEmbeds(db, embed.AgentID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := model.GetEmbedSession(embed.ID, active.Token); err == nil {
+		t.Error("session should be deleted with its embed")
+	}
+	var user model.User
+	if err := db.First(&user, guest.UserID).Error; err != nil || user.Status != model.UserStatusDisabled {
+		t.Errorf("guest user = %+v, %v", user, err)
+	}
+}
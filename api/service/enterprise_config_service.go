@@ -201,21 +201,11 @@ func ToggleEnterpriseConfig(eid int64, configType string) (bool, error) {
 		return false, err
 	}
 
-	// 解析当前配置内容
-	var content ConfigContent
-	_ = json.Unmarshal([]byte(config.Content), &content)
-
 	// 切换状态
 	config.Enabled = !config.Enabled
 
-	// 更新配置内容
-	updatedContent, err := json.Marshal(content)
-	if err != nil {
-		return false, err
-	}
-
-	// 保存更新后的配置
-	_, err = SaveEnterpriseConfig(eid, configType, string(updatedContent), config.Enabled)
+	// 保存更新后的配置，保留原有配置内容（如 oidc 的身份提供方列表）
+	_, err = SaveEnterpriseConfig(eid, configType, config.Content, config.Enabled)
 	if err != nil {
 		return false, err
 	}
@@ -0,0 +1,287 @@
+package evaluation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/agentversion"
+	"github.com/53AI/53AIHub/service/knowledge"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+var (
+	ErrEmptyDataset = errors.New("eval dataset has no cases")
+	// ErrInvalidCase 用例缺少问题，或没有任何评分依据（参考答案、正则、评分标准）
+	ErrInvalidCase  = errors.New("eval case requires a question and an expected answer, pattern or rubric")
+	ErrJudgeChannel = errors.New("judge channel not found")
+)
+
+// Answer 被测智能体对用例的回答
+type Answer struct {
+	Content     string
+	ElapsedTime int64
+	TotalTokens int
+}
+
+// AskFunc 以 userID 的身份通过正常的对话链路向智能体提问
+type AskFunc func(ctx context.Context, agent *model.Agent, userID int64, question string) (*Answer, error)
+
+// JudgeFunc 使用评审渠道完成一次单轮对话，返回回复内容
+type JudgeFunc func(ctx context.Context, channel *model.Channel, modelName string, prompt string) (string, error)
+
+// EmbedFunc 计算文本向量，与 knowledge.Embed 签名一致
+type EmbedFunc func(ctx context.Context, eid int64, modelName string, texts []string) ([][]float32, int, error)
+
+// Runner 执行评测运行，提问与评审依赖的对话链路由调用方注入
+type Runner struct {
+	Ask   AskFunc
+	Judge JudgeFunc
+	Embed EmbedFunc
+}
+
+func NewRunner(ask AskFunc, judge JudgeFunc) *Runner {
+	return &Runner{Ask: ask, Judge: judge, Embed: knowledge.Embed}
+}
+
+// ValidateCases 校验用例的问题、评分依据和正则表达式
+func ValidateCases(cases []*model.EvalCase) error {
+	for i, c := range cases {
+		c.Question = strings.TrimSpace(c.Question)
+		if c.Question == "" || (c.ExpectedAnswer == "" && c.Pattern == "" && c.Rubric == "") {
+			return fmt.Errorf("case %d: %w", i+1, ErrInvalidCase)
+		}
+		if c.Pattern != "" {
+			if _, err := regexp.Compile(c.Pattern); err != nil {
+				return fmt.Errorf("case %d: invalid pattern: %w", i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// TargetAgent 评测使用的智能体配置：指定版本叠加运行配置中的模型、提示词覆盖项
+func TargetAgent(agent *model.Agent, cfg model.EvalConfig) (*model.Agent, error) {
+	snapshot, err := agentversion.ResolveSnapshot(agent, cfg.Target)
+	if err != nil {
+		return nil, err
+	}
+	target := *agent
+	target.ApplySnapshot(snapshot)
+	switch cfg.Target {
+	case "", agentversion.RefCurrent:
+	case agentversion.RefDraft:
+		target.Version = model.AgentVersionDraft
+	default:
+		// ResolveSnapshot 已校验版本号
+		target.Version, _ = strconv.Atoi(cfg.Target)
+	}
+	if cfg.ChannelType != 0 {
+		target.ChannelType = cfg.ChannelType
+	}
+	if cfg.Model != "" {
+		target.Model = cfg.Model
+	}
+	if cfg.Prompt != "" {
+		target.Prompt = cfg.Prompt
+	}
+	return &target, nil
+}
+
+// CreateRun 校验配置并创建待执行的评测运行，返回的智能体为被测配置
+func CreateRun(agent *model.Agent, dataset *model.EvalDataset, cfg model.EvalConfig, userID int64) (*model.EvalRun, *model.Agent, error) {
+	if len(dataset.Cases) == 0 {
+		return nil, nil, ErrEmptyDataset
+	}
+	if cfg.JudgeChannelID > 0 {
+		channel, err := model.GetChannelByID(cfg.JudgeChannelID)
+		if err != nil || channel.Eid != agent.Eid {
+			return nil, nil, ErrJudgeChannel
+		}
+	}
+	target, err := TargetAgent(agent, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	run := &model.EvalRun{
+		Eid:          agent.Eid,
+		AgentID:      agent.AgentID,
+		DatasetID:    dataset.ID,
+		EvalConfig:   cfg,
+		AgentVersion: target.Version,
+		Status:       model.EvalRunStatusPending,
+		CaseCount:    len(dataset.Cases),
+		CreatedBy:    userID,
+	}
+	if err := model.CreateEvalRun(run); err != nil {
+		return nil, nil, err
+	}
+	return run, target, nil
+}
+
+// RunAsync 异步执行评测运行
+func (r *Runner) RunAsync(run *model.EvalRun, target *model.Agent, cases []*model.EvalCase) {
+	go func() {
+		if err := r.Run(context.Background(), run, target, cases); err != nil {
+			logger.SysErrorf("eval run failed, run_id=%d: %v", run.ID, err)
+		}
+	}()
+}
+
+// Run 逐条执行用例并评分，与评测集上一次完成的运行对比，执行结果写回运行状态
+func (r *Runner) Run(ctx context.Context, run *model.EvalRun, target *model.Agent, cases []*model.EvalCase) error {
+	run.Status = model.EvalRunStatusRunning
+	if err := model.UpdateEvalRun(run); err != nil {
+		return err
+	}
+
+	err := r.run(ctx, run, target, cases)
+	if err != nil {
+		run.Status = model.EvalRunStatusFailed
+		run.ErrorMessage = err.Error()
+	} else {
+		run.Status = model.EvalRunStatusCompleted
+	}
+	run.FinishedTime = time.Now().UTC().UnixMilli()
+	if updateErr := model.UpdateEvalRun(run); updateErr != nil {
+		return updateErr
+	}
+	return err
+}
+
+func (r *Runner) run(ctx context.Context, run *model.EvalRun, target *model.Agent, cases []*model.EvalCase) error {
+	var judge *model.Channel
+	if run.EvalConfig.JudgeChannelID > 0 {
+		channel, err := model.GetChannelByID(run.EvalConfig.JudgeChannelID)
+		if err != nil {
+			return ErrJudgeChannel
+		}
+		judge = channel
+	}
+
+	previous := make(map[int64]*model.EvalResult)
+	prevRun, err := model.GetLatestCompletedEvalRun(run.DatasetID, run.ID)
+	if err != nil {
+		return err
+	}
+	if prevRun != nil {
+		run.PreviousRunID = prevRun.ID
+		results, err := model.GetEvalResults(prevRun.ID)
+		if err != nil {
+			return err
+		}
+		for _, result := range results {
+			previous[result.CaseID] = result
+		}
+	}
+
+	var totalScore float64
+	for _, c := range cases {
+		result := r.evaluate(ctx, run, target, judge, c)
+		compare(result, previous[c.ID], run.ID)
+		if err := model.CreateEvalResult(result); err != nil {
+			return err
+		}
+		totalScore += result.Score
+		if result.Passed {
+			run.PassedCount++
+		}
+		switch result.Change {
+		case model.EvalChangeImproved:
+			run.Improved++
+		case model.EvalChangeRegressed:
+			run.Regressed++
+		}
+	}
+	run.CaseCount = len(cases)
+	if len(cases) > 0 {
+		run.AvgScore = totalScore / float64(len(cases))
+	}
+	return nil
+}
+
+// evaluate 执行单条用例；提问失败记为不通过，不中断整个运行
+func (r *Runner) evaluate(ctx context.Context, run *model.EvalRun, target *model.Agent, judge *model.Channel, c *model.EvalCase) *model.EvalResult {
+	result := &model.EvalResult{
+		RunID:          run.ID,
+		CaseID:         c.ID,
+		Question:       c.Question,
+		ExpectedAnswer: c.ExpectedAnswer,
+		ScoreDetails:   make([]model.EvalScore, 0),
+	}
+	answer, err := r.Ask(ctx, target, run.CreatedBy, c.Question)
+	if err != nil {
+		result.ErrorMessage = err.Error()
+		return result
+	}
+	result.Answer = answer.Content
+	result.ElapsedTime = answer.ElapsedTime
+	result.TotalTokens = answer.TotalTokens
+
+	cfg := run.EvalConfig
+	if c.ExpectedAnswer != "" {
+		result.ScoreDetails = append(result.ScoreDetails, scoreExact(c.ExpectedAnswer, answer.Content))
+		if cfg.EmbeddingModel != "" {
+			result.ScoreDetails = append(result.ScoreDetails, r.scoreEmbedding(ctx, run.Eid, cfg, c.ExpectedAnswer, answer.Content))
+		}
+	}
+	if c.Pattern != "" {
+		result.ScoreDetails = append(result.ScoreDetails, scoreRegex(c.Pattern, answer.Content))
+	}
+	if judge != nil {
+		result.ScoreDetails = append(result.ScoreDetails, r.scoreJudge(ctx, judge, cfg, c, answer.Content))
+	}
+	if len(result.ScoreDetails) == 0 {
+		result.ErrorMessage = "no applicable scorer"
+		return result
+	}
+
+	// 综合得分取各评分方式的最高分：精确匹配过严，语义相似或评审通过即可认为回答正确
+	for _, score := range result.ScoreDetails {
+		if score.Score > result.Score {
+			result.Score = score.Score
+		}
+		if score.Passed {
+			result.Passed = true
+		}
+	}
+	// 正则是硬性要求，未匹配时不通过
+	for _, score := range result.ScoreDetails {
+		if score.Scorer == ScorerRegex && !score.Passed {
+			result.Passed = false
+		}
+	}
+	return result
+}
+
+// compare 记录用例相对上一次运行的变化和回答差异
+func compare(result *model.EvalResult, previous *model.EvalResult, runID int64) {
+	if previous == nil {
+		result.Change = model.EvalChangeNew
+		return
+	}
+	result.PreviousScore = previous.Score
+	switch {
+	case previous.Passed && !result.Passed:
+		result.Change = model.EvalChangeRegressed
+	case !previous.Passed && result.Passed:
+		result.Change = model.EvalChangeImproved
+	default:
+		result.Change = model.EvalChangeUnchanged
+	}
+	if previous.Answer != result.Answer {
+		result.AnswerDiff, _ = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(previous.Answer),
+			B:        difflib.SplitLines(result.Answer),
+			FromFile: fmt.Sprintf("run-%d", previous.RunID),
+			ToFile:   fmt.Sprintf("run-%d", runID),
+			Context:  2,
+		})
+	}
+}
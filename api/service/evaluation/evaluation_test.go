@@ -0,0 +1,148 @@
+package evaluation
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+)
+
+func setupDataset(t *testing.T) (*model.Agent, *model.EvalDataset) {
+	db := testutil.SetupDB(t, &model.Agent{}, &model.AgentVersion{}, &model.Channel{},
+		&model.EvalDataset{}, &model.EvalCase{}, &model.EvalRun{}, &model.EvalResult{})
+	agent := &model.Agent{Eid: 1, Name: "assistant", Model: "gpt-4o", Prompt: "live prompt",
+		Configs: "{}", Tools: "[]", CustomConfig: "{}", Settings: "{}", UseCases: "[]"}
+	if err := agent.Create(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&model.Channel{Eid: 1, Name: "judge", Type: 1, Models: "gpt-4o"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []*model.EvalCase{
+		{Question: "capital of France?", ExpectedAnswer: "Paris"},
+		{Question: "order number format?", Pattern: `^ORD-\d+$`},
+		{Question: "be polite", Rubric: "must greet the user"},
+	}
+	if err := ValidateCases(cases); err != nil {
+		t.Fatal(err)
+	}
+	dataset := &model.EvalDataset{Eid: 1, AgentID: agent.AgentID, Name: "smoke", Cases: cases}
+	if err := model.SaveEvalDataset(dataset); err != nil {
+		t.Fatal(err)
+	}
+	return agent, dataset
+}
+
+// fakeRunner 按提示词决定回答，模拟提示词改动带来的回归
+func fakeRunner(asked *[]string) *Runner {
+	return &Runner{
+		Ask: func(ctx context.Context, agent *model.Agent, userID int64, question string) (*Answer, error) {
+			*asked = append(*asked, agent.Model+"|"+agent.Prompt)
+			answers := map[string]string{
+				"capital of France?":   " paris ",
+				"order number format?": "ORD-123",
+				"be polite":            "Hello there!",
+			}
+			if agent.Prompt == "terse" {
+				answers["capital of France?"] = "The capital is Paris,\nthe city of light."
+				answers["order number format?"] = "order 123"
+			}
+			return &Answer{Content: answers[question], ElapsedTime: 5, TotalTokens: 7}, nil
+		},
+		Judge: func(ctx context.Context, channel *model.Channel, modelName string, prompt string) (string, error) {
+			if strings.Contains(prompt, "Hello") {
+				return "```json\n{\"score\": 9, \"reason\": \"greets\"}\n```", nil
+			}
+			return `{"score": 2, "reason": "no greeting"}`, nil
+		},
+		Embed: func(ctx context.Context, eid int64, modelName string, texts []string) ([][]float32, int, error) {
+			if strings.Contains(texts[1], "Paris") {
+				return [][]float32{{1, 0}, {0.9, 0.1}}, 2, nil
+			}
+			return nil, 0, errors.New("embedding failed")
+		},
+	}
+}
+
+func TestValidateCases(t *testing.T) {
+	if err := ValidateCases([]*model.EvalCase{{Question: "q"}}); !errors.Is(err, ErrInvalidCase) {
+		t.Fatalf("missing criteria err = %v", err)
+	}
+	if err := ValidateCases([]*model.EvalCase{{Question: "q", Pattern: "("}}); err == nil {
+		t.Fatal("invalid pattern accepted")
+	}
+}
+
+func TestRunAndCompare(t *testing.T) {
+	agent, dataset := setupDataset(t)
+	var asked []string
+	runner := fakeRunner(&asked)
+	cfg := model.EvalConfig{EmbeddingModel: "text-embedding-3-small", JudgeChannelID: 1}
+
+	run, target, err := CreateRun(agent, dataset, cfg, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := runner.Run(context.Background(), run, target, dataset.Cases); err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != model.EvalRunStatusCompleted || run.PassedCount != 3 || run.PreviousRunID != 0 {
+		t.Fatalf("unexpected first run: %+v", run)
+	}
+	if asked[0] != "gpt-4o|live prompt" {
+		t.Fatalf("ran against wrong config: %v", asked)
+	}
+
+	// 覆盖提示词后再跑一次，与上一次运行对比
+	cfg.Prompt = "terse"
+	run2, target, err := CreateRun(agent, dataset, cfg, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := runner.Run(context.Background(), run2, target, dataset.Cases); err != nil {
+		t.Fatal(err)
+	}
+	if run2.PreviousRunID != run.ID || run2.Regressed != 1 || run2.PassedCount != 2 {
+		t.Fatalf("unexpected second run: %+v", run2)
+	}
+
+	loaded, err := model.GetEvalRun(1, agent.AgentID, run2.ID)
+	if err != nil || loaded.EvalConfig.Prompt != "terse" {
+		t.Fatalf("config not persisted: %+v, %v", loaded, err)
+	}
+	results, err := model.GetEvalResults(run2.ID)
+	if err != nil || len(results) != 3 {
+		t.Fatalf("GetEvalResults = %d, %v", len(results), err)
+	}
+	byQuestion := make(map[string]*model.EvalResult)
+	for _, r := range results {
+		byQuestion[r.Question] = r
+	}
+	// 精确匹配和评审未通过，但语义相似，仍然通过
+	capital := byQuestion["capital of France?"]
+	if !capital.Passed || capital.Change != model.EvalChangeUnchanged || len(capital.ScoreDetails) != 3 ||
+		!strings.Contains(capital.AnswerDiff, "+the city of light.") {
+		t.Fatalf("unexpected capital result: %+v", capital)
+	}
+	order := byQuestion["order number format?"]
+	if order.Passed || order.Change != model.EvalChangeRegressed || order.PreviousScore != 1 {
+		t.Fatalf("unexpected order result: %+v", order)
+	}
+	if polite := byQuestion["be polite"]; polite.ScoreDetails[0].Reason != "greets" || polite.Score != 0.9 {
+		t.Fatalf("unexpected judge result: %+v", polite.ScoreDetails)
+	}
+
+	// 修改评测集时保留已有用例的 ID，移除的用例被删除
+	dataset.Cases = append(dataset.Cases[1:], &model.EvalCase{Question: "new", ExpectedAnswer: "x"})
+	if err := model.SaveEvalDataset(dataset); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, _ := model.GetEvalDataset(1, agent.AgentID, dataset.ID)
+	if len(reloaded.Cases) != 3 || reloaded.Cases[0].ID != results[1].CaseID {
+		t.Fatalf("case ids not preserved: %+v", reloaded.Cases)
+	}
+}
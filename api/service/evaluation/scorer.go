@@ -0,0 +1,140 @@
+package evaluation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/53AI/53AIHub/model"
+)
+
+// 评分方式
+const (
+	ScorerExact     = "exact"
+	ScorerRegex     = "regex"
+	ScorerEmbedding = "embedding"
+	ScorerJudge     = "judge"
+)
+
+const (
+	defaultSimilarityThreshold = 0.85
+	defaultPassScore           = 0.6
+)
+
+const judgePromptTemplate = `你是一名严格的评测员，请评估 AI 助手对用户问题的回答质量。
+
+【用户问题】
+%s
+
+【参考答案】
+%s
+
+【评分标准】
+%s
+
+【助手回答】
+%s
+
+请给出 0 到 10 的整数评分，并简要说明理由。只输出 JSON，格式为：{"score": 8, "reason": "..."}`
+
+func normalize(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// scoreExact 忽略大小写和空白差异的精确匹配
+func scoreExact(expected, answer string) model.EvalScore {
+	if normalize(expected) == normalize(answer) {
+		return model.EvalScore{Scorer: ScorerExact, Score: 1, Passed: true}
+	}
+	return model.EvalScore{Scorer: ScorerExact}
+}
+
+func scoreRegex(pattern, answer string) model.EvalScore {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return model.EvalScore{Scorer: ScorerRegex, Reason: err.Error()}
+	}
+	if re.MatchString(answer) {
+		return model.EvalScore{Scorer: ScorerRegex, Score: 1, Passed: true}
+	}
+	return model.EvalScore{Scorer: ScorerRegex}
+}
+
+func (r *Runner) scoreEmbedding(ctx context.Context, eid int64, cfg model.EvalConfig, expected, answer string) model.EvalScore {
+	vectors, _, err := r.Embed(ctx, eid, cfg.EmbeddingModel, []string{expected, answer})
+	if err != nil {
+		return model.EvalScore{Scorer: ScorerEmbedding, Reason: err.Error()}
+	}
+	similarity := math.Max(0, cosine(vectors[0], vectors[1]))
+	threshold := cfg.SimilarityThreshold
+	if threshold <= 0 {
+		threshold = defaultSimilarityThreshold
+	}
+	return model.EvalScore{
+		Scorer: ScorerEmbedding,
+		Score:  similarity,
+		Passed: similarity >= threshold,
+		Reason: fmt.Sprintf("相似度 %.3f，阈值 %.2f", similarity, threshold),
+	}
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+type judgeVerdict struct {
+	Score  float64 `json:"score"`
+	Reason string  `json:"reason"`
+}
+
+func (r *Runner) scoreJudge(ctx context.Context, judge *model.Channel, cfg model.EvalConfig, c *model.EvalCase, answer string) model.EvalScore {
+	expected, rubric := c.ExpectedAnswer, c.Rubric
+	if expected == "" {
+		expected = "（无）"
+	}
+	if rubric == "" {
+		rubric = "回答是否正确、完整，并与参考答案的含义一致"
+	}
+	reply, err := r.Judge(ctx, judge, cfg.JudgeModel, fmt.Sprintf(judgePromptTemplate, c.Question, expected, rubric, answer))
+	if err != nil {
+		return model.EvalScore{Scorer: ScorerJudge, Reason: err.Error()}
+	}
+	verdict, err := parseVerdict(reply)
+	if err != nil {
+		return model.EvalScore{Scorer: ScorerJudge, Reason: err.Error()}
+	}
+	score := math.Min(math.Max(verdict.Score/10, 0), 1)
+	passScore := cfg.PassScore
+	if passScore <= 0 {
+		passScore = defaultPassScore
+	}
+	return model.EvalScore{Scorer: ScorerJudge, Score: score, Passed: score >= passScore, Reason: verdict.Reason}
+}
+
+// parseVerdict 从评审回复中取出 JSON，兼容模型在 JSON 前后附加的说明或代码块标记
+func parseVerdict(reply string) (*judgeVerdict, error) {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("judge reply is not json: %s", reply)
+	}
+	var verdict judgeVerdict
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &verdict); err != nil {
+		return nil, fmt.Errorf("invalid judge reply: %w", err)
+	}
+	return &verdict, nil
+}
@@ -0,0 +1,258 @@
+package experiment
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"time"
+
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/agentversion"
+	"github.com/53AI/53AIHub/service/prompttpl"
+)
+
+var (
+	// ErrExperimentRunning 同一智能体已有运行中的实验
+	ErrExperimentRunning = errors.New("agent already has a running experiment")
+	ErrInvalidVariants   = errors.New("experiment requires at least two variants with positive weight")
+	// ErrInvalidStatus 当前状态不允许该操作
+	ErrInvalidStatus   = errors.New("operation not allowed in current experiment status")
+	ErrVariantNotFound = errors.New("variant not found")
+)
+
+// VariantInput 方案配置，以 BaseVersion 指定的版本（默认当前生效配置）为基础，覆盖非空字段
+type VariantInput struct {
+	Name         string `json:"name" binding:"required,max=100" example:"方案 A"`
+	Weight       int    `json:"weight" example:"50"`
+	BaseVersion  string `json:"base_version" example:"current"` // draft/current/版本号
+	ChannelType  int    `json:"channel_type" example:"1"`
+	Model        string `json:"model" example:"gpt-4o"`
+	Prompt       string `json:"prompt"`
+	Configs      string `json:"configs"`
+	Tools        string `json:"tools"`
+	CustomConfig string `json:"custom_config"`
+	Settings     string `json:"settings"`
+}
+
+// Input 创建或修改实验的参数
+type Input struct {
+	Name        string         `json:"name" binding:"required,max=100" example:"GPT-4o 对比"`
+	Description string         `json:"description"`
+	Variants    []VariantInput `json:"variants" binding:"required,dive"`
+}
+
+// VariantResult 方案实验结果
+type VariantResult struct {
+	model.VariantStat
+	Name   string  `json:"name"`
+	Weight int     `json:"weight"`
+	UpRate float64 `json:"up_rate"` // 好评占评价数的比例
+}
+
+// Create 创建草稿状态的实验
+func Create(agent *model.Agent, input Input, userID int64) (*model.AgentExperiment, error) {
+	experiment := &model.AgentExperiment{
+		Eid:       agent.Eid,
+		AgentID:   agent.AgentID,
+		Status:    model.ExperimentStatusDraft,
+		CreatedBy: userID,
+	}
+	if err := apply(experiment, agent, input); err != nil {
+		return nil, err
+	}
+	if err := model.SaveAgentExperiment(experiment); err != nil {
+		return nil, err
+	}
+	return experiment, nil
+}
+
+// Update 修改实验，仅草稿状态可修改
+func Update(experiment *model.AgentExperiment, agent *model.Agent, input Input) error {
+	if experiment.Status != model.ExperimentStatusDraft {
+		return ErrInvalidStatus
+	}
+	if err := apply(experiment, agent, input); err != nil {
+		return err
+	}
+	return model.SaveAgentExperiment(experiment)
+}
+
+func apply(experiment *model.AgentExperiment, agent *model.Agent, input Input) error {
+	if len(input.Variants) < 2 {
+		return ErrInvalidVariants
+	}
+	variants := make([]*model.AgentExperimentVariant, 0, len(input.Variants))
+	for _, v := range input.Variants {
+		if v.Weight <= 0 {
+			return ErrInvalidVariants
+		}
+		if err := prompttpl.Validate(v.Prompt); err != nil {
+			return err
+		}
+		snapshot, err := agentversion.ResolveSnapshot(agent, v.BaseVersion)
+		if err != nil {
+			return err
+		}
+		overlay(&snapshot, v)
+		variants = append(variants, &model.AgentExperimentVariant{
+			Name:          v.Name,
+			Weight:        v.Weight,
+			AgentSnapshot: snapshot,
+		})
+	}
+	experiment.Name = input.Name
+	experiment.Description = input.Description
+	experiment.Variants = variants
+	return nil
+}
+
+func overlay(s *model.AgentSnapshot, v VariantInput) {
+	if v.ChannelType != 0 {
+		s.ChannelType = v.ChannelType
+	}
+	if v.Model != "" {
+		s.Model = v.Model
+	}
+	if v.Prompt != "" {
+		s.Prompt = v.Prompt
+	}
+	if v.Configs != "" {
+		s.Configs = v.Configs
+	}
+	if v.Tools != "" {
+		s.Tools = v.Tools
+	}
+	if v.CustomConfig != "" {
+		s.CustomConfig = v.CustomConfig
+	}
+	if v.Settings != "" {
+		s.Settings = v.Settings
+	}
+}
+
+// Start 开始或继续实验
+func Start(experiment *model.AgentExperiment) error {
+	if experiment.Status != model.ExperimentStatusDraft && experiment.Status != model.ExperimentStatusStopped {
+		return ErrInvalidStatus
+	}
+	running, err := model.GetRunningAgentExperiment(experiment.AgentID)
+	if err != nil {
+		return err
+	}
+	if running != nil && running.ID != experiment.ID {
+		return ErrExperimentRunning
+	}
+	experiment.Status = model.ExperimentStatusRunning
+	if experiment.StartedTime == 0 {
+		experiment.StartedTime = time.Now().UnixMilli()
+	}
+	experiment.StoppedTime = 0
+	return model.UpdateAgentExperimentStatus(experiment)
+}
+
+// Stop 停止分流，之后的对话使用智能体当前生效的配置
+func Stop(experiment *model.AgentExperiment) error {
+	if experiment.Status != model.ExperimentStatusRunning {
+		return ErrInvalidStatus
+	}
+	experiment.Status = model.ExperimentStatusStopped
+	experiment.StoppedTime = time.Now().UnixMilli()
+	return model.UpdateAgentExperimentStatus(experiment)
+}
+
+// Promote 将胜出方案发布为智能体的新版本并结束实验
+func Promote(experiment *model.AgentExperiment, agent *model.Agent, variantID int64, userID int64) (*model.AgentVersion, error) {
+	if experiment.Status == model.ExperimentStatusDraft || experiment.Status == model.ExperimentStatusCompleted {
+		return nil, ErrInvalidStatus
+	}
+	variant := findVariant(experiment, variantID)
+	if variant == nil {
+		return nil, ErrVariantNotFound
+	}
+	published, err := agentversion.PublishSnapshot(agent, variant.AgentSnapshot,
+		fmt.Sprintf("推广实验【%s】方案【%s】", experiment.Name, variant.Name), userID)
+	if err != nil {
+		return nil, err
+	}
+	if experiment.Status == model.ExperimentStatusRunning {
+		experiment.StoppedTime = time.Now().UnixMilli()
+	}
+	experiment.Status = model.ExperimentStatusCompleted
+	experiment.WinnerVariantID = variant.ID
+	if err := model.UpdateAgentExperimentStatus(experiment); err != nil {
+		return nil, err
+	}
+	return published, nil
+}
+
+func findVariant(experiment *model.AgentExperiment, variantID int64) *model.AgentExperimentVariant {
+	for _, v := range experiment.Variants {
+		if v.ID == variantID {
+			return v
+		}
+	}
+	return nil
+}
+
+// AssignVariant 按用户 ID 的哈希和方案权重确定分到的方案，同一用户在同一实验中始终分到同一方案
+func AssignVariant(experiment *model.AgentExperiment, userID int64) *model.AgentExperimentVariant {
+	total := 0
+	for _, v := range experiment.Variants {
+		total += v.Weight
+	}
+	if total <= 0 {
+		return nil
+	}
+	h := fnv.New32a()
+	h.Write([]byte(strconv.FormatInt(experiment.ID, 10) + ":" + strconv.FormatInt(userID, 10)))
+	bucket := int(h.Sum32() % uint32(total))
+	for _, v := range experiment.Variants {
+		if bucket < v.Weight {
+			return v
+		}
+		bucket -= v.Weight
+	}
+	return nil
+}
+
+// ApplyExperiment 智能体有运行中的实验时，返回叠加了用户所分方案的副本；否则原样返回
+func ApplyExperiment(agent *model.Agent, userID int64) (*model.Agent, error) {
+	experiment, err := model.GetRunningAgentExperiment(agent.AgentID)
+	if err != nil || experiment == nil {
+		return agent, err
+	}
+	variant := AssignVariant(experiment, userID)
+	if variant == nil {
+		return agent, nil
+	}
+	assigned := *agent
+	assigned.ApplySnapshot(variant.AgentSnapshot)
+	assigned.ExperimentID = experiment.ID
+	assigned.VariantID = variant.ID
+	return &assigned, nil
+}
+
+// Results 按方案汇总实验结果，没有数据的方案也会列出
+func Results(experiment *model.AgentExperiment) ([]*VariantResult, error) {
+	stats, err := model.GetExperimentVariantStats(experiment.ID)
+	if err != nil {
+		return nil, err
+	}
+	byVariant := make(map[int64]*model.VariantStat, len(stats))
+	for _, stat := range stats {
+		byVariant[stat.VariantID] = stat
+	}
+	results := make([]*VariantResult, 0, len(experiment.Variants))
+	for _, v := range experiment.Variants {
+		result := &VariantResult{VariantStat: model.VariantStat{VariantID: v.ID}, Name: v.Name, Weight: v.Weight}
+		if stat, ok := byVariant[v.ID]; ok {
+			result.VariantStat = *stat
+		}
+		if rated := result.UpCount + result.DownCount; rated > 0 {
+			result.UpRate = float64(result.UpCount) / float64(rated)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,141 @@
+package experiment
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+)
+
+func setupAgent(t *testing.T) *model.Agent {
+	testutil.SetupDB(t, &model.Agent{}, &model.AgentVersion{}, &model.AgentExperiment{}, &model.AgentExperimentVariant{},
+		&model.Message{}, &model.MessageFeedback{})
+	agent := &model.Agent{Eid: 1, Name: "assistant", Model: "gpt-4o", Prompt: "live prompt",
+		Configs: "{}", Tools: "[]", CustomConfig: "{}", Settings: "{}", UseCases: "[]"}
+	if err := agent.Create(); err != nil {
+		t.Fatal(err)
+	}
+	return agent
+}
+
+func twoVariants() Input {
+	return Input{Name: "model test", Variants: []VariantInput{
+		{Name: "control", Weight: 1},
+		{Name: "mini", Weight: 1, Model: "gpt-4o-mini", Prompt: "short prompt"},
+	}}
+}
+
+func TestAssignVariantIsDeterministic(t *testing.T) {
+	exp := &model.AgentExperiment{ID: 7, Variants: []*model.AgentExperimentVariant{
+		{ID: 1, Weight: 1}, {ID: 2, Weight: 3},
+	}}
+	counts := map[int64]int{}
+	for userID := int64(1); userID <= 4000; userID++ {
+		v := AssignVariant(exp, userID)
+		if v != AssignVariant(exp, userID) {
+			t.Fatalf("user %d assigned inconsistently", userID)
+		}
+		counts[v.ID]++
+	}
+	// 权重 1:3，允许一定偏差
+	if counts[1] < 800 || counts[1] > 1200 {
+		t.Fatalf("unexpected split: %v", counts)
+	}
+}
+
+func TestExperimentLifecycle(t *testing.T) {
+	agent := setupAgent(t)
+
+	if _, err := Create(agent, Input{Name: "x", Variants: []VariantInput{{Name: "a", Weight: 1}}}, 9); !errors.Is(err, ErrInvalidVariants) {
+		t.Fatalf("single variant err = %v", err)
+	}
+	exp, err := Create(agent, twoVariants(), 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp.Variants[0].Prompt != "live prompt" || exp.Variants[1].Model != "gpt-4o-mini" || exp.Variants[1].Prompt != "short prompt" {
+		t.Fatalf("unexpected variants: %+v %+v", exp.Variants[0].AgentSnapshot, exp.Variants[1].AgentSnapshot)
+	}
+
+	// 未开始时不分流
+	if applied, _ := ApplyExperiment(agent, 1); applied.ExperimentID != 0 {
+		t.Fatalf("draft experiment applied: %+v", applied)
+	}
+	if err := Start(exp); err != nil {
+		t.Fatal(err)
+	}
+	other, _ := Create(agent, twoVariants(), 9)
+	if err := Start(other); !errors.Is(err, ErrExperimentRunning) {
+		t.Fatalf("second start err = %v", err)
+	}
+	if err := Update(exp, agent, twoVariants()); !errors.Is(err, ErrInvalidStatus) {
+		t.Fatalf("update running err = %v", err)
+	}
+
+	// 按分到的方案记录消息与评价
+	for userID := int64(1); userID <= 20; userID++ {
+		applied, err := ApplyExperiment(agent, userID)
+		if err != nil || applied.ExperimentID != exp.ID {
+			t.Fatalf("ApplyExperiment = %+v, %v", applied, err)
+		}
+		if variant := AssignVariant(exp, userID); applied.Model != variant.Model {
+			t.Fatalf("user %d got model %s, want %s", userID, applied.Model, variant.Model)
+		}
+		msg := &model.Message{Eid: 1, UserID: userID, AgentID: agent.AgentID, ElapsedTime: 100, TotalTokens: 10, Quota: 5,
+			ExperimentID: applied.ExperimentID, VariantID: applied.VariantID}
+		if err := model.DB.Create(msg).Error; err != nil {
+			t.Fatal(err)
+		}
+		rating := model.FeedbackRatingUp
+		if applied.Model == "gpt-4o-mini" {
+			rating = model.FeedbackRatingDown
+		}
+		if err := model.DB.Create(&model.MessageFeedback{Eid: 1, MessageID: msg.ID, UserID: userID, AgentID: agent.AgentID, Rating: rating}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if agent.Model != "gpt-4o" {
+		t.Fatalf("live agent modified: %s", agent.Model)
+	}
+
+	results, err := Results(exp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total int64
+	for _, r := range results {
+		total += r.MessageCount
+		if r.MessageCount > 0 && r.TotalTokens != 10*r.MessageCount {
+			t.Fatalf("unexpected tokens: %+v", r)
+		}
+		want := 1.0
+		if r.Name == "mini" {
+			want = 0
+		}
+		if r.MessageCount > 0 && r.UpRate != want {
+			t.Fatalf("unexpected up rate: %+v", r)
+		}
+	}
+	if len(results) != 2 || total != 20 {
+		t.Fatalf("unexpected results: %d variants, %d messages", len(results), total)
+	}
+
+	if err := Stop(exp); err != nil {
+		t.Fatal(err)
+	}
+	version, err := Promote(exp, agent, exp.Variants[1].ID, 9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	live, _ := model.GetAgentByID(1, agent.AgentID)
+	if live.Model != "gpt-4o-mini" || live.Version != version.Version || version.Version != 2 {
+		t.Fatalf("winner not promoted: model=%s version=%d", live.Model, live.Version)
+	}
+	if exp.Status != model.ExperimentStatusCompleted || exp.WinnerVariantID != exp.Variants[1].ID {
+		t.Fatalf("unexpected experiment: %+v", exp)
+	}
+	if err := Start(exp); !errors.Is(err, ErrInvalidStatus) {
+		t.Fatalf("restart completed err = %v", err)
+	}
+}
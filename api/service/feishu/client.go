@@ -0,0 +1,255 @@
+package feishu
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/orgsync"
+)
+
+// 飞书开放平台地址，测试时替换为本地模拟服务
+var (
+	openBase     = "https://open.feishu.cn"
+	accountsBase = "https://accounts.feishu.cn"
+)
+
+const (
+	// 根部门的 open_department_id
+	rootDepartmentID = "0"
+	pageSize         = 50
+)
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// Client 飞书企业自建应用，实现 orgsync.Provider 与 orgsync.Authenticator
+type Client struct {
+	cfg         *Config
+	accessToken string
+}
+
+var _ orgsync.Authenticator = (*Client)(nil)
+
+func NewClient(cfg *Config) *Client {
+	return &Client{cfg: cfg}
+}
+
+// Load 按企业配置创建客户端
+func Load(eid int64) (*Client, error) {
+	cfg, err := LoadConfig(eid)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(cfg), nil
+}
+
+func (c *Client) Source() int {
+	return model.DepartmentFromFeishu
+}
+
+// apiError 开放接口的公共返回
+type apiError struct {
+	Code int    `json:"code"`
+	Msg  string `json:"msg"`
+}
+
+func (e *apiError) err() error {
+	if e.Code != 0 {
+		return fmt.Errorf("feishu api error %d: %s", e.Code, e.Msg)
+	}
+	return nil
+}
+
+// tenantToken 获取 tenant_access_token
+func (c *Client) tenantToken() (string, error) {
+	if c.accessToken != "" {
+		return c.accessToken, nil
+	}
+	var resp struct {
+		apiError
+		TenantAccessToken string `json:"tenant_access_token"`
+	}
+	body := map[string]string{"app_id": c.cfg.AppID, "app_secret": c.cfg.AppSecret}
+	if err := doJSON(http.MethodPost, openBase+"/open-apis/auth/v3/tenant_access_token/internal", "", body, &resp); err != nil {
+		return "", err
+	}
+	if err := resp.err(); err != nil {
+		return "", err
+	}
+	c.accessToken = resp.TenantAccessToken
+	return c.accessToken, nil
+}
+
+// get 以应用身份调用接口，result 需内嵌 apiError
+func (c *Client) get(path string, query url.Values, result interface{ err() error }) error {
+	token, err := c.tenantToken()
+	if err != nil {
+		return err
+	}
+	if err := doJSON(http.MethodGet, openBase+path+"?"+query.Encode(), token, nil, result); err != nil {
+		return err
+	}
+	return result.err()
+}
+
+func doJSON(method string, rawURL string, token string, body interface{}, result interface{}) error {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequest(method, rawURL, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// 飞书接口出错时 HTTP 状态码可能不是 200，但返回体中仍包含 code 与 msg
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("feishu api status %d: %w", resp.StatusCode, err)
+	}
+	return nil
+}
+
+// Departments 获取根部门（即企业本身）及其下全部子部门
+func (c *Client) Departments() ([]*orgsync.Department, error) {
+	var tenant struct {
+		apiError
+		Data struct {
+			Tenant struct {
+				Name string `json:"name"`
+			} `json:"tenant"`
+		} `json:"data"`
+	}
+	if err := c.get("/open-apis/tenant/v2/tenant/query", url.Values{}, &tenant); err != nil {
+		return nil, err
+	}
+	departments := []*orgsync.Department{{ID: rootDepartmentID, Name: tenant.Data.Tenant.Name}}
+
+	pageToken := ""
+	for {
+		var resp struct {
+			apiError
+			Data struct {
+				HasMore   bool   `json:"has_more"`
+				PageToken string `json:"page_token"`
+				Items     []struct {
+					Name               string `json:"name"`
+					OpenDepartmentID   string `json:"open_department_id"`
+					ParentDepartmentID string `json:"parent_department_id"`
+					Order              string `json:"order"`
+				} `json:"items"`
+			} `json:"data"`
+		}
+		query := url.Values{
+			"department_id_type": {"open_department_id"},
+			"fetch_child":        {"true"},
+			"page_size":          {strconv.Itoa(pageSize)},
+			"page_token":         {pageToken},
+		}
+		if err := c.get("/open-apis/contact/v3/departments/"+rootDepartmentID+"/children", query, &resp); err != nil {
+			return nil, err
+		}
+		for _, item := range resp.Data.Items {
+			order, _ := strconv.Atoi(item.Order)
+			departments = append(departments, &orgsync.Department{
+				ID:       item.OpenDepartmentID,
+				ParentID: item.ParentDepartmentID,
+				Name:     item.Name,
+				Order:    order,
+			})
+		}
+		if !resp.Data.HasMore {
+			return departments, nil
+		}
+		pageToken = resp.Data.PageToken
+	}
+}
+
+type user struct {
+	OpenID          string `json:"open_id"`
+	Name            string `json:"name"`
+	Mobile          string `json:"mobile"`
+	Email           string `json:"email"`
+	EnterpriseEmail string `json:"enterprise_email"`
+	Avatar          struct {
+		Avatar240 string `json:"avatar_240"`
+	} `json:"avatar"`
+	DepartmentIDs []string `json:"department_ids"`
+	Status        struct {
+		IsFrozen   bool `json:"is_frozen"`
+		IsResigned bool `json:"is_resigned"`
+	} `json:"status"`
+}
+
+func (u *user) member() *orgsync.Member {
+	member := &orgsync.Member{
+		ID:            u.OpenID,
+		Name:          u.Name,
+		Mobile:        normalizeMobile(u.Mobile),
+		Email:         u.Email,
+		Avatar:        u.Avatar.Avatar240,
+		DepartmentIDs: u.DepartmentIDs,
+		Disabled:      u.Status.IsFrozen || u.Status.IsResigned,
+	}
+	if member.Email == "" {
+		member.Email = u.EnterpriseEmail
+	}
+	return member
+}
+
+// normalizeMobile 飞书返回的手机号带国家码，如 +8613800138000
+func normalizeMobile(mobile string) string {
+	return strings.TrimPrefix(mobile, "+86")
+}
+
+// Members 分页获取部门直属成员
+func (c *Client) Members(departmentID string) ([]*orgsync.Member, error) {
+	members := make([]*orgsync.Member, 0)
+	pageToken := ""
+	for {
+		var resp struct {
+			apiError
+			Data struct {
+				HasMore   bool   `json:"has_more"`
+				PageToken string `json:"page_token"`
+				Items     []user `json:"items"`
+			} `json:"data"`
+		}
+		query := url.Values{
+			"department_id":      {departmentID},
+			"department_id_type": {"open_department_id"},
+			"user_id_type":       {"open_id"},
+			"page_size":          {strconv.Itoa(pageSize)},
+			"page_token":         {pageToken},
+		}
+		if err := c.get("/open-apis/contact/v3/users/find_by_department", query, &resp); err != nil {
+			return nil, err
+		}
+		for i := range resp.Data.Items {
+			members = append(members, resp.Data.Items[i].member())
+		}
+		if !resp.Data.HasMore {
+			return members, nil
+		}
+		pageToken = resp.Data.PageToken
+	}
+}
@@ -0,0 +1,153 @@
+package feishu
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/orgsync"
+)
+
+var fakeUsers = map[string]map[string]interface{}{
+	"ou_alice": {"open_id": "ou_alice", "name": "Alice", "mobile": "+8613800138000", "email": "alice@example.com",
+		"department_ids": []string{"od_eng"}, "status": map[string]bool{}},
+	"ou_bob": {"open_id": "ou_bob", "name": "Bob", "mobile": "+8613900139000",
+		"department_ids": []string{"od_qa"}, "status": map[string]bool{"is_frozen": true}},
+}
+
+// newFakeFeishu 模拟飞书开放平台的通讯录与登录接口，分页返回部门以覆盖 page_token
+func newFakeFeishu(t *testing.T) {
+	reply := func(w http.ResponseWriter, data interface{}) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "msg": "success", "data": data})
+	}
+	authorized := func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Bearer tenant-token" {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": 99991663, "msg": "invalid access token"})
+			return false
+		}
+		return true
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/open-apis/auth/v3/tenant_access_token/internal", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "tenant_access_token": "tenant-token"})
+	})
+	mux.HandleFunc("/open-apis/tenant/v2/tenant/query", func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r) {
+			reply(w, map[string]interface{}{"tenant": map[string]string{"name": "Acme"}})
+		}
+	})
+	mux.HandleFunc("/open-apis/contact/v3/departments/0/children", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+		if r.URL.Query().Get("page_token") == "" {
+			reply(w, map[string]interface{}{"has_more": true, "page_token": "p2", "items": []map[string]string{
+				{"name": "QA", "open_department_id": "od_qa", "parent_department_id": "od_eng", "order": "2"},
+			}})
+			return
+		}
+		reply(w, map[string]interface{}{"has_more": false, "items": []map[string]string{
+			{"name": "Engineering", "open_department_id": "od_eng", "parent_department_id": "0", "order": "1"},
+		}})
+	})
+	mux.HandleFunc("/open-apis/contact/v3/users/find_by_department", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+		items := make([]map[string]interface{}, 0)
+		for _, u := range fakeUsers {
+			if u["department_ids"].([]string)[0] == r.URL.Query().Get("department_id") {
+				items = append(items, u)
+			}
+		}
+		reply(w, map[string]interface{}{"has_more": false, "items": items})
+	})
+	mux.HandleFunc("/open-apis/authen/v2/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+		var v map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&v)
+		if v["client_secret"] != "secret" || v["redirect_uri"] != "https://hub.example.com/callback" {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": 20050, "error_description": "invalid request"})
+			return
+		}
+		// 测试中授权码即成员的 open_id
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "access_token": v["code"]})
+	})
+	mux.HandleFunc("/open-apis/authen/v1/user_info", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]string{"open_id": strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")})
+	})
+	mux.HandleFunc("/open-apis/contact/v3/users/", func(w http.ResponseWriter, r *http.Request) {
+		if authorized(w, r) {
+			reply(w, map[string]interface{}{"user": fakeUsers[strings.TrimPrefix(r.URL.Path, "/open-apis/contact/v3/users/")]})
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	openBase, accountsBase = server.URL, server.URL
+}
+
+func setupDB(t *testing.T) *Client {
+	db := testutil.SetupDB(t, &model.EnterpriseConfig{}, &model.User{}, &model.MemberBinding{},
+		&model.Department{}, &model.MemberDepartmentRelation{})
+	content := `{"app_id":"cli_test","app_secret":"secret"}`
+	db.Create(&model.EnterpriseConfig{EID: 1, Type: model.EnterpriseConfigTypeFeishu, Enabled: true, Content: content})
+	client, err := Load(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestSyncAndLogin(t *testing.T) {
+	newFakeFeishu(t)
+	client := setupDB(t)
+
+	result, err := orgsync.Sync(1, client)
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Departments != 3 || result.Created != 2 {
+		t.Fatalf("unexpected result: %+v", *result)
+	}
+	departments, _ := model.GetDepartmentsByEID(1, model.DepartmentFromFeishu)
+	byID := map[string]*model.Department{}
+	for _, department := range departments {
+		byID[department.BindValue] = department
+	}
+	if byID["od_qa"] == nil || byID["od_eng"] == nil || byID["od_qa"].PDID != byID["od_eng"].DID || byID["0"].Name != "Acme" {
+		t.Fatalf("department tree not synced: %+v", departments)
+	}
+	bob, _ := model.GetMemberBindingByBindValue(1, "ou_bob", model.MemberBindingSourceFeishu)
+	if bob == nil || bob.Status != model.MemberBindingStatusDisabled {
+		t.Fatalf("frozen member should be disabled: %+v", bob)
+	}
+
+	// 未开启自动创建时只关联已有成员
+	existing := &model.User{Username: "13800138000", Mobile: "13800138000", Nickname: "alice", Password: "x", Eid: 1,
+		Type: model.UserTypeInternal, Role: model.RoleCommonUser}
+	if err := existing.Create(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := orgsync.Authorize(1, client, "https://hub.example.com/callback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, err := orgsync.Login(1, client, "ou_alice", info.State)
+	if err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if user.UserID != existing.UserID {
+		t.Fatalf("login should link the member with the same mobile: %d != %d", user.UserID, existing.UserID)
+	}
+
+	info, _ = orgsync.Authorize(1, client, "https://hub.example.com/callback")
+	if _, err := orgsync.Login(1, client, "ou_bob", info.State); err != orgsync.ErrUserDisabled {
+		t.Fatalf("expected ErrUserDisabled, got %v", err)
+	}
+}
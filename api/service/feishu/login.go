@@ -0,0 +1,74 @@
+package feishu
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/53AI/53AIHub/service/orgsync"
+)
+
+func (c *Client) ClientID() string {
+	return c.cfg.AppID
+}
+
+func (c *Client) AutoCreateUser() bool {
+	return c.cfg.AutoCreateUser
+}
+
+// AuthorizeURL 飞书网页授权页，PC 浏览器展示二维码，飞书客户端内直接授权
+func (c *Client) AuthorizeURL(redirectURI string, state string) string {
+	query := url.Values{
+		"client_id":    {c.cfg.AppID},
+		"redirect_uri": {redirectURI},
+		"state":        {state},
+	}
+	return accountsBase + "/open-apis/authen/v1/authorize?" + query.Encode()
+}
+
+// UserByCode 授权码换取用户 token 获取 open_id，再通过通讯录接口查询成员详情
+func (c *Client) UserByCode(code string, redirectURI string) (*orgsync.Member, error) {
+	var token struct {
+		Code             int    `json:"code"`
+		AccessToken      string `json:"access_token"`
+		ErrorDescription string `json:"error_description"`
+	}
+	body := map[string]string{
+		"grant_type":    "authorization_code",
+		"client_id":     c.cfg.AppID,
+		"client_secret": c.cfg.AppSecret,
+		"code":          code,
+		"redirect_uri":  redirectURI,
+	}
+	if err := doJSON(http.MethodPost, openBase+"/open-apis/authen/v2/oauth/token", "", body, &token); err != nil {
+		return nil, err
+	}
+	if token.Code != 0 || token.AccessToken == "" {
+		return nil, errors.New("feishu oauth token: " + token.ErrorDescription)
+	}
+
+	var info struct {
+		apiError
+		Data struct {
+			OpenID string `json:"open_id"`
+		} `json:"data"`
+	}
+	if err := doJSON(http.MethodGet, openBase+"/open-apis/authen/v1/user_info", token.AccessToken, nil, &info); err != nil {
+		return nil, err
+	}
+	if err := info.err(); err != nil {
+		return nil, err
+	}
+
+	var detail struct {
+		apiError
+		Data struct {
+			User user `json:"user"`
+		} `json:"data"`
+	}
+	query := url.Values{"user_id_type": {"open_id"}, "department_id_type": {"open_department_id"}}
+	if err := c.get("/open-apis/contact/v3/users/"+url.PathEscape(info.Data.OpenID), query, &detail); err != nil {
+		return nil, err
+	}
+	return detail.Data.User.member(), nil
+}
@@ -0,0 +1,345 @@
+package guardrail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/model"
+)
+
+var (
+	ErrInvalidRule       = errors.New("invalid guardrail rule")
+	ErrModerationChannel = errors.New("moderation channel not found")
+)
+
+// DefaultBlockMessage 策略未配置拦截提示时返回给用户的内容
+const DefaultBlockMessage = "抱歉，该内容不符合企业的安全策略，无法继续处理。"
+
+// 流式输出时至少保留的字节数，保证跨分片的命中内容在发送前能被完整识别；
+// 自定义正则的命中内容超过该长度时可能被分片截断
+const minHoldback = 64
+
+const moderationPrompt = `你是内容安全审核员。请判断下面的用户输入是否包含违法违规、暴力、色情、歧视、隐私窃取或其他不安全的内容。
+只输出 JSON，格式为：{"flagged": true, "category": "违规类别"}，安全时 flagged 为 false。
+
+【用户输入】
+%s`
+
+// ModerateFunc 使用审核渠道完成一次单轮对话，返回回复内容
+type ModerateFunc func(ctx context.Context, channel *model.Channel, modelName string, prompt string) (string, error)
+
+// Violation 一次规则命中
+type Violation struct {
+	RuleName string `json:"rule_name"`
+	RuleType string `json:"rule_type"`
+	Action   string `json:"action"`
+	Excerpt  string `json:"excerpt"`
+}
+
+// Result 检查结果，Text 为按 mask 规则脱敏后的内容
+type Result struct {
+	Text       string      `json:"text"`
+	Blocked    bool        `json:"blocked"`
+	Violations []Violation `json:"violations"`
+}
+
+type rule struct {
+	model.GuardrailRule
+	re    *regexp.Regexp
+	valid func(string) bool
+	mask  func(string) string
+}
+
+// span 待脱敏的区间
+type span struct {
+	start, end int
+	text       string
+}
+
+// Pipeline 对智能体生效的护栏规则，由企业级与智能体策略合并而成
+type Pipeline struct {
+	input        []*rule
+	output       []*rule
+	moderation   *model.GuardrailModeration
+	blockMessage string
+	holdback     int
+	// Moderate 审核模型调用，为空时跳过审核
+	Moderate ModerateFunc
+}
+
+// Normalize 校验并补全策略：规则默认作用于输入和输出、默认拦截，正则与审核模型配置需有效
+func Normalize(cfg *model.GuardrailConfig, eid int64) error {
+	for i := range cfg.Rules {
+		r := &cfg.Rules[i]
+		if r.Scope == "" {
+			r.Scope = model.GuardrailScopeBoth
+		}
+		if r.Action == "" {
+			r.Action = model.GuardrailActionBlock
+		}
+		if _, err := compileRule(*r); err != nil {
+			return fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+	if m := cfg.Moderation; m != nil {
+		if m.Action == "" {
+			m.Action = model.GuardrailActionBlock
+		}
+		if m.Action != model.GuardrailActionBlock && m.Action != model.GuardrailActionWarn {
+			return fmt.Errorf("moderation: %w", ErrInvalidRule)
+		}
+		channel, err := model.GetChannelByID(m.ChannelID)
+		if err != nil || channel.Eid != eid {
+			return ErrModerationChannel
+		}
+	}
+	return nil
+}
+
+func compileRule(r model.GuardrailRule) (*rule, error) {
+	switch r.Action {
+	case model.GuardrailActionBlock, model.GuardrailActionMask, model.GuardrailActionWarn:
+	default:
+		return nil, fmt.Errorf("%w: unknown action %q", ErrInvalidRule, r.Action)
+	}
+	switch r.Scope {
+	case model.GuardrailScopeInput, model.GuardrailScopeOutput, model.GuardrailScopeBoth:
+	default:
+		return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidRule, r.Scope)
+	}
+
+	compiled := &rule{GuardrailRule: r, mask: maskAll}
+	switch r.Type {
+	case model.GuardrailRuleKeyword:
+		words := make([]string, 0, len(r.Words))
+		for _, w := range r.Words {
+			if w = strings.TrimSpace(w); w != "" {
+				words = append(words, regexp.QuoteMeta(w))
+			}
+		}
+		if len(words) == 0 {
+			return nil, fmt.Errorf("%w: keyword rule requires words", ErrInvalidRule)
+		}
+		// 较长的词优先匹配，避免短词截断长词
+		sort.Slice(words, func(i, j int) bool { return len(words[i]) > len(words[j]) })
+		compiled.re = regexp.MustCompile("(?i)" + strings.Join(words, "|"))
+	case model.GuardrailRuleRegex:
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil || r.Pattern == "" {
+			return nil, fmt.Errorf("%w: invalid pattern %q", ErrInvalidRule, r.Pattern)
+		}
+		compiled.re = re
+	case model.GuardrailRulePII:
+		detector, ok := detectors[r.PII]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown pii %q", ErrInvalidRule, r.PII)
+		}
+		compiled.re, compiled.valid, compiled.mask = detector.re, detector.valid, detector.mask
+	default:
+		return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidRule, r.Type)
+	}
+	if compiled.Name == "" {
+		compiled.Name = r.Type
+		if r.Type == model.GuardrailRulePII {
+			compiled.Name = r.PII
+		}
+	}
+	return compiled, nil
+}
+
+// Compile 合并多个策略，没有任何规则和审核配置时返回 nil
+func Compile(policies []*model.GuardrailPolicy) (*Pipeline, error) {
+	p := &Pipeline{holdback: minHoldback}
+	for _, policy := range policies {
+		cfg := policy.GuardrailConfig
+		for _, r := range cfg.Rules {
+			compiled, err := compileRule(r)
+			if err != nil {
+				return nil, err
+			}
+			if r.Scope != model.GuardrailScopeOutput {
+				p.input = append(p.input, compiled)
+			}
+			if r.Scope != model.GuardrailScopeInput {
+				p.output = append(p.output, compiled)
+				for _, w := range r.Words {
+					if len(w) > p.holdback {
+						p.holdback = len(w)
+					}
+				}
+			}
+		}
+		// 智能体策略的审核配置与拦截提示覆盖企业级策略
+		if cfg.Moderation != nil {
+			p.moderation = cfg.Moderation
+		}
+		if cfg.BlockMessage != "" {
+			p.blockMessage = cfg.BlockMessage
+		}
+	}
+	if len(p.input) == 0 && len(p.output) == 0 && p.moderation == nil {
+		return nil, nil
+	}
+	return p, nil
+}
+
+// Load 加载企业与智能体的已启用策略，未配置时返回 nil
+func Load(eid, agentID int64) (*Pipeline, error) {
+	policies, err := model.GetEnabledGuardrailPolicies(eid, agentID)
+	if err != nil || len(policies) == 0 {
+		return nil, err
+	}
+	return Compile(policies)
+}
+
+// BlockMessage 拦截时返回给用户的提示
+func (p *Pipeline) BlockMessage() string {
+	if p.blockMessage != "" {
+		return p.blockMessage
+	}
+	return DefaultBlockMessage
+}
+
+// HasOutputRules 是否需要检查模型输出
+func (p *Pipeline) HasOutputRules() bool {
+	return len(p.output) > 0
+}
+
+// CheckInput 检查用户输入：先执行规则，未被拦截时再调用审核模型
+func (p *Pipeline) CheckInput(ctx context.Context, text string) *Result {
+	result := check(p.input, text)
+	if result.Blocked || p.moderation == nil || p.Moderate == nil {
+		return result
+	}
+	if v := p.moderate(ctx, text); v != nil {
+		result.Violations = append(result.Violations, *v)
+		result.Blocked = v.Action == model.GuardrailActionBlock
+	}
+	return result
+}
+
+// CheckOutput 检查完整的模型输出
+func (p *Pipeline) CheckOutput(text string) *Result {
+	return check(p.output, text)
+}
+
+type moderationVerdict struct {
+	Flagged  bool   `json:"flagged"`
+	Category string `json:"category"`
+}
+
+// moderate 审核模型调用失败时放行，仅记录日志
+func (p *Pipeline) moderate(ctx context.Context, text string) *Violation {
+	channel, err := model.GetChannelByID(p.moderation.ChannelID)
+	if err != nil {
+		logger.Errorf(ctx, "guardrail moderation channel %d not found", p.moderation.ChannelID)
+		return nil
+	}
+	reply, err := p.Moderate(ctx, channel, p.moderation.Model, fmt.Sprintf(moderationPrompt, text))
+	if err != nil {
+		logger.Errorf(ctx, "guardrail moderation failed: %s", err.Error())
+		return nil
+	}
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	var verdict moderationVerdict
+	if start < 0 || end < start || json.Unmarshal([]byte(reply[start:end+1]), &verdict) != nil {
+		logger.Errorf(ctx, "guardrail moderation reply is not json: %s", reply)
+		return nil
+	}
+	if !verdict.Flagged {
+		return nil
+	}
+	return &Violation{
+		RuleName: "moderation",
+		RuleType: model.GuardrailRuleModeration,
+		Action:   p.moderation.Action,
+		Excerpt:  verdict.Category,
+	}
+}
+
+// check 执行规则，每条规则最多记录一次命中
+func check(rules []*rule, text string) *Result {
+	blocked, violations, spans := scan(rules, text)
+	return &Result{Text: applyMasks(text, spans), Blocked: blocked, Violations: violations}
+}
+
+func scan(rules []*rule, text string) (bool, []Violation, []span) {
+	blocked := false
+	violations := make([]Violation, 0)
+	spans := make([]span, 0)
+	for _, r := range rules {
+		hit := false
+		for _, loc := range r.re.FindAllStringIndex(text, -1) {
+			match := text[loc[0]:loc[1]]
+			if r.valid != nil && !r.valid(match) {
+				continue
+			}
+			if !hit {
+				hit = true
+				excerpt := match
+				if r.Type != model.GuardrailRuleKeyword {
+					excerpt = r.mask(match)
+				}
+				violations = append(violations, Violation{RuleName: r.Name, RuleType: r.Type, Action: r.Action, Excerpt: truncate(excerpt)})
+			}
+			switch r.Action {
+			case model.GuardrailActionBlock:
+				blocked = true
+			case model.GuardrailActionMask:
+				spans = append(spans, span{start: loc[0], end: loc[1], text: r.mask(match)})
+			}
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	return blocked, violations, spans
+}
+
+// applyMasks 按区间替换，与前一区间重叠的区间忽略
+func applyMasks(text string, spans []span) string {
+	if len(spans) == 0 {
+		return text
+	}
+	var sb strings.Builder
+	pos := 0
+	for _, s := range spans {
+		if s.start < pos {
+			continue
+		}
+		sb.WriteString(text[pos:s.start])
+		sb.WriteString(s.text)
+		pos = s.end
+	}
+	sb.WriteString(text[pos:])
+	return sb.String()
+}
+
+func truncate(s string) string {
+	runes := []rune(s)
+	if len(runes) > 50 {
+		return string(runes[:50]) + "..."
+	}
+	return s
+}
+
+// Record 保存命中记录，失败时仅记录日志
+func Record(ctx context.Context, base model.GuardrailViolation, stage string, violations []Violation) {
+	records := make([]*model.GuardrailViolation, 0, len(violations))
+	for _, v := range violations {
+		record := base
+		record.Stage = stage
+		record.RuleName = v.RuleName
+		record.RuleType = v.RuleType
+		record.Action = v.Action
+		record.Excerpt = v.Excerpt
+		records = append(records, &record)
+	}
+	if err := model.CreateGuardrailViolations(records); err != nil {
+		logger.Errorf(ctx, "save guardrail violations failed: %s", err.Error())
+	}
+}
@@ -0,0 +1,182 @@
+package guardrail
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+)
+
+func setupDB(t *testing.T) {
+	testutil.SetupDB(t, &model.GuardrailPolicy{}, &model.GuardrailViolation{}, &model.Channel{})
+}
+
+func pipeline(t *testing.T, rules ...model.GuardrailRule) *Pipeline {
+	cfg := model.GuardrailConfig{Rules: rules}
+	if err := Normalize(&cfg, 1); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Compile([]*model.GuardrailPolicy{{Eid: 1, Enabled: true, GuardrailConfig: cfg}})
+	if err != nil || p == nil {
+		t.Fatalf("Compile = %v, %v", p, err)
+	}
+	return p
+}
+
+func TestRules(t *testing.T) {
+	p := pipeline(t,
+		model.GuardrailRule{Name: "secret", Type: model.GuardrailRuleKeyword, Words: []string{"内部资料", "Project X"}},
+		model.GuardrailRule{Type: model.GuardrailRulePII, PII: model.GuardrailPIIMobile, Action: model.GuardrailActionMask},
+		model.GuardrailRule{Type: model.GuardrailRulePII, PII: model.GuardrailPIIIDCard, Action: model.GuardrailActionMask},
+		model.GuardrailRule{Type: model.GuardrailRulePII, PII: model.GuardrailPIIEmail, Action: model.GuardrailActionMask},
+		model.GuardrailRule{Type: model.GuardrailRulePII, PII: model.GuardrailPIIBankCard, Action: model.GuardrailActionMask},
+		model.GuardrailRule{Name: "salary", Type: model.GuardrailRuleRegex, Pattern: `工资\d+`, Action: model.GuardrailActionWarn, Scope: model.GuardrailScopeInput},
+	)
+
+	result := p.CheckInput(context.Background(), "请总结 project x 的进展")
+	if !result.Blocked || len(result.Violations) != 1 || result.Violations[0].Excerpt != "project x" {
+		t.Fatalf("keyword not blocked: %+v", result)
+	}
+
+	result = p.CheckInput(context.Background(), "手机13812345678，身份证11010519491231002X，邮箱zhang@example.com，卡号6222021234567894，工资8000")
+	want := "手机138****5678，身份证110105********002X，邮箱z***@example.com，卡号************7894，工资8000"
+	if result.Blocked || result.Text != want {
+		t.Fatalf("masked = %q, want %q", result.Text, want)
+	}
+	if len(result.Violations) != 5 {
+		t.Fatalf("violations = %+v", result.Violations)
+	}
+
+	// 校验码错误或更长数字串中的片段不视为个人信息
+	result = p.CheckInput(context.Background(), "订单号 6222021234567895 与 9913812345678")
+	if len(result.Violations) != 0 {
+		t.Fatalf("false positive: %+v", result.Violations)
+	}
+
+	// 仅作用于输入的规则不检查输出
+	if result := p.CheckOutput("工资8000"); len(result.Violations) != 0 {
+		t.Fatalf("input rule applied to output: %+v", result)
+	}
+}
+
+func TestCompileMergesPolicies(t *testing.T) {
+	enterprise := &model.GuardrailPolicy{Eid: 1, Enabled: true, GuardrailConfig: model.GuardrailConfig{
+		Rules:        []model.GuardrailRule{{Type: model.GuardrailRuleKeyword, Words: []string{"机密"}, Action: model.GuardrailActionBlock, Scope: model.GuardrailScopeBoth}},
+		BlockMessage: "企业提示",
+	}}
+	agent := &model.GuardrailPolicy{Eid: 1, AgentID: 2, Enabled: true, GuardrailConfig: model.GuardrailConfig{
+		Rules:        []model.GuardrailRule{{Type: model.GuardrailRuleKeyword, Words: []string{"竞品"}, Action: model.GuardrailActionBlock, Scope: model.GuardrailScopeOutput}},
+		BlockMessage: "智能体提示",
+	}}
+	p, err := Compile([]*model.GuardrailPolicy{enterprise, agent})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.BlockMessage() != "智能体提示" || len(p.input) != 1 || len(p.output) != 2 {
+		t.Fatalf("unexpected pipeline: %q input=%d output=%d", p.BlockMessage(), len(p.input), len(p.output))
+	}
+	if p, _ := Compile([]*model.GuardrailPolicy{{Eid: 1, Enabled: true}}); p != nil {
+		t.Fatal("empty policy should compile to nil")
+	}
+}
+
+func TestOutputFilter(t *testing.T) {
+	p := pipeline(t,
+		model.GuardrailRule{Type: model.GuardrailRulePII, PII: model.GuardrailPIIMobile, Action: model.GuardrailActionMask},
+		model.GuardrailRule{Type: model.GuardrailRuleKeyword, Words: []string{"绝密"}, Scope: model.GuardrailScopeOutput},
+	)
+
+	// 手机号被拆到多个分片中仍能完整脱敏
+	f := p.NewOutputFilter()
+	var sb strings.Builder
+	for _, chunk := range []string{strings.Repeat("联系方式如下。", 10) + "电话 138", "1234", "5678 ", "谢谢"} {
+		out, blocked := f.Push(chunk)
+		if blocked {
+			t.Fatal("unexpected block")
+		}
+		sb.WriteString(out)
+	}
+	sb.WriteString(f.Flush())
+	if got := sb.String(); !strings.Contains(got, "电话 138****5678 谢谢") || strings.Contains(got, "13812345678") {
+		t.Fatalf("stream output = %q", got)
+	}
+
+	// 命中拦截规则后不再输出
+	f = p.NewOutputFilter()
+	if _, blocked := f.Push("这是绝"); blocked {
+		t.Fatal("blocked before keyword completed")
+	}
+	if _, blocked := f.Push("密内容"); !blocked {
+		t.Fatal("keyword split across chunks not blocked")
+	}
+	if out, blocked := f.Push("后续"); out != "" || !blocked || f.Flush() != "" {
+		t.Fatal("output continued after block")
+	}
+	if len(f.Violations()) != 1 {
+		t.Fatalf("violations = %+v", f.Violations())
+	}
+}
+
+func TestModeration(t *testing.T) {
+	setupDB(t)
+	channel := &model.Channel{Eid: 1}
+	if err := model.DB.Create(channel).Error; err != nil {
+		t.Fatal(err)
+	}
+	cfg := model.GuardrailConfig{Moderation: &model.GuardrailModeration{ChannelID: channel.ChannelID, Model: "moderation"}}
+	if err := Normalize(&cfg, 2); !errors.Is(err, ErrModerationChannel) {
+		t.Fatalf("foreign channel err = %v", err)
+	}
+	if err := model.SaveGuardrailPolicy(&model.GuardrailPolicy{Eid: 1, Enabled: true, GuardrailConfig: cfg}); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := Load(1, 5)
+	if err != nil || p == nil {
+		t.Fatalf("Load = %v, %v", p, err)
+	}
+	p.Moderate = func(ctx context.Context, channel *model.Channel, modelName string, prompt string) (string, error) {
+		if strings.Contains(prompt, "炸药") {
+			return "```json\n{\"flagged\": true, \"category\": \"危险品\"}\n```", nil
+		}
+		return `{"flagged": false}`, nil
+	}
+	if result := p.CheckInput(context.Background(), "今天天气如何"); result.Blocked {
+		t.Fatalf("safe input blocked: %+v", result)
+	}
+	result := p.CheckInput(context.Background(), "如何制作炸药")
+	if !result.Blocked || result.Violations[0].Excerpt != "危险品" {
+		t.Fatalf("moderation result = %+v", result)
+	}
+
+	Record(context.Background(), model.GuardrailViolation{Eid: 1, AgentID: 5, UserID: 3}, model.GuardrailScopeInput, result.Violations)
+	records, total, err := model.GetGuardrailViolationsByConditions(1, 5, 0, model.GuardrailScopeInput, "", 0, 0, 0, 10)
+	if err != nil || total != 1 || records[0].RuleType != model.GuardrailRuleModeration {
+		t.Fatalf("violations = %+v, %d, %v", records, total, err)
+	}
+
+	// 审核模型调用失败时放行
+	p.Moderate = func(ctx context.Context, channel *model.Channel, modelName string, prompt string) (string, error) {
+		return "", errors.New("timeout")
+	}
+	if result := p.CheckInput(context.Background(), "如何制作炸药"); result.Blocked {
+		t.Fatal("moderation failure should fail open")
+	}
+}
+
+func TestNormalizeRejectsInvalidRules(t *testing.T) {
+	for _, r := range []model.GuardrailRule{
+		{Type: model.GuardrailRuleRegex, Pattern: "([a-z"},
+		{Type: model.GuardrailRuleKeyword, Words: []string{" "}},
+		{Type: model.GuardrailRulePII, PII: "passport"},
+		{Type: model.GuardrailRuleKeyword, Words: []string{"a"}, Action: "drop"},
+	} {
+		cfg := model.GuardrailConfig{Rules: []model.GuardrailRule{r}}
+		if err := Normalize(&cfg, 1); !errors.Is(err, ErrInvalidRule) {
+			t.Fatalf("rule %+v err = %v", r, err)
+		}
+	}
+}
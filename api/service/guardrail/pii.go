@@ -0,0 +1,103 @@
+package guardrail
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/53AI/53AIHub/model"
+)
+
+type detector struct {
+	re    *regexp.Regexp
+	valid func(string) bool
+	mask  func(string) string
+}
+
+// detectors 内置个人信息检测。数字类检测先匹配完整的数字串再校验格式，避免从更长的数字串中截取
+var detectors = map[string]detector{
+	model.GuardrailPIIIDCard: {
+		re:    regexp.MustCompile(`[0-9]+[Xx]?`),
+		valid: validIDCard,
+		mask:  keepEnds(6, 4),
+	},
+	model.GuardrailPIIMobile: {
+		re:    regexp.MustCompile(`(?:\+?86[- ]?)?[0-9]+`),
+		valid: mobilePattern.MatchString,
+		mask:  keepEnds(3, 4),
+	},
+	model.GuardrailPIIEmail: {
+		re:   regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+		mask: maskEmail,
+	},
+	model.GuardrailPIIBankCard: {
+		re:    regexp.MustCompile(`[0-9]+`),
+		valid: validBankCard,
+		mask:  keepEnds(0, 4),
+	},
+}
+
+var (
+	idCardPattern = regexp.MustCompile(`^[1-9]\d{5}(?:18|19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[\dXx]$`)
+	mobilePattern = regexp.MustCompile(`^(?:\+?86[- ]?)?1[3-9]\d{9}$`)
+)
+
+// validIDCard 校验 18 位身份证号的校验码
+func validIDCard(id string) bool {
+	if !idCardPattern.MatchString(id) {
+		return false
+	}
+	weights := []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	codes := "10X98765432"
+	sum := 0
+	for i := 0; i < 17; i++ {
+		sum += int(id[i]-'0') * weights[i]
+	}
+	return strings.ToUpper(id[17:]) == string(codes[sum%11])
+}
+
+// validBankCard 16 到 19 位且通过 Luhn 校验的卡号
+func validBankCard(number string) bool {
+	if len(number) < 16 || len(number) > 19 || number[0] == '0' {
+		return false
+	}
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// keepEnds 保留开头 head 位与末尾 tail 位，其余替换为 *
+func keepEnds(head, tail int) func(string) string {
+	return func(s string) string {
+		n := len(s)
+		if n <= head+tail {
+			return strings.Repeat("*", n)
+		}
+		return s[:head] + strings.Repeat("*", n-head-tail) + s[n-tail:]
+	}
+}
+
+// maskEmail 保留用户名首字符与域名
+func maskEmail(s string) string {
+	at := strings.LastIndex(s, "@")
+	if at <= 0 {
+		return maskAll(s)
+	}
+	return s[:1] + "***" + s[at:]
+}
+
+// maskAll 按字符数替换为 *
+func maskAll(s string) string {
+	return strings.Repeat("*", utf8.RuneCountInString(s))
+}
@@ -0,0 +1,110 @@
+package guardrail
+
+import "unicode/utf8"
+
+// OutputFilter 对流式输出逐段执行护栏规则。
+// 末尾保留一段未发送的内容，跨分片的命中内容拼接完整后再脱敏；命中拦截规则后不再输出
+type OutputFilter struct {
+	p          *Pipeline
+	pending    string
+	blocked    bool
+	seen       map[string]bool
+	violations []Violation
+}
+
+func (p *Pipeline) NewOutputFilter() *OutputFilter {
+	return &OutputFilter{p: p, seen: make(map[string]bool)}
+}
+
+// Push 追加一段输出，返回可以发送的内容；blocked 为 true 时调用方应以拦截提示替换后续输出
+func (f *OutputFilter) Push(delta string) (out string, blocked bool) {
+	if f.blocked {
+		return "", true
+	}
+	f.pending += delta
+	isBlocked, violations, spans := scan(f.p.output, f.pending)
+	f.record(violations)
+	if isBlocked {
+		f.blocked = true
+		f.pending = ""
+		return "", true
+	}
+
+	cut := len(f.pending) - f.p.holdback
+	for cut > 0 && !utf8.RuneStart(f.pending[cut]) {
+		cut--
+	}
+	if cut <= 0 {
+		return "", false
+	}
+	// 不在可能的命中内容中间截断，包括尚未通过校验的候选（如未输出完的数字串）
+	for moved := true; moved; {
+		moved = false
+		for _, r := range f.p.output {
+			for _, loc := range r.re.FindAllStringIndex(f.pending, -1) {
+				if loc[0] < cut && cut < loc[1] {
+					cut = loc[0]
+					moved = true
+				}
+			}
+		}
+	}
+	if cut <= 0 {
+		return "", false
+	}
+
+	emit := make([]span, 0, len(spans))
+	for _, s := range spans {
+		if s.end <= cut {
+			emit = append(emit, s)
+		}
+	}
+	out = applyMasks(f.pending[:cut], emit)
+	f.pending = f.pending[cut:]
+	return out, false
+}
+
+// Flush 输出结束时返回保留的剩余内容
+func (f *OutputFilter) Flush() string {
+	if f.blocked || f.pending == "" {
+		return ""
+	}
+	result := check(f.p.output, f.pending)
+	f.record(result.Violations)
+	f.pending = ""
+	if result.Blocked {
+		f.blocked = true
+		return ""
+	}
+	return result.Text
+}
+
+// Check 检查非流式的完整输出，被拦截时返回拦截提示
+func (f *OutputFilter) Check(text string) (string, bool) {
+	result := check(f.p.output, text)
+	f.record(result.Violations)
+	if result.Blocked {
+		f.blocked = true
+		return f.p.BlockMessage(), true
+	}
+	return result.Text, false
+}
+
+func (f *OutputFilter) Blocked() bool {
+	return f.blocked
+}
+
+// Violations 本次输出命中的规则，每条规则只记录一次
+func (f *OutputFilter) Violations() []Violation {
+	return f.violations
+}
+
+func (f *OutputFilter) record(violations []Violation) {
+	for _, v := range violations {
+		key := v.RuleType + ":" + v.RuleName
+		if !f.seen[key] {
+			f.seen[key] = true
+			f.violations = append(f.violations, v)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package knowledge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/songquanpeng/one-api/relay/channeltype"
+)
+
+const bailianCompatibleBaseURL = "https://dashscope.aliyuncs.com/compatible-mode"
+
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage struct {
+		TotalTokens int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+// Embed 使用企业下配置的 embedding 渠道（OpenAI 兼容接口）计算文本向量
+func Embed(ctx context.Context, eid int64, modelName string, texts []string) ([][]float32, int, error) {
+	if len(texts) == 0 {
+		return [][]float32{}, 0, nil
+	}
+	channel, err := model.GetRandomChannelByModelType(eid, model.ModelTypeEmbedding, modelName)
+	if err != nil {
+		return nil, 0, fmt.Errorf("embedding model %s has no available channel: %w", modelName, err)
+	}
+
+	batchSize := config.KnowledgeEmbeddingBatchSize
+	if batchSize <= 0 {
+		batchSize = 16
+	}
+	vectors := make([][]float32, 0, len(texts))
+	totalTokens := 0
+	for start := 0; start < len(texts); start += batchSize {
+		end := start + batchSize
+		if end > len(texts) {
+			end = len(texts)
+		}
+		batch, tokens, err := callEmbeddingAPI(ctx, channel, modelName, texts[start:end])
+		if err != nil {
+			return nil, 0, err
+		}
+		vectors = append(vectors, batch...)
+		totalTokens += tokens
+	}
+	return vectors, totalTokens, nil
+}
+
+func callEmbeddingAPI(ctx context.Context, channel *model.Channel, modelName string, texts []string) ([][]float32, int, error) {
+	actualModel := modelName
+	if mapping := channel.GetModelMapping(); mapping != nil && mapping[modelName] != "" {
+		actualModel = mapping[modelName]
+	}
+	body, err := json.Marshal(embeddingRequest{Model: actualModel, Input: texts})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, embeddingURL(channel), bytes.NewReader(body))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+channel.Key)
+
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("embedding request failed, status: %d, body: %s", resp.StatusCode, string(respBody))
+	}
+
+	var result embeddingResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return nil, 0, err
+	}
+	if len(result.Data) != len(texts) {
+		return nil, 0, fmt.Errorf("embedding result count mismatch, expect %d, got %d", len(texts), len(result.Data))
+	}
+	sort.Slice(result.Data, func(i, j int) bool { return result.Data[i].Index < result.Data[j].Index })
+
+	vectors := make([][]float32, 0, len(result.Data))
+	for _, d := range result.Data {
+		vectors = append(vectors, d.Embedding)
+	}
+	return vectors, result.Usage.TotalTokens, nil
+}
+
+// embeddingURL 获取渠道的 embeddings 接口地址
+func embeddingURL(channel *model.Channel) string {
+	baseURL := strings.TrimRight(channel.GetBaseURL(), "/")
+	if channel.Type == model.ChannelApiBailian {
+		if baseURL == "" || baseURL == "https://dashscope.aliyuncs.com" {
+			baseURL = bailianCompatibleBaseURL
+		}
+	}
+	if baseURL == "" && channel.Type >= 0 && channel.Type < len(channeltype.ChannelBaseURLs) {
+		baseURL = channeltype.ChannelBaseURLs[channel.Type]
+	}
+	if strings.HasSuffix(baseURL, "/v1") {
+		return baseURL + "/embeddings"
+	}
+	return baseURL + "/v1/embeddings"
+}
@@ -0,0 +1,305 @@
+package knowledge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/common/storage"
+	"github.com/53AI/53AIHub/config"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+	"github.com/songquanpeng/one-api/relay/adaptor/openai"
+	"github.com/songquanpeng/one-api/relay/meta"
+)
+
+// IngestDocumentAsync 异步解析、切分并向量化文档
+func IngestDocumentAsync(kb *model.KnowledgeBase, doc *model.KnowledgeDocument) {
+	go func() {
+		if err := IngestDocument(context.Background(), kb, doc); err != nil {
+			logger.SysErrorf("knowledge document ingest failed, document_id=%d: %v", doc.ID, err)
+		}
+	}()
+}
+
+// IngestDocument 解析、切分并向量化文档，处理结果写回文档状态
+func IngestDocument(ctx context.Context, kb *model.KnowledgeBase, doc *model.KnowledgeDocument) error {
+	doc.Status = model.KnowledgeDocumentStatusProcessing
+	doc.ErrorMessage = ""
+	if err := model.UpdateKnowledgeDocument(doc); err != nil {
+		return err
+	}
+
+	err := ingest(ctx, kb, doc)
+	if err != nil {
+		doc.Status = model.KnowledgeDocumentStatusFailed
+		doc.ErrorMessage = err.Error()
+	} else {
+		doc.Status = model.KnowledgeDocumentStatusCompleted
+	}
+	if updateErr := model.UpdateKnowledgeDocument(doc); updateErr != nil {
+		return updateErr
+	}
+	return err
+}
+
+func ingest(ctx context.Context, kb *model.KnowledgeBase, doc *model.KnowledgeDocument) error {
+	uploadFile, err := model.GetUploadFileByID(doc.FileID)
+	if err != nil {
+		return fmt.Errorf("file not found: %w", err)
+	}
+	data, err := storage.StorageInstance.Load(uploadFile.Key)
+	if err != nil {
+		return fmt.Errorf("load file failed: %w", err)
+	}
+	text, err := ExtractText(uploadFile.Extension, data)
+	if err != nil {
+		return err
+	}
+
+	contents := SplitText(text, kb.ChunkSize, kb.ChunkOverlap)
+	vectors, totalTokens, err := Embed(ctx, kb.Eid, kb.EmbeddingModel, contents)
+	if err != nil {
+		return err
+	}
+
+	chunks := make([]*model.KnowledgeChunk, 0, len(contents))
+	for i, content := range contents {
+		embedding, err := json.Marshal(vectors[i])
+		if err != nil {
+			return err
+		}
+		chunks = append(chunks, &model.KnowledgeChunk{
+			Eid:             kb.Eid,
+			KnowledgeBaseID: kb.ID,
+			DocumentID:      doc.ID,
+			Seq:             i,
+			Content:         content,
+			Embedding:       string(embedding),
+			TokenCount:      openai.CountTokenText(content, kb.EmbeddingModel),
+		})
+	}
+
+	store, err := GetVectorStore()
+	if err != nil {
+		return err
+	}
+	if err := store.DeleteByDocument(kb.ID, doc.ID); err != nil {
+		return err
+	}
+	if err := model.ReplaceKnowledgeChunks(doc.ID, chunks); err != nil {
+		return err
+	}
+	records := make([]VectorRecord, 0, len(chunks))
+	for i, chunk := range chunks {
+		records = append(records, VectorRecord{
+			ChunkID:         chunk.ID,
+			DocumentID:      doc.ID,
+			KnowledgeBaseID: kb.ID,
+			Vector:          vectors[i],
+		})
+	}
+	if err := store.Upsert(kb.ID, records); err != nil {
+		return err
+	}
+
+	doc.ChunkCount = len(chunks)
+	doc.TotalTokens = totalTokens
+	return nil
+}
+
+// DeleteDocument 删除文档及其向量
+func DeleteDocument(doc *model.KnowledgeDocument) error {
+	store, err := GetVectorStore()
+	if err != nil {
+		return err
+	}
+	if err := store.DeleteByDocument(doc.KnowledgeBaseID, doc.ID); err != nil {
+		return err
+	}
+	return model.DeleteKnowledgeDocument(doc.Eid, doc.ID)
+}
+
+// DeleteKnowledgeBase 删除知识库及其向量
+func DeleteKnowledgeBase(kb *model.KnowledgeBase) error {
+	store, err := GetVectorStore()
+	if err != nil {
+		return err
+	}
+	if err := store.DeleteByKnowledgeBase(kb.ID); err != nil {
+		return err
+	}
+	return kb.Delete()
+}
+
+// Retrieve 在多个知识库中检索与 query 最相关的分块
+func Retrieve(ctx context.Context, eid int64, knowledgeBaseIDs []int64, query string) ([]model.KnowledgeCitation, error) {
+	citations := make([]model.KnowledgeCitation, 0)
+	query = strings.TrimSpace(query)
+	if query == "" || len(knowledgeBaseIDs) == 0 {
+		return citations, nil
+	}
+	store, err := GetVectorStore()
+	if err != nil {
+		return nil, err
+	}
+
+	// 同一 embedding 模型的查询向量只计算一次
+	queryVectors := make(map[string][]float32)
+	for _, kbID := range knowledgeBaseIDs {
+		kb, err := model.GetKnowledgeBaseByID(eid, kbID)
+		if err != nil {
+			continue
+		}
+		vector, ok := queryVectors[kb.EmbeddingModel]
+		if !ok {
+			vectors, _, err := Embed(ctx, eid, kb.EmbeddingModel, []string{query})
+			if err != nil {
+				return nil, err
+			}
+			vector = vectors[0]
+			queryVectors[kb.EmbeddingModel] = vector
+		}
+
+		topK := kb.TopK
+		if topK <= 0 {
+			topK = 5
+		}
+		hits, err := store.Search(kb.ID, vector, topK)
+		if err != nil {
+			return nil, err
+		}
+		kbCitations, err := buildCitations(kb, hits)
+		if err != nil {
+			return nil, err
+		}
+		if kb.RerankModel != "" && len(kbCitations) > 0 {
+			kbCitations = rerank(ctx, kb, query, kbCitations)
+		}
+		for _, citation := range kbCitations {
+			if citation.Score >= kb.ScoreThreshold {
+				citations = append(citations, citation)
+			}
+		}
+	}
+
+	sort.SliceStable(citations, func(i, j int) bool { return citations[i].Score > citations[j].Score })
+	return citations, nil
+}
+
+func buildCitations(kb *model.KnowledgeBase, hits []SearchHit) ([]model.KnowledgeCitation, error) {
+	if len(hits) == 0 {
+		return []model.KnowledgeCitation{}, nil
+	}
+	chunkIDs := make([]int64, 0, len(hits))
+	docIDs := make([]int64, 0, len(hits))
+	for _, hit := range hits {
+		chunkIDs = append(chunkIDs, hit.ChunkID)
+		docIDs = append(docIDs, hit.DocumentID)
+	}
+	chunks, err := model.GetKnowledgeChunksByIDs(chunkIDs)
+	if err != nil {
+		return nil, err
+	}
+	docs, err := model.GetKnowledgeDocumentsByIDs(docIDs)
+	if err != nil {
+		return nil, err
+	}
+	chunkMap := make(map[int64]*model.KnowledgeChunk, len(chunks))
+	for _, chunk := range chunks {
+		chunkMap[chunk.ID] = chunk
+	}
+	docMap := make(map[int64]*model.KnowledgeDocument, len(docs))
+	for _, doc := range docs {
+		docMap[doc.ID] = doc
+	}
+
+	citations := make([]model.KnowledgeCitation, 0, len(hits))
+	for _, hit := range hits {
+		chunk, ok := chunkMap[hit.ChunkID]
+		if !ok {
+			continue
+		}
+		citation := model.KnowledgeCitation{
+			KnowledgeBaseID: kb.ID,
+			DocumentID:      chunk.DocumentID,
+			ChunkID:         chunk.ID,
+			Score:           hit.Score,
+			Content:         chunk.Content,
+		}
+		if doc, ok := docMap[chunk.DocumentID]; ok {
+			citation.FileName = doc.FileName
+		}
+		citations = append(citations, citation)
+	}
+	return citations, nil
+}
+
+// rerank 使用知识库配置的 rerank 模型重新打分，失败时保留向量检索结果
+func rerank(ctx context.Context, kb *model.KnowledgeBase, query string, citations []model.KnowledgeCitation) []model.KnowledgeCitation {
+	channelType := service.GetRerankChannelType(kb.RerankModel)
+	if channelType != model.ChannelApiBailian {
+		return citations
+	}
+	channel, err := model.GetRandomChannel(kb.Eid, channelType, kb.RerankModel)
+	if err != nil {
+		logger.SysErrorf("knowledge rerank channel not found, model=%s: %v", kb.RerankModel, err)
+		return citations
+	}
+
+	documents := make([]string, 0, len(citations))
+	for _, citation := range citations {
+		documents = append(documents, citation.Content)
+	}
+	relayMeta := &meta.Meta{
+		ChannelType:     channel.Type,
+		ChannelId:       int(channel.ChannelID),
+		OriginModelName: kb.RerankModel,
+		ActualModelName: kb.RerankModel,
+		APIType:         model.GetApiType(channel.Type),
+		APIKey:          channel.Key,
+		BaseURL:         channel.GetBaseURL(),
+	}
+	resp, _, err := (&service.BailianRerankService{}).CallBailianRerankAPI(ctx, &service.RerankRequest{
+		Model:     kb.RerankModel,
+		Query:     query,
+		Documents: documents,
+	}, relayMeta)
+	if err != nil {
+		logger.SysErrorf("knowledge rerank failed, model=%s: %v", kb.RerankModel, err)
+		return citations
+	}
+
+	reranked := make([]model.KnowledgeCitation, 0, len(resp.Data))
+	for _, result := range resp.Data {
+		if result.Index < 0 || result.Index >= len(citations) {
+			continue
+		}
+		citation := citations[result.Index]
+		citation.Score = result.RelevanceScore
+		reranked = append(reranked, citation)
+	}
+	return reranked
+}
+
+// BuildContextPrompt 将检索结果拼接为注入系统提示词的上下文，返回上下文及实际使用的引用
+func BuildContextPrompt(citations []model.KnowledgeCitation) (string, []model.KnowledgeCitation) {
+	if len(citations) == 0 {
+		return "", citations
+	}
+	maxLength := config.KnowledgeMaxContextLength
+	var sb strings.Builder
+	sb.WriteString("以下是从知识库中检索到的参考资料，请优先依据这些资料回答用户问题，并在回答中标注引用的资料编号，如 [1]。如果资料与问题无关，请忽略。\n\n")
+	for i, citation := range citations {
+		block := fmt.Sprintf("[%d] %s\n%s\n\n", i+1, citation.FileName, citation.Content)
+		if maxLength > 0 && sb.Len()+len(block) > maxLength && i > 0 {
+			citations = citations[:i]
+			break
+		}
+		sb.WriteString(block)
+	}
+	return strings.TrimSpace(sb.String()), citations
+}
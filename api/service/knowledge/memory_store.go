@@ -0,0 +1,153 @@
+package knowledge
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/model"
+)
+
+// MemoryStore 纯 Go 的进程内向量索引
+// 向量的持久化由 KnowledgeChunk.Embedding 负责，索引在首次检索时从数据库懒加载
+type MemoryStore struct {
+	mu      sync.RWMutex
+	indexes map[int64]*memoryIndex
+}
+
+type memoryIndex struct {
+	entries map[int64]*memoryEntry // key: chunkID
+}
+
+type memoryEntry struct {
+	documentID int64
+	vector     []float32
+	norm       float64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{indexes: make(map[int64]*memoryIndex)}
+}
+
+func (m *MemoryStore) Upsert(knowledgeBaseID int64, records []VectorRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	index, ok := m.indexes[knowledgeBaseID]
+	if !ok {
+		// 尚未加载过的知识库，等待检索时整体从数据库加载
+		return nil
+	}
+	for _, r := range records {
+		index.entries[r.ChunkID] = newMemoryEntry(r.DocumentID, r.Vector)
+	}
+	return nil
+}
+
+func (m *MemoryStore) Search(knowledgeBaseID int64, vector []float32, topK int) ([]SearchHit, error) {
+	index, err := m.loadIndex(knowledgeBaseID)
+	if err != nil {
+		return nil, err
+	}
+
+	queryNorm := vectorNorm(vector)
+	if queryNorm == 0 {
+		return []SearchHit{}, nil
+	}
+
+	m.mu.RLock()
+	hits := make([]SearchHit, 0, len(index.entries))
+	for chunkID, entry := range index.entries {
+		if entry.norm == 0 || len(entry.vector) != len(vector) {
+			continue
+		}
+		hits = append(hits, SearchHit{
+			ChunkID:    chunkID,
+			DocumentID: entry.documentID,
+			Score:      dotProduct(vector, entry.vector) / (queryNorm * entry.norm),
+		})
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if topK > 0 && len(hits) > topK {
+		hits = hits[:topK]
+	}
+	return hits, nil
+}
+
+func (m *MemoryStore) DeleteByDocument(knowledgeBaseID int64, documentID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	index, ok := m.indexes[knowledgeBaseID]
+	if !ok {
+		return nil
+	}
+	for chunkID, entry := range index.entries {
+		if entry.documentID == documentID {
+			delete(index.entries, chunkID)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStore) DeleteByKnowledgeBase(knowledgeBaseID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.indexes, knowledgeBaseID)
+	return nil
+}
+
+// loadIndex 返回知识库索引，不存在时从数据库加载
+func (m *MemoryStore) loadIndex(knowledgeBaseID int64) (*memoryIndex, error) {
+	m.mu.RLock()
+	index, ok := m.indexes[knowledgeBaseID]
+	m.mu.RUnlock()
+	if ok {
+		return index, nil
+	}
+
+	chunks, err := model.GetKnowledgeChunksByKnowledgeBaseID(knowledgeBaseID)
+	if err != nil {
+		return nil, err
+	}
+	index = &memoryIndex{entries: make(map[int64]*memoryEntry, len(chunks))}
+	for _, chunk := range chunks {
+		vector, err := chunk.GetEmbeddingVector()
+		if err != nil {
+			logger.SysErrorf("parse chunk embedding failed, chunk_id=%d: %v", chunk.ID, err)
+			continue
+		}
+		index.entries[chunk.ID] = newMemoryEntry(chunk.DocumentID, vector)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if existed, ok := m.indexes[knowledgeBaseID]; ok {
+		return existed, nil
+	}
+	m.indexes[knowledgeBaseID] = index
+	return index, nil
+}
+
+func newMemoryEntry(documentID int64, vector []float32) *memoryEntry {
+	return &memoryEntry{
+		documentID: documentID,
+		vector:     vector,
+		norm:       vectorNorm(vector),
+	}
+}
+
+func dotProduct(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+func vectorNorm(v []float32) float64 {
+	return math.Sqrt(dotProduct(v, v))
+}
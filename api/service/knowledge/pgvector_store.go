@@ -0,0 +1,149 @@
+package knowledge
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const pgVectorTable = "knowledge_vectors"
+
+// PgVectorStore 基于 PostgreSQL pgvector 扩展的向量存储
+// 向量维度在首次写入时确定，同一张表内所有知识库需使用同维度的 embedding 模型
+type PgVectorStore struct {
+	db        *gorm.DB
+	mu        sync.Mutex
+	tableInit bool
+}
+
+func NewPgVectorStore(dsn string) (*PgVectorStore, error) {
+	if dsn == "" {
+		return nil, errors.New("PGVECTOR_DSN is empty")
+	}
+	db, err := gorm.Open(postgres.New(postgres.Config{
+		DSN:                  dsn,
+		PreferSimpleProtocol: true,
+	}), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Exec("CREATE EXTENSION IF NOT EXISTS vector").Error; err != nil {
+		return nil, err
+	}
+	return &PgVectorStore{db: db}, nil
+}
+
+func (p *PgVectorStore) ensureTable(dimension int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.tableInit {
+		return nil
+	}
+	sql := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		chunk_id BIGINT PRIMARY KEY,
+		document_id BIGINT NOT NULL,
+		knowledge_base_id BIGINT NOT NULL,
+		embedding vector(%d) NOT NULL
+	)`, pgVectorTable, dimension)
+	if err := p.db.Exec(sql).Error; err != nil {
+		return err
+	}
+	indexSQL := fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_kb ON %s (knowledge_base_id)", pgVectorTable, pgVectorTable)
+	if err := p.db.Exec(indexSQL).Error; err != nil {
+		return err
+	}
+	p.tableInit = true
+	return nil
+}
+
+func (p *PgVectorStore) Upsert(knowledgeBaseID int64, records []VectorRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if err := p.ensureTable(len(records[0].Vector)); err != nil {
+		return err
+	}
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		for _, r := range records {
+			err := tx.Exec(fmt.Sprintf(`INSERT INTO %s (chunk_id, document_id, knowledge_base_id, embedding)
+				VALUES (?, ?, ?, ?::vector)
+				ON CONFLICT (chunk_id) DO UPDATE SET document_id = EXCLUDED.document_id,
+				knowledge_base_id = EXCLUDED.knowledge_base_id, embedding = EXCLUDED.embedding`, pgVectorTable),
+				r.ChunkID, r.DocumentID, knowledgeBaseID, formatPgVector(r.Vector)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (p *PgVectorStore) Search(knowledgeBaseID int64, vector []float32, topK int) ([]SearchHit, error) {
+	if err := p.ensureTable(len(vector)); err != nil {
+		return nil, err
+	}
+	if topK <= 0 {
+		topK = 5
+	}
+	var rows []struct {
+		ChunkID    int64
+		DocumentID int64
+		Distance   float64
+	}
+	err := p.db.Raw(fmt.Sprintf(`SELECT chunk_id, document_id, embedding <=> ?::vector AS distance
+		FROM %s WHERE knowledge_base_id = ? ORDER BY distance ASC LIMIT ?`, pgVectorTable),
+		formatPgVector(vector), knowledgeBaseID, topK).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]SearchHit, 0, len(rows))
+	for _, row := range rows {
+		// <=> 为余弦距离，转换为相似度
+		hits = append(hits, SearchHit{ChunkID: row.ChunkID, DocumentID: row.DocumentID, Score: 1 - row.Distance})
+	}
+	return hits, nil
+}
+
+func (p *PgVectorStore) DeleteByDocument(knowledgeBaseID int64, documentID int64) error {
+	if !p.tableInit {
+		return p.deleteIfTableExists("knowledge_base_id = ? AND document_id = ?", knowledgeBaseID, documentID)
+	}
+	return p.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE knowledge_base_id = ? AND document_id = ?", pgVectorTable),
+		knowledgeBaseID, documentID).Error
+}
+
+func (p *PgVectorStore) DeleteByKnowledgeBase(knowledgeBaseID int64) error {
+	if !p.tableInit {
+		return p.deleteIfTableExists("knowledge_base_id = ?", knowledgeBaseID)
+	}
+	return p.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE knowledge_base_id = ?", pgVectorTable), knowledgeBaseID).Error
+}
+
+func (p *PgVectorStore) deleteIfTableExists(where string, args ...interface{}) error {
+	if !p.db.Migrator().HasTable(pgVectorTable) {
+		return nil
+	}
+	return p.db.Exec(fmt.Sprintf("DELETE FROM %s WHERE %s", pgVectorTable, where), args...).Error
+}
+
+// formatPgVector 将向量转换为 pgvector 文本格式，如 [0.1,0.2]
+func formatPgVector(vector []float32) string {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	for i, v := range vector {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(strconv.FormatFloat(float64(v), 'f', -1, 32))
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
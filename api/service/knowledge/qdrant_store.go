@@ -0,0 +1,180 @@
+package knowledge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QdrantStore 基于 Qdrant REST API 的向量存储，每个知识库对应一个 collection
+type QdrantStore struct {
+	baseURL     string
+	apiKey      string
+	prefix      string
+	client      *http.Client
+	mu          sync.Mutex
+	collections map[int64]bool
+}
+
+func NewQdrantStore(baseURL, apiKey, prefix string) *QdrantStore {
+	return &QdrantStore{
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		apiKey:      apiKey,
+		prefix:      prefix,
+		client:      &http.Client{Timeout: 30 * time.Second},
+		collections: make(map[int64]bool),
+	}
+}
+
+func (q *QdrantStore) collectionName(knowledgeBaseID int64) string {
+	return fmt.Sprintf("%s%d", q.prefix, knowledgeBaseID)
+}
+
+func (q *QdrantStore) do(method, path string, body interface{}, out interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, q.baseURL+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if q.apiKey != "" {
+		req.Header.Set("api-key", q.apiKey)
+	}
+	resp, err := q.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("qdrant request failed, status: %d, body: %s", resp.StatusCode, string(respBody))
+	}
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return resp.StatusCode, err
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+func (q *QdrantStore) ensureCollection(knowledgeBaseID int64, dimension int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.collections[knowledgeBaseID] {
+		return nil
+	}
+	name := q.collectionName(knowledgeBaseID)
+	status, err := q.do(http.MethodGet, "/collections/"+name, nil, nil)
+	if err != nil && status != http.StatusNotFound {
+		return err
+	}
+	if status == http.StatusNotFound {
+		_, err = q.do(http.MethodPut, "/collections/"+name, map[string]interface{}{
+			"vectors": map[string]interface{}{
+				"size":     dimension,
+				"distance": "Cosine",
+			},
+		}, nil)
+		if err != nil {
+			return err
+		}
+	}
+	q.collections[knowledgeBaseID] = true
+	return nil
+}
+
+func (q *QdrantStore) Upsert(knowledgeBaseID int64, records []VectorRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if err := q.ensureCollection(knowledgeBaseID, len(records[0].Vector)); err != nil {
+		return err
+	}
+	points := make([]map[string]interface{}, 0, len(records))
+	for _, r := range records {
+		points = append(points, map[string]interface{}{
+			"id":     r.ChunkID,
+			"vector": r.Vector,
+			"payload": map[string]interface{}{
+				"document_id": r.DocumentID,
+			},
+		})
+	}
+	_, err := q.do(http.MethodPut, "/collections/"+q.collectionName(knowledgeBaseID)+"/points?wait=true",
+		map[string]interface{}{"points": points}, nil)
+	return err
+}
+
+func (q *QdrantStore) Search(knowledgeBaseID int64, vector []float32, topK int) ([]SearchHit, error) {
+	if topK <= 0 {
+		topK = 5
+	}
+	var result struct {
+		Result []struct {
+			ID      int64   `json:"id"`
+			Score   float64 `json:"score"`
+			Payload struct {
+				DocumentID int64 `json:"document_id"`
+			} `json:"payload"`
+		} `json:"result"`
+	}
+	status, err := q.do(http.MethodPost, "/collections/"+q.collectionName(knowledgeBaseID)+"/points/search",
+		map[string]interface{}{
+			"vector":       vector,
+			"limit":        topK,
+			"with_payload": true,
+		}, &result)
+	if status == http.StatusNotFound {
+		return []SearchHit{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	hits := make([]SearchHit, 0, len(result.Result))
+	for _, r := range result.Result {
+		hits = append(hits, SearchHit{ChunkID: r.ID, DocumentID: r.Payload.DocumentID, Score: r.Score})
+	}
+	return hits, nil
+}
+
+func (q *QdrantStore) DeleteByDocument(knowledgeBaseID int64, documentID int64) error {
+	status, err := q.do(http.MethodPost, "/collections/"+q.collectionName(knowledgeBaseID)+"/points/delete?wait=true",
+		map[string]interface{}{
+			"filter": map[string]interface{}{
+				"must": []map[string]interface{}{
+					{"key": "document_id", "match": map[string]interface{}{"value": documentID}},
+				},
+			},
+		}, nil)
+	if status == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+func (q *QdrantStore) DeleteByKnowledgeBase(knowledgeBaseID int64) error {
+	status, err := q.do(http.MethodDelete, "/collections/"+q.collectionName(knowledgeBaseID), nil, nil)
+	q.mu.Lock()
+	delete(q.collections, knowledgeBaseID)
+	q.mu.Unlock()
+	if status == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
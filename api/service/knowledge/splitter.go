@@ -0,0 +1,150 @@
+package knowledge
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	htmlTagRegexp    = regexp.MustCompile(`(?s)<script.*?</script>|<style.*?</style>|<[^>]+>`)
+	blankLinesRegexp = regexp.MustCompile(`\n{3,}`)
+)
+
+// ExtractText 按扩展名从文件内容中提取纯文本
+func ExtractText(extension string, data []byte) (string, error) {
+	ext := strings.ToLower(strings.TrimPrefix(extension, "."))
+	switch ext {
+	case "txt", "md", "markdown", "csv", "json", "log", "":
+		if !utf8.Valid(data) {
+			return "", errors.New("file is not valid utf-8 text")
+		}
+		return string(data), nil
+	case "html", "htm":
+		text := htmlTagRegexp.ReplaceAllString(string(data), "\n")
+		return blankLinesRegexp.ReplaceAllString(text, "\n\n"), nil
+	case "docx":
+		return extractDocxText(data)
+	default:
+		return "", fmt.Errorf("unsupported file type: %s", ext)
+	}
+}
+
+// extractDocxText 读取 docx 中 word/document.xml 的文本段落
+func extractDocxText(data []byte) (string, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", err
+	}
+	for _, f := range reader.File {
+		if f.Name != "word/document.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+		defer rc.Close()
+
+		var sb strings.Builder
+		decoder := xml.NewDecoder(rc)
+		inText := false
+		for {
+			token, err := decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return "", err
+			}
+			switch t := token.(type) {
+			case xml.StartElement:
+				if t.Name.Local == "t" {
+					inText = true
+				}
+			case xml.EndElement:
+				switch t.Name.Local {
+				case "t":
+					inText = false
+				case "p":
+					sb.WriteString("\n")
+				}
+			case xml.CharData:
+				if inText {
+					sb.Write(t)
+				}
+			}
+		}
+		return sb.String(), nil
+	}
+	return "", errors.New("invalid docx file")
+}
+
+// SplitText 按段落切分文本，单段超过 chunkSize 时按字符截断，相邻分块保留 overlap 个字符的重叠
+func SplitText(text string, chunkSize, overlap int) []string {
+	if chunkSize <= 0 {
+		chunkSize = 500
+	}
+	if overlap < 0 || overlap >= chunkSize {
+		overlap = 0
+	}
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	paragraphs := strings.Split(text, "\n")
+
+	chunks := make([]string, 0)
+	current := make([]rune, 0, chunkSize)
+	flush := func() {
+		content := strings.TrimSpace(string(current))
+		if content != "" {
+			chunks = append(chunks, content)
+		}
+		if overlap > 0 && len(current) > overlap {
+			current = append([]rune{}, current[len(current)-overlap:]...)
+		} else {
+			current = current[:0]
+		}
+	}
+
+	for _, paragraph := range paragraphs {
+		paragraph = strings.TrimSpace(paragraph)
+		if paragraph == "" {
+			continue
+		}
+		runes := []rune(paragraph)
+		if len(current) > overlap && len(current)+len(runes)+1 > chunkSize {
+			flush()
+		}
+		newParagraph := true
+		for len(runes) > 0 {
+			space := chunkSize - len(current)
+			if newParagraph && len(current) > 0 {
+				current = append(current, '\n')
+				space--
+			}
+			newParagraph = false
+			if space <= 0 {
+				flush()
+				continue
+			}
+			if len(runes) <= space {
+				current = append(current, runes...)
+				runes = nil
+			} else {
+				current = append(current, runes[:space]...)
+				runes = runes[space:]
+				flush()
+			}
+		}
+	}
+	if len(current) > overlap || len(chunks) == 0 {
+		flush()
+	}
+	return chunks
+}
@@ -0,0 +1,62 @@
+package knowledge
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitText(t *testing.T) {
+	for _, tc := range []struct {
+		name               string
+		text               string
+		chunkSize, overlap int
+		want               []string
+	}{
+		{"blank lines dropped", "a\r\n\r\n  b  \n", 500, 0, []string{"a\nb"}},
+		{"paragraphs packed", "第一段\n第二段\n第三段", 7, 0, []string{"第一段\n第二段", "第三段"}},
+		{"long paragraph cut", "abcdefghijkl", 5, 0, []string{"abcde", "fghij", "kl"}},
+		{"overlap kept", "abcdefghijkl", 5, 2, []string{"abcde", "defgh", "ghijk", "jkl"}},
+		{"overlap not smaller than chunk", "abcdef", 3, 3, []string{"abc", "def"}},
+		{"empty text", " \n ", 10, 0, []string{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := SplitText(tc.text, tc.chunkSize, tc.overlap); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("SplitText = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExtractText(t *testing.T) {
+	text, err := ExtractText(".MD", []byte("# 标题"))
+	if err != nil || text != "# 标题" {
+		t.Fatalf("markdown = %q, %v", text, err)
+	}
+	if _, err := ExtractText("txt", []byte{0xff, 0xfe}); err == nil {
+		t.Fatal("invalid utf-8 should fail")
+	}
+	if _, err := ExtractText("pdf", []byte("%PDF")); err == nil {
+		t.Fatal("unsupported type should fail")
+	}
+
+	text, err = ExtractText("html", []byte(`<html><script>alert(1)</script><p>你好</p><p>世界</p></html>`))
+	if err != nil || strings.Contains(text, "alert") || strings.Join(strings.Fields(text), " ") != "你好 世界" {
+		t.Fatalf("html = %q, %v", text, err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, _ := zw.Create("word/document.xml")
+	w.Write([]byte(`<w:document xmlns:w="w"><w:body><w:p><w:r><w:t>第一</w:t></w:r><w:r><w:t>段</w:t></w:r></w:p><w:p><w:r><w:t>第二段</w:t></w:r></w:p></w:body></w:document>`))
+	zw.Close()
+	text, err = ExtractText("docx", buf.Bytes())
+	if err != nil || text != "第一段\n第二段\n" {
+		t.Fatalf("docx = %q, %v", text, err)
+	}
+	if _, err := ExtractText("docx", []byte("not a zip")); err == nil {
+		t.Fatal("invalid docx should fail")
+	}
+}
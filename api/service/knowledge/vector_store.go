@@ -0,0 +1,68 @@
+package knowledge
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/53AI/53AIHub/config"
+)
+
+// VectorRecord 写入向量存储的一条记录
+type VectorRecord struct {
+	ChunkID         int64
+	DocumentID      int64
+	KnowledgeBaseID int64
+	Vector          []float32
+}
+
+// SearchHit 向量检索命中结果，Score 为余弦相似度
+type SearchHit struct {
+	ChunkID    int64
+	DocumentID int64
+	Score      float64
+}
+
+// VectorStore 定义向量存储的统一接口
+type VectorStore interface {
+	// 写入或覆盖向量
+	Upsert(knowledgeBaseID int64, records []VectorRecord) error
+	// 检索 topK 个最相似的分块
+	Search(knowledgeBaseID int64, vector []float32, topK int) ([]SearchHit, error)
+	// 删除文档的全部向量
+	DeleteByDocument(knowledgeBaseID int64, documentID int64) error
+	// 删除知识库的全部向量
+	DeleteByKnowledgeBase(knowledgeBaseID int64) error
+}
+
+const (
+	VectorStoreMemory   = "memory"
+	VectorStorePgVector = "pgvector"
+	VectorStoreQdrant   = "qdrant"
+)
+
+var (
+	storeOnce     sync.Once
+	storeInstance VectorStore
+	storeErr      error
+)
+
+// GetVectorStore 根据 VECTOR_STORE 配置返回向量存储实例
+func GetVectorStore() (VectorStore, error) {
+	storeOnce.Do(func() {
+		storeInstance, storeErr = newVectorStore(config.VectorStoreType)
+	})
+	return storeInstance, storeErr
+}
+
+func newVectorStore(storeType string) (VectorStore, error) {
+	switch storeType {
+	case "", VectorStoreMemory:
+		return NewMemoryStore(), nil
+	case VectorStorePgVector:
+		return NewPgVectorStore(config.PgVectorDSN)
+	case VectorStoreQdrant:
+		return NewQdrantStore(config.QdrantURL, config.QdrantAPIKey, config.QdrantCollectionPrefix), nil
+	default:
+		return nil, fmt.Errorf("unsupported vector store: %s", storeType)
+	}
+}
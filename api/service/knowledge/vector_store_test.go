@@ -0,0 +1,225 @@
+package knowledge
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+)
+
+func hitIDs(hits []SearchHit) []int64 {
+	ids := make([]int64, 0, len(hits))
+	for _, hit := range hits {
+		ids = append(ids, hit.ChunkID)
+	}
+	return ids
+}
+
+func TestMemoryStore(t *testing.T) {
+	testutil.SetupDB(t, &model.KnowledgeChunk{})
+	for _, chunk := range []*model.KnowledgeChunk{
+		{Eid: 1, KnowledgeBaseID: 1, DocumentID: 10, Embedding: "[1,0]"},
+		{Eid: 1, KnowledgeBaseID: 1, DocumentID: 10, Embedding: "[0.6,0.8]"},
+		{Eid: 1, KnowledgeBaseID: 1, DocumentID: 11, Embedding: "[0,1]"},
+		{Eid: 1, KnowledgeBaseID: 1, DocumentID: 11, Embedding: "[1,0,0]"},
+		{Eid: 1, KnowledgeBaseID: 2, DocumentID: 20, Embedding: "[1,0]"},
+	} {
+		if err := model.DB.Create(chunk).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store := NewMemoryStore()
+	// 尚未加载的知识库写入会被忽略，检索时以数据库为准
+	if err := store.Upsert(1, []VectorRecord{{ChunkID: 99, DocumentID: 12, Vector: []float32{1, 0}}}); err != nil {
+		t.Fatal(err)
+	}
+	hits, err := store.Search(1, []float32{1, 0}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(hitIDs(hits), []int64{1, 2}) || hits[0].Score != 1 || hits[1].DocumentID != 10 {
+		t.Fatalf("unexpected hits: %+v", hits)
+	}
+
+	// 已加载的知识库直接更新索引，维度不一致的向量不参与检索
+	if err := store.Upsert(1, []VectorRecord{{ChunkID: 3, DocumentID: 11, Vector: []float32{1, 0.1}}}); err != nil {
+		t.Fatal(err)
+	}
+	if hits, _ = store.Search(1, []float32{1, 0}, 0); !reflect.DeepEqual(hitIDs(hits), []int64{1, 3, 2}) {
+		t.Fatalf("after upsert: %+v", hits)
+	}
+	if err := store.DeleteByDocument(1, 10); err != nil {
+		t.Fatal(err)
+	}
+	if hits, _ = store.Search(1, []float32{1, 0}, 5); !reflect.DeepEqual(hitIDs(hits), []int64{3}) {
+		t.Fatalf("after delete document: %+v", hits)
+	}
+	if hits, _ = store.Search(1, []float32{0, 0}, 5); len(hits) != 0 {
+		t.Fatalf("zero query vector: %+v", hits)
+	}
+	if hits, _ = store.Search(2, []float32{1, 0}, 5); !reflect.DeepEqual(hitIDs(hits), []int64{5}) {
+		t.Fatalf("other knowledge base: %+v", hits)
+	}
+
+	// 删除知识库后索引重新从数据库加载
+	if err := store.DeleteByKnowledgeBase(1); err != nil {
+		t.Fatal(err)
+	}
+	if hits, _ = store.Search(1, []float32{1, 0}, 1); !reflect.DeepEqual(hitIDs(hits), []int64{1}) {
+		t.Fatalf("after reload: %+v", hits)
+	}
+}
+
+type qdrantRequest struct {
+	method, path string
+	body         map[string]interface{}
+}
+
+func newFakeQdrant(t *testing.T) (*httptest.Server, *[]qdrantRequest) {
+	var mu sync.Mutex
+	var requests []qdrantRequest
+	collections := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("api-key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var body map[string]interface{}
+		data, _ := io.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, qdrantRequest{r.Method, r.URL.RequestURI(), body})
+
+		name := strings.Split(strings.TrimPrefix(r.URL.Path, "/collections/"), "/")[0]
+		switch {
+		case r.Method == http.MethodPut && !strings.Contains(r.URL.Path, "/points"):
+			collections[name] = true
+		case r.Method == http.MethodDelete:
+			if !collections[name] {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			delete(collections, name)
+		case !collections[name]:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status":{"error":"Not found"}}`))
+			return
+		case strings.HasSuffix(r.URL.Path, "/points/search"):
+			w.Write([]byte(`{"result":[{"id":2,"score":0.9,"payload":{"document_id":10}},{"id":1,"score":0.5,"payload":{"document_id":11}}]}`))
+			return
+		}
+		w.Write([]byte(`{"result":true}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestQdrantStore(t *testing.T) {
+	server, requests := newFakeQdrant(t)
+	store := NewQdrantStore(server.URL+"/", "secret", "hub_")
+
+	// 集合不存在时检索返回空结果
+	hits, err := store.Search(1, []float32{1, 0}, 0)
+	if err != nil || len(hits) != 0 {
+		t.Fatalf("search missing collection = %+v, %v", hits, err)
+	}
+
+	records := []VectorRecord{{ChunkID: 1, DocumentID: 11, Vector: []float32{1, 0}}, {ChunkID: 2, DocumentID: 10, Vector: []float32{0, 1}}}
+	if err := store.Upsert(1, records); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Upsert(1, records[:1]); err != nil {
+		t.Fatal(err)
+	}
+	hits, err = store.Search(1, []float32{1, 0}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 2 || hits[0] != (SearchHit{ChunkID: 2, DocumentID: 10, Score: 0.9}) {
+		t.Fatalf("unexpected hits: %+v", hits)
+	}
+	if err := store.DeleteByDocument(1, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteByKnowledgeBase(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteByKnowledgeBase(1); err != nil {
+		t.Fatalf("deleting a missing collection: %v", err)
+	}
+
+	var got []string
+	for _, r := range *requests {
+		got = append(got, r.method+" "+r.path)
+	}
+	want := []string{
+		"POST /collections/hub_1/points/search",
+		"GET /collections/hub_1",
+		"PUT /collections/hub_1",
+		"PUT /collections/hub_1/points?wait=true",
+		// 集合已确认存在，不再重复检查
+		"PUT /collections/hub_1/points?wait=true",
+		"POST /collections/hub_1/points/search",
+		"POST /collections/hub_1/points/delete?wait=true",
+		"DELETE /collections/hub_1",
+		"DELETE /collections/hub_1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("requests:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	create := (*requests)[2].body["vectors"].(map[string]interface{})
+	if create["size"] != float64(2) || create["distance"] != "Cosine" {
+		t.Fatalf("unexpected collection config: %+v", create)
+	}
+	search := (*requests)[5].body
+	if search["limit"] != float64(3) || search["with_payload"] != true {
+		t.Fatalf("unexpected search body: %+v", search)
+	}
+	filter, _ := json.Marshal((*requests)[6].body["filter"])
+	if string(filter) != `{"must":[{"key":"document_id","match":{"value":10}}]}` {
+		t.Fatalf("unexpected delete filter: %s", filter)
+	}
+
+	if _, err := NewQdrantStore(server.URL, "wrong", "hub_").Search(1, []float32{1}, 1); err == nil {
+		t.Fatal("request with a wrong api key should fail")
+	}
+}
+
+func TestFormatPgVector(t *testing.T) {
+	for _, tc := range []struct {
+		vector []float32
+		want   string
+	}{
+		{nil, "[]"},
+		{[]float32{0.1, -0.25, 3}, "[0.1,-0.25,3]"},
+		{[]float32{1e-7}, "[0.0000001]"},
+	} {
+		if got := formatPgVector(tc.vector); got != tc.want {
+			t.Errorf("formatPgVector(%v) = %s, want %s", tc.vector, got, tc.want)
+		}
+	}
+}
+
+func TestNewVectorStore(t *testing.T) {
+	if store, err := newVectorStore(""); err != nil {
+		t.Fatal(err)
+	} else if _, ok := store.(*MemoryStore); !ok {
+		t.Fatalf("default store = %T", store)
+	}
+	if _, err := newVectorStore("milvus"); err == nil {
+		t.Fatal("unknown store type should fail")
+	}
+	if _, err := NewPgVectorStore(""); err == nil {
+		t.Fatal("empty PGVECTOR_DSN should fail")
+	}
+}
@@ -0,0 +1,68 @@
+package ldap
+
+import (
+	"errors"
+
+	"github.com/53AI/53AIHub/model"
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+var (
+	ErrUserNotFound       = errors.New("ldap user not found")
+	ErrInvalidCredentials = errors.New("ldap invalid credentials")
+	ErrUserDisabled       = errors.New("ldap user is disabled")
+)
+
+// Authenticate 以成员条目的 DN 与密码绑定目录完成认证，认证通过后同步成员信息。
+// 目录中找不到登录名时返回 ErrUserNotFound，调用方可回退到本地密码登录
+func Authenticate(eid int64, login string, password string) (*model.User, error) {
+	cfg, err := LoadConfig(eid)
+	if err != nil {
+		return nil, err
+	}
+	// 空密码会被目录视为匿名绑定而成功
+	if login == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := dial(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entries, err := searchLogin(conn, cfg, login)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, ErrUserNotFound
+	}
+	if len(entries) > 1 {
+		return nil, errors.New("ldap login matches multiple entries")
+	}
+	entry := entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if goldap.IsErrorWithCode(err, goldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+	if entry.Disabled {
+		return nil, ErrUserDisabled
+	}
+
+	s, err := newSyncer(eid, cfg)
+	if err != nil {
+		return nil, err
+	}
+	user, _, _, err := s.upsertUser(entry, cfg.AutoCreateUser)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status == model.UserStatusDisabled {
+		return nil, ErrUserDisabled
+	}
+	return user, nil
+}
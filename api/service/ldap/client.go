@@ -0,0 +1,223 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+const (
+	dialTimeout = 10 * time.Second
+	pageSize    = 500
+
+	// Active Directory userAccountControl 中的 ACCOUNTDISABLE 标志位
+	adAccountDisable = 0x2
+)
+
+// Entry 目录中的成员条目
+type Entry struct {
+	DN       string
+	ID       string
+	Login    string
+	Name     string
+	Email    string
+	Mobile   string
+	Disabled bool
+	MemberOf []string
+}
+
+// connect 建立目录连接，按配置启用 StartTLS，测试中替换为内存目录
+var connect = func(cfg *Config) (goldap.Client, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+	if u, err := url.Parse(cfg.URL); err == nil {
+		tlsConfig.ServerName = u.Hostname()
+	}
+	conn, err := goldap.DialURL(cfg.URL,
+		goldap.DialWithTLSConfig(tlsConfig),
+		goldap.DialWithDialer(&net.Dialer{Timeout: dialTimeout}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(dialTimeout)
+	if cfg.StartTLS && strings.HasPrefix(cfg.URL, "ldap://") {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// dial 连接目录并以服务账号绑定
+func dial(cfg *Config) (goldap.Client, error) {
+	conn, err := connect(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.BindDN != "" {
+		err = conn.Bind(cfg.BindDN, cfg.BindPassword)
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ldap service bind: %w", err)
+	}
+	return conn, nil
+}
+
+func (c *Config) userAttributes() []string {
+	attributes := []string{"objectGUID", "entryUUID", "userAccountControl", "nsAccountLock", "pwdAccountLockedTime", "cn",
+		c.NameAttribute, c.EmailAttribute, c.MobileAttribute, c.MemberOfAttribute}
+	if c.IDAttribute != "" {
+		attributes = append(attributes, c.IDAttribute)
+	}
+	return append(attributes, c.LoginAttributes...)
+}
+
+// searchUsers 分页搜索成员，filter 为空时返回全部成员
+func searchUsers(conn goldap.Client, cfg *Config, filter string, sizeLimit int) ([]*Entry, error) {
+	if filter == "" {
+		filter = cfg.UserFilter
+	} else {
+		filter = "(&" + cfg.UserFilter + filter + ")"
+	}
+	request := goldap.NewSearchRequest(cfg.UserBaseDN, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases,
+		sizeLimit, 0, false, filter, cfg.userAttributes(), nil)
+
+	var (
+		result *goldap.SearchResult
+		err    error
+	)
+	if sizeLimit > 0 {
+		result, err = conn.Search(request)
+	} else {
+		result, err = conn.SearchWithPaging(request, pageSize)
+	}
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*Entry, 0, len(result.Entries))
+	for _, item := range result.Entries {
+		entries = append(entries, cfg.toEntry(item))
+	}
+	return entries, nil
+}
+
+// searchLogin 按登录名查找成员，登录名可匹配任一 LoginAttributes
+func searchLogin(conn goldap.Client, cfg *Config, login string) ([]*Entry, error) {
+	var filter strings.Builder
+	filter.WriteString("(|")
+	for _, attribute := range cfg.LoginAttributes {
+		filter.WriteString("(" + attribute + "=" + goldap.EscapeFilter(login) + ")")
+	}
+	filter.WriteString(")")
+	return searchUsers(conn, cfg, filter.String(), 2)
+}
+
+// searchDepartments 搜索组织单位
+func searchDepartments(conn goldap.Client, cfg *Config) ([]*goldap.Entry, error) {
+	request := goldap.NewSearchRequest(cfg.DepartmentBaseDN, goldap.ScopeWholeSubtree, goldap.NeverDerefAliases,
+		0, 0, false, cfg.DepartmentFilter, []string{"ou", "name"}, nil)
+	result, err := conn.SearchWithPaging(request, pageSize)
+	if err != nil {
+		return nil, err
+	}
+	return result.Entries, nil
+}
+
+func (c *Config) toEntry(item *goldap.Entry) *Entry {
+	entry := &Entry{
+		DN:       item.DN,
+		ID:       entryID(c, item),
+		Name:     item.GetAttributeValue(c.NameAttribute),
+		Email:    strings.TrimSpace(item.GetAttributeValue(c.EmailAttribute)),
+		Mobile:   normalizeMobile(item.GetAttributeValue(c.MobileAttribute)),
+		MemberOf: item.GetAttributeValues(c.MemberOfAttribute),
+		Disabled: isDisabled(item),
+	}
+	for _, attribute := range c.LoginAttributes {
+		if value := item.GetAttributeValue(attribute); value != "" {
+			entry.Login = value
+			break
+		}
+	}
+	if entry.Name == "" {
+		entry.Name = item.GetAttributeValue("cn")
+	}
+	return entry
+}
+
+// entryID objectGUID 为二进制值，转换为十六进制字符串
+func entryID(c *Config, item *goldap.Entry) string {
+	attributes := []string{"objectGUID", "entryUUID"}
+	if c.IDAttribute != "" {
+		attributes = []string{c.IDAttribute}
+	}
+	for _, attribute := range attributes {
+		if attribute == "objectGUID" {
+			if raw := item.GetRawAttributeValue(attribute); len(raw) > 0 {
+				return hex.EncodeToString(raw)
+			}
+			continue
+		}
+		if value := item.GetAttributeValue(attribute); value != "" {
+			return value
+		}
+	}
+	return normalizeDN(item.DN)
+}
+
+// isDisabled 识别 AD（userAccountControl）与 OpenLDAP / 389DS（pwdAccountLockedTime、nsAccountLock）的停用状态
+func isDisabled(item *goldap.Entry) bool {
+	if value := item.GetAttributeValue("userAccountControl"); value != "" {
+		if flags, err := strconv.ParseInt(value, 10, 64); err == nil && flags&adAccountDisable != 0 {
+			return true
+		}
+	}
+	if item.GetAttributeValue("pwdAccountLockedTime") != "" {
+		return true
+	}
+	return strings.EqualFold(item.GetAttributeValue("nsAccountLock"), "true")
+}
+
+// normalizeDN 统一 DN 的大小写与转义，用于比较与作为部门绑定值
+func normalizeDN(dn string) string {
+	parsed, err := goldap.ParseDN(dn)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(dn))
+	}
+	return strings.ToLower(parsed.String())
+}
+
+// parentDN 返回上级 DN（已规范化），已经是顶级时返回空字符串
+func parentDN(dn string) string {
+	parsed, err := goldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) < 2 {
+		return ""
+	}
+	parent := &goldap.DN{RDNs: parsed.RDNs[1:]}
+	return strings.ToLower(parent.String())
+}
+
+// normalizeMobile 将 +86 138 0013 8000 等格式转换为成员手机号格式
+func normalizeMobile(mobile string) string {
+	mobile = strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, mobile)
+	if len(mobile) == 13 && strings.HasPrefix(mobile, "86") {
+		mobile = mobile[2:]
+	}
+	return mobile
+}
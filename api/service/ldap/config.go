@@ -0,0 +1,128 @@
+package ldap
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/53AI/53AIHub/model"
+	"gorm.io/gorm"
+)
+
+var ErrNotEnabled = errors.New("ldap is not enabled")
+
+// Config 企业配置 ldap 的内容
+//
+// 示例（Active Directory）：
+//
+//	{"url":"ldaps://dc.example.com:636","bind_dn":"CN=svc-hub,OU=Service,DC=example,DC=com","bind_password":"xxx",
+//	 "base_dn":"DC=example,DC=com","user_filter":"(&(objectCategory=person)(objectClass=user))",
+//	 "sync_departments":true,"sync_interval":60,"auto_create_user":true,
+//	 "group_mapping":{"CN=Hub VIP,OU=Groups,DC=example,DC=com":3}}
+type Config struct {
+	URL                string `json:"url"` // ldap://host:389 或 ldaps://host:636
+	StartTLS           bool   `json:"start_tls"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	BindDN             string `json:"bind_dn"` // 用于搜索与同步的服务账号
+	BindPassword       string `json:"bind_password"`
+	BaseDN             string `json:"base_dn"`
+
+	// UserBaseDN / DepartmentBaseDN 为空时使用 BaseDN
+	UserBaseDN       string `json:"user_base_dn"`
+	DepartmentBaseDN string `json:"department_base_dn"`
+	UserFilter       string `json:"user_filter"`
+	DepartmentFilter string `json:"department_filter"`
+
+	// LoginAttributes 登录名可匹配的属性，依次为 AD 与 OpenLDAP 常用属性
+	LoginAttributes []string `json:"login_attributes"`
+	// IDAttribute 条目的唯一标识，AD 为 objectGUID，OpenLDAP 为 entryUUID，找不到时使用 DN
+	IDAttribute       string `json:"id_attribute"`
+	NameAttribute     string `json:"name_attribute"`
+	EmailAttribute    string `json:"email_attribute"`
+	MobileAttribute   string `json:"mobile_attribute"`
+	MemberOfAttribute string `json:"member_of_attribute"`
+
+	// SyncDepartments 按组织单位（OU）同步部门树，成员归属其所在的 OU
+	SyncDepartments bool `json:"sync_departments"`
+	// SyncInterval 定时同步间隔（分钟），0 表示只手动同步
+	SyncInterval int `json:"sync_interval"`
+	// AutoCreateUser 登录时目录中存在但尚未同步的成员自动创建
+	AutoCreateUser bool `json:"auto_create_user"`
+	// GroupMapping LDAP 组 DN 到用户组 ID 的映射，只维护映射中出现的用户组
+	GroupMapping map[string]int64 `json:"group_mapping"`
+}
+
+func (c *Config) withDefaults() {
+	c.URL = strings.TrimSpace(c.URL)
+	if c.UserBaseDN == "" {
+		c.UserBaseDN = c.BaseDN
+	}
+	if c.DepartmentBaseDN == "" {
+		c.DepartmentBaseDN = c.BaseDN
+	}
+	if c.UserFilter == "" {
+		c.UserFilter = "(|(&(objectCategory=person)(objectClass=user))(objectClass=inetOrgPerson))"
+	}
+	if c.DepartmentFilter == "" {
+		c.DepartmentFilter = "(objectClass=organizationalUnit)"
+	}
+	if len(c.LoginAttributes) == 0 {
+		c.LoginAttributes = []string{"sAMAccountName", "userPrincipalName", "uid", "mail"}
+	}
+	if c.NameAttribute == "" {
+		c.NameAttribute = "displayName"
+	}
+	if c.EmailAttribute == "" {
+		c.EmailAttribute = "mail"
+	}
+	if c.MobileAttribute == "" {
+		c.MobileAttribute = "mobile"
+	}
+	if c.MemberOfAttribute == "" {
+		c.MemberOfAttribute = "memberOf"
+	}
+}
+
+// ParseConfig 解析并校验 ldap 配置内容
+func ParseConfig(content string) (*Config, error) {
+	var cfg Config
+	if err := json.Unmarshal([]byte(content), &cfg); err != nil {
+		return nil, err
+	}
+	cfg.withDefaults()
+	if !strings.HasPrefix(cfg.URL, "ldap://") && !strings.HasPrefix(cfg.URL, "ldaps://") {
+		return nil, errors.New("ldap url must start with ldap:// or ldaps://")
+	}
+	if cfg.BaseDN == "" {
+		return nil, errors.New("ldap base_dn is required")
+	}
+	if cfg.SyncInterval < 0 {
+		return nil, errors.New("ldap sync_interval must not be negative")
+	}
+	return &cfg, nil
+}
+
+// LoadConfig 读取企业已启用的 ldap 配置
+func LoadConfig(eid int64) (*Config, error) {
+	var record model.EnterpriseConfig
+	err := model.DB.Where("eid = ? AND type = ?", eid, model.EnterpriseConfigTypeLDAP).First(&record).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotEnabled
+		}
+		return nil, err
+	}
+	if !record.Enabled {
+		return nil, ErrNotEnabled
+	}
+	return ParseConfig(record.Content)
+}
+
+// GetEnabledEids 返回启用了 ldap 的企业
+func GetEnabledEids() ([]int64, error) {
+	var eids []int64
+	err := model.DB.Model(&model.EnterpriseConfig{}).
+		Where("type = ? AND enabled = ?", model.EnterpriseConfigTypeLDAP, true).
+		Pluck("eid", &eids).Error
+	return eids, err
+}
@@ -0,0 +1,269 @@
+package ldap
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+	goldap "github.com/go-ldap/ldap/v3"
+)
+
+const (
+	testBaseDN       = "dc=example,dc=com"
+	testBindDN       = "cn=admin,dc=example,dc=com"
+	testBindPassword = "admin"
+)
+
+// testDirectory 内存目录服务，替换 connect 返回，支持 and / or / not / 相等 / 存在 过滤条件
+type testDirectory struct {
+	mu        sync.Mutex
+	entries   map[string]map[string][]string // dn -> 属性
+	passwords map[string]string              // dn -> 密码
+}
+
+func newTestDirectory(t *testing.T) *testDirectory {
+	d := &testDirectory{entries: map[string]map[string][]string{}, passwords: map[string]string{testBindDN: testBindPassword}}
+	previous := connect
+	connect = func(cfg *Config) (goldap.Client, error) { return &testConn{directory: d}, nil }
+	t.Cleanup(func() { connect = previous })
+	return d
+}
+
+func (d *testDirectory) add(dn string, attributes map[string][]string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries[dn] = attributes
+}
+
+func (d *testDirectory) remove(dn string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.entries, dn)
+}
+
+// testConn 目录连接，仅实现同步与登录用到的方法
+type testConn struct {
+	goldap.Client
+	directory *testDirectory
+}
+
+func (c *testConn) Bind(username, password string) error {
+	c.directory.mu.Lock()
+	defer c.directory.mu.Unlock()
+	if expected, ok := c.directory.passwords[username]; ok && password != "" && expected == password {
+		return nil
+	}
+	return goldap.NewError(goldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+}
+
+func (c *testConn) UnauthenticatedBind(username string) error {
+	return nil
+}
+
+func (c *testConn) Close() error {
+	return nil
+}
+
+func (c *testConn) Search(request *goldap.SearchRequest) (*goldap.SearchResult, error) {
+	c.directory.mu.Lock()
+	defer c.directory.mu.Unlock()
+	result := &goldap.SearchResult{}
+	for dn, attributes := range c.directory.entries {
+		if !strings.HasSuffix(strings.ToLower(dn), strings.ToLower(request.BaseDN)) || !matchFilter(request.Filter, attributes) {
+			continue
+		}
+		result.Entries = append(result.Entries, goldap.NewEntry(dn, attributes))
+	}
+	return result, nil
+}
+
+func (c *testConn) SearchWithPaging(request *goldap.SearchRequest, pagingSize uint32) (*goldap.SearchResult, error) {
+	return c.Search(request)
+}
+
+func matchFilter(filter string, attributes map[string][]string) bool {
+	matched, _ := evalFilter(filter, attributes)
+	return matched
+}
+
+// evalFilter 计算以 "(" 开头的过滤条件，返回结果与剩余未解析的部分
+func evalFilter(filter string, attributes map[string][]string) (bool, string) {
+	filter = filter[1:]
+	switch filter[0] {
+	case '&', '|':
+		and := filter[0] == '&'
+		matched := and
+		filter = filter[1:]
+		for filter[0] == '(' {
+			var ok bool
+			ok, filter = evalFilter(filter, attributes)
+			if and {
+				matched = matched && ok
+			} else {
+				matched = matched || ok
+			}
+		}
+		return matched, filter[1:]
+	case '!':
+		ok, rest := evalFilter(filter[1:], attributes)
+		return !ok, rest[1:]
+	}
+	end := strings.IndexByte(filter, ')')
+	name, value, _ := strings.Cut(filter[:end], "=")
+	values := attributeValues(attributes, name)
+	if value == "*" {
+		return len(values) > 0, filter[end+1:]
+	}
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true, filter[end+1:]
+		}
+	}
+	return false, filter[end+1:]
+}
+
+func attributeValues(attributes map[string][]string, name string) []string {
+	for key, values := range attributes {
+		if strings.EqualFold(key, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+func setupDB(t *testing.T, d *testDirectory) {
+	db := testutil.SetupDB(t, &model.EnterpriseConfig{}, &model.User{}, &model.MemberBinding{}, &model.Group{},
+		&model.ResourcePermission{}, &model.Department{}, &model.MemberDepartmentRelation{})
+	content, _ := json.Marshal(Config{
+		URL:             "ldap://ldap.example.com",
+		BindDN:          testBindDN,
+		BindPassword:    testBindPassword,
+		BaseDN:          testBaseDN,
+		UserFilter:      "(objectClass=inetOrgPerson)",
+		LoginAttributes: []string{"uid", "mail"},
+		SyncDepartments: true,
+		AutoCreateUser:  true,
+		GroupMapping:    map[string]int64{"CN=VIP,OU=Groups,DC=example,DC=com": 7},
+	})
+	db.Create(&model.EnterpriseConfig{EID: 1, Type: model.EnterpriseConfigTypeLDAP, Enabled: true, Content: string(content)})
+	db.Create(&model.Group{GroupId: 7, Eid: 1, GroupName: "vip"})
+
+	d.add("ou=Engineering,"+testBaseDN, map[string][]string{"objectClass": {"organizationalUnit"}, "ou": {"Engineering"}})
+	d.add("ou=Backend,ou=Engineering,"+testBaseDN, map[string][]string{"objectClass": {"organizationalUnit"}, "ou": {"Backend"}})
+	d.add("uid=alice,ou=Backend,ou=Engineering,"+testBaseDN, map[string][]string{
+		"objectClass": {"inetOrgPerson"},
+		"entryUUID":   {"uuid-alice"},
+		"uid":         {"alice"},
+		"displayName": {"Alice"},
+		"mail":        {"alice@example.com"},
+		"memberOf":    {"cn=vip,ou=groups,dc=example,dc=com"},
+	})
+	d.add("uid=bob,ou=Engineering,"+testBaseDN, map[string][]string{
+		"objectClass":   {"inetOrgPerson"},
+		"entryUUID":     {"uuid-bob"},
+		"uid":           {"bob"},
+		"displayName":   {"Bob"},
+		"mail":          {"bob@example.com"},
+		"nsAccountLock": {"TRUE"},
+	})
+	d.passwords["uid=alice,ou=Backend,ou=Engineering,"+testBaseDN] = "alice-secret"
+	d.passwords["uid=bob,ou=Engineering,"+testBaseDN] = "bob-secret"
+}
+
+func getUser(t *testing.T, email string) model.User {
+	user, err := model.GetUserByEmail(1, email)
+	if err != nil {
+		t.Fatalf("user %s: %v", email, err)
+	}
+	return user
+}
+
+func TestSyncDirectory(t *testing.T) {
+	d := newTestDirectory(t)
+	setupDB(t, d)
+
+	result, err := Sync(1)
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Departments != 2 || result.Created != 2 {
+		t.Fatalf("unexpected result: %+v", *result)
+	}
+
+	departments, _ := model.GetDepartmentsByEID(1, model.DepartmentFromLDAP)
+	byName := map[string]*model.Department{}
+	for _, department := range departments {
+		byName[department.Name] = department
+	}
+	if byName["Engineering"] == nil || byName["Backend"] == nil || byName["Backend"].PDID != byName["Engineering"].DID {
+		t.Fatalf("department tree not built from OUs: %+v", departments)
+	}
+
+	alice := getUser(t, "alice@example.com")
+	if alice.Nickname != "Alice" || alice.Status == model.UserStatusDisabled {
+		t.Fatalf("unexpected alice: %+v", alice)
+	}
+	binding, _ := model.GetMemberBindingByBindValue(1, "uuid-alice", model.MemberBindingSourceLDAP)
+	dids, _ := model.GetMemberDidsByBID(1, binding.ID)
+	if len(dids) != 1 || dids[0] != byName["Backend"].DID {
+		t.Fatalf("alice should belong to Backend: %v", dids)
+	}
+	groups, _ := model.GetGroupsByUserID(alice.UserID)
+	if len(groups) != 1 || groups[0] != 7 {
+		t.Fatalf("alice groups from memberOf: %v", groups)
+	}
+	if bob := getUser(t, "bob@example.com"); bob.Status != model.UserStatusDisabled {
+		t.Fatalf("locked account should be disabled: %+v", bob)
+	}
+
+	// 成员从目录删除后再次同步
+	d.remove("uid=alice,ou=Backend,ou=Engineering," + testBaseDN)
+	if result, err = Sync(1); err != nil {
+		t.Fatalf("second sync: %v", err)
+	}
+	if result.Created != 0 || result.Disabled != 1 {
+		t.Fatalf("unexpected second result: %+v", *result)
+	}
+	if alice = getUser(t, "alice@example.com"); alice.Status != model.UserStatusDisabled {
+		t.Fatalf("removed entry should disable the user: %+v", alice)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	d := newTestDirectory(t)
+	setupDB(t, d)
+
+	user, err := Authenticate(1, "alice", "alice-secret")
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if user.Email != "alice@example.com" {
+		t.Fatalf("unexpected user: %+v", user)
+	}
+	again, err := Authenticate(1, "alice@example.com", "alice-secret")
+	if err != nil || again.UserID != user.UserID {
+		t.Fatalf("login by mail should resolve the same user: %v %v", again, err)
+	}
+
+	cases := []struct {
+		login, password string
+		want            error
+	}{
+		{"alice", "wrong", ErrInvalidCredentials},
+		{"alice", "", ErrInvalidCredentials},
+		{"carol", "secret", ErrUserNotFound},
+		{"bob", "bob-secret", ErrUserDisabled},
+	}
+	for _, c := range cases {
+		if _, err := Authenticate(1, c.login, c.password); err != c.want {
+			t.Fatalf("%s: expected %v, got %v", c.login, c.want, err)
+		}
+	}
+	if _, err := Authenticate(2, "alice", "alice-secret"); err != ErrNotEnabled {
+		t.Fatalf("expected %v, got %v", ErrNotEnabled, err)
+	}
+}
@@ -0,0 +1,413 @@
+package ldap
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/common/utils/helper"
+	"github.com/53AI/53AIHub/model"
+	goldap "github.com/go-ldap/ldap/v3"
+	"gorm.io/gorm"
+)
+
+var ErrUserNotProvisioned = errors.New("ldap user has not been synced to the enterprise")
+
+// SyncResult 一次目录同步的统计
+type SyncResult struct {
+	Departments int `json:"departments"`
+	Created     int `json:"created"`
+	Updated     int `json:"updated"`
+	Disabled    int `json:"disabled"`
+	Failed      int `json:"failed"`
+}
+
+type syncer struct {
+	eid         int64
+	cfg         *Config
+	departments map[string]int64 // 规范化的 OU DN -> did
+}
+
+func newSyncer(eid int64, cfg *Config) (*syncer, error) {
+	s := &syncer{eid: eid, cfg: cfg, departments: make(map[string]int64)}
+	departments, err := model.GetDepartmentsByEID(eid, model.DepartmentFromLDAP)
+	if err != nil {
+		return nil, err
+	}
+	for _, department := range departments {
+		s.departments[department.BindValue] = department.DID
+	}
+	return s, nil
+}
+
+// Sync 同步目录：按 OU 同步部门树，创建或更新成员，目录中已不存在的成员停用
+func Sync(eid int64) (*SyncResult, error) {
+	cfg, err := LoadConfig(eid)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dial(cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	s, err := newSyncer(eid, cfg)
+	if err != nil {
+		return nil, err
+	}
+	result := &SyncResult{}
+	if cfg.SyncDepartments {
+		if err := s.syncDepartments(conn, result); err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err := searchUsers(conn, cfg, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[int64]bool, len(entries))
+	for _, entry := range entries {
+		if entry.ID == "" {
+			continue
+		}
+		_, binding, created, err := s.upsertUser(entry, true)
+		if err != nil {
+			result.Failed++
+			logger.SysErrorf("ldap sync user failed: eid=%d dn=%s err=%v", eid, entry.DN, err)
+			continue
+		}
+		seen[binding.ID] = true
+		if created {
+			result.Created++
+		} else {
+			result.Updated++
+		}
+	}
+
+	disabled, err := s.disableMissingUsers(seen)
+	if err != nil {
+		return nil, err
+	}
+	result.Disabled = disabled
+	return result, nil
+}
+
+// syncDepartments 上级 OU 先于下级创建；目录中已删除的 OU 对应部门一并删除
+func (s *syncer) syncDepartments(conn goldap.Client, result *SyncResult) error {
+	items, err := searchDepartments(conn, s.cfg)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return strings.Count(normalizeDN(items[i].DN), ",") < strings.Count(normalizeDN(items[j].DN), ",")
+	})
+
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		dn := normalizeDN(item.DN)
+		name := item.GetAttributeValue("ou")
+		if name == "" {
+			name = item.GetAttributeValue("name")
+		}
+		if name == "" || seen[dn] {
+			continue
+		}
+		seen[dn] = true
+		pdid := s.departments[parentDN(dn)]
+
+		if did, ok := s.departments[dn]; ok {
+			department, err := model.GetDepartmentByID(s.eid, did)
+			if err != nil {
+				return err
+			}
+			if department.Name != name || department.PDID != pdid {
+				department.Name, department.PDID = name, pdid
+				if err := model.UpdateDepartment(department); err != nil {
+					return err
+				}
+			}
+		} else {
+			department := &model.Department{EID: s.eid, PDID: pdid, Name: name, From: model.DepartmentFromLDAP}
+			if err := model.CreateDepartment(department); err != nil {
+				return err
+			}
+			// CreateDepartment 会将 bindvalue 设置为部门ID，这里改为 OU 的 DN
+			if err := model.DB.Model(department).Update("bindvalue", dn).Error; err != nil {
+				return err
+			}
+			s.departments[dn] = department.DID
+		}
+		result.Departments++
+	}
+
+	// 由深到浅删除已不存在的 OU
+	missing := make([]string, 0)
+	for dn := range s.departments {
+		if !seen[dn] {
+			missing = append(missing, dn)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		return strings.Count(missing[i], ",") > strings.Count(missing[j], ",")
+	})
+	for _, dn := range missing {
+		if err := model.DeleteDepartment(s.eid, s.departments[dn], true); err != nil {
+			logger.SysErrorf("ldap sync delete department failed: eid=%d dn=%s err=%v", s.eid, dn, err)
+		}
+		delete(s.departments, dn)
+	}
+	return nil
+}
+
+// upsertUser 按条目 ID 查找已同步的成员；未同步时按邮箱、手机号关联已有成员，allowCreate 时创建新成员。
+// 之后更新成员属性、停用状态、所属部门与用户组
+func (s *syncer) upsertUser(entry *Entry, allowCreate bool) (*model.User, *model.MemberBinding, bool, error) {
+	binding, err := model.GetMemberBindingByBindValue(s.eid, entry.ID, model.MemberBindingSourceLDAP)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	var user *model.User
+	if binding != nil {
+		if user, err = s.findUser("user_id = ?", binding.MID); err != nil {
+			return nil, nil, false, err
+		}
+	}
+	created := false
+	if user == nil {
+		if user, err = s.findUserByContact(entry); err != nil {
+			return nil, nil, false, err
+		}
+	}
+	if user == nil {
+		if !allowCreate {
+			return nil, nil, false, ErrUserNotProvisioned
+		}
+		if user, err = s.createUser(entry); err != nil {
+			return nil, nil, false, err
+		}
+		created = true
+	}
+
+	if binding, err = s.saveUser(user, binding, entry); err != nil {
+		return nil, nil, false, err
+	}
+	if s.cfg.SyncDepartments {
+		if err := s.saveDepartments(binding, entry); err != nil {
+			return nil, nil, false, err
+		}
+	}
+	if err := s.saveGroups(user, entry); err != nil {
+		return nil, nil, false, err
+	}
+	return user, binding, created, nil
+}
+
+func (s *syncer) findUser(query string, args ...interface{}) (*model.User, error) {
+	var user model.User
+	err := model.DB.Where("eid = ? AND type = ?", s.eid, model.UserTypeInternal).Where(query, args...).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (s *syncer) findUserByContact(entry *Entry) (*model.User, error) {
+	if helper.IsValidEmail(entry.Email) {
+		if user, err := s.findUser("email = ?", entry.Email); err != nil || user != nil {
+			return user, err
+		}
+	}
+	if helper.IsValidPhone(entry.Mobile) {
+		return s.findUser("mobile = ?", entry.Mobile)
+	}
+	return nil, nil
+}
+
+// createUser 用户名优先使用邮箱，其次手机号，最后使用登录名
+func (s *syncer) createUser(entry *Entry) (*model.User, error) {
+	user := &model.User{
+		Password: helper.RandomString(16), // 目录成员通过 LDAP 认证登录
+		Eid:      s.eid,
+		Type:     model.UserTypeInternal,
+		Role:     model.RoleCommonUser,
+		Nickname: entry.Name,
+	}
+	if helper.IsValidEmail(entry.Email) && !s.contactUsed("email", entry.Email, 0) {
+		user.Email = entry.Email
+	}
+	if helper.IsValidPhone(entry.Mobile) && !s.contactUsed("mobile", entry.Mobile, 0) {
+		user.Mobile = entry.Mobile
+	}
+	switch {
+	case user.Email != "":
+		user.Username = user.Email
+	case user.Mobile != "":
+		user.Username = user.Mobile
+	default:
+		user.Username = entry.Login
+	}
+	if user.Username == "" {
+		return nil, errors.New("ldap entry has no usable username")
+	}
+	if user.Nickname == "" {
+		user.Nickname = user.Username
+	}
+	if err := user.Create(); err != nil {
+		return nil, err
+	}
+	err := model.CreateMemberBinding(&model.MemberBinding{
+		MID:       user.UserID,
+		EID:       s.eid,
+		Name:      user.Nickname,
+		BindValue: strconv.FormatInt(user.UserID, 10),
+		Status:    model.MemberBindingStatusActive,
+		From:      model.DepartmentFromBackend,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *syncer) contactUsed(column string, value string, excludeUserID int64) bool {
+	var count int64
+	model.DB.Model(&model.User{}).Where("eid = ? AND "+column+" = ? AND user_id <> ?", s.eid, value, excludeUserID).Count(&count)
+	return count > 0
+}
+
+// saveUser 更新成员属性与状态，并保存 LDAP 来源的成员绑定；
+// 只恢复由目录停用的成员，管理员在后台停用的成员保持停用
+func (s *syncer) saveUser(user *model.User, binding *model.MemberBinding, entry *Entry) (*model.MemberBinding, error) {
+	if entry.Name != "" {
+		user.Nickname = entry.Name
+	}
+	if helper.IsValidEmail(entry.Email) && !s.contactUsed("email", entry.Email, user.UserID) {
+		user.Email = entry.Email
+	}
+	if helper.IsValidPhone(entry.Mobile) && !s.contactUsed("mobile", entry.Mobile, user.UserID) {
+		user.Mobile = entry.Mobile
+	}
+	bindingStatus := model.MemberBindingStatusActive
+	if entry.Disabled {
+		user.Status = model.UserStatusDisabled
+		bindingStatus = model.MemberBindingStatusDisabled
+	} else if user.Status == model.UserStatusDisabled && binding != nil && binding.Status == model.MemberBindingStatusDisabled {
+		user.Status = model.UserStatusNotJoined
+		if user.LastLoginTime > 0 {
+			user.Status = model.UserStatusJoined
+		}
+	}
+	err := model.DB.Model(user).Updates(map[string]interface{}{
+		"nickname": user.Nickname,
+		"email":    user.Email,
+		"mobile":   user.Mobile,
+		"status":   user.Status,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if binding == nil {
+		binding = &model.MemberBinding{
+			MID:       user.UserID,
+			EID:       s.eid,
+			Name:      user.Nickname,
+			BindValue: entry.ID,
+			Status:    bindingStatus,
+			From:      model.MemberBindingSourceLDAP,
+		}
+		return binding, model.CreateMemberBinding(binding)
+	}
+	binding.MID = user.UserID
+	binding.Name = user.Nickname
+	binding.Status = bindingStatus
+	err = model.DB.Model(binding).Updates(map[string]interface{}{
+		"mid":    binding.MID,
+		"name":   binding.Name,
+		"status": binding.Status,
+	}).Error
+	return binding, err
+}
+
+// saveDepartments 成员归属其条目所在的 OU
+func (s *syncer) saveDepartments(binding *model.MemberBinding, entry *Entry) error {
+	did := s.departments[parentDN(entry.DN)]
+	return model.DB.Transaction(func(tx *gorm.DB) error {
+		var existing []int64
+		err := tx.Model(&model.MemberDepartmentRelation{}).
+			Where("eid = ? AND bid = ? AND `from` = ?", s.eid, binding.ID, model.MemberDepartmentRelationFromLDAP).
+			Pluck("did", &existing).Error
+		if err != nil {
+			return err
+		}
+		if len(existing) == 1 && existing[0] == did {
+			return nil
+		}
+		err = tx.Where("eid = ? AND bid = ? AND `from` = ?", s.eid, binding.ID, model.MemberDepartmentRelationFromLDAP).
+			Delete(&model.MemberDepartmentRelation{}).Error
+		if err != nil || did == 0 {
+			return err
+		}
+		return tx.Create(&model.MemberDepartmentRelation{
+			EID:  s.eid,
+			BID:  binding.ID,
+			DID:  did,
+			From: model.MemberDepartmentRelationFromLDAP,
+		}).Error
+	})
+}
+
+// saveGroups 按 memberOf 与组映射维护成员所属用户组
+func (s *syncer) saveGroups(user *model.User, entry *Entry) error {
+	if len(s.cfg.GroupMapping) == 0 {
+		return nil
+	}
+	memberOf := make(map[string]bool, len(entry.MemberOf))
+	for _, dn := range entry.MemberOf {
+		memberOf[normalizeDN(dn)] = true
+	}
+	managed := make([]int64, 0, len(s.cfg.GroupMapping))
+	wanted := make(map[int64]bool)
+	for dn, groupID := range s.cfg.GroupMapping {
+		managed = append(managed, groupID)
+		if memberOf[normalizeDN(dn)] {
+			wanted[groupID] = true
+		}
+	}
+	return model.SyncUserGroups(s.eid, user.UserID, managed, wanted)
+}
+
+// disableMissingUsers 停用目录中已不存在的成员
+func (s *syncer) disableMissingUsers(seen map[int64]bool) (int, error) {
+	bindings, err := model.GetMemberBindingsBySource(s.eid, model.MemberBindingSourceLDAP)
+	if err != nil {
+		return 0, err
+	}
+	disabled := 0
+	for _, binding := range bindings {
+		if seen[binding.ID] || binding.Status == model.MemberBindingStatusDisabled {
+			continue
+		}
+		err := model.DB.Transaction(func(tx *gorm.DB) error {
+			err := tx.Model(&model.User{}).Where("eid = ? AND user_id = ?", s.eid, binding.MID).
+				Update("status", model.UserStatusDisabled).Error
+			if err != nil {
+				return err
+			}
+			return tx.Model(binding).Update("status", model.MemberBindingStatusDisabled).Error
+		})
+		if err != nil {
+			return disabled, err
+		}
+		disabled++
+	}
+	return disabled, nil
+}
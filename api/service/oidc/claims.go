@@ -0,0 +1,101 @@
+package oidc
+
+import (
+	"strings"
+)
+
+// Claims ID Token 与 userinfo 合并后的 claim
+type Claims map[string]interface{}
+
+func (c Claims) Subject() string {
+	return c.String("sub")
+}
+
+// lookup 支持以点分隔的嵌套 claim，如 realm_access.roles
+func (c Claims) lookup(path string) (interface{}, bool) {
+	if value, ok := c[path]; ok {
+		return value, true
+	}
+	var current interface{} = map[string]interface{}(c)
+	for _, part := range strings.Split(path, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[part]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func (c Claims) String(path string) string {
+	value, _ := c.lookup(path)
+	s, _ := value.(string)
+	return strings.TrimSpace(s)
+}
+
+// Bool 兼容部分身份提供方以字符串返回的布尔值
+func (c Claims) Bool(path string) bool {
+	value, _ := c.lookup(path)
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return strings.EqualFold(v, "true")
+	}
+	return false
+}
+
+// Strings 读取字符串数组 claim，单个字符串视为只有一个元素
+func (c Claims) Strings(path string) []string {
+	value, _ := c.lookup(path)
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func claimOrDefault(claim string, defaultClaim string) string {
+	if claim != "" {
+		return claim
+	}
+	return defaultClaim
+}
+
+func (p *Provider) email(claims Claims) string {
+	return claims.String(claimOrDefault(p.EmailClaim, "email"))
+}
+
+// mobile 将 E.164 格式（+86 138 0013 8000）转换为成员手机号格式
+func (p *Provider) mobile(claims Claims) string {
+	mobile := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, claims.String(claimOrDefault(p.MobileClaim, "phone_number")))
+	if len(mobile) == 13 && strings.HasPrefix(mobile, "86") {
+		mobile = mobile[2:]
+	}
+	return mobile
+}
+
+func (p *Provider) name(claims Claims) string {
+	if name := claims.String(claimOrDefault(p.NameClaim, "name")); name != "" {
+		return name
+	}
+	return claims.String("preferred_username")
+}
@@ -0,0 +1,176 @@
+package oidc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/53AI/53AIHub/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrNotEnabled       = errors.New("oidc sso is not enabled")
+	ErrProviderNotFound = errors.New("oidc provider not found")
+)
+
+// Provider 企业配置中的一个 OpenID Connect 身份提供方
+//
+// 示例：
+//
+//	{"id":"okta","name":"Okta","issuer":"https://example.okta.com","client_id":"xxx","client_secret":"xxx",
+//	 "redirect_uri":"https://hub.example.com/sso/callback","scopes":["openid","profile","email","groups"],
+//	 "auto_create_user":true,"allowed_domains":["example.com"],
+//	 "department_claim":"departments","department_mapping":{"engineering":12},
+//	 "group_claim":"groups","group_mapping":{"hub-vip":3}}
+type Provider struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURI  string   `json:"redirect_uri"`
+	Scopes       []string `json:"scopes"`
+	// TokenAuthMethod 令牌端点的客户端认证方式：client_secret_basic（默认）或 client_secret_post
+	TokenAuthMethod string `json:"token_auth_method"`
+
+	// AutoCreateUser 首次登录且无法关联已有成员时自动创建内部成员
+	AutoCreateUser bool `json:"auto_create_user"`
+	// AllowedDomains 允许登录的邮箱域名，为空时不限制
+	AllowedDomains []string `json:"allowed_domains"`
+	// TrustEmail 身份提供方未返回 email_verified 时仍按邮箱关联已有成员
+	TrustEmail bool `json:"trust_email"`
+
+	// 成员属性对应的 claim，为空时使用标准 claim
+	EmailClaim  string `json:"email_claim"`
+	MobileClaim string `json:"mobile_claim"`
+	NameClaim   string `json:"name_claim"`
+
+	// DepartmentClaim / GroupClaim 中的值按映射分配部门与用户组，只维护映射中出现的部门与用户组
+	DepartmentClaim   string           `json:"department_claim"`
+	DepartmentMapping map[string]int64 `json:"department_mapping"`
+	GroupClaim        string           `json:"group_claim"`
+	GroupMapping      map[string]int64 `json:"group_mapping"`
+}
+
+// Config 企业配置 oidc 的内容
+type Config struct {
+	Providers []*Provider `json:"providers"`
+}
+
+// ProviderInfo 登录页展示的身份提供方信息，不包含密钥
+type ProviderInfo struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func (p *Provider) validate() error {
+	if p.ID == "" || p.Issuer == "" || p.ClientID == "" || p.RedirectURI == "" {
+		return fmt.Errorf("oidc provider %q requires id, issuer, client_id and redirect_uri", p.ID)
+	}
+	return nil
+}
+
+func (p *Provider) scopes() []string {
+	if len(p.Scopes) == 0 {
+		return []string{"openid", "profile", "email"}
+	}
+	for _, scope := range p.Scopes {
+		if scope == "openid" {
+			return p.Scopes
+		}
+	}
+	return append([]string{"openid"}, p.Scopes...)
+}
+
+func (p *Provider) displayName() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.ID
+}
+
+// allowsEmail 检查邮箱域名是否在允许范围内
+func (p *Provider) allowsEmail(email string) bool {
+	if len(p.AllowedDomains) == 0 {
+		return true
+	}
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, allowed := range p.AllowedDomains {
+		if strings.EqualFold(strings.TrimPrefix(allowed, "@"), domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseConfig 解析并校验 oidc 配置内容
+func ParseConfig(content string) (*Config, error) {
+	var cfg Config
+	if err := json.Unmarshal([]byte(content), &cfg); err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(cfg.Providers))
+	for _, provider := range cfg.Providers {
+		if err := provider.validate(); err != nil {
+			return nil, err
+		}
+		if seen[provider.ID] {
+			return nil, fmt.Errorf("duplicate oidc provider id %q", provider.ID)
+		}
+		seen[provider.ID] = true
+		provider.Issuer = strings.TrimRight(provider.Issuer, "/")
+	}
+	return &cfg, nil
+}
+
+// LoadConfig 读取企业已启用的 oidc 配置
+func LoadConfig(eid int64) (*Config, error) {
+	var record model.EnterpriseConfig
+	err := model.DB.Where("eid = ? AND type = ?", eid, model.EnterpriseConfigTypeOIDC).First(&record).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotEnabled
+		}
+		return nil, err
+	}
+	if !record.Enabled {
+		return nil, ErrNotEnabled
+	}
+	return ParseConfig(record.Content)
+}
+
+// GetProvider 获取企业已启用的身份提供方
+func GetProvider(eid int64, providerID string) (*Provider, error) {
+	cfg, err := LoadConfig(eid)
+	if err != nil {
+		return nil, err
+	}
+	for _, provider := range cfg.Providers {
+		if provider.ID == providerID {
+			return provider, nil
+		}
+	}
+	return nil, ErrProviderNotFound
+}
+
+// ListProviders 返回登录页可用的身份提供方
+func ListProviders(eid int64) ([]ProviderInfo, error) {
+	list := make([]ProviderInfo, 0)
+	cfg, err := LoadConfig(eid)
+	if err != nil {
+		if errors.Is(err, ErrNotEnabled) {
+			return list, nil
+		}
+		return nil, err
+	}
+	for _, provider := range cfg.Providers {
+		list = append(list, ProviderInfo{ID: provider.ID, Name: provider.displayName()})
+	}
+	return list, nil
+}
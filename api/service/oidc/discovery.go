@@ -0,0 +1,206 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 发现文档与公钥的缓存时间
+const metadataCacheTTL = time.Hour
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Metadata OpenID Provider 发现文档（/.well-known/openid-configuration）中使用的字段
+type Metadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint"`
+	JwksURI               string   `json:"jwks_uri"`
+	CodeChallengeMethods  []string `json:"code_challenge_methods_supported"`
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type issuerCache struct {
+	metadata  *Metadata
+	keys      map[string]interface{}
+	fetchedAt time.Time
+	keysAt    time.Time
+}
+
+var (
+	cacheMu sync.Mutex
+	caches  = map[string]*issuerCache{}
+)
+
+func getJSON(url string, v interface{}) error {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", url, resp.StatusCode)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// GetMetadata 获取身份提供方的发现文档，结果按 issuer 缓存
+func GetMetadata(issuer string) (*Metadata, error) {
+	cacheMu.Lock()
+	cache, ok := caches[issuer]
+	cacheMu.Unlock()
+	if ok && time.Since(cache.fetchedAt) < metadataCacheTTL {
+		return cache.metadata, nil
+	}
+
+	var metadata Metadata
+	if err := getJSON(issuer+"/.well-known/openid-configuration", &metadata); err != nil {
+		return nil, err
+	}
+	if metadata.Issuer != issuer {
+		return nil, fmt.Errorf("issuer mismatch: configured %s, discovered %s", issuer, metadata.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JwksURI == "" {
+		return nil, errors.New("incomplete openid configuration")
+	}
+
+	cacheMu.Lock()
+	caches[issuer] = &issuerCache{metadata: &metadata, fetchedAt: time.Now()}
+	cacheMu.Unlock()
+	return &metadata, nil
+}
+
+// getSigningKey 按 kid 查找签名公钥，找不到时重新拉取一次 JWKS 以支持密钥轮换
+func getSigningKey(issuer string, kid string) (interface{}, error) {
+	metadata, err := GetMetadata(issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheMu.Lock()
+	cache := caches[issuer]
+	keys, keysAt := cache.keys, cache.keysAt
+	cacheMu.Unlock()
+
+	if key := findKey(keys, kid); key != nil && time.Since(keysAt) < metadataCacheTTL {
+		return key, nil
+	}
+
+	keys, err = fetchKeys(metadata.JwksURI)
+	if err != nil {
+		return nil, err
+	}
+	cacheMu.Lock()
+	cache.keys, cache.keysAt = keys, time.Now()
+	cacheMu.Unlock()
+
+	if key := findKey(keys, kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("signing key %q not found", kid)
+}
+
+// findKey kid 为空且只有一个公钥时直接使用该公钥
+func findKey(keys map[string]interface{}, kid string) interface{} {
+	if key, ok := keys[kid]; ok {
+		return key
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key
+		}
+	}
+	return nil
+}
+
+func fetchKeys(jwksURI string) (map[string]interface{}, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := getJSON(jwksURI, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+	}
+}
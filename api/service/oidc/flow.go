@@ -0,0 +1,238 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ID Token 允许的签名算法
+var idTokenSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// LoginResult 授权码兑换后的身份信息
+type LoginResult struct {
+	Eid      int64
+	Provider *Provider
+	Claims   Claims
+}
+
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// codeChallenge PKCE S256：BASE64URL(SHA256(code_verifier))
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthorizeURL 生成授权地址，state、nonce 与 PKCE code_verifier 保存在服务端
+func AuthorizeURL(eid int64, provider *Provider) (string, error) {
+	metadata, err := GetMetadata(provider.Issuer)
+	if err != nil {
+		return "", err
+	}
+	state, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	err = saveState(state, &authState{
+		Eid:          eid,
+		ProviderID:   provider.ID,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		RedirectURI:  provider.RedirectURI,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", provider.ClientID)
+	query.Set("redirect_uri", provider.RedirectURI)
+	query.Set("scope", strings.Join(provider.scopes(), " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge(verifier))
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(metadata.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return metadata.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Exchange 校验 state 后用授权码兑换令牌，校验 ID Token 并合并 userinfo 中的 claim
+func Exchange(eid int64, providerID string, code string, state string) (*LoginResult, error) {
+	saved, err := consumeState(state)
+	if err != nil {
+		return nil, err
+	}
+	if saved.Eid != eid || saved.ProviderID != providerID {
+		return nil, ErrInvalidState
+	}
+	provider, err := GetProvider(eid, providerID)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := GetMetadata(provider.Issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := requestToken(metadata, provider, code, saved)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := verifyIDToken(provider, tokens.IDToken, saved.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	if metadata.UserinfoEndpoint != "" && tokens.AccessToken != "" {
+		// userinfo 仅用于补充 claim，获取失败时只使用 ID Token
+		userinfo, err := fetchUserinfo(metadata.UserinfoEndpoint, tokens.AccessToken)
+		if err != nil {
+			logger.SysErrorf("oidc userinfo failed: provider=%s err=%v", provider.ID, err)
+		} else if sub, _ := userinfo["sub"].(string); sub != claims.Subject() {
+			// userinfo 的 sub 必须与 ID Token 一致
+			return nil, errors.New("userinfo subject mismatch")
+		} else {
+			// ID Token 中已有的 claim 优先
+			for key, value := range userinfo {
+				if _, ok := claims[key]; !ok {
+					claims[key] = value
+				}
+			}
+		}
+	}
+	return &LoginResult{Eid: eid, Provider: provider, Claims: claims}, nil
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	IDToken          string `json:"id_token"`
+	TokenType        string `json:"token_type"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func requestToken(metadata *Metadata, provider *Provider, code string, saved *authState) (*tokenResponse, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", saved.RedirectURI)
+	form.Set("code_verifier", saved.CodeVerifier)
+	form.Set("client_id", provider.ClientID)
+	if provider.TokenAuthMethod == "client_secret_post" {
+		form.Set("client_secret", provider.ClientSecret)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if provider.TokenAuthMethod != "client_secret_post" && provider.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(provider.ClientID), url.QueryEscape(provider.ClientSecret))
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	var tokens tokenResponse
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return nil, fmt.Errorf("token endpoint: status %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK || tokens.Error != "" {
+		return nil, fmt.Errorf("token endpoint: %s %s", tokens.Error, tokens.ErrorDescription)
+	}
+	if tokens.IDToken == "" {
+		return nil, errors.New("token endpoint did not return an id_token")
+	}
+	return &tokens, nil
+}
+
+// verifyIDToken 校验签名、iss、aud、exp、nonce 与 azp（OpenID Connect Core 3.1.3.7）
+func verifyIDToken(provider *Provider, rawIDToken string, nonce string) (Claims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return getSigningKey(provider.Issuer, kid)
+	},
+		jwt.WithValidMethods(idTokenSigningMethods),
+		jwt.WithIssuer(provider.Issuer),
+		jwt.WithAudience(provider.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+	if value, _ := claims["nonce"].(string); value != nonce {
+		return nil, errors.New("invalid id_token: nonce mismatch")
+	}
+	if aud, _ := claims.GetAudience(); len(aud) > 1 {
+		if azp, _ := claims["azp"].(string); azp != provider.ClientID {
+			return nil, errors.New("invalid id_token: azp mismatch")
+		}
+	}
+	result := Claims(claims)
+	if result.Subject() == "" {
+		return nil, errors.New("invalid id_token: missing sub")
+	}
+	return result, nil
+}
+
+func fetchUserinfo(endpoint string, accessToken string) (map[string]interface{}, error) {
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("userinfo endpoint: status %d", resp.StatusCode)
+	}
+	var userinfo map[string]interface{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&userinfo); err != nil {
+		return nil, err
+	}
+	return userinfo, nil
+}
@@ -0,0 +1,193 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockProvider 本地模拟的 OpenID Provider，授权请求直接签发授权码
+type mockProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]interface{}
+
+	mu    sync.Mutex
+	codes map[string]url.Values // code -> 授权请求参数
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockProvider{key: key, codes: map[string]url.Values{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(Metadata{
+			Issuer:                m.server.URL,
+			AuthorizationEndpoint: m.server.URL + "/authorize",
+			TokenEndpoint:         m.server.URL + "/token",
+			UserinfoEndpoint:      m.server.URL + "/userinfo",
+			JwksURI:               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": "test", "kty": "RSA", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		m.mu.Lock()
+		params, ok := m.codes[r.PostForm.Get("code")]
+		delete(m.codes, r.PostForm.Get("code"))
+		m.mu.Unlock()
+		clientID, secret, _ := r.BasicAuth()
+		if !ok || clientID != "hub" || secret != "secret" ||
+			codeChallenge(r.PostForm.Get("code_verifier")) != params.Get("code_challenge") ||
+			r.PostForm.Get("redirect_uri") != params.Get("redirect_uri") {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		claims := jwt.MapClaims{
+			"iss":   m.server.URL,
+			"aud":   "hub",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"iat":   time.Now().Unix(),
+			"nonce": params.Get("nonce"),
+		}
+		for k, v := range m.claims {
+			claims[k] = v
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "test"
+		idToken, _ := token.SignedString(key)
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "at", "id_token": idToken, "token_type": "Bearer"})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"sub": m.claims["sub"], "groups": []string{"hub-vip"}})
+	})
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// authorize 模拟用户在身份提供方完成登录，返回回调中的 code 与 state
+func (m *mockProvider) authorize(t *testing.T, authorizeURL string) (string, string) {
+	u, err := url.Parse(authorizeURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := u.Query()
+	if params.Get("code_challenge_method") != "S256" || params.Get("code_challenge") == "" {
+		t.Fatalf("authorize request without PKCE: %s", authorizeURL)
+	}
+	code, _ := randomToken()
+	m.mu.Lock()
+	m.codes[code] = params
+	m.mu.Unlock()
+	return code, params.Get("state")
+}
+
+func setupDB(t *testing.T, issuer string) {
+	db := testutil.SetupDB(t, &model.EnterpriseConfig{}, &model.User{}, &model.MemberBinding{}, &model.Group{},
+		&model.ResourcePermission{}, &model.Department{}, &model.MemberDepartmentRelation{})
+	content, _ := json.Marshal(Config{Providers: []*Provider{{
+		ID:             "mock",
+		Issuer:         issuer,
+		ClientID:       "hub",
+		ClientSecret:   "secret",
+		RedirectURI:    "https://hub.example.com/sso/callback",
+		AutoCreateUser: true,
+		AllowedDomains: []string{"example.com"},
+		GroupClaim:     "groups",
+		GroupMapping:   map[string]int64{"hub-vip": 7, "hub-basic": 8},
+	}}})
+	db.Create(&model.EnterpriseConfig{EID: 1, Type: model.EnterpriseConfigTypeOIDC, Enabled: true, Content: string(content)})
+	db.Create(&model.Group{GroupId: 7, Eid: 1, GroupName: "vip"})
+	db.Create(&model.Group{GroupId: 8, Eid: 1, GroupName: "basic"})
+}
+
+func login(t *testing.T, m *mockProvider) (*model.User, error) {
+	provider, err := GetProvider(1, "mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorizeURL, err := AuthorizeURL(1, provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := m.authorize(t, authorizeURL)
+	result, err := Exchange(1, "mock", code, state)
+	if err != nil {
+		return nil, err
+	}
+	return ResolveUser(result)
+}
+
+func TestLoginCreatesAndLinksUser(t *testing.T) {
+	m := newMockProvider(t)
+	setupDB(t, m.server.URL)
+	m.claims = map[string]interface{}{"sub": "u-1", "email": "alice@example.com", "email_verified": true, "name": "Alice"}
+
+	user, err := login(t, m)
+	if err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	if user.Username != "alice@example.com" || user.Nickname != "Alice" || user.Type != model.UserTypeInternal {
+		t.Fatalf("unexpected user: %+v", user)
+	}
+	groups, _ := model.GetGroupsByUserID(user.UserID)
+	if len(groups) != 1 || groups[0] != 7 {
+		t.Fatalf("groups from userinfo claim: %v", groups)
+	}
+
+	again, err := login(t, m)
+	if err != nil {
+		t.Fatalf("second login: %v", err)
+	}
+	if again.UserID != user.UserID {
+		t.Fatalf("second login created another user: %d != %d", again.UserID, user.UserID)
+	}
+
+	m.claims["sub"] = "u-2"
+	m.claims["email"] = "bob@other.com"
+	if _, err := login(t, m); err != ErrEmailNotAllowed {
+		t.Fatalf("expected ErrEmailNotAllowed, got %v", err)
+	}
+}
+
+func TestExchangeRejectsReplayedState(t *testing.T) {
+	m := newMockProvider(t)
+	setupDB(t, m.server.URL)
+	m.claims = map[string]interface{}{"sub": "u-1", "email": "alice@example.com"}
+
+	provider, _ := GetProvider(1, "mock")
+	authorizeURL, err := AuthorizeURL(1, provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state := m.authorize(t, authorizeURL)
+	if _, err := Exchange(1, "mock", code, state); err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	if _, err := Exchange(1, "mock", code, state); err != ErrInvalidState {
+		t.Fatalf("expected ErrInvalidState, got %v", err)
+	}
+}
@@ -0,0 +1,247 @@
+package oidc
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/53AI/53AIHub/common/utils/helper"
+	"github.com/53AI/53AIHub/model"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUserNotProvisioned = errors.New("no enterprise member is linked to this identity")
+	ErrUserDisabled       = errors.New("enterprise member is disabled")
+	ErrEmailNotAllowed    = errors.New("email domain is not allowed")
+	ErrAccountConflict    = errors.New("email or mobile is used by an account outside the enterprise")
+)
+
+// bindValue 身份在成员绑定中的标识，同一 issuer 下 sub 唯一
+func bindValue(provider *Provider, claims Claims) string {
+	return provider.Issuer + "|" + claims.Subject()
+}
+
+// ResolveUser 查找身份已绑定的成员；未绑定时按已验证的邮箱或手机号关联已有成员，
+// 仍未找到且开启自动创建时创建内部成员。之后按 claim 同步部门与用户组
+func ResolveUser(result *LoginResult) (*model.User, error) {
+	provider, claims := result.Provider, result.Claims
+	email := provider.email(claims)
+	if len(provider.AllowedDomains) > 0 && !provider.allowsEmail(email) {
+		return nil, ErrEmailNotAllowed
+	}
+
+	user, err := findBoundUser(result.Eid, bindValue(provider, claims))
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		if user, err = findUserByContact(result.Eid, provider, claims); err != nil {
+			return nil, err
+		}
+		if user == nil {
+			if !provider.AutoCreateUser {
+				return nil, ErrUserNotProvisioned
+			}
+			if user, err = createUser(result.Eid, provider, claims); err != nil {
+				return nil, err
+			}
+		}
+		err = model.CreateMemberBinding(&model.MemberBinding{
+			MID:       user.UserID,
+			EID:       result.Eid,
+			Name:      provider.displayName(),
+			BindValue: bindValue(provider, claims),
+			Status:    model.MemberBindingStatusActive,
+			From:      model.MemberBindingSourceOIDC,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if user.Status == model.UserStatusDisabled {
+		return nil, ErrUserDisabled
+	}
+	if err := syncDepartments(user, provider, claims); err != nil {
+		return nil, err
+	}
+	if err := syncGroups(user, provider, claims); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// findBoundUser 绑定的成员已被删除时清理失效的绑定
+func findBoundUser(eid int64, value string) (*model.User, error) {
+	binding, err := model.GetMemberBindingByBindValue(eid, value, model.MemberBindingSourceOIDC)
+	if err != nil || binding == nil {
+		return nil, err
+	}
+	var user model.User
+	err = model.DB.Where("eid = ? AND user_id = ? AND type = ?", eid, binding.MID, model.UserTypeInternal).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, model.DeleteMemberBinding(binding.ID)
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// findUserByContact 只有身份提供方声明已验证（或配置信任）时才按邮箱、手机号关联
+func findUserByContact(eid int64, provider *Provider, claims Claims) (*model.User, error) {
+	var (
+		user model.User
+		err  error
+	)
+	if email := provider.email(claims); email != "" && (provider.TrustEmail || claims.Bool("email_verified")) {
+		user, err = model.GetUserByEmail(eid, email)
+	} else if mobile := provider.mobile(claims); mobile != "" && claims.Bool("phone_number_verified") {
+		user, err = model.GetUserByMobile(eid, mobile)
+	} else {
+		return nil, nil
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if user.Type != model.UserTypeInternal {
+		return nil, ErrAccountConflict
+	}
+	return &user, nil
+}
+
+// createUser 自动创建内部成员，用户名优先使用邮箱，其次手机号
+func createUser(eid int64, provider *Provider, claims Claims) (*model.User, error) {
+	email, mobile := provider.email(claims), provider.mobile(claims)
+	if !helper.IsValidEmail(email) {
+		email = ""
+	}
+	if !helper.IsValidPhone(mobile) {
+		mobile = ""
+	}
+	username := email
+	if username == "" {
+		username = mobile
+	}
+	if username == "" {
+		return nil, ErrUserNotProvisioned
+	}
+	nickname := provider.name(claims)
+	if nickname == "" {
+		nickname = username
+	}
+
+	user := &model.User{
+		Username: username,
+		Nickname: nickname,
+		Email:    email,
+		Mobile:   mobile,
+		Password: helper.RandomString(16), // 单点登录成员不使用密码登录
+		Eid:      eid,
+		Type:     model.UserTypeInternal,
+		Role:     model.RoleCommonUser,
+	}
+	if err := user.Create(); err != nil {
+		if err.Error() == "email already exists" || err.Error() == "mobile already exists" {
+			return nil, ErrAccountConflict
+		}
+		return nil, err
+	}
+	err := model.CreateMemberBinding(&model.MemberBinding{
+		MID:       user.UserID,
+		EID:       eid,
+		Name:      user.Nickname,
+		BindValue: strconv.FormatInt(user.UserID, 10),
+		Status:    model.MemberBindingStatusActive,
+		From:      model.DepartmentFromBackend,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// mappedIDs 返回映射中出现的全部 ID 与当前 claim 命中的 ID
+func mappedIDs(mapping map[string]int64, values []string) (managed []int64, wanted map[int64]bool) {
+	wanted = make(map[int64]bool)
+	for _, value := range values {
+		if id, ok := mapping[value]; ok {
+			wanted[id] = true
+		}
+	}
+	seen := make(map[int64]bool, len(mapping))
+	for _, id := range mapping {
+		if !seen[id] {
+			seen[id] = true
+			managed = append(managed, id)
+		}
+	}
+	return managed, wanted
+}
+
+// syncDepartments 按 claim 维护后台部门关系，只增删映射中出现的部门
+func syncDepartments(user *model.User, provider *Provider, claims Claims) error {
+	if provider.DepartmentClaim == "" || len(provider.DepartmentMapping) == 0 {
+		return nil
+	}
+	managed, wanted := mappedIDs(provider.DepartmentMapping, claims.Strings(provider.DepartmentClaim))
+
+	return model.DB.Transaction(func(tx *gorm.DB) error {
+		binding, err := model.GetMemberBindingByDepartmentFromBackend(user.UserID, tx)
+		if err != nil {
+			return err
+		}
+		var existing []int64
+		err = tx.Model(&model.MemberDepartmentRelation{}).
+			Where("eid = ? AND bid = ? AND `from` = ? AND did IN ?", user.Eid, binding.ID, model.MemberDepartmentRelationFromBackend, managed).
+			Pluck("did", &existing).Error
+		if err != nil {
+			return err
+		}
+		has := make(map[int64]bool, len(existing))
+		for _, did := range existing {
+			has[did] = true
+			if !wanted[did] {
+				err := tx.Where("eid = ? AND bid = ? AND `from` = ? AND did = ?", user.Eid, binding.ID, model.MemberDepartmentRelationFromBackend, did).
+					Delete(&model.MemberDepartmentRelation{}).Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		var dids []int64
+		err = tx.Model(&model.Department{}).
+			Where("eid = ? AND `from` = ? AND did IN ?", user.Eid, model.DepartmentFromBackend, managed).
+			Pluck("did", &dids).Error
+		if err != nil {
+			return err
+		}
+		for _, did := range dids {
+			if wanted[did] && !has[did] {
+				relation := &model.MemberDepartmentRelation{
+					EID:  user.Eid,
+					BID:  binding.ID,
+					DID:  did,
+					From: model.MemberDepartmentRelationFromBackend,
+				}
+				if err := tx.Create(relation).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// syncGroups 按 claim 维护成员所属用户组，只增删映射中出现的用户组
+func syncGroups(user *model.User, provider *Provider, claims Claims) error {
+	if provider.GroupClaim == "" || len(provider.GroupMapping) == 0 {
+		return nil
+	}
+	managed, wanted := mappedIDs(provider.GroupMapping, claims.Strings(provider.GroupClaim))
+	return model.SyncUserGroups(user.Eid, user.UserID, managed, wanted)
+}
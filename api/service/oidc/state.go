@@ -0,0 +1,42 @@
+package oidc
+
+import (
+	"errors"
+	"time"
+
+	"github.com/53AI/53AIHub/common"
+)
+
+// 授权请求的有效期
+const stateTTL = 10 * time.Minute
+
+var ErrInvalidState = errors.New("oidc state is invalid or expired")
+
+// authState 发起授权时保存的上下文，回调时通过 state 取回并校验
+type authState struct {
+	Eid          int64  `json:"eid"`
+	ProviderID   string `json:"provider_id"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	RedirectURI  string `json:"redirect_uri"`
+}
+
+func stateKey(state string) string {
+	return "Api::OIDCState:" + state
+}
+
+func saveState(state string, data *authState) error {
+	return common.STATES.Save(stateKey(state), data, stateTTL)
+}
+
+// consumeState 取回并删除 state，每个 state 只能使用一次
+func consumeState(state string) (*authState, error) {
+	if state == "" {
+		return nil, ErrInvalidState
+	}
+	data := &authState{}
+	if err := common.STATES.Consume(stateKey(state), data); err != nil {
+		return nil, ErrInvalidState
+	}
+	return data, nil
+}
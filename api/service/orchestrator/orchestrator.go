@@ -0,0 +1,251 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/common/utils/helper"
+	"github.com/53AI/53AIHub/model"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+var ErrInvalidConfig = errors.New("invalid orchestrator config")
+
+const (
+	// DefaultMaxRounds 未配置时的最大调用轮数
+	DefaultMaxRounds = 3
+	// MaxRounds 允许配置的最大调用轮数
+	MaxRounds = 5
+	// MaxCalls 单次提问最多调用子智能体的次数
+	MaxCalls = 8
+
+	toolPrefix = "agent_"
+)
+
+// RouteFunc 携带子智能体工具请求编排智能体的模型，返回模型的回复（可能包含工具调用）
+type RouteFunc func(ctx context.Context, messages []relaymodel.Message, tools []relaymodel.Tool) (*relaymodel.Message, error)
+
+// CallFunc 调用子智能体回答问题
+type CallFunc func(ctx context.Context, agent *model.Agent, query string) (string, error)
+
+// Candidate 当前用户可调用的子智能体
+type Candidate struct {
+	Agent       *model.Agent
+	Description string
+}
+
+// Call 一次子智能体调用
+type Call struct {
+	AgentID   int64  `json:"agent_id"`
+	AgentName string `json:"agent_name"`
+	Query     string `json:"query"`
+	Answer    string `json:"answer"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Runner 执行编排：模型通过工具调用选择子智能体，子智能体的回答作为工具结果返回给模型，直到模型不再调用或达到轮数上限
+type Runner struct {
+	Route RouteFunc
+	Call  CallFunc
+}
+
+// Validate 校验编排配置：子智能体需为同一企业的普通应用智能体，不能是自身，也不能重复
+func Validate(cfg *model.OrchestratorConfig, eid int64, agentID int64) error {
+	if cfg.MaxRounds < 0 || cfg.MaxRounds > MaxRounds {
+		return fmt.Errorf("%w: max_rounds must be between 0 and %d", ErrInvalidConfig, MaxRounds)
+	}
+	if len(cfg.SubAgents) == 0 {
+		return fmt.Errorf("%w: at least one sub agent is required", ErrInvalidConfig)
+	}
+	seen := make(map[int64]bool, len(cfg.SubAgents))
+	for _, sub := range cfg.SubAgents {
+		if sub.AgentID == agentID {
+			return fmt.Errorf("%w: an agent cannot call itself", ErrInvalidConfig)
+		}
+		if seen[sub.AgentID] {
+			return fmt.Errorf("%w: duplicate sub agent %d", ErrInvalidConfig, sub.AgentID)
+		}
+		seen[sub.AgentID] = true
+		if strings.TrimSpace(sub.Description) == "" {
+			return fmt.Errorf("%w: sub agent %d requires a description", ErrInvalidConfig, sub.AgentID)
+		}
+		agent, err := model.GetAgentByID(eid, sub.AgentID)
+		if err != nil {
+			return fmt.Errorf("%w: sub agent %d not found", ErrInvalidConfig, sub.AgentID)
+		}
+		if agent.AgentType != model.AgentTypeApp {
+			return fmt.Errorf("%w: sub agent %d must be an app agent", ErrInvalidConfig, sub.AgentID)
+		}
+	}
+	return nil
+}
+
+// Candidates 过滤出已启用且用户所在分组有权使用的子智能体，isAdmin 为 true 时不检查分组
+func Candidates(cfg *model.OrchestratorConfig, eid int64, user *model.User, isAdmin bool) ([]*Candidate, error) {
+	var userGroupIDs []int64
+	if !isAdmin {
+		var err error
+		if userGroupIDs, err = user.GetUserGroupIds(); err != nil {
+			return nil, err
+		}
+	}
+	candidates := make([]*Candidate, 0, len(cfg.SubAgents))
+	for _, sub := range cfg.SubAgents {
+		agent, err := model.GetAgentByID(eid, sub.AgentID)
+		if err != nil || !agent.Enable || agent.AgentType != model.AgentTypeApp {
+			continue
+		}
+		if !isAdmin {
+			agentGroupIDs, err := agent.GetUserGroupIds()
+			if err != nil {
+				return nil, err
+			}
+			if !helper.HasIntersection(agentGroupIDs, userGroupIDs) {
+				continue
+			}
+		}
+		candidates = append(candidates, &Candidate{Agent: agent, Description: sub.Description})
+	}
+	return candidates, nil
+}
+
+// Tools 每个子智能体对应一个函数工具
+func Tools(candidates []*Candidate) []relaymodel.Tool {
+	tools := make([]relaymodel.Tool, 0, len(candidates))
+	for _, c := range candidates {
+		tools = append(tools, relaymodel.Tool{
+			Type: "function",
+			Function: relaymodel.Function{
+				Name:        toolName(c.Agent.AgentID),
+				Description: fmt.Sprintf("%s：%s", c.Agent.Name, c.Description),
+				Parameters: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"query": map[string]interface{}{
+							"type":        "string",
+							"description": "交给该智能体回答的完整问题，需包含必要的上下文",
+						},
+					},
+					"required": []string{"query"},
+				},
+			},
+		})
+	}
+	return tools
+}
+
+func toolName(agentID int64) string {
+	return toolPrefix + strconv.FormatInt(agentID, 10)
+}
+
+// Run 执行编排，返回子智能体调用记录；首轮请求模型失败时返回错误，之后的失败只结束编排
+func (r *Runner) Run(ctx context.Context, cfg *model.OrchestratorConfig, messages []relaymodel.Message, candidates []*Candidate) ([]Call, error) {
+	calls := make([]Call, 0)
+	if len(candidates) == 0 {
+		return calls, nil
+	}
+	byName := make(map[string]*Candidate, len(candidates))
+	for _, c := range candidates {
+		byName[toolName(c.Agent.AgentID)] = c
+	}
+	tools := Tools(candidates)
+	rounds := cfg.MaxRounds
+	if rounds == 0 {
+		rounds = DefaultMaxRounds
+	}
+
+	history := append(make([]relaymodel.Message, 0, len(messages)), messages...)
+	for round := 0; round < rounds && len(calls) < MaxCalls; round++ {
+		reply, err := r.Route(ctx, history, tools)
+		if err != nil {
+			if round == 0 {
+				return nil, err
+			}
+			logger.Errorf(ctx, "orchestrator route failed: %s", err.Error())
+			break
+		}
+		if len(reply.ToolCalls) == 0 {
+			break
+		}
+		history = append(history, relaymodel.Message{Role: "assistant", Content: reply.StringContent(), ToolCalls: reply.ToolCalls})
+		for _, tc := range reply.ToolCalls {
+			var call Call
+			if len(calls) >= MaxCalls {
+				call.Error = "too many sub agent calls"
+			} else if call = r.invoke(ctx, byName, tc, lastUserText(messages)); call.AgentID != 0 {
+				calls = append(calls, call)
+			}
+			result := call.Answer
+			if call.Error != "" {
+				result = "调用失败：" + call.Error
+			}
+			history = append(history, relaymodel.Message{Role: "tool", ToolCallId: tc.Id, Content: result})
+		}
+	}
+	return calls, nil
+}
+
+func (r *Runner) invoke(ctx context.Context, byName map[string]*Candidate, tc relaymodel.Tool, fallback string) Call {
+	candidate, ok := byName[tc.Function.Name]
+	if !ok {
+		return Call{Error: fmt.Sprintf("unknown agent %q", tc.Function.Name)}
+	}
+	call := Call{AgentID: candidate.Agent.AgentID, AgentName: candidate.Agent.Name, Query: toolQuery(tc.Function.Arguments)}
+	if call.Query == "" {
+		call.Query = fallback
+	}
+	answer, err := r.Call(ctx, candidate.Agent, call.Query)
+	if err != nil {
+		logger.Errorf(ctx, "orchestrator call agent %d failed: %s", call.AgentID, err.Error())
+		call.Error = err.Error()
+		return call
+	}
+	call.Answer = answer
+	return call
+}
+
+// toolQuery 解析工具参数中的 query，参数可能是 JSON 字符串或已解析的对象
+func toolQuery(arguments any) string {
+	var args struct {
+		Query string `json:"query"`
+	}
+	switch v := arguments.(type) {
+	case string:
+		_ = json.Unmarshal([]byte(v), &args)
+	case map[string]interface{}:
+		args.Query, _ = v["query"].(string)
+	}
+	return strings.TrimSpace(args.Query)
+}
+
+func lastUserText(messages []relaymodel.Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].StringContent()
+		}
+	}
+	return ""
+}
+
+// ContextPrompt 将子智能体的回答整理为编排智能体生成最终回答时的参考资料
+func ContextPrompt(calls []Call) string {
+	if len(calls) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("以下是各专业智能体针对用户问题给出的回答，请综合这些内容回答用户，不要提及调用过程：")
+	for i, call := range calls {
+		sb.WriteString(fmt.Sprintf("\n\n[%d] %s\n问题：%s\n", i+1, call.AgentName, call.Query))
+		if call.Error != "" {
+			sb.WriteString("（该智能体暂时无法回答）")
+		} else {
+			sb.WriteString(call.Answer)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,173 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+func setupAgents(t *testing.T) (hr, it, workflow *model.Agent) {
+	db := testutil.SetupDB(t, &model.Agent{}, &model.ResourcePermission{})
+	hr = &model.Agent{Eid: 1, Name: "HR", Enable: true}
+	it = &model.Agent{Eid: 1, Name: "IT", Enable: true}
+	workflow = &model.Agent{Eid: 1, Name: "报销流程", Enable: true, AgentType: model.AgentTypeWorkflow}
+	for _, a := range []*model.Agent{hr, it, workflow} {
+		if err := db.Create(a).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// HR 仅对分组 10 开放，IT 仅对分组 20 开放
+	for _, p := range []*model.ResourcePermission{
+		{GroupID: 10, ResourceID: hr.AgentID, ResourceType: model.ResourceTypeAgent, Permission: model.PermissionRead},
+		{GroupID: 20, ResourceID: it.AgentID, ResourceType: model.ResourceTypeAgent, Permission: model.PermissionRead},
+	} {
+		if err := db.Create(p).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return hr, it, workflow
+}
+
+func TestParseAndValidate(t *testing.T) {
+	hr, it, workflow := setupAgents(t)
+
+	cfg, err := model.ParseOrchestratorConfig(`{"orchestrator":{"sub_agents":[{"agent_id":1,"description":"人事问题"}],"max_rounds":2}}`)
+	if err != nil || len(cfg.SubAgents) != 1 || cfg.MaxRounds != 2 {
+		t.Fatalf("ParseOrchestratorConfig = %+v, %v", cfg, err)
+	}
+	if cfg, err := model.ParseOrchestratorConfig(""); err != nil || len(cfg.SubAgents) != 0 {
+		t.Fatalf("empty config = %+v, %v", cfg, err)
+	}
+
+	valid := &model.OrchestratorConfig{SubAgents: []model.SubAgent{
+		{AgentID: hr.AgentID, Description: "人事问题"},
+		{AgentID: it.AgentID, Description: "IT 问题"},
+	}}
+	if err := Validate(valid, 1, 99); err != nil {
+		t.Fatal(err)
+	}
+	for name, cfg := range map[string]*model.OrchestratorConfig{
+		"empty":        {},
+		"self":         {SubAgents: []model.SubAgent{{AgentID: hr.AgentID, Description: "x"}}},
+		"duplicate":    {SubAgents: []model.SubAgent{{AgentID: it.AgentID, Description: "x"}, {AgentID: it.AgentID, Description: "y"}}},
+		"description":  {SubAgents: []model.SubAgent{{AgentID: it.AgentID}}},
+		"workflow":     {SubAgents: []model.SubAgent{{AgentID: workflow.AgentID, Description: "x"}}},
+		"other eid":    {SubAgents: []model.SubAgent{{AgentID: 404, Description: "x"}}},
+		"rounds limit": {SubAgents: valid.SubAgents, MaxRounds: MaxRounds + 1},
+	} {
+		if err := Validate(cfg, 1, hr.AgentID); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+}
+
+func TestCandidatesFollowGroupPermissions(t *testing.T) {
+	hr, it, workflow := setupAgents(t)
+	cfg := &model.OrchestratorConfig{SubAgents: []model.SubAgent{
+		{AgentID: hr.AgentID, Description: "人事问题"},
+		{AgentID: it.AgentID, Description: "IT 问题"},
+		{AgentID: workflow.AgentID, Description: "报销"},
+	}}
+
+	user := &model.User{UserID: 5, Type: model.UserTypeRegistered, GroupId: 10}
+	candidates, err := Candidates(cfg, 1, user, false)
+	if err != nil || len(candidates) != 1 || candidates[0].Agent.AgentID != hr.AgentID {
+		t.Fatalf("candidates = %+v, %v", candidates, err)
+	}
+
+	candidates, err = Candidates(cfg, 1, user, true)
+	if err != nil || len(candidates) != 2 {
+		t.Fatalf("admin candidates = %+v, %v", candidates, err)
+	}
+	tools := Tools(candidates)
+	if tools[0].Function.Name != toolName(hr.AgentID) || !strings.Contains(tools[1].Function.Description, "IT 问题") {
+		t.Fatalf("tools = %+v", tools)
+	}
+}
+
+func TestRun(t *testing.T) {
+	hr := &model.Agent{AgentID: 1, Name: "HR"}
+	it := &model.Agent{AgentID: 2, Name: "IT"}
+	candidates := []*Candidate{{Agent: hr, Description: "人事问题"}, {Agent: it, Description: "IT 问题"}}
+	messages := []relaymodel.Message{{Role: "user", Content: "年假怎么算？电脑坏了找谁？"}}
+
+	routes := 0
+	runner := &Runner{
+		Route: func(ctx context.Context, history []relaymodel.Message, tools []relaymodel.Tool) (*relaymodel.Message, error) {
+			routes++
+			if len(tools) != 2 {
+				t.Fatalf("tools = %d", len(tools))
+			}
+			if routes == 1 {
+				return &relaymodel.Message{Role: "assistant", ToolCalls: []relaymodel.Tool{
+					{Id: "call_1", Type: "function", Function: relaymodel.Function{Name: "agent_1", Arguments: `{"query":"年假怎么算"}`}},
+					{Id: "call_2", Type: "function", Function: relaymodel.Function{Name: "agent_2", Arguments: map[string]interface{}{"query": "电脑坏了找谁"}}},
+					{Id: "call_3", Type: "function", Function: relaymodel.Function{Name: "agent_9", Arguments: `{}`}},
+				}}, nil
+			}
+			// 第二轮应带上工具调用结果
+			last := history[len(history)-1]
+			if last.Role != "tool" || last.ToolCallId != "call_3" || !strings.HasPrefix(last.StringContent(), "调用失败") {
+				t.Fatalf("unexpected history tail: %+v", last)
+			}
+			return &relaymodel.Message{Role: "assistant", Content: "好的"}, nil
+		},
+		Call: func(ctx context.Context, agent *model.Agent, query string) (string, error) {
+			if agent.AgentID == it.AgentID {
+				return "", errors.New("channel unavailable")
+			}
+			return "入职满一年享有 5 天年假", nil
+		},
+	}
+	calls, err := runner.Run(context.Background(), &model.OrchestratorConfig{}, messages, candidates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if routes != 2 || len(calls) != 2 {
+		t.Fatalf("routes = %d, calls = %+v", routes, calls)
+	}
+	if calls[0].Query != "年假怎么算" || calls[0].Answer == "" || calls[1].Error == "" {
+		t.Fatalf("calls = %+v", calls)
+	}
+	prompt := ContextPrompt(calls)
+	if !strings.Contains(prompt, "入职满一年享有 5 天年假") || !strings.Contains(prompt, "暂时无法回答") {
+		t.Fatalf("prompt = %q", prompt)
+	}
+
+	// 首轮路由失败时返回错误，由调用方直接回答
+	runner.Route = func(ctx context.Context, history []relaymodel.Message, tools []relaymodel.Tool) (*relaymodel.Message, error) {
+		return nil, errors.New("timeout")
+	}
+	if _, err := runner.Run(context.Background(), &model.OrchestratorConfig{}, messages, candidates); err == nil {
+		t.Fatal("expected route error")
+	}
+	if calls, err := runner.Run(context.Background(), &model.OrchestratorConfig{}, messages, nil); err != nil || len(calls) != 0 {
+		t.Fatalf("no candidates = %+v, %v", calls, err)
+	}
+}
+
+func TestRunStopsAtMaxRounds(t *testing.T) {
+	agent := &model.Agent{AgentID: 1, Name: "HR"}
+	routes := 0
+	runner := &Runner{
+		Route: func(ctx context.Context, history []relaymodel.Message, tools []relaymodel.Tool) (*relaymodel.Message, error) {
+			routes++
+			return &relaymodel.Message{Role: "assistant", ToolCalls: []relaymodel.Tool{
+				{Id: "call", Type: "function", Function: relaymodel.Function{Name: "agent_1", Arguments: `{"query":"再问一次"}`}},
+			}}, nil
+		},
+		Call: func(ctx context.Context, agent *model.Agent, query string) (string, error) {
+			return "答复", nil
+		},
+	}
+	calls, err := runner.Run(context.Background(), &model.OrchestratorConfig{MaxRounds: 2}, []relaymodel.Message{{Role: "user", Content: "hi"}},
+		[]*Candidate{{Agent: agent, Description: "人事"}})
+	if err != nil || routes != 2 || len(calls) != 2 {
+		t.Fatalf("routes = %d, calls = %d, err = %v", routes, len(calls), err)
+	}
+}
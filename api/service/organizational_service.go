@@ -7,6 +7,10 @@ import (
 
 	"github.com/53AI/53AIHub/config"
 	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/dingtalk"
+	"github.com/53AI/53AIHub/service/feishu"
+	"github.com/53AI/53AIHub/service/orgsync"
+	"github.com/53AI/53AIHub/service/wecom"
 )
 
 type SyncOrganizationParams struct {
@@ -51,18 +55,41 @@ type OrganizationDepartmentRelationRes struct {
 	Sort      int    `json:"sort"`
 }
 
-func WeComRunSyncOrganization(e *model.Enterprise, params SyncOrganizationParams) error {
-	if config.IS_SAAS {
+var ErrOrgSyncNotSupported = errors.New("organization sync is not supported for this source")
+
+// GetOrgSyncProvider 按来源返回组织架构同步的实现
+func GetOrgSyncProvider(e *model.Enterprise, from int, params SyncOrganizationParams) (orgsync.Provider, error) {
+	switch from {
+	case model.DepartmentFromWecom:
+		if !config.IS_SAAS {
+			return nil, ErrOrgSyncNotSupported
+		}
 		wc, err := model.GetWecomCorp(params.SuiteID, e.WecomCorpID)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if wc == nil {
-			return errors.New("wecom corp not found")
+			return nil, errors.New("wecom corp not found")
 		}
-		return nil
-	} else {
-		return nil
+		return wecom.NewClient(wc), nil
+	case model.DepartmentFromDingTalk:
+		return dingtalk.Load(e.Eid)
+	case model.DepartmentFromFeishu:
+		return feishu.Load(e.Eid)
+	default:
+		return nil, ErrOrgSyncNotSupported
+	}
+}
+
+// GetOrgAuthenticator 按平台名称返回扫码登录的实现
+func GetOrgAuthenticator(eid int64, platform string) (orgsync.Authenticator, error) {
+	switch platform {
+	case model.EnterpriseConfigTypeDingTalk:
+		return dingtalk.Load(eid)
+	case model.EnterpriseConfigTypeFeishu:
+		return feishu.Load(eid)
+	default:
+		return nil, ErrOrgSyncNotSupported
 	}
 }
 
@@ -0,0 +1,202 @@
+package orgsync
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/53AI/53AIHub/common"
+	"github.com/53AI/53AIHub/common/utils/helper"
+	"github.com/53AI/53AIHub/model"
+	"gorm.io/gorm"
+)
+
+// 授权请求的有效期
+const stateTTL = 10 * time.Minute
+
+var ErrInvalidState = errors.New("login state is invalid or expired")
+
+// LoginInfo 发起登录时返回给前端：跳转 AuthorizeURL，或使用 ClientID 与 State 嵌入平台扫码组件
+type LoginInfo struct {
+	AuthorizeURL string `json:"authorize_url"`
+	ClientID     string `json:"client_id"`
+	State        string `json:"state"`
+}
+
+type loginState struct {
+	Eid         int64  `json:"eid"`
+	Source      int    `json:"source"`
+	RedirectURI string `json:"redirect_uri"`
+}
+
+func stateKey(state string) string {
+	return "Api::OrgLoginState:" + state
+}
+
+// Authorize 生成一次性的 state 与授权地址
+func Authorize(eid int64, auth Authenticator, redirectURI string) (*LoginInfo, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	state := base64.RawURLEncoding.EncodeToString(buf)
+	data := &loginState{Eid: eid, Source: auth.Source(), RedirectURI: redirectURI}
+	if err := common.STATES.Save(stateKey(state), data, stateTTL); err != nil {
+		return nil, err
+	}
+	return &LoginInfo{AuthorizeURL: auth.AuthorizeURL(redirectURI, state), ClientID: auth.ClientID(), State: state}, nil
+}
+
+// consumeState 取回并删除 state，每个 state 只能使用一次
+func consumeState(state string) (*loginState, error) {
+	if state == "" {
+		return nil, ErrInvalidState
+	}
+	data := &loginState{}
+	if err := common.STATES.Consume(stateKey(state), data); err != nil {
+		return nil, ErrInvalidState
+	}
+	return data, nil
+}
+
+// Login 校验 state 后使用授权码换取平台成员，返回关联的企业成员。
+// 成员尚未关联时按手机号、邮箱关联已有成员，仍未找到且开启自动创建时创建内部成员
+func Login(eid int64, auth Authenticator, code string, state string) (*model.User, error) {
+	data, err := consumeState(state)
+	if err != nil {
+		return nil, err
+	}
+	if data.Eid != eid || data.Source != auth.Source() {
+		return nil, ErrInvalidState
+	}
+	member, err := auth.UserByCode(code, data.RedirectURI)
+	if err != nil {
+		return nil, err
+	}
+	if member.Disabled {
+		return nil, ErrUserDisabled
+	}
+
+	// 尚未同步的成员先保存绑定与部门关系
+	s, err := newSyncer(eid, auth.Source())
+	if err != nil {
+		return nil, err
+	}
+	binding, _, err := s.saveMember(member)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *model.User
+	if binding.MID > 0 {
+		if user, err = findUser(eid, "user_id = ?", binding.MID); err != nil {
+			return nil, err
+		}
+	}
+	if user == nil {
+		if user, err = findUserByContact(eid, member); err != nil {
+			return nil, err
+		}
+		if user == nil {
+			if !auth.AutoCreateUser() {
+				return nil, ErrUserNotProvisioned
+			}
+			if user, err = createUser(eid, member); err != nil {
+				return nil, err
+			}
+		}
+		err = model.DB.Model(binding).Updates(map[string]interface{}{
+			"mid":    user.UserID,
+			"status": model.MemberBindingStatusActive,
+		}).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	if user.Status == model.UserStatusDisabled {
+		return nil, ErrUserDisabled
+	}
+	return user, nil
+}
+
+func findUser(eid int64, query string, args ...interface{}) (*model.User, error) {
+	var user model.User
+	err := model.DB.Where("eid = ?", eid).Where(query, args...).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// findUserByContact 平台返回的手机号、邮箱由企业管理员维护，可直接用于关联
+func findUserByContact(eid int64, member *Member) (*model.User, error) {
+	var (
+		user *model.User
+		err  error
+	)
+	if helper.IsValidPhone(member.Mobile) {
+		if user, err = findUser(eid, "mobile = ?", member.Mobile); err != nil {
+			return nil, err
+		}
+	}
+	if user == nil && helper.IsValidEmail(member.Email) {
+		if user, err = findUser(eid, "email = ?", member.Email); err != nil {
+			return nil, err
+		}
+	}
+	if user != nil && user.Type != model.UserTypeInternal {
+		return nil, ErrAccountConflict
+	}
+	return user, nil
+}
+
+// createUser 用户名优先使用手机号，其次邮箱
+func createUser(eid int64, member *Member) (*model.User, error) {
+	user := &model.User{
+		Nickname: member.Name,
+		Avatar:   member.Avatar,
+		Password: helper.RandomString(16), // 扫码登录成员不使用密码登录
+		Eid:      eid,
+		Type:     model.UserTypeInternal,
+		Role:     model.RoleCommonUser,
+	}
+	if helper.IsValidPhone(member.Mobile) {
+		user.Mobile = member.Mobile
+	}
+	if helper.IsValidEmail(member.Email) {
+		user.Email = member.Email
+	}
+	user.Username = user.Mobile
+	if user.Username == "" {
+		user.Username = user.Email
+	}
+	if user.Username == "" {
+		return nil, ErrUserNotProvisioned
+	}
+	if user.Nickname == "" {
+		user.Nickname = user.Username
+	}
+	if err := user.Create(); err != nil {
+		if err.Error() == "email already exists" || err.Error() == "mobile already exists" {
+			return nil, ErrAccountConflict
+		}
+		return nil, err
+	}
+	err := model.CreateMemberBinding(&model.MemberBinding{
+		MID:       user.UserID,
+		EID:       eid,
+		Name:      user.Nickname,
+		BindValue: strconv.FormatInt(user.UserID, 10),
+		Status:    model.MemberBindingStatusActive,
+		From:      model.DepartmentFromBackend,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,56 @@
+// Package orgsync 第三方平台（企业微信、钉钉、飞书）组织架构同步与扫码登录的公共实现。
+//
+// 各平台实现 Provider 提供部门与成员数据，实现 Authenticator 完成授权登录；
+// 同步时部门写入对应来源的 departments，成员写入对应来源的 member_bindings（bindvalue 为平台成员 ID），
+// 成员首次登录时才关联或创建企业成员。
+package orgsync
+
+import "errors"
+
+var (
+	ErrUserNotProvisioned = errors.New("member has not joined the enterprise")
+	ErrUserDisabled       = errors.New("member is disabled")
+	ErrAccountConflict    = errors.New("email or mobile is used by an account outside the enterprise")
+)
+
+// Department 平台中的部门，ParentID 为空或找不到时作为顶级部门
+type Department struct {
+	ID       string
+	ParentID string
+	Name     string
+	Order    int
+}
+
+// Member 平台中的成员
+type Member struct {
+	ID            string
+	Name          string
+	Mobile        string
+	Email         string
+	Avatar        string
+	DepartmentIDs []string
+	Disabled      bool // 已停用、冻结或离职
+}
+
+// Provider 组织架构来源
+type Provider interface {
+	// Source 部门、成员绑定与部门关系的 from 值
+	Source() int
+	// Departments 返回全部部门
+	Departments() ([]*Department, error)
+	// Members 返回部门下的直属成员
+	Members(departmentID string) ([]*Member, error)
+}
+
+// Authenticator 平台网页授权与扫码登录
+type Authenticator interface {
+	Provider
+	// ClientID 前端嵌入平台扫码组件时使用的应用标识
+	ClientID() string
+	// AuthorizeURL 网页授权地址，PC 端打开时展示二维码
+	AuthorizeURL(redirectURI string, state string) string
+	// UserByCode 使用回调中的授权码换取成员信息
+	UserByCode(code string, redirectURI string) (*Member, error)
+	// AutoCreateUser 成员首次登录时是否自动创建企业成员
+	AutoCreateUser() bool
+}
@@ -0,0 +1,290 @@
+package orgsync
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/model"
+	"gorm.io/gorm"
+)
+
+// Result 一次组织架构同步的统计
+type Result struct {
+	Departments int `json:"departments"`
+	Created     int `json:"created"`
+	Updated     int `json:"updated"`
+	Disabled    int `json:"disabled"`
+	Failed      int `json:"failed"`
+}
+
+type syncer struct {
+	eid         int64
+	source      int
+	departments map[string]int64 // 平台部门 ID -> did
+}
+
+func newSyncer(eid int64, source int) (*syncer, error) {
+	s := &syncer{eid: eid, source: source, departments: make(map[string]int64)}
+	departments, err := model.GetDepartmentsByEID(eid, source)
+	if err != nil {
+		return nil, err
+	}
+	for _, department := range departments {
+		s.departments[department.BindValue] = department.DID
+	}
+	return s, nil
+}
+
+// Sync 同步部门树与成员，平台中已不存在的部门删除、成员停用
+func Sync(eid int64, provider Provider) (*Result, error) {
+	s, err := newSyncer(eid, provider.Source())
+	if err != nil {
+		return nil, err
+	}
+	departments, err := provider.Departments()
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{}
+	if err := s.syncDepartments(departments, result); err != nil {
+		return nil, err
+	}
+
+	// 成员可属于多个部门，按成员 ID 去重
+	seen := make(map[int64]bool)
+	members := make(map[string]bool)
+	for _, department := range departments {
+		list, err := provider.Members(department.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range list {
+			if member.ID == "" || members[member.ID] {
+				continue
+			}
+			members[member.ID] = true
+			binding, created, err := s.saveMember(member)
+			if err != nil {
+				result.Failed++
+				logger.SysErrorf("org sync member failed: eid=%d from=%d id=%s err=%v", eid, s.source, member.ID, err)
+				continue
+			}
+			seen[binding.ID] = true
+			if created {
+				result.Created++
+			} else {
+				result.Updated++
+			}
+		}
+	}
+
+	disabled, err := s.disableMissingMembers(seen)
+	if err != nil {
+		return nil, err
+	}
+	result.Disabled = disabled
+	return result, nil
+}
+
+// syncDepartments 上级部门先于下级创建
+func (s *syncer) syncDepartments(departments []*Department, result *Result) error {
+	parents := make(map[string]string, len(departments))
+	for _, department := range departments {
+		parents[department.ID] = department.ParentID
+	}
+	depth := func(id string) int {
+		d := 0
+		for visited := map[string]bool{}; parents[id] != "" && !visited[id]; d++ {
+			visited[id] = true
+			id = parents[id]
+		}
+		return d
+	}
+	ordered := append([]*Department(nil), departments...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return depth(ordered[i].ID) < depth(ordered[j].ID)
+	})
+
+	seen := make(map[string]bool, len(ordered))
+	for _, item := range ordered {
+		if item.ID == "" || item.Name == "" || seen[item.ID] {
+			continue
+		}
+		seen[item.ID] = true
+		pdid := s.departments[item.ParentID]
+
+		if did, ok := s.departments[item.ID]; ok {
+			department, err := model.GetDepartmentByID(s.eid, did)
+			if err != nil {
+				return err
+			}
+			if department.Name != item.Name || department.PDID != pdid || department.Sort != item.Order {
+				department.Name, department.PDID, department.Sort = item.Name, pdid, item.Order
+				if err := model.UpdateDepartment(department); err != nil {
+					return err
+				}
+			}
+		} else {
+			department := &model.Department{EID: s.eid, PDID: pdid, Name: item.Name, Sort: item.Order, From: s.source}
+			if err := model.CreateDepartment(department); err != nil {
+				return err
+			}
+			// CreateDepartment 会将 bindvalue 设置为部门ID，这里改为平台部门 ID
+			if err := model.DB.Model(department).Update("bindvalue", item.ID).Error; err != nil {
+				return err
+			}
+			s.departments[item.ID] = department.DID
+		}
+		result.Departments++
+	}
+
+	// 由深到浅删除已不存在的部门
+	missing := make([]*model.Department, 0)
+	for id, did := range s.departments {
+		if !seen[id] {
+			department, err := model.GetDepartmentByID(s.eid, did)
+			if err != nil {
+				return err
+			}
+			missing = append(missing, department)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool {
+		return strings.Count(missing[i].Path, ",") > strings.Count(missing[j].Path, ",")
+	})
+	for _, department := range missing {
+		if err := model.DeleteDepartment(s.eid, department.DID, true); err != nil {
+			logger.SysErrorf("org sync delete department failed: eid=%d did=%d err=%v", s.eid, department.DID, err)
+		}
+		delete(s.departments, department.BindValue)
+	}
+	return nil
+}
+
+// saveMember 保存成员绑定与部门关系；已关联的企业成员随平台停用或恢复
+func (s *syncer) saveMember(member *Member) (*model.MemberBinding, bool, error) {
+	binding, err := model.GetMemberBindingByBindValue(s.eid, member.ID, s.source)
+	if err != nil {
+		return nil, false, err
+	}
+	created := binding == nil
+
+	err = model.DB.Transaction(func(tx *gorm.DB) error {
+		if created {
+			binding = &model.MemberBinding{
+				EID:       s.eid,
+				Name:      member.Name,
+				BindValue: member.ID,
+				Status:    model.MemberBindingStatusInactive,
+				From:      s.source,
+			}
+			if member.Disabled {
+				binding.Status = model.MemberBindingStatusDisabled
+			}
+			// 尚未关联企业成员，mid 为 0
+			if err := tx.Create(binding).Error; err != nil {
+				return err
+			}
+		} else {
+			status := binding.Status
+			if member.Disabled {
+				status = model.MemberBindingStatusDisabled
+			} else if status == model.MemberBindingStatusDisabled {
+				status = model.MemberBindingStatusInactive
+				if binding.MID > 0 {
+					status = model.MemberBindingStatusActive
+				}
+			}
+			if status != binding.Status && binding.MID > 0 {
+				if err := s.updateUserStatus(tx, binding.MID, status == model.MemberBindingStatusDisabled); err != nil {
+					return err
+				}
+			}
+			binding.Name, binding.Status = member.Name, status
+			err := tx.Model(binding).Updates(map[string]interface{}{
+				"name":   binding.Name,
+				"status": binding.Status,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return s.saveDepartments(tx, binding, member)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return binding, created, nil
+}
+
+// updateUserStatus 停用关联的企业成员；恢复时按是否登录过恢复为已加入或未加入
+func (s *syncer) updateUserStatus(tx *gorm.DB, userID int64, disabled bool) error {
+	var user model.User
+	if err := tx.Where("eid = ? AND user_id = ?", s.eid, userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	status := model.UserStatusDisabled
+	if !disabled {
+		if user.Status != model.UserStatusDisabled {
+			return nil
+		}
+		status = model.UserStatusNotJoined
+		if user.LastLoginTime > 0 {
+			status = model.UserStatusJoined
+		}
+	}
+	return tx.Model(&user).Update("status", status).Error
+}
+
+func (s *syncer) saveDepartments(tx *gorm.DB, binding *model.MemberBinding, member *Member) error {
+	err := tx.Where("eid = ? AND bid = ? AND `from` = ?", s.eid, binding.ID, s.source).
+		Delete(&model.MemberDepartmentRelation{}).Error
+	if err != nil {
+		return err
+	}
+	added := make(map[int64]bool, len(member.DepartmentIDs))
+	for _, id := range member.DepartmentIDs {
+		did, ok := s.departments[id]
+		if !ok || added[did] {
+			continue
+		}
+		added[did] = true
+		relation := &model.MemberDepartmentRelation{EID: s.eid, BID: binding.ID, DID: did, From: s.source}
+		if err := tx.Create(relation).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// disableMissingMembers 停用平台中已不存在（离职或移出可见范围）的成员
+func (s *syncer) disableMissingMembers(seen map[int64]bool) (int, error) {
+	bindings, err := model.GetMemberBindingsBySource(s.eid, s.source)
+	if err != nil {
+		return 0, err
+	}
+	disabled := 0
+	for _, binding := range bindings {
+		if seen[binding.ID] || binding.Status == model.MemberBindingStatusDisabled {
+			continue
+		}
+		err := model.DB.Transaction(func(tx *gorm.DB) error {
+			if binding.MID > 0 {
+				if err := s.updateUserStatus(tx, binding.MID, true); err != nil {
+					return err
+				}
+			}
+			return tx.Model(binding).Update("status", model.MemberBindingStatusDisabled).Error
+		})
+		if err != nil {
+			return disabled, err
+		}
+		disabled++
+	}
+	return disabled, nil
+}
@@ -0,0 +1,198 @@
+package promptlib
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/53AI/53AIHub/model"
+	"github.com/pmezard/go-difflib/difflib"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotForkable 只有系统提示词可以复制为个人提示词
+	ErrNotForkable   = errors.New("only system prompts can be forked")
+	ErrInvalidPeriod = errors.New("invalid ranking period")
+)
+
+// 排行统计周期
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+	PeriodAll   = "all"
+)
+
+// RevisionDiff 两个修订版本之间的差异，Content 为统一格式的文本差异
+type RevisionDiff struct {
+	From        int      `json:"from"`
+	To          int      `json:"to"`
+	Fields      []string `json:"fields"`
+	Content     string   `json:"content"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+}
+
+// Stats 提示词的使用统计
+type Stats struct {
+	PromptID int64                     `json:"prompt_id"`
+	UseCount int64                     `json:"use_count"`
+	Agents   []*model.PromptAgentUsage `json:"agents"`
+	Forks    int                       `json:"forks"`
+}
+
+// RecordRevision 名称、内容或描述与最新修订不同时记录新修订，并更新提示词的当前修订号
+// 需在保存提示词的事务内调用
+func RecordRevision(tx *gorm.DB, prompt *model.Prompt, userID int64) (*model.PromptRevision, error) {
+	latest, err := model.GetLatestPromptRevision(tx, prompt.PromptID)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil && latest.Name == prompt.Name && latest.Content == prompt.Content && latest.Description == prompt.Description {
+		return latest, nil
+	}
+	revision := &model.PromptRevision{
+		Eid:         prompt.Eid,
+		PromptID:    prompt.PromptID,
+		Revision:    1,
+		Name:        prompt.Name,
+		Content:     prompt.Content,
+		Description: prompt.Description,
+		CreatedBy:   userID,
+	}
+	if latest != nil {
+		revision.Revision = latest.Revision + 1
+	}
+	if err := tx.Create(revision).Error; err != nil {
+		return nil, err
+	}
+	prompt.Revision = revision.Revision
+	err = tx.Model(&model.Prompt{}).Where("prompt_id = ?", prompt.PromptID).Update("revision", prompt.Revision).Error
+	return revision, err
+}
+
+// Diff 对比两个修订版本
+func Diff(from, to *model.PromptRevision) *RevisionDiff {
+	diff := &RevisionDiff{From: from.Revision, To: to.Revision, Fields: make([]string, 0)}
+	label := func(r *model.PromptRevision) string { return fmt.Sprintf("revision-%d", r.Revision) }
+	unified := func(a, b string) string {
+		text, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(a),
+			B:        difflib.SplitLines(b),
+			FromFile: label(from),
+			ToFile:   label(to),
+			Context:  3,
+		})
+		return text
+	}
+	if from.Name != to.Name {
+		diff.Fields = append(diff.Fields, "name")
+		diff.Name = unified(from.Name, to.Name)
+	}
+	if from.Content != to.Content {
+		diff.Fields = append(diff.Fields, "content")
+		diff.Content = unified(from.Content, to.Content)
+	}
+	if from.Description != to.Description {
+		diff.Fields = append(diff.Fields, "description")
+		diff.Description = unified(from.Description, to.Description)
+	}
+	return diff
+}
+
+// Fork 将系统提示词复制为 userID 的个人提示词，记录来源提示词及其修订版本
+func Fork(source *model.Prompt, userID int64) (*model.Prompt, error) {
+	if source.Type != model.PromptTypeSystem {
+		return nil, ErrNotForkable
+	}
+	fork := &model.Prompt{
+		Name:         source.Name,
+		Content:      source.Content,
+		Description:  source.Description,
+		Type:         model.PromptTypePersonal,
+		Status:       model.PromptStatusNormal,
+		UserID:       userID,
+		Eid:          source.Eid,
+		CustomConfig: source.CustomConfig,
+		AILinks:      source.AILinks,
+		SourceID:     source.PromptID,
+		SourceRev:    source.Revision,
+	}
+	err := model.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(fork).Error; err != nil {
+			return err
+		}
+		_, err := RecordRevision(tx, fork, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fork, nil
+}
+
+// RecordUsage 记录一次插入对话，并累加提示词的使用次数
+func RecordUsage(prompt *model.Prompt, userID, agentID, conversationID int64) error {
+	return model.DB.Transaction(func(tx *gorm.DB) error {
+		usage := &model.PromptUsage{
+			Eid:            prompt.Eid,
+			PromptID:       prompt.PromptID,
+			UserID:         userID,
+			AgentID:        agentID,
+			ConversationID: conversationID,
+		}
+		if err := tx.Create(usage).Error; err != nil {
+			return err
+		}
+		prompt.UseCount++
+		return tx.Model(&model.Prompt{}).Where("prompt_id = ?", prompt.PromptID).
+			UpdateColumn("use_count", gorm.Expr("use_count + 1")).Error
+	})
+}
+
+// GetStats 提示词累计使用次数、按智能体的分布以及被复制的次数
+func GetStats(prompt *model.Prompt) (*Stats, error) {
+	count, err := model.CountPromptUsages(prompt.PromptID)
+	if err != nil {
+		return nil, err
+	}
+	agents, err := model.GetPromptAgentUsages(prompt.PromptID)
+	if err != nil {
+		return nil, err
+	}
+	forks, err := model.GetPromptForks(prompt.PromptID)
+	if err != nil {
+		return nil, err
+	}
+	return &Stats{PromptID: prompt.PromptID, UseCount: count, Agents: agents, Forks: len(forks)}, nil
+}
+
+// PeriodStart 统计周期的起始时间（毫秒时间戳），按自然日、自然周（周一开始）、自然月计算
+func PeriodStart(period string, now time.Time) (int64, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch period {
+	case PeriodDay:
+		return today.UnixMilli(), nil
+	case PeriodWeek:
+		offset := (int(today.Weekday()) + 6) % 7
+		return today.AddDate(0, 0, -offset).UnixMilli(), nil
+	case PeriodMonth:
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location()).UnixMilli(), nil
+	case "", PeriodAll:
+		return 0, nil
+	}
+	return 0, ErrInvalidPeriod
+}
+
+// Rankings 统计周期内使用次数最多的提示词，promptType 为 0 时不区分系统与个人提示词
+func Rankings(eid int64, period string, promptType int, limit int) ([]*model.PromptRanking, error) {
+	start, err := PeriodStart(period, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return model.GetPromptRankings(eid, promptType, start, limit)
+}
@@ -0,0 +1,117 @@
+package promptlib
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+)
+
+func setupPrompt(t *testing.T) *model.Prompt {
+	db := testutil.SetupDB(t, &model.Prompt{}, &model.PromptRevision{}, &model.PromptUsage{}, &model.Agent{})
+	prompt := &model.Prompt{Eid: 1, UserID: 1, Type: model.PromptTypeSystem, Status: model.PromptStatusNormal,
+		Name: "translator", Content: "Translate to English.\nKeep it short.", Description: "翻译", CustomConfig: "{}"}
+	if err := db.Create(prompt).Error; err != nil {
+		t.Fatal(err)
+	}
+	return prompt
+}
+
+func TestRevisionsAndDiff(t *testing.T) {
+	prompt := setupPrompt(t)
+	if _, err := RecordRevision(model.DB, prompt, 1); err != nil {
+		t.Fatal(err)
+	}
+	// 内容未变化时不产生新修订
+	if r, err := RecordRevision(model.DB, prompt, 1); err != nil || r.Revision != 1 {
+		t.Fatalf("unchanged prompt recorded revision %+v, %v", r, err)
+	}
+	prompt.Content = "Translate to English.\nKeep it formal."
+	if r, err := RecordRevision(model.DB, prompt, 2); err != nil || r.Revision != 2 || prompt.Revision != 2 {
+		t.Fatalf("RecordRevision = %+v, %v", r, err)
+	}
+
+	from, _ := model.GetPromptRevision(prompt.PromptID, 1)
+	to, _ := model.GetPromptRevision(prompt.PromptID, 2)
+	diff := Diff(from, to)
+	if len(diff.Fields) != 1 || diff.Fields[0] != "content" ||
+		!strings.Contains(diff.Content, "-Keep it short.") || !strings.Contains(diff.Content, "+Keep it formal.") {
+		t.Fatalf("unexpected diff: %+v", diff)
+	}
+	if _, err := model.GetPromptRevision(prompt.PromptID, 3); !errors.Is(err, model.ErrPromptRevisionNotFound) {
+		t.Fatalf("missing revision err = %v", err)
+	}
+}
+
+func TestForkAndUsage(t *testing.T) {
+	prompt := setupPrompt(t)
+	if _, err := RecordRevision(model.DB, prompt, 1); err != nil {
+		t.Fatal(err)
+	}
+	fork, err := Fork(prompt, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fork.Type != model.PromptTypePersonal || fork.UserID != 7 || fork.SourceID != prompt.PromptID ||
+		fork.SourceRev != 1 || fork.Revision != 1 {
+		t.Fatalf("unexpected fork: %+v", fork)
+	}
+	if _, err := Fork(fork, 7); !errors.Is(err, ErrNotForkable) {
+		t.Fatalf("forking personal prompt err = %v", err)
+	}
+
+	agent := &model.Agent{Eid: 1, Name: "writer", Configs: "{}", Tools: "[]", CustomConfig: "{}", Settings: "{}", UseCases: "[]"}
+	if err := model.DB.Create(agent).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, userID := range []int64{7, 7, 8} {
+		if err := RecordUsage(prompt, userID, agent.AgentID, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := RecordUsage(fork, 7, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	// 旧的使用记录不计入本周排行
+	old := &model.PromptUsage{Eid: 1, PromptID: fork.PromptID, UserID: 7, BaseModel: model.BaseModel{CreatedTime: 1}}
+	if err := model.DB.Create(old).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := GetStats(prompt)
+	if err != nil || stats.UseCount != 3 || stats.Forks != 1 || len(stats.Agents) != 1 ||
+		stats.Agents[0].AgentName != "writer" || stats.Agents[0].Count != 3 {
+		t.Fatalf("unexpected stats: %+v, %v", stats, err)
+	}
+	reloaded, _ := model.GetPromptByID(int(prompt.PromptID))
+	if reloaded.UseCount != 3 {
+		t.Fatalf("use_count = %d", reloaded.UseCount)
+	}
+
+	rankings, err := Rankings(1, PeriodWeek, 0, 10)
+	if err != nil || len(rankings) != 2 || rankings[0].PromptID != prompt.PromptID ||
+		rankings[0].Count != 3 || rankings[0].UserCount != 2 || rankings[1].Count != 1 {
+		t.Fatalf("unexpected rankings: %+v, %v", rankings, err)
+	}
+	if all, _ := Rankings(1, PeriodAll, model.PromptTypePersonal, 10); len(all) != 1 || all[0].Count != 2 {
+		t.Fatalf("unexpected personal rankings: %+v", all)
+	}
+	if _, err := Rankings(1, "year", 0, 10); !errors.Is(err, ErrInvalidPeriod) {
+		t.Fatalf("invalid period err = %v", err)
+	}
+}
+
+func TestPeriodStart(t *testing.T) {
+	now := time.Date(2025, 1, 2, 15, 4, 0, 0, time.UTC) // 星期四
+	week, _ := PeriodStart(PeriodWeek, now)
+	if got := time.UnixMilli(week).UTC(); !got.Equal(time.Date(2024, 12, 30, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("week start = %v", got)
+	}
+	month, _ := PeriodStart(PeriodMonth, now)
+	if got := time.UnixMilli(month).UTC(); !got.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("month start = %v", got)
+	}
+}
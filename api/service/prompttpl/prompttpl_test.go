@@ -0,0 +1,96 @@
+package prompttpl
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+)
+
+func TestValidate(t *testing.T) {
+	valid := []string{
+		"plain prompt",
+		"你好 {{ user.nickname }}，今天是{{weekday}}",
+		"{{#form.topic}}主题：{{form.topic}}{{/form.topic}}{{^form.topic}}无主题{{/form.topic}}",
+	}
+	for _, tpl := range valid {
+		if err := Validate(tpl); err != nil {
+			t.Errorf("Validate(%q) = %v", tpl, err)
+		}
+	}
+	invalid := []string{
+		"{{user.nickname",
+		"{{user.password}}",
+		"{{form.}}",
+		"{{ printf \"%s\" }}",
+		"{{#form.a}}x",
+		"{{#form.a}}x{{/form.b}}",
+		"x{{/form.a}}",
+	}
+	for _, tpl := range invalid {
+		if err := Validate(tpl); err == nil {
+			t.Errorf("Validate(%q) accepted", tpl)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	vars := Vars{"user.nickname": "Alice", "form.topic": "Go"}
+	cases := map[string]string{
+		"hi {{user.nickname}}":                               "hi Alice",
+		"{{#form.topic}}topic={{form.topic}}{{/form.topic}}": "topic=Go",
+		"{{^form.lang}}default{{/form.lang}}":                "default",
+		"{{user.email}}|":                                    "|",
+		// 历史内容中的非模板花括号原样保留
+		"json: {{ \"a\": 1 }}":     "json: {{ \"a\": 1 }}",
+		"{{#form.topic}} unclosed": "{{#form.topic}} unclosed",
+		"broken {{user.nickname":   "broken {{user.nickname",
+	}
+	for tpl, want := range cases {
+		if got := Render(tpl, vars); got != want {
+			t.Errorf("Render(%q) = %q, want %q", tpl, got, want)
+		}
+	}
+}
+
+func TestVariablesAndFormFields(t *testing.T) {
+	tpl := "{{user.nickname}} {{#form.topic}}{{form.topic}}{{form.level}}{{/form.topic}} {{user.nickname}}"
+	if got := Variables(tpl); !reflect.DeepEqual(got, []string{"user.nickname", "form.topic", "form.level"}) {
+		t.Fatalf("Variables = %v", got)
+	}
+	if got := FormFields(tpl); !reflect.DeepEqual(got, []string{"topic", "level"}) {
+		t.Fatalf("FormFields = %v", got)
+	}
+}
+
+func TestBuildVars(t *testing.T) {
+	db := testutil.SetupDB(t, &model.User{}, &model.Enterprise{}, &model.Department{}, &model.MemberDepartmentRelation{}, &model.MemberBinding{})
+	enterprise := &model.Enterprise{DisplayName: "Acme"}
+	if err := db.Create(enterprise).Error; err != nil {
+		t.Fatal(err)
+	}
+	user := &model.User{Eid: enterprise.Eid, Username: "alice", Nickname: "Alice", Email: "alice@example.com"}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	dept := &model.Department{EID: enterprise.Eid, Name: "研发部"}
+	if err := db.Create(dept).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&model.MemberDepartmentRelation{EID: enterprise.Eid, BID: user.UserID, DID: dept.DID}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	vars := BuildVars(enterprise.Eid, user.UserID, map[string]string{"topic": "Go"})
+	got := Render("{{enterprise.display_name}}/{{user.nickname}}/{{user.departments}}/{{form.topic}}", vars)
+	if got != "Acme/Alice/研发部/Go" {
+		t.Fatalf("rendered %q", got)
+	}
+
+	now := time.Date(2025, 1, 2, 15, 4, 0, 0, time.UTC)
+	if tv := TimeVars(now); tv["now"] != "2025-01-02 15:04" || tv["weekday"] != "星期四" {
+		t.Fatalf("TimeVars = %v", tv)
+	}
+}
@@ -0,0 +1,251 @@
+package prompttpl
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 提示词模板语法（mustache 子集）：
+//
+//	{{user.nickname}}              变量
+//	{{#form.topic}}...{{/form.topic}} 变量非空时输出
+//	{{^form.topic}}...{{/form.topic}} 变量为空时输出
+//
+// 不支持函数调用和循环，模板只能读取 Vars 中的值
+
+// FormPrefix 用户在对话时填写的表单字段
+const FormPrefix = "form."
+
+// BuiltinVariables 内置变量及说明
+var BuiltinVariables = map[string]string{
+	"user.id":                 "用户ID",
+	"user.nickname":           "用户昵称",
+	"user.username":           "用户名",
+	"user.email":              "邮箱",
+	"user.departments":        "所在部门，多个以顿号分隔",
+	"enterprise.id":           "企业ID",
+	"enterprise.display_name": "企业名称",
+	"now":                     "当前时间，如 2025-01-02 15:04",
+	"date":                    "当前日期，如 2025-01-02",
+	"weekday":                 "星期，如 星期四",
+}
+
+var namePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*(\.[a-zA-Z_][a-zA-Z0-9_]*)*$`)
+
+type nodeKind int
+
+const (
+	nodeText nodeKind = iota
+	nodeVar
+	nodeSection
+	nodeInverted
+)
+
+type node struct {
+	kind     nodeKind
+	text     string // nodeText 的内容或变量名
+	raw      string // 标签原文，宽松渲染时原样输出
+	children []*node
+}
+
+// Error 模板语法错误，Pos 为出错标签在模板中的字节偏移
+type Error struct {
+	Pos     int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("prompt template error at %d: %s", e.Pos, e.Message)
+}
+
+// Vars 渲染使用的变量，键为完整变量名，如 user.nickname、form.topic
+type Vars map[string]string
+
+// IsTemplate 内容中是否包含模板标签
+func IsTemplate(content string) bool {
+	return strings.Contains(content, "{{")
+}
+
+// Validate 保存时校验模板：标签需闭合且配对，变量必须是内置变量或 form.* 表单字段
+func Validate(content string) error {
+	_, err := parse(content, true)
+	return err
+}
+
+// Variables 模板引用的变量，按首次出现的顺序去重
+func Variables(content string) []string {
+	nodes, _ := parse(content, false)
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	var walk func([]*node)
+	walk = func(nodes []*node) {
+		for _, n := range nodes {
+			if n.kind != nodeText && !seen[n.text] {
+				seen[n.text] = true
+				names = append(names, n.text)
+			}
+			walk(n.children)
+		}
+	}
+	walk(nodes)
+	return names
+}
+
+// FormFields 模板需要用户填写的表单字段名（不含 form. 前缀）
+func FormFields(content string) []string {
+	fields := make([]string, 0)
+	for _, name := range Variables(content) {
+		if strings.HasPrefix(name, FormPrefix) {
+			fields = append(fields, strings.TrimPrefix(name, FormPrefix))
+		}
+	}
+	return fields
+}
+
+// Render 渲染模板。为兼容历史内容，无法解析的标签原样保留，未提供的变量渲染为空
+func Render(content string, vars Vars) string {
+	if !IsTemplate(content) {
+		return content
+	}
+	nodes, _ := parse(content, false)
+	var sb strings.Builder
+	render(&sb, nodes, vars)
+	return sb.String()
+}
+
+func render(sb *strings.Builder, nodes []*node, vars Vars) {
+	for _, n := range nodes {
+		switch n.kind {
+		case nodeText:
+			sb.WriteString(n.text)
+		case nodeVar:
+			sb.WriteString(vars[n.text])
+		case nodeSection:
+			if vars[n.text] != "" {
+				render(sb, n.children, vars)
+			}
+		case nodeInverted:
+			if vars[n.text] == "" {
+				render(sb, n.children, vars)
+			}
+		}
+	}
+}
+
+// parse 解析模板。strict 为 false 时不报错，无效标签作为文本保留
+func parse(content string, strict bool) ([]*node, error) {
+	type frame struct {
+		parent *node
+		pos    int
+	}
+	root := &node{}
+	stack := []frame{{parent: root}}
+	appendNode := func(n *node) {
+		top := stack[len(stack)-1].parent
+		top.children = append(top.children, n)
+	}
+	appendText := func(text string) {
+		if text == "" {
+			return
+		}
+		top := stack[len(stack)-1].parent
+		if last := len(top.children) - 1; last >= 0 && top.children[last].kind == nodeText {
+			top.children[last].text += text
+			return
+		}
+		top.children = append(top.children, &node{kind: nodeText, text: text})
+	}
+
+	pos := 0
+	for pos < len(content) {
+		start := strings.Index(content[pos:], "{{")
+		if start < 0 {
+			appendText(content[pos:])
+			break
+		}
+		start += pos
+		appendText(content[pos:start])
+		end := strings.Index(content[start+2:], "}}")
+		if end < 0 {
+			if strict {
+				return nil, &Error{Pos: start, Message: "unclosed tag"}
+			}
+			appendText(content[start:])
+			break
+		}
+		end += start + 2
+		raw := content[start : end+2]
+		tag := strings.TrimSpace(content[start+2 : end])
+		pos = end + 2
+
+		sigil := byte(0)
+		if tag != "" && strings.ContainsRune("#^/", rune(tag[0])) {
+			sigil = tag[0]
+			tag = strings.TrimSpace(tag[1:])
+		}
+		if !namePattern.MatchString(tag) {
+			if strict {
+				return nil, &Error{Pos: start, Message: fmt.Sprintf("invalid tag %q", raw)}
+			}
+			appendText(raw)
+			continue
+		}
+		if strict && !isKnownVariable(tag) {
+			return nil, &Error{Pos: start, Message: fmt.Sprintf("unknown variable %q", tag)}
+		}
+
+		switch sigil {
+		case '#', '^':
+			kind := nodeSection
+			if sigil == '^' {
+				kind = nodeInverted
+			}
+			n := &node{kind: kind, text: tag, raw: raw}
+			appendNode(n)
+			stack = append(stack, frame{parent: n, pos: start})
+		case '/':
+			top := stack[len(stack)-1]
+			if len(stack) == 1 || top.parent.text != tag {
+				if strict {
+					return nil, &Error{Pos: start, Message: fmt.Sprintf("unexpected closing tag %q", raw)}
+				}
+				appendText(raw)
+				continue
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			appendNode(&node{kind: nodeVar, text: tag, raw: raw})
+		}
+	}
+
+	// 未闭合的区块：严格模式报错，宽松模式将开始标签还原为文本
+	for len(stack) > 1 {
+		top := stack[len(stack)-1]
+		if strict {
+			return nil, &Error{Pos: top.pos, Message: fmt.Sprintf("unclosed section %q", top.parent.text)}
+		}
+		stack = stack[:len(stack)-1]
+		unwrapSection(stack[len(stack)-1].parent, top.parent)
+	}
+	return root.children, nil
+}
+
+// unwrapSection 把未闭合区块替换为其标签原文加子节点
+func unwrapSection(parent *node, section *node) {
+	for i, child := range parent.children {
+		if child != section {
+			continue
+		}
+		replaced := append([]*node{{kind: nodeText, text: section.raw}}, section.children...)
+		parent.children = append(parent.children[:i], append(replaced, parent.children[i+1:]...)...)
+		return
+	}
+}
+
+func isKnownVariable(name string) bool {
+	if _, ok := BuiltinVariables[name]; ok {
+		return true
+	}
+	return strings.HasPrefix(name, FormPrefix) && len(name) > len(FormPrefix)
+}
@@ -0,0 +1,59 @@
+package prompttpl
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/53AI/53AIHub/model"
+)
+
+var weekdays = []string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"}
+
+// BuildVars 组装渲染变量：当前用户、企业、时间以及用户填写的表单字段
+// 用户或企业不存在时对应变量为空，不影响渲染
+func BuildVars(eid int64, userID int64, form map[string]string) Vars {
+	vars := TimeVars(time.Now())
+	vars["enterprise.id"] = strconv.FormatInt(eid, 10)
+	if enterprise, err := model.GetEnterpriseByID(eid); err == nil {
+		vars["enterprise.display_name"] = enterprise.DisplayName
+	}
+	if userID > 0 {
+		vars["user.id"] = strconv.FormatInt(userID, 10)
+		if user, err := model.GetUserByID(userID); err == nil {
+			vars["user.nickname"] = user.Nickname
+			vars["user.username"] = user.Username
+			vars["user.email"] = user.Email
+			vars["user.departments"] = departmentNames(user)
+		}
+	}
+	for key, value := range form {
+		vars[FormPrefix+key] = value
+	}
+	return vars
+}
+
+// TimeVars 时间相关变量
+func TimeVars(now time.Time) Vars {
+	return Vars{
+		"now":     now.Format("2006-01-02 15:04"),
+		"date":    now.Format("2006-01-02"),
+		"weekday": weekdays[now.Weekday()],
+	}
+}
+
+func departmentNames(user *model.User) string {
+	dids, err := user.GetDepartmentIDs()
+	if err != nil || len(dids) == 0 {
+		return ""
+	}
+	departments, err := model.BatchGetDepartmentsByIDs(user.Eid, dids)
+	if err != nil {
+		return ""
+	}
+	names := make([]string, 0, len(departments))
+	for _, department := range departments {
+		names = append(names, department.Name)
+	}
+	return strings.Join(names, "、")
+}
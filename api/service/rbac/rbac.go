@@ -0,0 +1,200 @@
+package rbac
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/53AI/53AIHub/common/session"
+	"github.com/53AI/53AIHub/model"
+	"github.com/gin-gonic/gin"
+)
+
+// 本次请求已解析的权限点缓存在 gin.Context 中
+const contextPermissionsKey = "SESSION_USER_PERMISSIONS"
+
+var (
+	ErrInvalidPermission = errors.New("invalid permission")
+	ErrInvalidSubject    = errors.New("invalid role subject")
+	// ErrPermissionEscalation 只能授予自己已拥有的权限点
+	ErrPermissionEscalation = errors.New("cannot grant permissions you do not have")
+)
+
+// UserPermissions 成员的有效权限点：数值角色对应的内置角色，加上授予本人或其所在部门的自定义角色
+func UserPermissions(user *model.User) ([]string, error) {
+	builtin := model.BuiltinRoleByLevel(user.Role)
+	permissions := append([]string{}, builtin.PermissionList...)
+	if model.MatchPermission(permissions, model.PermAll) {
+		return permissions, nil
+	}
+
+	departmentIDs, err := user.GetDepartmentIDs()
+	if err != nil {
+		return nil, err
+	}
+	roles, err := model.GetAssignedRoles(user.Eid, user.UserID, departmentIDs)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(permissions))
+	for _, p := range permissions {
+		seen[p] = true
+	}
+	for _, role := range roles {
+		for _, p := range role.PermissionList {
+			if !seen[p] {
+				seen[p] = true
+				permissions = append(permissions, p)
+			}
+		}
+	}
+	return permissions, nil
+}
+
+// ContextPermissions 当前登录成员的有效权限点，需在 UserTokenAuth 之后调用；同一请求内只查询一次
+func ContextPermissions(c *gin.Context) ([]string, error) {
+	if cached, ok := c.Get(contextPermissionsKey); ok {
+		return cached.([]string), nil
+	}
+	user := &model.User{
+		UserID: c.GetInt64(session.SESSION_USER_ID),
+		Eid:    c.GetInt64(session.ENV_EID),
+		Role:   c.GetInt64(session.SESSION_USER_ROLE),
+	}
+	if user.UserID == 0 {
+		return []string{}, nil
+	}
+	permissions, err := UserPermissions(user)
+	if err != nil {
+		return nil, err
+	}
+	c.Set(contextPermissionsKey, permissions)
+	return permissions, nil
+}
+
+// HasPermission 当前登录成员是否拥有 permission，查询失败时视为没有权限
+func HasPermission(c *gin.Context, permission string) bool {
+	permissions, err := ContextPermissions(c)
+	return err == nil && model.MatchPermission(permissions, permission)
+}
+
+// CanManageUser 当前成员能否删除、修改、停用 target；管理员及以上的账号只有拥有全部权限的成员才能操作，
+// 避免持有 user:write 的自定义角色越权处理管理员、创建者账号
+func CanManageUser(c *gin.Context, target *model.User) bool {
+	return target.Role < model.RoleAdminUser || HasPermission(c, model.PermAll)
+}
+
+func normalizePermissions(operator []string, permissions []string) ([]string, error) {
+	result := make([]string, 0, len(permissions))
+	seen := make(map[string]bool, len(permissions))
+	for _, p := range permissions {
+		p = strings.TrimSpace(p)
+		if !model.IsValidPermission(p) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPermission, p)
+		}
+		if !model.MatchPermission(operator, p) {
+			return nil, fmt.Errorf("%w: %s", ErrPermissionEscalation, p)
+		}
+		if !seen[p] {
+			seen[p] = true
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
+
+// SaveRole 创建或更新自定义角色，operator 为操作者的有效权限点
+func SaveRole(role *model.Role, operator []string) error {
+	permissions, err := normalizePermissions(operator, role.PermissionList)
+	if err != nil {
+		return err
+	}
+	role.PermissionList = permissions
+	if role.ID == 0 {
+		return model.CreateRole(role)
+	}
+	return model.UpdateRole(role)
+}
+
+// checkGrant 授予或收回角色前检查操作者拥有该角色的全部权限点
+func checkGrant(role *model.Role, operator []string) error {
+	for _, p := range role.PermissionList {
+		if !model.MatchPermission(operator, p) {
+			return fmt.Errorf("%w: %s", ErrPermissionEscalation, p)
+		}
+	}
+	return nil
+}
+
+// CheckDepartmentGrant 把成员加入部门前检查操作者拥有部门上全部自定义角色的权限点。
+// 部门角色随成员关系生效，加入部门等同于授予这些角色
+func CheckDepartmentGrant(eid int64, departmentIDs []int64, operator []string) error {
+	if len(departmentIDs) == 0 {
+		return nil
+	}
+	roles, err := model.GetAssignedRoles(eid, 0, departmentIDs)
+	if err != nil {
+		return err
+	}
+	for _, role := range roles {
+		if err := checkGrant(role, operator); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Assign 把角色授予成员和部门，成员与部门需属于角色所在企业
+func Assign(role *model.Role, userIDs []int64, departmentIDs []int64, operator []string) error {
+	if err := checkGrant(role, operator); err != nil {
+		return err
+	}
+	if err := checkSubjects(role.Eid, userIDs, departmentIDs); err != nil {
+		return err
+	}
+	if err := model.AssignRole(role.Eid, role.ID, model.RoleSubjectUser, userIDs); err != nil {
+		return err
+	}
+	return model.AssignRole(role.Eid, role.ID, model.RoleSubjectDepartment, departmentIDs)
+}
+
+// Unassign 收回成员和部门的角色
+func Unassign(role *model.Role, userIDs []int64, departmentIDs []int64, operator []string) error {
+	if err := checkGrant(role, operator); err != nil {
+		return err
+	}
+	if err := model.UnassignRole(role.Eid, role.ID, model.RoleSubjectUser, userIDs); err != nil {
+		return err
+	}
+	return model.UnassignRole(role.Eid, role.ID, model.RoleSubjectDepartment, departmentIDs)
+}
+
+func checkSubjects(eid int64, userIDs []int64, departmentIDs []int64) error {
+	if len(userIDs) > 0 {
+		var count int64
+		if err := model.DB.Model(&model.User{}).Where("eid = ? AND user_id IN ?", eid, userIDs).Count(&count).Error; err != nil {
+			return err
+		}
+		if int(count) != len(uniqueIDs(userIDs)) {
+			return fmt.Errorf("%w: user not found", ErrInvalidSubject)
+		}
+	}
+	if len(departmentIDs) > 0 {
+		var count int64
+		if err := model.DB.Model(&model.Department{}).Where("eid = ? AND did IN ?", eid, departmentIDs).Count(&count).Error; err != nil {
+			return err
+		}
+		if int(count) != len(uniqueIDs(departmentIDs)) {
+			return fmt.Errorf("%w: department not found", ErrInvalidSubject)
+		}
+	}
+	return nil
+}
+
+func uniqueIDs(ids []int64) map[int64]bool {
+	set := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
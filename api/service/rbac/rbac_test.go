@@ -0,0 +1,175 @@
+package rbac
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/53AI/53AIHub/common/session"
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+	"github.com/gin-gonic/gin"
+)
+
+func setupDB(t *testing.T) {
+	testutil.SetupDB(t, &model.User{}, &model.Department{}, &model.MemberDepartmentRelation{},
+		&model.MemberBinding{}, &model.Role{}, &model.RoleAssignment{})
+}
+
+func createUser(t *testing.T, role int64, email string) *model.User {
+	user := &model.User{Username: email, Email: email, Nickname: email, Password: "x", Eid: 1,
+		Type: model.UserTypeInternal, Role: role}
+	if err := user.Create(); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestMatchPermission(t *testing.T) {
+	cases := []struct {
+		granted  []string
+		required string
+		want     bool
+	}{
+		{[]string{model.PermAll}, model.PermOrderRefund, true},
+		{[]string{"order:*"}, model.PermOrderRefund, true},
+		{[]string{model.PermOrderRead}, model.PermOrderRefund, false},
+		{[]string{model.PermAgentWrite}, model.PermAll, false},
+	}
+	for _, tc := range cases {
+		if got := model.MatchPermission(tc.granted, tc.required); got != tc.want {
+			t.Fatalf("MatchPermission(%v, %s) = %v", tc.granted, tc.required, got)
+		}
+	}
+	if !model.IsValidPermission("agent:*") || model.IsValidPermission("agent:delete") {
+		t.Fatal("unexpected IsValidPermission result")
+	}
+}
+
+func TestUserPermissions(t *testing.T) {
+	setupDB(t)
+	admin := createUser(t, model.RoleAdminUser, "admin@example.com")
+	editor := createUser(t, model.RoleCommonUser, "editor@example.com")
+	member := createUser(t, model.RoleCommonUser, "member@example.com")
+
+	// 现有管理员映射为内置管理员角色，拥有全部权限
+	if permissions, _ := UserPermissions(admin); !model.MatchPermission(permissions, model.PermPaymentWrite) {
+		t.Fatalf("admin should keep full access: %v", permissions)
+	}
+	adminPermissions, _ := UserPermissions(admin)
+
+	role := &model.Role{Eid: 1, Name: "智能体编辑", PermissionList: []string{model.PermAgentRead, model.PermAgentWrite, model.PermAgentWrite}}
+	if err := SaveRole(role, adminPermissions); err != nil {
+		t.Fatal(err)
+	}
+	if saved, _ := model.GetRole(1, role.ID); len(saved.PermissionList) != 2 {
+		t.Fatalf("permissions should be de-duplicated: %v", saved.PermissionList)
+	}
+	if err := Assign(role, []int64{editor.UserID}, nil, adminPermissions); err != nil {
+		t.Fatal(err)
+	}
+	permissions, _ := UserPermissions(editor)
+	if !model.MatchPermission(permissions, model.PermAgentWrite) || model.MatchPermission(permissions, model.PermPaymentWrite) {
+		t.Fatalf("unexpected editor permissions: %v", permissions)
+	}
+
+	// 授予部门的角色对部门成员生效
+	dept := &model.Department{EID: 1, Name: "财务"}
+	model.DB.Create(dept)
+	model.DB.Create(&model.MemberDepartmentRelation{EID: 1, DID: dept.DID, BID: member.UserID})
+	finance := &model.Role{Eid: 1, Name: "财务", PermissionList: []string{"order:*"}}
+	if err := SaveRole(finance, adminPermissions); err != nil {
+		t.Fatal(err)
+	}
+	if err := Assign(finance, nil, []int64{dept.DID}, adminPermissions); err != nil {
+		t.Fatal(err)
+	}
+	if permissions, _ := UserPermissions(member); !model.MatchPermission(permissions, model.PermOrderRefund) {
+		t.Fatalf("department role should apply to members: %v", permissions)
+	}
+
+	// 不能授予自己没有的权限，也不能把自己没有的角色授予他人
+	if err := SaveRole(&model.Role{Eid: 1, Name: "x", PermissionList: []string{model.PermAll}}, permissions); !errors.Is(err, ErrPermissionEscalation) {
+		t.Fatalf("expected ErrPermissionEscalation, got %v", err)
+	}
+	editorPermissions, _ := UserPermissions(editor)
+	if err := Assign(finance, []int64{editor.UserID}, nil, editorPermissions); !errors.Is(err, ErrPermissionEscalation) {
+		t.Fatalf("expected ErrPermissionEscalation, got %v", err)
+	}
+	if err := Assign(role, []int64{9999}, nil, adminPermissions); !errors.Is(err, ErrInvalidSubject) {
+		t.Fatalf("expected ErrInvalidSubject, got %v", err)
+	}
+
+	if err := model.DeleteRole(1, role.ID); err != nil {
+		t.Fatal(err)
+	}
+	if permissions, _ := UserPermissions(editor); model.MatchPermission(permissions, model.PermAgentWrite) {
+		t.Fatal("deleting the role should revoke its permissions")
+	}
+}
+
+func userContext(user *model.User) *gin.Context {
+	c, _ := gin.CreateTestContext(nil)
+	c.Set(session.SESSION_USER_ID, user.UserID)
+	c.Set(session.ENV_EID, user.Eid)
+	c.Set(session.SESSION_USER_ROLE, user.Role)
+	return c
+}
+
+func TestCanManageUser(t *testing.T) {
+	setupDB(t)
+	admin := createUser(t, model.RoleAdminUser, "admin@example.com")
+	creator := createUser(t, model.RoleCreatorUser, "creator@example.com")
+	operator := createUser(t, model.RoleCommonUser, "operator@example.com")
+	member := createUser(t, model.RoleCommonUser, "member@example.com")
+
+	adminPermissions, _ := UserPermissions(admin)
+	role := &model.Role{Eid: 1, Name: "成员管理", PermissionList: []string{model.PermUserRead, model.PermUserWrite}}
+	if err := SaveRole(role, adminPermissions); err != nil {
+		t.Fatal(err)
+	}
+	if err := Assign(role, []int64{operator.UserID}, nil, adminPermissions); err != nil {
+		t.Fatal(err)
+	}
+
+	// 自定义角色的 user:write 只能管理普通成员，不能处理管理员、创建者账号
+	if !CanManageUser(userContext(operator), member) {
+		t.Fatal("user:write should manage common members")
+	}
+	for _, target := range []*model.User{admin, creator} {
+		if CanManageUser(userContext(operator), target) {
+			t.Fatalf("user:write should not manage role %d", target.Role)
+		}
+		if !CanManageUser(userContext(admin), target) {
+			t.Fatalf("admin should manage role %d", target.Role)
+		}
+	}
+}
+
+func TestCheckDepartmentGrant(t *testing.T) {
+	setupDB(t)
+	admin := createUser(t, model.RoleAdminUser, "admin@example.com")
+	adminPermissions, _ := UserPermissions(admin)
+	finance := &model.Department{EID: 1, Name: "财务"}
+	sales := &model.Department{EID: 1, Name: "销售"}
+	model.DB.Create(finance)
+	model.DB.Create(sales)
+	role := &model.Role{Eid: 1, Name: "财务", PermissionList: []string{"order:*"}}
+	if err := SaveRole(role, adminPermissions); err != nil {
+		t.Fatal(err)
+	}
+	if err := Assign(role, nil, []int64{finance.DID}, adminPermissions); err != nil {
+		t.Fatal(err)
+	}
+
+	// 加入部门等同于获得部门上的角色，操作者只有 department:write 时不能借此提权
+	operator := []string{model.PermDepartmentWrite}
+	if err := CheckDepartmentGrant(1, []int64{finance.DID}, operator); !errors.Is(err, ErrPermissionEscalation) {
+		t.Fatalf("expected ErrPermissionEscalation, got %v", err)
+	}
+	if err := CheckDepartmentGrant(1, []int64{sales.DID}, operator); err != nil {
+		t.Fatalf("department without roles: %v", err)
+	}
+	if err := CheckDepartmentGrant(1, []int64{finance.DID}, append(operator, "order:*")); err != nil {
+		t.Fatalf("operator holding the role's permissions: %v", err)
+	}
+}
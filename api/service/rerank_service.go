@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/model"
 	"github.com/songquanpeng/one-api/relay/adaptor/openai"
 	"github.com/songquanpeng/one-api/relay/meta"
 	relay_model "github.com/songquanpeng/one-api/relay/model"
@@ -54,6 +55,21 @@ type RerankUsage struct {
 	TotalTokens int `json:"total_tokens" example:"150"`
 }
 
+// GetRerankChannelType 根据 rerank 模型名获取渠道类型，不支持时返回 -1
+func GetRerankChannelType(modelName string) int {
+	// 百炼模型
+	if strings.HasPrefix(modelName, "gte-rerank") {
+		return model.ChannelApiBailian
+	}
+
+	// 可以扩展支持其他厂商的 rerank 模型
+	// if strings.HasPrefix(modelName, "cohere-rerank") {
+	//     return channeltype.Cohere
+	// }
+
+	return -1 // 不支持的模型
+}
+
 // CallBailianRerankAPI 调用百炼 rerank API
 func (s *BailianRerankService) CallBailianRerankAPI(ctx context.Context, req *RerankRequest, meta *meta.Meta) (*RerankResponse, *relay_model.Usage, error) {
 	// 创建百炼适配器请求格式
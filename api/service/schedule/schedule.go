@@ -0,0 +1,419 @@
+package schedule
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/53AI/53AIHub/common"
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/common/utils/helper"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+	"github.com/53AI/53AIHub/service/hub_adaptor/custom"
+)
+
+var (
+	ErrInvalidTarget   = errors.New("invalid schedule target")
+	ErrInvalidDelivery = errors.New("invalid schedule delivery")
+	ErrInvalidTimezone = errors.New("invalid timezone")
+	ErrInvalidRunAs    = errors.New("invalid run as user")
+	// ErrRunning 上一次执行尚未结束（可能在其他实例上）
+	ErrRunning = errors.New("schedule is running")
+)
+
+const (
+	// MaxEmails 单个定时任务最多投递的邮箱数
+	MaxEmails = 20
+	// lockTTL 执行期间持有的分布式锁时长，超过后即使未释放也允许再次执行
+	lockTTL = 30 * time.Minute
+	// runTimeout 单次执行的超时时间
+	runTimeout = 10 * time.Minute
+)
+
+// Target 一次执行的目标，会话仅在投递到会话时存在
+type Target struct {
+	Schedule     *model.Schedule
+	Agent        *model.Agent
+	User         *model.User
+	Conversation *model.Conversation
+	// Parameters 工作流的最终输入（已合并智能体配置的静态参数）
+	Parameters map[string]interface{}
+}
+
+// Output 执行结果
+type Output struct {
+	Content   string
+	MessageID int64
+}
+
+// ExecuteFunc 以执行用户的身份请求智能体或工作流
+type ExecuteFunc func(ctx context.Context, target *Target) (*Output, error)
+
+// MailFunc 发送邮件
+type MailFunc func(eid int64, to []string, subject string, text string) error
+
+// Runner 执行定时任务并投递结果
+type Runner struct {
+	Execute  ExecuteFunc
+	SendMail MailFunc
+	// HTTPClient 投递 webhook，默认拒绝连接内网地址
+	HTTPClient *http.Client
+}
+
+func NewRunner(execute ExecuteFunc) *Runner {
+	return &Runner{
+		Execute:    execute,
+		SendMail:   service.SendTextEmail,
+		HTTPClient: newWebhookClient(),
+	}
+}
+
+// Location 解析任务时区，未设置时使用服务器时区
+func Location(name string) (*time.Location, error) {
+	if name == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidTimezone, name)
+	}
+	return loc, nil
+}
+
+// NextRun 计算 after 之后的下一次执行时间（毫秒），没有可执行时间时返回 0
+func NextRun(s *model.Schedule, after time.Time) (int64, error) {
+	spec, err := ParseCron(s.Cron)
+	if err != nil {
+		return 0, err
+	}
+	loc, err := Location(s.Timezone)
+	if err != nil {
+		return 0, err
+	}
+	next := spec.Next(after.In(loc))
+	if next.IsZero() {
+		return 0, nil
+	}
+	return next.UTC().UnixMilli(), nil
+}
+
+// Validate 校验定时任务：cron 与时区、目标智能体、执行用户的权限以及投递方式
+func Validate(s *model.Schedule) error {
+	if strings.TrimSpace(s.Name) == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidTarget)
+	}
+	if _, err := ParseCron(s.Cron); err != nil {
+		return err
+	}
+	if _, err := Location(s.Timezone); err != nil {
+		return err
+	}
+	agent, err := model.GetAgentByID(s.Eid, s.AgentID)
+	if err != nil {
+		return fmt.Errorf("%w: agent %d not found", ErrInvalidTarget, s.AgentID)
+	}
+	if agent.AgentType != model.AgentTypeWorkflow && strings.TrimSpace(s.Query) == "" {
+		return fmt.Errorf("%w: query is required for chat agents", ErrInvalidTarget)
+	}
+	if _, err := runAsUser(s, agent); err != nil {
+		return err
+	}
+
+	d := s.DeliveryConfig
+	if !d.Conversation && len(d.Emails) == 0 && d.WebhookURL == "" {
+		return fmt.Errorf("%w: at least one delivery is required", ErrInvalidDelivery)
+	}
+	if len(d.Emails) > MaxEmails {
+		return fmt.Errorf("%w: at most %d emails", ErrInvalidDelivery, MaxEmails)
+	}
+	for _, e := range d.Emails {
+		if !common.ValidateEmailFormat(e) {
+			return fmt.Errorf("%w: invalid email %q", ErrInvalidDelivery, e)
+		}
+	}
+	if d.WebhookURL != "" {
+		u, err := url.Parse(d.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: invalid webhook url", ErrInvalidDelivery)
+		}
+		if err := checkWebhookHost(u.Hostname()); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidDelivery, err)
+		}
+	}
+	return nil
+}
+
+// runAsUser 执行用户需为同一企业的有效成员，且有权使用该智能体；执行时也会再次检查，权限变更后立即生效
+func runAsUser(s *model.Schedule, agent *model.Agent) (*model.User, error) {
+	user, err := model.GetUserByID(s.RunAs)
+	if err != nil || user.Eid != s.Eid || user.Status == model.UserStatusDisabled {
+		return nil, fmt.Errorf("%w: user %d", ErrInvalidRunAs, s.RunAs)
+	}
+	if user.Role >= model.RoleAdminUser {
+		return user, nil
+	}
+	agentGroupIDs, err := agent.GetUserGroupIds()
+	if err != nil {
+		return nil, err
+	}
+	userGroupIDs, err := user.GetUserGroupIds()
+	if err != nil {
+		return nil, err
+	}
+	if !helper.HasIntersection(agentGroupIDs, userGroupIDs) {
+		return nil, fmt.Errorf("%w: user %d cannot access agent %d", ErrInvalidRunAs, s.RunAs, agent.AgentID)
+	}
+	return user, nil
+}
+
+// WorkflowInputs 合并工作流输入，智能体配置中 source 为 static 的参数始终使用配置值
+func WorkflowInputs(agent *model.Agent, parameters map[string]interface{}) map[string]interface{} {
+	inputs := make(map[string]interface{}, len(parameters))
+	for k, v := range parameters {
+		inputs[k] = v
+	}
+	var cfg custom.CustomConfig
+	if agent.CustomConfig != "" {
+		if err := json.Unmarshal([]byte(agent.CustomConfig), &cfg); err != nil {
+			logger.SysErrorf("parse agent %d custom config failed: %v", agent.AgentID, err)
+		}
+	}
+	for name, param := range cfg.WorkflowParams {
+		if param.Source == "static" {
+			inputs[name] = param.Value
+		}
+	}
+	return inputs
+}
+
+func lockKey(id int64) string {
+	return fmt.Sprintf("schedule:%d", id)
+}
+
+// RunDue 执行所有到期的定时任务；通过分布式锁保证多实例部署时每个任务只由一个实例触发
+func (r *Runner) RunDue(now time.Time) {
+	schedules, err := model.GetDueSchedules(now.UTC().UnixMilli())
+	if err != nil {
+		logger.SysErrorf("get due schedules failed: %v", err)
+		return
+	}
+	for _, s := range schedules {
+		key := lockKey(s.ID)
+		if !common.LOCKER.TryLock(key, lockTTL) {
+			continue
+		}
+		// 加锁后重新读取，其他实例可能已经执行并推进了下次执行时间
+		current, err := model.GetSchedule(s.Eid, s.ID)
+		if err != nil || !current.Enabled || current.NextRunTime == 0 || current.NextRunTime > now.UTC().UnixMilli() {
+			common.LOCKER.Unlock(key)
+			continue
+		}
+		// 先推进下次执行时间，执行期间宕机也不会重复触发
+		next, err := NextRun(current, now)
+		if err != nil {
+			logger.SysErrorf("schedule %d next run failed: %v", current.ID, err)
+		}
+		if err := model.UpdateScheduleColumns(current.ID, map[string]interface{}{"next_run_time": next}); err != nil {
+			logger.SysErrorf("update schedule %d next run failed: %v", current.ID, err)
+			common.LOCKER.Unlock(key)
+			continue
+		}
+		go func(s *model.Schedule) {
+			defer common.LOCKER.Unlock(key)
+			if _, err := r.Run(s, model.ScheduleTriggerCron); err != nil {
+				logger.SysErrorf("schedule %d run failed: %v", s.ID, err)
+			}
+		}(current)
+	}
+}
+
+// Start 手动触发一次执行，返回已创建的执行记录，执行在后台进行
+func (r *Runner) Start(s *model.Schedule) (*model.ScheduleRun, error) {
+	key := lockKey(s.ID)
+	if !common.LOCKER.TryLock(key, lockTTL) {
+		return nil, ErrRunning
+	}
+	run, err := r.newRun(s, model.ScheduleTriggerManual)
+	if err != nil {
+		common.LOCKER.Unlock(key)
+		return nil, err
+	}
+	go func() {
+		defer common.LOCKER.Unlock(key)
+		r.execute(s, run)
+	}()
+	return run, nil
+}
+
+// Run 同步执行一次并投递结果，执行失败也会记录在执行记录中
+func (r *Runner) Run(s *model.Schedule, trigger string) (*model.ScheduleRun, error) {
+	run, err := r.newRun(s, trigger)
+	if err != nil {
+		return nil, err
+	}
+	r.execute(s, run)
+	return run, nil
+}
+
+func (r *Runner) newRun(s *model.Schedule, trigger string) (*model.ScheduleRun, error) {
+	run := &model.ScheduleRun{
+		Eid:        s.Eid,
+		ScheduleID: s.ID,
+		Trigger:    trigger,
+		Status:     model.ScheduleRunStatusRunning,
+	}
+	return run, model.CreateScheduleRun(run)
+}
+
+func (r *Runner) execute(s *model.Schedule, run *model.ScheduleRun) {
+	ctx, cancel := context.WithTimeout(context.Background(), runTimeout)
+	defer cancel()
+
+	output, err := r.executeTarget(ctx, s)
+	if err != nil {
+		run.Status = model.ScheduleRunStatusFailed
+		run.ErrorMessage = err.Error()
+	} else {
+		run.Status = model.ScheduleRunStatusSuccess
+		run.Output = output.Content
+		run.MessageID = output.MessageID
+	}
+	if err := r.deliver(ctx, s, run); err != nil {
+		run.DeliveryError = err.Error()
+	}
+	run.FinishedTime = time.Now().UTC().UnixMilli()
+	if err := model.UpdateScheduleRun(run); err != nil {
+		logger.SysErrorf("update schedule run %d failed: %v", run.ID, err)
+	}
+	if err := model.UpdateScheduleColumns(s.ID, map[string]interface{}{
+		"last_run_time": run.CreatedTime,
+		"last_status":   run.Status,
+	}); err != nil {
+		logger.SysErrorf("update schedule %d last run failed: %v", s.ID, err)
+	}
+}
+
+func (r *Runner) executeTarget(ctx context.Context, s *model.Schedule) (*Output, error) {
+	agent, err := model.GetAgentByID(s.Eid, s.AgentID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: agent %d not found", ErrInvalidTarget, s.AgentID)
+	}
+	if !agent.Enable {
+		return nil, fmt.Errorf("%w: agent %d is disabled", ErrInvalidTarget, s.AgentID)
+	}
+	user, err := runAsUser(s, agent)
+	if err != nil {
+		return nil, err
+	}
+	target := &Target{Schedule: s, Agent: agent, User: user}
+	if agent.AgentType == model.AgentTypeWorkflow {
+		target.Parameters = WorkflowInputs(agent, s.Parameters)
+	}
+	if s.DeliveryConfig.Conversation {
+		if target.Conversation, err = scheduleConversation(s, user); err != nil {
+			return nil, err
+		}
+	}
+	return r.Execute(ctx, target)
+}
+
+// scheduleConversation 复用任务的会话，会话被删除或执行用户变更后重新创建
+func scheduleConversation(s *model.Schedule, user *model.User) (*model.Conversation, error) {
+	if s.ConversationID > 0 {
+		conversation, err := model.GetConversationByIdAndUserId(s.Eid, s.ConversationID, user.UserID)
+		if err == nil && conversation.AgentID == s.AgentID && conversation.Status != model.ConversationStatusDeleted {
+			return conversation, nil
+		}
+	}
+	conversation := &model.Conversation{
+		Eid:     s.Eid,
+		UserID:  user.UserID,
+		AgentID: s.AgentID,
+		Title:   s.Name,
+		Status:  model.ConversationStatusActive,
+	}
+	if err := model.CreateConversation(conversation); err != nil {
+		return nil, err
+	}
+	s.ConversationID = conversation.ConversationID
+	if err := model.UpdateScheduleColumns(s.ID, map[string]interface{}{"conversation_id": conversation.ConversationID}); err != nil {
+		return nil, err
+	}
+	return conversation, nil
+}
+
+// WebhookPayload 推送到 webhook 的内容
+type WebhookPayload struct {
+	ScheduleID   int64  `json:"schedule_id"`
+	ScheduleName string `json:"schedule_name"`
+	RunID        int64  `json:"run_id"`
+	Trigger      string `json:"trigger"`
+	Status       string `json:"status"`
+	Output       string `json:"output"`
+	Error        string `json:"error,omitempty"`
+	Time         int64  `json:"time"`
+}
+
+// deliver 投递到邮件与 webhook，会话投递在执行时已完成；各方式互不影响，失败原因合并返回
+func (r *Runner) deliver(ctx context.Context, s *model.Schedule, run *model.ScheduleRun) error {
+	var errs []error
+	success := run.Status == model.ScheduleRunStatusSuccess
+	if len(s.DeliveryConfig.Emails) > 0 {
+		subject, text := s.Name, run.Output
+		if !success {
+			subject = fmt.Sprintf("%s（执行失败）", s.Name)
+			text = "执行失败：" + run.ErrorMessage
+		}
+		if err := r.SendMail(s.Eid, s.DeliveryConfig.Emails, subject, text); err != nil {
+			errs = append(errs, fmt.Errorf("email: %w", err))
+		}
+	}
+	if s.DeliveryConfig.WebhookURL != "" {
+		status := "success"
+		if !success {
+			status = "failed"
+		}
+		payload := &WebhookPayload{
+			ScheduleID:   s.ID,
+			ScheduleName: s.Name,
+			RunID:        run.ID,
+			Trigger:      run.Trigger,
+			Status:       status,
+			Output:       run.Output,
+			Error:        run.ErrorMessage,
+			Time:         time.Now().UTC().UnixMilli(),
+		}
+		if err := r.postWebhook(ctx, s.DeliveryConfig.WebhookURL, payload); err != nil {
+			errs = append(errs, fmt.Errorf("webhook: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Runner) postWebhook(ctx context.Context, webhookURL string, payload *WebhookPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("http status code: %d", resp.StatusCode)
+	}
+	return nil
+}
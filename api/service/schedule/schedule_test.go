@@ -0,0 +1,303 @@
+package schedule
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/53AI/53AIHub/common"
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, expr := range []string{"* * * * *", "*/15 9-18 * * 1-5", "0 0 1,15 * *", "5/10 * * * 7", "@daily", "@Hourly"} {
+		if _, err := ParseCron(expr); err != nil {
+			t.Errorf("ParseCron(%q) = %v", expr, err)
+		}
+	}
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *", "@every 5m"} {
+		if _, err := ParseCron(expr); !errors.Is(err, ErrInvalidCron) {
+			t.Errorf("ParseCron(%q) err = %v", expr, err)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	loc, _ := time.LoadLocation("Asia/Shanghai")
+	from := time.Date(2026, 10, 16, 9, 30, 20, 0, loc) // 周五
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2026, 10, 16, 9, 31, 0, 0, loc)},
+		{"30 9 * * *", time.Date(2026, 10, 17, 9, 30, 0, 0, loc)},
+		{"0 9 * * 1-5", time.Date(2026, 10, 19, 9, 0, 0, 0, loc)},
+		{"*/20 * * * *", time.Date(2026, 10, 16, 9, 40, 0, 0, loc)},
+		{"0 0 1 * *", time.Date(2026, 11, 1, 0, 0, 0, 0, loc)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, loc)},
+		// 日与周都有限定时满足其一即可：18 日或周日
+		{"0 8 18 * 0", time.Date(2026, 10, 18, 8, 0, 0, 0, loc)},
+		{"0 8 * * 7", time.Date(2026, 10, 18, 8, 0, 0, 0, loc)},
+	}
+	for _, tc := range cases {
+		spec, err := ParseCron(tc.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := spec.Next(from); !got.Equal(tc.want) {
+			t.Errorf("%q: Next = %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+	spec, _ := ParseCron("0 0 31 2 *")
+	if got := spec.Next(from); !got.IsZero() {
+		t.Errorf("impossible date: Next = %v", got)
+	}
+
+	s := &model.Schedule{Cron: "0 9 * * *", Timezone: "Asia/Shanghai"}
+	next, err := NextRun(s, from)
+	if err != nil || next != time.Date(2026, 10, 17, 1, 0, 0, 0, time.UTC).UnixMilli() {
+		t.Fatalf("NextRun = %d, %v", next, err)
+	}
+	s.Timezone = "Mars/Olympus"
+	if _, err := NextRun(s, from); !errors.Is(err, ErrInvalidTimezone) {
+		t.Fatalf("timezone err = %v", err)
+	}
+}
+
+type fixture struct {
+	agent, workflow *model.Agent
+	admin, member   *model.User
+}
+
+func setup(t *testing.T) *fixture {
+	common.RedisEnabled = false
+	common.InitLocker()
+	db := testutil.SetupDB(t, &model.Agent{}, &model.User{}, &model.ResourcePermission{}, &model.Conversation{},
+		&model.Schedule{}, &model.ScheduleRun{})
+	f := &fixture{
+		agent: &model.Agent{Eid: 1, Name: "日报", Enable: true},
+		workflow: &model.Agent{Eid: 1, Name: "汇总", Enable: true, AgentType: model.AgentTypeWorkflow,
+			CustomConfig: `{"workflow_params":{"region":{"source":"static","value":"华东"},"date":{"source":"user_input"}}}`},
+		admin:  &model.User{Eid: 1, Username: "admin", Role: model.RoleAdminUser, Status: model.UserStatusJoined},
+		member: &model.User{Eid: 1, Username: "member", Role: 1, Status: model.UserStatusJoined, GroupId: 20},
+	}
+	for _, v := range []interface{}{f.agent, f.workflow, f.admin, f.member} {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Create(&model.ResourcePermission{GroupID: 10, ResourceID: f.agent.AgentID,
+		ResourceType: model.ResourceTypeAgent, Permission: model.PermissionRead}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestValidate(t *testing.T) {
+	f := setup(t)
+	valid := func() *model.Schedule {
+		return &model.Schedule{Eid: 1, Name: "日报", Cron: "0 9 * * *", AgentID: f.agent.AgentID, Query: "总结昨天", RunAs: f.admin.UserID,
+			DeliveryConfig: model.ScheduleDelivery{Conversation: true, Emails: []string{"ops@example.com"}, WebhookURL: "https://example.com/hook"}}
+	}
+	if err := Validate(valid()); err != nil {
+		t.Fatal(err)
+	}
+	workflow := valid()
+	workflow.AgentID, workflow.Query = f.workflow.AgentID, ""
+	if err := Validate(workflow); err != nil {
+		t.Fatalf("workflow without query: %v", err)
+	}
+
+	cases := map[string]struct {
+		mutate func(s *model.Schedule)
+		err    error
+	}{
+		"cron":        {func(s *model.Schedule) { s.Cron = "* *" }, ErrInvalidCron},
+		"timezone":    {func(s *model.Schedule) { s.Timezone = "Nowhere/City" }, ErrInvalidTimezone},
+		"agent":       {func(s *model.Schedule) { s.AgentID = 404 }, ErrInvalidTarget},
+		"query":       {func(s *model.Schedule) { s.Query = " " }, ErrInvalidTarget},
+		"no delivery": {func(s *model.Schedule) { s.DeliveryConfig = model.ScheduleDelivery{} }, ErrInvalidDelivery},
+		"email":       {func(s *model.Schedule) { s.DeliveryConfig.Emails = []string{"not-an-email"} }, ErrInvalidDelivery},
+		"webhook":     {func(s *model.Schedule) { s.DeliveryConfig.WebhookURL = "ftp://example.com" }, ErrInvalidDelivery},
+		"loopback":    {func(s *model.Schedule) { s.DeliveryConfig.WebhookURL = "http://127.0.0.1:8080/hook" }, ErrInvalidDelivery},
+		"localhost":   {func(s *model.Schedule) { s.DeliveryConfig.WebhookURL = "http://LOCALHOST./hook" }, ErrInvalidDelivery},
+		"private":     {func(s *model.Schedule) { s.DeliveryConfig.WebhookURL = "http://10.0.0.8/hook" }, ErrInvalidDelivery},
+		"ipv6":        {func(s *model.Schedule) { s.DeliveryConfig.WebhookURL = "http://[fd00::1]/hook" }, ErrInvalidDelivery},
+		"metadata":    {func(s *model.Schedule) { s.DeliveryConfig.WebhookURL = "http://169.254.169.254/latest/meta-data" }, ErrInvalidDelivery},
+		"other eid":   {func(s *model.Schedule) { s.Eid = 2 }, ErrInvalidTarget},
+		"no user":     {func(s *model.Schedule) { s.RunAs = 404 }, ErrInvalidRunAs},
+		// 成员所在分组没有该智能体的权限
+		"no access": {func(s *model.Schedule) { s.RunAs = f.member.UserID }, ErrInvalidRunAs},
+	}
+	for name, tc := range cases {
+		s := valid()
+		tc.mutate(s)
+		if err := Validate(s); !errors.Is(err, tc.err) {
+			t.Errorf("%s: err = %v", name, err)
+		}
+	}
+}
+
+func TestWorkflowInputs(t *testing.T) {
+	f := setup(t)
+	inputs := WorkflowInputs(f.workflow, map[string]interface{}{"date": "yesterday", "region": "华北"})
+	if inputs["date"] != "yesterday" || inputs["region"] != "华东" {
+		t.Fatalf("inputs = %+v", inputs)
+	}
+}
+
+func TestRun(t *testing.T) {
+	f := setup(t)
+	var mu sync.Mutex
+	var payloads []WebhookPayload
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p WebhookPayload
+		_ = json.NewDecoder(r.Body).Decode(&p)
+		mu.Lock()
+		payloads = append(payloads, p)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	var mails []string
+	var targets []*Target
+	fail := false
+	runner := NewRunner(func(ctx context.Context, target *Target) (*Output, error) {
+		targets = append(targets, target)
+		if fail {
+			return nil, errors.New("channel unavailable")
+		}
+		return &Output{Content: "昨日销售额 100 万", MessageID: 7}, nil
+	})
+	runner.SendMail = func(eid int64, to []string, subject string, text string) error {
+		mails = append(mails, subject+"|"+text)
+		return errors.New("smtp down")
+	}
+	// 测试 webhook 监听在本机，默认客户端会拒绝连接
+	runner.HTTPClient = hook.Client()
+
+	s := &model.Schedule{Eid: 1, Name: "日报", Cron: "0 9 * * *", AgentID: f.workflow.AgentID, RunAs: f.admin.UserID, Enabled: true,
+		Parameters:     map[string]interface{}{"date": "yesterday"},
+		DeliveryConfig: model.ScheduleDelivery{Conversation: true, Emails: []string{"ops@example.com"}, WebhookURL: hook.URL}}
+	if err := model.SaveSchedule(s); err != nil {
+		t.Fatal(err)
+	}
+
+	run, err := runner.Run(s, model.ScheduleTriggerManual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.Status != model.ScheduleRunStatusSuccess || run.MessageID != 7 || run.Output != "昨日销售额 100 万" || run.FinishedTime == 0 {
+		t.Fatalf("run = %+v", run)
+	}
+	// 邮件失败记录在投递错误中，不影响执行结果与 webhook
+	if run.DeliveryError == "" || len(mails) != 1 || len(payloads) != 1 || payloads[0].Status != "success" || payloads[0].RunID != run.ID {
+		t.Fatalf("delivery error = %q, mails = %v, payloads = %+v", run.DeliveryError, mails, payloads)
+	}
+	target := targets[0]
+	if target.Conversation == nil || target.Conversation.UserID != f.admin.UserID || target.Parameters["region"] != "华东" {
+		t.Fatalf("target = %+v", target)
+	}
+
+	// 第二次执行复用同一会话，失败时同样投递
+	fail = true
+	run, _ = runner.Run(s, model.ScheduleTriggerCron)
+	if run.Status != model.ScheduleRunStatusFailed || run.ErrorMessage != "channel unavailable" {
+		t.Fatalf("failed run = %+v", run)
+	}
+	if targets[1].Conversation.ConversationID != target.Conversation.ConversationID || payloads[1].Status != "failed" {
+		t.Fatalf("conversation = %d, payload = %+v", targets[1].Conversation.ConversationID, payloads[1])
+	}
+	saved, _ := model.GetSchedule(1, s.ID)
+	if saved.LastStatus != model.ScheduleRunStatusFailed || saved.ConversationID != target.Conversation.ConversationID {
+		t.Fatalf("schedule = %+v", saved)
+	}
+	count, _, _ := model.GetScheduleRuns(1, s.ID, 0, 10)
+	if count != 2 {
+		t.Fatalf("runs = %d", count)
+	}
+
+	// 智能体停用后执行失败
+	model.DB.Model(f.workflow).Update("enable", false)
+	if run, _ := runner.Run(s, model.ScheduleTriggerManual); run.Status != model.ScheduleRunStatusFailed || len(targets) != 2 {
+		t.Fatalf("disabled agent run = %+v", run)
+	}
+}
+
+func TestRunDue(t *testing.T) {
+	f := setup(t)
+	runs := make(chan int64, 4)
+	runner := NewRunner(func(ctx context.Context, target *Target) (*Output, error) {
+		runs <- target.Schedule.ID
+		return &Output{Content: "ok"}, nil
+	})
+	now := time.Now()
+	due := &model.Schedule{Eid: 1, Name: "due", Cron: "* * * * *", AgentID: f.agent.AgentID, Query: "hi", RunAs: f.admin.UserID,
+		Enabled: true, NextRunTime: now.Add(-time.Minute).UTC().UnixMilli()}
+	later := &model.Schedule{Eid: 1, Name: "later", Cron: "* * * * *", AgentID: f.agent.AgentID, Query: "hi", RunAs: f.admin.UserID,
+		Enabled: true, NextRunTime: now.Add(time.Hour).UTC().UnixMilli()}
+	locked := &model.Schedule{Eid: 1, Name: "locked", Cron: "* * * * *", AgentID: f.agent.AgentID, Query: "hi", RunAs: f.admin.UserID,
+		Enabled: true, NextRunTime: now.Add(-time.Minute).UTC().UnixMilli()}
+	for _, s := range []*model.Schedule{due, later, locked} {
+		if err := model.SaveSchedule(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 其他实例正在执行的任务不会重复触发
+	if !common.LOCKER.TryLock(lockKey(locked.ID), time.Minute) {
+		t.Fatal("lock failed")
+	}
+	defer common.LOCKER.Unlock(lockKey(locked.ID))
+
+	runner.RunDue(now)
+	select {
+	case id := <-runs:
+		if id != due.ID {
+			t.Fatalf("ran schedule %d", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("due schedule not run")
+	}
+	saved, _ := model.GetSchedule(1, due.ID)
+	if saved.NextRunTime <= now.UTC().UnixMilli() {
+		t.Fatalf("next run not advanced: %d", saved.NextRunTime)
+	}
+	// 等待后台执行释放锁后再次检查：下次执行时间已推进，不会重复执行
+	deadline := time.Now().Add(5 * time.Second)
+	for !common.LOCKER.TryLock(lockKey(due.ID), time.Minute) {
+		if time.Now().After(deadline) {
+			t.Fatal("lock not released")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	common.LOCKER.Unlock(lockKey(due.ID))
+	runner.RunDue(now)
+	select {
+	case id := <-runs:
+		t.Fatalf("schedule %d ran twice", id)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// 域名解析到内网地址时在连接前拒绝
+func TestWebhookRejectsInternalAddress(t *testing.T) {
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("webhook should not reach an internal address")
+	}))
+	defer hook.Close()
+
+	runner := NewRunner(nil)
+	for _, webhookURL := range []string{hook.URL, strings.Replace(hook.URL, "127.0.0.1", "localhost", 1)} {
+		err := runner.postWebhook(context.Background(), webhookURL, &WebhookPayload{})
+		if !errors.Is(err, errInternalAddress) {
+			t.Errorf("%s: err = %v", webhookURL, err)
+		}
+	}
+}
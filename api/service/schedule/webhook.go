@@ -0,0 +1,53 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var errInternalAddress = errors.New("webhook destination is an internal address")
+
+// internalIP 回环、私有、链路本地及未指定地址，webhook 不允许投递到这些地址，避免被用于探测内网服务
+func internalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified()
+}
+
+// checkWebhookHost 保存时校验 webhook 主机，域名在连接时按解析出的地址再次检查
+func checkWebhookHost(host string) error {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errInternalAddress
+	}
+	if ip := net.ParseIP(host); ip != nil && internalIP(ip) {
+		return errInternalAddress
+	}
+	return nil
+}
+
+// webhookDialControl 在建立连接前检查实际连接的地址，覆盖域名解析到内网地址与重定向的情况
+func webhookDialControl(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || internalIP(ip) {
+		return fmt.Errorf("%w: %s", errInternalAddress, host)
+	}
+	return nil
+}
+
+// newWebhookClient 直接连接 webhook 地址，不经过环境变量中的代理，以保证连接地址检查有效
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: webhookDialControl}
+	return &http.Client{
+		Timeout:   15 * time.Second,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 10 * time.Second},
+	}
+}
@@ -0,0 +1,160 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// FilterExpr 过滤条件中的一个比较表达式，如 userName eq "john@example.com"
+type FilterExpr struct {
+	Attribute string
+	Operator  string
+	Value     string
+}
+
+// 支持的比较运算符，RFC 7644 3.4.2.2
+var filterOperators = map[string]bool{
+	"eq": true, "ne": true, "co": true, "sw": true, "ew": true,
+	"pr": true, "gt": true, "ge": true, "lt": true, "le": true,
+}
+
+func errInvalidFilter(detail string) *Error {
+	return NewError(http.StatusBadRequest, "invalidFilter", detail)
+}
+
+// ParseFilter 解析以 and 连接的过滤条件，不支持 or、not 与括号分组
+func ParseFilter(filter string) ([]FilterExpr, error) {
+	tokens, err := tokenizeFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	exprs := make([]FilterExpr, 0)
+	for i := 0; i < len(tokens); {
+		if len(exprs) > 0 {
+			if !strings.EqualFold(tokens[i], "and") {
+				return nil, errInvalidFilter(fmt.Sprintf("unsupported logical operator %q", tokens[i]))
+			}
+			i++
+		}
+		if i+1 >= len(tokens) {
+			return nil, errInvalidFilter("incomplete filter expression")
+		}
+		expr := FilterExpr{
+			Attribute: normalizeAttributePath(tokens[i]),
+			Operator:  strings.ToLower(tokens[i+1]),
+		}
+		if !filterOperators[expr.Operator] {
+			return nil, errInvalidFilter(fmt.Sprintf("unsupported operator %q", tokens[i+1]))
+		}
+		i += 2
+		if expr.Operator != "pr" {
+			if i >= len(tokens) {
+				return nil, errInvalidFilter("missing comparison value")
+			}
+			expr.Value = unquoteFilterValue(tokens[i])
+			i++
+		}
+		exprs = append(exprs, expr)
+	}
+	return exprs, nil
+}
+
+// tokenizeFilter 按空白切分，保留双引号字符串与中括号内的值过滤
+func tokenizeFilter(filter string) ([]string, error) {
+	tokens := make([]string, 0)
+	var current strings.Builder
+	inQuote, escaped, depth := false, false, 0
+	for _, r := range strings.TrimSpace(filter) {
+		switch {
+		case escaped:
+			escaped = false
+		case inQuote && r == '\\':
+			escaped = true
+		case r == '"':
+			inQuote = !inQuote
+		case !inQuote && r == '[':
+			depth++
+		case !inQuote && r == ']':
+			depth--
+		case !inQuote && depth == 0 && (r == ' ' || r == '\t'):
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+			continue
+		}
+		current.WriteRune(r)
+	}
+	if inQuote || depth != 0 {
+		return nil, errInvalidFilter("unbalanced quotes or brackets in filter")
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens, nil
+}
+
+func unquoteFilterValue(token string) string {
+	if strings.HasPrefix(token, "\"") {
+		var value string
+		if err := json.Unmarshal([]byte(token), &value); err == nil {
+			return value
+		}
+		return strings.Trim(token, "\"")
+	}
+	return token
+}
+
+// normalizeAttributePath 去掉 schema 前缀与值过滤，统一为小写，如 emails[type eq "work"].value -> emails.value
+func normalizeAttributePath(path string) string {
+	for _, schema := range []string{SchemaUser + ":", SchemaGroup + ":"} {
+		if len(path) > len(schema) && strings.EqualFold(path[:len(schema)], schema) {
+			path = path[len(schema):]
+		}
+	}
+	if start := strings.Index(path, "["); start >= 0 {
+		if end := strings.LastIndex(path, "]"); end > start {
+			path = path[:start] + path[end+1:]
+		}
+	}
+	return strings.ToLower(path)
+}
+
+// applyFilter 将过滤条件转换为查询条件，columns 为属性到列名的映射，字符串比较不区分大小写
+func applyFilter(db *gorm.DB, exprs []FilterExpr, columns map[string]string) (*gorm.DB, error) {
+	for _, expr := range exprs {
+		column, ok := columns[expr.Attribute]
+		if !ok {
+			return nil, errInvalidFilter(fmt.Sprintf("unsupported filter attribute %q", expr.Attribute))
+		}
+		lowerColumn := "LOWER(" + column + ")"
+		value := strings.ToLower(expr.Value)
+		switch expr.Operator {
+		case "eq":
+			db = db.Where(lowerColumn+" = ?", value)
+		case "ne":
+			db = db.Where(lowerColumn+" <> ?", value)
+		case "co":
+			db = db.Where(lowerColumn+" LIKE ?", "%"+value+"%")
+		case "sw":
+			db = db.Where(lowerColumn+" LIKE ?", value+"%")
+		case "ew":
+			db = db.Where(lowerColumn+" LIKE ?", "%"+value)
+		case "pr":
+			db = db.Where(column + " IS NOT NULL AND " + column + " <> ''")
+		case "gt":
+			db = db.Where(lowerColumn+" > ?", value)
+		case "ge":
+			db = db.Where(lowerColumn+" >= ?", value)
+		case "lt":
+			db = db.Where(lowerColumn+" < ?", value)
+		case "le":
+			db = db.Where(lowerColumn+" <= ?", value)
+		}
+	}
+	return db, nil
+}
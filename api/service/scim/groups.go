@@ -0,0 +1,402 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/53AI/53AIHub/model"
+	"gorm.io/gorm"
+)
+
+// groupFilterColumns 组过滤属性与列的映射，externalId 保存在部门绑定值上
+var groupFilterColumns = map[string]string{
+	"id":          "did",
+	"displayname": "name",
+	"externalid":  "bindvalue",
+}
+
+func (s *Service) groupQuery() *gorm.DB {
+	return model.DB.Model(&model.Department{}).
+		Where("eid = ? AND `from` = ?", s.Eid, model.DepartmentFromSCIM)
+}
+
+// ListGroups 按过滤条件分页列出 SCIM 来源的部门
+func (s *Service) ListGroups(params ListParams) (*ListResponse, error) {
+	params.normalize()
+	exprs, err := ParseFilter(params.Filter)
+	if err != nil {
+		return nil, err
+	}
+	query, err := applyFilter(s.groupQuery(), exprs, groupFilterColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+	var departments []*model.Department
+	err = query.Order("did ASC").Offset(params.StartIndex - 1).Limit(params.Count).Find(&departments).Error
+	if err != nil {
+		return nil, err
+	}
+
+	list := &ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   params.StartIndex,
+		ItemsPerPage: len(departments),
+		Resources:    make([]interface{}, 0, len(departments)),
+	}
+	for _, department := range departments {
+		resource, err := s.toGroupResource(department, !params.excludes("members"))
+		if err != nil {
+			return nil, err
+		}
+		list.Resources = append(list.Resources, resource)
+	}
+	return list, nil
+}
+
+func (s *Service) GetGroup(id string, withMembers bool) (*Group, error) {
+	department, err := s.findGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.toGroupResource(department, withMembers)
+}
+
+// CreateGroup 创建部门并设置成员
+func (s *Service) CreateGroup(input *Group) (*Group, error) {
+	if err := s.validateGroupInput(input, 0); err != nil {
+		return nil, err
+	}
+	// 先校验成员，成员不存在或不可修改时不留下空部门
+	if _, err := s.memberBindings(memberIDs(input.Members)); err != nil {
+		return nil, err
+	}
+	department := &model.Department{
+		EID:  s.Eid,
+		Name: input.DisplayName,
+		From: model.DepartmentFromSCIM,
+	}
+	if err := model.CreateDepartment(department); err != nil {
+		return nil, err
+	}
+	if input.ExternalID != "" {
+		department.BindValue = input.ExternalID
+		if err := model.DB.Model(department).Update("bindvalue", department.BindValue).Error; err != nil {
+			return nil, err
+		}
+	}
+	if err := s.replaceGroupMembers(department, memberIDs(input.Members)); err != nil {
+		return nil, err
+	}
+	return s.toGroupResource(department, true)
+}
+
+// ReplaceGroup 以请求内容整体替换部门名称与成员（PUT）
+func (s *Service) ReplaceGroup(id string, input *Group) (*Group, error) {
+	department, err := s.findGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateGroupInput(input, department.DID); err != nil {
+		return nil, err
+	}
+	if err := s.updateGroupAttributes(department, input.DisplayName, input.ExternalID); err != nil {
+		return nil, err
+	}
+	if err := s.replaceGroupMembers(department, memberIDs(input.Members)); err != nil {
+		return nil, err
+	}
+	return s.toGroupResource(department, true)
+}
+
+// PatchGroup 应用 PATCH 操作，成员增删只修改涉及的成员，不影响其它成员
+func (s *Service) PatchGroup(id string, patch *PatchRequest) (*Group, error) {
+	department, err := s.findGroup(id)
+	if err != nil {
+		return nil, err
+	}
+	displayName, externalID := department.Name, groupExternalID(department)
+	for _, operation := range patch.Operations {
+		op := strings.ToLower(operation.Op)
+		if op != "add" && op != "replace" && op != "remove" {
+			return nil, errInvalidValue(fmt.Sprintf("unsupported patch op %q", operation.Op))
+		}
+
+		values := map[string]json.RawMessage{}
+		if operation.Path == "" {
+			if op == "remove" {
+				return nil, NewError(http.StatusBadRequest, "noTarget", "path is required for remove")
+			}
+			if err := json.Unmarshal(operation.Value, &values); err != nil {
+				return nil, errInvalidValue("value must be an object when path is empty")
+			}
+		} else {
+			values[operation.Path] = operation.Value
+		}
+
+		for path, value := range values {
+			switch normalizeAttributePath(path) {
+			case "displayname":
+				if op == "remove" {
+					return nil, errInvalidValue("displayName cannot be removed")
+				}
+				if err := unmarshalValue(value, &displayName); err != nil {
+					return nil, err
+				}
+			case "externalid":
+				externalID = ""
+				if op != "remove" {
+					if err := unmarshalValue(value, &externalID); err != nil {
+						return nil, err
+					}
+				}
+			case "members":
+				if err := s.patchGroupMembers(department, op, path, value); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if err := s.validateGroupInput(&Group{DisplayName: displayName}, department.DID); err != nil {
+		return nil, err
+	}
+	if err := s.updateGroupAttributes(department, displayName, externalID); err != nil {
+		return nil, err
+	}
+	return s.toGroupResource(department, true)
+}
+
+// DeleteGroup 删除部门及其成员关系
+func (s *Service) DeleteGroup(id string) error {
+	department, err := s.findGroup(id)
+	if err != nil {
+		return err
+	}
+	return model.DeleteDepartment(s.Eid, department.DID, true)
+}
+
+func (s *Service) findGroup(id string) (*model.Department, error) {
+	did, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, errNotFound(ResourceTypeGroup, id)
+	}
+	var department model.Department
+	if err := s.groupQuery().Where("did = ?", did).First(&department).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errNotFound(ResourceTypeGroup, id)
+		}
+		return nil, err
+	}
+	return &department, nil
+}
+
+func (s *Service) validateGroupInput(input *Group, excludeDID int64) error {
+	input.DisplayName = strings.TrimSpace(input.DisplayName)
+	if input.DisplayName == "" {
+		return errInvalidValue("displayName is required")
+	}
+	var count int64
+	err := s.groupQuery().Where("name = ? AND did <> ?", input.DisplayName, excludeDID).Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errUniqueness(fmt.Sprintf("group %s already exists", input.DisplayName))
+	}
+	return nil
+}
+
+func (s *Service) updateGroupAttributes(department *model.Department, displayName string, externalID string) error {
+	department.Name = displayName
+	department.BindValue = externalID
+	if department.BindValue == "" {
+		department.BindValue = strconv.FormatInt(department.DID, 10)
+	}
+	return model.DB.Model(department).Updates(map[string]interface{}{
+		"name":      department.Name,
+		"bindvalue": department.BindValue,
+	}).Error
+}
+
+// groupExternalID 部门绑定值默认为部门ID，与部门ID相同时视为未设置 externalId
+func groupExternalID(department *model.Department) string {
+	if department.BindValue == strconv.FormatInt(department.DID, 10) {
+		return ""
+	}
+	return department.BindValue
+}
+
+// patchGroupMembers 处理 members 路径的增删改，支持 members[value eq "1"] 形式的移除
+func (s *Service) patchGroupMembers(department *model.Department, op string, path string, value json.RawMessage) error {
+	var members []MultiValue
+	if len(value) > 0 && string(value) != "null" {
+		if err := unmarshalValue(value, &members); err != nil {
+			return err
+		}
+	}
+	ids := memberIDs(members)
+	exprs, err := memberPathFilter(path)
+	if err != nil {
+		return err
+	}
+	for _, expr := range exprs {
+		if expr.Attribute == "value" && expr.Operator == "eq" {
+			ids = append(ids, expr.Value)
+		}
+	}
+
+	switch op {
+	case "add":
+		return s.addGroupMembers(department, ids)
+	case "replace":
+		return s.replaceGroupMembers(department, ids)
+	default:
+		if len(ids) == 0 {
+			return s.replaceGroupMembers(department, nil)
+		}
+		return s.removeGroupMembers(department, ids)
+	}
+}
+
+// memberPathFilter 解析 members[...] 中的值过滤
+func memberPathFilter(path string) ([]FilterExpr, error) {
+	start, end := strings.Index(path, "["), strings.LastIndex(path, "]")
+	if start < 0 || end <= start {
+		return nil, nil
+	}
+	return ParseFilter(path[start+1 : end])
+}
+
+func memberIDs(members []MultiValue) []string {
+	ids := make([]string, 0, len(members))
+	for _, member := range members {
+		if member.Value != "" {
+			ids = append(ids, member.Value)
+		}
+	}
+	return ids
+}
+
+// memberBindings 获取成员的 SCIM 绑定，仅通过 SCIM 创建的成员可以调整所属组
+func (s *Service) memberBindings(ids []string) ([]*model.MemberBinding, error) {
+	bindings := make([]*model.MemberBinding, 0, len(ids))
+	for _, id := range ids {
+		_, binding, err := s.findManagedUser(id)
+		if err != nil {
+			return nil, err
+		}
+		bindings = append(bindings, binding)
+	}
+	return bindings, nil
+}
+
+func (s *Service) addGroupMembers(department *model.Department, ids []string) error {
+	bindings, err := s.memberBindings(ids)
+	if err != nil {
+		return err
+	}
+	for _, binding := range bindings {
+		var count int64
+		err := model.DB.Model(&model.MemberDepartmentRelation{}).
+			Where("eid = ? AND did = ? AND bid = ? AND `from` = ?", s.Eid, department.DID, binding.ID, model.MemberDepartmentRelationFromSCIM).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		err = model.CreateMemberDepartmentRelation(&model.MemberDepartmentRelation{
+			DID:  department.DID,
+			EID:  s.Eid,
+			BID:  binding.ID,
+			From: model.MemberDepartmentRelationFromSCIM,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) removeGroupMembers(department *model.Department, ids []string) error {
+	bindings, err := s.memberBindings(ids)
+	if err != nil {
+		return err
+	}
+	bindingIDs := make([]int64, 0, len(bindings))
+	for _, binding := range bindings {
+		bindingIDs = append(bindingIDs, binding.ID)
+	}
+	if len(bindingIDs) == 0 {
+		return nil
+	}
+	return model.DB.Where("eid = ? AND did = ? AND `from` = ? AND bid IN ?",
+		s.Eid, department.DID, model.MemberDepartmentRelationFromSCIM, bindingIDs).
+		Delete(&model.MemberDepartmentRelation{}).Error
+}
+
+func (s *Service) replaceGroupMembers(department *model.Department, ids []string) error {
+	err := model.DB.Where("eid = ? AND did = ? AND `from` = ?", s.Eid, department.DID, model.MemberDepartmentRelationFromSCIM).
+		Delete(&model.MemberDepartmentRelation{}).Error
+	if err != nil {
+		return err
+	}
+	return s.addGroupMembers(department, ids)
+}
+
+func (s *Service) toGroupResource(department *model.Department, withMembers bool) (*Group, error) {
+	id := strconv.FormatInt(department.DID, 10)
+	group := &Group{
+		Schemas:     []string{SchemaGroup},
+		ID:          id,
+		ExternalID:  groupExternalID(department),
+		DisplayName: department.Name,
+		Meta: &Meta{
+			ResourceType: ResourceTypeGroup,
+			Created:      formatTime(department.CreatedTime),
+			LastModified: formatTime(department.UpdatedTime),
+			Location:     s.BaseURL + "/Groups/" + id,
+		},
+	}
+	if !withMembers {
+		return group, nil
+	}
+
+	type memberRow struct {
+		UserID   int64  `gorm:"column:user_id"`
+		Nickname string `gorm:"column:nickname"`
+	}
+	var rows []memberRow
+	err := model.DB.Model(&model.MemberDepartmentRelation{}).
+		Select("users.user_id, users.nickname").
+		Joins("JOIN member_bindings ON member_bindings.id = member_department_relations.bid AND member_bindings.eid = member_department_relations.eid").
+		Joins("JOIN users ON users.user_id = member_bindings.mid AND users.eid = member_bindings.eid").
+		Where("member_department_relations.eid = ? AND member_department_relations.did = ? AND member_department_relations.`from` = ?",
+			s.Eid, department.DID, model.MemberDepartmentRelationFromSCIM).
+		Order("users.user_id ASC").
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	group.Members = make([]MultiValue, 0, len(rows))
+	for _, row := range rows {
+		userID := strconv.FormatInt(row.UserID, 10)
+		group.Members = append(group.Members, MultiValue{
+			Value:   userID,
+			Display: row.Nickname,
+			Ref:     s.BaseURL + "/Users/" + userID,
+		})
+	}
+	return group, nil
+}
@@ -0,0 +1,103 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/53AI/53AIHub/model"
+)
+
+func memberValues(group *Group) []string {
+	values := make([]string, 0, len(group.Members))
+	for _, member := range group.Members {
+		values = append(values, member.Value)
+	}
+	return values
+}
+
+func sameValues(got []string, want ...string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestGroupMembershipSync(t *testing.T) {
+	setupDB(t)
+	s := NewService(1, "https://hub.example.com/scim/v2")
+	alice := createUser(t, s, "alice@example.com", model.RoleCommonUser)
+	bob := createUser(t, s, "bob@example.com", model.RoleCommonUser)
+	carol := createUser(t, s, "carol@example.com", model.RoleCommonUser)
+
+	group, err := s.CreateGroup(&Group{DisplayName: "Engineering", ExternalID: "eng", Members: []MultiValue{{Value: alice.ID}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameValues(memberValues(group), alice.ID) || group.ExternalID != "eng" {
+		t.Fatalf("unexpected group: %+v", group)
+	}
+	if _, err := s.CreateGroup(&Group{DisplayName: "Engineering"}); scimType(err) != "uniqueness" {
+		t.Fatalf("duplicate group err = %v", err)
+	}
+
+	// 增删成员只影响涉及的成员，重复添加不会产生重复关系
+	patch := &PatchRequest{Operations: []PatchOperation{
+		{Op: "add", Path: "members", Value: json.RawMessage(`[{"value":"` + bob.ID + `"},{"value":"` + alice.ID + `"}]`)},
+		{Op: "add", Path: "members", Value: json.RawMessage(`[{"value":"` + carol.ID + `"}]`)},
+		{Op: "remove", Path: `members[value eq "` + alice.ID + `"]`},
+	}}
+	if group, err = s.PatchGroup(group.ID, patch); err != nil {
+		t.Fatal(err)
+	}
+	if !sameValues(memberValues(group), bob.ID, carol.ID) {
+		t.Fatalf("unexpected members after patch: %v", memberValues(group))
+	}
+
+	// 成员资源中的组与组成员保持一致
+	user, err := s.GetUser(bob.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(user.Groups) != 1 || user.Groups[0].Value != group.ID || user.Groups[0].Display != "Engineering" {
+		t.Fatalf("unexpected user groups: %+v", user.Groups)
+	}
+
+	if group, err = s.ReplaceGroup(group.ID, &Group{DisplayName: "R&D", Members: []MultiValue{{Value: alice.ID}}}); err != nil {
+		t.Fatal(err)
+	}
+	if !sameValues(memberValues(group), alice.ID) || group.DisplayName != "R&D" || group.ExternalID != "" {
+		t.Fatalf("unexpected group after replace: %+v", group)
+	}
+	if user, err = s.GetUser(bob.ID); err != nil || len(user.Groups) != 0 {
+		t.Fatalf("bob should have left the group: %+v %v", user, err)
+	}
+
+	if group, err = s.PatchGroup(group.ID, &PatchRequest{Operations: []PatchOperation{{Op: "remove", Path: "members"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(group.Members) != 0 {
+		t.Fatalf("removing members without a filter should clear the group: %v", memberValues(group))
+	}
+	var scimErr *Error
+	if _, err := s.PatchGroup(group.ID, &PatchRequest{Operations: []PatchOperation{
+		{Op: "add", Path: "members", Value: json.RawMessage(`[{"value":"999"}]`)},
+	}}); !errors.As(err, &scimErr) || scimErr.HTTPStatus() != http.StatusNotFound {
+		t.Fatalf("adding an unknown member err = %v", err)
+	}
+
+	if err := s.DeleteGroup(group.ID); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	model.DB.Model(&model.MemberDepartmentRelation{}).Where("`from` = ?", model.MemberDepartmentRelationFromSCIM).Count(&count)
+	if count != 0 {
+		t.Fatalf("deleting the group should remove its relations, got %d", count)
+	}
+}
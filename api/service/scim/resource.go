@@ -0,0 +1,233 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SCIM 2.0 (RFC 7643 / RFC 7644) 协议中使用的 schema
+const (
+	SchemaUser                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaServiceProviderConfig = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SchemaResourceType          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+)
+
+// ContentType SCIM 响应的媒体类型
+const ContentType = "application/scim+json"
+
+const (
+	ResourceTypeUser  = "User"
+	ResourceTypeGroup = "Group"
+)
+
+// 分页参数
+const (
+	DefaultCount = 100
+	MaxCount     = 500
+)
+
+type Meta struct {
+	ResourceType string `json:"resourceType"`
+	Created      string `json:"created,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Location     string `json:"location,omitempty"`
+}
+
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+}
+
+// MultiValue 多值属性（emails、phoneNumbers、groups、members）
+type MultiValue struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// User SCIM 用户，对应 UserTypeInternal 类型的 model.User
+type User struct {
+	Schemas      []string     `json:"schemas"`
+	ID           string       `json:"id,omitempty"`
+	ExternalID   string       `json:"externalId,omitempty"`
+	UserName     string       `json:"userName"`
+	Name         *Name        `json:"name,omitempty"`
+	DisplayName  string       `json:"displayName,omitempty"`
+	Active       *bool        `json:"active,omitempty"`
+	Emails       []MultiValue `json:"emails,omitempty"`
+	PhoneNumbers []MultiValue `json:"phoneNumbers,omitempty"`
+	Groups       []MultiValue `json:"groups,omitempty"`
+	Meta         *Meta        `json:"meta,omitempty"`
+}
+
+// Group SCIM 组，对应来源为 SCIM 的 model.Department
+type Group struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []MultiValue `json:"members,omitempty"`
+	Meta        *Meta        `json:"meta,omitempty"`
+}
+
+type ListResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int64         `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// ListParams 列表查询参数，StartIndex 从 1 开始
+type ListParams struct {
+	Filter             string `form:"filter"`
+	StartIndex         int    `form:"startIndex"`
+	Count              int    `form:"count"`
+	ExcludedAttributes string `form:"excludedAttributes"`
+}
+
+func (p *ListParams) normalize() {
+	if p.StartIndex < 1 {
+		p.StartIndex = 1
+	}
+	if p.Count <= 0 {
+		p.Count = DefaultCount
+	}
+	if p.Count > MaxCount {
+		p.Count = MaxCount
+	}
+}
+
+func (p *ListParams) excludes(attribute string) bool {
+	for _, excluded := range strings.Split(p.ExcludedAttributes, ",") {
+		if strings.EqualFold(strings.TrimSpace(excluded), attribute) {
+			return true
+		}
+	}
+	return false
+}
+
+// Error SCIM 错误响应，同时实现 error 接口
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("scim error %s: %s", e.Status, e.Detail)
+}
+
+// HTTPStatus 返回错误对应的 HTTP 状态码
+func (e *Error) HTTPStatus() int {
+	status, err := strconv.Atoi(e.Status)
+	if err != nil {
+		return http.StatusInternalServerError
+	}
+	return status
+}
+
+// NewError 创建 SCIM 错误，scimType 见 RFC 7644 3.12
+func NewError(status int, scimType string, detail string) *Error {
+	return &Error{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(status),
+		ScimType: scimType,
+		Detail:   detail,
+	}
+}
+
+// ToError 将任意错误转换为 SCIM 错误
+func ToError(err error) *Error {
+	if scimErr, ok := err.(*Error); ok {
+		return scimErr
+	}
+	return NewError(http.StatusInternalServerError, "", err.Error())
+}
+
+func errNotFound(resourceType string, id string) *Error {
+	return NewError(http.StatusNotFound, "", fmt.Sprintf("%s %s not found", resourceType, id))
+}
+
+func errInvalidValue(detail string) *Error {
+	return NewError(http.StatusBadRequest, "invalidValue", detail)
+}
+
+func errUniqueness(detail string) *Error {
+	return NewError(http.StatusConflict, "uniqueness", detail)
+}
+
+func formatTime(ms int64) string {
+	if ms <= 0 {
+		return ""
+	}
+	return time.UnixMilli(ms).UTC().Format(time.RFC3339)
+}
+
+// ServiceProviderConfig 返回服务能力声明
+func ServiceProviderConfig(baseURL string) map[string]interface{} {
+	return map[string]interface{}{
+		"schemas":          []string{SchemaServiceProviderConfig},
+		"documentationUri": "https://datatracker.ietf.org/doc/html/rfc7644",
+		"patch":            map[string]bool{"supported": true},
+		"bulk":             map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":           map[string]interface{}{"supported": true, "maxResults": MaxCount},
+		"changePassword":   map[string]bool{"supported": false},
+		"sort":             map[string]bool{"supported": false},
+		"etag":             map[string]bool{"supported": false},
+		"authenticationSchemes": []map[string]interface{}{
+			{
+				"type":        "oauthbearertoken",
+				"name":        "OAuth Bearer Token",
+				"description": "Authentication scheme using the enterprise SCIM token",
+				"primary":     true,
+			},
+		},
+		"meta": Meta{ResourceType: "ServiceProviderConfig", Location: baseURL + "/ServiceProviderConfig"},
+	}
+}
+
+// ResourceTypes 返回支持的资源类型
+func ResourceTypes(baseURL string) []map[string]interface{} {
+	return []map[string]interface{}{
+		{
+			"schemas":  []string{SchemaResourceType},
+			"id":       ResourceTypeUser,
+			"name":     ResourceTypeUser,
+			"endpoint": "/Users",
+			"schema":   SchemaUser,
+			"meta":     Meta{ResourceType: "ResourceType", Location: baseURL + "/ResourceTypes/" + ResourceTypeUser},
+		},
+		{
+			"schemas":  []string{SchemaResourceType},
+			"id":       ResourceTypeGroup,
+			"name":     ResourceTypeGroup,
+			"endpoint": "/Groups",
+			"schema":   SchemaGroup,
+			"meta":     Meta{ResourceType: "ResourceType", Location: baseURL + "/ResourceTypes/" + ResourceTypeGroup},
+		},
+	}
+}
@@ -0,0 +1,654 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/53AI/53AIHub/common/utils/helper"
+	"github.com/53AI/53AIHub/model"
+	"gorm.io/gorm"
+)
+
+// Service 企业范围内的 SCIM 资源操作，BaseURL 形如 https://hub.example.com/scim/v2，用于生成 meta.location
+type Service struct {
+	Eid     int64
+	BaseURL string
+}
+
+func NewService(eid int64, baseURL string) *Service {
+	return &Service{Eid: eid, BaseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// userFilterColumns 用户过滤属性与列的映射，externalId 保存在 SCIM 来源的成员绑定上
+var userFilterColumns = map[string]string{
+	"id":                 "users.user_id",
+	"username":           "users.username",
+	"displayname":        "users.nickname",
+	"name.formatted":     "users.nickname",
+	"emails":             "users.email",
+	"emails.value":       "users.email",
+	"phonenumbers":       "users.mobile",
+	"phonenumbers.value": "users.mobile",
+	"externalid":         "member_bindings.bindvalue",
+}
+
+func (s *Service) userQuery() *gorm.DB {
+	return model.DB.Model(&model.User{}).
+		Joins("LEFT JOIN member_bindings ON member_bindings.mid = users.user_id AND member_bindings.eid = users.eid AND member_bindings.`from` = ?", model.MemberBindingSourceSCIM).
+		Where("users.eid = ? AND users.type = ?", s.Eid, model.UserTypeInternal)
+}
+
+// ListUsers 按过滤条件分页列出企业内部成员
+func (s *Service) ListUsers(params ListParams) (*ListResponse, error) {
+	params.normalize()
+	exprs, err := ParseFilter(params.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	query := s.userQuery()
+	columnExprs := make([]FilterExpr, 0, len(exprs))
+	for _, expr := range exprs {
+		if expr.Attribute != "active" {
+			columnExprs = append(columnExprs, expr)
+			continue
+		}
+		// active 映射为用户状态是否为停用
+		active := strings.EqualFold(expr.Value, "true")
+		if expr.Operator == "ne" {
+			active = !active
+		} else if expr.Operator != "eq" {
+			return nil, errInvalidFilter("active only supports eq and ne")
+		}
+		if active {
+			query = query.Where("users.status <> ?", model.UserStatusDisabled)
+		} else {
+			query = query.Where("users.status = ?", model.UserStatusDisabled)
+		}
+	}
+	if query, err = applyFilter(query, columnExprs, userFilterColumns); err != nil {
+		return nil, err
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, err
+	}
+	var users []*model.User
+	err = query.Select("users.*").Order("users.user_id ASC").
+		Offset(params.StartIndex - 1).Limit(params.Count).Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+
+	resources, err := s.toUserResources(users, !params.excludes("groups"))
+	if err != nil {
+		return nil, err
+	}
+	list := &ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   params.StartIndex,
+		ItemsPerPage: len(resources),
+		Resources:    make([]interface{}, 0, len(resources)),
+	}
+	for _, resource := range resources {
+		list.Resources = append(list.Resources, resource)
+	}
+	return list, nil
+}
+
+func (s *Service) GetUser(id string) (*User, error) {
+	user, err := s.findUser(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.toUserResource(user)
+}
+
+// CreateUser 创建内部成员，同时创建后台来源与 SCIM 来源的成员绑定
+func (s *Service) CreateUser(input *User) (*User, error) {
+	if err := validateUserInput(input); err != nil {
+		return nil, err
+	}
+	if err := s.checkUserNameAvailable(input.UserName, 0); err != nil {
+		return nil, err
+	}
+
+	user := &model.User{
+		Username: input.UserName,
+		Password: helper.RandomString(16), // SCIM 成员通过单点登录或重置密码登录
+		Eid:      s.Eid,
+		Type:     model.UserTypeInternal,
+		Role:     model.RoleCommonUser,
+	}
+	applyUserAttributes(user, input)
+	if err := user.Create(); err != nil {
+		if strings.Contains(err.Error(), "already exists") {
+			return nil, errUniqueness(err.Error())
+		}
+		return nil, err
+	}
+	// 新成员未登录前为未加入状态，与后台批量添加成员一致
+	status := model.UserStatusNotJoined
+	if input.Active != nil && !*input.Active {
+		status = model.UserStatusDisabled
+	}
+	if err := model.DB.Model(user).Update("status", status).Error; err != nil {
+		return nil, err
+	}
+	user.Status = status
+
+	if err := model.CreateMemberBinding(&model.MemberBinding{
+		MID:       user.UserID,
+		EID:       s.Eid,
+		Name:      user.Nickname,
+		BindValue: strconv.FormatInt(user.UserID, 10),
+		Status:    model.MemberBindingStatusActive,
+		From:      model.DepartmentFromBackend,
+	}); err != nil {
+		return nil, err
+	}
+	if _, err := s.saveUserBinding(user, input.ExternalID); err != nil {
+		return nil, err
+	}
+	return s.toUserResource(user)
+}
+
+// ReplaceUser 以请求内容整体替换成员属性（PUT）
+func (s *Service) ReplaceUser(id string, input *User) (*User, error) {
+	user, _, err := s.findManagedUser(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateUserInput(input); err != nil {
+		return nil, err
+	}
+	return s.saveUser(user, input)
+}
+
+// PatchUser 在当前成员属性上应用 PATCH 操作后保存
+func (s *Service) PatchUser(id string, patch *PatchRequest) (*User, error) {
+	user, _, err := s.findManagedUser(id)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.toUserResource(user)
+	if err != nil {
+		return nil, err
+	}
+	for _, operation := range patch.Operations {
+		if err := patchUser(current, operation); err != nil {
+			return nil, err
+		}
+	}
+	if err := validateUserInput(current); err != nil {
+		return nil, err
+	}
+	return s.saveUser(user, current)
+}
+
+// DeleteUser 删除成员及其绑定与部门关系
+func (s *Service) DeleteUser(id string) error {
+	user, _, err := s.findManagedUser(id)
+	if err != nil {
+		return err
+	}
+	if err := checkDeprovision(user); err != nil {
+		return err
+	}
+	return model.DeleteUser(s.Eid, user.UserID)
+}
+
+// checkDeprovision 管理员、创建者等账号不能通过 SCIM 删除或停用，只能在后台处理，
+// 避免 SCIM 令牌泄露或身份提供方误操作时锁死企业管理员
+func checkDeprovision(user *model.User) error {
+	if user.Role >= model.RoleAdminUser {
+		return NewError(http.StatusForbidden, "", fmt.Sprintf("User %d is an administrator and cannot be deleted or deactivated through SCIM", user.UserID))
+	}
+	return nil
+}
+
+func (s *Service) findUser(id string) (*model.User, error) {
+	userID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, errNotFound(ResourceTypeUser, id)
+	}
+	var user model.User
+	err = model.DB.Where("eid = ? AND user_id = ? AND type = ?", s.Eid, userID, model.UserTypeInternal).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errNotFound(ResourceTypeUser, id)
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// findManagedUser 查找可通过 SCIM 修改的成员，仅限带有 SCIM 来源绑定的成员，
+// 后台创建或其它来源同步的成员只能查询，不能通过 SCIM 修改、删除或调整所属组
+func (s *Service) findManagedUser(id string) (*model.User, *model.MemberBinding, error) {
+	user, err := s.findUser(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	binding, err := model.GetMemberBindingByMidAndFrom(user.UserID, model.MemberBindingSourceSCIM)
+	if err != nil {
+		return nil, nil, err
+	}
+	if binding == nil {
+		return nil, nil, NewError(http.StatusForbidden, "", fmt.Sprintf("User %d is not provisioned through SCIM and can only be managed in the console", user.UserID))
+	}
+	return user, binding, nil
+}
+
+// checkAdminUpdate 管理员、创建者等账号的登录名、联系方式与状态不能通过 SCIM 修改，
+// 否则持有 SCIM 令牌即可改写管理员邮箱或手机号后重置密码接管账号
+func checkAdminUpdate(before model.User, user *model.User) error {
+	if before.Role < model.RoleAdminUser {
+		return nil
+	}
+	if before.Username != user.Username || before.Email != user.Email || before.Mobile != user.Mobile || before.Status != user.Status {
+		return NewError(http.StatusForbidden, "", fmt.Sprintf("User %d is an administrator, userName, emails, phoneNumbers and active cannot be changed through SCIM", user.UserID))
+	}
+	return nil
+}
+
+func (s *Service) checkUserNameAvailable(userName string, excludeUserID int64) error {
+	var count int64
+	err := model.DB.Model(&model.User{}).
+		Where("eid = ? AND LOWER(username) = ? AND user_id <> ?", s.Eid, strings.ToLower(userName), excludeUserID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errUniqueness(fmt.Sprintf("userName %s already exists", userName))
+	}
+	return nil
+}
+
+func (s *Service) saveUser(user *model.User, input *User) (*User, error) {
+	before := *user
+	if !strings.EqualFold(user.Username, input.UserName) {
+		if err := s.checkUserNameAvailable(input.UserName, user.UserID); err != nil {
+			return nil, err
+		}
+	}
+	user.Username = input.UserName
+	applyUserAttributes(user, input)
+	if input.Active != nil {
+		if !*input.Active {
+			if user.Status != model.UserStatusDisabled {
+				if err := checkDeprovision(user); err != nil {
+					return nil, err
+				}
+			}
+			user.Status = model.UserStatusDisabled
+		} else if user.Status == model.UserStatusDisabled {
+			// 重新启用时按是否登录过恢复状态
+			user.Status = model.UserStatusNotJoined
+			if user.LastLoginTime > 0 {
+				user.Status = model.UserStatusJoined
+			}
+		}
+	}
+	if err := checkAdminUpdate(before, user); err != nil {
+		return nil, err
+	}
+	if err := s.checkContactAvailable(user); err != nil {
+		return nil, err
+	}
+	err := model.DB.Model(user).Updates(map[string]interface{}{
+		"username": user.Username,
+		"nickname": user.Nickname,
+		"email":    user.Email,
+		"mobile":   user.Mobile,
+		"status":   user.Status,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.saveUserBinding(user, input.ExternalID); err != nil {
+		return nil, err
+	}
+	return s.toUserResource(user)
+}
+
+// checkContactAvailable 邮箱与手机号在企业内唯一
+func (s *Service) checkContactAvailable(user *model.User) error {
+	for column, value := range map[string]string{"email": user.Email, "mobile": user.Mobile} {
+		if value == "" {
+			continue
+		}
+		var count int64
+		err := model.DB.Model(&model.User{}).
+			Where("eid = ? AND user_id <> ? AND "+column+" = ?", s.Eid, user.UserID, value).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return errUniqueness(fmt.Sprintf("%s %s already exists", column, value))
+		}
+	}
+	return nil
+}
+
+// saveUserBinding 创建或更新成员的 SCIM 来源绑定，externalId 保存为绑定值，未提供时使用成员ID
+func (s *Service) saveUserBinding(user *model.User, externalID string) (*model.MemberBinding, error) {
+	binding, err := model.GetMemberBindingByMidAndFrom(user.UserID, model.MemberBindingSourceSCIM)
+	if err != nil {
+		return nil, err
+	}
+	bindValue := externalID
+	if bindValue == "" {
+		bindValue = strconv.FormatInt(user.UserID, 10)
+	}
+	status := model.MemberBindingStatusActive
+	if user.Status == model.UserStatusDisabled {
+		status = model.MemberBindingStatusDisabled
+	}
+	if binding == nil {
+		binding = &model.MemberBinding{
+			MID:       user.UserID,
+			EID:       s.Eid,
+			Name:      user.Nickname,
+			BindValue: bindValue,
+			Status:    status,
+			From:      model.MemberBindingSourceSCIM,
+		}
+		return binding, model.CreateMemberBinding(binding)
+	}
+	binding.Name = user.Nickname
+	binding.BindValue = bindValue
+	binding.Status = status
+	err = model.DB.Model(binding).Updates(map[string]interface{}{
+		"name":      binding.Name,
+		"bindvalue": binding.BindValue,
+		"status":    binding.Status,
+	}).Error
+	return binding, err
+}
+
+func validateUserInput(input *User) error {
+	input.UserName = strings.TrimSpace(input.UserName)
+	if input.UserName == "" {
+		return errInvalidValue("userName is required")
+	}
+	return nil
+}
+
+// applyUserAttributes 将 SCIM 属性写入成员，昵称依次取 displayName、name.formatted、姓名组合与 userName
+func applyUserAttributes(user *model.User, input *User) {
+	user.Nickname = input.DisplayName
+	if user.Nickname == "" && input.Name != nil {
+		user.Nickname = input.Name.Formatted
+		if user.Nickname == "" {
+			user.Nickname = strings.TrimSpace(input.Name.GivenName + " " + input.Name.FamilyName)
+		}
+	}
+	if user.Nickname == "" {
+		user.Nickname = input.UserName
+	}
+
+	user.Email = primaryValue(input.Emails)
+	if user.Email == "" && helper.IsValidEmail(input.UserName) {
+		user.Email = input.UserName
+	}
+	user.Mobile = primaryValue(input.PhoneNumbers)
+	if user.Mobile == "" && helper.IsValidPhone(input.UserName) {
+		user.Mobile = input.UserName
+	}
+}
+
+func primaryValue(values []MultiValue) string {
+	for _, value := range values {
+		if value.Primary {
+			return value.Value
+		}
+	}
+	if len(values) > 0 {
+		return values[0].Value
+	}
+	return ""
+}
+
+func (s *Service) toUserResource(user *model.User) (*User, error) {
+	resources, err := s.toUserResources([]*model.User{user}, true)
+	if err != nil {
+		return nil, err
+	}
+	return resources[0], nil
+}
+
+// toUserResources 批量转换成员，一次加载 SCIM 绑定与所属组
+func (s *Service) toUserResources(users []*model.User, withGroups bool) ([]*User, error) {
+	userIDs := make([]int64, 0, len(users))
+	for _, user := range users {
+		userIDs = append(userIDs, user.UserID)
+	}
+	var bindings []*model.MemberBinding
+	if len(userIDs) > 0 {
+		err := model.DB.Where("eid = ? AND `from` = ? AND mid IN ?", s.Eid, model.MemberBindingSourceSCIM, userIDs).
+			Find(&bindings).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+	bindingByUser := make(map[int64]*model.MemberBinding, len(bindings))
+	bindingIDs := make([]int64, 0, len(bindings))
+	for _, binding := range bindings {
+		bindingByUser[binding.MID] = binding
+		bindingIDs = append(bindingIDs, binding.ID)
+	}
+
+	groupsByBinding := make(map[int64][]MultiValue)
+	if withGroups && len(bindingIDs) > 0 {
+		type groupRow struct {
+			BID  int64  `gorm:"column:bid"`
+			DID  int64  `gorm:"column:did"`
+			Name string `gorm:"column:name"`
+		}
+		var rows []groupRow
+		err := model.DB.Model(&model.MemberDepartmentRelation{}).
+			Select("member_department_relations.bid, departments.did, departments.name").
+			Joins("JOIN departments ON departments.did = member_department_relations.did AND departments.eid = member_department_relations.eid").
+			Where("member_department_relations.eid = ? AND member_department_relations.`from` = ? AND member_department_relations.bid IN ?",
+				s.Eid, model.MemberDepartmentRelationFromSCIM, bindingIDs).
+			Find(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			groupID := strconv.FormatInt(row.DID, 10)
+			groupsByBinding[row.BID] = append(groupsByBinding[row.BID], MultiValue{
+				Value:   groupID,
+				Display: row.Name,
+				Ref:     s.BaseURL + "/Groups/" + groupID,
+			})
+		}
+	}
+
+	resources := make([]*User, 0, len(users))
+	for _, user := range users {
+		id := strconv.FormatInt(user.UserID, 10)
+		active := user.Status != model.UserStatusDisabled
+		resource := &User{
+			Schemas:     []string{SchemaUser},
+			ID:          id,
+			UserName:    user.Username,
+			Name:        &Name{Formatted: user.Nickname},
+			DisplayName: user.Nickname,
+			Active:      &active,
+			Meta: &Meta{
+				ResourceType: ResourceTypeUser,
+				Created:      formatTime(user.CreatedTime),
+				LastModified: formatTime(user.UpdatedTime),
+				Location:     s.BaseURL + "/Users/" + id,
+			},
+		}
+		if user.Email != "" {
+			resource.Emails = []MultiValue{{Value: user.Email, Type: "work", Primary: true}}
+		}
+		if user.Mobile != "" {
+			resource.PhoneNumbers = []MultiValue{{Value: user.Mobile, Type: "mobile", Primary: true}}
+		}
+		if binding, ok := bindingByUser[user.UserID]; ok {
+			if binding.BindValue != id {
+				resource.ExternalID = binding.BindValue
+			}
+			resource.Groups = groupsByBinding[binding.ID]
+		}
+		resources = append(resources, resource)
+	}
+	return resources, nil
+}
+
+// patchUser 应用单个 PATCH 操作，未指定 path 时 value 为属性对象
+func patchUser(user *User, operation PatchOperation) error {
+	op := strings.ToLower(operation.Op)
+	if op != "add" && op != "replace" && op != "remove" {
+		return errInvalidValue(fmt.Sprintf("unsupported patch op %q", operation.Op))
+	}
+	if operation.Path == "" {
+		if op == "remove" {
+			return NewError(http.StatusBadRequest, "noTarget", "path is required for remove")
+		}
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(operation.Value, &values); err != nil {
+			return errInvalidValue("value must be an object when path is empty")
+		}
+		// 姓名会重新生成昵称，displayName 放在最后应用以保证优先
+		paths := make([]string, 0, len(values))
+		for path := range values {
+			paths = append(paths, path)
+		}
+		sort.SliceStable(paths, func(i, j int) bool {
+			return normalizeAttributePath(paths[j]) == "displayname" && normalizeAttributePath(paths[i]) != "displayname"
+		})
+		for _, path := range paths {
+			if err := setUserAttribute(user, path, values[path], false); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return setUserAttribute(user, operation.Path, operation.Value, op == "remove")
+}
+
+// setUserAttribute 设置或移除单个属性，未支持的属性忽略，以兼容身份提供商推送的扩展属性
+func setUserAttribute(user *User, path string, value json.RawMessage, remove bool) error {
+	switch normalizeAttributePath(path) {
+	case "active":
+		active := true
+		if !remove {
+			parsed, err := parseBool(value)
+			if err != nil {
+				return err
+			}
+			active = parsed
+		}
+		user.Active = &active
+	case "username":
+		if remove {
+			return errInvalidValue("userName cannot be removed")
+		}
+		return unmarshalValue(value, &user.UserName)
+	case "displayname":
+		user.DisplayName = ""
+		if !remove {
+			return unmarshalValue(value, &user.DisplayName)
+		}
+	case "externalid":
+		user.ExternalID = ""
+		if !remove {
+			return unmarshalValue(value, &user.ExternalID)
+		}
+	case "name":
+		user.Name = nil
+		if !remove {
+			user.Name = &Name{}
+			return unmarshalValue(value, user.Name)
+		}
+	case "name.formatted", "name.givenname", "name.familyname":
+		if user.Name == nil {
+			user.Name = &Name{}
+		}
+		// 姓名变更时以姓名为准重新生成昵称
+		user.DisplayName = ""
+		var text string
+		if !remove {
+			if err := unmarshalValue(value, &text); err != nil {
+				return err
+			}
+		}
+		switch normalizeAttributePath(path) {
+		case "name.formatted":
+			user.Name.Formatted = text
+		case "name.givenname":
+			user.Name.Formatted = ""
+			user.Name.GivenName = text
+		case "name.familyname":
+			user.Name.Formatted = ""
+			user.Name.FamilyName = text
+		}
+	case "emails":
+		return setMultiValue(&user.Emails, value, remove)
+	case "emails.value":
+		return setPrimaryValue(&user.Emails, value, remove)
+	case "phonenumbers":
+		return setMultiValue(&user.PhoneNumbers, value, remove)
+	case "phonenumbers.value":
+		return setPrimaryValue(&user.PhoneNumbers, value, remove)
+	}
+	return nil
+}
+
+func setMultiValue(target *[]MultiValue, value json.RawMessage, remove bool) error {
+	*target = nil
+	if remove {
+		return nil
+	}
+	return unmarshalValue(value, target)
+}
+
+func setPrimaryValue(target *[]MultiValue, value json.RawMessage, remove bool) error {
+	*target = nil
+	if remove {
+		return nil
+	}
+	var text string
+	if err := unmarshalValue(value, &text); err != nil {
+		return err
+	}
+	*target = []MultiValue{{Value: text, Primary: true}}
+	return nil
+}
+
+func unmarshalValue(value json.RawMessage, target interface{}) error {
+	if err := json.Unmarshal(value, target); err != nil {
+		return errInvalidValue(err.Error())
+	}
+	return nil
+}
+
+// parseBool 兼容部分身份提供商以字符串 "True"/"False" 传递布尔值
+func parseBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var text string
+	if err := json.Unmarshal(value, &text); err == nil {
+		if parsed, err := strconv.ParseBool(strings.ToLower(text)); err == nil {
+			return parsed, nil
+		}
+	}
+	return false, errInvalidValue("invalid boolean value " + string(value))
+}
@@ -0,0 +1,277 @@
+package scim
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+)
+
+func setupDB(t *testing.T) {
+	testutil.SetupDB(t, &model.User{}, &model.MemberBinding{}, &model.Department{}, &model.MemberDepartmentRelation{},
+		&model.UserSession{}, &model.PasswordHistory{}, &model.RoleAssignment{}, &model.UserTwoFactor{})
+}
+
+func createUser(t *testing.T, s *Service, userName string, role int) *User {
+	resource, err := s.CreateUser(&User{UserName: userName})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role != model.RoleCommonUser {
+		model.DB.Model(&model.User{}).Where("user_id = ?", resource.ID).Update("role", role)
+	}
+	return resource
+}
+
+func patchActive(active bool) *PatchRequest {
+	value, _ := json.Marshal(active)
+	return &PatchRequest{Operations: []PatchOperation{{Op: "replace", Path: "active", Value: value}}}
+}
+
+func TestDeprovisionSkipsAdministrators(t *testing.T) {
+	setupDB(t)
+	s := NewService(1, "https://hub.example.com/scim/v2")
+	admin := createUser(t, s, "admin@example.com", model.RoleAdminUser)
+	member := createUser(t, s, "member@example.com", model.RoleCommonUser)
+
+	var scimErr *Error
+	if err := s.DeleteUser(admin.ID); !errors.As(err, &scimErr) || scimErr.HTTPStatus() != http.StatusForbidden {
+		t.Fatalf("delete admin err = %v", err)
+	}
+	if _, err := s.PatchUser(admin.ID, patchActive(false)); !errors.As(err, &scimErr) || scimErr.HTTPStatus() != http.StatusForbidden {
+		t.Fatalf("deactivate admin err = %v", err)
+	}
+	if _, err := s.ReplaceUser(admin.ID, &User{UserName: admin.UserName, Active: new(bool)}); !errors.As(err, &scimErr) || scimErr.HTTPStatus() != http.StatusForbidden {
+		t.Fatalf("replace admin as inactive err = %v", err)
+	}
+	// 管理员属性的其他更新不受影响
+	if _, err := s.PatchUser(admin.ID, patchActive(true)); err != nil {
+		t.Fatalf("update admin err = %v", err)
+	}
+
+	if _, err := s.PatchUser(member.ID, patchActive(false)); err != nil {
+		t.Fatalf("deactivate member err = %v", err)
+	}
+	if err := s.DeleteUser(member.ID); err != nil {
+		t.Fatalf("delete member err = %v", err)
+	}
+	userID, _ := strconv.ParseInt(admin.ID, 10, 64)
+	var count int64
+	model.DB.Model(&model.User{}).Where("user_id = ? AND status <> ?", userID, model.UserStatusDisabled).Count(&count)
+	if count != 1 {
+		t.Fatal("admin should remain active")
+	}
+}
+
+func TestAdminIdentityCannotChange(t *testing.T) {
+	setupDB(t)
+	s := NewService(1, "https://hub.example.com/scim/v2")
+	admin := createUser(t, s, "admin@example.com", model.RoleAdminUser)
+
+	var scimErr *Error
+	for _, operation := range []PatchOperation{
+		{Op: "replace", Path: "emails", Value: json.RawMessage(`[{"value":"attacker@evil.com","primary":true}]`)},
+		{Op: "replace", Path: "userName", Value: json.RawMessage(`"attacker@evil.com"`)},
+		{Op: "add", Path: "phoneNumbers", Value: json.RawMessage(`[{"value":"13800000000"}]`)},
+	} {
+		_, err := s.PatchUser(admin.ID, &PatchRequest{Operations: []PatchOperation{operation}})
+		if !errors.As(err, &scimErr) || scimErr.HTTPStatus() != http.StatusForbidden {
+			t.Fatalf("patch admin %s: err = %v", operation.Path, err)
+		}
+	}
+	if _, err := s.ReplaceUser(admin.ID, &User{UserName: admin.UserName, Emails: []MultiValue{{Value: "attacker@evil.com"}}}); !errors.As(err, &scimErr) || scimErr.HTTPStatus() != http.StatusForbidden {
+		t.Fatalf("replace admin email: err = %v", err)
+	}
+	userID, _ := strconv.ParseInt(admin.ID, 10, 64)
+	stored, err := model.GetUserByID(userID)
+	if err != nil || stored.Email != "admin@example.com" || stored.Username != "admin@example.com" || stored.Mobile != "" {
+		t.Fatalf("admin identity changed: %+v %v", stored, err)
+	}
+
+	// 显示名称等其它属性仍然同步
+	updated, err := s.PatchUser(admin.ID, &PatchRequest{Operations: []PatchOperation{
+		{Op: "replace", Path: "displayName", Value: json.RawMessage(`"Administrator"`)},
+	}})
+	if err != nil || updated.DisplayName != "Administrator" {
+		t.Fatalf("update admin display name: %+v %v", updated, err)
+	}
+}
+
+func TestUnmanagedUsersAreReadOnly(t *testing.T) {
+	setupDB(t)
+	s := NewService(1, "https://hub.example.com/scim/v2")
+	// 后台创建的成员没有 SCIM 来源绑定
+	console := &model.User{Eid: 1, Username: "owner@example.com", Email: "owner@example.com", Type: model.UserTypeInternal}
+	model.DB.Create(console)
+	id := strconv.FormatInt(console.UserID, 10)
+
+	if _, err := s.GetUser(id); err != nil {
+		t.Fatalf("get unmanaged user: %v", err)
+	}
+	var scimErr *Error
+	forbidden := func(name string, err error) {
+		t.Helper()
+		if !errors.As(err, &scimErr) || scimErr.HTTPStatus() != http.StatusForbidden {
+			t.Fatalf("%s: err = %v", name, err)
+		}
+	}
+	_, err := s.PatchUser(id, &PatchRequest{Operations: []PatchOperation{
+		{Op: "replace", Path: "emails", Value: json.RawMessage(`[{"value":"attacker@evil.com"}]`)},
+	}})
+	forbidden("patch", err)
+	_, err = s.ReplaceUser(id, &User{UserName: "attacker@evil.com"})
+	forbidden("replace", err)
+	forbidden("delete", s.DeleteUser(id))
+	_, err = s.CreateGroup(&Group{DisplayName: "Admins", Members: []MultiValue{{Value: id}}})
+	forbidden("add to group", err)
+	if list, err := s.ListGroups(ListParams{}); err != nil || list.TotalResults != 0 {
+		t.Fatalf("group should not be created: %+v %v", list, err)
+	}
+
+	stored, err := model.GetUserByID(console.UserID)
+	if err != nil || stored.Email != "owner@example.com" {
+		t.Fatalf("unmanaged user changed: %+v %v", stored, err)
+	}
+}
+
+func scimType(err error) string {
+	var scimErr *Error
+	if !errors.As(err, &scimErr) {
+		return ""
+	}
+	return scimErr.ScimType
+}
+
+func TestPatchUser(t *testing.T) {
+	user := &User{UserName: "alice@example.com", DisplayName: "Alice"}
+	operations := []PatchOperation{
+		// 未指定 path 时 displayName 最后应用，不会被姓名变更清空
+		{Op: "replace", Value: json.RawMessage(`{"displayName":"Alice Liddell","name.givenName":"Alice"}`)},
+		{Op: "Replace", Path: "active", Value: json.RawMessage(`"False"`)},
+		{Op: "add", Path: `emails[type eq "work"].value`, Value: json.RawMessage(`"alice@corp.example.com"`)},
+		{Op: "replace", Path: "urn:ietf:params:scim:schemas:core:2.0:User:externalId", Value: json.RawMessage(`"ext-1"`)},
+		{Op: "add", Path: "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:department", Value: json.RawMessage(`"R&D"`)},
+	}
+	for _, operation := range operations {
+		if err := patchUser(user, operation); err != nil {
+			t.Fatalf("patch %+v: %v", operation, err)
+		}
+	}
+	if user.DisplayName != "Alice Liddell" || user.Name == nil || user.Name.GivenName != "Alice" {
+		t.Fatalf("unexpected names: %q %+v", user.DisplayName, user.Name)
+	}
+	if user.Active == nil || *user.Active {
+		t.Fatal("active should be false")
+	}
+	if len(user.Emails) != 1 || user.Emails[0].Value != "alice@corp.example.com" || !user.Emails[0].Primary {
+		t.Fatalf("unexpected emails: %+v", user.Emails)
+	}
+	if user.ExternalID != "ext-1" {
+		t.Fatalf("unexpected externalId: %q", user.ExternalID)
+	}
+
+	if err := patchUser(user, PatchOperation{Op: "remove", Path: "externalId"}); err != nil || user.ExternalID != "" {
+		t.Fatalf("remove externalId: %v %q", err, user.ExternalID)
+	}
+	if err := patchUser(user, PatchOperation{Op: "remove", Path: "active"}); err != nil || !*user.Active {
+		t.Fatalf("removing active should reactivate: %v", err)
+	}
+
+	for _, tc := range []struct {
+		operation PatchOperation
+		scimType  string
+	}{
+		{PatchOperation{Op: "move", Path: "userName"}, "invalidValue"},
+		{PatchOperation{Op: "remove"}, "noTarget"},
+		{PatchOperation{Op: "remove", Path: "userName"}, "invalidValue"},
+		{PatchOperation{Op: "replace", Value: json.RawMessage(`"alice"`)}, "invalidValue"},
+		{PatchOperation{Op: "replace", Path: "active", Value: json.RawMessage(`"maybe"`)}, "invalidValue"},
+		{PatchOperation{Op: "replace", Path: "displayName", Value: json.RawMessage(`1`)}, "invalidValue"},
+	} {
+		if err := patchUser(user, tc.operation); scimType(err) != tc.scimType {
+			t.Errorf("patch %+v: err = %v, want scimType %q", tc.operation, err, tc.scimType)
+		}
+	}
+}
+
+func TestPatchUserRegeneratesNickname(t *testing.T) {
+	setupDB(t)
+	s := NewService(1, "https://hub.example.com/scim/v2")
+	resource := createUser(t, s, "alice@example.com", model.RoleCommonUser)
+
+	patch := &PatchRequest{Operations: []PatchOperation{
+		{Op: "replace", Path: "name.givenName", Value: json.RawMessage(`"Alice"`)},
+		{Op: "replace", Path: "name.familyName", Value: json.RawMessage(`"Liddell"`)},
+		{Op: "add", Path: "phoneNumbers", Value: json.RawMessage(`[{"value":"13800000000","type":"mobile"}]`)},
+	}}
+	updated, err := s.PatchUser(resource.ID, patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.DisplayName != "Alice Liddell" || updated.Emails[0].Value != "alice@example.com" {
+		t.Fatalf("unexpected user: %+v", updated)
+	}
+	if len(updated.PhoneNumbers) != 1 || updated.PhoneNumbers[0].Value != "13800000000" {
+		t.Fatalf("unexpected phone numbers: %+v", updated.PhoneNumbers)
+	}
+}
+
+func TestListUsersFilter(t *testing.T) {
+	setupDB(t)
+	s := NewService(1, "https://hub.example.com/scim/v2")
+	if _, err := s.CreateUser(&User{UserName: "Alice@Example.com", ExternalID: "ext-alice"}); err != nil {
+		t.Fatal(err)
+	}
+	bob := createUser(t, s, "bob@example.com", model.RoleCommonUser)
+	createUser(t, s, "carol@other.com", model.RoleCommonUser)
+	if _, err := s.PatchUser(bob.ID, patchActive(false)); err != nil {
+		t.Fatal(err)
+	}
+	// 其它企业的成员不会出现在列表中
+	if _, err := NewService(2, "").CreateUser(&User{UserName: "dave@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		filter string
+		want   []string
+	}{
+		{"", []string{"Alice@Example.com", "bob@example.com", "carol@other.com"}},
+		{`userName eq "alice@example.com"`, []string{"Alice@Example.com"}},
+		{`externalId eq "EXT-ALICE"`, []string{"Alice@Example.com"}},
+		{`emails.value ew "@example.com"`, []string{"Alice@Example.com", "bob@example.com"}},
+		{`active eq false`, []string{"bob@example.com"}},
+		{`active ne false and userName sw "c"`, []string{"carol@other.com"}},
+	} {
+		list, err := s.ListUsers(ListParams{Filter: tc.filter})
+		if err != nil {
+			t.Fatalf("filter %q: %v", tc.filter, err)
+		}
+		got := make([]string, 0, len(list.Resources))
+		for _, resource := range list.Resources {
+			got = append(got, resource.(*User).UserName)
+		}
+		if int(list.TotalResults) != len(tc.want) || strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Errorf("filter %q: got %v (total %d), want %v", tc.filter, got, list.TotalResults, tc.want)
+		}
+	}
+
+	list, err := s.ListUsers(ListParams{StartIndex: 2, Count: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.TotalResults != 3 || list.ItemsPerPage != 1 || list.Resources[0].(*User).ID != bob.ID {
+		t.Fatalf("unexpected page: %+v", list)
+	}
+
+	for _, filter := range []string{`userName eq "a" or userName eq "b"`, `title eq "x"`, `active gt true`, `userName eq`} {
+		if _, err := s.ListUsers(ListParams{Filter: filter}); scimType(err) != "invalidFilter" {
+			t.Errorf("filter %q: err = %v, want invalidFilter", filter, err)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package security
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/53AI/53AIHub/model"
+	"gorm.io/gorm"
+)
+
+// Config 企业配置 security 的内容，未配置或未启用时使用 DefaultConfig
+//
+// 示例：
+//
+//	{"two_factor_required_for_admins":true,"max_sessions_per_user":3,
+//	 "password_policy":{"min_length":10,"require_digit":true,"expire_days":90,"history_count":5},
+//	 "lockout":{"max_failures":5,"lock_minutes":5,"max_lock_minutes":1440,"captcha_after":3}}
+type Config struct {
+	// TwoFactorRequiredForAdmins 管理员（RoleAdminUser 及以上）登录必须通过两步验证
+	TwoFactorRequiredForAdmins bool `json:"two_factor_required_for_admins"`
+	// MaxSessionsPerUser 每个用户同时在线的会话数，超出时踢掉最早的登录；0 表示不限制
+	MaxSessionsPerUser int            `json:"max_sessions_per_user"`
+	PasswordPolicy     PasswordPolicy `json:"password_policy"`
+	Lockout            LockoutPolicy  `json:"lockout"`
+}
+
+// PasswordPolicy 密码复杂度、有效期与历史密码策略，各项为 0 或 false 时不检查
+type PasswordPolicy struct {
+	MinLength        int  `json:"min_length"`
+	RequireUppercase bool `json:"require_uppercase"`
+	RequireLowercase bool `json:"require_lowercase"`
+	RequireDigit     bool `json:"require_digit"`
+	RequireSymbol    bool `json:"require_symbol"`
+	// ExpireDays 密码有效天数，过期后需重置密码才能登录
+	ExpireDays int `json:"expire_days"`
+	// HistoryCount 新密码不能与最近几次使用过的密码相同
+	HistoryCount int `json:"history_count"`
+}
+
+// LockoutPolicy 连续登录失败的锁定策略
+type LockoutPolicy struct {
+	// MaxFailures 连续失败多少次后锁定账号；0 表示不锁定
+	MaxFailures int `json:"max_failures"`
+	// LockMinutes 首次锁定的时长，之后每次锁定时长翻倍，最长 MaxLockMinutes
+	LockMinutes    int `json:"lock_minutes"`
+	MaxLockMinutes int `json:"max_lock_minutes"`
+	// CaptchaAfter 连续失败多少次后要求前端展示验证码；0 表示不要求
+	CaptchaAfter int `json:"captcha_after"`
+}
+
+// DefaultConfig 企业未配置 security 时的默认策略：不限制密码复杂度，连续失败 5 次锁定
+func DefaultConfig() *Config {
+	return &Config{
+		Lockout: LockoutPolicy{
+			MaxFailures:    5,
+			LockMinutes:    5,
+			MaxLockMinutes: 24 * 60,
+			CaptchaAfter:   3,
+		},
+	}
+}
+
+// ParseConfig 解析并校验 security 配置内容
+func ParseConfig(content string) (*Config, error) {
+	var cfg Config
+	if err := json.Unmarshal([]byte(content), &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.MaxSessionsPerUser < 0 {
+		return nil, errors.New("max_sessions_per_user must not be negative")
+	}
+	p := cfg.PasswordPolicy
+	if p.MinLength < 0 || p.ExpireDays < 0 || p.HistoryCount < 0 {
+		return nil, errors.New("password_policy values must not be negative")
+	}
+	l := &cfg.Lockout
+	if l.MaxFailures < 0 || l.LockMinutes < 0 || l.MaxLockMinutes < 0 || l.CaptchaAfter < 0 {
+		return nil, errors.New("lockout values must not be negative")
+	}
+	if l.LockMinutes == 0 {
+		l.LockMinutes = DefaultConfig().Lockout.LockMinutes
+	}
+	if l.MaxLockMinutes < l.LockMinutes {
+		l.MaxLockMinutes = l.LockMinutes
+	}
+	return &cfg, nil
+}
+
+// LoadConfig 读取企业的 security 配置
+func LoadConfig(eid int64) (*Config, error) {
+	var record model.EnterpriseConfig
+	err := model.DB.Where("eid = ? AND type = ?", eid, model.EnterpriseConfigTypeSecurity).First(&record).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return DefaultConfig(), nil
+		}
+		return nil, err
+	}
+	if !record.Enabled {
+		return DefaultConfig(), nil
+	}
+	return ParseConfig(record.Content)
+}
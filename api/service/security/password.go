@@ -0,0 +1,112 @@
+package security
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/53AI/53AIHub/model"
+)
+
+var (
+	ErrWeakPassword   = errors.New("password does not meet the password policy")
+	ErrPasswordReused = errors.New("password was used recently")
+)
+
+// Validate 检查密码复杂度，不满足时返回包装 ErrWeakPassword 的错误并说明缺少的项
+func (p PasswordPolicy) Validate(password string) error {
+	if p.MinLength > 0 && len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("%w: at least %d characters", ErrWeakPassword, p.MinLength)
+	}
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+	missing := make([]string, 0, 4)
+	if p.RequireUppercase && !upper {
+		missing = append(missing, "an uppercase letter")
+	}
+	if p.RequireLowercase && !lower {
+		missing = append(missing, "a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		missing = append(missing, "a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		missing = append(missing, "a symbol")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: must contain %s", ErrWeakPassword, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// ValidatePassword 按企业的密码策略检查新密码，用于注册等还没有用户记录的场景
+func ValidatePassword(eid int64, password string) error {
+	cfg, err := LoadConfig(eid)
+	if err != nil {
+		return err
+	}
+	return cfg.PasswordPolicy.Validate(password)
+}
+
+// ChangePassword 按企业的密码策略检查复杂度和历史密码后修改密码
+func ChangePassword(user *model.User, password string) error {
+	cfg, err := LoadConfig(user.Eid)
+	if err != nil {
+		return err
+	}
+	policy := cfg.PasswordPolicy
+	if err := policy.Validate(password); err != nil {
+		return err
+	}
+	if policy.HistoryCount > 0 {
+		if user.Password != "" && user.VerifyPassword(password) == nil {
+			return ErrPasswordReused
+		}
+	}
+	// 当前密码占一个名额，历史表只保存更早的 HistoryCount-1 个
+	if policy.HistoryCount > 1 {
+		histories, err := model.GetPasswordHistories(user.Eid, user.UserID, policy.HistoryCount-1)
+		if err != nil {
+			return err
+		}
+		for _, history := range histories {
+			if history.Matches(password) {
+				return ErrPasswordReused
+			}
+		}
+	}
+	return user.ChangePassword(password, policy.HistoryCount-1)
+}
+
+// PasswordExpired 判断用户密码是否已超过企业规定的有效期。未记录修改时间的老用户从首次检查时开始计算
+func PasswordExpired(user *model.User) (bool, error) {
+	cfg, err := LoadConfig(user.Eid)
+	if err != nil {
+		return false, err
+	}
+	if cfg.PasswordPolicy.ExpireDays <= 0 || user.Password == "" {
+		return false, nil
+	}
+	now := time.Now().UTC()
+	if user.PasswordUpdatedTime == 0 {
+		user.PasswordUpdatedTime = now.UnixMilli()
+		err := model.DB.Model(&model.User{}).Where("user_id = ?", user.UserID).
+			Update("password_updated_time", user.PasswordUpdatedTime).Error
+		return false, err
+	}
+	expiresAt := time.UnixMilli(user.PasswordUpdatedTime).Add(time.Duration(cfg.PasswordPolicy.ExpireDays) * 24 * time.Hour)
+	return now.After(expiresAt), nil
+}
@@ -0,0 +1,79 @@
+package security
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/53AI/53AIHub/common"
+)
+
+const (
+	// 密码校验通过后完成两步验证的期限
+	preAuthTTL = 5 * time.Minute
+	// 同一预认证令牌允许输错验证码的次数，超过后需重新输入密码
+	preAuthMaxAttempts = 5
+)
+
+var ErrInvalidPreAuthToken = errors.New("pre-auth token is invalid or expired")
+
+// preAuth 密码校验通过、等待第二步验证的登录
+type preAuth struct {
+	Eid       int64 `json:"eid"`
+	UserID    int64 `json:"user_id"`
+	Attempts  int   `json:"attempts"`
+	ExpiresAt int64 `json:"expires_at"`
+}
+
+func preAuthKey(token string) string {
+	return "Api::PreAuthToken:" + token
+}
+
+func issuePreAuthToken(eid int64, userID int64) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+	data := &preAuth{Eid: eid, UserID: userID, ExpiresAt: time.Now().Add(preAuthTTL).Unix()}
+	if err := savePreAuth(token, data); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// savePreAuth 保存令牌，累计失败次数时不延长有效期
+func savePreAuth(token string, data *preAuth) error {
+	ttl := time.Until(time.Unix(data.ExpiresAt, 0))
+	if ttl <= 0 {
+		return ErrInvalidPreAuthToken
+	}
+	return common.STATES.Save(preAuthKey(token), data, ttl)
+}
+
+// loadPreAuth 读取但不作废令牌，验证成功或失败次数用尽时才删除
+func loadPreAuth(token string) (*preAuth, error) {
+	if token == "" {
+		return nil, ErrInvalidPreAuthToken
+	}
+	data := &preAuth{}
+	if err := common.STATES.Load(preAuthKey(token), data); err != nil {
+		return nil, ErrInvalidPreAuthToken
+	}
+	return data, nil
+}
+
+// recordFailedAttempt 累计验证失败次数，用尽后令牌失效
+func recordFailedAttempt(token string, data *preAuth) {
+	data.Attempts++
+	if data.Attempts >= preAuthMaxAttempts {
+		revokePreAuth(token)
+		return
+	}
+	_ = savePreAuth(token, data)
+}
+
+func revokePreAuth(token string) {
+	_ = common.STATES.Delete(preAuthKey(token))
+}
@@ -0,0 +1,65 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+)
+
+const (
+	recoveryCodeCount = 10
+	// 恢复码字符集去掉了容易混淆的 0/o、1/l/i
+	recoveryCodeAlphabet = "23456789abcdefghjkmnpqrstuvwxyz"
+)
+
+// generateRecoveryCodes 生成一组恢复码，返回明文（只展示一次）与保存用的摘要
+func generateRecoveryCodes() ([]string, string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		buf := make([]byte, 10)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, "", err
+		}
+		for j, b := range buf {
+			buf[j] = recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)]
+		}
+		codes[i] = string(buf[:5]) + "-" + string(buf[5:])
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	stored, err := json.Marshal(hashes)
+	if err != nil {
+		return nil, "", err
+	}
+	return codes, string(stored), nil
+}
+
+// hashRecoveryCode 忽略大小写与分隔符，用户可以原样或去掉短横线输入
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func recoveryHashes(stored string) []string {
+	var hashes []string
+	if stored != "" {
+		_ = json.Unmarshal([]byte(stored), &hashes)
+	}
+	return hashes
+}
+
+// useRecoveryCode 匹配成功时返回移除该恢复码后的摘要列表
+func useRecoveryCode(stored string, code string) (string, bool) {
+	hashes := recoveryHashes(stored)
+	target := hashRecoveryCode(code)
+	for i, hash := range hashes {
+		if hash == target {
+			remaining, _ := json.Marshal(append(hashes[:i:i], hashes[i+1:]...))
+			return string(remaining), true
+		}
+	}
+	return stored, false
+}
@@ -0,0 +1,269 @@
+package security
+
+import (
+	"encoding/base32"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+)
+
+func setupDB(t *testing.T) {
+	testutil.SetupDB(t, &model.EnterpriseConfig{}, &model.Enterprise{}, &model.User{},
+		&model.UserTwoFactor{}, &model.VerificationCode{}, &model.UserSession{},
+		&model.LoginThrottle{}, &model.PasswordHistory{})
+}
+
+func createUser(t *testing.T, role int64, email string) *model.User {
+	user := &model.User{Username: email, Email: email, Nickname: "admin", Password: "x", Eid: 1,
+		Type: model.UserTypeInternal, Role: role}
+	if err := user.Create(); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// RFC 6238 附录 B 的 SHA1 测试向量（取后 6 位）
+func TestTOTPCode(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	cases := map[int64]string{59: "287082", 1111111109: "081804", 1234567890: "005924", 2000000000: "279037"}
+	for unix, want := range cases {
+		got, err := TOTPCode(secret, unix/totpPeriod)
+		if err != nil || got != want {
+			t.Fatalf("TOTPCode(%d) = %s, %v; want %s", unix, got, err, want)
+		}
+	}
+}
+
+func TestTOTPLogin(t *testing.T) {
+	setupDB(t)
+	user := createUser(t, model.RoleAdminUser, "admin@example.com")
+
+	if challenge, err := BeginLogin(user); err != nil || challenge != nil {
+		t.Fatalf("2fa should be optional by default: %+v %v", challenge, err)
+	}
+
+	setup, err := SetupTOTP(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := time.Now().Unix() / totpPeriod
+	code, _ := TOTPCode(setup.Secret, step)
+	recoveryCodes, err := EnableTOTP(user, code)
+	if err != nil || len(recoveryCodes) != recoveryCodeCount {
+		t.Fatalf("enable: %v %v", recoveryCodes, err)
+	}
+
+	challenge, err := BeginLogin(user)
+	if err != nil || challenge == nil || challenge.Methods[0] != model.TwoFactorMethodTOTP {
+		t.Fatalf("expected totp challenge: %+v %v", challenge, err)
+	}
+	// 启用时使用过的动态码不能再次用于登录
+	if _, _, err := VerifyLogin(challenge.PreAuthToken, model.TwoFactorMethodTOTP, code); err != ErrInvalidCode {
+		t.Fatalf("expected replayed code to be rejected, got %v", err)
+	}
+	next, _ := TOTPCode(setup.Secret, step+1)
+	logged, _, err := VerifyLogin(challenge.PreAuthToken, model.TwoFactorMethodTOTP, next)
+	if err != nil || logged.UserID != user.UserID {
+		t.Fatalf("verify: %v", err)
+	}
+	if _, _, err := VerifyLogin(challenge.PreAuthToken, model.TwoFactorMethodTOTP, next); err != ErrInvalidPreAuthToken {
+		t.Fatalf("pre-auth token should be single use, got %v", err)
+	}
+
+	// 恢复码只能使用一次，输错次数用尽后令牌失效
+	challenge, _ = BeginLogin(user)
+	if _, _, err := VerifyLogin(challenge.PreAuthToken, model.TwoFactorMethodRecovery, recoveryCodes[0]); err != nil {
+		t.Fatalf("recovery code: %v", err)
+	}
+	challenge, _ = BeginLogin(user)
+	for i := 0; i < preAuthMaxAttempts; i++ {
+		if _, _, err := VerifyLogin(challenge.PreAuthToken, model.TwoFactorMethodRecovery, recoveryCodes[0]); err != ErrInvalidCode {
+			t.Fatalf("attempt %d: expected ErrInvalidCode, got %v", i, err)
+		}
+	}
+	if _, _, err := VerifyLogin(challenge.PreAuthToken, model.TwoFactorMethodRecovery, recoveryCodes[1]); err != ErrInvalidPreAuthToken {
+		t.Fatalf("expected token revoked after too many attempts, got %v", err)
+	}
+
+	status, _ := GetStatus(user)
+	if !status.Enabled || status.RecoveryCodesRemaining != recoveryCodeCount-1 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+}
+
+func TestRequiredForAdmins(t *testing.T) {
+	setupDB(t)
+	var sent string
+	send := sendCodeEmail
+	t.Cleanup(func() { sendCodeEmail = send })
+	sendCodeEmail = func(eid int64, to string, code string, minutes int) error {
+		sent = code
+		return nil
+	}
+
+	model.DB.Create(&model.EnterpriseConfig{EID: 1, Type: model.EnterpriseConfigTypeSecurity, Enabled: true,
+		Content: `{"two_factor_required_for_admins":true}`})
+	admin := createUser(t, model.RoleAdminUser, "admin@example.com")
+	member := createUser(t, model.RoleCommonUser, "member@example.com")
+
+	if challenge, _ := BeginLogin(member); challenge != nil {
+		t.Fatal("common users are not affected by the admin policy")
+	}
+	challenge, err := BeginLogin(admin)
+	if err != nil || challenge == nil || !challenge.EnrollRequired {
+		t.Fatalf("expected enroll challenge: %+v %v", challenge, err)
+	}
+
+	if err := SendLoginEmailCode(challenge.PreAuthToken); err != nil || sent == "" {
+		t.Fatalf("send email code: %v", err)
+	}
+	if _, _, err := VerifyLogin(challenge.PreAuthToken, model.TwoFactorMethodEmail, sent); err != nil {
+		t.Fatalf("email login: %v", err)
+	}
+
+	// 登录过程中设置 TOTP
+	challenge, _ = BeginLogin(admin)
+	setup, err := SetupLoginTOTP(challenge.PreAuthToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := TOTPCode(setup.Secret, time.Now().Unix()/totpPeriod)
+	_, recoveryCodes, err := VerifyLogin(challenge.PreAuthToken, model.TwoFactorMethodTOTP, code)
+	if err != nil || len(recoveryCodes) != recoveryCodeCount {
+		t.Fatalf("enroll during login: %v %v", recoveryCodes, err)
+	}
+	if err := Disable(admin, model.TwoFactorMethodRecovery, recoveryCodes[0]); err != ErrTwoFactorMandatory {
+		t.Fatalf("expected ErrTwoFactorMandatory, got %v", err)
+	}
+	if err := Reset(1, admin.UserID); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := GetStatus(admin); status.Enabled || !status.Required {
+		t.Fatalf("unexpected status after reset: %+v", status)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	setupDB(t)
+	model.DB.Create(&model.EnterpriseConfig{EID: 1, Type: model.EnterpriseConfigTypeSecurity, Enabled: true,
+		Content: `{"max_sessions_per_user":2}`})
+	user := createUser(t, model.RoleCommonUser, "member@example.com")
+
+	tokens := make([]string, 3)
+	for i := range tokens {
+		if err := StartSession(user, "10.0.0.1", "Mozilla/5.0 (Windows NT 10.0) Chrome/120.0 Safari/537.36"); err != nil {
+			t.Fatal(err)
+		}
+		tokens[i] = user.AccessToken
+		// 保证会话的最近活跃时间不同
+		model.DB.Model(&model.UserSession{}).Where("token_hash = ?", model.HashAccessToken(tokens[i])).
+			Update("last_seen_time", int64(i+1))
+	}
+
+	// 超出并发数限制时最早的会话被踢下线，其余会话同时有效
+	if model.ValidateAccessToken(tokens[0]) != nil {
+		t.Fatal("oldest session should have been evicted")
+	}
+	for _, token := range tokens[1:] {
+		if u := model.ValidateAccessToken(token); u == nil || u.UserID != user.UserID {
+			t.Fatalf("session should be valid: %s", token)
+		}
+	}
+	sessions, _ := model.GetUserSessions(1, user.UserID)
+	if len(sessions) != 2 || sessions[0].Device != "Chrome on Windows" || sessions[0].IP != "10.0.0.1" {
+		t.Fatalf("unexpected sessions: %+v", sessions)
+	}
+
+	if err := model.DeleteUserSessionByToken(tokens[1]); err != nil {
+		t.Fatal(err)
+	}
+	if model.ValidateAccessToken(tokens[1]) != nil || model.ValidateAccessToken(tokens[2]) == nil {
+		t.Fatal("logout should only end the current session")
+	}
+	if err := user.InvalidateAccessToken(); err != nil {
+		t.Fatal(err)
+	}
+	if model.ValidateAccessToken(tokens[2]) != nil {
+		t.Fatal("force logout should end all sessions")
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	setupDB(t)
+	model.DB.Create(&model.EnterpriseConfig{EID: 1, Type: model.EnterpriseConfigTypeSecurity, Enabled: true,
+		Content: `{"password_policy":{"min_length":8,"require_uppercase":true,"require_digit":true,"require_symbol":true,"expire_days":30,"history_count":3}}`})
+	user := createUser(t, model.RoleCommonUser, "member@example.com")
+
+	for _, weak := range []string{"Ab1!", "abcdefg1!", "Abcdefgh!", "Abcdefgh1"} {
+		if err := ChangePassword(user, weak); !errors.Is(err, ErrWeakPassword) {
+			t.Fatalf("%s: expected ErrWeakPassword, got %v", weak, err)
+		}
+	}
+	for _, password := range []string{"Passw0rd!1", "Passw0rd!2", "Passw0rd!3"} {
+		if err := ChangePassword(user, password); err != nil {
+			t.Fatalf("%s: %v", password, err)
+		}
+	}
+	// 最近 3 次的密码不能重复使用，更早的可以
+	for _, reused := range []string{"Passw0rd!1", "Passw0rd!2", "Passw0rd!3"} {
+		if err := ChangePassword(user, reused); err != ErrPasswordReused {
+			t.Fatalf("%s: expected ErrPasswordReused, got %v", reused, err)
+		}
+	}
+	if err := ChangePassword(user, "Passw0rd!4"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ChangePassword(user, "Passw0rd!1"); err != nil {
+		t.Fatalf("password older than the history should be allowed: %v", err)
+	}
+	if user.VerifyPassword("Passw0rd!1") != nil {
+		t.Fatal("password was not changed")
+	}
+
+	if expired, err := PasswordExpired(user); err != nil || expired {
+		t.Fatalf("fresh password should not expire: %v", err)
+	}
+	user.PasswordUpdatedTime = time.Now().Add(-31 * 24 * time.Hour).UnixMilli()
+	if expired, _ := PasswordExpired(user); !expired {
+		t.Fatal("password should have expired")
+	}
+}
+
+func TestLockout(t *testing.T) {
+	setupDB(t)
+	model.DB.Create(&model.EnterpriseConfig{EID: 1, Type: model.EnterpriseConfigTypeSecurity, Enabled: true,
+		Content: `{"lockout":{"max_failures":3,"lock_minutes":5,"max_lock_minutes":15,"captcha_after":2}}`})
+
+	status, err := RecordLoginFailure(1, "Member@example.com")
+	if err != nil || status.CaptchaRequired || status.LockedUntil != 0 {
+		t.Fatalf("unexpected status after first failure: %+v %v", status, err)
+	}
+	status, _ = RecordLoginFailure(1, "member@example.com")
+	if !status.CaptchaRequired {
+		t.Fatal("captcha should be required after 2 failures")
+	}
+	status, _ = RecordLoginFailure(1, "member@example.com")
+	if status.LockedUntil == 0 {
+		t.Fatal("account should be locked after 3 failures")
+	}
+	if _, err := CheckLogin(1, "member@example.com"); err != ErrAccountLocked {
+		t.Fatalf("expected ErrAccountLocked, got %v", err)
+	}
+
+	policy := LockoutPolicy{LockMinutes: 5, MaxLockMinutes: 15}
+	for n, want := range map[int]time.Duration{1: 5 * time.Minute, 2: 10 * time.Minute, 3: 15 * time.Minute, 10: 15 * time.Minute} {
+		if got := lockDuration(policy, n); got != want {
+			t.Fatalf("lockDuration(%d) = %v, want %v", n, got, want)
+		}
+	}
+
+	if err := RecordLoginSuccess(1, "member@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if status, err := CheckLogin(1, "member@example.com"); err != nil || status.CaptchaRequired {
+		t.Fatalf("success should clear the lockout: %+v %v", status, err)
+	}
+}
@@ -0,0 +1,45 @@
+package security
+
+import (
+	"time"
+
+	"github.com/53AI/53AIHub/common/utils"
+	"github.com/53AI/53AIHub/common/utils/jwt"
+	"github.com/53AI/53AIHub/model"
+)
+
+const maxUserAgentLength = 512
+
+// StartSession 签发新的访问令牌（写入 user.AccessToken）并记录登录会话，
+// 超过企业并发会话数限制时踢掉最早活跃的会话
+func StartSession(user *model.User, ip string, userAgent string) error {
+	if err := user.RefreshAccessToken(); err != nil {
+		return err
+	}
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	now := time.Now().UTC()
+	session := &model.UserSession{
+		Eid:          user.Eid,
+		UserID:       user.UserID,
+		TokenHash:    model.HashAccessToken(user.AccessToken),
+		Device:       utils.ParseDevice(userAgent),
+		IP:           ip,
+		UserAgent:    userAgent,
+		LastSeenTime: now.UnixMilli(),
+		ExpiresAt:    now.Add(jwt.UserTokenTTL).UnixMilli(),
+	}
+	if err := model.CreateUserSession(session); err != nil {
+		return err
+	}
+
+	cfg, err := LoadConfig(user.Eid)
+	if err != nil {
+		return err
+	}
+	if cfg.MaxSessionsPerUser > 0 {
+		return model.TrimUserSessions(user.Eid, user.UserID, cfg.MaxSessionsPerUser)
+	}
+	return nil
+}
@@ -0,0 +1,430 @@
+package security
+
+import (
+	"errors"
+	"time"
+
+	"github.com/53AI/53AIHub/common"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service"
+)
+
+var (
+	ErrInvalidCode        = errors.New("invalid two-factor verification code")
+	ErrNotEnabled         = errors.New("two-factor authentication is not enabled")
+	ErrAlreadyEnabled     = errors.New("two-factor authentication is already enabled with this method")
+	ErrSetupRequired      = errors.New("two-factor setup has not been started")
+	ErrMethodNotAllowed   = errors.New("two-factor method is not available for this account")
+	ErrEmailRequired      = errors.New("an email address is required for email verification")
+	ErrTwoFactorMandatory = errors.New("two-factor authentication is mandatory for administrators")
+)
+
+const (
+	defaultIssuer   = "53AI Hub"
+	emailCodeLength = 6
+	emailCodeTTL    = 10 * time.Minute
+)
+
+// 发送邮件验证码，测试时替换
+var sendCodeEmail = service.SendVerificationCodeEmail
+
+// Status 用户两步验证状态
+type Status struct {
+	Enabled  bool   `json:"enabled"`
+	Method   string `json:"method"`
+	Required bool   `json:"required"` // 企业要求管理员必须开启
+	// RecoveryCodesRemaining 剩余可用的恢复码数量
+	RecoveryCodesRemaining int `json:"recovery_codes_remaining"`
+}
+
+// Challenge 需要两步验证时登录接口返回的内容
+type Challenge struct {
+	PreAuthToken string   `json:"pre_auth_token"`
+	Methods      []string `json:"methods"`
+	// EnrollRequired 企业强制开启但用户尚未设置：可通过邮箱验证码登录，或先设置 TOTP 再登录
+	EnrollRequired bool `json:"enroll_required"`
+}
+
+// TOTPSetup 待确认的 TOTP 密钥，otpauth_uri 供前端生成二维码
+type TOTPSetup struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"`
+}
+
+// isRequired 企业要求管理员（RoleAdminUser 及以上）必须通过两步验证
+func isRequired(user *model.User) (bool, error) {
+	if user.Role < model.RoleAdminUser {
+		return false, nil
+	}
+	cfg, err := LoadConfig(user.Eid)
+	if err != nil {
+		return false, err
+	}
+	return cfg.TwoFactorRequiredForAdmins, nil
+}
+
+// allowedMethods 登录时可用的验证方式，返回空表示无需两步验证
+func allowedMethods(user *model.User, tf *model.UserTwoFactor) ([]string, bool, error) {
+	if tf != nil && tf.Enabled {
+		return []string{tf.Method, model.TwoFactorMethodRecovery}, false, nil
+	}
+	required, err := isRequired(user)
+	if err != nil || !required {
+		return nil, false, err
+	}
+	methods := []string{model.TwoFactorMethodTOTP}
+	if user.Email != "" {
+		methods = append([]string{model.TwoFactorMethodEmail}, methods...)
+	}
+	return methods, true, nil
+}
+
+func contains(methods []string, method string) bool {
+	for _, m := range methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func GetStatus(user *model.User) (*Status, error) {
+	tf, err := model.GetUserTwoFactor(user.Eid, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	required, err := isRequired(user)
+	if err != nil {
+		return nil, err
+	}
+	status := &Status{Required: required}
+	if tf != nil && tf.Enabled {
+		status.Enabled = true
+		status.Method = tf.Method
+		status.RecoveryCodesRemaining = len(recoveryHashes(tf.RecoveryCodes))
+	}
+	return status, nil
+}
+
+// BeginLogin 密码校验通过后调用，需要两步验证时签发预认证令牌，否则返回 nil
+func BeginLogin(user *model.User) (*Challenge, error) {
+	tf, err := model.GetUserTwoFactor(user.Eid, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	methods, enroll, err := allowedMethods(user, tf)
+	if err != nil || len(methods) == 0 {
+		return nil, err
+	}
+	token, err := issuePreAuthToken(user.Eid, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return &Challenge{PreAuthToken: token, Methods: methods, EnrollRequired: enroll}, nil
+}
+
+func preAuthUser(token string) (*preAuth, *model.User, *model.UserTwoFactor, error) {
+	data, err := loadPreAuth(token)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	user, err := model.GetUserByID(data.UserID)
+	if err != nil || user.Eid != data.Eid || user.Status == model.UserStatusDisabled {
+		revokePreAuth(token)
+		return nil, nil, nil, ErrInvalidPreAuthToken
+	}
+	tf, err := model.GetUserTwoFactor(user.Eid, user.UserID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return data, user, tf, nil
+}
+
+// SendLoginEmailCode 登录第二步发送邮箱验证码
+func SendLoginEmailCode(token string) error {
+	_, user, tf, err := preAuthUser(token)
+	if err != nil {
+		return err
+	}
+	methods, _, err := allowedMethods(user, tf)
+	if err != nil {
+		return err
+	}
+	if !contains(methods, model.TwoFactorMethodEmail) {
+		return ErrMethodNotAllowed
+	}
+	return sendEmailCode(user.Eid, user.Email)
+}
+
+// SetupLoginTOTP 企业强制开启但尚未设置的管理员，在登录过程中设置 TOTP
+func SetupLoginTOTP(token string) (*TOTPSetup, error) {
+	_, user, tf, err := preAuthUser(token)
+	if err != nil {
+		return nil, err
+	}
+	if tf != nil && tf.Enabled {
+		return nil, ErrAlreadyEnabled
+	}
+	return SetupTOTP(user)
+}
+
+// VerifyLogin 校验登录第二步，成功后令牌作废；登录过程中完成 TOTP 设置时同时返回恢复码
+func VerifyLogin(token string, method string, code string) (*model.User, []string, error) {
+	data, user, tf, err := preAuthUser(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	methods, enroll, err := allowedMethods(user, tf)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !contains(methods, method) {
+		return nil, nil, ErrMethodNotAllowed
+	}
+
+	var recoveryCodes []string
+	ok := false
+	switch {
+	case !enroll:
+		ok, err = verifyFactor(user, tf, method, code)
+	case method == model.TwoFactorMethodEmail:
+		ok, err = verifyEmailCode(user.Email, code)
+	default:
+		recoveryCodes, err = EnableTOTP(user, code)
+		ok = err == nil
+		if errors.Is(err, ErrInvalidCode) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		recordFailedAttempt(token, data)
+		return nil, nil, ErrInvalidCode
+	}
+	revokePreAuth(token)
+	return user, recoveryCodes, nil
+}
+
+// verifyFactor 使用已开启的方式或恢复码校验
+func verifyFactor(user *model.User, tf *model.UserTwoFactor, method string, code string) (bool, error) {
+	switch method {
+	case model.TwoFactorMethodTOTP:
+		if tf.Method != model.TwoFactorMethodTOTP {
+			return false, nil
+		}
+		step, ok := ValidateTOTP(tf.Secret, code, time.Now(), tf.LastUsedStep)
+		if !ok {
+			return false, nil
+		}
+		tf.LastUsedStep = step
+		return true, model.SaveUserTwoFactor(tf)
+	case model.TwoFactorMethodEmail:
+		if tf.Method != model.TwoFactorMethodEmail {
+			return false, nil
+		}
+		return verifyEmailCode(user.Email, code)
+	case model.TwoFactorMethodRecovery:
+		remaining, ok := useRecoveryCode(tf.RecoveryCodes, code)
+		if !ok {
+			return false, nil
+		}
+		tf.RecoveryCodes = remaining
+		return true, model.SaveUserTwoFactor(tf)
+	}
+	return false, nil
+}
+
+func sendEmailCode(eid int64, to string) error {
+	if to == "" {
+		return ErrEmailRequired
+	}
+	code, err := common.GenerateRandomCode(emailCodeLength)
+	if err != nil {
+		return err
+	}
+	expiresAt := time.Now().UTC().Add(emailCodeTTL).UnixMilli()
+	if err := model.SaveVerificationCode(model.VerificationCodeTypeEmail, to, code, expiresAt); err != nil {
+		return err
+	}
+	_ = common.RedisSet("email_verification:"+to, code, emailCodeTTL)
+	return sendCodeEmail(eid, to, code, int(emailCodeTTL/time.Minute))
+}
+
+// verifyEmailCode 校验通过后作废验证码
+func verifyEmailCode(to string, code string) (bool, error) {
+	if to == "" {
+		return false, ErrEmailRequired
+	}
+	if valid, err := common.VerifyEmailCode(to, code); err != nil || !valid {
+		return false, nil
+	}
+	return true, model.ExpireVerificationCode(model.VerificationCodeTypeEmail, to)
+}
+
+// SendEmailCode 向当前用户邮箱发送验证码，用于开启或关闭邮箱两步验证
+func SendEmailCode(user *model.User) error {
+	return sendEmailCode(user.Eid, user.Email)
+}
+
+// SetupTOTP 生成待确认的 TOTP 密钥；已开启邮箱验证时确认后切换为 TOTP
+func SetupTOTP(user *model.User) (*TOTPSetup, error) {
+	tf, err := model.GetUserTwoFactor(user.Eid, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if tf != nil && tf.Enabled && tf.Method == model.TwoFactorMethodTOTP {
+		return nil, ErrAlreadyEnabled
+	}
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if tf == nil {
+		tf = &model.UserTwoFactor{Eid: user.Eid, UserID: user.UserID, Method: model.TwoFactorMethodTOTP}
+	}
+	tf.Secret = secret
+	if err := model.SaveUserTwoFactor(tf); err != nil {
+		return nil, err
+	}
+
+	issuer, _ := model.GetEnterpriseName(user.Eid)
+	if issuer == "" {
+		issuer = defaultIssuer
+	}
+	account := user.Email
+	if account == "" {
+		account = user.Username
+	}
+	return &TOTPSetup{Secret: secret, OtpauthURI: TOTPURI(issuer, account, secret)}, nil
+}
+
+// EnableTOTP 校验动态码确认密钥，开启 TOTP 并返回新的恢复码
+func EnableTOTP(user *model.User, code string) ([]string, error) {
+	tf, err := model.GetUserTwoFactor(user.Eid, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if tf == nil || tf.Secret == "" {
+		return nil, ErrSetupRequired
+	}
+	if tf.Enabled && tf.Method == model.TwoFactorMethodTOTP {
+		return nil, ErrAlreadyEnabled
+	}
+	step, ok := ValidateTOTP(tf.Secret, code, time.Now(), 0)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	codes, stored, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	tf.Method = model.TwoFactorMethodTOTP
+	tf.Enabled = true
+	tf.LastUsedStep = step
+	tf.RecoveryCodes = stored
+	if err := model.SaveUserTwoFactor(tf); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// EnableEmail 校验邮箱验证码后开启邮箱两步验证，返回新的恢复码
+func EnableEmail(user *model.User, code string) ([]string, error) {
+	tf, err := model.GetUserTwoFactor(user.Eid, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if tf != nil && tf.Enabled && tf.Method == model.TwoFactorMethodEmail {
+		return nil, ErrAlreadyEnabled
+	}
+	ok, err := verifyEmailCode(user.Email, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	codes, stored, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if tf == nil {
+		tf = &model.UserTwoFactor{Eid: user.Eid, UserID: user.UserID}
+	}
+	tf.Method = model.TwoFactorMethodEmail
+	tf.Enabled = true
+	tf.Secret = ""
+	tf.LastUsedStep = 0
+	tf.RecoveryCodes = stored
+	if err := model.SaveUserTwoFactor(tf); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes 校验当前验证方式后重新生成恢复码，旧恢复码全部失效
+func RegenerateRecoveryCodes(user *model.User, method string, code string) ([]string, error) {
+	tf, err := model.GetUserTwoFactor(user.Eid, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if tf == nil || !tf.Enabled {
+		return nil, ErrNotEnabled
+	}
+	ok, err := verifyFactor(user, tf, method, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	codes, stored, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	tf.RecoveryCodes = stored
+	if err := model.SaveUserTwoFactor(tf); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable 用户自行关闭两步验证；企业强制开启时管理员不能关闭
+func Disable(user *model.User, method string, code string) error {
+	required, err := isRequired(user)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrTwoFactorMandatory
+	}
+	tf, err := model.GetUserTwoFactor(user.Eid, user.UserID)
+	if err != nil {
+		return err
+	}
+	if tf == nil || !tf.Enabled {
+		return ErrNotEnabled
+	}
+	ok, err := verifyFactor(user, tf, method, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidCode
+	}
+	return model.DeleteUserTwoFactor(user.Eid, user.UserID)
+}
+
+// Reset 管理员为丢失设备的成员重置两步验证，成员下次登录时重新设置
+func Reset(eid int64, userID int64) error {
+	tf, err := model.GetUserTwoFactor(eid, userID)
+	if err != nil {
+		return err
+	}
+	if tf == nil {
+		return ErrNotEnabled
+	}
+	return model.DeleteUserTwoFactor(eid, userID)
+}
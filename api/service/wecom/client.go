@@ -0,0 +1,129 @@
+package wecom
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/orgsync"
+)
+
+// 企业微信服务端接口地址，测试时替换为本地模拟服务
+var apiBase = "https://qyapi.weixin.qq.com"
+
+// 成员状态：1 已激活，2 已禁用，4 未激活，5 退出企业
+const (
+	userStatusDisabled = 2
+	userStatusExited   = 5
+)
+
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
+// Client 使用代开发 / 第三方应用授权企业的 access_token 读取通讯录，实现 orgsync.Provider
+type Client struct {
+	accessToken string
+}
+
+var _ orgsync.Provider = (*Client)(nil)
+
+// NewClient 授权企业的 access_token 由应用授权流程维护
+func NewClient(corp *model.WecomCorp) *Client {
+	return &Client{accessToken: corp.AccessToken}
+}
+
+func (c *Client) Source() int {
+	return model.DepartmentFromWecom
+}
+
+type apiError struct {
+	Errcode int    `json:"errcode"`
+	Errmsg  string `json:"errmsg"`
+}
+
+func (c *Client) get(path string, query url.Values, result interface{}) error {
+	query.Set("access_token", c.accessToken)
+	resp, err := httpClient.Get(apiBase + path + "?" + query.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("wecom api status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// Departments 获取应用可见范围内的全部部门
+func (c *Client) Departments() ([]*orgsync.Department, error) {
+	var resp struct {
+		apiError
+		Department []struct {
+			ID       int64  `json:"id"`
+			Name     string `json:"name"`
+			ParentID int64  `json:"parentid"`
+			Order    int    `json:"order"`
+		} `json:"department"`
+	}
+	if err := c.get("/cgi-bin/department/list", url.Values{}, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Errcode != 0 {
+		return nil, fmt.Errorf("wecom api error %d: %s", resp.Errcode, resp.Errmsg)
+	}
+	departments := make([]*orgsync.Department, 0, len(resp.Department))
+	for _, item := range resp.Department {
+		department := &orgsync.Department{
+			ID:    strconv.FormatInt(item.ID, 10),
+			Name:  item.Name,
+			Order: item.Order,
+		}
+		if item.ParentID > 0 {
+			department.ParentID = strconv.FormatInt(item.ParentID, 10)
+		}
+		departments = append(departments, department)
+	}
+	return departments, nil
+}
+
+// Members 获取部门直属成员，第三方应用获取不到手机号与邮箱
+func (c *Client) Members(departmentID string) ([]*orgsync.Member, error) {
+	var resp struct {
+		apiError
+		UserList []struct {
+			UserID     string  `json:"userid"`
+			Name       string  `json:"name"`
+			Mobile     string  `json:"mobile"`
+			Email      string  `json:"email"`
+			Avatar     string  `json:"avatar"`
+			Department []int64 `json:"department"`
+			Status     int     `json:"status"`
+		} `json:"userlist"`
+	}
+	query := url.Values{"department_id": {departmentID}}
+	if err := c.get("/cgi-bin/user/list", query, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Errcode != 0 {
+		return nil, fmt.Errorf("wecom api error %d: %s", resp.Errcode, resp.Errmsg)
+	}
+	members := make([]*orgsync.Member, 0, len(resp.UserList))
+	for _, item := range resp.UserList {
+		member := &orgsync.Member{
+			ID:       item.UserID,
+			Name:     item.Name,
+			Mobile:   item.Mobile,
+			Email:    item.Email,
+			Avatar:   item.Avatar,
+			Disabled: item.Status == userStatusDisabled || item.Status == userStatusExited,
+		}
+		for _, id := range item.Department {
+			member.DepartmentIDs = append(member.DepartmentIDs, strconv.FormatInt(id, 10))
+		}
+		members = append(members, member)
+	}
+	return members, nil
+}
@@ -0,0 +1,129 @@
+package wecom
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/53AI/53AIHub/internal/testutil"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/orgsync"
+)
+
+// fakeWecom 模拟企业微信服务端的通讯录接口
+type fakeWecom struct {
+	mu    sync.Mutex
+	users map[string]map[string]interface{} // userid -> 成员详情
+}
+
+func newFakeWecom(t *testing.T) *fakeWecom {
+	f := &fakeWecom{users: map[string]map[string]interface{}{
+		"alice": {"userid": "alice", "name": "Alice", "mobile": "13800138000", "department": []int64{2}, "status": 1},
+		"bob":   {"userid": "bob", "name": "Bob", "email": "bob@example.com", "department": []int64{1, 3}, "status": 1},
+		"carol": {"userid": "carol", "name": "Carol", "department": []int64{3}, "status": userStatusDisabled},
+	}}
+	reply := func(w http.ResponseWriter, r *http.Request, v map[string]interface{}) {
+		if r.URL.Query().Get("access_token") != "corp-token" {
+			v = map[string]interface{}{"errcode": 40014, "errmsg": "invalid access_token"}
+		}
+		if _, ok := v["errcode"]; !ok {
+			v["errcode"] = 0
+		}
+		_ = json.NewEncoder(w).Encode(v)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cgi-bin/department/list", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, r, map[string]interface{}{"department": []map[string]interface{}{
+			{"id": 1, "name": "Acme", "parentid": 0, "order": 1},
+			{"id": 2, "name": "Engineering", "parentid": 1, "order": 2},
+			{"id": 3, "name": "Sales", "parentid": 1, "order": 3},
+		}})
+	})
+	mux.HandleFunc("/cgi-bin/user/list", func(w http.ResponseWriter, r *http.Request) {
+		deptID, _ := strconv.ParseInt(r.URL.Query().Get("department_id"), 10, 64)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		list := make([]map[string]interface{}, 0)
+		for _, u := range f.users {
+			for _, id := range u["department"].([]int64) {
+				if id == deptID {
+					list = append(list, u)
+				}
+			}
+		}
+		reply(w, r, map[string]interface{}{"userlist": list})
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	apiBase = server.URL
+	return f
+}
+
+func setupDB(t *testing.T) {
+	testutil.SetupDB(t, &model.User{}, &model.MemberBinding{}, &model.Department{}, &model.MemberDepartmentRelation{})
+}
+
+func TestSyncOrganization(t *testing.T) {
+	f := newFakeWecom(t)
+	setupDB(t)
+	client := NewClient(&model.WecomCorp{AccessToken: "corp-token"})
+
+	result, err := orgsync.Sync(1, client)
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if result.Departments != 3 || result.Created != 3 {
+		t.Fatalf("unexpected result: %+v", *result)
+	}
+
+	departments, _ := model.GetDepartmentsByEID(1, model.DepartmentFromWecom)
+	byID := map[string]*model.Department{}
+	for _, department := range departments {
+		byID[department.BindValue] = department
+	}
+	if byID["1"] == nil || byID["2"] == nil || byID["2"].PDID != byID["1"].DID || byID["3"].Name != "Sales" {
+		t.Fatalf("department tree not synced: %+v", departments)
+	}
+
+	bob, _ := model.GetMemberBindingByBindValue(1, "bob", model.MemberBindingSourceWeChat)
+	if bob == nil || bob.MID != 0 || bob.Status != model.MemberBindingStatusInactive {
+		t.Fatalf("unexpected binding: %+v", bob)
+	}
+	dids, _ := model.GetMemberDidsByBID(1, bob.ID)
+	if len(dids) != 2 {
+		t.Fatalf("bob should belong to 2 departments: %v", dids)
+	}
+	carol, _ := model.GetMemberBindingByBindValue(1, "carol", model.MemberBindingSourceWeChat)
+	if carol == nil || carol.Status != model.MemberBindingStatusDisabled {
+		t.Fatalf("disabled member should be synced as disabled: %+v", carol)
+	}
+
+	// 退出企业的成员
+	f.mu.Lock()
+	f.users["bob"]["status"] = userStatusExited
+	f.mu.Unlock()
+	if result, err = orgsync.Sync(1, client); err != nil {
+		t.Fatalf("second sync: %v", err)
+	}
+	if result.Created != 0 {
+		t.Fatalf("unexpected second result: %+v", *result)
+	}
+	bob, _ = model.GetMemberBindingByBindValue(1, "bob", model.MemberBindingSourceWeChat)
+	if bob.Status != model.MemberBindingStatusDisabled {
+		t.Fatalf("exited member should be disabled: %+v", bob)
+	}
+}
+
+func TestSyncAPIError(t *testing.T) {
+	newFakeWecom(t)
+	setupDB(t)
+	client := NewClient(&model.WecomCorp{AccessToken: "expired"})
+	if _, err := orgsync.Sync(1, client); err == nil {
+		t.Fatal("sync should fail when the access token is rejected")
+	}
+}
@@ -0,0 +1,62 @@
+package tasks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/53AI/53AIHub/common"
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/model"
+	"github.com/53AI/53AIHub/service/ldap"
+)
+
+// ldapLastSync 各企业上次定时同步的时间
+var ldapLastSync = make(map[int64]time.Time)
+
+// StartLDAPSyncTask starts the LDAP directory sync task
+// Checks every minute and syncs enterprises whose sync_interval has elapsed
+func StartLDAPSyncTask() {
+	go func() {
+		ticker := time.NewTicker(1 * time.Minute)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			syncLDAPDirectories()
+		}
+	}()
+	logger.SysLog("LDAP directory sync task started, checking every minute")
+}
+
+func syncLDAPDirectories() {
+	eids, err := ldap.GetEnabledEids()
+	if err != nil {
+		logger.SysError("Failed to get ldap enabled enterprises: " + err.Error())
+		return
+	}
+
+	now := time.Now()
+	for _, eid := range eids {
+		cfg, err := ldap.LoadConfig(eid)
+		if err != nil {
+			logger.SysErrorf("Failed to load ldap config: eid=%d err=%v", eid, err)
+			continue
+		}
+		if cfg.SyncInterval <= 0 || now.Sub(ldapLastSync[eid]) < time.Duration(cfg.SyncInterval)*time.Minute {
+			continue
+		}
+
+		// 与手动同步共用锁，避免同时同步
+		lockKey := fmt.Sprintf("%s:%d:%d", model.LockOrganizationKeyPre, model.DepartmentFromLDAP, eid)
+		if !common.LOCKER.TryLock(lockKey, 60*5*time.Second) {
+			continue
+		}
+		ldapLastSync[eid] = now
+		result, err := ldap.Sync(eid)
+		common.LOCKER.Unlock(lockKey)
+		if err != nil {
+			logger.SysErrorf("LDAP directory sync failed: eid=%d err=%v", eid, err)
+			continue
+		}
+		logger.SysLogf("LDAP directory sync finished: eid=%d result=%+v", eid, *result)
+	}
+}
@@ -7,4 +7,7 @@ import (
 func Start() {
 	StartOrderExpirationTask(1 * time.Minute)
 	StartChannelUpdateKeyTask()
+	StartLDAPSyncTask()
+	StartSessionCleanupTask(1 * time.Hour)
+	StartScheduleTask()
 }
\ No newline at end of file
@@ -0,0 +1,33 @@
+package tasks
+
+import (
+	"time"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/service/schedule"
+)
+
+// scheduleRunner 由 controller 注入，执行需要复用对话与工作流的转发逻辑
+var scheduleRunner *schedule.Runner
+
+func SetScheduleRunner(r *schedule.Runner) {
+	scheduleRunner = r
+}
+
+// StartScheduleTask starts the scheduled agent and workflow runs
+// Checks every minute and fires schedules whose next_run_time has passed
+func StartScheduleTask() {
+	if scheduleRunner == nil {
+		logger.SysError("Schedule runner is not set, scheduled runs are disabled")
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(1 * time.Minute)
+		defer ticker.Stop()
+
+		for now := range ticker.C {
+			scheduleRunner.RunDue(now)
+		}
+	}()
+	logger.SysLog("Schedule task started, checking every minute")
+}
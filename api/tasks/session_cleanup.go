@@ -0,0 +1,29 @@
+package tasks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/53AI/53AIHub/common/logger"
+	"github.com/53AI/53AIHub/model"
+)
+
+// StartSessionCleanupTask starts the expired login session cleanup task
+func StartSessionCleanupTask(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			count, err := model.DeleteExpiredUserSessions()
+			if err != nil {
+				logger.SysError("Failed to delete expired sessions: " + err.Error())
+				continue
+			}
+			if count > 0 {
+				logger.SysLog(fmt.Sprintf("Deleted %d expired sessions", count))
+			}
+		}
+	}()
+	logger.SysLog(fmt.Sprintf("Session cleanup task started, running every %v", interval))
+}